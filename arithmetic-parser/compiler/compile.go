@@ -0,0 +1,184 @@
+package compiler
+
+import (
+	"fmt"
+
+	"github.com/unix-world/smartgoext/arithmetic-parser/lexer"
+	"github.com/unix-world/smartgoext/arithmetic-parser/tree"
+	"github.com/unix-world/smartgoext/arithmetic-parser/value"
+)
+
+// Compile turns a parse tree into a Program. Sub-trees without
+// identifiers in them get folded into constants, by evaluating them
+// with tree.Node.Eval, so a constant sub-tree always gives the same
+// value the tree walker would. That includes built-in function calls
+// with constant arguments: changing value.Builtins after Compile
+// does not affect them.
+//
+// Compile only fails on trees the parser would not produce, or on
+// calls to functions that are not in value.Builtins.
+func Compile(root *tree.Node) (*Program, error) {
+	c := &compiler{prog: &Program{}, names: make(map[string]int)}
+	if err := c.emit(c.fold(root)); err != nil {
+		return nil, err
+	}
+	return c.prog, nil
+}
+
+type compiler struct {
+	prog  *Program
+	names map[string]int
+	depth int
+}
+
+// folded wraps a parse tree node, with a constant
+// value for the nodes that turned out to have one.
+type folded struct {
+	node     *tree.Node
+	constant bool
+	value    value.Value
+	kids     []*folded
+}
+
+func (c *compiler) fold(n *tree.Node) *folded {
+	if n == nil {
+		return nil
+	}
+	f := &folded{node: n}
+	switch n.Op {
+	case lexer.CONSTANT:
+		f.constant = true
+	case lexer.IDENT:
+	case lexer.NOT:
+		f.kids = []*folded{nil, c.fold(n.Right)}
+		f.constant = f.kids[1] != nil && f.kids[1].constant
+	case lexer.CALL, lexer.COND:
+		for _, a := range n.Args {
+			f.kids = append(f.kids, c.fold(a))
+		}
+		f.constant = allConstant(f.kids)
+		if _, ok := value.Builtins[n.Lexeme]; n.Op == lexer.CALL && !ok {
+			f.constant = false // let emit report it
+		}
+	default:
+		f.kids = []*folded{c.fold(n.Left), c.fold(n.Right)}
+		f.constant = allConstant(f.kids)
+	}
+	switch n.Op {
+	case lexer.AND, lexer.OR, lexer.COND:
+		// a constant deciding operand is enough
+		if d := f.kids[0]; !f.constant && d != nil && d.constant && len(f.kids) >= 2 {
+			if b, ok := d.value.(value.Bool); ok {
+				switch {
+				case n.Op == lexer.COND && len(f.kids) == 3 && bool(b):
+					return f.kids[1]
+				case n.Op == lexer.COND && len(f.kids) == 3:
+					return f.kids[2]
+				case n.Op != lexer.COND && bool(b) == (n.Op == lexer.OR):
+					return d
+				}
+			}
+		}
+	}
+	if f.constant {
+		f.value = n.Eval()
+	}
+	return f
+}
+
+func allConstant(kids []*folded) bool {
+	for _, k := range kids {
+		if k == nil || !k.constant {
+			return false
+		}
+	}
+	return true
+}
+
+func (c *compiler) push(in instr, delta int) int {
+	c.prog.code = append(c.prog.code, in)
+	c.depth += delta
+	if c.depth > c.prog.depth {
+		c.prog.depth = c.depth
+	}
+	return len(c.prog.code) - 1
+}
+
+func (c *compiler) emit(f *folded) error {
+	if f == nil {
+		return fmt.Errorf("compile: missing operand")
+	}
+	n := f.node
+	if f.constant {
+		c.prog.consts = append(c.prog.consts, unbox(f.value))
+		c.push(instr{code: opConst, arg: len(c.prog.consts) - 1}, 1)
+		return nil
+	}
+	switch n.Op {
+	case lexer.IDENT:
+		idx, ok := c.names[n.Lexeme]
+		if !ok {
+			idx = len(c.prog.names)
+			c.names[n.Lexeme] = idx
+			c.prog.names = append(c.prog.names, n.Lexeme)
+		}
+		c.push(instr{code: opLoad, arg: idx}, 1)
+	case lexer.CALL:
+		if _, ok := value.Builtins[n.Lexeme]; !ok {
+			return fmt.Errorf("compile: undefined function '%s'", n.Lexeme)
+		}
+		for _, k := range f.kids {
+			if err := c.emit(k); err != nil {
+				return err
+			}
+		}
+		idx := len(c.prog.funcs)
+		c.prog.funcs = append(c.prog.funcs, n.Lexeme)
+		c.push(instr{code: opCall, arg: idx, n: len(f.kids)}, 1-len(f.kids))
+	case lexer.NOT:
+		if err := c.emit(f.kids[1]); err != nil {
+			return err
+		}
+		c.push(instr{code: opNot}, 0)
+	case lexer.AND, lexer.OR:
+		if err := c.emit(f.kids[0]); err != nil {
+			return err
+		}
+		at := c.push(instr{code: opShortCircuit, op: n.Op}, 0)
+		if err := c.emit(f.kids[1]); err != nil {
+			return err
+		}
+		c.push(instr{code: opBinary, op: n.Op}, -1)
+		c.prog.code[at].arg = len(c.prog.code)
+	case lexer.COND:
+		if len(f.kids) != 3 {
+			return fmt.Errorf("compile: malformed conditional")
+		}
+		if err := c.emit(f.kids[0]); err != nil {
+			return err
+		}
+		branch := c.push(instr{code: opBranch}, -1)
+		if err := c.emit(f.kids[1]); err != nil {
+			return err
+		}
+		jump := c.push(instr{code: opJump}, -1)
+		c.prog.code[branch].arg = len(c.prog.code)
+		if err := c.emit(f.kids[2]); err != nil {
+			return err
+		}
+		c.prog.code[jump].arg = len(c.prog.code)
+		c.prog.code[branch].n = len(c.prog.code)
+	case lexer.PLUS, lexer.MINUS, lexer.MULT, lexer.DIV, lexer.REM, lexer.EXP,
+		lexer.EQ, lexer.NE, lexer.LT, lexer.LE, lexer.GT, lexer.GE:
+		if err := c.emit(f.kids[0]); err != nil {
+			return err
+		}
+		if err := c.emit(f.kids[1]); err != nil {
+			return err
+		}
+		c.push(instr{code: opBinary, op: n.Op}, -1)
+	default:
+		return fmt.Errorf("compile: unexpected node %v", n.Op)
+	}
+	return nil
+}
@@ -0,0 +1,157 @@
+package compiler_test
+
+import (
+	"testing"
+
+	"github.com/unix-world/smartgoext/arithmetic-parser/compiler"
+	"github.com/unix-world/smartgoext/arithmetic-parser/lexer"
+	"github.com/unix-world/smartgoext/arithmetic-parser/parser"
+	"github.com/unix-world/smartgoext/arithmetic-parser/tree"
+	"github.com/unix-world/smartgoext/arithmetic-parser/value"
+)
+
+const ruleExpr = "age >= 18 && (country == 1 || country == 2 * 2) && !vip"
+
+func parse(t testing.TB, expr string) *tree.Node {
+	t.Helper()
+	node, err := parser.NewParser(lexer.Lex(expr)).Parse()
+	if err != nil {
+		t.Fatalf("%q: %v", expr, err)
+	}
+	return node
+}
+
+func compile(t testing.TB, expr string) *compiler.Program {
+	t.Helper()
+	prog, err := compiler.Compile(parse(t, expr))
+	if err != nil {
+		t.Fatalf("%q: %v", expr, err)
+	}
+	return prog
+}
+
+func rules() value.Env {
+	return value.NewEnv(map[string]interface{}{
+		"age":     21,
+		"country": 1,
+		"vip":     false,
+		"price":   "19.99",
+		"qty":     3,
+		"rate":    1.5,
+	})
+}
+
+// The tree walker is the reference, the compiled program has to agree with it.
+func TestCompileMatchesEval(t *testing.T) {
+	env := rules()
+	for _, expr := range []string{
+		"1 + 3*4",
+		"2 ^ 3 * 2",
+		"7 / 2",
+		"7 % 3",
+		"1 / 0",
+		"9223372036854775807 + 1",
+		"rate * 2",
+		"price * qty",
+		"round(price * qty * 1.19, 2)",
+		"age >= 18 && country == 1",
+		"vip || age > 65 && country != 1",
+		"!vip && !(age < 18)",
+		"age >= 18 ? 10 : 0",
+		"vip ? 1 : age < 18 ? 2 : 3",
+		"vip && 1/0 == 1",
+		"!vip || 1/0 == 1",
+		"age ? 1 : 2",
+		"max(age, 30) > 25 ? round(2.5) : -1",
+		"unknown + 1",
+		ruleExpr,
+	} {
+		node := parse(t, expr)
+		want := node.EvalEnv(env).String()
+		prog, err := compiler.Compile(node)
+		if err != nil {
+			t.Errorf("%q: %v", expr, err)
+			continue
+		}
+		if got := prog.Run(env).String(); got != want {
+			t.Errorf("%q: compiled program gives %s, tree walker %s", expr, got, want)
+		}
+	}
+}
+
+func TestCompileFoldsConstants(t *testing.T) {
+	for _, tc := range []struct {
+		expr string
+		len  int
+	}{
+		{"1 + 3*4", 1},
+		{"max(1, 2) * round(2.5)", 1},
+		{"age + 2*3", 3},
+		{"1 < 2 ? age : qty", 1},
+		{"1 < 2 || vip", 1},
+		{"1 > 2 && vip", 1},
+		{"1 > 2 || vip", 4},
+	} {
+		if got := compile(t, tc.expr).Len(); got != tc.len {
+			t.Errorf("%q: %d instructions, want %d", tc.expr, got, tc.len)
+		}
+	}
+}
+
+func TestCompileUndefinedFunction(t *testing.T) {
+	if _, err := compiler.Compile(parse(t, "nosuch(1)")); err == nil {
+		t.Error("expecting error for an undefined function")
+	}
+}
+
+func TestProgramRunTyped(t *testing.T) {
+	env := rules()
+	if got, err := compile(t, "age * 2").RunInt(env); err != nil || got != 42 {
+		t.Errorf("RunInt: got %d, %v, want 42", got, err)
+	}
+	if got, err := compile(t, "rate * qty").RunFloat(env); err != nil || got != 4.5 {
+		t.Errorf("RunFloat: got %v, %v, want 4.5", got, err)
+	}
+	if got, err := compile(t, ruleExpr).RunBool(env); err != nil || !got {
+		t.Errorf("RunBool: got %v, %v, want true", got, err)
+	}
+	if _, err := compile(t, "age + 1").RunBool(env); err == nil {
+		t.Error("RunBool: expecting error for an integer result")
+	}
+	if _, err := compile(t, "1 / 0 + age").RunInt(env); err == nil {
+		t.Error("RunInt: expecting error for a division by zero")
+	}
+}
+
+func TestProgramRunDoesNotAllocate(t *testing.T) {
+	prog := compile(t, ruleExpr)
+	env := rules()
+	allocs := testing.AllocsPerRun(1000, func() {
+		if ok, err := prog.RunBool(env); err != nil || !ok {
+			t.Fatalf("got %v, %v, want true", ok, err)
+		}
+	})
+	if allocs != 0 {
+		t.Errorf("%v allocations per run, want 0", allocs)
+	}
+}
+
+func BenchmarkProgramRunBool(b *testing.B) {
+	prog := compile(b, ruleExpr)
+	env := rules()
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		if _, err := prog.RunBool(env); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkTreeEvalEnv(b *testing.B) {
+	node := parse(b, ruleExpr)
+	env := rules()
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		node.EvalEnv(env)
+	}
+}
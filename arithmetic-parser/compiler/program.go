@@ -0,0 +1,169 @@
+package compiler
+
+import (
+	"fmt"
+
+	"github.com/unix-world/smartgoext/arithmetic-parser/lexer"
+	"github.com/unix-world/smartgoext/arithmetic-parser/value"
+)
+
+type opcode uint8
+
+const (
+	opConst        opcode = iota // push consts[arg]
+	opLoad                       // push env[names[arg]]
+	opBinary                     // pop y, pop x, push x op y
+	opNot                        // replace top with !top
+	opShortCircuit               // if top is the Bool that decides op, jump to arg
+	opBranch                     // pop cond: true falls through, false jumps to arg, anything else is an error, pushed, jumping to n
+	opJump                       // jump to arg
+	opCall                       // pop n arguments, push funcs[arg](arguments...)
+)
+
+type instr struct {
+	code opcode
+	op   lexer.TokenType
+	arg  int
+	n    int
+}
+
+// stackSize is how deep a program's stack can get before
+// Run has to allocate one, rather than use the goroutine stack.
+const stackSize = 32
+
+// Program is a compiled expression: a flat list of instructions for
+// a small stack machine. A Program is immutable, so a single one can
+// Run on any number of goroutines at once.
+//
+// Run does not allocate for Int, Float and Bool arithmetic and
+// comparisons. Decimal arithmetic, integer overflow, inexact
+// division, errors and function calls go through package value,
+// exactly like tree.Node.Eval does, and allocate like it does.
+type Program struct {
+	code   []instr
+	consts []slot
+	names  []string
+	funcs  []string
+	depth  int
+}
+
+// Len returns the number of instructions in the program.
+func (p *Program) Len() int {
+	return len(p.code)
+}
+
+// Run evaluates the program, resolving identifiers in env.
+// It gives the same result tree.Node.EvalEnv gives for the
+// parse tree the program was compiled from. Returning the
+// result as a value.Value boxes it, use RunInt, RunFloat or
+// RunBool to avoid even that allocation.
+func (p *Program) Run(env value.Env) value.Value {
+	return p.run(env).value()
+}
+
+// RunInt runs the program, for expressions that produce an Int.
+// Any other result is an error.
+func (p *Program) RunInt(env value.Env) (int, error) {
+	r := p.run(env)
+	if r.kind != kindInt {
+		return 0, resultError("an integer", r)
+	}
+	return r.i, nil
+}
+
+// RunFloat runs the program, for expressions that produce a number.
+// Int and Decimal results get converted to float64, anything
+// else is an error.
+func (p *Program) RunFloat(env value.Env) (float64, error) {
+	r := p.run(env)
+	switch r.kind {
+	case kindFloat:
+		return r.f, nil
+	case kindInt:
+		return float64(r.i), nil
+	}
+	if d, ok := r.v.(value.Decimal); ok {
+		return d.InexactFloat64(), nil
+	}
+	return 0, resultError("a number", r)
+}
+
+// RunBool runs the program, for expressions that produce a Bool,
+// like rule engine conditions. Any other result is an error.
+func (p *Program) RunBool(env value.Env) (bool, error) {
+	r := p.run(env)
+	if r.kind != kindBool {
+		return false, resultError("a boolean", r)
+	}
+	return r.b, nil
+}
+
+func resultError(want string, r slot) error {
+	if e, ok := r.v.(value.Error); ok {
+		return fmt.Errorf("%s", string(e))
+	}
+	return fmt.Errorf("result is not %s: '%v'", want, r.value())
+}
+
+func (p *Program) run(env value.Env) slot {
+	var buf [stackSize]slot
+	stack := buf[:0]
+	if p.depth > stackSize {
+		stack = make([]slot, 0, p.depth)
+	}
+	for pc := 0; pc < len(p.code); pc++ {
+		in := &p.code[pc]
+		switch in.code {
+		case opConst:
+			stack = append(stack, p.consts[in.arg])
+		case opLoad:
+			name := p.names[in.arg]
+			v, ok := env[name]
+			if !ok {
+				v = env.Lookup(name)
+			}
+			stack = append(stack, unbox(v))
+		case opBinary:
+			top := len(stack) - 1
+			stack[top-1] = binary(in.op, stack[top-1], stack[top])
+			stack = stack[:top]
+		case opNot:
+			top := len(stack) - 1
+			if stack[top].kind == kindBool {
+				stack[top].b = !stack[top].b
+			} else {
+				stack[top] = unbox(value.Not(stack[top].value()))
+			}
+		case opShortCircuit:
+			top := stack[len(stack)-1]
+			if top.kind == kindBool && top.b == (in.op == lexer.OR) {
+				pc = in.arg - 1
+			}
+		case opBranch:
+			top := len(stack) - 1
+			cond := stack[top]
+			stack = stack[:top]
+			switch {
+			case cond.kind == kindBool && cond.b:
+			case cond.kind == kindBool:
+				pc = in.arg - 1
+			default:
+				if _, ok := cond.v.(value.Error); !ok {
+					cond = slot{kind: kindBoxed, v: value.Error(fmt.Sprintf("condition is not a boolean: '%v'", cond.value()))}
+				}
+				stack = append(stack, cond)
+				pc = in.n - 1
+			}
+		case opJump:
+			pc = in.arg - 1
+		case opCall:
+			base := len(stack) - in.n
+			args := make([]value.Value, in.n)
+			for i := range args {
+				args[i] = stack[base+i].value()
+			}
+			stack = append(stack[:base], unbox(value.Call(p.funcs[in.arg], args)))
+		}
+	}
+	return stack[0]
+}
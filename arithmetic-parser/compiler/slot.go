@@ -0,0 +1,182 @@
+package compiler
+
+import (
+	"math"
+
+	"github.com/unix-world/smartgoext/arithmetic-parser/lexer"
+	"github.com/unix-world/smartgoext/arithmetic-parser/value"
+)
+
+type kind uint8
+
+const (
+	kindInt kind = iota
+	kindFloat
+	kindBool
+	kindBoxed
+)
+
+// slot is one entry of the evaluation stack. Int, Float and Bool
+// values live unboxed in i, f and b, so the common arithmetic does
+// not allocate. Anything else (Decimal, Error) stays a value.Value.
+type slot struct {
+	kind kind
+	b    bool
+	i    int
+	f    float64
+	v    value.Value
+}
+
+func unbox(v value.Value) slot {
+	switch v := v.(type) {
+	case value.Int:
+		return slot{kind: kindInt, i: int(v)}
+	case value.Float:
+		return slot{kind: kindFloat, f: float64(v)}
+	case value.Bool:
+		return slot{kind: kindBool, b: bool(v)}
+	}
+	return slot{kind: kindBoxed, v: v}
+}
+
+func (s slot) value() value.Value {
+	switch s.kind {
+	case kindInt:
+		return value.Int(s.i)
+	case kindFloat:
+		return value.Float(s.f)
+	case kindBool:
+		return value.Bool(s.b)
+	}
+	return s.v
+}
+
+// binary computes x op y. The fast paths must give exactly what
+// value.Value.BinaryOp gives; whenever they can't (overflow, inexact
+// division, division by zero, NaN...) the slots get boxed and the
+// value package does the work.
+func binary(op lexer.TokenType, x, y slot) slot {
+	switch {
+	case x.kind == kindInt && y.kind == kindInt:
+		if r, ok := intOp(op, x.i, y.i); ok {
+			return r
+		}
+	case x.kind == kindFloat && y.kind == kindFloat:
+		if r, ok := floatOp(op, x.f, y.f); ok {
+			return r
+		}
+	case x.kind == kindFloat && y.kind == kindInt:
+		if r, ok := floatOp(op, x.f, float64(y.i)); ok {
+			return r
+		}
+	case x.kind == kindInt && y.kind == kindFloat:
+		if r, ok := floatOp(op, float64(x.i), y.f); ok {
+			return r
+		}
+	case x.kind == kindBool && y.kind == kindBool:
+		switch op {
+		case lexer.EQ:
+			return slot{kind: kindBool, b: x.b == y.b}
+		case lexer.NE:
+			return slot{kind: kindBool, b: x.b != y.b}
+		case lexer.AND:
+			return slot{kind: kindBool, b: x.b && y.b}
+		case lexer.OR:
+			return slot{kind: kindBool, b: x.b || y.b}
+		}
+	}
+	return unbox(x.value().BinaryOp(op, y.value()))
+}
+
+func compared(op lexer.TokenType, c int) (slot, bool) {
+	switch op {
+	case lexer.EQ:
+		return slot{kind: kindBool, b: c == 0}, true
+	case lexer.NE:
+		return slot{kind: kindBool, b: c != 0}, true
+	case lexer.LT:
+		return slot{kind: kindBool, b: c < 0}, true
+	case lexer.LE:
+		return slot{kind: kindBool, b: c <= 0}, true
+	case lexer.GT:
+		return slot{kind: kindBool, b: c > 0}, true
+	case lexer.GE:
+		return slot{kind: kindBool, b: c >= 0}, true
+	}
+	return slot{}, false
+}
+
+func intOp(op lexer.TokenType, x, y int) (slot, bool) {
+	c := 0
+	if x < y {
+		c = -1
+	} else if x > y {
+		c = 1
+	}
+	if r, ok := compared(op, c); ok {
+		return r, true
+	}
+	switch op {
+	case lexer.PLUS:
+		s := x + y
+		if (s > x) != (y > 0) {
+			return slot{}, false
+		}
+		return slot{kind: kindInt, i: s}, true
+	case lexer.MINUS:
+		d := x - y
+		if (d < x) != (y > 0) {
+			return slot{}, false
+		}
+		return slot{kind: kindInt, i: d}, true
+	case lexer.MULT:
+		p := x * y
+		if x != 0 && (p/x != y || (x == -1 && y == math.MinInt)) {
+			return slot{}, false
+		}
+		return slot{kind: kindInt, i: p}, true
+	case lexer.DIV:
+		if y == 0 || x%y != 0 || (x == math.MinInt && y == -1) {
+			return slot{}, false
+		}
+		return slot{kind: kindInt, i: x / y}, true
+	case lexer.REM:
+		if y == 0 {
+			return slot{}, false
+		}
+		return slot{kind: kindInt, i: x % y}, true
+	}
+	return slot{}, false
+}
+
+func floatOp(op lexer.TokenType, x, y float64) (slot, bool) {
+	c := 0
+	if x < y {
+		c = -1
+	} else if x > y {
+		c = 1
+	}
+	if r, ok := compared(op, c); ok {
+		return r, true
+	}
+	var r float64
+	switch op {
+	case lexer.PLUS:
+		r = x + y
+	case lexer.MINUS:
+		r = x - y
+	case lexer.MULT:
+		r = x * y
+	case lexer.DIV:
+		if y == 0 {
+			return slot{}, false
+		}
+		r = x / y
+	default:
+		return slot{}, false
+	}
+	if math.IsNaN(r) || math.IsInf(r, 0) {
+		return slot{}, false
+	}
+	return slot{kind: kindFloat, f: r}, true
+}
@@ -3,15 +3,20 @@ package main
 import (
 	"fmt"
 	"os"
+	"strings"
 
+	"github.com/unix-world/smartgoext/arithmetic-parser/compiler"
 	"github.com/unix-world/smartgoext/arithmetic-parser/lexer"
 	"github.com/unix-world/smartgoext/arithmetic-parser/parser"
+	"github.com/unix-world/smartgoext/arithmetic-parser/value"
 )
 
 const (
 	DEBUG bool      = false
 
 	mathExpr string = "1 + 3*4"
+	priceExpr string = "round(price * qty * (1 + vat/100), 2)"
+	ruleExpr string = "age >= 18 && (country == 1 || country == 2 * 2) && !vip"
 )
 
 func main() {
@@ -19,7 +24,11 @@ func main() {
 	lxr := lexer.Lex(mathExpr)
 	psr := parser.NewParser(lxr)
 
-	tree := psr.Parse()
+	tree, err := psr.Parse()
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
 
 	if DEBUG {
 		fmt.Println("******** [DEBUG] ********")
@@ -31,4 +40,94 @@ func main() {
 	fmt.Printf("Reconstituted expression: %q\n", tree)
 	fmt.Printf("Result (calculated): %s\n", tree.Eval())
 
+	env := value.NewEnv(map[string]interface{}{
+		"price": "19.99", // decimal, exact
+		"qty":   3,
+		"vat":   19,
+	})
+
+	tree, err = parser.NewParser(lexer.Lex(priceExpr)).Parse()
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+
+	fmt.Printf("Original expression: %q\n", priceExpr)
+	fmt.Printf("Reconstituted expression: %q\n", tree)
+	fmt.Printf("Result (calculated, with price=19.99, qty=3, vat=19): %s\n", tree.EvalEnv(env))
+
+	rules := value.NewEnv(map[string]interface{}{
+		"age":     21,
+		"country": 1,
+		"vip":     false,
+	})
+
+	// precedence scenarios: expression, expected reconstitution, expected result
+	scenarios := [][3]string{
+		{"1 + 3*4", "1 + (3 * 4)", "13"},
+		{"2 ^ 3 * 2", "(2 ^ 3) * 2", "16"},
+		{"1 + 2 < 4", "(1 + 2) < 4", "true"},
+		{"1 < 2 == 2 < 3", "(1 < 2) == (2 < 3)", "true"},
+		{"age >= 18 && country == 1", "(age >= 18) && (country == 1)", "true"},
+		{"vip || age > 65 && country != 1", "vip || ((age > 65) && (country != 1))", "false"},
+		{"!vip && !(age < 18)", "(!vip) && (!(age < 18))", "true"},
+		{"age >= 18 ? 10 : 0", "(age >= 18) ? 10 : 0", "10"},
+		{"vip ? 1 : age < 18 ? 2 : 3", "vip ? 1 : ((age < 18) ? 2 : 3)", "3"},
+		{"vip && 1/0 == 1", "vip && ((1 / 0) == 1)", "false"},
+		{"max(age, 30) > 25 ? round(2.5) : -1", "(max(age, 30) > 25) ? round(2.5) : (0 - 1)", "3"},
+	}
+	failed := 0
+	for _, sc := range scenarios {
+		tree, err = parser.NewParser(lexer.Lex(sc[0])).Parse()
+		if err != nil {
+			fmt.Printf("FAIL %q: %v\n", sc[0], err)
+			failed++
+			continue
+		}
+		got := tree.EvalEnv(rules).String()
+		if tree.String() != sc[1] || got != sc[2] {
+			fmt.Printf("FAIL %q: got %q = %s, wanted %q = %s\n", sc[0], tree, got, sc[1], sc[2])
+			failed++
+			continue
+		}
+		// the tree walker is the reference, the compiled program has to agree with it
+		prog, err := compiler.Compile(tree)
+		if err != nil {
+			fmt.Printf("FAIL %q: %v\n", sc[0], err)
+			failed++
+			continue
+		}
+		if compiled := prog.Run(rules).String(); compiled != got {
+			fmt.Printf("FAIL %q: compiled program gives %s, tree walker %s\n", sc[0], compiled, got)
+			failed++
+			continue
+		}
+		fmt.Printf("ok   %q = %s\n", tree, got)
+	}
+
+	tree, _ = parser.NewParser(lexer.Lex(ruleExpr)).Parse()
+	prog, err := compiler.Compile(tree)
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+	ok, err := prog.RunBool(rules)
+	if err != nil || !ok {
+		failed++
+	}
+	fmt.Printf("Compiled %q: %d instructions, result %v\n", ruleExpr, prog.Len(), ok)
+
+	for _, badExpr := range []string{"(1 + 2", "1 + * 3", "2 $ 3", "max(1 2)", "a = 1", "a ? 1 2"} {
+		_, err = parser.NewParser(lexer.Lex(badExpr)).Parse()
+		if serr, ok := err.(*parser.SyntaxError); ok {
+			fmt.Printf("Malformed expression: %q\n", badExpr)
+			fmt.Printf("                       %s^\n", strings.Repeat(" ", serr.Offset))
+			fmt.Printf("Error: %v\n", serr)
+		}
+	}
+
+	if failed > 0 {
+		os.Exit(1)
+	}
+
 }
@@ -2,6 +2,7 @@ package lexer
 
 import (
 	"unicode"
+	"unicode/utf8"
 )
 
 // TokenType tells parser what the lexer thinks
@@ -12,7 +13,10 @@ type TokenType int
 // ADD_OP, MULT_OP, EXP_OP aren't individual operation
 // tokens, but rather levels of precedence. The lexeme
 // for a MULT_OP will be "*" or "/" or "%", which all have
-// the same level of precedence.
+// the same level of precedence. Likewise EQ_OP covers
+// "==" and "!=", REL_OP covers "<", "<=", ">" and ">=".
+// EQ, NE, LT, LE, GT, GE, AND, OR, NOT and COND are the
+// operations parse tree nodes carry.
 const (
 	EOF      TokenType = iota
 	ADD_OP   TokenType = iota
@@ -30,6 +34,27 @@ const (
 	POSITIVE TokenType = iota
 	NEGATIVE TokenType = iota
 	EOL      TokenType = iota
+	IDENT    TokenType = iota
+	COMMA    TokenType = iota
+	CALL     TokenType = iota
+	ILLEGAL  TokenType = iota
+	EQ_OP    TokenType = iota
+	REL_OP   TokenType = iota
+	AND_OP   TokenType = iota
+	OR_OP    TokenType = iota
+	NOT_OP   TokenType = iota
+	QUESTION TokenType = iota
+	COLON    TokenType = iota
+	EQ       TokenType = iota
+	NE       TokenType = iota
+	LT       TokenType = iota
+	LE       TokenType = iota
+	GT       TokenType = iota
+	GE       TokenType = iota
+	AND      TokenType = iota
+	OR       TokenType = iota
+	NOT      TokenType = iota
+	COND     TokenType = iota
 )
 
 func (t TokenType) String() string {
@@ -38,14 +63,74 @@ func (t TokenType) String() string {
 		return "ADD_OP"
 	case MULT_OP:
 		return "MULT_OP"
+	case EXP_OP:
+		return "EXP_OP"
+	case PLUS:
+		return "PLUS"
+	case MINUS:
+		return "MINUS"
+	case MULT:
+		return "MULT"
+	case DIV:
+		return "DIV"
+	case REM:
+		return "REM"
+	case EXP:
+		return "EXP"
 	case CONSTANT:
 		return "CONSTANT"
 	case LPAREN:
 		return "LPAREN"
 	case RPAREN:
 		return "RPAREN"
+	case POSITIVE:
+		return "POSITIVE"
+	case NEGATIVE:
+		return "NEGATIVE"
 	case EOL:
 		return "EOL"
+	case IDENT:
+		return "IDENT"
+	case COMMA:
+		return "COMMA"
+	case CALL:
+		return "CALL"
+	case ILLEGAL:
+		return "ILLEGAL"
+	case EQ_OP:
+		return "EQ_OP"
+	case REL_OP:
+		return "REL_OP"
+	case AND_OP:
+		return "AND_OP"
+	case OR_OP:
+		return "OR_OP"
+	case NOT_OP:
+		return "NOT_OP"
+	case QUESTION:
+		return "QUESTION"
+	case COLON:
+		return "COLON"
+	case EQ:
+		return "EQ"
+	case NE:
+		return "NE"
+	case LT:
+		return "LT"
+	case LE:
+		return "LE"
+	case GT:
+		return "GT"
+	case GE:
+		return "GE"
+	case AND:
+		return "AND"
+	case OR:
+		return "OR"
+	case NOT:
+		return "NOT"
+	case COND:
+		return "COND"
 	case EOF:
 		return "EOF"
 	}
@@ -55,13 +140,16 @@ func (t TokenType) String() string {
 type item struct {
 	kind   TokenType
 	lexeme string
+	offset int
 }
 
 // Lexer instances hold information needed to break
 // a string into arithmetic expression tokens.
 type Lexer struct {
 	input       []rune
+	size        int
 	start       int
+	offset      int
 	pos         int
 	items       chan item
 	currentItem item
@@ -75,6 +163,7 @@ type stateFn func(*Lexer) stateFn
 func Lex(input string) *Lexer {
 	l := &Lexer{
 		input:    []rune(input),
+		size:     len(input),
 		items:    make(chan item),
 		consumed: true,
 	}
@@ -84,14 +173,35 @@ func Lex(input string) *Lexer {
 
 // NextToken called by parser to retrieve whatever
 // the lexer thinks is the next token.
+// Once the input is used up, it keeps on returning EOF.
 func (l *Lexer) NextToken() (TokenType, string) {
 	if l.consumed {
-		l.currentItem = <-l.items
+		it, ok := <-l.items
+		if !ok {
+			it = item{kind: EOF, offset: l.size}
+		}
+		l.currentItem = it
 		l.consumed = false
 	}
 	return l.currentItem.kind, l.currentItem.lexeme
 }
 
+// Offset returns the byte offset in the input string of the token
+// that the last call to NextToken returned.
+func (l *Lexer) Offset() int {
+	return l.currentItem.offset
+}
+
+// Drain reads and throws away the rest of the tokens, so that
+// the background goroutine can finish. Parser calls it before
+// returning, also when it gives up on the input part way through.
+func (l *Lexer) Drain() {
+	for range l.items {
+	}
+	l.currentItem = item{kind: EOF, offset: l.size}
+	l.consumed = false
+}
+
 // Consume called by parser when it has found a place in the parse tree for the
 // token. Parse can and does call NextToken() repeatedly to find out the
 // token's type.
@@ -109,9 +219,10 @@ func (l *Lexer) run() {
 func lexWhiteSpace(l *Lexer) stateFn {
 	for _, r := range l.input[l.start:] {
 		switch r {
-		case ' ', '"', '\'', '\t':
+		case ' ', '"', '\'', '\t', '\r':
 			l.pos++
 			l.start++
+			l.offset += utf8.RuneLen(r)
 		default:
 			return l.nextStateFn()
 		}
@@ -140,33 +251,143 @@ func (l *Lexer) nextStateFn() stateFn {
 		return lexExp
 	case '%':
 		return lexMod
+	case ',':
+		return lexComma
+	case '=', '!', '<', '>':
+		return lexCompare
+	case '&', '|':
+		return lexLogical
+	case '?':
+		return lexQuestion
+	case ':':
+		return lexColon
 	case '\n':
 		return lexEOL
 	default:
 		if unicode.IsDigit(l.input[l.pos]) {
 			return lexNumber
 		}
-		return lexWhiteSpace
+		if isIdentStart(l.input[l.pos]) {
+			return lexIdent
+		}
+		switch l.input[l.pos] {
+		case ' ', '"', '\'', '\t', '\r':
+			return lexWhiteSpace
+		}
+		return lexIllegal
 	}
 }
 
 func (l *Lexer) emit(t TokenType) {
-	l.items <- item{t, string(l.input[l.start:l.pos])}
+	lexeme := string(l.input[l.start:l.pos])
+	l.items <- item{t, lexeme, l.offset}
 	l.start = l.pos
+	l.offset += len(lexeme)
+}
+
+// lexIllegal hands a character that can't start any token
+// to the parser, which will report it as a syntax error.
+func lexIllegal(l *Lexer) stateFn {
+	l.pos++
+	l.emit(ILLEGAL)
+	return l.nextStateFn()
 }
 
 func lexEOF(l *Lexer) stateFn {
 	return nil
 }
 
+// lexNumber accepts integer and decimal literals, "42" or "3.14".
+// Whether the literal ends up an integer, a decimal or an error
+// is for package value to decide.
 func lexNumber(l *Lexer) stateFn {
 	for l.pos < len(l.input) && unicode.IsDigit(rune(l.input[l.pos])) {
 		l.pos++
 	}
+	if l.pos+1 < len(l.input) && l.input[l.pos] == '.' && unicode.IsDigit(l.input[l.pos+1]) {
+		l.pos++
+		for l.pos < len(l.input) && unicode.IsDigit(rune(l.input[l.pos])) {
+			l.pos++
+		}
+	}
 	l.emit(CONSTANT)
 	return l.nextStateFn()
 }
 
+func isIdentStart(r rune) bool {
+	return r == '_' || unicode.IsLetter(r)
+}
+
+// lexIdent accepts variable and function names: a letter or underscore,
+// followed by any number of letters, digits or underscores.
+func lexIdent(l *Lexer) stateFn {
+	for l.pos < len(l.input) && (isIdentStart(l.input[l.pos]) || unicode.IsDigit(l.input[l.pos])) {
+		l.pos++
+	}
+	l.emit(IDENT)
+	return l.nextStateFn()
+}
+
+func lexComma(l *Lexer) stateFn {
+	l.pos++
+	l.emit(COMMA)
+	return l.nextStateFn()
+}
+
+// lexCompare handles "==", "!=", "<", "<=", ">", ">=" and "!".
+// A lone "=" is an ILLEGAL token, there's no assignment.
+func lexCompare(l *Lexer) stateFn {
+	r := l.input[l.pos]
+	l.pos++
+	if l.pos < len(l.input) && l.input[l.pos] == '=' {
+		l.pos++
+		if r == '<' || r == '>' {
+			l.emit(REL_OP)
+		} else {
+			l.emit(EQ_OP)
+		}
+		return l.nextStateFn()
+	}
+	switch r {
+	case '<', '>':
+		l.emit(REL_OP)
+	case '!':
+		l.emit(NOT_OP)
+	default:
+		l.emit(ILLEGAL)
+	}
+	return l.nextStateFn()
+}
+
+// lexLogical handles "&&" and "||". A single '&' or '|' is ILLEGAL.
+func lexLogical(l *Lexer) stateFn {
+	r := l.input[l.pos]
+	l.pos++
+	if l.pos >= len(l.input) || l.input[l.pos] != r {
+		l.emit(ILLEGAL)
+		return l.nextStateFn()
+	}
+	l.pos++
+	if r == '&' {
+		l.emit(AND_OP)
+	} else {
+		l.emit(OR_OP)
+	}
+	return l.nextStateFn()
+}
+
+func lexQuestion(l *Lexer) stateFn {
+	l.pos++
+	l.emit(QUESTION)
+	return l.nextStateFn()
+}
+
+func lexColon(l *Lexer) stateFn {
+	l.pos++
+	l.emit(COLON)
+	return l.nextStateFn()
+}
+
 func lexLeftParen(l *Lexer) stateFn {
 	l.pos++
 	l.emit(LPAREN)
@@ -0,0 +1,20 @@
+package lexer
+
+import "testing"
+
+func TestTokenTypeString(t *testing.T) {
+	seen := make(map[string]TokenType)
+	for tt := EOF; tt <= COND; tt++ {
+		name := tt.String()
+		if name == "unknown" {
+			t.Errorf("token type %d has no name", tt)
+		}
+		if other, ok := seen[name]; ok {
+			t.Errorf("token types %d and %d are both named %s", other, tt, name)
+		}
+		seen[name] = tt
+	}
+	if name := (COND + 1).String(); name != "unknown" {
+		t.Errorf("undefined token type named %s", name)
+	}
+}
@@ -0,0 +1,30 @@
+package parser
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/unix-world/smartgoext/arithmetic-parser/lexer"
+)
+
+// SyntaxError describes where and why Parse gave up on its input.
+// Offset is a byte offset into the original string, so a user
+// interface can point at the exact spot: the offending lexeme
+// starts there, and an EOF is reported at the length of the input.
+type SyntaxError struct {
+	Offset   int
+	Expected []lexer.TokenType
+	Got      lexer.TokenType
+	Lexeme   string
+}
+
+func (e *SyntaxError) Error() string {
+	want := make([]string, len(e.Expected))
+	for i, t := range e.Expected {
+		want[i] = t.String()
+	}
+	if e.Got == lexer.EOF {
+		return fmt.Sprintf("syntax error at offset %d: wanted %s, got end of input", e.Offset, strings.Join(want, " or "))
+	}
+	return fmt.Sprintf("syntax error at offset %d: wanted %s, got %v %q", e.Offset, strings.Join(want, " or "), e.Got, e.Lexeme)
+}
@@ -1,17 +1,23 @@
 package parser
 
 import (
-	"fmt"
-
 	"github.com/unix-world/smartgoext/arithmetic-parser/lexer"
 	"github.com/unix-world/smartgoext/arithmetic-parser/tree"
 )
 
 /*
+cond -> disjunction ['?' cond ':' cond]
+disjunction -> conjunction {'||' conjunction}
+conjunction -> equality {'&&' equality}
+equality -> relation {eq-op relation}
+relation -> expr {rel-op expr}
 expr -> term   {add-op term}
 term -> spork {mult-op spork}
 spork -> factor {exp-op factor}
-factor -> '(' expr ')' | '-' factor | '+' factor | NUMBER
+factor -> '(' cond ')' | '-' factor | '+' factor | '!' factor | NUMBER | IDENT | call
+call -> IDENT '(' [cond {',' cond}] ')'
+eq-op -> '=='|'!='
+rel-op -> '<'|'<='|'>'|'>='
 add-op -> '+'|'-'
 mult-op -> '*'|'/'|'%'
 exp-op -> '^'
@@ -33,73 +39,259 @@ type Parser struct {
 	lexer *lexer.Lexer
 }
 
-// Parse starts building a parse tree, and returns it
-func (p *Parser) Parse() *tree.Node {
-	return p.expr()
+// Parse starts building a parse tree, and returns it.
+// The whole input has to be a single expression, optionally
+// followed by a newline. On malformed input the error
+// is a *SyntaxError, and the parse tree is nil. Anything
+// after the newline is trailing input, and a syntax error.
+func (p *Parser) Parse() (*tree.Node, error) {
+	defer p.lexer.Drain()
+	node, err := p.cond()
+	if err != nil {
+		return nil, err
+	}
+	kind, _ := p.lexer.NextToken()
+	if kind == lexer.EOL {
+		p.lexer.Consume()
+		if kind, _ = p.lexer.NextToken(); kind != lexer.EOF {
+			return nil, p.unexpected(lexer.EOF)
+		}
+	}
+	if kind != lexer.EOF {
+		return nil, p.unexpected(lexer.ADD_OP, lexer.MULT_OP, lexer.EXP_OP, lexer.EQ_OP, lexer.REL_OP,
+			lexer.AND_OP, lexer.OR_OP, lexer.QUESTION, lexer.EOF)
+	}
+	return node, nil
+}
+
+func (p *Parser) cond() (*tree.Node, error) {
+	node, err := p.disjunction()
+	if err != nil {
+		return nil, err
+	}
+	if kind, _ := p.lexer.NextToken(); kind != lexer.QUESTION {
+		return node, nil
+	}
+	p.lexer.Consume()
+	then, err := p.cond()
+	if err != nil {
+		return nil, err
+	}
+	if kind, _ := p.lexer.NextToken(); kind != lexer.COLON {
+		return nil, p.unexpected(lexer.COLON)
+	}
+	p.lexer.Consume()
+	otherwise, err := p.cond()
+	if err != nil {
+		return nil, err
+	}
+	return tree.CondNode(node, then, otherwise), nil
+}
+
+func (p *Parser) disjunction() (*tree.Node, error) {
+	node, err := p.conjunction()
+	if err != nil {
+		return nil, err
+	}
+	for kind, lexeme := p.lexer.NextToken(); kind == lexer.OR_OP; kind, lexeme = p.lexer.NextToken() {
+		tmp := tree.NewNode(kind, lexeme)
+		p.lexer.Consume()
+		tmp.Left = node
+		node = tmp
+		if node.Right, err = p.conjunction(); err != nil {
+			return nil, err
+		}
+	}
+	return node, nil
+}
+
+func (p *Parser) conjunction() (*tree.Node, error) {
+	node, err := p.equality()
+	if err != nil {
+		return nil, err
+	}
+	for kind, lexeme := p.lexer.NextToken(); kind == lexer.AND_OP; kind, lexeme = p.lexer.NextToken() {
+		tmp := tree.NewNode(kind, lexeme)
+		p.lexer.Consume()
+		tmp.Left = node
+		node = tmp
+		if node.Right, err = p.equality(); err != nil {
+			return nil, err
+		}
+	}
+	return node, nil
+}
+
+func (p *Parser) equality() (*tree.Node, error) {
+	node, err := p.relation()
+	if err != nil {
+		return nil, err
+	}
+	for kind, lexeme := p.lexer.NextToken(); kind == lexer.EQ_OP; kind, lexeme = p.lexer.NextToken() {
+		tmp := tree.NewNode(kind, lexeme)
+		p.lexer.Consume()
+		tmp.Left = node
+		node = tmp
+		if node.Right, err = p.relation(); err != nil {
+			return nil, err
+		}
+	}
+	return node, nil
+}
+
+func (p *Parser) relation() (*tree.Node, error) {
+	node, err := p.expr()
+	if err != nil {
+		return nil, err
+	}
+	for kind, lexeme := p.lexer.NextToken(); kind == lexer.REL_OP; kind, lexeme = p.lexer.NextToken() {
+		tmp := tree.NewNode(kind, lexeme)
+		p.lexer.Consume()
+		tmp.Left = node
+		node = tmp
+		if node.Right, err = p.expr(); err != nil {
+			return nil, err
+		}
+	}
+	return node, nil
 }
 
-func (p *Parser) expr() *tree.Node {
-	node := p.term()
+func (p *Parser) expr() (*tree.Node, error) {
+	node, err := p.term()
+	if err != nil {
+		return nil, err
+	}
 	for kind, lexeme := p.lexer.NextToken(); kind == lexer.ADD_OP; kind, lexeme = p.lexer.NextToken() {
 		tmp := tree.NewNode(kind, lexeme)
 		p.lexer.Consume()
 		tmp.Left = node
 		node = tmp
-		node.Right = p.term()
+		if node.Right, err = p.term(); err != nil {
+			return nil, err
+		}
 	}
-	return node
+	return node, nil
 }
 
-func (p *Parser) term() *tree.Node {
-	node := p.spork()
+func (p *Parser) term() (*tree.Node, error) {
+	node, err := p.spork()
+	if err != nil {
+		return nil, err
+	}
 	for kind, lexeme := p.lexer.NextToken(); kind == lexer.MULT_OP; kind, lexeme = p.lexer.NextToken() {
 		tmp := tree.NewNode(kind, lexeme)
 		p.lexer.Consume()
 		tmp.Left = node
 		node = tmp
-		node.Right = p.spork()
+		if node.Right, err = p.spork(); err != nil {
+			return nil, err
+		}
 	}
-	return node
+	return node, nil
 
 }
 
-func (p *Parser) spork() *tree.Node {
-	node := p.factor()
+func (p *Parser) spork() (*tree.Node, error) {
+	node, err := p.factor()
+	if err != nil {
+		return nil, err
+	}
 	for kind, lexeme := p.lexer.NextToken(); kind == lexer.EXP_OP; kind, lexeme = p.lexer.NextToken() {
 		tmp := tree.NewNode(kind, lexeme)
 		p.lexer.Consume()
 		tmp.Left = node
 		node = tmp
-		node.Right = p.factor()
+		if node.Right, err = p.factor(); err != nil {
+			return nil, err
+		}
 	}
-	return node
+	return node, nil
 
 }
 
-func (p *Parser) factor() *tree.Node {
+func (p *Parser) factor() (*tree.Node, error) {
 	kind, lexeme := p.lexer.NextToken()
 	switch kind {
 	case lexer.ADD_OP:
 		unaryOp := lexeme
 		p.lexer.Consume()
-		factor := p.factor()
-		return tree.UnaryNode(unaryOp, factor)
+		factor, err := p.factor()
+		if err != nil {
+			return nil, err
+		}
+		return tree.UnaryNode(unaryOp, factor), nil
+	case lexer.NOT_OP:
+		p.lexer.Consume()
+		factor, err := p.factor()
+		if err != nil {
+			return nil, err
+		}
+		return tree.NotNode(factor), nil
 	case lexer.CONSTANT:
 		p.lexer.Consume()
-		return tree.NewNode(kind, lexeme)
+		return tree.NewNode(kind, lexeme), nil
+	case lexer.IDENT:
+		p.lexer.Consume()
+		if next, _ := p.lexer.NextToken(); next == lexer.LPAREN {
+			p.lexer.Consume()
+			args, err := p.args()
+			if err != nil {
+				return nil, err
+			}
+			return tree.CallNode(lexeme, args), nil
+		}
+		return tree.NewNode(kind, lexeme), nil
 	case lexer.LPAREN:
 		p.lexer.Consume()
-		expr := p.expr()
-		kind, lexeme = p.lexer.NextToken()
+		expr, err := p.cond()
+		if err != nil {
+			return nil, err
+		}
+		if kind, _ = p.lexer.NextToken(); kind != lexer.RPAREN {
+			return nil, p.unexpected(lexer.RPAREN)
+		}
+		p.lexer.Consume()
+		return expr, nil
+	}
+	return nil, p.unexpected(lexer.CONSTANT, lexer.IDENT, lexer.LPAREN, lexer.ADD_OP, lexer.NOT_OP)
+}
+
+// args parses a function call's argument list, the
+// opening LPAREN has already been consumed.
+func (p *Parser) args() ([]*tree.Node, error) {
+	var args []*tree.Node
+	if kind, _ := p.lexer.NextToken(); kind == lexer.RPAREN {
+		p.lexer.Consume()
+		return args, nil
+	}
+	for {
+		arg, err := p.cond()
+		if err != nil {
+			return nil, err
+		}
+		args = append(args, arg)
+		kind, _ := p.lexer.NextToken()
+		if kind == lexer.COMMA {
+			p.lexer.Consume()
+			continue
+		}
 		if kind != lexer.RPAREN {
-			fmt.Printf("Wanted an RPAREN, got %v: %q\n", kind, lexeme)
+			return nil, p.unexpected(lexer.COMMA, lexer.RPAREN)
 		}
 		p.lexer.Consume()
-		return expr
-	default:
-		fmt.Printf("Wanted a CONSTANT or LPAREN, got %v: %q\n", kind, lexeme)
+		return args, nil
+	}
+}
+
+// unexpected builds a *SyntaxError for the lexer's current token.
+func (p *Parser) unexpected(expected ...lexer.TokenType) error {
+	kind, lexeme := p.lexer.NextToken()
+	return &SyntaxError{
+		Offset:   p.lexer.Offset(),
+		Expected: expected,
+		Got:      kind,
+		Lexeme:   lexeme,
 	}
-	return nil
 }
 
 // NewParser creates a filled in Parser struct and returns it.
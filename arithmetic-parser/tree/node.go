@@ -20,6 +20,7 @@ type Node struct {
 	Lexeme string
 	Left   *Node
 	Right  *Node
+	Args   []*Node
 }
 
 // NewNode creates interior nodes of a parse tree, which will
@@ -45,13 +46,50 @@ func NewNode(op lexer.TokenType, lexeme string) *Node {
 		case "%":
 			n.Op = lexer.REM
 		}
-	case lexer.CONSTANT:
-		n.Op = lexer.CONSTANT
+	case lexer.EQ_OP, lexer.REL_OP:
+		switch lexeme {
+		case "==":
+			n.Op = lexer.EQ
+		case "!=":
+			n.Op = lexer.NE
+		case "<":
+			n.Op = lexer.LT
+		case "<=":
+			n.Op = lexer.LE
+		case ">":
+			n.Op = lexer.GT
+		case ">=":
+			n.Op = lexer.GE
+		}
+	case lexer.AND_OP:
+		n.Op = lexer.AND
+	case lexer.OR_OP:
+		n.Op = lexer.OR
+	case lexer.CONSTANT, lexer.IDENT:
+		n.Op = op
 		n.Lexeme = lexeme
 	}
 	return &n
 }
 
+// NotNode sets up a "!something" sub-tree. Logical negation
+// has only a Right operand, Left stays nil.
+func NotNode(factor *Node) *Node {
+	return &Node{Op: lexer.NOT, Right: factor}
+}
+
+// CondNode creates a "cond ? then : otherwise" node. All three
+// sub-trees hang off Args, in that order.
+func CondNode(cond, then, otherwise *Node) *Node {
+	return &Node{Op: lexer.COND, Args: []*Node{cond, then, otherwise}}
+}
+
+// CallNode creates a function call node, "name(args...)".
+// The arguments hang off Args, not Left and Right.
+func CallNode(name string, args []*Node) *Node {
+	return &Node{Op: lexer.CALL, Lexeme: name, Args: args}
+}
+
 // UnaryNode handles "-something" and "+something" situtations.
 // It returns "something" in "+something" cases,
 // but sets up a "0 - something" sub-tree for unary negation.
@@ -64,15 +102,59 @@ func UnaryNode(unaryOp string, factor *Node) *Node {
 
 // Eval recursively traverses a parse tree for an arithmetic expression.
 // It uses type value.Value to do the numerical evaluation.
+// Any identifier in the expression evaluates to an error,
+// use EvalEnv to supply values for them.
 func (p *Node) Eval() value.Value {
-	if p.Op == lexer.CONSTANT {
+	return p.EvalEnv(nil)
+}
+
+// EvalEnv works like Eval, but resolves identifiers in env.
+// Function calls go to the value.Builtins functions.
+// "&&", "||" and "?:" only evaluate the sub-trees they need to.
+func (p *Node) EvalEnv(env value.Env) value.Value {
+	switch p.Op {
+	case lexer.CONSTANT:
 		return value.NewValue(p.Lexeme)
+	case lexer.IDENT:
+		return env.Lookup(p.Lexeme)
+	case lexer.CALL:
+		args := make([]value.Value, len(p.Args))
+		for i, a := range p.Args {
+			args[i] = a.EvalEnv(env)
+		}
+		return value.Call(p.Lexeme, args)
+	case lexer.NOT:
+		return value.Not(p.Right.EvalEnv(env))
+	case lexer.AND, lexer.OR:
+		left := p.Left.EvalEnv(env)
+		if b, ok := left.(value.Bool); ok && bool(b) == (p.Op == lexer.OR) {
+			return b
+		}
+		return left.BinaryOp(p.Op, p.Right.EvalEnv(env))
+	case lexer.COND:
+		cond := p.Args[0].EvalEnv(env)
+		switch b := cond.(type) {
+		case value.Bool:
+			if b {
+				return p.Args[1].EvalEnv(env)
+			}
+			return p.Args[2].EvalEnv(env)
+		case value.Error:
+			return b
+		}
+		return value.Error(fmt.Sprintf("condition is not a boolean: '%v'", cond))
 	}
-	left := p.Left.Eval()
-	right := p.Right.Eval()
+	left := p.Left.EvalEnv(env)
+	right := p.Right.EvalEnv(env)
 	return left.BinaryOp(p.Op, right)
 }
 
+// isLeaf tells Print whether a node needs parenthesizing
+// when it appears as an operand.
+func (p *Node) isLeaf() bool {
+	return p.Op == lexer.CONSTANT || p.Op == lexer.IDENT || p.Op == lexer.CALL
+}
+
 // Print puts a human-readable, nicely formatted string representation
 // of a parse tree onto the io.Writer, w.  Essentially just an in-order
 // traversal of a binary tree, with accommodating a few oddities, like
@@ -81,7 +163,7 @@ func (p *Node) Print(w io.Writer) {
 
 	if p.Left != nil {
 		printParen := false
-		if p.Left.Op != lexer.CONSTANT {
+		if !p.Left.isLeaf() {
 			fmt.Fprintf(w, "(")
 			printParen = true
 		}
@@ -91,38 +173,53 @@ func (p *Node) Print(w io.Writer) {
 		}
 	}
 
-	var oper rune
 	switch p.Op {
-	case lexer.MULT:
-		oper = '*'
-	case lexer.DIV:
-		oper = '/'
-	case lexer.REM:
-		oper = '%'
-	case lexer.EXP:
-		oper = '^'
-	case lexer.PLUS:
-		oper = '+'
-	case lexer.MINUS:
-		oper = '-'
-	case lexer.CONSTANT, lexer.POSITIVE, lexer.NEGATIVE:
-		oper = 0
-	}
-	if oper != 0 {
-		fmt.Fprintf(w, " %c ", oper)
+	case lexer.CONSTANT, lexer.IDENT, lexer.CALL, lexer.POSITIVE, lexer.NEGATIVE, lexer.COND:
+	case lexer.NOT:
+		fmt.Fprint(w, "!")
+	default:
+		fmt.Fprintf(w, " %s ", opSymbol(p.Op))
 	}
 
-	if p.Op == lexer.CONSTANT {
+	if p.Op == lexer.CONSTANT || p.Op == lexer.IDENT {
 		fmt.Fprintf(w, "%s", p.Lexeme)
 	}
 
+	if p.Op == lexer.CALL {
+		fmt.Fprintf(w, "%s(", p.Lexeme)
+		for i, a := range p.Args {
+			if i > 0 {
+				fmt.Fprint(w, ", ")
+			}
+			a.Print(w)
+		}
+		fmt.Fprint(w, ")")
+	}
+
+	if p.Op == lexer.COND {
+		for i, a := range p.Args {
+			if i == 1 {
+				fmt.Fprint(w, " ? ")
+			} else if i == 2 {
+				fmt.Fprint(w, " : ")
+			}
+			if !a.isLeaf() {
+				fmt.Fprint(w, "(")
+			}
+			a.Print(w)
+			if !a.isLeaf() {
+				fmt.Fprint(w, ")")
+			}
+		}
+	}
+
 	if p.Op == lexer.NEGATIVE {
 		fmt.Fprint(w, "-")
 	}
 
 	if p.Right != nil {
 		printParen := false
-		if p.Right.Op != lexer.CONSTANT {
+		if !p.Right.isLeaf() {
 			fmt.Fprintf(w, "(")
 			printParen = true
 		}
@@ -133,6 +230,41 @@ func (p *Node) Print(w io.Writer) {
 	}
 }
 
+// opSymbol gives the source text of a binary operation.
+func opSymbol(op lexer.TokenType) string {
+	switch op {
+	case lexer.MULT:
+		return "*"
+	case lexer.DIV:
+		return "/"
+	case lexer.REM:
+		return "%"
+	case lexer.EXP:
+		return "^"
+	case lexer.PLUS:
+		return "+"
+	case lexer.MINUS:
+		return "-"
+	case lexer.EQ:
+		return "=="
+	case lexer.NE:
+		return "!="
+	case lexer.LT:
+		return "<"
+	case lexer.LE:
+		return "<="
+	case lexer.GT:
+		return ">"
+	case lexer.GE:
+		return ">="
+	case lexer.AND:
+		return "&&"
+	case lexer.OR:
+		return "||"
+	}
+	return ""
+}
+
 func (p *Node) String() string {
 	var sb bytes.Buffer
 	p.Print(&sb)
@@ -144,22 +276,18 @@ func (p *Node) graphNode(w io.Writer) {
 	var label string
 
 	switch p.Op {
-	case lexer.CONSTANT:
+	case lexer.CONSTANT, lexer.IDENT:
 		label = fmt.Sprintf("%s", p.Lexeme)
-	case lexer.MINUS:
-		label = "-"
-	case lexer.PLUS:
-		label = "+"
-	case lexer.DIV:
-		label = "/"
-	case lexer.MULT:
-		label = "*"
-	case lexer.REM:
-		label = "%"
-	case lexer.EXP:
-		label = "^"
+	case lexer.CALL:
+		label = p.Lexeme + "()"
 	case lexer.NEGATIVE:
 		label = "~"
+	case lexer.NOT:
+		label = "!"
+	case lexer.COND:
+		label = "?:"
+	default:
+		label = opSymbol(p.Op)
 	}
 
 	fmt.Fprintf(w, "n%p [label=\"%s\"];\n", p, label)
@@ -172,6 +300,10 @@ func (p *Node) graphNode(w io.Writer) {
 		p.Right.graphNode(w)
 		fmt.Fprintf(w, "n%p -> n%p;\n", p, p.Right)
 	}
+	for _, a := range p.Args {
+		a.graphNode(w)
+		fmt.Fprintf(w, "n%p -> n%p;\n", p, a)
+	}
 }
 
 // GraphNode puts a dot-format text representation of
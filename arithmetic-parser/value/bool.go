@@ -0,0 +1,72 @@
+package value
+
+import (
+	"fmt"
+
+	"github.com/unix-world/smartgoext/arithmetic-parser/lexer"
+)
+
+// Bool implements Value interface for the results of comparisons
+// and logical operations. It does no arithmetic.
+type Bool bool
+
+func (x Bool) String() string {
+	if x {
+		return "true"
+	}
+	return "false"
+}
+
+// BinaryOp implements equality and logical operations for type Bool.
+// Logical operations here evaluate both sides, tree.Node.Eval does
+// the short-circuiting.
+func (x Bool) BinaryOp(op lexer.TokenType, y Value) Value {
+	switch y := y.(type) {
+	case Bool:
+		switch op {
+		case lexer.EQ:
+			return Bool(x == y)
+		case lexer.NE:
+			return Bool(x != y)
+		case lexer.AND:
+			return x && y
+		case lexer.OR:
+			return x || y
+		}
+	case Error:
+		return y
+	}
+	return Error(fmt.Sprintf("illegal op: '%v %s %v'", x, op, y))
+}
+
+// Not negates a Bool. Anything other than a Bool is an error.
+func Not(x Value) Value {
+	switch x := x.(type) {
+	case Bool:
+		return !x
+	case Error:
+		return x
+	}
+	return Error(fmt.Sprintf("illegal op: '!%v'", x))
+}
+
+// compareOp turns c, the result of a three-way comparison,
+// into a Bool for comparison operator op. The second return value
+// is false if op isn't a comparison.
+func compareOp(op lexer.TokenType, c int) (Value, bool) {
+	switch op {
+	case lexer.EQ:
+		return Bool(c == 0), true
+	case lexer.NE:
+		return Bool(c != 0), true
+	case lexer.LT:
+		return Bool(c < 0), true
+	case lexer.LE:
+		return Bool(c <= 0), true
+	case lexer.GT:
+		return Bool(c > 0), true
+	case lexer.GE:
+		return Bool(c >= 0), true
+	}
+	return nil, false
+}
@@ -0,0 +1,77 @@
+package value
+
+import (
+	"fmt"
+
+	"github.com/unix-world/smartgoext/arithmetic-parser/lexer"
+	"github.com/unix-world/smartgoext/bignum/shopspring/decimal"
+)
+
+// Decimal implements Value interface for exact, arbitrary-precision
+// decimal arithmetic, backed by bignum/shopspring/decimal.
+// Division that does not terminate is rounded to
+// decimal.DivisionPrecision places.
+type Decimal struct {
+	decimal.Decimal
+}
+
+// MaxDecimalExp is the largest absolute integer exponent of a Decimal power,
+// larger exponents are an error rather than a result of many thousand digits.
+const MaxDecimalExp = 1000
+
+// BinaryOp implements decimal arithmetic for type Decimal.
+// Int operands are converted to Decimal, a Float operand
+// turns the whole operation into a Float one.
+func (x Decimal) BinaryOp(op lexer.TokenType, y Value) Value {
+	var d decimal.Decimal
+	switch y := y.(type) {
+	case Decimal:
+		d = y.Decimal
+	case Int:
+		d = decimal.NewFromInt(int64(y))
+	case Float:
+		return Float(x.InexactFloat64()).BinaryOp(op, y)
+	case Error:
+		return y
+	default:
+		return Error(fmt.Sprintf("illegal op: '%v %s %v'", x, op, y))
+	}
+	if b, ok := compareOp(op, x.Cmp(d)); ok {
+		return b
+	}
+	switch op {
+	case lexer.PLUS:
+		return Decimal{x.Add(d)}
+	case lexer.MINUS:
+		return Decimal{x.Sub(d)}
+	case lexer.MULT:
+		return Decimal{x.Mul(d)}
+	case lexer.DIV:
+		if d.IsZero() {
+			return Error(fmt.Sprintf("division by zero: '%v / %v'", x, y))
+		}
+		return Decimal{x.Div(d)}
+	case lexer.EXP:
+		if !d.IsInteger() {
+			return Float(x.InexactFloat64()).BinaryOp(op, Float(d.InexactFloat64()))
+		}
+		if d.Abs().GreaterThan(decimal.NewFromInt(MaxDecimalExp)) {
+			// the digits of an exact power grow with the exponent
+			return Error(fmt.Sprintf("exponent too large: '%v ^ %v'", x, y))
+		}
+		if x.IsZero() && d.IsNegative() {
+			return Error(fmt.Sprintf("division by zero: '%v ^ %v'", x, y))
+		}
+		r, err := x.PowInt32(int32(d.IntPart()))
+		if err != nil {
+			return Error(fmt.Sprintf("%v: '%v ^ %v'", err, x, y))
+		}
+		return Decimal{r}
+	case lexer.REM:
+		if d.IsZero() {
+			return Error(fmt.Sprintf("modulo of zero: '%v %% %v'", x, y))
+		}
+		return Decimal{x.Mod(d)}
+	}
+	return Error(fmt.Sprintf("illegal op: '%v %s %v'", x, op, y))
+}
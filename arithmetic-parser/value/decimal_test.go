@@ -0,0 +1,57 @@
+package value_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/unix-world/smartgoext/arithmetic-parser/lexer"
+	"github.com/unix-world/smartgoext/arithmetic-parser/parser"
+	"github.com/unix-world/smartgoext/arithmetic-parser/value"
+)
+
+func eval(t *testing.T, expr string) value.Value {
+	t.Helper()
+	node, err := parser.NewParser(lexer.Lex(expr)).Parse()
+	if err != nil {
+		t.Fatalf("%q: %v", expr, err)
+	}
+	return node.Eval()
+}
+
+func TestDecimalExp(t *testing.T) {
+	for _, tc := range []struct {
+		expr, want string
+	}{
+		{"2 ^ 64", "18446744073709551616"},
+		{"1.5 ^ 2", "2.25"},
+		{"2.0 ^ -2", "0.25"},
+		{"1.1 ^ 1000", ""},
+		{"4.0 ^ 0.5", "2"},
+	} {
+		v := eval(t, tc.expr)
+		if _, ok := v.(value.Error); ok {
+			t.Errorf("%q = %v", tc.expr, v)
+			continue
+		}
+		if tc.want != "" && v.String() != tc.want {
+			t.Errorf("%q = %v, want %s", tc.expr, v, tc.want)
+		}
+	}
+}
+
+// An exact power has about exponent times as many digits as its base, large
+// exponents must fail instead of computing for minutes.
+func TestDecimalExpTooLarge(t *testing.T) {
+	for _, expr := range []string{
+		"1.0000001 ^ 2147483647",
+		"1.0000001 ^ 1001",
+		"2 ^ 1000000",
+		"0.5 ^ -2147483648",
+		"pow(1.0000001, 9999999999)",
+	} {
+		v := eval(t, expr)
+		if e, ok := v.(value.Error); !ok || !strings.Contains(string(e), "exponent too large") {
+			t.Errorf("%q = %v, want an exponent error", expr, v)
+		}
+	}
+}
@@ -0,0 +1,68 @@
+package value
+
+import (
+	"fmt"
+
+	"github.com/unix-world/smartgoext/bignum/shopspring/decimal"
+)
+
+// Env maps identifier names to values, it is what a parse tree
+// gets evaluated against when an expression has variables in it.
+type Env map[string]Value
+
+// NewEnv builds an Env from plain Go values, see FromGo
+// for the conversions that get done.
+func NewEnv(vars map[string]interface{}) Env {
+	env := make(Env, len(vars))
+	for name, v := range vars {
+		env[name] = FromGo(v)
+	}
+	return env
+}
+
+// Lookup returns the value bound to name,
+// or an Error if there is no such identifier.
+func (e Env) Lookup(name string) Value {
+	if v, ok := e[name]; ok {
+		return v
+	}
+	return Error(fmt.Sprintf("undefined identifier '%s'", name))
+}
+
+// FromGo converts a Go value to a Value: integer types become Int,
+// float types become Float, decimal.Decimal becomes Decimal, bool
+// becomes Bool, and strings are treated as literals, the same way
+// NewValue does it. Anything else yields an Error.
+func FromGo(v interface{}) Value {
+	switch v := v.(type) {
+	case Value:
+		return v
+	case bool:
+		return Bool(v)
+	case int:
+		return Int(v)
+	case int8:
+		return Int(v)
+	case int16:
+		return Int(v)
+	case int32:
+		return Int(v)
+	case int64:
+		return Int(v)
+	case uint8:
+		return Int(v)
+	case uint16:
+		return Int(v)
+	case uint32:
+		return Int(v)
+	case float32:
+		return Float(v)
+	case float64:
+		return Float(v)
+	case decimal.Decimal:
+		return Decimal{v}
+	case string:
+		return NewValue(v)
+	}
+	return Error(fmt.Sprintf("unsupported value type %T", v))
+}
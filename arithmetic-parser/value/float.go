@@ -0,0 +1,69 @@
+package value
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+
+	"github.com/unix-world/smartgoext/arithmetic-parser/lexer"
+)
+
+// Float implements Value interface for (inexact) floating point arithmetic.
+// Any operation with a Float operand produces a Float.
+type Float float64
+
+func (x Float) String() string { return strconv.FormatFloat(float64(x), 'g', -1, 64) }
+
+// BinaryOp implements floating point arithmetic for type Float.
+// Int and Decimal operands are converted to Float first.
+func (x Float) BinaryOp(op lexer.TokenType, y Value) Value {
+	var f Float
+	switch y := y.(type) {
+	case Float:
+		f = y
+	case Int:
+		f = Float(y)
+	case Decimal:
+		f = Float(y.InexactFloat64())
+	case Error:
+		return y
+	default:
+		return Error(fmt.Sprintf("illegal op: '%v %s %v'", x, op, y))
+	}
+	c := 0
+	if x < f {
+		c = -1
+	} else if x > f {
+		c = 1
+	}
+	if b, ok := compareOp(op, c); ok {
+		return b
+	}
+	var r Float
+	switch op {
+	case lexer.PLUS:
+		r = x + f
+	case lexer.MINUS:
+		r = x - f
+	case lexer.MULT:
+		r = x * f
+	case lexer.DIV:
+		if f == 0 {
+			return Error(fmt.Sprintf("division by zero: '%v / %v'", x, y))
+		}
+		r = x / f
+	case lexer.EXP:
+		r = Float(math.Pow(float64(x), float64(f)))
+	case lexer.REM:
+		if f == 0 {
+			return Error(fmt.Sprintf("modulo of zero: '%v %% %v'", x, y))
+		}
+		r = Float(math.Mod(float64(x), float64(f)))
+	default:
+		return Error(fmt.Sprintf("illegal op: '%v %s %v'", x, op, y))
+	}
+	if math.IsNaN(float64(r)) || math.IsInf(float64(r), 0) {
+		return Error(fmt.Sprintf("result out of range: '%v %s %v'", x, op, y))
+	}
+	return r
+}
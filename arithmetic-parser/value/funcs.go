@@ -0,0 +1,214 @@
+package value
+
+import (
+	"fmt"
+	"math"
+
+	"github.com/unix-world/smartgoext/arithmetic-parser/lexer"
+	"github.com/unix-world/smartgoext/bignum/shopspring/decimal"
+)
+
+// Func is a function callable from an expression, like "max(a, b)".
+// Any argument that is an Error should be returned as the result.
+type Func func(args []Value) Value
+
+// Builtins holds the functions every expression can call.
+var Builtins = map[string]Func{
+	"min":   fnMin,
+	"max":   fnMax,
+	"abs":   fnAbs,
+	"round": fnRound,
+	"sqrt":  fnSqrt,
+	"pow":   fnPow,
+}
+
+// Call runs the built-in function name with args.
+func Call(name string, args []Value) Value {
+	fn, ok := Builtins[name]
+	if !ok {
+		return Error(fmt.Sprintf("undefined function '%s'", name))
+	}
+	for _, a := range args {
+		if e, ok := a.(Error); ok {
+			return e
+		}
+	}
+	return fn(args)
+}
+
+func wantArgs(name string, args []Value, min, max int) Value {
+	if len(args) < min || len(args) > max {
+		if min == max {
+			return Error(fmt.Sprintf("%s() wants %d argument(s), got %d", name, min, len(args)))
+		}
+		return Error(fmt.Sprintf("%s() wants %d to %d arguments, got %d", name, min, max, len(args)))
+	}
+	return nil
+}
+
+// compare returns -1, 0 or +1 as x is less than, equal to or greater than y.
+func compare(x, y Value) (int, Value) {
+	_, fx := x.(Float)
+	_, fy := y.(Float)
+	if fx || fy {
+		a, err := toFloat(x)
+		if err != nil {
+			return 0, err
+		}
+		b, err := toFloat(y)
+		if err != nil {
+			return 0, err
+		}
+		switch {
+		case a < b:
+			return -1, nil
+		case a > b:
+			return 1, nil
+		}
+		return 0, nil
+	}
+	a, err := toDecimal(x)
+	if err != nil {
+		return 0, err
+	}
+	b, err := toDecimal(y)
+	if err != nil {
+		return 0, err
+	}
+	return a.Cmp(b), nil
+}
+
+func toFloat(v Value) (float64, Value) {
+	switch v := v.(type) {
+	case Int:
+		return float64(v), nil
+	case Float:
+		return float64(v), nil
+	case Decimal:
+		return v.InexactFloat64(), nil
+	}
+	return 0, Error(fmt.Sprintf("not a number: '%v'", v))
+}
+
+func toDecimal(v Value) (decimal.Decimal, Value) {
+	switch v := v.(type) {
+	case Int:
+		return decimal.NewFromInt(int64(v)), nil
+	case Float:
+		return decimal.NewFromFloat(float64(v)), nil
+	case Decimal:
+		return v.Decimal, nil
+	}
+	return decimal.Decimal{}, Error(fmt.Sprintf("not a number: '%v'", v))
+}
+
+func pick(name string, args []Value, want int) Value {
+	if len(args) == 0 {
+		return Error(fmt.Sprintf("%s() wants at least 1 argument", name))
+	}
+	r := args[0]
+	if _, err := toFloat(r); err != nil {
+		return err
+	}
+	for _, a := range args[1:] {
+		c, err := compare(a, r)
+		if err != nil {
+			return err
+		}
+		if c == want {
+			r = a
+		}
+	}
+	return r
+}
+
+func fnMin(args []Value) Value {
+	return pick("min", args, -1)
+}
+
+func fnMax(args []Value) Value {
+	return pick("max", args, 1)
+}
+
+func fnAbs(args []Value) Value {
+	if err := wantArgs("abs", args, 1, 1); err != nil {
+		return err
+	}
+	switch x := args[0].(type) {
+	case Int:
+		if x == math.MinInt {
+			return Decimal{x.decimal().Abs()}
+		}
+		if x < 0 {
+			return -x
+		}
+		return x
+	case Float:
+		return Float(math.Abs(float64(x)))
+	case Decimal:
+		return Decimal{x.Abs()}
+	}
+	return Error(fmt.Sprintf("not a number: '%v'", args[0]))
+}
+
+// fnRound rounds half away from zero, "round(x)" to a whole
+// number, "round(x, places)" to that many decimal places.
+func fnRound(args []Value) Value {
+	if err := wantArgs("round", args, 1, 2); err != nil {
+		return err
+	}
+	places := int32(0)
+	if len(args) == 2 {
+		p, ok := args[1].(Int)
+		if !ok || p < math.MinInt32 || p > math.MaxInt32 {
+			return Error(fmt.Sprintf("round() places must be an integer, got '%v'", args[1]))
+		}
+		places = int32(p)
+	}
+	switch x := args[0].(type) {
+	case Int:
+		if places >= 0 {
+			return x
+		}
+		d := x.decimal().Round(places)
+		if d.GreaterThan(decimal.NewFromInt(math.MaxInt)) || d.LessThan(decimal.NewFromInt(math.MinInt)) {
+			return Decimal{d}
+		}
+		return Int(d.IntPart())
+	case Float:
+		return Float(decimal.NewFromFloat(float64(x)).Round(places).InexactFloat64())
+	case Decimal:
+		return Decimal{x.Round(places)}
+	}
+	return Error(fmt.Sprintf("not a number: '%v'", args[0]))
+}
+
+func fnSqrt(args []Value) Value {
+	if err := wantArgs("sqrt", args, 1, 1); err != nil {
+		return err
+	}
+	f, err := toFloat(args[0])
+	if err != nil {
+		return err
+	}
+	if f < 0 {
+		return Error(fmt.Sprintf("square root of negative number: '%v'", args[0]))
+	}
+	r := math.Sqrt(f)
+	switch x := args[0].(type) {
+	case Int:
+		if n := Int(r); n*n == x {
+			return n
+		}
+	case Decimal:
+		return Decimal{decimal.NewFromFloat(r)}
+	}
+	return Float(r)
+}
+
+func fnPow(args []Value) Value {
+	if err := wantArgs("pow", args, 2, 2); err != nil {
+		return err
+	}
+	return args[0].BinaryOp(lexer.EXP, args[1])
+}
@@ -2,27 +2,36 @@ package value
 
 import (
 	"fmt"
+	"math"
 	"strconv"
 
 	"github.com/unix-world/smartgoext/arithmetic-parser/lexer"
+	"github.com/unix-world/smartgoext/bignum/shopspring/decimal"
 )
 
 // Value interface allows parse tree evaluation to return
 // an error all the way up the call stack to the user.
-// There's an integer type and an error type that fit this interface.
+// There are integer, float, arbitrary-precision decimal and boolean
+// types, and an error type that fit this interface.
 type Value interface {
 	BinaryOp(op lexer.TokenType, y Value) Value
 	String() string
 }
 
 // NewValue creates an instance of type Int if possible, which fits Value
-// interface. Otherwise it creates an Error instance, which will end up the
+// interface. Literals with a fractional part, or integers too large for
+// an Int, become a Decimal so that no precision is lost.
+// Otherwise it creates an Error instance, which will end up the
 // result of an evaluation.
 func NewValue(lit string) Value {
 	x, err := strconv.Atoi(lit)
 	if err == nil {
 		return Int(x)
 	}
+	d, err := decimal.NewFromString(lit)
+	if err == nil {
+		return Decimal{d}
+	}
 	return Error(fmt.Sprintf("illegal literal '%s'", lit))
 }
 
@@ -32,27 +41,73 @@ type Int int
 func (x Int) String() string { return strconv.Itoa(int(x)) }
 
 // BinaryOp implements integer arithmetic for type Int.
-// Some error handling exists, but it does not check overflow.
+// Results that overflow an Int, and quotients that are not whole
+// numbers, are computed as a Decimal instead.
 func (x Int) BinaryOp(op lexer.TokenType, y Value) Value {
 	switch y := y.(type) {
 	case Int:
+		c := 0
+		if x < y {
+			c = -1
+		} else if x > y {
+			c = 1
+		}
+		if b, ok := compareOp(op, c); ok {
+			return b
+		}
 		switch op {
 		case lexer.PLUS:
-			return x + y
+			s := x + y
+			if (s > x) != (y > 0) {
+				return x.decimal().BinaryOp(op, y.decimal())
+			}
+			return s
 		case lexer.MINUS:
-			return x - y
+			d := x - y
+			if (d < x) != (y > 0) {
+				return x.decimal().BinaryOp(op, y.decimal())
+			}
+			return d
 		case lexer.MULT:
+			if x != 0 {
+				p := x * y
+				if p/x != y || (x == -1 && y == math.MinInt) {
+					return x.decimal().BinaryOp(op, y.decimal())
+				}
+				return p
+			}
 			return x * y
 		case lexer.DIV:
 			if y == 0 {
 				return Error(fmt.Sprintf("division by zero: '%v / %v'", x, y))
 			}
+			if x%y != 0 || (x == math.MinInt && y == -1) {
+				return x.decimal().BinaryOp(op, y.decimal())
+			}
 			return x / y
 		case lexer.EXP:
+			if y < 0 {
+				return x.decimal().BinaryOp(op, y)
+			}
+			switch x {
+			case 0, 1:
+				if y == 0 {
+					return Int(1)
+				}
+				return x
+			case -1:
+				if y%2 == 0 {
+					return Int(1)
+				}
+				return x
+			}
 			n := Int(1)
-			for y > 0 {
-				n *= x
-				y--
+			for i := y; i > 0; i-- {
+				p := n * x
+				if x != 0 && (p/x != n || (x == -1 && n == math.MinInt)) {
+					return x.decimal().BinaryOp(op, y)
+				}
+				n = p
 			}
 			return n
 		case lexer.REM:
@@ -61,12 +116,20 @@ func (x Int) BinaryOp(op lexer.TokenType, y Value) Value {
 			}
 			return x % y
 		}
+	case Decimal:
+		return x.decimal().BinaryOp(op, y)
+	case Float:
+		return Float(x).BinaryOp(op, y)
 	case Error:
 		return y
 	}
 	return Error(fmt.Sprintf("illegal op: '%v %s %v'", x, op, y))
 }
 
+func (x Int) decimal() Decimal {
+	return Decimal{decimal.NewFromInt(int64(x))}
+}
+
 // Error implements Value interface for sending errors up the call stack
 type Error string
 
@@ -15,40 +15,52 @@ type Calendar struct {
 
 // RecurrenceSet returns the Recurrence Set for this component.
 func (comp *Component) RecurrenceSet(loc *time.Location) (*rrule.Set, error) {
+	return comp.recurrenceSet(loc, loadLocation)
+}
+
+func (comp *Component) recurrenceSet(loc *time.Location, resolve locationFunc) (*rrule.Set, error) {
 	roption, err := comp.Props.RecurrenceRule()
 	if err != nil {
 		return nil, fmt.Errorf("ical: error parsing recurrence: %v", err)
 	}
-	if roption == nil {
+	if roption == nil && len(comp.Props[PropRecurrenceDates]) == 0 {
 		return nil, nil
 	}
-	dateTime, err := comp.Props.DateTime(PropDateTimeStart, loc)
+	dateTime, err := comp.Props.dateTime(PropDateTimeStart, loc, resolve)
 	if err != nil {
 		return nil, fmt.Errorf("ical: error parsing start time: %v", err)
 	}
 
-	rule, err := rrule.NewRRule(*roption)
-	if err != nil {
-		return nil, fmt.Errorf("ical: error buildling rrule: %v", err)
-	}
-
 	ruleSet := rrule.Set{}
-	ruleSet.RRule(rule)
+	if roption != nil {
+		rule, err := rrule.NewRRule(*roption)
+		if err != nil {
+			return nil, fmt.Errorf("ical: error buildling rrule: %v", err)
+		}
+		ruleSet.RRule(rule)
+	} else {
+		// DTSTART is the first instance of a recurrence of RDATEs only
+		ruleSet.RDate(dateTime)
+	}
 	ruleSet.DTStart(dateTime)
 
 	for _, exdateProp := range comp.Props[PropExceptionDates] {
-		exdate, err := exdateProp.DateTime(loc)
+		exdates, err := exdateProp.dateTimeList(loc, resolve)
 		if err != nil {
 			return nil, fmt.Errorf("ical: error parsing exdate: %v", err)
 		}
-		ruleSet.ExDate(exdate)
+		for _, exdate := range exdates {
+			ruleSet.ExDate(exdate)
+		}
 	}
 	for _, rdateProp := range comp.Props[PropRecurrenceDates] {
-		rdate, err := rdateProp.DateTime(loc)
+		rdates, err := rdateProp.dateTimeList(loc, resolve)
 		if err != nil {
 			return nil, fmt.Errorf("ical: error parsing rdate: %v", err)
 		}
-		ruleSet.RDate(rdate)
+		for _, rdate := range rdates {
+			ruleSet.RDate(rdate)
+		}
 	}
 
 	return &ruleSet, nil
@@ -87,8 +99,12 @@ func (e *Event) DateTimeStart(loc *time.Location) (time.Time, error) {
 
 // DateTimeEnd returns the non-inclusive end of the event.
 func (e *Event) DateTimeEnd(loc *time.Location) (time.Time, error) {
+	return e.dateTimeEnd(loc, loadLocation)
+}
+
+func (e *Event) dateTimeEnd(loc *time.Location, resolve locationFunc) (time.Time, error) {
 	if prop := e.Props.Get(PropDateTimeEnd); prop != nil {
-		return prop.DateTime(loc)
+		return prop.dateTime(prop.Value, loc, resolve)
 	}
 
 	startProp := e.Props.Get(PropDateTimeStart)
@@ -96,7 +112,7 @@ func (e *Event) DateTimeEnd(loc *time.Location) (time.Time, error) {
 		return time.Time{}, nil
 	}
 
-	start, err := startProp.DateTime(loc)
+	start, err := startProp.dateTime(startProp.Value, loc, resolve)
 	if err != nil {
 		return time.Time{}, err
 	}
@@ -0,0 +1,117 @@
+package ical
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// EventOccurrence is an instance of an event of a calendar.
+type EventOccurrence struct {
+	// Event is the recurring event or the event overriding the instance.
+	Event Event
+	// RecurrenceID is the original start of the instance, it is zero if the
+	// event does not recur.
+	RecurrenceID time.Time
+	Start        time.Time
+	End          time.Time
+}
+
+// ExpandEvents returns the instances of the events of the calendar which overlap
+// the interval from start to end, ordered by their start.
+//
+// Recurring events are expanded with their RRULE, RDATE and EXDATE properties.
+// An instance is replaced by the event with the same UID and a RECURRENCE-ID of
+// its original start, instances overridden by a cancelled event are dropped.
+// TZIDs are resolved by the Timezones of the calendar and floating date-times
+// are in loc.
+func (cal *Calendar) ExpandEvents(start, end time.Time, loc *time.Location) ([]EventOccurrence, error) {
+	tz := cal.Timezones()
+	events := cal.Events()
+
+	// the recurrence IDs of the overridden instances by UID
+	overridden := make(map[string]map[int64]bool)
+	for _, e := range events {
+		prop := e.Props.Get(PropRecurrenceID)
+		if prop == nil {
+			continue
+		}
+		uid, err := e.Props.Text(PropUID)
+		if err != nil {
+			return nil, err
+		}
+		rid, err := tz.DateTime(prop, loc)
+		if err != nil {
+			return nil, fmt.Errorf("ical: error parsing recurrence id: %v", err)
+		}
+		if overridden[uid] == nil {
+			overridden[uid] = make(map[int64]bool)
+		}
+		overridden[uid][rid.Unix()] = true
+	}
+
+	var l []EventOccurrence
+	for _, e := range events {
+		if e.Props.Get(PropDateTimeStart) == nil {
+			continue
+		}
+		eventStart, err := e.Props.dateTime(PropDateTimeStart, loc, tz.Location)
+		if err != nil {
+			return nil, fmt.Errorf("ical: error parsing start time: %v", err)
+		}
+		eventEnd, err := e.dateTimeEnd(loc, tz.Location)
+		if err != nil {
+			return nil, fmt.Errorf("ical: error parsing end time: %v", err)
+		}
+
+		if prop := e.Props.Get(PropRecurrenceID); prop != nil {
+			status, _ := e.Props.Text(PropStatus)
+			if EventStatus(strings.ToUpper(status)) == EventCancelled {
+				continue
+			}
+			if overlaps(eventStart, eventEnd, start, end) {
+				rid, _ := tz.DateTime(prop, loc)
+				l = append(l, EventOccurrence{e, rid, eventStart, eventEnd})
+			}
+			continue
+		}
+
+		set, err := tz.RecurrenceSet(e.Component, loc)
+		if err != nil {
+			return nil, err
+		}
+		if set == nil {
+			if overlaps(eventStart, eventEnd, start, end) {
+				l = append(l, EventOccurrence{e, time.Time{}, eventStart, eventEnd})
+			}
+			continue
+		}
+
+		uid, err := e.Props.Text(PropUID)
+		if err != nil {
+			return nil, err
+		}
+		dur := eventEnd.Sub(eventStart)
+		for _, t := range set.Between(start.Add(-dur), end, true) {
+			if overridden[uid][t.Unix()] || !overlaps(t, t.Add(dur), start, end) {
+				continue
+			}
+			l = append(l, EventOccurrence{e, t, t, t.Add(dur)})
+		}
+	}
+
+	sort.SliceStable(l, func(i, j int) bool {
+		return l[i].Start.Before(l[j].Start)
+	})
+	return l, nil
+}
+
+// overlaps reports whether an instance overlaps the interval from start to end,
+// an instance without duration overlaps it if it starts within.
+func overlaps(instStart, instEnd, start, end time.Time) bool {
+	if !instStart.Before(end) {
+		return false
+	}
+	return instEnd.After(start) || !instStart.Before(start)
+}
@@ -0,0 +1,138 @@
+package ical
+
+import (
+	"testing"
+	"time"
+)
+
+const testEvents = "BEGIN:VEVENT\r\n" +
+	"UID:weekly@example.org\r\n" +
+	"SUMMARY:Weekly\r\n" +
+	"DTSTART;TZID=Outlook Europe:20210301T100000\r\n" +
+	"DTEND;TZID=Outlook Europe:20210301T110000\r\n" +
+	"RRULE:FREQ=WEEKLY;COUNT=6\r\n" +
+	"EXDATE;TZID=Outlook Europe:20210315T100000\r\n" +
+	"END:VEVENT\r\n" +
+	"BEGIN:VEVENT\r\n" +
+	"UID:weekly@example.org\r\n" +
+	"SUMMARY:Moved\r\n" +
+	"RECURRENCE-ID;TZID=Outlook Europe:20210322T100000\r\n" +
+	"DTSTART;TZID=Outlook Europe:20210322T140000\r\n" +
+	"DTEND;TZID=Outlook Europe:20210322T150000\r\n" +
+	"END:VEVENT\r\n" +
+	"BEGIN:VEVENT\r\n" +
+	"UID:weekly@example.org\r\n" +
+	"SUMMARY:Cancelled\r\n" +
+	"RECURRENCE-ID;TZID=Outlook Europe:20210405T100000\r\n" +
+	"DTSTART;TZID=Outlook Europe:20210405T100000\r\n" +
+	"DTEND;TZID=Outlook Europe:20210405T110000\r\n" +
+	"STATUS:CANCELLED\r\n" +
+	"END:VEVENT\r\n" +
+	"BEGIN:VEVENT\r\n" +
+	"UID:allday@example.org\r\n" +
+	"SUMMARY:All day\r\n" +
+	"DTSTART;VALUE=DATE:20210310\r\n" +
+	"DTEND;VALUE=DATE:20210311\r\n" +
+	"END:VEVENT\r\n" +
+	"BEGIN:VEVENT\r\n" +
+	"UID:floating@example.org\r\n" +
+	"SUMMARY:Floating\r\n" +
+	"DTSTART:20210320T090000\r\n" +
+	"DURATION:PT30M\r\n" +
+	"END:VEVENT\r\n" +
+	"BEGIN:VEVENT\r\n" +
+	"UID:later@example.org\r\n" +
+	"SUMMARY:Later\r\n" +
+	"DTSTART:20210601T090000Z\r\n" +
+	"DTEND:20210601T100000Z\r\n" +
+	"END:VEVENT\r\n"
+
+type testOccurrence struct {
+	summary      string
+	recurrenceID time.Time
+	start        time.Time
+	end          time.Time
+}
+
+func expandTest(t *testing.T, cal *Calendar, start, end time.Time, loc *time.Location, want []testOccurrence) {
+	t.Helper()
+	l, err := cal.ExpandEvents(start, end, loc)
+	if err != nil {
+		t.Fatalf("ExpandEvents() = %v", err)
+	}
+	if len(l) != len(want) {
+		t.Errorf("ExpandEvents() returned %d occurrences, want %d", len(l), len(want))
+	}
+	for i := 0; i < len(l) && i < len(want); i++ {
+		summary, _ := l[i].Event.Props.Text(PropSummary)
+		got := testOccurrence{summary, l[i].RecurrenceID, l[i].Start, l[i].End}
+		w := want[i]
+		if got.summary != w.summary || !got.recurrenceID.Equal(w.recurrenceID) || !got.start.Equal(w.start) || !got.end.Equal(w.end) {
+			t.Errorf("occurrence %d = %v, want %v", i, got, w)
+		}
+	}
+}
+
+func TestExpandEvents(t *testing.T) {
+	cal := decodeTestCalendar(t, testCalendar(testTimezoneOutlook, testEvents))
+	loc := time.FixedZone("floating", -3*3600)
+	utc := func(month time.Month, day, hour, minute int) time.Time {
+		return time.Date(2021, month, day, hour, minute, 0, 0, time.UTC)
+	}
+
+	expandTest(t, cal, utc(3, 1, 0, 0), utc(5, 1, 0, 0), loc, []testOccurrence{
+		{"Weekly", utc(3, 1, 9, 0), utc(3, 1, 9, 0), utc(3, 1, 10, 0)},
+		{"Weekly", utc(3, 8, 9, 0), utc(3, 8, 9, 0), utc(3, 8, 10, 0)},
+		{"All day", time.Time{}, time.Date(2021, 3, 10, 0, 0, 0, 0, loc), time.Date(2021, 3, 11, 0, 0, 0, 0, loc)},
+		{"Floating", time.Time{}, utc(3, 20, 12, 0), utc(3, 20, 12, 30)},
+		{"Moved", utc(3, 22, 9, 0), utc(3, 22, 13, 0), utc(3, 22, 14, 0)},
+		// the local time is kept across the change to daylight saving time
+		{"Weekly", utc(3, 29, 8, 0), utc(3, 29, 8, 0), utc(3, 29, 9, 0)},
+	})
+
+	// instances starting before the interval overlap it
+	expandTest(t, cal, utc(3, 8, 9, 30), utc(3, 8, 9, 45), loc, []testOccurrence{
+		{"Weekly", utc(3, 8, 9, 0), utc(3, 8, 9, 0), utc(3, 8, 10, 0)},
+	})
+	expandTest(t, cal, utc(3, 8, 10, 0), utc(3, 8, 11, 0), loc, nil)
+
+	// the override is found by its start, not by the original one
+	expandTest(t, cal, utc(3, 22, 8, 0), utc(3, 22, 11, 0), loc, nil)
+	expandTest(t, cal, utc(3, 22, 12, 0), utc(3, 22, 14, 0), loc, []testOccurrence{
+		{"Moved", utc(3, 22, 9, 0), utc(3, 22, 13, 0), utc(3, 22, 14, 0)},
+	})
+
+	expandTest(t, cal, utc(6, 1, 9, 59), utc(6, 2, 0, 0), loc, []testOccurrence{
+		{"Later", time.Time{}, utc(6, 1, 9, 0), utc(6, 1, 10, 0)},
+	})
+}
+
+func TestExpandEventsRDate(t *testing.T) {
+	cal := decodeTestCalendar(t, testCalendar(testTimezoneNewYork, "BEGIN:VEVENT\r\n"+
+		"UID:rdate@example.org\r\n"+
+		"SUMMARY:Dates\r\n"+
+		"DTSTART;TZID=Custom New York:20230301T090000\r\n"+
+		"DURATION:PT1H\r\n"+
+		"RDATE;TZID=Custom New York:20230315T090000,20230401T090000\r\n"+
+		"END:VEVENT\r\n"))
+	utc := func(month time.Month, day, hour int) time.Time {
+		return time.Date(2023, month, day, hour, 0, 0, 0, time.UTC)
+	}
+
+	expandTest(t, cal, utc(1, 1, 0), utc(12, 31, 0), time.UTC, []testOccurrence{
+		{"Dates", utc(3, 1, 14), utc(3, 1, 14), utc(3, 1, 15)},
+		{"Dates", utc(3, 15, 13), utc(3, 15, 13), utc(3, 15, 14)},
+		{"Dates", utc(4, 1, 13), utc(4, 1, 13), utc(4, 1, 14)},
+	})
+}
+
+func TestExpandEventsUnknownTimezone(t *testing.T) {
+	cal := decodeTestCalendar(t, testCalendar("BEGIN:VEVENT\r\n"+
+		"UID:unknown@example.org\r\n"+
+		"DTSTART;TZID=Nowhere/Atlantis:20230301T090000\r\n"+
+		"END:VEVENT\r\n"))
+	start := time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)
+	if _, err := cal.ExpandEvents(start, start.AddDate(1, 0, 0), time.UTC); err == nil {
+		t.Error("ExpandEvents() with an unknown TZID succeeded")
+	}
+}
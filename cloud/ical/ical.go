@@ -121,15 +121,23 @@ func (prop *Prop) Bool() (bool, error) {
 }
 
 // DateTime parses the property value as a date-time or a date.
+//
+// The TZID parameter is looked up in the IANA time zone database, Windows time
+// zone names are accepted as well. Use Calendar.Timezones to resolve the TZIDs
+// defined by the VTIMEZONE components of a calendar.
 func (prop *Prop) DateTime(loc *time.Location) (time.Time, error) {
+	return prop.dateTime(prop.Value, loc, loadLocation)
+}
+
+func (prop *Prop) dateTime(value string, loc *time.Location, resolve locationFunc) (time.Time, error) {
 	// Default to UTC, if there is no given location.
 	if loc == nil {
 		loc = time.UTC
 	}
 
 	valueType := prop.ValueType()
-	valueLength := len(prop.Value)
-	if valueType == ValueDefault {
+	valueLength := len(value)
+	if valueType == ValueDefault || valueType == ValuePeriod {
 		switch valueLength {
 		case len(dateFormat):
 			valueType = ValueDate
@@ -140,23 +148,45 @@ func (prop *Prop) DateTime(loc *time.Location) (time.Time, error) {
 
 	switch valueType {
 	case ValueDate:
-		return time.ParseInLocation(dateFormat, prop.Value, loc)
+		return time.ParseInLocation(dateFormat, value, loc)
 	case ValueDateTime:
 		if valueLength == len(datetimeUTCFormat) {
-			return time.ParseInLocation(datetimeUTCFormat, prop.Value, time.UTC)
+			return time.ParseInLocation(datetimeUTCFormat, value, time.UTC)
 		}
 		// Use the TZID location, if available.
 		if tzid := prop.Params.Get(PropTimezoneID); tzid != "" {
-			tzLoc, err := time.LoadLocation(tzid)
+			tzLoc, err := resolve(tzid)
 			if err != nil {
 				return time.Time{}, err
 			}
 			loc = tzLoc
 		}
-		return time.ParseInLocation(datetimeFormat, prop.Value, loc)
+		return time.ParseInLocation(datetimeFormat, value, loc)
 	}
 
-	return time.Time{}, fmt.Errorf("ical: cannot process: (%q) %s", valueType, prop.Value)
+	return time.Time{}, fmt.Errorf("ical: cannot process: (%q) %s", valueType, value)
+}
+
+// DateTimeList parses the property value as a list of date-times or dates, like
+// the values of EXDATE and RDATE. Periods are reduced to their start.
+func (prop *Prop) DateTimeList(loc *time.Location) ([]time.Time, error) {
+	return prop.dateTimeList(loc, loadLocation)
+}
+
+func (prop *Prop) dateTimeList(loc *time.Location, resolve locationFunc) ([]time.Time, error) {
+	values := strings.Split(prop.Value, ",")
+	l := make([]time.Time, 0, len(values))
+	for _, value := range values {
+		if i := strings.IndexByte(value, '/'); i >= 0 {
+			value = value[:i]
+		}
+		t, err := prop.dateTime(value, loc, resolve)
+		if err != nil {
+			return nil, err
+		}
+		l = append(l, t)
+	}
+	return l, nil
 }
 
 func (prop *Prop) SetDate(t time.Time) {
@@ -394,7 +424,51 @@ func (prop *Prop) SetURI(u *url.URL) {
 	prop.Value = u.String()
 }
 
-// TODO: Period, Time, UTCOffset
+// UTCOffset parses the property value as a UTC offset, like the values of
+// TZOFFSETFROM and TZOFFSETTO.
+func (prop *Prop) UTCOffset() (time.Duration, error) {
+	if err := prop.expectValueType(ValueUTCOffset); err != nil {
+		return 0, err
+	}
+
+	v := prop.Value
+	if (len(v) != 5 && len(v) != 7) || (v[0] != '+' && v[0] != '-') {
+		return 0, fmt.Errorf("ical: invalid UTC offset: %q", v)
+	}
+	var dur time.Duration
+	for i, unit := range []time.Duration{time.Hour, time.Minute, time.Second} {
+		if 1+2*i >= len(v) {
+			break
+		}
+		n, err := strconv.ParseUint(v[1+2*i:3+2*i], 10, 8)
+		if err != nil || (i > 0 && n >= 60) {
+			return 0, fmt.Errorf("ical: invalid UTC offset: %q", v)
+		}
+		dur += time.Duration(n) * unit
+	}
+	if v[0] == '-' {
+		dur = -dur
+	}
+	return dur, nil
+}
+
+func (prop *Prop) SetUTCOffset(dur time.Duration) {
+	prop.SetValueType(ValueUTCOffset)
+
+	sign := byte('+')
+	if dur < 0 {
+		sign = '-'
+		dur = -dur
+	}
+	sec := int(dur / time.Second)
+	s := fmt.Sprintf("%c%02d%02d", sign, sec/3600, sec/60%60)
+	if sec%60 != 0 {
+		s += fmt.Sprintf("%02d", sec%60)
+	}
+	prop.Value = s
+}
+
+// TODO: Period, Time
 
 // Props is a set of component properties.
 type Props map[string][]Prop
@@ -436,12 +510,23 @@ func (props Props) SetText(name, text string) {
 }
 
 func (props Props) DateTime(name string, loc *time.Location) (time.Time, error) {
+	return props.dateTime(name, loc, loadLocation)
+}
+
+func (props Props) dateTime(name string, loc *time.Location, resolve locationFunc) (time.Time, error) {
 	if prop := props.Get(name); prop != nil {
-		return prop.DateTime(loc)
+		return prop.dateTime(prop.Value, loc, resolve)
 	}
 	return time.Time{}, nil
 }
 
+func (props Props) UTCOffset(name string) (time.Duration, error) {
+	if prop := props.Get(name); prop != nil {
+		return prop.UTCOffset()
+	}
+	return 0, nil
+}
+
 func (props Props) SetDate(name string, t time.Time) {
 	prop := NewProp(name)
 	prop.SetDate(t)
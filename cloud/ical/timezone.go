@@ -0,0 +1,312 @@
+package ical
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/unix-world/smartgoext/cloud/ical/rrule"
+)
+
+// locationFunc resolves the TZID parameter of a date-time.
+type locationFunc func(tzid string) (*time.Location, error)
+
+// loadLocation looks tzid up in the IANA time zone database. Windows time zone
+// names and TZIDs with a path prefix, like /mozilla.org/20050126_1/Europe/Paris,
+// are accepted as well.
+func loadLocation(tzid string) (*time.Location, error) {
+	loc, err := time.LoadLocation(tzid)
+	if err == nil {
+		return loc, nil
+	}
+
+	if name, ok := windowsZones[tzid]; ok {
+		return time.LoadLocation(name)
+	}
+	for s := strings.TrimPrefix(tzid, "/"); s != tzid; {
+		i := strings.IndexByte(s, '/')
+		if i < 0 {
+			break
+		}
+		s = s[i+1:]
+		if l, e := time.LoadLocation(s); e == nil {
+			return l, nil
+		}
+	}
+	return nil, fmt.Errorf("ical: unknown time zone %q: %v", tzid, err)
+}
+
+// Timezones resolves the TZID parameters of the date-times of a calendar. It is
+// safe for concurrent use.
+type Timezones struct {
+	mu   sync.Mutex
+	defs map[string]*Component
+	locs map[string]*time.Location
+}
+
+// Timezones returns the resolver of the TZIDs of the calendar. A TZID defined by
+// a VTIMEZONE component of the calendar is resolved to a location built from its
+// STANDARD and DAYLIGHT components, other TZIDs are looked up in the IANA time
+// zone database and by their Windows time zone name.
+func (cal *Calendar) Timezones() *Timezones {
+	tz := &Timezones{
+		defs: make(map[string]*Component),
+		locs: make(map[string]*time.Location),
+	}
+	for _, child := range cal.Children {
+		if child.Name != CompTimezone {
+			continue
+		}
+		if tzid, err := child.Props.Text(PropTimezoneID); err == nil && tzid != "" {
+			tz.defs[tzid] = child
+		}
+	}
+	return tz
+}
+
+// Location returns the location of a TZID.
+func (tz *Timezones) Location(tzid string) (*time.Location, error) {
+	tz.mu.Lock()
+	defer tz.mu.Unlock()
+
+	if loc, ok := tz.locs[tzid]; ok {
+		return loc, nil
+	}
+
+	var loc *time.Location
+	var err error
+	if def, ok := tz.defs[tzid]; ok {
+		loc, err = timezoneLocation(tzid, def)
+	} else {
+		loc, err = loadLocation(tzid)
+	}
+	if err != nil {
+		return nil, err
+	}
+	tz.locs[tzid] = loc
+	return loc, nil
+}
+
+// DateTime parses the value of prop as a date-time or a date like Prop.DateTime,
+// with the TZID parameter resolved by tz.
+func (tz *Timezones) DateTime(prop *Prop, loc *time.Location) (time.Time, error) {
+	return prop.dateTime(prop.Value, loc, tz.Location)
+}
+
+// RecurrenceSet returns the Recurrence Set of comp like Component.RecurrenceSet,
+// with the TZID parameters resolved by tz.
+func (tz *Timezones) RecurrenceSet(comp *Component, loc *time.Location) (*rrule.Set, error) {
+	return comp.recurrenceSet(loc, tz.Location)
+}
+
+// timezoneHorizon is the end of the transitions computed from the rules of a
+// VTIMEZONE, the last offset applies after it.
+var timezoneHorizon = time.Date(2100, 1, 1, 0, 0, 0, 0, time.UTC)
+
+// timezoneRuleStart is the first year of the transitions of an unbounded yearly
+// rule. Outlook starts its rules in 1601, which is beyond the range of rrule.
+const timezoneRuleStart = 1900
+
+type zoneType struct {
+	offset int // seconds east of UTC
+	isDST  bool
+	name   string
+}
+
+type zoneTransition struct {
+	at   int64 // Unix time
+	zone int
+}
+
+// timezoneLocation builds the location of a VTIMEZONE from the onsets of its
+// STANDARD and DAYLIGHT components.
+func timezoneLocation(tzid string, comp *Component) (*time.Location, error) {
+	// the first type applies before the first transition
+	zones := []zoneType{{}}
+	var transitions []zoneTransition
+
+	var first *zoneTransition
+	var firstFrom time.Duration
+	for _, child := range comp.Children {
+		if child.Name != CompTimezoneStandard && child.Name != CompTimezoneDaylight {
+			continue
+		}
+
+		from, err := child.Props.UTCOffset(PropTimezoneOffsetFrom)
+		if err != nil {
+			return nil, err
+		}
+		to, err := child.Props.UTCOffset(PropTimezoneOffsetTo)
+		if err != nil {
+			return nil, err
+		}
+		name, err := child.Props.Text(PropTimezoneName)
+		if err != nil {
+			return nil, err
+		}
+		if name == "" {
+			name = offsetName(to)
+		}
+
+		zone := zoneType{int(to / time.Second), child.Name == CompTimezoneDaylight, name}
+		index := -1
+		for i := 1; i < len(zones); i++ {
+			if zones[i] == zone {
+				index = i
+				break
+			}
+		}
+		if index < 0 {
+			index = len(zones)
+			zones = append(zones, zone)
+		}
+
+		onsets, err := timezoneOnsets(child, from)
+		if err != nil {
+			return nil, fmt.Errorf("ical: invalid %s of time zone %q: %v", child.Name, tzid, err)
+		}
+		for _, onset := range onsets {
+			transitions = append(transitions, zoneTransition{onset.Unix(), index})
+			if first == nil || onset.Unix() < first.at {
+				first = &zoneTransition{onset.Unix(), index}
+				firstFrom = from
+			}
+		}
+	}
+	if first == nil {
+		return nil, fmt.Errorf("ical: time zone %q has no STANDARD or DAYLIGHT onset", tzid)
+	}
+
+	// the offset before the first onset is the other of a DST change, or the same
+	// zone if the onset does not change the offset
+	zones[0] = zoneType{int(firstFrom / time.Second), !zones[first.zone].isDST, offsetName(firstFrom)}
+	if zones[0].offset == zones[first.zone].offset {
+		zones[0].isDST = zones[first.zone].isDST
+	}
+	for _, zone := range zones[1:] {
+		if zone.offset == zones[0].offset && zone.isDST == zones[0].isDST {
+			zones[0].name = zone.name
+			break
+		}
+	}
+
+	sort.SliceStable(transitions, func(i, j int) bool {
+		return transitions[i].at < transitions[j].at
+	})
+	return time.LoadLocationFromTZData(tzid, tzifData(zones, transitions))
+}
+
+// timezoneOnsets returns the onsets of a STANDARD or DAYLIGHT component until
+// the horizon. Its local times are in the offset before the onset.
+func timezoneOnsets(comp *Component, from time.Duration) ([]time.Time, error) {
+	prop := comp.Props.Get(PropDateTimeStart)
+	if prop == nil {
+		return nil, fmt.Errorf("missing DTSTART")
+	}
+	loc := time.FixedZone(offsetName(from), int(from/time.Second))
+	dtstart, err := prop.DateTime(loc)
+	if err != nil {
+		return nil, err
+	}
+
+	roption, err := comp.Props.RecurrenceRule()
+	if err != nil {
+		return nil, err
+	}
+	onsets := []time.Time{dtstart}
+	if roption != nil {
+		start := dtstart
+		if roption.Freq == rrule.YEARLY && roption.Interval <= 1 && roption.Count == 0 && start.Year() < timezoneRuleStart {
+			// the placeholder DTSTART only seeds the rule, it is no transition
+			start = time.Date(timezoneRuleStart, start.Month(), start.Day(), start.Hour(), start.Minute(), start.Second(), 0, loc)
+			onsets = nil
+		}
+		roption.Dtstart = start
+		rule, err := rrule.NewRRule(*roption)
+		if err != nil {
+			return nil, err
+		}
+		onsets = append(onsets, rule.Between(start, timezoneHorizon, true)...)
+	}
+	for _, rdateProp := range comp.Props[PropRecurrenceDates] {
+		rdates, err := rdateProp.DateTimeList(loc)
+		if err != nil {
+			return nil, err
+		}
+		onsets = append(onsets, rdates...)
+	}
+
+	seen := make(map[int64]bool, len(onsets))
+	l := onsets[:0]
+	for _, onset := range onsets {
+		if !seen[onset.Unix()] && onset.Before(timezoneHorizon) {
+			seen[onset.Unix()] = true
+			l = append(l, onset)
+		}
+	}
+	return l, nil
+}
+
+// offsetName returns the name of a zone without TZNAME, like +0130.
+func offsetName(offset time.Duration) string {
+	prop := Prop{Name: PropTimezoneOffsetTo, Params: make(Params)}
+	prop.SetUTCOffset(offset)
+	return prop.Value
+}
+
+// tzifData encodes zones and transitions in the TZif format of RFC 8536, which
+// is the only way to build a location with transitions.
+func tzifData(zones []zoneType, transitions []zoneTransition) []byte {
+	var names []byte
+	nameIndex := make(map[string]int)
+	for _, zone := range zones {
+		if _, ok := nameIndex[zone.name]; !ok {
+			nameIndex[zone.name] = len(names)
+			names = append(append(names, zone.name...), 0)
+		}
+	}
+
+	var buf bytes.Buffer
+	header := func(timeCount, typeCount int) {
+		buf.WriteString("TZif2")
+		buf.Write(make([]byte, 15))
+		// isutcnt, isstdcnt, leapcnt, timecnt, typecnt, charcnt
+		for _, n := range []int{0, 0, 0, timeCount, typeCount, len(names)} {
+			binary.Write(&buf, binary.BigEndian, uint32(n))
+		}
+	}
+	zoneData := func(zone zoneType) {
+		binary.Write(&buf, binary.BigEndian, int32(zone.offset))
+		if zone.isDST {
+			buf.WriteByte(1)
+		} else {
+			buf.WriteByte(0)
+		}
+		buf.WriteByte(byte(nameIndex[zone.name]))
+	}
+
+	// readers of version 2 skip the 32-bit data, which holds the first zone only
+	header(0, 1)
+	zoneData(zones[0])
+	buf.Write(names)
+
+	header(len(transitions), len(zones))
+	for _, t := range transitions {
+		binary.Write(&buf, binary.BigEndian, t.at)
+	}
+	for _, t := range transitions {
+		buf.WriteByte(byte(t.zone))
+	}
+	for _, zone := range zones {
+		zoneData(zone)
+	}
+	buf.Write(names)
+	// no rule for the time after the last transition
+	buf.WriteString("\n\n")
+
+	return buf.Bytes()
+}
@@ -0,0 +1,190 @@
+package ical
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+// testTimezoneOutlook is a VTIMEZONE like Outlook writes them, with rules seeded
+// by a DTSTART in 1601.
+const testTimezoneOutlook = "BEGIN:VTIMEZONE\r\n" +
+	"TZID:Outlook Europe\r\n" +
+	"BEGIN:STANDARD\r\n" +
+	"DTSTART:16010101T030000\r\n" +
+	"TZOFFSETFROM:+0200\r\n" +
+	"TZOFFSETTO:+0100\r\n" +
+	"RRULE:FREQ=YEARLY;BYDAY=-1SU;BYMONTH=10\r\n" +
+	"END:STANDARD\r\n" +
+	"BEGIN:DAYLIGHT\r\n" +
+	"DTSTART:16010101T020000\r\n" +
+	"TZOFFSETFROM:+0100\r\n" +
+	"TZOFFSETTO:+0200\r\n" +
+	"RRULE:FREQ=YEARLY;BYDAY=-1SU;BYMONTH=3\r\n" +
+	"END:DAYLIGHT\r\n" +
+	"END:VTIMEZONE\r\n"
+
+const testTimezoneNewYork = "BEGIN:VTIMEZONE\r\n" +
+	"TZID:Custom New York\r\n" +
+	"BEGIN:STANDARD\r\n" +
+	"DTSTART:20071104T020000\r\n" +
+	"TZOFFSETFROM:-0400\r\n" +
+	"TZOFFSETTO:-0500\r\n" +
+	"TZNAME:EST\r\n" +
+	"RRULE:FREQ=YEARLY;BYMONTH=11;BYDAY=1SU\r\n" +
+	"END:STANDARD\r\n" +
+	"BEGIN:DAYLIGHT\r\n" +
+	"DTSTART:20070311T020000\r\n" +
+	"TZOFFSETFROM:-0500\r\n" +
+	"TZOFFSETTO:-0400\r\n" +
+	"TZNAME:EDT\r\n" +
+	"RRULE:FREQ=YEARLY;BYMONTH=3;BYDAY=2SU\r\n" +
+	"END:DAYLIGHT\r\n" +
+	"END:VTIMEZONE\r\n"
+
+const testTimezoneFixed = "BEGIN:VTIMEZONE\r\n" +
+	"TZID:Custom Kolkata\r\n" +
+	"BEGIN:STANDARD\r\n" +
+	"DTSTART:19700101T000000\r\n" +
+	"TZOFFSETFROM:+0530\r\n" +
+	"TZOFFSETTO:+0530\r\n" +
+	"END:STANDARD\r\n" +
+	"END:VTIMEZONE\r\n"
+
+// testTimezoneRDate has onsets listed by RDATE.
+const testTimezoneRDate = "BEGIN:VTIMEZONE\r\n" +
+	"TZID:Custom Dates\r\n" +
+	"BEGIN:DAYLIGHT\r\n" +
+	"DTSTART:20200301T020000\r\n" +
+	"RDATE:20210301T020000\r\n" +
+	"TZOFFSETFROM:+0000\r\n" +
+	"TZOFFSETTO:+0100\r\n" +
+	"END:DAYLIGHT\r\n" +
+	"BEGIN:STANDARD\r\n" +
+	"DTSTART:20201001T020000\r\n" +
+	"RDATE:20211001T020000\r\n" +
+	"TZOFFSETFROM:+0100\r\n" +
+	"TZOFFSETTO:+0000\r\n" +
+	"END:STANDARD\r\n" +
+	"END:VTIMEZONE\r\n"
+
+func decodeTestCalendar(t *testing.T, s string) *Calendar {
+	t.Helper()
+	cal, err := NewDecoder(strings.NewReader(s)).Decode()
+	if err != nil {
+		t.Fatalf("Decode() = %v", err)
+	}
+	return cal
+}
+
+func testCalendar(components ...string) string {
+	return "BEGIN:VCALENDAR\r\n" +
+		"VERSION:2.0\r\n" +
+		"PRODID:-//Test//Test//EN\r\n" +
+		strings.Join(components, "") +
+		"END:VCALENDAR\r\n"
+}
+
+func TestTimezonesLocation(t *testing.T) {
+	cal := decodeTestCalendar(t, testCalendar(testTimezoneOutlook, testTimezoneNewYork, testTimezoneFixed, testTimezoneRDate))
+	tz := cal.Timezones()
+
+	for _, tc := range []struct {
+		tzid   string
+		t      time.Time
+		name   string
+		offset int
+		isDST  bool
+	}{
+		// the 1601 onsets only seed the rules
+		{"Outlook Europe", time.Date(1850, 1, 15, 12, 0, 0, 0, time.UTC), "+0100", 3600, false},
+		{"Outlook Europe", time.Date(1850, 7, 15, 12, 0, 0, 0, time.UTC), "+0100", 3600, false},
+		{"Outlook Europe", time.Date(1900, 7, 15, 12, 0, 0, 0, time.UTC), "+0200", 7200, true},
+		{"Outlook Europe", time.Date(2021, 1, 15, 12, 0, 0, 0, time.UTC), "+0100", 3600, false},
+		{"Outlook Europe", time.Date(2021, 3, 28, 0, 59, 59, 0, time.UTC), "+0100", 3600, false},
+		{"Outlook Europe", time.Date(2021, 3, 28, 1, 0, 0, 0, time.UTC), "+0200", 7200, true},
+		{"Outlook Europe", time.Date(2021, 10, 31, 0, 59, 59, 0, time.UTC), "+0200", 7200, true},
+		{"Outlook Europe", time.Date(2021, 10, 31, 1, 0, 0, 0, time.UTC), "+0100", 3600, false},
+		{"Outlook Europe", time.Date(2150, 7, 15, 12, 0, 0, 0, time.UTC), "+0100", 3600, false},
+
+		// before DTSTART the offset is the TZOFFSETFROM of the first onset
+		{"Custom New York", time.Date(2000, 7, 1, 12, 0, 0, 0, time.UTC), "EST", -5 * 3600, false},
+		{"Custom New York", time.Date(2023, 1, 1, 12, 0, 0, 0, time.UTC), "EST", -5 * 3600, false},
+		{"Custom New York", time.Date(2023, 3, 12, 6, 59, 59, 0, time.UTC), "EST", -5 * 3600, false},
+		{"Custom New York", time.Date(2023, 3, 12, 7, 0, 0, 0, time.UTC), "EDT", -4 * 3600, true},
+		{"Custom New York", time.Date(2023, 11, 5, 6, 0, 0, 0, time.UTC), "EST", -5 * 3600, false},
+
+		{"Custom Kolkata", time.Date(1960, 1, 1, 0, 0, 0, 0, time.UTC), "+0530", 5*3600 + 1800, false},
+		{"Custom Kolkata", time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC), "+0530", 5*3600 + 1800, false},
+
+		{"Custom Dates", time.Date(2020, 6, 1, 0, 0, 0, 0, time.UTC), "+0100", 3600, true},
+		{"Custom Dates", time.Date(2020, 12, 1, 0, 0, 0, 0, time.UTC), "+0000", 0, false},
+		{"Custom Dates", time.Date(2021, 6, 1, 0, 0, 0, 0, time.UTC), "+0100", 3600, true},
+		{"Custom Dates", time.Date(2022, 6, 1, 0, 0, 0, 0, time.UTC), "+0000", 0, false},
+	} {
+		loc, err := tz.Location(tc.tzid)
+		if err != nil {
+			t.Fatalf("Location(%q) = %v", tc.tzid, err)
+		}
+		lt := tc.t.In(loc)
+		name, offset := lt.Zone()
+		if name != tc.name || offset != tc.offset || lt.IsDST() != tc.isDST {
+			t.Errorf("%s at %v = %s %d DST %v, want %s %d DST %v", tc.tzid, tc.t, name, offset, lt.IsDST(), tc.name, tc.offset, tc.isDST)
+		}
+	}
+}
+
+func TestTimezonesLoadLocation(t *testing.T) {
+	tz := decodeTestCalendar(t, testCalendar()).Timezones()
+	for _, tc := range []struct {
+		tzid string
+		want string
+	}{
+		{"Europe/Paris", "Europe/Paris"},
+		{"W. Europe Standard Time", "Europe/Berlin"},
+		{"/mozilla.org/20050126_1/Europe/Paris", "Europe/Paris"},
+		{"/citadel.org/20190914_1/America/New_York", "America/New_York"},
+	} {
+		loc, err := tz.Location(tc.tzid)
+		if err != nil {
+			t.Errorf("Location(%q) = %v", tc.tzid, err)
+			continue
+		}
+		if loc.String() != tc.want {
+			t.Errorf("Location(%q) = %v, want %v", tc.tzid, loc, tc.want)
+		}
+	}
+
+	if _, err := tz.Location("Nowhere/Atlantis"); err == nil {
+		t.Error("Location() of an unknown TZID succeeded")
+	}
+}
+
+func TestTimezonesDateTime(t *testing.T) {
+	cal := decodeTestCalendar(t, testCalendar(testTimezoneOutlook))
+	tz := cal.Timezones()
+
+	prop := NewProp(PropDateTimeStart)
+	prop.Value = "20210715T100000"
+	prop.Params.Set(ParamTimezoneID, "Outlook Europe")
+	got, err := tz.DateTime(prop, time.UTC)
+	if err != nil {
+		t.Fatalf("DateTime() = %v", err)
+	}
+	if want := time.Date(2021, 7, 15, 8, 0, 0, 0, time.UTC); !got.Equal(want) {
+		t.Errorf("DateTime() = %v, want %v", got, want)
+	}
+}
+
+func TestTimezonesInvalid(t *testing.T) {
+	for _, comp := range []string{
+		"BEGIN:VTIMEZONE\r\nTZID:Empty\r\nEND:VTIMEZONE\r\n",
+		"BEGIN:VTIMEZONE\r\nTZID:Empty\r\nBEGIN:STANDARD\r\nTZOFFSETFROM:+0100\r\nTZOFFSETTO:+0100\r\nEND:STANDARD\r\nEND:VTIMEZONE\r\n",
+		"BEGIN:VTIMEZONE\r\nTZID:Empty\r\nBEGIN:STANDARD\r\nDTSTART:19700101T000000\r\nTZOFFSETFROM:+1\r\nTZOFFSETTO:+0100\r\nEND:STANDARD\r\nEND:VTIMEZONE\r\n",
+	} {
+		tz := decodeTestCalendar(t, testCalendar(comp)).Timezones()
+		if _, err := tz.Location("Empty"); err == nil {
+			t.Errorf("Location() of %q succeeded", comp)
+		}
+	}
+}
@@ -0,0 +1,280 @@
+// Package dkim implements DomainKeys Identified Mail signatures, defined in
+// RFC 6376, with the RSA-SHA256 and Ed25519-SHA256 (RFC 8463) algorithms.
+//
+// Messages are signed and verified in their wire format, with CRLF line
+// endings. A message created by mail.CreateWriter can be signed by writing it
+// to a Signer:
+//
+//	var b bytes.Buffer
+//	s, err := dkim.NewSigner(options)
+//	if err != nil {
+//		log.Fatal(err)
+//	}
+//	mw, err := mail.CreateWriter(io.MultiWriter(&b, s), header)
+//	// write the parts, close mw and s
+//	signature := s.Signature() // to be written before the message in b
+package dkim
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// headerFieldName is the name of the header field holding a signature.
+const headerFieldName = "DKIM-Signature"
+
+// Canonicalization is an algorithm preparing a header or a body for signing.
+type Canonicalization string
+
+const (
+	// CanonicalizationSimple tolerates almost no modification.
+	CanonicalizationSimple Canonicalization = "simple"
+	// CanonicalizationRelaxed tolerates whitespace changes and header field
+	// rewrapping.
+	CanonicalizationRelaxed Canonicalization = "relaxed"
+)
+
+func parseCanonicalization(s string) (header, body Canonicalization, err error) {
+	header, body = CanonicalizationSimple, CanonicalizationSimple
+	if s == "" {
+		return
+	}
+	if i := strings.IndexByte(s, '/'); i >= 0 {
+		header, body = Canonicalization(s[:i]), Canonicalization(s[i+1:])
+	} else {
+		header = Canonicalization(s)
+	}
+	for _, c := range []Canonicalization{header, body} {
+		if c != CanonicalizationSimple && c != CanonicalizationRelaxed {
+			return "", "", fmt.Errorf("dkim: unknown canonicalization %q", c)
+		}
+	}
+	return
+}
+
+// A verificationError is the failure of a signature.
+type verificationError struct {
+	msg  string
+	temp bool
+}
+
+func (err *verificationError) Error() string {
+	return "dkim: " + err.msg
+}
+
+func permFail(format string, v ...interface{}) error {
+	return &verificationError{msg: fmt.Sprintf(format, v...)}
+}
+
+func tempFail(format string, v ...interface{}) error {
+	return &verificationError{msg: fmt.Sprintf(format, v...), temp: true}
+}
+
+// IsPermFail reports whether err is a permanent failure of a signature, for
+// instance an invalid signature or a revoked key.
+func IsPermFail(err error) bool {
+	var verr *verificationError
+	return errors.As(err, &verr) && !verr.temp
+}
+
+// IsTempFail reports whether err is a temporary failure of a signature, like a
+// failing key lookup.
+func IsTempFail(err error) bool {
+	var verr *verificationError
+	return errors.As(err, &verr) && verr.temp
+}
+
+// ErrBodyHashMismatch is the failure of a signature whose body hash differs
+// from the hash of the body of the message.
+var ErrBodyHashMismatch = &verificationError{msg: "body hash mismatch"}
+
+func isWSP(c byte) bool {
+	return c == ' ' || c == '\t'
+}
+
+// canonicalizeHeader canonicalizes a raw header field, which ends with CRLF.
+func canonicalizeHeader(raw []byte, c Canonicalization) []byte {
+	raw = fixCRLF(raw)
+	if c == CanonicalizationSimple {
+		return raw
+	}
+
+	colon := bytes.IndexByte(raw, ':')
+	if colon < 0 {
+		return raw
+	}
+	k := strings.ToLower(strings.TrimRight(string(raw[:colon]), " \t"))
+
+	v := make([]byte, 0, len(raw)-colon)
+	space := false
+	for _, ch := range raw[colon+1:] {
+		switch {
+		case ch == '\r' || ch == '\n':
+			// unfold
+		case isWSP(ch):
+			space = true
+		default:
+			if space && len(v) > 0 {
+				v = append(v, ' ')
+			}
+			space = false
+			v = append(v, ch)
+		}
+	}
+	return []byte(k + ":" + string(v) + "\r\n")
+}
+
+// fixCRLF converts bare LF line endings to CRLF.
+func fixCRLF(b []byte) []byte {
+	if !bytes.Contains(b, []byte{'\n'}) || bytes.Count(b, []byte("\r\n")) == bytes.Count(b, []byte{'\n'}) {
+		return b
+	}
+	fixed := make([]byte, 0, len(b)+8)
+	for i, ch := range b {
+		if ch == '\n' && (i == 0 || b[i-1] != '\r') {
+			fixed = append(fixed, '\r')
+		}
+		fixed = append(fixed, ch)
+	}
+	return fixed
+}
+
+// bodyCanonicalizer canonicalizes a body written to it and writes the result
+// to w, up to limit bytes if limit is not negative.
+type bodyCanonicalizer struct {
+	w     io.Writer
+	c     Canonicalization
+	limit int64
+
+	line    []byte
+	cr      bool // the last byte written is a CR
+	empty   int  // pending empty lines
+	written int64
+}
+
+func newBodyCanonicalizer(w io.Writer, c Canonicalization, limit int64) *bodyCanonicalizer {
+	return &bodyCanonicalizer{w: w, c: c, limit: limit}
+}
+
+func (bc *bodyCanonicalizer) Write(b []byte) (int, error) {
+	for _, ch := range b {
+		switch {
+		case ch == '\n':
+			if err := bc.writeLine(); err != nil {
+				return 0, err
+			}
+			bc.cr = false
+			continue
+		case bc.cr:
+			// a bare CR is part of the line
+			bc.line = append(bc.line, '\r')
+		}
+		bc.cr = ch == '\r'
+		if !bc.cr {
+			bc.line = append(bc.line, ch)
+		}
+	}
+	return len(b), nil
+}
+
+func (bc *bodyCanonicalizer) writeLine() error {
+	line := bc.line
+	bc.line = bc.line[:0]
+	if bc.c == CanonicalizationRelaxed {
+		line = relaxLine(line)
+	}
+	if len(line) == 0 {
+		bc.empty++
+		return nil
+	}
+
+	for ; bc.empty > 0; bc.empty-- {
+		if err := bc.output([]byte("\r\n")); err != nil {
+			return err
+		}
+	}
+	if err := bc.output(line); err != nil {
+		return err
+	}
+	return bc.output([]byte("\r\n"))
+}
+
+func (bc *bodyCanonicalizer) output(b []byte) error {
+	if bc.limit >= 0 && bc.written+int64(len(b)) > bc.limit {
+		b = b[:bc.limit-bc.written]
+	}
+	bc.written += int64(len(b))
+	_, err := bc.w.Write(b)
+	return err
+}
+
+// Close writes the last line. An empty body is a single CRLF in the simple
+// canonicalization.
+func (bc *bodyCanonicalizer) Close() error {
+	if bc.cr {
+		bc.line = append(bc.line, '\r')
+		bc.cr = false
+	}
+	if len(bc.line) > 0 {
+		if err := bc.writeLine(); err != nil {
+			return err
+		}
+	}
+	if bc.written == 0 && bc.c == CanonicalizationSimple {
+		return bc.output([]byte("\r\n"))
+	}
+	return nil
+}
+
+// relaxLine removes the whitespace at the end of a line and reduces the other
+// sequences of whitespace to a single space.
+func relaxLine(line []byte) []byte {
+	relaxed := line[:0]
+	space := false
+	for _, ch := range line {
+		if isWSP(ch) {
+			space = true
+			continue
+		}
+		if space {
+			relaxed = append(relaxed, ' ')
+			space = false
+		}
+		relaxed = append(relaxed, ch)
+	}
+	return relaxed
+}
+
+// parseTagList parses a tag=value list of a signature or a key record.
+func parseTagList(s string) (map[string]string, error) {
+	tags := make(map[string]string)
+	for _, item := range strings.Split(s, ";") {
+		if strings.TrimSpace(item) == "" {
+			continue
+		}
+		i := strings.IndexByte(item, '=')
+		if i < 0 {
+			return nil, fmt.Errorf("malformed tag %q", strings.TrimSpace(item))
+		}
+		k := strings.TrimSpace(item[:i])
+		if _, ok := tags[k]; ok {
+			return nil, fmt.Errorf("duplicate tag %q", k)
+		}
+		tags[k] = strings.TrimSpace(item[i+1:])
+	}
+	return tags, nil
+}
+
+// removeWhitespace removes the folding whitespace of a tag value.
+func removeWhitespace(s string) string {
+	return strings.Map(func(r rune) rune {
+		switch r {
+		case ' ', '\t', '\r', '\n':
+			return -1
+		}
+		return r
+	}, s)
+}
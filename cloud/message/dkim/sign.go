@@ -0,0 +1,362 @@
+package dkim
+
+import (
+	"bufio"
+	"bytes"
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"hash"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/unix-world/smartgoext/cloud/message/textproto"
+)
+
+// now returns the signing time.
+var now = time.Now
+
+// defaultHeaderKeys are the header fields signed by default, if present,
+// recommended by RFC 6376 section 5.4.1.
+var defaultHeaderKeys = []string{
+	"From", "Reply-To", "Subject", "Date", "To", "Cc",
+	"Resent-Date", "Resent-From", "Resent-To", "Resent-Cc",
+	"In-Reply-To", "References",
+	"List-Id", "List-Help", "List-Unsubscribe", "List-Subscribe", "List-Post", "List-Owner", "List-Archive",
+	"Message-Id", "Mime-Version", "Content-Type", "Content-Transfer-Encoding",
+}
+
+// SignOptions are the options of a signature.
+type SignOptions struct {
+	// Domain is the signing domain, it is required.
+	Domain string
+	// Selector of the public key in the domain, it is required.
+	Selector string
+	// Identifier is the agent or user on behalf of which the message is
+	// signed, like an address of Domain or one of its subdomains. It is
+	// optional.
+	Identifier string
+
+	// Signer is the private key, an *rsa.PrivateKey or an
+	// ed25519.PrivateKey. It is required.
+	Signer crypto.Signer
+	// Hash is the hash algorithm. Only crypto.SHA256 is supported, which is
+	// the default.
+	Hash crypto.Hash
+
+	// HeaderCanonicalization and BodyCanonicalization default to
+	// CanonicalizationSimple.
+	HeaderCanonicalization Canonicalization
+	BodyCanonicalization   Canonicalization
+
+	// HeaderKeys are the header fields to sign, From is required. By default
+	// the header fields recommended by RFC 6376 which are present in the
+	// message are signed.
+	HeaderKeys []string
+
+	// BodyLength adds the length of the signed body to the signature, with
+	// the l= tag. Content appended to the body after signing does not break
+	// the signature then.
+	BodyLength bool
+
+	// Expiration is the time the signature expires at. It is optional.
+	Expiration time.Time
+}
+
+func (options *SignOptions) check() error {
+	switch {
+	case options == nil:
+		return errors.New("dkim: no options")
+	case options.Domain == "":
+		return errors.New("dkim: no domain")
+	case options.Selector == "":
+		return errors.New("dkim: no selector")
+	case options.Signer == nil:
+		return errors.New("dkim: no signer")
+	case options.Hash != 0 && options.Hash != crypto.SHA256:
+		return errors.New("dkim: unsupported hash algorithm")
+	}
+	if options.Identifier != "" {
+		i := strings.LastIndexByte(options.Identifier, '@')
+		if i < 0 || !isSubdomain(options.Identifier[i+1:], options.Domain) {
+			return errors.New("dkim: identifier not in the domain")
+		}
+	}
+	if options.HeaderKeys != nil && !hasFrom(options.HeaderKeys) {
+		return errors.New("dkim: the From header field must be signed")
+	}
+	return nil
+}
+
+// isSubdomain reports whether domain is parent or one of its subdomains.
+func isSubdomain(domain, parent string) bool {
+	domain, parent = strings.ToLower(domain), strings.ToLower(parent)
+	return domain == parent || strings.HasSuffix(domain, "."+parent)
+}
+
+// A Signer signs a message written to it. The message must be written in its
+// wire format, header and body, and the Signer closed before the signature is
+// available.
+type Signer struct {
+	options   SignOptions
+	algorithm string
+
+	header  []byte
+	inBody  bool
+	headerC Canonicalization
+	bodyC   Canonicalization
+	bodyH   hash.Hash
+	body    *bodyCanonicalizer
+	closed  bool
+
+	signature string
+}
+
+// NewSigner creates a new Signer.
+func NewSigner(options *SignOptions) (*Signer, error) {
+	if err := options.check(); err != nil {
+		return nil, err
+	}
+
+	s := &Signer{options: *options}
+	switch options.Signer.Public().(type) {
+	case *rsa.PublicKey:
+		s.algorithm = "rsa-sha256"
+	case ed25519.PublicKey:
+		s.algorithm = "ed25519-sha256"
+	default:
+		return nil, errors.New("dkim: unsupported key algorithm")
+	}
+
+	s.headerC, s.bodyC = options.HeaderCanonicalization, options.BodyCanonicalization
+	if s.headerC == "" {
+		s.headerC = CanonicalizationSimple
+	}
+	if s.bodyC == "" {
+		s.bodyC = CanonicalizationSimple
+	}
+	if _, _, err := parseCanonicalization(string(s.headerC) + "/" + string(s.bodyC)); err != nil {
+		return nil, err
+	}
+
+	s.bodyH = sha256.New()
+	s.body = newBodyCanonicalizer(s.bodyH, s.bodyC, -1)
+	return s, nil
+}
+
+// Write writes a part of the message.
+func (s *Signer) Write(b []byte) (int, error) {
+	if s.closed {
+		return 0, errors.New("dkim: signer closed")
+	}
+	if s.inBody {
+		return s.body.Write(b)
+	}
+
+	s.header = append(s.header, b...)
+	end := headerEnd(s.header)
+	if end < 0 {
+		return len(b), nil
+	}
+	s.inBody = true
+	body := s.header[end:]
+	s.header = s.header[:end]
+	if _, err := s.body.Write(body); err != nil {
+		return 0, err
+	}
+	return len(b), nil
+}
+
+// headerEnd returns the end of the header of a message, after the empty line,
+// or -1 if the header is incomplete.
+func headerEnd(b []byte) int {
+	if bytes.HasPrefix(b, []byte("\r\n")) {
+		return 2
+	}
+	if bytes.HasPrefix(b, []byte("\n")) {
+		return 1
+	}
+	crlf := bytes.Index(b, []byte("\n\r\n"))
+	lf := bytes.Index(b, []byte("\n\n"))
+	switch {
+	case crlf >= 0 && (lf < 0 || crlf < lf):
+		return crlf + 3
+	case lf >= 0:
+		return lf + 2
+	}
+	return -1
+}
+
+// Close computes the signature.
+func (s *Signer) Close() error {
+	if s.closed {
+		return errors.New("dkim: signer already closed")
+	}
+	s.closed = true
+	if err := s.body.Close(); err != nil {
+		return err
+	}
+
+	h, err := textproto.ReadHeader(bufio.NewReader(bytes.NewReader(s.header)))
+	if err != nil && err != io.EOF {
+		return err
+	}
+
+	keys := s.options.HeaderKeys
+	if keys == nil {
+		for _, k := range defaultHeaderKeys {
+			if h.Has(k) {
+				keys = append(keys, k)
+			}
+		}
+		if !h.Has("From") {
+			return errors.New("dkim: no From header field")
+		}
+	}
+
+	tags := []string{
+		"v=1",
+		"a=" + s.algorithm,
+		"c=" + string(s.headerC) + "/" + string(s.bodyC),
+		"d=" + s.options.Domain,
+		"s=" + s.options.Selector,
+	}
+	if s.options.Identifier != "" {
+		tags = append(tags, "i="+s.options.Identifier)
+	}
+	tags = append(tags, "t="+strconv.FormatInt(now().Unix(), 10))
+	if !s.options.Expiration.IsZero() {
+		tags = append(tags, "x="+strconv.FormatInt(s.options.Expiration.Unix(), 10))
+	}
+	if s.options.BodyLength {
+		tags = append(tags, "l="+strconv.FormatInt(s.body.written, 10))
+	}
+	tags = append(tags,
+		"h="+strings.Join(keys, ":"),
+		"bh="+base64.StdEncoding.EncodeToString(s.bodyH.Sum(nil)),
+		"b=",
+	)
+	field := formatSignature(tags)
+
+	hh := sha256.New()
+	for _, raw := range selectHeaderFields(h, keys) {
+		hh.Write(canonicalizeHeader(raw, s.headerC))
+	}
+	canonical := canonicalizeHeader([]byte(field+"\r\n"), s.headerC)
+	hh.Write(bytes.TrimSuffix(canonical, []byte("\r\n")))
+
+	var sig []byte
+	switch s.algorithm {
+	case "rsa-sha256":
+		sig, err = s.options.Signer.Sign(rand.Reader, hh.Sum(nil), crypto.SHA256)
+	default:
+		// Ed25519 signs the hash itself, RFC 8463 section 3
+		sig, err = s.options.Signer.Sign(rand.Reader, hh.Sum(nil), crypto.Hash(0))
+	}
+	if err != nil {
+		return err
+	}
+
+	column := len(field) - strings.LastIndex(field, "\n") - 1
+	s.signature = field + foldBase64(base64.StdEncoding.EncodeToString(sig), column) + "\r\n"
+	return nil
+}
+
+// Signature returns the DKIM-Signature header field, including its trailing
+// CRLF, to be written before the header of the message. It is empty before the
+// Signer is closed.
+func (s *Signer) Signature() string {
+	return s.signature
+}
+
+// formatSignature formats the signature header field up to its b= tag, folded
+// between the tags.
+func formatSignature(tags []string) string {
+	var b strings.Builder
+	b.WriteString(headerFieldName + ":")
+	lineLen := b.Len()
+	for i, tag := range tags {
+		if i > 0 {
+			b.WriteString(";")
+			lineLen++
+		}
+		if lineLen+1+len(tag) > 76 {
+			b.WriteString("\r\n")
+			lineLen = 0
+		}
+		b.WriteString(" " + tag)
+		lineLen += 1 + len(tag)
+	}
+	return b.String()
+}
+
+// foldBase64 folds the value of the b= tag, which starts at column of the
+// current line, into lines of up to 76 characters.
+func foldBase64(s string, column int) string {
+	var b strings.Builder
+	for width := 76 - column; len(s) > width; width = 75 {
+		if width > 0 {
+			b.WriteString(s[:width])
+			s = s[width:]
+		}
+		b.WriteString("\r\n ")
+	}
+	b.WriteString(s)
+	return b.String()
+}
+
+// selectHeaderFields returns the raw header fields of keys. A key repeated
+// selects the fields of that name from the bottom of the header up, a key
+// without field left selects nothing.
+func selectHeaderFields(h textproto.Header, keys []string) [][]byte {
+	used := make(map[string]int)
+	var l [][]byte
+	for _, k := range keys {
+		var fields [][]byte
+		fs := h.FieldsByKey(k)
+		for fs.Next() {
+			raw, err := fs.Raw()
+			if err != nil {
+				continue
+			}
+			fields = append(fields, raw)
+		}
+
+		ck := strings.ToLower(k)
+		n := used[ck]
+		used[ck]++
+		if n < len(fields) {
+			l = append(l, fields[len(fields)-1-n])
+		}
+	}
+	return l
+}
+
+// Sign signs the message read from r and writes it to w, preceded by its
+// DKIM-Signature header field.
+func Sign(w io.Writer, r io.Reader, options *SignOptions) error {
+	s, err := NewSigner(options)
+	if err != nil {
+		return err
+	}
+
+	var b bytes.Buffer
+	if _, err := io.Copy(io.MultiWriter(&b, s), r); err != nil {
+		return err
+	}
+	if err := s.Close(); err != nil {
+		return err
+	}
+
+	if _, err := io.WriteString(w, s.Signature()); err != nil {
+		return err
+	}
+	_, err = b.WriteTo(w)
+	return err
+}
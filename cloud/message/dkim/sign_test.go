@@ -0,0 +1,211 @@
+package dkim
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"strings"
+	"testing"
+	"time"
+)
+
+const testMessage = "From: Joe SixPack <joe@example.org>\r\n" +
+	"To: Suzie Q <suzie@example.net>\r\n" +
+	"Subject:  Is dinner   ready? \r\n" +
+	"\tFolded\r\n" +
+	"Date: Fri, 11 Jul 2003 21:00:37 -0700\r\n" +
+	"\r\n" +
+	"Hi.  \r\n" +
+	"\r\n" +
+	"We lost the game.\tAre you hungry yet?\r\n" +
+	"\r\n" +
+	"\r\n"
+
+func signTest(t *testing.T, msg string, options *SignOptions) string {
+	t.Helper()
+	var b bytes.Buffer
+	if err := Sign(&b, strings.NewReader(msg), options); err != nil {
+		t.Fatalf("Sign() = %v", err)
+	}
+	return b.String()
+}
+
+func testRSAKey(t *testing.T) (*rsa.PrivateKey, string) {
+	t.Helper()
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	der, err := x509.MarshalPKIXPublicKey(&key.PublicKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return key, "v=DKIM1; k=rsa; p=" + base64.StdEncoding.EncodeToString(der)
+}
+
+func TestSignVerify(t *testing.T) {
+	rsaKey, rsaRecord := testRSAKey(t)
+	edKey := testEd25519Key()
+	keys := map[string]string{
+		"rsa._domainkey.example.org": rsaRecord,
+		"ed._domainkey.example.org":  testEd25519Record(edKey),
+	}
+
+	for _, c := range []Canonicalization{CanonicalizationSimple, CanonicalizationRelaxed} {
+		for selector, key := range map[string]crypto.Signer{"rsa": rsaKey, "ed": edKey} {
+			options := &SignOptions{
+				Domain:                 "example.org",
+				Selector:               selector,
+				Identifier:             "joe@example.org",
+				Signer:                 key,
+				HeaderCanonicalization: c,
+				BodyCanonicalization:   c,
+			}
+
+			signed := signTest(t, testMessage, options)
+			verifications := verifyTest(t, signed, keys)
+			if len(verifications) != 1 {
+				t.Fatalf("%s/%s: got %d verifications, want 1", selector, c, len(verifications))
+			}
+			v := verifications[0]
+			if v.Err != nil {
+				t.Errorf("%s/%s: verification = %v", selector, c, v.Err)
+			}
+			if v.Domain != "example.org" || v.Identifier != "joe@example.org" {
+				t.Errorf("%s/%s: domain %q and identifier %q", selector, c, v.Domain, v.Identifier)
+			}
+
+			// the relaxed canonicalization tolerates whitespace changes, which
+			// break simple signatures
+			for _, modified := range []string{
+				strings.Replace(signed, "Hi.  \r\n", "Hi. \r\n", 1),
+				strings.Replace(signed, "Subject:  Is dinner   ready? \r\n\tFolded", "Subject: Is dinner ready? Folded", 1),
+			} {
+				err := verifyTest(t, modified, keys)[0].Err
+				if c == CanonicalizationRelaxed && err != nil {
+					t.Errorf("%s/%s: verification of changed whitespace = %v", selector, c, err)
+				}
+				if c == CanonicalizationSimple && !IsPermFail(err) {
+					t.Errorf("%s/%s: verification of changed whitespace = %v, want a permanent failure", selector, c, err)
+				}
+			}
+
+			// empty lines at the end of the body are ignored
+			if err := verifyTest(t, signed+"\r\n", keys)[0].Err; err != nil {
+				t.Errorf("%s/%s: verification with an empty line appended = %v", selector, c, err)
+			}
+		}
+	}
+}
+
+func TestSignBodyLength(t *testing.T) {
+	key := testEd25519Key()
+	keys := map[string]string{"ed._domainkey.example.org": testEd25519Record(key)}
+
+	for _, bodyLength := range []bool{false, true} {
+		options := &SignOptions{
+			Domain:                 "example.org",
+			Selector:               "ed",
+			Signer:                 key,
+			HeaderCanonicalization: CanonicalizationRelaxed,
+			BodyCanonicalization:   CanonicalizationRelaxed,
+			BodyLength:             bodyLength,
+		}
+		signed := signTest(t, testMessage, options)
+
+		v := verifyTest(t, signed, keys)[0]
+		if v.Err != nil {
+			t.Errorf("BodyLength %t: verification = %v", bodyLength, v.Err)
+		}
+		if bodyLength && v.BodyLength != int64(len("Hi.\r\n\r\nWe lost the game. Are you hungry yet?\r\n")) {
+			t.Errorf("BodyLength %t: l=%d", bodyLength, v.BodyLength)
+		}
+		if !bodyLength && v.BodyLength >= 0 {
+			t.Errorf("BodyLength %t: l=%d, want none", bodyLength, v.BodyLength)
+		}
+
+		err := verifyTest(t, signed+"Appended\r\n", keys)[0].Err
+		if bodyLength && err != nil {
+			t.Errorf("BodyLength %t: verification with content appended = %v", bodyLength, err)
+		}
+		if !bodyLength && err != ErrBodyHashMismatch {
+			t.Errorf("BodyLength %t: verification with content appended = %v, want %v", bodyLength, err, ErrBodyHashMismatch)
+		}
+	}
+}
+
+func TestSignLineLength(t *testing.T) {
+	defer func() { now = time.Now }()
+	now = func() time.Time { return time.Unix(1528637909, 0) }
+
+	rsaKey, _ := testRSAKey(t)
+	for _, options := range []*SignOptions{
+		{Domain: "example.org", Selector: "rsa", Signer: rsaKey},
+		{Domain: "example.org", Selector: "ed", Signer: testEd25519Key()},
+		{Domain: "mail.example.org", Selector: "rsa", Identifier: "joe@mail.example.org", Signer: rsaKey, BodyLength: true},
+		{Domain: "example.org", Selector: "rsa", Signer: rsaKey, HeaderKeys: []string{"From", "To", "Subject", "Date", "Subject"}},
+	} {
+		s, err := NewSigner(options)
+		if err != nil {
+			t.Fatalf("NewSigner() = %v", err)
+		}
+		if _, err := s.Write([]byte(testMessage)); err != nil {
+			t.Fatalf("Signer.Write() = %v", err)
+		}
+		if err := s.Close(); err != nil {
+			t.Fatalf("Signer.Close() = %v", err)
+		}
+
+		signature := s.Signature()
+		if !strings.HasPrefix(signature, "DKIM-Signature: v=1;") || !strings.HasSuffix(signature, "\r\n") {
+			t.Fatalf("Signature() = %q", signature)
+		}
+		for _, line := range strings.Split(strings.TrimSuffix(signature, "\r\n"), "\r\n") {
+			if len(line) > 76 {
+				t.Errorf("line of %d characters in signature of %s/%s: %q", len(line), options.Domain, options.Selector, line)
+			}
+		}
+	}
+}
+
+func TestFoldBase64(t *testing.T) {
+	s := strings.Repeat("A", 200)
+	for _, column := range []int{0, 3, 40, 74, 76, 80} {
+		folded := foldBase64(s, column)
+		if got := strings.Replace(folded, "\r\n ", "", -1); got != s {
+			t.Errorf("column %d: unfolded value differs", column)
+		}
+		lines := strings.Split(folded, "\r\n")
+		if first := column + len(lines[0]); first > 76 && len(lines[0]) > 0 {
+			t.Errorf("column %d: first line of %d characters", column, first)
+		}
+		for _, line := range lines[1:] {
+			if len(line) > 76 {
+				t.Errorf("column %d: line of %d characters", column, len(line))
+			}
+		}
+	}
+}
+
+func TestSignOptions(t *testing.T) {
+	key := testEd25519Key()
+	for _, tc := range []struct {
+		name    string
+		options *SignOptions
+	}{
+		{"nil", nil},
+		{"domain", &SignOptions{Selector: "ed", Signer: key}},
+		{"selector", &SignOptions{Domain: "example.org", Signer: key}},
+		{"signer", &SignOptions{Domain: "example.org", Selector: "ed"}},
+		{"identifier", &SignOptions{Domain: "example.org", Selector: "ed", Signer: key, Identifier: "joe@example.net"}},
+		{"From", &SignOptions{Domain: "example.org", Selector: "ed", Signer: key, HeaderKeys: []string{"To"}}},
+		{"canonicalization", &SignOptions{Domain: "example.org", Selector: "ed", Signer: key, BodyCanonicalization: "strict"}},
+	} {
+		if _, err := NewSigner(tc.options); err == nil {
+			t.Errorf("%s: NewSigner() succeeded", tc.name)
+		}
+	}
+}
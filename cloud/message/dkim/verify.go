@@ -0,0 +1,409 @@
+package dkim
+
+import (
+	"bufio"
+	"bytes"
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"hash"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/unix-world/smartgoext/cloud/message/textproto"
+)
+
+// defaultMaxVerifications is the number of signatures verified by default.
+const defaultMaxVerifications = 5
+
+// minRSAKeyBits is the minimum size of an RSA key, RFC 8301 section 3.2.
+const minRSAKeyBits = 1024
+
+// A Verification is the result of the verification of a signature.
+type Verification struct {
+	// Domain is the signing domain.
+	Domain string
+	// Identifier is the agent or user on behalf of which the message is
+	// signed.
+	Identifier string
+	// HeaderKeys are the signed header fields.
+	HeaderKeys []string
+	// Time is the signing time, it is zero if unknown.
+	Time time.Time
+	// Expiration is the time the signature expires at, it is zero if the
+	// signature does not expire.
+	Expiration time.Time
+	// BodyLength is the length of the signed body, it is negative if the whole
+	// body is signed.
+	BodyLength int64
+
+	// Err is nil if the signature is valid. Otherwise IsPermFail or IsTempFail
+	// reports the kind of the failure.
+	Err error
+}
+
+// VerifyOptions are the options of a verification.
+type VerifyOptions struct {
+	// LookupTXT looks the TXT records of a domain up. It defaults to
+	// net.LookupTXT, another function allows to verify signatures offline.
+	LookupTXT func(domain string) ([]string, error)
+	// MaxVerifications is the maximum number of signatures verified, 5 by
+	// default. The signatures beyond are reported as failing.
+	MaxVerifications int
+}
+
+// signature is a parsed DKIM-Signature header field.
+type signature struct {
+	algorithm  string
+	domain     string
+	selector   string
+	identifier string
+	keys       []string
+	headerC    Canonicalization
+	bodyC      Canonicalization
+	bodyLength int64
+	bodyHash   []byte
+	sig        []byte
+	time       time.Time
+	expiration time.Time
+
+	raw   []byte
+	bodyH hash.Hash
+	body  *bodyCanonicalizer
+}
+
+// Verify verifies the signatures of the message read from r, looking the keys
+// up in the DNS.
+func Verify(r io.Reader) ([]*Verification, error) {
+	return VerifyWithOptions(r, nil)
+}
+
+// VerifyWithOptions verifies the signatures of the message read from r. The
+// error is only set if the message cannot be read, the result of each
+// signature is in its Verification.
+func VerifyWithOptions(r io.Reader, options *VerifyOptions) ([]*Verification, error) {
+	lookupTXT := net.LookupTXT
+	maxVerifications := defaultMaxVerifications
+	if options != nil {
+		if options.LookupTXT != nil {
+			lookupTXT = options.LookupTXT
+		}
+		if options.MaxVerifications > 0 {
+			maxVerifications = options.MaxVerifications
+		}
+	}
+
+	br := bufio.NewReader(r)
+	h, err := textproto.ReadHeader(br)
+	if err != nil && err != io.EOF {
+		return nil, err
+	}
+
+	var verifications []*Verification
+	var sigs []*signature
+	var writers []io.Writer
+	fs := h.FieldsByKey(headerFieldName)
+	for fs.Next() {
+		v := &Verification{BodyLength: -1}
+		verifications = append(verifications, v)
+		sigs = append(sigs, nil)
+		if len(verifications) > maxVerifications {
+			v.Err = permFail("too many signatures")
+			continue
+		}
+
+		raw, err := fs.Raw()
+		if err != nil {
+			v.Err = permFail("malformed signature: %v", err)
+			continue
+		}
+		sig, err := parseSignature(raw)
+		if sig != nil {
+			v.Domain = sig.domain
+			v.Identifier = sig.identifier
+			v.HeaderKeys = sig.keys
+			v.Time = sig.time
+			v.Expiration = sig.expiration
+			v.BodyLength = sig.bodyLength
+		}
+		if err != nil {
+			v.Err = err
+			continue
+		}
+
+		sig.bodyH = sha256.New()
+		sig.body = newBodyCanonicalizer(sig.bodyH, sig.bodyC, sig.bodyLength)
+		sigs[len(sigs)-1] = sig
+		writers = append(writers, sig.body)
+	}
+	if len(writers) == 0 {
+		return verifications, nil
+	}
+
+	if _, err := io.Copy(io.MultiWriter(writers...), br); err != nil {
+		return nil, err
+	}
+
+	for i, sig := range sigs {
+		if sig == nil {
+			continue
+		}
+		if err := sig.body.Close(); err != nil {
+			return nil, err
+		}
+		if sig.bodyLength >= 0 && sig.body.written < sig.bodyLength {
+			// RFC 6376 section 6.1.1, l= must not exceed the canonicalized body
+			verifications[i].Err = permFail("body length %d exceeds the body of %d bytes", sig.bodyLength, sig.body.written)
+			continue
+		}
+		verifications[i].Err = sig.verify(h, lookupTXT)
+	}
+	return verifications, nil
+}
+
+func parseSignature(raw []byte) (*signature, error) {
+	colon := bytes.IndexByte(raw, ':')
+	if colon < 0 {
+		return nil, permFail("malformed signature")
+	}
+	tags, err := parseTagList(string(raw[colon+1:]))
+	if err != nil {
+		return nil, permFail("malformed signature: %v", err)
+	}
+
+	sig := &signature{
+		algorithm: strings.ToLower(tags["a"]),
+		domain:    tags["d"],
+		selector:  tags["s"],
+		raw:       raw,
+	}
+	for _, k := range strings.Split(removeWhitespace(tags["h"]), ":") {
+		if k != "" {
+			sig.keys = append(sig.keys, k)
+		}
+	}
+	sig.identifier = removeWhitespace(tags["i"])
+	if sig.identifier == "" {
+		sig.identifier = "@" + sig.domain
+	}
+	sig.bodyLength = -1
+	if l, ok := tags["l"]; ok {
+		if sig.bodyLength, err = strconv.ParseInt(l, 10, 64); err != nil || sig.bodyLength < 0 {
+			return sig, permFail("malformed body length %q", l)
+		}
+	}
+	if t, ok := tags["t"]; ok {
+		sec, err := strconv.ParseInt(t, 10, 64)
+		if err != nil {
+			return sig, permFail("malformed signature timestamp %q", t)
+		}
+		sig.time = time.Unix(sec, 0)
+	}
+	if x, ok := tags["x"]; ok {
+		sec, err := strconv.ParseInt(x, 10, 64)
+		if err != nil {
+			return sig, permFail("malformed signature expiration %q", x)
+		}
+		sig.expiration = time.Unix(sec, 0)
+	}
+
+	switch {
+	case tags["v"] != "1":
+		return sig, permFail("unsupported signature version %q", tags["v"])
+	case sig.algorithm == "":
+		return sig, permFail("missing algorithm")
+	case sig.domain == "":
+		return sig, permFail("missing domain")
+	case sig.selector == "":
+		return sig, permFail("missing selector")
+	case tags["b"] == "" || tags["bh"] == "":
+		return sig, permFail("missing signature or body hash")
+	case !hasFrom(sig.keys):
+		return sig, permFail("the From header field is not signed")
+	}
+	if sig.algorithm != "rsa-sha256" && sig.algorithm != "ed25519-sha256" {
+		return sig, permFail("unsupported algorithm %q", sig.algorithm)
+	}
+	if q, ok := tags["q"]; ok && !strings.Contains(strings.ToLower(q), "dns/txt") {
+		return sig, permFail("unsupported query method %q", q)
+	}
+	i := strings.LastIndexByte(sig.identifier, '@')
+	if i < 0 || !isSubdomain(sig.identifier[i+1:], sig.domain) {
+		return sig, permFail("identifier %q not in domain %q", sig.identifier, sig.domain)
+	}
+	if sig.headerC, sig.bodyC, err = parseCanonicalization(strings.ToLower(tags["c"])); err != nil {
+		return sig, permFail("unknown canonicalization %q", tags["c"])
+	}
+	if sig.bodyHash, err = base64.StdEncoding.DecodeString(removeWhitespace(tags["bh"])); err != nil {
+		return sig, permFail("malformed body hash: %v", err)
+	}
+	if sig.sig, err = base64.StdEncoding.DecodeString(removeWhitespace(tags["b"])); err != nil {
+		return sig, permFail("malformed signature: %v", err)
+	}
+	if !sig.expiration.IsZero() && sig.expiration.Before(now()) {
+		return sig, permFail("signature expired")
+	}
+	return sig, nil
+}
+
+func hasFrom(keys []string) bool {
+	for _, k := range keys {
+		if strings.EqualFold(k, "From") {
+			return true
+		}
+	}
+	return false
+}
+
+func (sig *signature) verify(h textproto.Header, lookupTXT func(string) ([]string, error)) error {
+	if !bytes.Equal(sig.bodyH.Sum(nil), sig.bodyHash) {
+		return ErrBodyHashMismatch
+	}
+
+	key, strict, err := queryKey(sig.selector+"._domainkey."+sig.domain, sig.algorithm, lookupTXT)
+	if err != nil {
+		return err
+	}
+	if strict && !strings.EqualFold(sig.identifier[strings.LastIndexByte(sig.identifier, '@')+1:], sig.domain) {
+		return permFail("identifier %q not in domain %q of a strict key", sig.identifier, sig.domain)
+	}
+
+	hh := sha256.New()
+	for _, raw := range selectHeaderFields(h, sig.keys) {
+		hh.Write(canonicalizeHeader(raw, sig.headerC))
+	}
+	canonical := canonicalizeHeader(removeSignature(sig.raw), sig.headerC)
+	hh.Write(bytes.TrimSuffix(canonical, []byte("\r\n")))
+	hashed := hh.Sum(nil)
+
+	switch key := key.(type) {
+	case *rsa.PublicKey:
+		if err := rsa.VerifyPKCS1v15(key, crypto.SHA256, hashed, sig.sig); err != nil {
+			return permFail("signature mismatch")
+		}
+	case ed25519.PublicKey:
+		if !ed25519.Verify(key, hashed, sig.sig) {
+			return permFail("signature mismatch")
+		}
+	}
+	return nil
+}
+
+// removeSignature empties the value of the b= tag of a raw signature header
+// field, including its whitespace.
+func removeSignature(raw []byte) []byte {
+	raw = bytes.TrimRight(raw, "\r\n")
+	start := bytes.IndexByte(raw, ':') + 1
+	for start <= len(raw) {
+		end := bytes.IndexByte(raw[start:], ';')
+		if end < 0 {
+			end = len(raw)
+		} else {
+			end += start
+		}
+		if eq := bytes.IndexByte(raw[start:end], '='); eq >= 0 {
+			if name := bytes.TrimSpace(raw[start : start+eq]); string(name) == "b" {
+				l := append([]byte(nil), raw[:start+eq+1]...)
+				l = append(l, raw[end:]...)
+				return append(l, '\r', '\n')
+			}
+		}
+		start = end + 1
+	}
+	return append(raw, '\r', '\n')
+}
+
+// queryKey looks the public key of a selector up and reports whether its
+// identifiers must be in the signing domain itself.
+func queryKey(query, algorithm string, lookupTXT func(string) ([]string, error)) (crypto.PublicKey, bool, error) {
+	txts, err := lookupTXT(query)
+	if err != nil {
+		if dnsErr, ok := err.(*net.DNSError); ok && dnsErr.IsNotFound {
+			return nil, false, permFail("no key for %s", query)
+		}
+		return nil, false, tempFail("key lookup of %s: %v", query, err)
+	}
+	if len(txts) == 0 {
+		return nil, false, permFail("no key for %s", query)
+	}
+
+	// several records are ambiguous, use the first valid one
+	var tags map[string]string
+	for _, txt := range txts {
+		if tags, err = parseTagList(txt); err == nil {
+			break
+		}
+	}
+	if err != nil {
+		return nil, false, permFail("malformed key record: %v", err)
+	}
+
+	if v, ok := tags["v"]; ok && v != "DKIM1" {
+		return nil, false, permFail("unsupported key version %q", v)
+	}
+	if hashes, ok := tags["h"]; ok && !containsItem(hashes, "sha256") {
+		return nil, false, permFail("hash algorithm not allowed by key")
+	}
+	if services, ok := tags["s"]; ok && !containsItem(services, "*") && !containsItem(services, "email") {
+		return nil, false, permFail("key not for email")
+	}
+	strict := containsItem(tags["t"], "s")
+
+	p := removeWhitespace(tags["p"])
+	if p == "" {
+		return nil, false, permFail("key revoked")
+	}
+	der, err := base64.StdEncoding.DecodeString(p)
+	if err != nil {
+		return nil, false, permFail("malformed public key: %v", err)
+	}
+
+	keyType := tags["k"]
+	if keyType == "" {
+		keyType = "rsa"
+	}
+	if !strings.HasPrefix(algorithm, keyType+"-") {
+		return nil, false, permFail("key type %q does not match algorithm %q", keyType, algorithm)
+	}
+	switch keyType {
+	case "rsa":
+		pub, err := x509.ParsePKIXPublicKey(der)
+		if err != nil {
+			// some records have a PKCS #1 key
+			if pub, err = x509.ParsePKCS1PublicKey(der); err != nil {
+				return nil, false, permFail("malformed public key: %v", err)
+			}
+		}
+		rsaPub, ok := pub.(*rsa.PublicKey)
+		if !ok {
+			return nil, false, permFail("public key is not an RSA key")
+		}
+		if rsaPub.N.BitLen() < minRSAKeyBits {
+			return nil, false, permFail("RSA key too short")
+		}
+		return rsaPub, strict, nil
+	case "ed25519":
+		if len(der) != ed25519.PublicKeySize {
+			return nil, false, permFail("malformed Ed25519 public key")
+		}
+		return ed25519.PublicKey(der), strict, nil
+	}
+	return nil, false, permFail("unsupported key type %q", keyType)
+}
+
+// containsItem reports whether a colon-separated list of a key record has an
+// item.
+func containsItem(list, item string) bool {
+	for _, s := range strings.Split(list, ":") {
+		if strings.EqualFold(strings.TrimSpace(s), item) {
+			return true
+		}
+	}
+	return false
+}
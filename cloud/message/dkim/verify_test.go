@@ -0,0 +1,271 @@
+package dkim
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// rfc8463Message is the message of RFC 8463 appendix A.3, signed with the
+// Ed25519 and RSA keys of appendix A.2.
+var rfc8463Message = toCRLF(`DKIM-Signature: v=1; a=ed25519-sha256; c=relaxed/relaxed;
+ d=football.example.com; i=@football.example.com;
+ q=dns/txt; s=brisbane; t=1528637909; h=from : to :
+ subject : date : message-id : from : subject : date;
+ bh=2jUSOH9NhtVGCQWNr9BrIAPreKQjO6Sn7XIkfJVOzv8=;
+ b=/gCrinpcQOoIfuHNQIbq4pgh9kyIK3AQUdt9OdqQehSwhEIug4D11Bus
+ Fa3bT3FY5OsU7ZbnKELq+eXdp1Q1Dw==
+DKIM-Signature: v=1; a=rsa-sha256; c=relaxed/relaxed;
+ d=football.example.com; i=@football.example.com;
+ q=dns/txt; s=test; t=1528637909; h=from : to : subject :
+ date : message-id : from : subject : date;
+ bh=2jUSOH9NhtVGCQWNr9BrIAPreKQjO6Sn7XIkfJVOzv8=;
+ b=F45dVWDfMbQDGHJFlXUNB2HKfbCeLRyhDXgFpEL8GwpsRe0IeIixNTe3
+ DhCVlUrSjV4BwcVcOF6+FF3Zo9Rpo1tFOeS9mPYQTnGdaSGsgeefOsk2Jz
+ dA+L10TeYt9BgDfQNZtKdN1WO//KgIqXP7OdEFE4LjFYNcUxZQ4FADY+8=
+From: Joe SixPack <joe@football.example.com>
+To: Suzie Q <suzie@shopping.example.net>
+Subject: Is dinner ready?
+Date: Fri, 11 Jul 2003 21:00:37 -0700 (PDT)
+Message-ID: <20030712040037.46341.5F8J@football.example.com>
+
+Hi.
+
+We lost the game.  Are you hungry yet?
+
+Joe.
+`)
+
+var rfc8463Keys = map[string]string{
+	"brisbane._domainkey.football.example.com": "v=DKIM1; k=ed25519; p=11qYAYKxCrfVS/7TyWQHOg7hcvPapiMlrwIaaPcHURo=",
+	"test._domainkey.football.example.com": "v=DKIM1; k=rsa; p=MIGfMA0GCSqGSIb3DQEBAQUAA4GNADCBiQKBgQDkHlOQoBTzWRiGs5V6NpP3idY6Wk08" +
+		"a5qhdR6wy5bdOKb2jLQiY/J16JYi0Qvx/byYzCNb3W91y3FutACDfzwQ/BC/e/8uBsCR+yz1Lxj+PL6lHvqMKrM3rG4hstT5QjvHO9PzoxZyVYLzBfO2EeC3Ip3G+2kr" +
+		"yOTIKT+l/K4w3QIDAQAB",
+}
+
+func toCRLF(s string) string {
+	return strings.Replace(s, "\n", "\r\n", -1)
+}
+
+// lookupKeys returns a LookupTXT function which looks the keys up in a map.
+func lookupKeys(keys map[string]string) func(string) ([]string, error) {
+	return func(domain string) ([]string, error) {
+		if txt, ok := keys[domain]; ok {
+			return []string{txt}, nil
+		}
+		return nil, &net.DNSError{Err: "no such host", Name: domain, IsNotFound: true}
+	}
+}
+
+func verifyTest(t *testing.T, msg string, keys map[string]string) []*Verification {
+	t.Helper()
+	verifications, err := VerifyWithOptions(strings.NewReader(msg), &VerifyOptions{LookupTXT: lookupKeys(keys)})
+	if err != nil {
+		t.Fatalf("VerifyWithOptions() = %v", err)
+	}
+	return verifications
+}
+
+func TestVerifyRFC8463(t *testing.T) {
+	verifications := verifyTest(t, rfc8463Message, rfc8463Keys)
+	if len(verifications) != 2 {
+		t.Fatalf("got %d verifications, want 2", len(verifications))
+	}
+
+	keys := []string{"from", "to", "subject", "date", "message-id", "from", "subject", "date"}
+	for _, v := range verifications {
+		if v.Err != nil {
+			t.Errorf("verification of %s failed: %v", v.Domain, v.Err)
+		}
+		if v.Domain != "football.example.com" || v.Identifier != "@football.example.com" {
+			t.Errorf("domain %q and identifier %q, want football.example.com and @football.example.com", v.Domain, v.Identifier)
+		}
+		if strings.Join(v.HeaderKeys, ",") != strings.Join(keys, ",") {
+			t.Errorf("HeaderKeys = %v, want %v", v.HeaderKeys, keys)
+		}
+		if !v.Time.Equal(time.Unix(1528637909, 0)) {
+			t.Errorf("Time = %v, want %v", v.Time, time.Unix(1528637909, 0))
+		}
+		if v.BodyLength >= 0 {
+			t.Errorf("BodyLength = %d, want negative", v.BodyLength)
+		}
+	}
+}
+
+func TestVerifyModified(t *testing.T) {
+	body := strings.Replace(rfc8463Message, "We lost the game.", "We won the game.", 1)
+	for _, v := range verifyTest(t, body, rfc8463Keys) {
+		if v.Err != ErrBodyHashMismatch {
+			t.Errorf("verification of a modified body = %v, want %v", v.Err, ErrBodyHashMismatch)
+		}
+	}
+
+	header := strings.Replace(rfc8463Message, "Is dinner ready?", "Is lunch ready?", 1)
+	for _, v := range verifyTest(t, header, rfc8463Keys) {
+		if !IsPermFail(v.Err) || v.Err == ErrBodyHashMismatch {
+			t.Errorf("verification of a modified header = %v, want a signature mismatch", v.Err)
+		}
+	}
+}
+
+func TestVerifyKeyLookup(t *testing.T) {
+	for _, v := range verifyTest(t, rfc8463Message, nil) {
+		if !IsPermFail(v.Err) {
+			t.Errorf("verification without key = %v, want a permanent failure", v.Err)
+		}
+	}
+
+	options := &VerifyOptions{LookupTXT: func(string) ([]string, error) {
+		return nil, errors.New("timeout")
+	}}
+	verifications, err := VerifyWithOptions(strings.NewReader(rfc8463Message), options)
+	if err != nil {
+		t.Fatalf("VerifyWithOptions() = %v", err)
+	}
+	for _, v := range verifications {
+		if !IsTempFail(v.Err) {
+			t.Errorf("verification with a failing lookup = %v, want a temporary failure", v.Err)
+		}
+	}
+
+	revoked := map[string]string{
+		"brisbane._domainkey.football.example.com": "v=DKIM1; k=ed25519; p=",
+		"test._domainkey.football.example.com":     "v=DKIM1; k=rsa; p=",
+	}
+	for _, v := range verifyTest(t, rfc8463Message, revoked) {
+		if !IsPermFail(v.Err) {
+			t.Errorf("verification with a revoked key = %v, want a permanent failure", v.Err)
+		}
+	}
+}
+
+func TestVerifyMalformedSignature(t *testing.T) {
+	for _, tc := range []struct {
+		name, field string
+	}{
+		{"version", "DKIM-Signature: v=2; a=ed25519-sha256; d=example.org; s=ed; h=from; bh=AA==; b=AA=="},
+		{"algorithm", "DKIM-Signature: v=1; a=rsa-sha1; d=example.org; s=ed; h=from; bh=AA==; b=AA=="},
+		{"From", "DKIM-Signature: v=1; a=ed25519-sha256; d=example.org; s=ed; h=to; bh=AA==; b=AA=="},
+		{"identifier", "DKIM-Signature: v=1; a=ed25519-sha256; d=example.org; i=a@example.net; s=ed; h=from; bh=AA==; b=AA=="},
+		{"body length", "DKIM-Signature: v=1; a=ed25519-sha256; d=example.org; l=-1; s=ed; h=from; bh=AA==; b=AA=="},
+	} {
+		msg := tc.field + "\r\nFrom: a@example.org\r\n\r\nHello\r\n"
+		verifications := verifyTest(t, msg, nil)
+		if len(verifications) != 1 || !IsPermFail(verifications[0].Err) {
+			t.Errorf("%s: verification = %v, want a permanent failure", tc.name, verifications[0].Err)
+		}
+	}
+}
+
+func TestVerifyMaxVerifications(t *testing.T) {
+	field := strings.SplitAfterN(rfc8463Message, "DKIM-Signature:", 3)
+	ed := "DKIM-Signature:" + strings.TrimSuffix(field[1], "DKIM-Signature:")
+	msg := ed + ed + rfc8463Message
+
+	options := &VerifyOptions{LookupTXT: lookupKeys(rfc8463Keys), MaxVerifications: 3}
+	verifications, err := VerifyWithOptions(strings.NewReader(msg), options)
+	if err != nil {
+		t.Fatalf("VerifyWithOptions() = %v", err)
+	}
+	if len(verifications) != 4 {
+		t.Fatalf("got %d verifications, want 4", len(verifications))
+	}
+	for i, v := range verifications {
+		if i < 3 && v.Err != nil {
+			t.Errorf("verification %d = %v", i, v.Err)
+		}
+		if i >= 3 && !IsPermFail(v.Err) {
+			t.Errorf("verification %d beyond the maximum = %v, want a permanent failure", i, v.Err)
+		}
+	}
+}
+
+const testBodyLengthHeader = "From: a@example.org\r\n" +
+	"Subject: Hello\r\n"
+
+func TestVerifyBodyLength(t *testing.T) {
+	key := testEd25519Key()
+	keys := map[string]string{"ed._domainkey.example.org": testEd25519Record(key)}
+
+	// the body "Hello\r\n" is signed, its canonicalized form is 7 bytes long
+	for _, tc := range []struct {
+		length int64
+		body   string
+		valid  bool
+	}{
+		{7, "Hello\r\n", true},
+		{7, "Hello\r\nAppended\r\n", true},
+		{5, "Hello, world\r\n", true},
+		{0, "Replaced\r\n", true},
+		{8, "Hello\r\n", false},
+		{100, "Hello\r\nAppended\r\n", false},
+	} {
+		field := signTestMessage(t, key, testBodyLengthHeader, "Hello\r\n", tc.length)
+		msg := field + testBodyLengthHeader + "\r\n" + tc.body
+
+		v := verifyTest(t, msg, keys)[0]
+		if v.BodyLength != tc.length {
+			t.Errorf("l=%d: BodyLength = %d", tc.length, v.BodyLength)
+		}
+		if tc.valid && v.Err != nil {
+			t.Errorf("l=%d, body %q: verification = %v", tc.length, tc.body, v.Err)
+		}
+		if !tc.valid && (!IsPermFail(v.Err) || v.Err == ErrBodyHashMismatch) {
+			t.Errorf("l=%d, body %q: verification = %v, want a body length failure", tc.length, tc.body, v.Err)
+		}
+	}
+}
+
+func testEd25519Key() ed25519.PrivateKey {
+	return ed25519.NewKeyFromSeed(make([]byte, ed25519.SeedSize))
+}
+
+func testEd25519Record(key ed25519.PrivateKey) string {
+	return "v=DKIM1; k=ed25519; p=" + base64.StdEncoding.EncodeToString(key.Public().(ed25519.PublicKey))
+}
+
+// signTestMessage returns a relaxed ed25519-sha256 signature of the header
+// fields and body whose l= tag is length, unlike a Signer which sets it to the
+// length of the body. The body hash covers at most length bytes of the body.
+func signTestMessage(t *testing.T, key ed25519.PrivateKey, header, body string, length int64) string {
+	t.Helper()
+	bodyH := sha256.New()
+	bc := newBodyCanonicalizer(bodyH, CanonicalizationRelaxed, length)
+	if _, err := io.WriteString(bc, body); err != nil {
+		t.Fatal(err)
+	}
+	if err := bc.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	var keys []string
+	hh := sha256.New()
+	for _, raw := range strings.SplitAfter(strings.TrimSuffix(header, "\r\n"), "\r\n") {
+		keys = append(keys, raw[:strings.IndexByte(raw, ':')])
+		hh.Write(canonicalizeHeader([]byte(strings.TrimSuffix(raw, "\r\n")+"\r\n"), CanonicalizationRelaxed))
+	}
+	field := formatSignature([]string{
+		"v=1",
+		"a=ed25519-sha256",
+		"c=relaxed/relaxed",
+		"d=example.org",
+		"s=ed",
+		"l=" + strconv.FormatInt(length, 10),
+		"h=" + strings.Join(keys, ":"),
+		"bh=" + base64.StdEncoding.EncodeToString(bodyH.Sum(nil)),
+		"b=",
+	})
+	canonical := canonicalizeHeader([]byte(field+"\r\n"), CanonicalizationRelaxed)
+	hh.Write(bytes.TrimSuffix(canonical, []byte("\r\n")))
+
+	sig := ed25519.Sign(key, hh.Sum(nil))
+	return field + base64.StdEncoding.EncodeToString(sig) + "\r\n"
+}
@@ -0,0 +1,392 @@
+// Package smime implements S/MIME signing and encryption of message entities,
+// defined in RFC 8551, on top of the pkcs7 package.
+//
+// Sign and Verify handle multipart/signed entities with a detached
+// application/pkcs7-signature part. Encrypt and Decrypt handle
+// application/pkcs7-mime entities with enveloped data, whose content is
+// encrypted with AES-256 in CBC mode, or in GCM mode with EncryptWithOptions.
+//
+// The entities returned by Sign and Encrypt have a Content-Type header field
+// only, the header fields of a mail, like From or Subject, can be added to them
+// before they are written:
+//
+//	signed, err := smime.Sign(e, cert, key, nil)
+//	if err != nil {
+//		log.Fatal(err)
+//	}
+//	signed.Header.Set("From", "invoices@example.org")
+//	err = signed.WriteTo(w)
+package smime
+
+import (
+	"bufio"
+	"bytes"
+	"crypto"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/unix-world/smartgoext/cloud/message"
+	"github.com/unix-world/smartgoext/crypto/pkcs7"
+)
+
+// Media types of S/MIME, the x- variants are still used by some agents.
+const (
+	signatureType  = "application/pkcs7-signature"
+	mimeType       = "application/pkcs7-mime"
+	xSignatureType = "application/x-pkcs7-signature"
+	xMIMEType      = "application/x-pkcs7-mime"
+
+	smimeTypeEnveloped = "enveloped-data"
+)
+
+// micalg is the name of the digest algorithm of the signatures.
+const micalg = "sha-256"
+
+// canonicalize converts the bare LF line endings of an entity to CRLF, the
+// canonical form of MIME which is signed and encrypted.
+func canonicalize(b []byte) []byte {
+	if bytes.Count(b, []byte("\r\n")) == bytes.Count(b, []byte{'\n'}) {
+		return b
+	}
+	l := make([]byte, 0, len(b)+len(b)/32)
+	for i, ch := range b {
+		if ch == '\n' && (i == 0 || b[i-1] != '\r') {
+			l = append(l, '\r')
+		}
+		l = append(l, ch)
+	}
+	return l
+}
+
+// entityBytes returns the canonical form of an entity. A body of 8 bits is
+// encoded first, since it would not survive the transport unchanged.
+func entityBytes(e *message.Entity) ([]byte, error) {
+	mediaType, _, _ := e.Header.ContentType()
+	if !strings.HasPrefix(mediaType, "multipart/") {
+		switch strings.ToLower(e.Header.Get("Content-Transfer-Encoding")) {
+		case "", "7bit", "8bit", "binary":
+			if strings.HasPrefix(mediaType, "text/") || mediaType == "" {
+				e.Header.Set("Content-Transfer-Encoding", "quoted-printable")
+			} else {
+				e.Header.Set("Content-Transfer-Encoding", "base64")
+			}
+		}
+	}
+
+	var b bytes.Buffer
+	if err := e.WriteTo(&b); err != nil {
+		return nil, err
+	}
+	return canonicalize(b.Bytes()), nil
+}
+
+func randomBoundary() (string, error) {
+	var b [30]byte
+	if _, err := io.ReadFull(rand.Reader, b[:]); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b[:]), nil
+}
+
+// writeBase64 writes b in base64 with lines of 76 characters.
+func writeBase64(w *bytes.Buffer, b []byte) {
+	s := base64.StdEncoding.EncodeToString(b)
+	for len(s) > 76 {
+		w.WriteString(s[:76] + "\r\n")
+		s = s[76:]
+	}
+	w.WriteString(s + "\r\n")
+}
+
+// Sign signs an entity with a detached signature and returns the
+// multipart/signed entity holding it. The signer certificate and its parents,
+// which may be nil, are included in the signature. The content of the entity is
+// consumed.
+//
+// A body of 8 bits is encoded with quoted-printable or base64 before signing.
+func Sign(e *message.Entity, cert *x509.Certificate, key crypto.PrivateKey, parents []*x509.Certificate) (*message.Entity, error) {
+	content, err := entityBytes(e)
+	if err != nil {
+		return nil, err
+	}
+
+	sd, err := pkcs7.NewSignedData(content)
+	if err != nil {
+		return nil, err
+	}
+	sd.SetDigestAlgorithm(pkcs7.OIDDigestAlgorithmSHA256)
+	if err := sd.AddSignerChain(cert, key, parents, pkcs7.SignerInfoConfig{}); err != nil {
+		return nil, err
+	}
+	sd.Detach()
+	signature, err := sd.Finish()
+	if err != nil {
+		return nil, err
+	}
+
+	boundary, err := randomBoundary()
+	if err != nil {
+		return nil, err
+	}
+
+	// the signed part is written as is, the entity is not encoded again
+	var body bytes.Buffer
+	body.WriteString("--" + boundary + "\r\n")
+	body.Write(content)
+	body.WriteString("\r\n--" + boundary + "\r\n")
+	body.WriteString("Content-Type: " + signatureType + "; name=smime.p7s\r\n")
+	body.WriteString("Content-Transfer-Encoding: base64\r\n")
+	body.WriteString("Content-Disposition: attachment; filename=smime.p7s\r\n")
+	body.WriteString("\r\n")
+	writeBase64(&body, signature)
+	body.WriteString("--" + boundary + "--\r\n")
+
+	var h message.Header
+	h.Set("MIME-Version", "1.0")
+	h.SetContentType("multipart/signed", map[string]string{
+		"protocol": signatureType,
+		"micalg":   micalg,
+		"boundary": boundary,
+	})
+	return message.New(h, &body)
+}
+
+// Verify verifies the signature of a multipart/signed entity and returns the
+// signed entity and the certificates of the signers. If roots is not nil, the
+// certificates of the signers must chain to one of them. The entity must have
+// been read by message.Read, to keep the signed part unchanged.
+func Verify(e *message.Entity, roots *x509.CertPool) (*message.Entity, []*x509.Certificate, error) {
+	mediaType, params, err := e.Header.ContentType()
+	if err != nil {
+		return nil, nil, err
+	}
+	if mediaType != "multipart/signed" {
+		return nil, nil, fmt.Errorf("smime: entity is not multipart/signed but %s", mediaType)
+	}
+	if protocol := strings.ToLower(params["protocol"]); protocol != signatureType && protocol != xSignatureType {
+		return nil, nil, fmt.Errorf("smime: unsupported signature protocol %q", params["protocol"])
+	}
+	if params["boundary"] == "" {
+		return nil, nil, errors.New("smime: missing multipart boundary")
+	}
+
+	body, err := io.ReadAll(e.Body)
+	if err != nil {
+		return nil, nil, err
+	}
+	parts := splitMultipart(body, params["boundary"])
+	if len(parts) != 2 {
+		return nil, nil, fmt.Errorf("smime: multipart/signed has %d parts instead of 2", len(parts))
+	}
+	content := canonicalize(parts[0])
+
+	sigEntity, err := message.Read(bytes.NewReader(parts[1]))
+	if err != nil {
+		return nil, nil, err
+	}
+	if t, _, _ := sigEntity.Header.ContentType(); t != signatureType && t != xSignatureType {
+		return nil, nil, fmt.Errorf("smime: unsupported signature part %s", t)
+	}
+	signature, err := io.ReadAll(sigEntity.Body)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	p7, err := pkcs7.Parse(signature)
+	if err != nil {
+		return nil, nil, err
+	}
+	p7.Content = content
+	if err := p7.VerifyWithChain(roots); err != nil {
+		return nil, nil, err
+	}
+
+	signed, err := message.Read(bytes.NewReader(content))
+	if err != nil && !message.IsUnknownCharset(err) && !message.IsUnknownEncoding(err) {
+		return nil, nil, err
+	}
+	return signed, signerCertificates(p7), nil
+}
+
+// signerCertificates returns the certificates of the signers of signed data,
+// they are among its certificates.
+func signerCertificates(p7 *pkcs7.PKCS7) []*x509.Certificate {
+	if cert := p7.GetOnlySigner(); cert != nil {
+		return []*x509.Certificate{cert}
+	}
+	return p7.Certificates
+}
+
+// splitMultipart returns the raw parts of a multipart body, without the CRLF
+// preceding each delimiter.
+func splitMultipart(body []byte, boundary string) [][]byte {
+	delimiter := []byte("--" + boundary)
+
+	var parts [][]byte
+	start := -1
+	br := bufio.NewReader(bytes.NewReader(body))
+	offset := 0
+	for {
+		line, err := br.ReadBytes('\n')
+		lineStart := offset
+		offset += len(line)
+
+		trimmed := bytes.TrimRight(line, " \t\r\n")
+		if bytes.HasPrefix(trimmed, delimiter) {
+			rest := trimmed[len(delimiter):]
+			if len(rest) == 0 || bytes.Equal(rest, []byte("--")) {
+				if start >= 0 {
+					end := lineStart
+					// the line ending before a delimiter belongs to it
+					if end > start && body[end-1] == '\n' {
+						end--
+						if end > start && body[end-1] == '\r' {
+							end--
+						}
+					}
+					parts = append(parts, body[start:end])
+				}
+				if len(rest) > 0 {
+					break
+				}
+				start = offset
+			}
+		}
+		if err != nil {
+			break
+		}
+	}
+	return parts
+}
+
+// A ContentEncryption is an algorithm encrypting the content of an entity.
+type ContentEncryption int
+
+const (
+	// AES256CBC is AES-256 in CBC mode, which agents like OpenSSL decrypt.
+	AES256CBC ContentEncryption = iota
+	// AES256GCM is AES-256 in GCM mode, which authenticates the content as
+	// well, but is not decrypted by all agents in enveloped data.
+	AES256GCM
+)
+
+// contentEncryptionAlgorithms are the pkcs7 algorithms of the content
+// encryptions.
+var contentEncryptionAlgorithms = map[ContentEncryption]int{
+	AES256CBC: pkcs7.EncryptionAlgorithmAES256CBC,
+	AES256GCM: pkcs7.EncryptionAlgorithmAES256GCM,
+}
+
+// A KeyEncryption is an algorithm encrypting the content key for RSA
+// recipients. EC recipients always get an ECDH key agreement.
+type KeyEncryption int
+
+const (
+	// RSAOAEP is RSAES-OAEP with SHA-256.
+	RSAOAEP KeyEncryption = iota
+	// RSAPKCS1v15 is RSAES-PKCS1-v1_5, only for agents without OAEP.
+	RSAPKCS1v15
+)
+
+// keyEncryptionAlgorithms are the pkcs7 algorithms of the key encryptions.
+var keyEncryptionAlgorithms = map[KeyEncryption]int{
+	RSAOAEP:     pkcs7.KeyEncryptionAlgorithmRSAOAEPSHA256,
+	RSAPKCS1v15: pkcs7.KeyEncryptionAlgorithmRSAPKCS1v15,
+}
+
+// EncryptOptions are the options of an encryption.
+type EncryptOptions struct {
+	// ContentEncryption is the algorithm encrypting the content, AES256CBC
+	// by default.
+	ContentEncryption ContentEncryption
+	// KeyEncryption is the algorithm encrypting the content key for RSA
+	// recipients, RSAOAEP by default.
+	KeyEncryption KeyEncryption
+}
+
+// Encrypt encrypts an entity for recipients with AES256CBC and RSAOAEP and
+// returns the application/pkcs7-mime entity holding it. The content of the
+// entity is consumed.
+func Encrypt(e *message.Entity, recipients []*x509.Certificate) (*message.Entity, error) {
+	return EncryptWithOptions(e, recipients, nil)
+}
+
+// EncryptWithOptions is like Encrypt, with the algorithms encrypting the content
+// and its key set by options.
+func EncryptWithOptions(e *message.Entity, recipients []*x509.Certificate, options *EncryptOptions) (*message.Entity, error) {
+	algorithm := contentEncryptionAlgorithms[AES256CBC]
+	keyAlgorithm := keyEncryptionAlgorithms[RSAOAEP]
+	if options != nil {
+		var ok bool
+		if algorithm, ok = contentEncryptionAlgorithms[options.ContentEncryption]; !ok {
+			return nil, fmt.Errorf("smime: unsupported content encryption %d", options.ContentEncryption)
+		}
+		if keyAlgorithm, ok = keyEncryptionAlgorithms[options.KeyEncryption]; !ok {
+			return nil, fmt.Errorf("smime: unsupported key encryption %d", options.KeyEncryption)
+		}
+	}
+
+	content, err := entityBytes(e)
+	if err != nil {
+		return nil, err
+	}
+
+	enveloped, err := pkcs7.EncryptWithAlgorithm(content, recipients, algorithm, keyAlgorithm)
+	if err != nil {
+		return nil, err
+	}
+
+	var h message.Header
+	h.Set("MIME-Version", "1.0")
+	h.SetContentType(mimeType, map[string]string{
+		"smime-type": smimeTypeEnveloped,
+		"name":       "smime.p7m",
+	})
+	h.SetContentDisposition("attachment", map[string]string{"filename": "smime.p7m"})
+	h.Set("Content-Transfer-Encoding", "base64")
+
+	// the body of an entity is in its transfer encoding
+	var body bytes.Buffer
+	writeBase64(&body, enveloped)
+	return message.New(h, &body)
+}
+
+// Decrypt decrypts an application/pkcs7-mime entity with the certificate and
+// the private key of a recipient and returns the decrypted entity.
+func Decrypt(e *message.Entity, cert *x509.Certificate, key crypto.PrivateKey) (*message.Entity, error) {
+	mediaType, params, err := e.Header.ContentType()
+	if err != nil {
+		return nil, err
+	}
+	if mediaType != mimeType && mediaType != xMIMEType {
+		return nil, fmt.Errorf("smime: entity is not %s but %s", mimeType, mediaType)
+	}
+	if smimeType := strings.ToLower(params["smime-type"]); smimeType != "" && smimeType != smimeTypeEnveloped {
+		return nil, fmt.Errorf("smime: unsupported smime-type %q", params["smime-type"])
+	}
+
+	enveloped, err := io.ReadAll(e.Body)
+	if err != nil {
+		return nil, err
+	}
+	p7, err := pkcs7.Parse(enveloped)
+	if err != nil {
+		return nil, err
+	}
+	content, err := p7.Decrypt(cert, key)
+	if err != nil {
+		return nil, err
+	}
+
+	decrypted, err := message.Read(bytes.NewReader(content))
+	if err != nil && !message.IsUnknownCharset(err) && !message.IsUnknownEncoding(err) {
+		return nil, err
+	}
+	return decrypted, nil
+}
@@ -0,0 +1,257 @@
+package smime
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"io"
+	"math/big"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/unix-world/smartgoext/cloud/message"
+	"github.com/unix-world/smartgoext/crypto/pkcs7"
+)
+
+const testBody = "Hello,\r\n\r\nthe invoice is attached. Grüße\r\n"
+
+func testEntity(t *testing.T) *message.Entity {
+	t.Helper()
+	var h message.Header
+	h.SetContentType("text/plain", map[string]string{"charset": "utf-8"})
+	e, err := message.New(h, strings.NewReader(testBody))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return e
+}
+
+func testCertificate(t *testing.T, key crypto.Signer) *x509.Certificate {
+	t.Helper()
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "invoices@example.org"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature | x509.KeyUsageKeyEncipherment | x509.KeyUsageKeyAgreement,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageEmailProtection},
+		EmailAddresses: []string{
+			"invoices@example.org",
+		},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, key.Public(), key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return cert
+}
+
+func testKeys(t *testing.T) map[string]crypto.Signer {
+	t.Helper()
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return map[string]crypto.Signer{"RSA": rsaKey, "P-256": ecKey}
+}
+
+// envelopeAlgorithms returns the content encryption algorithm of enveloped data,
+// and the key encryption algorithm of its first recipient if that is an RSA
+// one.
+func envelopeAlgorithms(t *testing.T, enveloped []byte) (content, key asn1.ObjectIdentifier) {
+	t.Helper()
+	var info struct {
+		ContentType asn1.ObjectIdentifier
+		Content     asn1.RawValue `asn1:"explicit,tag:0"`
+	}
+	if _, err := asn1.Unmarshal(enveloped, &info); err != nil {
+		t.Fatalf("malformed content info: %v", err)
+	}
+	if !info.ContentType.Equal(pkcs7.OIDEnvelopedData) {
+		t.Fatalf("content type %v, want enveloped data", info.ContentType)
+	}
+	var data struct {
+		Version              int
+		RecipientInfos       asn1.RawValue
+		EncryptedContentInfo struct {
+			ContentType                asn1.ObjectIdentifier
+			ContentEncryptionAlgorithm pkix.AlgorithmIdentifier
+			EncryptedContent           asn1.RawValue `asn1:"tag:0,optional"`
+		}
+	}
+	if _, err := asn1.Unmarshal(info.Content.Bytes, &data); err != nil {
+		t.Fatalf("malformed enveloped data: %v", err)
+	}
+	var recipient asn1.RawValue
+	if _, err := asn1.Unmarshal(data.RecipientInfos.Bytes, &recipient); err != nil {
+		t.Fatalf("malformed recipient infos: %v", err)
+	}
+	if recipient.Class == asn1.ClassUniversal && recipient.Tag == asn1.TagSequence {
+		var info struct {
+			Version                int
+			IssuerAndSerialNumber  asn1.RawValue
+			KeyEncryptionAlgorithm pkix.AlgorithmIdentifier
+			EncryptedKey           []byte
+		}
+		if _, err := asn1.Unmarshal(recipient.FullBytes, &info); err != nil {
+			t.Fatalf("malformed recipient info: %v", err)
+		}
+		key = info.KeyEncryptionAlgorithm.Algorithm
+	}
+	return data.EncryptedContentInfo.ContentEncryptionAlgorithm.Algorithm, key
+}
+
+func TestEncryptDecrypt(t *testing.T) {
+	for name, key := range testKeys(t) {
+		cert := testCertificate(t, key)
+		for _, tc := range []struct {
+			options *EncryptOptions
+			oid     asn1.ObjectIdentifier
+			keyOID  asn1.ObjectIdentifier
+		}{
+			{nil, pkcs7.OIDEncryptionAlgorithmAES256CBC, pkcs7.OIDEncryptionAlgorithmRSAESOAEP},
+			{&EncryptOptions{ContentEncryption: AES256CBC}, pkcs7.OIDEncryptionAlgorithmAES256CBC, pkcs7.OIDEncryptionAlgorithmRSAESOAEP},
+			{&EncryptOptions{ContentEncryption: AES256GCM}, pkcs7.OIDEncryptionAlgorithmAES256GCM, pkcs7.OIDEncryptionAlgorithmRSAESOAEP},
+			{&EncryptOptions{KeyEncryption: RSAPKCS1v15}, pkcs7.OIDEncryptionAlgorithmAES256CBC, pkcs7.OIDEncryptionAlgorithmRSA},
+		} {
+			encrypted, err := EncryptWithOptions(testEntity(t), []*x509.Certificate{cert}, tc.options)
+			if err != nil {
+				t.Fatalf("%s: EncryptWithOptions() = %v", name, err)
+			}
+			var b bytes.Buffer
+			if err := encrypted.WriteTo(&b); err != nil {
+				t.Fatalf("%s: WriteTo() = %v", name, err)
+			}
+			raw := b.String()
+
+			e, err := message.Read(strings.NewReader(raw))
+			if err != nil {
+				t.Fatalf("%s: Read() = %v", name, err)
+			}
+			if mediaType, params, _ := e.Header.ContentType(); mediaType != mimeType || params["smime-type"] != smimeTypeEnveloped {
+				t.Errorf("%s: Content-Type %s; smime-type=%s", name, mediaType, params["smime-type"])
+			}
+			enveloped, err := io.ReadAll(e.Body)
+			if err != nil {
+				t.Fatalf("%s: reading the body: %v", name, err)
+			}
+			oid, keyOID := envelopeAlgorithms(t, enveloped)
+			if !oid.Equal(tc.oid) {
+				t.Errorf("%s: content encryption algorithm %v, want %v", name, oid, tc.oid)
+			}
+			// EC recipients get a key agreement, whatever the key encryption
+			if _, isRSA := key.(*rsa.PrivateKey); isRSA && !keyOID.Equal(tc.keyOID) {
+				t.Errorf("%s: key encryption algorithm %v, want %v", name, keyOID, tc.keyOID)
+			}
+			if _, isRSA := key.(*rsa.PrivateKey); !isRSA && keyOID != nil {
+				t.Errorf("%s: key transport %v for an EC recipient", name, keyOID)
+			}
+
+			e, err = message.Read(strings.NewReader(raw))
+			if err != nil {
+				t.Fatalf("%s: Read() = %v", name, err)
+			}
+			decrypted, err := Decrypt(e, cert, key)
+			if err != nil {
+				t.Fatalf("%s: Decrypt() = %v", name, err)
+			}
+			body, err := io.ReadAll(decrypted.Body)
+			if err != nil {
+				t.Fatalf("%s: reading the decrypted body: %v", name, err)
+			}
+			if string(body) != testBody {
+				t.Errorf("%s: decrypted body %q, want %q", name, body, testBody)
+			}
+		}
+	}
+}
+
+func TestEncryptDefaults(t *testing.T) {
+	defer func(algorithm int) { pkcs7.ContentEncryptionAlgorithm = algorithm }(pkcs7.ContentEncryptionAlgorithm)
+	pkcs7.ContentEncryptionAlgorithm = pkcs7.EncryptionAlgorithmDESCBC
+
+	key := testKeys(t)["RSA"]
+	cert := testCertificate(t, key)
+	encrypted, err := Encrypt(testEntity(t), []*x509.Certificate{cert})
+	if err != nil {
+		t.Fatalf("Encrypt() = %v", err)
+	}
+	enveloped, err := io.ReadAll(encrypted.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	oid, keyOID := envelopeAlgorithms(t, enveloped)
+	if !oid.Equal(pkcs7.OIDEncryptionAlgorithmAES256CBC) {
+		t.Errorf("content encryption algorithm %v, want AES-256-CBC", oid)
+	}
+	if !keyOID.Equal(pkcs7.OIDEncryptionAlgorithmRSAESOAEP) {
+		t.Errorf("key encryption algorithm %v, want RSAES-OAEP", keyOID)
+	}
+
+	if _, err := EncryptWithOptions(testEntity(t), []*x509.Certificate{cert}, &EncryptOptions{ContentEncryption: -1}); err == nil {
+		t.Error("EncryptWithOptions() with an unknown content encryption succeeded")
+	}
+	if _, err := EncryptWithOptions(testEntity(t), []*x509.Certificate{cert}, &EncryptOptions{KeyEncryption: -1}); err == nil {
+		t.Error("EncryptWithOptions() with an unknown key encryption succeeded")
+	}
+}
+
+func TestSignVerify(t *testing.T) {
+	for name, key := range testKeys(t) {
+		cert := testCertificate(t, key)
+		signed, err := Sign(testEntity(t), cert, key, nil)
+		if err != nil {
+			t.Fatalf("%s: Sign() = %v", name, err)
+		}
+		var b bytes.Buffer
+		if err := signed.WriteTo(&b); err != nil {
+			t.Fatalf("%s: WriteTo() = %v", name, err)
+		}
+		raw := b.String()
+
+		e, err := message.Read(strings.NewReader(raw))
+		if err != nil {
+			t.Fatalf("%s: Read() = %v", name, err)
+		}
+		roots := x509.NewCertPool()
+		roots.AddCert(cert)
+		content, signers, err := Verify(e, roots)
+		if err != nil {
+			t.Fatalf("%s: Verify() = %v", name, err)
+		}
+		if len(signers) != 1 || !signers[0].Equal(cert) {
+			t.Errorf("%s: signers %v, want the certificate", name, signers)
+		}
+		body, err := io.ReadAll(content.Body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(body) != testBody {
+			t.Errorf("%s: signed body %q, want %q", name, body, testBody)
+		}
+
+		tampered := strings.Replace(raw, "invoice", "inv=6Fice", 1)
+		e, err = message.Read(strings.NewReader(tampered))
+		if err != nil {
+			t.Fatalf("%s: Read() = %v", name, err)
+		}
+		if _, _, err := Verify(e, roots); err == nil {
+			t.Errorf("%s: Verify() of a modified entity succeeded", name)
+		}
+	}
+}
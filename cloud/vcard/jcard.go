@@ -0,0 +1,437 @@
+package vcard
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// MIME type of jCard, defined in RFC 7095 section 8.1.
+const JCardMIMEType = "application/vcard+json"
+
+// Value data types, defined in RFC 6350 section 4.
+const (
+	typeText          = "text"
+	typeURI           = "uri"
+	typeDate          = "date"
+	typeTime          = "time"
+	typeDateTime      = "date-time"
+	typeDateAndOrTime = "date-and-or-time"
+	typeTimestamp     = "timestamp"
+	typeBoolean       = "boolean"
+	typeInteger       = "integer"
+	typeFloat         = "float"
+	typeUTCOffset     = "utc-offset"
+	typeLanguageTag   = "language-tag"
+	typeUnknown       = "unknown"
+)
+
+// defaultTypes are the value types of the properties without VALUE parameter
+// which are not text.
+var defaultTypes = map[string]string{
+	FieldSource:             typeURI,
+	FieldPhoto:              typeURI,
+	FieldBirthday:           typeDateAndOrTime,
+	FieldAnniversary:        typeDateAndOrTime,
+	FieldIMPP:               typeURI,
+	FieldLanguage:           typeLanguageTag,
+	FieldGeolocation:        typeURI,
+	FieldLogo:               typeURI,
+	FieldMember:             typeURI,
+	FieldRelated:            typeURI,
+	FieldRevision:           typeTimestamp,
+	FieldSound:              typeURI,
+	FieldUID:                typeURI,
+	FieldURL:                typeURI,
+	FieldKey:                typeURI,
+	FieldFreeOrBusyURL:      typeURI,
+	FieldCalendarAddressURI: typeURI,
+	FieldCalendarURI:        typeURI,
+}
+
+// structuredFields are the properties whose values have components separated
+// by semicolons.
+var structuredFields = map[string]bool{
+	FieldName:         true,
+	FieldAddress:      true,
+	FieldOrganization: true,
+	FieldGender:       true,
+	FieldClientPIDMap: true,
+}
+
+// listFields are the properties whose values are lists separated by commas.
+var listFields = map[string]bool{
+	FieldNickname:   true,
+	FieldCategories: true,
+}
+
+// paramGroup is the parameter holding the group of a property in jCard.
+const paramGroup = "group"
+
+// defaultType returns the value type of property k without VALUE parameter.
+func defaultType(k string) string {
+	if t, ok := defaultTypes[k]; ok {
+		return t
+	}
+	if strings.HasPrefix(k, "X-") {
+		return typeUnknown
+	}
+	return typeText
+}
+
+// valueType returns the value type of a field of property k.
+func valueType(k string, f *Field) string {
+	if t := f.Params.Get(ParamValue); t != "" {
+		return strings.ToLower(t)
+	}
+	return defaultType(k)
+}
+
+// splitComponents splits a structured value at the semicolons which are not
+// escaped.
+func splitComponents(v string) []string {
+	var l []string
+	var b strings.Builder
+	for i := 0; i < len(v); i++ {
+		switch {
+		case v[i] == '\\' && i+1 < len(v) && v[i+1] == ';':
+			b.WriteByte(';')
+			i++
+		case v[i] == ';':
+			l = append(l, b.String())
+			b.Reset()
+		default:
+			b.WriteByte(v[i])
+		}
+	}
+	return append(l, b.String())
+}
+
+// joinComponents is the inverse of splitComponents.
+func joinComponents(l []string) string {
+	for i, s := range l {
+		l[i] = strings.Replace(s, ";", "\\;", -1)
+	}
+	return strings.Join(l, ";")
+}
+
+// extendedDateTime converts a date, time or UTC offset of the basic format of
+// vCard to the extended format of jCard.
+func extendedDateTime(t, v string) string {
+	switch t {
+	case typeDate, typeDateTime, typeDateAndOrTime, typeTimestamp:
+		date, tm, hasTime := v, "", false
+		if i := strings.IndexByte(v, 'T'); i >= 0 {
+			date, tm, hasTime = v[:i], v[i+1:], true
+		}
+		switch {
+		case len(date) == 8 && isDigits(date):
+			date = date[:4] + "-" + date[4:6] + "-" + date[6:]
+		case len(date) == 6 && strings.HasPrefix(date, "--") && isDigits(date[2:]):
+			date = "--" + date[2:4] + "-" + date[4:]
+		}
+		if !hasTime {
+			return date
+		}
+		return date + "T" + extendedTime(tm)
+	case typeTime:
+		return extendedTime(v)
+	case typeUTCOffset:
+		return extendedOffset(v)
+	}
+	return v
+}
+
+func extendedTime(v string) string {
+	local, zone := v, ""
+	if i := strings.IndexAny(v[min(1, len(v)):], "Z+-"); i >= 0 {
+		local, zone = v[:i+1], v[i+1:]
+	}
+	switch {
+	case len(local) == 6 && isDigits(local):
+		local = local[:2] + ":" + local[2:4] + ":" + local[4:]
+	case len(local) == 4 && isDigits(local):
+		local = local[:2] + ":" + local[2:]
+	case len(local) == 5 && local[0] == '-' && isDigits(local[1:]):
+		local = local[:3] + ":" + local[3:]
+	}
+	return local + extendedOffset(zone)
+}
+
+func extendedOffset(v string) string {
+	if len(v) == 5 && (v[0] == '+' || v[0] == '-') && isDigits(v[1:]) {
+		return v[:3] + ":" + v[3:]
+	}
+	return v
+}
+
+// basicDateTime is the inverse of extendedDateTime.
+func basicDateTime(t, v string) string {
+	switch t {
+	case typeDate, typeDateTime, typeDateAndOrTime, typeTimestamp:
+		date, tm := v, ""
+		if i := strings.IndexByte(v, 'T'); i >= 0 {
+			date, tm = v[:i], v[i:]
+		}
+		switch {
+		case len(date) == 10 && date[4] == '-' && date[7] == '-':
+			date = date[:4] + date[5:7] + date[8:]
+		case len(date) == 7 && strings.HasPrefix(date, "--") && date[4] == '-':
+			date = date[:4] + date[5:]
+		}
+		return date + strings.Replace(tm, ":", "", -1)
+	case typeTime, typeUTCOffset:
+		return strings.Replace(v, ":", "", -1)
+	}
+	return v
+}
+
+// A JCardEncoder formats cards as jCard, defined in RFC 7095.
+type JCardEncoder struct {
+	enc *json.Encoder
+}
+
+// NewJCardEncoder creates a new JCardEncoder that writes cards to w, one per
+// line.
+func NewJCardEncoder(w io.Writer) *JCardEncoder {
+	enc := json.NewEncoder(w)
+	enc.SetEscapeHTML(false)
+	return &JCardEncoder{enc}
+}
+
+// Encode formats a card. The card must have a FieldVersion field.
+//
+// Dates and times are written in the extended format and values of the types
+// boolean, integer and float as JSON literals, structured values are split into
+// their components. A card of version 4 decoded by a JCardDecoder is the same
+// card, apart from a VALUE parameter set to the default type of a property.
+func (enc *JCardEncoder) Encode(c Card) error {
+	version := c.Get(FieldVersion)
+	if version == nil {
+		return errors.New("vcard: VERSION field missing")
+	}
+
+	props := []interface{}{jcardProp(FieldVersion, version)}
+	var keys []string
+	for k := range c {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		if strings.EqualFold(k, FieldVersion) {
+			continue
+		}
+		for _, f := range c[k] {
+			props = append(props, jcardProp(k, f))
+		}
+	}
+
+	return enc.enc.Encode([]interface{}{"vcard", props})
+}
+
+func jcardProp(k string, f *Field) []interface{} {
+	t := valueType(k, f)
+
+	params := make(map[string]interface{})
+	for pk, pv := range f.Params {
+		if pk == ParamValue || len(pv) == 0 {
+			continue
+		}
+		if len(pv) == 1 {
+			params[strings.ToLower(pk)] = pv[0]
+		} else {
+			params[strings.ToLower(pk)] = pv
+		}
+	}
+	if f.Group != "" {
+		params[paramGroup] = f.Group
+	}
+
+	prop := []interface{}{strings.ToLower(k), params, t}
+	switch {
+	case structuredFields[k]:
+		if components := splitComponents(f.Value); len(components) > 1 || components[0] != f.Value {
+			prop = append(prop, components)
+		} else {
+			prop = append(prop, components[0])
+		}
+	case listFields[k]:
+		for _, v := range strings.Split(f.Value, ",") {
+			prop = append(prop, v)
+		}
+	default:
+		prop = append(prop, jcardValue(t, f.Value))
+	}
+	return prop
+}
+
+func jcardValue(t, v string) interface{} {
+	switch t {
+	case typeBoolean:
+		switch v {
+		case "TRUE":
+			return true
+		case "FALSE":
+			return false
+		}
+	case typeInteger, typeFloat:
+		// keep values which are no JSON numbers, like +1, as strings
+		if _, err := strconv.ParseFloat(v, 64); err == nil && json.Valid([]byte(v)) {
+			return json.Number(v)
+		}
+	}
+	return extendedDateTime(t, v)
+}
+
+// A JCardDecoder parses cards formatted as jCard, defined in RFC 7095.
+type JCardDecoder struct {
+	dec   *json.Decoder
+	queue []interface{}
+}
+
+// NewJCardDecoder creates a new JCardDecoder reading cards from r. The input is
+// a sequence of jCards and arrays of jCards.
+func NewJCardDecoder(r io.Reader) *JCardDecoder {
+	dec := json.NewDecoder(r)
+	dec.UseNumber()
+	return &JCardDecoder{dec: dec}
+}
+
+// Decode parses a single card.
+func (dec *JCardDecoder) Decode() (Card, error) {
+	if len(dec.queue) == 0 {
+		var v interface{}
+		if err := dec.dec.Decode(&v); err != nil {
+			return nil, err
+		}
+		l, ok := v.([]interface{})
+		if !ok {
+			return nil, errors.New("vcard: jCard is not an array")
+		}
+		if len(l) > 0 {
+			if _, ok := l[0].(string); ok {
+				l = []interface{}{l}
+			}
+		}
+		dec.queue = l
+		if len(l) == 0 {
+			return dec.Decode()
+		}
+	}
+
+	v := dec.queue[0]
+	dec.queue = dec.queue[1:]
+	return parseJCard(v)
+}
+
+func parseJCard(v interface{}) (Card, error) {
+	l, ok := v.([]interface{})
+	if !ok || len(l) != 2 {
+		return nil, errors.New("vcard: malformed jCard")
+	}
+	if name, _ := l[0].(string); !strings.EqualFold(name, "vcard") {
+		return nil, errors.New("vcard: jCard is not a vcard")
+	}
+	props, ok := l[1].([]interface{})
+	if !ok {
+		return nil, errors.New("vcard: malformed jCard properties")
+	}
+
+	card := make(Card)
+	for _, prop := range props {
+		k, f, err := parseJCardProp(prop)
+		if err != nil {
+			return card, err
+		}
+		card[k] = append(card[k], f)
+	}
+	return card, nil
+}
+
+func parseJCardProp(v interface{}) (string, *Field, error) {
+	prop, ok := v.([]interface{})
+	if !ok || len(prop) < 4 {
+		return "", nil, errors.New("vcard: malformed jCard property")
+	}
+	name, ok := prop[0].(string)
+	if !ok {
+		return "", nil, errors.New("vcard: malformed jCard property name")
+	}
+	k := strings.ToUpper(name)
+	params, ok := prop[1].(map[string]interface{})
+	if !ok {
+		return "", nil, fmt.Errorf("vcard: malformed jCard parameters of %s", k)
+	}
+	t, ok := prop[2].(string)
+	if !ok {
+		return "", nil, fmt.Errorf("vcard: malformed jCard value type of %s", k)
+	}
+	t = strings.ToLower(t)
+
+	f := new(Field)
+	for pk, pv := range params {
+		if strings.EqualFold(pk, paramGroup) {
+			f.Group = jcardString(pv)
+			continue
+		}
+		if f.Params == nil {
+			f.Params = make(Params)
+		}
+		pk = strings.ToUpper(pk)
+		if l, ok := pv.([]interface{}); ok {
+			for _, item := range l {
+				f.Params.Add(pk, jcardString(item))
+			}
+		} else {
+			f.Params.Add(pk, jcardString(pv))
+		}
+	}
+	if t != defaultType(k) {
+		if f.Params == nil {
+			f.Params = make(Params)
+		}
+		f.Params.Set(ParamValue, t)
+	}
+
+	values := make([]string, len(prop)-3)
+	for i, value := range prop[3:] {
+		if components, ok := value.([]interface{}); ok {
+			l := make([]string, len(components))
+			for j, c := range components {
+				l[j] = jcardString(c)
+			}
+			values[i] = joinComponents(l)
+		} else {
+			values[i] = basicDateTime(t, jcardString(value))
+		}
+	}
+	f.Value = strings.Join(values, ",")
+	return k, f, nil
+}
+
+// jcardString formats a JSON value as a vCard value, the values of a multi-valued
+// component are separated by commas.
+func jcardString(v interface{}) string {
+	switch v := v.(type) {
+	case string:
+		return v
+	case json.Number:
+		return v.String()
+	case bool:
+		if v {
+			return "TRUE"
+		}
+		return "FALSE"
+	case []interface{}:
+		l := make([]string, len(v))
+		for i, item := range v {
+			l[i] = jcardString(item)
+		}
+		return strings.Join(l, ",")
+	}
+	return ""
+}
@@ -0,0 +1,96 @@
+package vcard
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const testCardCodec = "BEGIN:VCARD\r\n" +
+	"VERSION:4.0\r\n" +
+	"FN:Simon Perreault\r\n" +
+	"N:Perreault;Simon;;;ing. jr,M.Sc.\r\n" +
+	"NICKNAME:Simon,Si\r\n" +
+	"BDAY:--0203\r\n" +
+	"ANNIVERSARY:20090808T1430-0500\r\n" +
+	"GENDER:M\r\n" +
+	"LANG;PREF=1:fr\r\n" +
+	"LANG;PREF=2:en\r\n" +
+	"ORG;TYPE=work:Viagenie;Research\\;Development\r\n" +
+	"ADR;TYPE=work:;Suite D2-630;2875 Laurier;Quebec;QC;G1V 2M2;Canada\r\n" +
+	"TEL;VALUE=uri;TYPE=work,voice;PREF=1:tel:+1-418-656-9254;ext=102\r\n" +
+	"item1.EMAIL;TYPE=work:simon.perreault@viagenie.ca\r\n" +
+	"item1.X-ABLABEL:_$!<Work>!$_\r\n" +
+	"GEO;TYPE=work:geo:46.772673,-71.282945\r\n" +
+	"KEY;TYPE=work:http://www.viagenie.ca/simon.perreault/simon.asc\r\n" +
+	"TZ;VALUE=utc-offset:-0500\r\n" +
+	"CATEGORIES:work,test\r\n" +
+	"NOTE:Line one\\nLine two\\, with a comma\r\n" +
+	"REV:20080424T195243Z\r\n" +
+	"X-SCORE;VALUE=integer:42\r\n" +
+	"END:VCARD\r\n"
+
+func TestJCardRoundTrip(t *testing.T) {
+	card := decodeTestCard(t, testCardCodec)
+
+	var buf bytes.Buffer
+	if err := NewJCardEncoder(&buf).Encode(card); err != nil {
+		t.Fatalf("JCardEncoder.Encode() = %v", err)
+	}
+	got, err := NewJCardDecoder(&buf).Decode()
+	if err != nil {
+		t.Fatalf("JCardDecoder.Decode() = %v", err)
+	}
+
+	if !reflect.DeepEqual(normalizeParams(got), normalizeParams(card)) {
+		t.Errorf("jCard round trip =\n%s\nwant\n%s", formatTestCard(t, got), formatTestCard(t, card))
+	}
+}
+
+func TestJCardEncode(t *testing.T) {
+	card := decodeTestCard(t, "BEGIN:VCARD\r\n"+
+		"VERSION:4.0\r\n"+
+		"FN:Simon Perreault\r\n"+
+		"N:Perreault;Simon;;;\r\n"+
+		"BDAY:--0203\r\n"+
+		"item1.EMAIL;TYPE=work:simon.perreault@viagenie.ca\r\n"+
+		"X-SCORE;VALUE=integer:42\r\n"+
+		"END:VCARD\r\n")
+
+	var buf bytes.Buffer
+	if err := NewJCardEncoder(&buf).Encode(card); err != nil {
+		t.Fatalf("JCardEncoder.Encode() = %v", err)
+	}
+	want := `["vcard",[["version",{},"text","4.0"],` +
+		`["bday",{},"date-and-or-time","--02-03"],` +
+		`["email",{"group":"item1","type":"work"},"text","simon.perreault@viagenie.ca"],` +
+		`["fn",{},"text","Simon Perreault"],` +
+		`["n",{},"text",["Perreault","Simon","","",""]],` +
+		`["x-score",{},"integer",42]]]` + "\n"
+	if got := buf.String(); got != want {
+		t.Errorf("JCardEncoder.Encode() =\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestJCardDecodeArray(t *testing.T) {
+	r := strings.NewReader(`[["vcard",[["version",{},"text","4.0"],["fn",{},"text","A"]]],` +
+		`["vcard",[["version",{},"text","4.0"],["fn",{},"text","B"]]]]`)
+	dec := NewJCardDecoder(r)
+	for _, name := range []string{"A", "B"} {
+		card, err := dec.Decode()
+		if err != nil {
+			t.Fatalf("JCardDecoder.Decode() = %v", err)
+		}
+		if got := card.Value(FieldFormattedName); got != name {
+			t.Errorf("FN = %q, want %q", got, name)
+		}
+	}
+}
+
+func TestJCardEncodeMissingVersion(t *testing.T) {
+	card := Card{FieldFormattedName: {{Value: "A"}}}
+	if err := NewJCardEncoder(new(bytes.Buffer)).Encode(card); err == nil {
+		t.Error("JCardEncoder.Encode() without VERSION succeeded")
+	}
+}
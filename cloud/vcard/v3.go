@@ -0,0 +1,276 @@
+package vcard
+
+import (
+	"strconv"
+	"strings"
+)
+
+// vCard 3 properties and parameters, and the extensions used by vCard 3 address
+// books for vCard 4 properties.
+const (
+	fieldLabel         = "LABEL"
+	fieldSortString    = "SORT-STRING"
+	fieldAppleKind     = "X-ADDRESSBOOKSERVER-KIND"
+	fieldAppleMember   = "X-ADDRESSBOOKSERVER-MEMBER"
+	fieldAnniversaryV3 = "X-ANNIVERSARY"
+	fieldGenderV3      = "X-GENDER"
+
+	paramLabel         = "LABEL"
+	paramEncoding      = "ENCODING"
+	paramAppleOmitYear = "X-APPLE-OMIT-YEAR"
+)
+
+// omitYear replaces the missing year of a vCard 3 date, following Apple.
+const omitYear = "1604"
+
+var sexV3 = map[Sex]string{
+	SexFemale:  "Female",
+	SexMale:    "Male",
+	SexOther:   "Other",
+	SexNone:    "None",
+	SexUnknown: "Unknown",
+}
+
+var sexV4 = map[string]Sex{
+	"female":  SexFemale,
+	"male":    SexMale,
+	"other":   SexOther,
+	"none":    SexNone,
+	"unknown": SexUnknown,
+}
+
+// ToV3 converts a card to vCard version 3.
+//
+// KIND and MEMBER become the X-ADDRESSBOOKSERVER-KIND and -MEMBER extensions of
+// Apple, GENDER and ANNIVERSARY become X-GENDER and X-ANNIVERSARY, where the sex
+// is spelled out and followed by the gender identity as in "Female;woman". The most
+// preferred field of each property gets TYPE=pref, data URIs become inline
+// binary data with ENCODING=b and the LABEL parameter of addresses becomes a
+// LABEL field. Parameters and properties which do not exist in vCard 3 are
+// removed.
+func ToV3(card Card) {
+	version := card.Value(FieldVersion)
+	if strings.HasPrefix(version, "3.") {
+		return
+	}
+
+	card.SetValue(FieldVersion, "3.0")
+	delete(card, FieldClientPIDMap)
+	delete(card, FieldXML)
+
+	if kind := card.Get(FieldKind); kind != nil {
+		card.SetValue(fieldAppleKind, strings.ToLower(kind.Value))
+		delete(card, FieldKind)
+	}
+	renameField(card, FieldMember, fieldAppleMember)
+	renameField(card, FieldAnniversary, fieldAnniversaryV3)
+	if card.Get(FieldGender) != nil {
+		sex, identity := card.Gender()
+		v := sexV3[sex]
+		if v != "" && identity != "" {
+			v += ";" + identity
+		} else if v == "" {
+			v = identity
+		}
+		if v != "" {
+			card.SetValue(fieldGenderV3, v)
+		}
+		delete(card, FieldGender)
+	}
+
+	// fields are added to the card while converting
+	keys := make([]string, 0, len(card))
+	for k := range card {
+		keys = append(keys, k)
+	}
+	for _, k := range keys {
+		fields := card[k]
+		if strings.EqualFold(k, FieldVersion) {
+			continue
+		}
+
+		var preferred *Field
+		for _, f := range fields {
+			if f.Params.Get(ParamPreferred) != "" {
+				preferred = card.Preferred(k)
+				break
+			}
+		}
+		for _, f := range fields {
+			if f.Params == nil {
+				f.Params = make(Params)
+			}
+			delete(f.Params, ParamPreferred)
+			if f == preferred && !f.Params.HasType("pref") {
+				f.Params.Add(ParamType, "pref")
+			}
+
+			switch k {
+			case FieldPhoto, FieldLogo, FieldSound, FieldKey:
+				uriToBinary(f)
+			case FieldBirthday, fieldAnniversaryV3:
+				var omitted bool
+				f.Value, omitted = dateToV3(f.Value)
+				if omitted {
+					f.Params.Set(paramAppleOmitYear, omitYear)
+				}
+			case FieldGeolocation:
+				if strings.HasPrefix(f.Value, "geo:") {
+					f.Value = strings.Replace(strings.TrimPrefix(f.Value, "geo:"), ",", ";", 1)
+				}
+			case FieldName:
+				if sortAs := f.Params.Get(ParamSortAs); sortAs != "" {
+					card.SetValue(fieldSortString, sortAs)
+				}
+			case FieldAddress:
+				if label := f.Params.Get(paramLabel); label != "" {
+					params := make(Params)
+					if types := f.Params[ParamType]; len(types) > 0 {
+						params[ParamType] = append([]string(nil), types...)
+					}
+					card.Add(fieldLabel, &Field{Value: label, Params: params, Group: f.Group})
+				}
+			}
+
+			for _, param := range []string{ParamPID, ParamAltID, ParamMediaType, ParamCalendarScale, ParamSortAs, ParamGeolocation, ParamTimezone, paramLabel} {
+				delete(f.Params, param)
+			}
+		}
+	}
+}
+
+// renameField moves the fields of a property to another property.
+func renameField(card Card, from, to string) {
+	if fields, ok := card[from]; ok {
+		card[to] = append(card[to], fields...)
+		delete(card, from)
+	}
+}
+
+// removeType removes a value of the TYPE parameter.
+func (p Params) removeType(t string) {
+	types := p[ParamType][:0]
+	for _, tt := range p[ParamType] {
+		if !strings.EqualFold(t, tt) {
+			types = append(types, tt)
+		}
+	}
+	if len(types) > 0 {
+		p[ParamType] = types
+	} else {
+		delete(p, ParamType)
+	}
+}
+
+// uriToBinary converts a data URI to inline binary data.
+func uriToBinary(f *Field) {
+	if !strings.HasPrefix(f.Value, "data:") {
+		if f.Value != "" && f.Params.Get(ParamValue) == "" {
+			f.Params.Set(ParamValue, "uri")
+		}
+		return
+	}
+
+	comma := strings.IndexByte(f.Value, ',')
+	if comma < 0 {
+		return
+	}
+	header := f.Value[len("data:"):comma]
+	if !strings.HasSuffix(header, ";base64") {
+		// vCard 3 has inline base64 data only
+		f.Params.Set(ParamValue, "uri")
+		return
+	}
+
+	mediaType := strings.TrimSuffix(header, ";base64")
+	f.Value = f.Value[comma+1:]
+	f.Params.Set(paramEncoding, "b")
+	delete(f.Params, ParamValue)
+	if i := strings.IndexByte(mediaType, '/'); i >= 0 {
+		subtype := mediaType[i+1:]
+		if j := strings.IndexByte(subtype, ';'); j >= 0 {
+			subtype = subtype[:j]
+		}
+		// TYPE may hold pref already
+		f.Params.Add(ParamType, strings.ToUpper(subtype))
+	}
+}
+
+// binaryToURI converts inline binary data to a data URI.
+func binaryToURI(k string, f *Field) {
+	if f.Params == nil {
+		return
+	}
+	if strings.EqualFold(f.Params.Get(ParamValue), "uri") || strings.EqualFold(f.Params.Get(ParamValue), "url") {
+		delete(f.Params, ParamValue)
+		return
+	}
+
+	encoding := strings.ToLower(f.Params.Get(paramEncoding))
+	if encoding != "b" && encoding != "base64" {
+		return
+	}
+
+	mediaType := "application/octet-stream"
+	if types := f.Params.Types(); len(types) > 0 {
+		switch subtype := types[0]; {
+		case strings.Contains(subtype, "/"):
+			mediaType = subtype
+		case k == FieldPhoto || k == FieldLogo:
+			if subtype == "jpg" {
+				subtype = "jpeg"
+			}
+			mediaType = "image/" + subtype
+		case k == FieldSound:
+			mediaType = "audio/" + subtype
+		default:
+			mediaType = "application/" + subtype
+		}
+	}
+
+	f.Value = "data:" + mediaType + ";base64," + f.Value
+	delete(f.Params, paramEncoding)
+	delete(f.Params, ParamType)
+}
+
+// dateToV3 converts a date of the basic format of vCard 4 to the extended format
+// of vCard 3, a missing year is replaced.
+func dateToV3(v string) (string, bool) {
+	date, tail := v, ""
+	if i := strings.IndexByte(v, 'T'); i >= 0 {
+		date, tail = v[:i], v[i:]
+	}
+
+	switch {
+	case len(date) == 8 && isDigits(date):
+		return date[:4] + "-" + date[4:6] + "-" + date[6:] + tail, false
+	case len(date) == 6 && strings.HasPrefix(date, "--") && isDigits(date[2:]):
+		return omitYear + "-" + date[2:4] + "-" + date[4:] + tail, true
+	}
+	return v, false
+}
+
+// dateToV4 converts a date of the extended format of vCard 3 to the basic format
+// of vCard 4, the year omitYear is removed.
+func dateToV4(v, omitYear string) string {
+	date, tail := v, ""
+	if i := strings.IndexByte(v, 'T'); i >= 0 {
+		date, tail = v[:i], v[i:]
+	}
+
+	if len(date) == 10 && date[4] == '-' && date[7] == '-' && isDigits(date[:4]+date[5:7]+date[8:]) {
+		if omitYear != "" && date[:4] == omitYear {
+			date = "--" + date[5:7] + date[8:]
+		} else {
+			date = date[:4] + date[5:7] + date[8:]
+		}
+		tail = strings.Replace(tail, ":", "", -1)
+		return date + tail
+	}
+	return v
+}
+
+func isDigits(s string) bool {
+	_, err := strconv.ParseUint(s, 10, 64)
+	return err == nil
+}
@@ -0,0 +1,161 @@
+package vcard
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const testCardV4 = "BEGIN:VCARD\r\n" +
+	"VERSION:4.0\r\n" +
+	"KIND:individual\r\n" +
+	"FN:Jane Doe\r\n" +
+	"N;SORT-AS=Doe:Doe;Jane;;;\r\n" +
+	"GENDER:F;woman\r\n" +
+	"BDAY:--0412\r\n" +
+	"ANNIVERSARY:20090808\r\n" +
+	"TEL;TYPE=cell;PREF=1:+1-555-555-1234\r\n" +
+	"TEL;TYPE=home:+1-555-555-4321\r\n" +
+	"ADR;TYPE=home;LABEL=\"1 Main St\\nSpringfield\":;;1 Main St;Springfield;;;\r\n" +
+	"GEO:geo:37.386013,-122.082932\r\n" +
+	"PHOTO:data:image/jpeg;base64,MIICajCCAdOgAwIBAgICBEUwDQYJKoZIhvcN\r\n" +
+	"MEMBER:urn:uuid:03a0e51f-d1aa-4385-8a53-e29025acd8af\r\n" +
+	"END:VCARD\r\n"
+
+const testCardV3 = "BEGIN:VCARD\r\n" +
+	"VERSION:3.0\r\n" +
+	"X-ADDRESSBOOKSERVER-KIND:individual\r\n" +
+	"FN:Jane Doe\r\n" +
+	"N:Doe;Jane;;;\r\n" +
+	"SORT-STRING:Doe\r\n" +
+	"X-GENDER:Female;woman\r\n" +
+	"BDAY;X-APPLE-OMIT-YEAR=1604:1604-04-12\r\n" +
+	"X-ANNIVERSARY:2009-08-08\r\n" +
+	"TEL;TYPE=cell,pref:+1-555-555-1234\r\n" +
+	"TEL;TYPE=home:+1-555-555-4321\r\n" +
+	"ADR;TYPE=home:;;1 Main St;Springfield;;;\r\n" +
+	"LABEL;TYPE=home:1 Main St\\nSpringfield\r\n" +
+	"GEO:37.386013;-122.082932\r\n" +
+	"PHOTO;ENCODING=b;TYPE=JPEG:MIICajCCAdOgAwIBAgICBEUwDQYJKoZIhvcN\r\n" +
+	"X-ADDRESSBOOKSERVER-MEMBER:urn:uuid:03a0e51f-d1aa-4385-8a53-e29025acd8af\r\n" +
+	"END:VCARD\r\n"
+
+func decodeTestCard(t *testing.T, s string) Card {
+	t.Helper()
+	card, err := NewDecoder(strings.NewReader(s)).Decode()
+	if err != nil {
+		t.Fatalf("Decode() = %v", err)
+	}
+	return card
+}
+
+// normalizeParams replaces empty parameters by nil, which the conversions and
+// decoders do not keep apart.
+func normalizeParams(card Card) Card {
+	for _, fields := range card {
+		for _, f := range fields {
+			if len(f.Params) == 0 {
+				f.Params = nil
+			}
+		}
+	}
+	return card
+}
+
+func TestToV3(t *testing.T) {
+	card := decodeTestCard(t, testCardV4)
+	ToV3(card)
+
+	want := decodeTestCard(t, testCardV3)
+	if !reflect.DeepEqual(normalizeParams(card), normalizeParams(want)) {
+		t.Errorf("ToV3() =\n%s\nwant\n%s", formatTestCard(t, card), formatTestCard(t, want))
+	}
+}
+
+func TestToV4(t *testing.T) {
+	card := decodeTestCard(t, testCardV3)
+	ToV4(card)
+
+	want := decodeTestCard(t, testCardV4)
+	if !reflect.DeepEqual(normalizeParams(card), normalizeParams(want)) {
+		t.Errorf("ToV4() =\n%s\nwant\n%s", formatTestCard(t, card), formatTestCard(t, want))
+	}
+}
+
+func TestToV3Gender(t *testing.T) {
+	for _, tc := range []struct {
+		v4, v3 string
+	}{
+		{"M", "Male"},
+		{"F;woman", "Female;woman"},
+		{"O;intersex", "Other;intersex"},
+		{";nonbinary", "nonbinary"},
+		{"U", "Unknown"},
+	} {
+		card := Card{FieldVersion: {{Value: "4.0"}}, FieldGender: {{Value: tc.v4}}}
+		ToV3(card)
+		if got := card.Value(fieldGenderV3); got != tc.v3 {
+			t.Errorf("ToV3(GENDER:%s) = X-GENDER:%s, want %s", tc.v4, got, tc.v3)
+		}
+		if card.Get(FieldGender) != nil {
+			t.Errorf("ToV3(GENDER:%s) kept GENDER", tc.v4)
+		}
+
+		ToV4(card)
+		if got := card.Value(FieldGender); got != tc.v4 {
+			t.Errorf("ToV4(X-GENDER:%s) = GENDER:%s, want %s", tc.v3, got, tc.v4)
+		}
+		if card.Get(fieldGenderV3) != nil {
+			t.Errorf("ToV4(X-GENDER:%s) kept X-GENDER", tc.v3)
+		}
+	}
+}
+
+func TestToV3PreferredPhoto(t *testing.T) {
+	const v4 = "BEGIN:VCARD\r\n" +
+		"VERSION:4.0\r\n" +
+		"FN:Jane Doe\r\n" +
+		"PHOTO;PREF=1:data:image/png;base64,iVBORw0KGgo=\r\n" +
+		"PHOTO;PREF=2:data:image/jpeg;base64,/9j/4AAQ\r\n" +
+		"END:VCARD\r\n"
+	const v3 = "BEGIN:VCARD\r\n" +
+		"VERSION:3.0\r\n" +
+		"FN:Jane Doe\r\n" +
+		"PHOTO;ENCODING=b;TYPE=pref,PNG:iVBORw0KGgo=\r\n" +
+		"PHOTO;ENCODING=b;TYPE=JPEG:/9j/4AAQ\r\n" +
+		"END:VCARD\r\n"
+
+	card := decodeTestCard(t, v4)
+	ToV3(card)
+	want := decodeTestCard(t, v3)
+	if !reflect.DeepEqual(normalizeParams(card), normalizeParams(want)) {
+		t.Errorf("ToV3() =\n%s\nwant\n%s", formatTestCard(t, card), formatTestCard(t, want))
+	}
+	if f := card.Preferred(FieldPhoto); f == nil || f.Value != "iVBORw0KGgo=" {
+		t.Errorf("Preferred(PHOTO) = %v, want the PNG photo", f)
+	}
+
+	ToV4(card)
+	if f := card.Preferred(FieldPhoto); f == nil || f.Value != "data:image/png;base64,iVBORw0KGgo=" {
+		t.Errorf("ToV4() Preferred(PHOTO) = %v, want the PNG photo", f)
+	}
+}
+
+func TestToV3KeepsVersion3(t *testing.T) {
+	card := decodeTestCard(t, testCardV3)
+	ToV3(card)
+
+	want := decodeTestCard(t, testCardV3)
+	if !reflect.DeepEqual(card, want) {
+		t.Errorf("ToV3() changed a vCard 3 card:\n%s", formatTestCard(t, card))
+	}
+}
+
+func formatTestCard(t *testing.T, card Card) string {
+	t.Helper()
+	var sb strings.Builder
+	if err := NewEncoder(&sb).Encode(card); err != nil {
+		t.Fatalf("Encode() = %v", err)
+	}
+	return sb.String()
+}
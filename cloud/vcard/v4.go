@@ -6,7 +6,10 @@ import (
 
 // See https://github.com/mangstadt/ez-vcard/wiki/Version-differences
 
-// ToV4 converts a card to vCard version 4.
+// ToV4 converts a card to vCard version 4. The extensions used by vCard 3 address
+// books for KIND, MEMBER, GENDER and ANNIVERSARY are converted back, TYPE=pref
+// becomes PREF=1, inline binary data becomes data URIs and LABEL fields become
+// the LABEL parameter of the address with the same types.
 func ToV4(card Card) {
 	version := card.Value(FieldVersion)
 	if strings.HasPrefix(version, "4.") {
@@ -22,9 +25,95 @@ func ToV4(card Card) {
 
 		for _, f := range fields {
 			if f.Params.HasType("pref") {
-				delete(f.Params, "pref")
-				f.Params.Set("pref", "1")
+				f.Params.removeType("pref")
+				f.Params.Set(ParamPreferred, "1")
 			}
+
+			switch k {
+			case FieldPhoto, FieldLogo, FieldSound, FieldKey:
+				binaryToURI(k, f)
+			case FieldBirthday:
+				f.Value = dateToV4(f.Value, f.Params.Get(paramAppleOmitYear))
+				delete(f.Params, paramAppleOmitYear)
+			case FieldGeolocation:
+				if parts := strings.SplitN(f.Value, ";", 2); len(parts) == 2 {
+					f.Value = "geo:" + parts[0] + "," + parts[1]
+				}
+			}
+		}
+	}
+
+	if kinds := card[fieldAppleKind]; len(kinds) > 0 {
+		card.SetKind(Kind(strings.ToLower(kinds[0].Value)))
+		delete(card, fieldAppleKind)
+	}
+	renameField(card, fieldAppleMember, FieldMember)
+	renameField(card, fieldAnniversaryV3, FieldAnniversary)
+	for _, f := range card[FieldAnniversary] {
+		f.Value = dateToV4(f.Value, "")
+	}
+	if genders := card[fieldGenderV3]; len(genders) > 0 {
+		parts := strings.SplitN(genders[0].Value, ";", 2)
+		sex, ok := sexV4[strings.ToLower(parts[0])]
+		identity := maybeGet(parts, 1)
+		if !ok && identity == "" {
+			// a value which is no sex is the gender identity
+			identity = parts[0]
+		}
+		card.SetGender(sex, identity)
+		delete(card, fieldGenderV3)
+	}
+	if sortString := card.Get(fieldSortString); sortString != nil {
+		if n := card.Get(FieldName); n != nil {
+			if n.Params == nil {
+				n.Params = make(Params)
+			}
+			n.Params.Set(ParamSortAs, sortString.Value)
+		}
+		delete(card, fieldSortString)
+	}
+
+	labelsToAddresses(card)
+}
+
+// labelsToAddresses moves the vCard 3 LABEL fields into the LABEL parameter of
+// the first address with the same types.
+func labelsToAddresses(card Card) {
+	var remaining []*Field
+	for _, label := range card[fieldLabel] {
+		var adr *Field
+		for _, f := range card[FieldAddress] {
+			if f.Params.Get(paramLabel) == "" && sameTypes(f.Params, label.Params) {
+				adr = f
+				break
+			}
+		}
+		if adr == nil {
+			remaining = append(remaining, label)
+			continue
+		}
+		if adr.Params == nil {
+			adr.Params = make(Params)
+		}
+		adr.Params.Set(paramLabel, label.Value)
+	}
+
+	if len(remaining) > 0 {
+		card[fieldLabel] = remaining
+	} else {
+		delete(card, fieldLabel)
+	}
+}
+
+func sameTypes(a, b Params) bool {
+	ta, tb := a.Types(), b.Types()
+	if len(ta) != len(tb) {
+		return false
+	}
+	for _, t := range ta {
+		if !b.HasType(t) {
+			return false
 		}
 	}
+	return true
 }
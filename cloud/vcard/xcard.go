@@ -0,0 +1,326 @@
+package vcard
+
+import (
+	"encoding/xml"
+	"errors"
+	"io"
+	"sort"
+	"strings"
+)
+
+// MIME type and namespace of xCard, defined in RFC 6351.
+const (
+	XCardMIMEType   = "application/vcard+xml"
+	XCardNamespace  = "urn:ietf:params:xml:ns:vcard-4.0"
+	xcardParameters = "parameters"
+	xcardGroup      = "group"
+)
+
+// xcardComponents are the element names of the components of the structured
+// properties. ORG has text components.
+var xcardComponents = map[string][]string{
+	FieldName:         {"surname", "given", "additional", "prefix", "suffix"},
+	FieldAddress:      {"pobox", "ext", "street", "locality", "region", "code", "country"},
+	FieldGender:       {"sex", "identity"},
+	FieldClientPIDMap: {"sourceid", "uri"},
+}
+
+// xcardParamTypes are the value types of the parameters which are not text.
+var xcardParamTypes = map[string]string{
+	ParamLanguage:    typeLanguageTag,
+	ParamPreferred:   typeInteger,
+	ParamGeolocation: typeURI,
+}
+
+// An XCardEncoder formats cards as xCard, defined in RFC 6351.
+type XCardEncoder struct {
+	enc     *xml.Encoder
+	started bool
+}
+
+// NewXCardEncoder creates a new XCardEncoder that writes cards to w. Close must
+// be called after the last card.
+func NewXCardEncoder(w io.Writer) *XCardEncoder {
+	return &XCardEncoder{enc: xml.NewEncoder(w)}
+}
+
+func (enc *XCardEncoder) start() error {
+	if enc.started {
+		return nil
+	}
+	enc.started = true
+	return enc.enc.EncodeToken(xml.StartElement{
+		Name: xml.Name{Local: "vcards"},
+		Attr: []xml.Attr{{Name: xml.Name{Local: "xmlns"}, Value: XCardNamespace}},
+	})
+}
+
+// Encode formats a card. The card must have a FieldVersion field.
+//
+// Values are written in the format of vCard, structured values are split into
+// their components. A card decoded by an XCardDecoder is the same card, apart
+// from a VALUE parameter set to the default type of a property.
+func (enc *XCardEncoder) Encode(c Card) error {
+	if c.Get(FieldVersion) == nil {
+		return errors.New("vcard: VERSION field missing")
+	}
+	if err := enc.start(); err != nil {
+		return err
+	}
+
+	vcard := xml.StartElement{Name: xml.Name{Local: "vcard"}}
+	if err := enc.enc.EncodeToken(vcard); err != nil {
+		return err
+	}
+
+	var keys []string
+	for k := range c {
+		if !strings.EqualFold(k, FieldVersion) {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+	keys = append([]string{FieldVersion}, keys...)
+	for _, k := range keys {
+		for _, f := range c[k] {
+			if err := enc.encodeField(k, f); err != nil {
+				return err
+			}
+		}
+	}
+
+	if err := enc.enc.EncodeToken(vcard.End()); err != nil {
+		return err
+	}
+	return enc.enc.Flush()
+}
+
+// Close writes the end of the xCard document.
+func (enc *XCardEncoder) Close() error {
+	if err := enc.start(); err != nil {
+		return err
+	}
+	if err := enc.enc.EncodeToken(xml.EndElement{Name: xml.Name{Local: "vcards"}}); err != nil {
+		return err
+	}
+	return enc.enc.Flush()
+}
+
+func (enc *XCardEncoder) encodeText(name, v string) error {
+	start := xml.StartElement{Name: xml.Name{Local: name}}
+	if err := enc.enc.EncodeToken(start); err != nil {
+		return err
+	}
+	if err := enc.enc.EncodeToken(xml.CharData(v)); err != nil {
+		return err
+	}
+	return enc.enc.EncodeToken(start.End())
+}
+
+// encodeField writes a field, in a group element if it has a group.
+func (enc *XCardEncoder) encodeField(k string, f *Field) error {
+	if f.Group == "" {
+		return enc.encodeProp(k, f)
+	}
+
+	group := xml.StartElement{
+		Name: xml.Name{Local: xcardGroup},
+		Attr: []xml.Attr{{Name: xml.Name{Local: "name"}, Value: f.Group}},
+	}
+	if err := enc.enc.EncodeToken(group); err != nil {
+		return err
+	}
+	if err := enc.encodeProp(k, f); err != nil {
+		return err
+	}
+	return enc.enc.EncodeToken(group.End())
+}
+
+func (enc *XCardEncoder) encodeProp(k string, f *Field) error {
+	t := valueType(k, f)
+
+	prop := xml.StartElement{Name: xml.Name{Local: strings.ToLower(k)}}
+	if err := enc.enc.EncodeToken(prop); err != nil {
+		return err
+	}
+
+	var paramKeys []string
+	for pk, pv := range f.Params {
+		if pk != ParamValue && len(pv) > 0 {
+			paramKeys = append(paramKeys, pk)
+		}
+	}
+	sort.Strings(paramKeys)
+	if len(paramKeys) > 0 {
+		params := xml.StartElement{Name: xml.Name{Local: xcardParameters}}
+		if err := enc.enc.EncodeToken(params); err != nil {
+			return err
+		}
+		for _, pk := range paramKeys {
+			param := xml.StartElement{Name: xml.Name{Local: strings.ToLower(pk)}}
+			if err := enc.enc.EncodeToken(param); err != nil {
+				return err
+			}
+			pt, ok := xcardParamTypes[pk]
+			if !ok {
+				pt = typeText
+			}
+			for _, pv := range f.Params[pk] {
+				if err := enc.encodeText(pt, pv); err != nil {
+					return err
+				}
+			}
+			if err := enc.enc.EncodeToken(param.End()); err != nil {
+				return err
+			}
+		}
+		if err := enc.enc.EncodeToken(params.End()); err != nil {
+			return err
+		}
+	}
+
+	var names, values []string
+	components, structured := xcardComponents[k]
+	switch {
+	case structured || k == FieldOrganization:
+		values = splitComponents(f.Value)
+		names = make([]string, len(values))
+		for i := range values {
+			names[i] = typeText
+			if structured {
+				names[i] = components[min(i, len(components)-1)]
+			}
+		}
+		if structured && len(values) > len(components) {
+			// more components than defined, keep the value as is
+			names, values = []string{typeText}, []string{f.Value}
+		}
+	case listFields[k]:
+		values = strings.Split(f.Value, ",")
+		names = make([]string, len(values))
+		for i := range values {
+			names[i] = t
+		}
+	default:
+		names, values = []string{t}, []string{f.Value}
+	}
+	for i, v := range values {
+		if err := enc.encodeText(names[i], v); err != nil {
+			return err
+		}
+	}
+
+	return enc.enc.EncodeToken(prop.End())
+}
+
+// xcardNode is an element of an xCard.
+type xcardNode struct {
+	XMLName xml.Name
+	Attrs   []xml.Attr  `xml:",any,attr"`
+	Nodes   []xcardNode `xml:",any"`
+	Text    string      `xml:",chardata"`
+}
+
+// An XCardDecoder parses cards formatted as xCard, defined in RFC 6351.
+type XCardDecoder struct {
+	dec *xml.Decoder
+}
+
+// NewXCardDecoder creates a new XCardDecoder reading cards from r.
+func NewXCardDecoder(r io.Reader) *XCardDecoder {
+	return &XCardDecoder{xml.NewDecoder(r)}
+}
+
+// Decode parses a single card.
+func (dec *XCardDecoder) Decode() (Card, error) {
+	for {
+		tok, err := dec.dec.Token()
+		if err != nil {
+			return nil, err
+		}
+		start, ok := tok.(xml.StartElement)
+		if !ok || start.Name.Local != "vcard" {
+			continue
+		}
+
+		var node xcardNode
+		if err := dec.dec.DecodeElement(&node, &start); err != nil {
+			return nil, err
+		}
+		card := make(Card)
+		for _, child := range node.Nodes {
+			if child.XMLName.Local != xcardGroup {
+				parseXCardProp(card, child, "")
+				continue
+			}
+			var group string
+			for _, attr := range child.Attrs {
+				if attr.Name.Local == "name" {
+					group = attr.Value
+				}
+			}
+			for _, prop := range child.Nodes {
+				parseXCardProp(card, prop, group)
+			}
+		}
+		return card, nil
+	}
+}
+
+func parseXCardProp(card Card, node xcardNode, group string) {
+	k := strings.ToUpper(node.XMLName.Local)
+	f := &Field{Group: group}
+
+	var values []xcardNode
+	for _, child := range node.Nodes {
+		if child.XMLName.Local != xcardParameters {
+			values = append(values, child)
+			continue
+		}
+		for _, param := range child.Nodes {
+			if f.Params == nil {
+				f.Params = make(Params)
+			}
+			pk := strings.ToUpper(param.XMLName.Local)
+			for _, pv := range param.Nodes {
+				f.Params.Add(pk, pv.Text)
+			}
+		}
+	}
+
+	t := defaultType(k)
+	components, structured := xcardComponents[k]
+	switch {
+	case len(values) == 0:
+	case (structured && isComponent(components, values[0].XMLName.Local)) || k == FieldOrganization:
+		l := make([]string, len(values))
+		for i, v := range values {
+			l[i] = v.Text
+		}
+		f.Value = joinComponents(l)
+	default:
+		t = values[0].XMLName.Local
+		l := make([]string, len(values))
+		for i, v := range values {
+			l[i] = v.Text
+		}
+		f.Value = strings.Join(l, ",")
+	}
+	if t != defaultType(k) {
+		if f.Params == nil {
+			f.Params = make(Params)
+		}
+		f.Params.Set(ParamValue, t)
+	}
+
+	card.Add(k, f)
+}
+
+func isComponent(components []string, name string) bool {
+	for _, c := range components {
+		if c == name {
+			return true
+		}
+	}
+	return false
+}
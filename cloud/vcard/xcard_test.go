@@ -0,0 +1,75 @@
+package vcard
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestXCardRoundTrip(t *testing.T) {
+	card := decodeTestCard(t, testCardCodec)
+
+	var buf bytes.Buffer
+	enc := NewXCardEncoder(&buf)
+	if err := enc.Encode(card); err != nil {
+		t.Fatalf("XCardEncoder.Encode() = %v", err)
+	}
+	if err := enc.Close(); err != nil {
+		t.Fatalf("XCardEncoder.Close() = %v", err)
+	}
+	got, err := NewXCardDecoder(&buf).Decode()
+	if err != nil {
+		t.Fatalf("XCardDecoder.Decode() = %v", err)
+	}
+
+	if !reflect.DeepEqual(normalizeParams(got), normalizeParams(card)) {
+		t.Errorf("xCard round trip =\n%s\nwant\n%s", formatTestCard(t, got), formatTestCard(t, card))
+	}
+}
+
+func TestXCardEncode(t *testing.T) {
+	card := decodeTestCard(t, "BEGIN:VCARD\r\n"+
+		"VERSION:4.0\r\n"+
+		"N:Perreault;Simon;;;\r\n"+
+		"GENDER:M\r\n"+
+		"item1.EMAIL;TYPE=work:simon.perreault@viagenie.ca\r\n"+
+		"END:VCARD\r\n")
+
+	var buf bytes.Buffer
+	enc := NewXCardEncoder(&buf)
+	if err := enc.Encode(card); err != nil {
+		t.Fatalf("XCardEncoder.Encode() = %v", err)
+	}
+	if err := enc.Close(); err != nil {
+		t.Fatalf("XCardEncoder.Close() = %v", err)
+	}
+	want := `<vcards xmlns="urn:ietf:params:xml:ns:vcard-4.0"><vcard>` +
+		`<version><text>4.0</text></version>` +
+		`<group name="item1"><email><parameters><type><text>work</text></type></parameters>` +
+		`<text>simon.perreault@viagenie.ca</text></email></group>` +
+		`<gender><sex>M</sex></gender>` +
+		`<n><surname>Perreault</surname><given>Simon</given><additional></additional><prefix></prefix><suffix></suffix></n>` +
+		`</vcard></vcards>`
+	if got := buf.String(); got != want {
+		t.Errorf("XCardEncoder.Encode() =\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestXCardDecodeMultiple(t *testing.T) {
+	r := strings.NewReader(`<?xml version="1.0" encoding="UTF-8"?>` +
+		`<vcards xmlns="urn:ietf:params:xml:ns:vcard-4.0">` +
+		`<vcard><version><text>4.0</text></version><fn><text>A</text></fn></vcard>` +
+		`<vcard><version><text>4.0</text></version><fn><text>B</text></fn></vcard>` +
+		`</vcards>`)
+	dec := NewXCardDecoder(r)
+	for _, name := range []string{"A", "B"} {
+		card, err := dec.Decode()
+		if err != nil {
+			t.Fatalf("XCardDecoder.Decode() = %v", err)
+		}
+		if got := card.Value(FieldFormattedName); got != name {
+			t.Errorf("FN = %q, want %q", got, name)
+		}
+	}
+}
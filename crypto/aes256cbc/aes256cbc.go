@@ -41,6 +41,7 @@ func GenerateRandomIv() ([]byte, error) {
 } //END FUNCTION
 
 
+// EncryptBase64AES256CBC is kept for compatibility ; the output is not authenticated, use EncryptEnvelope for new data.
 func EncryptBase64AES256CBC(key string, text string, iv []byte, salt string) (string, error) { // b64EncData, errEnc
 	//--
 	defer smart.PanicHandler() // for aes ...
@@ -0,0 +1,357 @@
+
+// AES256GCM / Ascon :: Authenticated Envelope
+// (c) 2023-2024 unix-world.org
+// r.20241117.2358
+
+package aes256cbc
+
+import (
+	"errors"
+	"strings"
+	"encoding/binary"
+
+	"crypto/sha256"
+	"crypto/cipher"
+	"crypto/aes"
+
+	smart "github.com/unix-world/smartgo"
+	pbkdf2 "github.com/unix-world/smartgo/crypto/pbkdf2"
+	scrypt "github.com/unix-world/smartgo/crypto/scrypt"
+	argon2 "github.com/unix-world/smartgo/crypto/argon2"
+
+	ascon "github.com/unix-world/smartgoext/crypto/ascon"
+)
+
+// The envelope is self-describing, all the parameters needed to open it travel with it:
+//
+//	version(1) | cipher id(1) | kdf id(1) | kdf params | salt length(1) | salt | nonce | ciphertext + tag
+//
+// Everything before the nonce is the header; the header and the nonce are authenticated as additional data.
+// The text form is EnvelopeSignature followed by the Base64 of the binary envelope ; since the signature
+// contains a `!`, which is not part of the Base64 alphabet, it can not be confused with a legacy CBC payload.
+
+const (
+	EnvelopeSignature string = "aenv.v1!"
+
+	envelopeVersion byte = 1
+
+	EnvCipherAES256GCM  byte = 1
+	EnvCipherAscon128   byte = 2
+	EnvCipherAscon128a  byte = 3
+
+	EnvKdfPbkdf2Sha256  byte = 1 // params: iterations uint32
+	EnvKdfScrypt        byte = 2 // params: logN uint8, r uint32, p uint32
+	EnvKdfArgon2id      byte = 3 // params: time uint32, memory (KiB) uint32, threads uint8
+
+	envelopeLengthSalt int = 16
+
+	// limits enforced before deriving a key, so a forged header can not make us burn CPU or RAM ; they are
+	// four times the defaults, scrypt needs about 128 * r * N bytes, thus at most 128 MiB (the defaults 32 MiB),
+	// Argon2id at most 256 MiB (the defaults 64 MiB)
+	envMaxPbkdf2Iterations uint32 = 10000000
+	envMaxScryptLogN       uint8  = 17
+	envMaxScryptRP         uint64 = 8 // r * p
+	envMaxArgon2Time       uint32 = 64
+	envMaxArgon2Memory     uint32 = 256 * 1024 // KiB, 256 MiB
+	envMaxArgon2Threads    uint8  = 16
+)
+
+
+// EnvelopeParams selects the AEAD cipher and the key derivation (with it's cost) used by EncryptEnvelope.
+// Only the fields of the selected KDF are used.
+type EnvelopeParams struct {
+	Cipher         byte
+	Kdf            byte
+	Iterations     uint32 // PBKDF2-SHA256 iterations
+	ScryptLogN     uint8  // scrypt N = 2^ScryptLogN
+	ScryptR        uint32
+	ScryptP        uint32
+	Argon2Time     uint32
+	Argon2Memory   uint32 // KiB
+	Argon2Threads  uint8
+}
+
+
+// DefaultEnvelopeParams returns AES-256-GCM with an Argon2id key derivation.
+func DefaultEnvelopeParams() EnvelopeParams {
+	//--
+	return EnvelopeParams{
+		Cipher:        EnvCipherAES256GCM,
+		Kdf:           EnvKdfArgon2id,
+		Iterations:    600000,
+		ScryptLogN:    15,
+		ScryptR:       8,
+		ScryptP:       1,
+		Argon2Time:    3,
+		Argon2Memory:  64 * 1024,
+		Argon2Threads: 4,
+	}
+	//--
+} //END FUNCTION
+
+
+// IsEnvelope tells if data is in the envelope text format, as returned by EncryptEnvelope.
+func IsEnvelope(data string) bool {
+	//--
+	return strings.HasPrefix(smart.StrTrimWhitespaces(data), EnvelopeSignature)
+	//--
+} //END FUNCTION
+
+
+// EncryptEnvelope derives a key from the passphrase with the selected KDF and a random salt,
+// encrypts and authenticates the text with the selected AEAD cipher and a random nonce.
+// If params is nil, DefaultEnvelopeParams() are used.
+func EncryptEnvelope(key string, text string, params *EnvelopeParams) (string, error) { // envData, errEnc
+	//--
+	defer smart.PanicHandler() // for aes ...
+	//--
+	if(text == "") {
+		return "", nil
+	} //end if
+	//--
+	if(params == nil) {
+		defParams := DefaultEnvelopeParams()
+		params = &defParams
+	} //end if
+	//--
+	aead, keyLen, errCipher := envelopeCipherKeySize(params.Cipher)
+	if(errCipher != nil) {
+		return "", errCipher
+	} //end if
+	//--
+	salt, errSalt := smart.GenerateRandomBytes(envelopeLengthSalt)
+	if(errSalt != nil) {
+		return "", errSalt
+	} //end if
+	//--
+	header := []byte{envelopeVersion, params.Cipher, params.Kdf}
+	var kdfParams []byte
+	switch(params.Kdf) {
+		case EnvKdfPbkdf2Sha256:
+			kdfParams = binary.BigEndian.AppendUint32(nil, params.Iterations)
+			break
+		case EnvKdfScrypt:
+			kdfParams = append([]byte{params.ScryptLogN}, binary.BigEndian.AppendUint32(nil, params.ScryptR)...)
+			kdfParams = binary.BigEndian.AppendUint32(kdfParams, params.ScryptP)
+			break
+		case EnvKdfArgon2id:
+			kdfParams = binary.BigEndian.AppendUint32(nil, params.Argon2Time)
+			kdfParams = binary.BigEndian.AppendUint32(kdfParams, params.Argon2Memory)
+			kdfParams = append(kdfParams, params.Argon2Threads)
+			break
+		default:
+			return "", smart.NewError("Invalid Envelope KDF")
+	} //end switch
+	header = append(header, kdfParams...)
+	header = append(header, byte(len(salt)))
+	header = append(header, salt...)
+	//--
+	dkey, errKdf := envelopeDeriveKey(key, params.Kdf, kdfParams, salt, keyLen)
+	if(errKdf != nil) {
+		return "", errKdf
+	} //end if
+	//--
+	cph, errNew := aead(dkey)
+	if(errNew != nil) {
+		return "", errNew
+	} //end if
+	//--
+	nonce, errNonce := smart.GenerateRandomBytes(cph.NonceSize())
+	if(errNonce != nil) {
+		return "", errNonce
+	} //end if
+	//--
+	header = append(header, nonce...) // the nonce is authenticated too
+	data := make([]byte, len(header), len(header) + len(text) + cph.Overhead())
+	copy(data, header)
+	data = cph.Seal(data, nonce, []byte(text), header)
+	//--
+	return EnvelopeSignature + smart.Base64Encode(string(data)), nil
+	//--
+} //END FUNCTION
+
+
+// DecryptEnvelope opens data created by EncryptEnvelope.
+// Any modification of the envelope, including of it's header, makes it fail.
+func DecryptEnvelope(key string, envData string) (string, error) { // decStr, decErr
+	//--
+	defer smart.PanicHandler() // base64 decode may panic
+	//--
+	envData = smart.StrTrimWhitespaces(envData)
+	if(envData == "") {
+		return "", nil
+	} //end if
+	if(!strings.HasPrefix(envData, EnvelopeSignature)) {
+		return "", smart.NewError("Invalid Envelope Signature")
+	} //end if
+	//--
+	dataRaw := smart.Base64Decode(strings.TrimPrefix(envData, EnvelopeSignature))
+	envData = "" // free mem
+	if(dataRaw == "") {
+		return "", errors.New("ERR: Base64 Data Decoding Failed")
+	} //end if
+	var data []byte = []byte(dataRaw)
+	dataRaw = "" // free mem
+	//--
+	if((len(data) < 3) || (data[0] != envelopeVersion)) {
+		return "", smart.NewError("Invalid Envelope Version")
+	} //end if
+	aead, keyLen, errCipher := envelopeCipherKeySize(data[1])
+	if(errCipher != nil) {
+		return "", errCipher
+	} //end if
+	//--
+	var lenKdfParams int = 0
+	switch(data[2]) {
+		case EnvKdfPbkdf2Sha256:
+			lenKdfParams = 4
+			break
+		case EnvKdfScrypt:
+			lenKdfParams = 9
+			break
+		case EnvKdfArgon2id:
+			lenKdfParams = 9
+			break
+		default:
+			return "", smart.NewError("Invalid Envelope KDF")
+	} //end switch
+	pos := 3 + lenKdfParams
+	if(len(data) < pos + 1) {
+		return "", smart.NewError("Invalid Envelope Length")
+	} //end if
+	kdfParams := data[3:pos]
+	lenSalt := int(data[pos])
+	pos++
+	if((lenSalt == 0) || (len(data) < pos + lenSalt)) {
+		return "", smart.NewError("Invalid Envelope Salt")
+	} //end if
+	salt := data[pos:pos+lenSalt]
+	pos += lenSalt
+	//--
+	dkey, errKdf := envelopeDeriveKey(key, data[2], kdfParams, salt, keyLen)
+	if(errKdf != nil) {
+		return "", errKdf
+	} //end if
+	cph, errNew := aead(dkey)
+	if(errNew != nil) {
+		return "", errNew
+	} //end if
+	//--
+	if(len(data) < pos + cph.NonceSize() + cph.Overhead()) {
+		return "", smart.NewError("Invalid Envelope Length")
+	} //end if
+	nonce := data[pos:pos+cph.NonceSize()]
+	pos += cph.NonceSize()
+	//--
+	text, errOpen := cph.Open(nil, nonce, data[pos:], data[:pos])
+	if(errOpen != nil) {
+		return "", smart.NewError("Envelope Authentication Failed")
+	} //end if
+	//--
+	return string(text), nil
+	//--
+} //END FUNCTION
+
+
+// Decrypt opens both formats: an envelope (recognized by EnvelopeSignature) is handled by DecryptEnvelope,
+// anything else is taken as a legacy payload of EncryptBase64AES256CBC, that needs the legacy iV and Salt.
+// Legacy payloads are not authenticated, a tampered one can only be detected by a padding error.
+func Decrypt(key string, data string, legacyIv []byte, legacySalt string) (string, error) { // decStr, decErr
+	//--
+	if(IsEnvelope(data)) {
+		return DecryptEnvelope(key, data)
+	} //end if
+	//--
+	return DecryptBase64AES256CBC(key, data, legacyIv, legacySalt)
+	//--
+} //END FUNCTION
+
+
+func envelopeCipherKeySize(cipherId byte) (func([]byte) (cipher.AEAD, error), int, error) {
+	//--
+	switch(cipherId) {
+		case EnvCipherAES256GCM:
+			return func(key []byte) (cipher.AEAD, error) {
+				block, err := aes.NewCipher(key)
+				if(err != nil) {
+					return nil, err
+				} //end if
+				return cipher.NewGCM(block)
+			}, aes256LengthKey, nil
+		case EnvCipherAscon128:
+			return func(key []byte) (cipher.AEAD, error) {
+				return ascon.New(key, ascon.Ascon128)
+			}, ascon.KeySize, nil
+		case EnvCipherAscon128a:
+			return func(key []byte) (cipher.AEAD, error) {
+				return ascon.New(key, ascon.Ascon128a)
+			}, ascon.KeySize, nil
+	} //end switch
+	//--
+	return nil, 0, smart.NewError("Invalid Envelope Cipher")
+	//--
+} //END FUNCTION
+
+
+// envelopeCheckKdfParams validates the KDF parameters against the limits, before any key derivation.
+func envelopeCheckKdfParams(kdfId byte, kdfParams []byte) error {
+	//--
+	switch(kdfId) {
+		case EnvKdfPbkdf2Sha256:
+			iterations := binary.BigEndian.Uint32(kdfParams[0:4])
+			if((iterations < 1) || (iterations > envMaxPbkdf2Iterations)) {
+				return smart.NewError("Invalid Envelope KDF Iterations")
+			} //end if
+			return nil
+		case EnvKdfScrypt:
+			logN := kdfParams[0]
+			r := uint64(binary.BigEndian.Uint32(kdfParams[1:5]))
+			p := uint64(binary.BigEndian.Uint32(kdfParams[5:9]))
+			if((logN < 1) || (logN > envMaxScryptLogN) || (r < 1) || (p < 1) || (r * p > envMaxScryptRP)) {
+				return smart.NewError("Invalid Envelope KDF Parameters")
+			} //end if
+			return nil
+		case EnvKdfArgon2id:
+			time := binary.BigEndian.Uint32(kdfParams[0:4])
+			memory := binary.BigEndian.Uint32(kdfParams[4:8])
+			threads := kdfParams[8]
+			if((time < 1) || (time > envMaxArgon2Time) || (threads < 1) || (threads > envMaxArgon2Threads) || (memory < 8 * uint32(threads)) || (memory > envMaxArgon2Memory)) {
+				return smart.NewError("Invalid Envelope KDF Parameters")
+			} //end if
+			return nil
+	} //end switch
+	//--
+	return smart.NewError("Invalid Envelope KDF")
+	//--
+} //END FUNCTION
+
+
+func envelopeDeriveKey(key string, kdfId byte, kdfParams []byte, salt []byte, keyLen int) ([]byte, error) {
+	//--
+	errParams := envelopeCheckKdfParams(kdfId, kdfParams)
+	if(errParams != nil) {
+		return nil, errParams
+	} //end if
+	//--
+	switch(kdfId) {
+		case EnvKdfPbkdf2Sha256:
+			iterations := binary.BigEndian.Uint32(kdfParams[0:4])
+			return pbkdf2.Key([]byte(key), salt, int(iterations), keyLen, sha256.New), nil
+		case EnvKdfScrypt:
+			logN := kdfParams[0]
+			r := binary.BigEndian.Uint32(kdfParams[1:5])
+			p := binary.BigEndian.Uint32(kdfParams[5:9])
+			return scrypt.Key([]byte(key), salt, 1 << logN, int(r), int(p), keyLen)
+		case EnvKdfArgon2id:
+			time := binary.BigEndian.Uint32(kdfParams[0:4])
+			memory := binary.BigEndian.Uint32(kdfParams[4:8])
+			threads := kdfParams[8]
+			return argon2.IDKey([]byte(key), salt, time, memory, threads, uint32(keyLen)), nil
+	} //end switch
+	//--
+	return nil, smart.NewError("Invalid Envelope KDF")
+	//--
+} //END FUNCTION
+
+
+// #END
@@ -0,0 +1,110 @@
+package aes256cbc
+
+import (
+	"encoding/base64"
+	"encoding/binary"
+	"strings"
+	"testing"
+)
+
+const testPassphrase = "correct horse battery staple"
+
+func TestEnvelopeRoundTrip(t *testing.T) {
+	for _, kdf := range []byte{EnvKdfPbkdf2Sha256, EnvKdfScrypt, EnvKdfArgon2id} {
+		params := DefaultEnvelopeParams()
+		params.Kdf = kdf
+		params.Iterations = 1000
+		env, err := EncryptEnvelope(testPassphrase, "some text", &params)
+		if err != nil {
+			t.Fatalf("kdf %d: %v", kdf, err)
+		}
+		text, err := DecryptEnvelope(testPassphrase, env)
+		if err != nil || text != "some text" {
+			t.Errorf("kdf %d: got %q, %v", kdf, text, err)
+		}
+		if _, err := DecryptEnvelope("wrong", env); err == nil {
+			t.Errorf("kdf %d: opened with a wrong passphrase", kdf)
+		}
+	}
+}
+
+// forge returns an envelope with its KDF params overwritten ; the header is authenticated,
+// so without the limits it would only fail after deriving a key with the forged cost.
+func forge(t *testing.T, kdf byte, kdfParams []byte) string {
+	t.Helper()
+	params := DefaultEnvelopeParams()
+	params.Kdf = kdf
+	params.Iterations = 1000
+	env, err := EncryptEnvelope(testPassphrase, "some text", &params)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := base64.StdEncoding.DecodeString(strings.TrimPrefix(env, EnvelopeSignature))
+	if err != nil {
+		t.Fatal(err)
+	}
+	copy(data[3:], kdfParams)
+	return EnvelopeSignature + base64.StdEncoding.EncodeToString(data)
+}
+
+func scryptParams(logN uint8, r, p uint32) []byte {
+	b := binary.BigEndian.AppendUint32([]byte{logN}, r)
+	return binary.BigEndian.AppendUint32(b, p)
+}
+
+func argon2Params(time, memory uint32, threads uint8) []byte {
+	b := binary.BigEndian.AppendUint32(nil, time)
+	b = binary.BigEndian.AppendUint32(b, memory)
+	return append(b, threads)
+}
+
+func TestEnvelopeKdfLimits(t *testing.T) {
+	for _, tc := range []struct {
+		name   string
+		kdf    byte
+		params []byte
+		err    string
+	}{
+		{"pbkdf2 iterations", EnvKdfPbkdf2Sha256, binary.BigEndian.AppendUint32(nil, envMaxPbkdf2Iterations+1), "Invalid Envelope KDF Iterations"},
+		{"scrypt logN", EnvKdfScrypt, scryptParams(envMaxScryptLogN+1, 8, 1), "Invalid Envelope KDF Parameters"},
+		{"scrypt 1 GiB", EnvKdfScrypt, scryptParams(20, 8, 1), "Invalid Envelope KDF Parameters"},
+		{"scrypt r", EnvKdfScrypt, scryptParams(15, 9, 1), "Invalid Envelope KDF Parameters"},
+		{"scrypt r*p", EnvKdfScrypt, scryptParams(15, 8, 2), "Invalid Envelope KDF Parameters"},
+		{"scrypt p", EnvKdfScrypt, scryptParams(15, 1, 0xFFFFFFFF), "Invalid Envelope KDF Parameters"},
+		{"argon2 time", EnvKdfArgon2id, argon2Params(envMaxArgon2Time+1, 64*1024, 4), "Invalid Envelope KDF Parameters"},
+		{"argon2 memory", EnvKdfArgon2id, argon2Params(3, envMaxArgon2Memory+1, 4), "Invalid Envelope KDF Parameters"},
+		{"argon2 threads", EnvKdfArgon2id, argon2Params(3, 64*1024, envMaxArgon2Threads+1), "Invalid Envelope KDF Parameters"},
+	} {
+		_, err := DecryptEnvelope(testPassphrase, forge(t, tc.kdf, tc.params))
+		if err == nil || err.Error() != tc.err {
+			t.Errorf("%s: got error %v, want %q", tc.name, err, tc.err)
+		}
+	}
+
+	// parameters right at the limits are accepted
+	for _, tc := range []struct {
+		name   string
+		kdf    byte
+		params []byte
+	}{
+		{"scrypt", EnvKdfScrypt, scryptParams(envMaxScryptLogN, 4, 2)},
+		{"argon2", EnvKdfArgon2id, argon2Params(3, envMaxArgon2Memory, envMaxArgon2Threads)},
+	} {
+		if err := envelopeCheckKdfParams(tc.kdf, tc.params); err != nil {
+			t.Errorf("%s: parameters at the limits rejected: %v", tc.name, err)
+		}
+	}
+}
+
+func TestEnvelopeDefaultsWithinLimits(t *testing.T) {
+	params := DefaultEnvelopeParams()
+	if err := envelopeCheckKdfParams(EnvKdfScrypt, scryptParams(params.ScryptLogN, params.ScryptR, params.ScryptP)); err != nil {
+		t.Errorf("scrypt defaults: %v", err)
+	}
+	if err := envelopeCheckKdfParams(EnvKdfArgon2id, argon2Params(params.Argon2Time, params.Argon2Memory, params.Argon2Threads)); err != nil {
+		t.Errorf("argon2 defaults: %v", err)
+	}
+	if err := envelopeCheckKdfParams(EnvKdfPbkdf2Sha256, binary.BigEndian.AppendUint32(nil, params.Iterations)); err != nil {
+		t.Errorf("pbkdf2 defaults: %v", err)
+	}
+}
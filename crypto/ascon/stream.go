@@ -0,0 +1,245 @@
+package ascon
+
+import (
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"io"
+)
+
+// Streaming encryption, following the STREAM construction of Hoang, Reyhanitabar,
+// Rogaway and Vizár ("Online Authenticated-Encryption and its Nonce-Reuse
+// Misuse-Resistance", CRYPTO 2015).
+//
+// The plaintext is cut in chunks of a fixed size, each chunk is sealed on its
+// own with the nonce
+//
+//	prefix (11 bytes) || chunk counter (4 bytes, big endian) || last flag (1 byte)
+//
+// so chunks that are dropped, duplicated or reordered fail to open, and so does
+// a stream that was cut right after a chunk boundary, because the chunk before
+// the cut was not sealed as the last one.
+//
+// The stream starts with a header, version (1 byte) || chunk size (4 bytes, big
+// endian) || nonce prefix (11 bytes), which is authenticated as part of the
+// additional data of every chunk.
+
+const (
+	// DefaultChunkSize is the plaintext size of every chunk but the last one,
+	// when NewWriter is given a chunk size of 0.
+	DefaultChunkSize = 64 * 1024
+	// MaxChunkSize bounds the chunk size a Reader accepts from a stream header.
+	MaxChunkSize = 16 * 1024 * 1024
+	// StreamHeaderSize is the size of the header that starts a stream.
+	StreamHeaderSize = 16
+
+	streamVersion    = 1
+	streamPrefixSize = NonceSize - 5
+	streamMaxChunks  = 1 << 32
+)
+
+var (
+	ErrStreamHeader = errors.New("ascon: invalid stream header")
+	ErrStreamClosed = errors.New("ascon: write to closed stream")
+	ErrStreamTooBig = errors.New("ascon: stream has too many chunks")
+)
+
+type stream struct {
+	aead   *Cipher
+	ad     []byte // header || additional data
+	nonce  [NonceSize]byte
+	chunks uint64
+}
+
+func newStream(a *Cipher, header, additionalData []byte) stream {
+	s := stream{aead: a}
+	s.ad = append(append(s.ad, header...), additionalData...)
+	copy(s.nonce[:], header[5:])
+	return s
+}
+
+// next sets up the nonce for the next chunk.
+func (s *stream) next(last bool) error {
+	if s.chunks >= streamMaxChunks {
+		return ErrStreamTooBig
+	}
+	binary.BigEndian.PutUint32(s.nonce[streamPrefixSize:], uint32(s.chunks))
+	s.nonce[NonceSize-1] = 0
+	if last {
+		s.nonce[NonceSize-1] = 1
+	}
+	s.chunks++
+	return nil
+}
+
+// Writer encrypts and authenticates everything written to it, in chunks.
+// Close must be called to seal the last chunk: a stream that was not
+// closed fails to open.
+type Writer struct {
+	stream
+	w      io.Writer
+	buf    []byte
+	size   int
+	err    error
+	closed bool
+}
+
+// NewWriter writes the stream header to w and returns a Writer that encrypts
+// to w with a, in chunks of chunkSize bytes of plaintext (DefaultChunkSize if
+// chunkSize is 0). The additional data is authenticated with every chunk, the
+// Reader must be given the same. Memory use is bounded by the chunk size, no
+// matter how much gets written.
+func NewWriter(w io.Writer, a *Cipher, chunkSize int, additionalData []byte) (*Writer, error) {
+	if chunkSize == 0 {
+		chunkSize = DefaultChunkSize
+	}
+	if chunkSize < 0 || chunkSize > MaxChunkSize {
+		return nil, ErrStreamHeader
+	}
+	var header [StreamHeaderSize]byte
+	header[0] = streamVersion
+	binary.BigEndian.PutUint32(header[1:5], uint32(chunkSize))
+	if _, err := io.ReadFull(rand.Reader, header[5:]); err != nil {
+		return nil, err
+	}
+	if _, err := w.Write(header[:]); err != nil {
+		return nil, err
+	}
+	return &Writer{
+		stream: newStream(a, header[:], additionalData),
+		w:      w,
+		buf:    make([]byte, 0, chunkSize+TagSize),
+		size:   chunkSize,
+	}, nil
+}
+
+// Write encrypts p. Full chunks are only written out once more data follows
+// them, since the last chunk is sealed differently.
+func (w *Writer) Write(p []byte) (int, error) {
+	if w.closed {
+		return 0, ErrStreamClosed
+	}
+	if w.err != nil {
+		return 0, w.err
+	}
+	n := 0
+	for len(p) > 0 {
+		if len(w.buf) == w.size {
+			if w.err = w.flush(false); w.err != nil {
+				return n, w.err
+			}
+		}
+		c := copy(w.buf[len(w.buf):w.size], p)
+		w.buf = w.buf[:len(w.buf)+c]
+		p = p[c:]
+		n += c
+	}
+	return n, nil
+}
+
+// Close seals and writes the last chunk. It does not close the
+// underlying io.Writer.
+func (w *Writer) Close() error {
+	if w.closed {
+		return w.err
+	}
+	w.closed = true
+	if w.err != nil {
+		return w.err
+	}
+	w.err = w.flush(true)
+	return w.err
+}
+
+func (w *Writer) flush(last bool) error {
+	if err := w.next(last); err != nil {
+		return err
+	}
+	w.buf = w.aead.Seal(w.buf[:0], w.nonce[:], w.buf, w.ad)
+	_, err := w.w.Write(w.buf)
+	w.buf = w.buf[:0]
+	return err
+}
+
+// Reader decrypts and authenticates a stream made by a Writer.
+// Read never returns plaintext that did not authenticate.
+type Reader struct {
+	stream
+	r     io.Reader
+	buf   []byte // ciphertext, one chunk plus one byte to look ahead
+	plain []byte
+	out   []byte // plaintext not read yet
+	size  int
+	err   error
+}
+
+// NewReader reads the stream header from r and returns a Reader that
+// decrypts from r with a. The additional data must be what the
+// Writer was given.
+func NewReader(r io.Reader, a *Cipher, additionalData []byte) (*Reader, error) {
+	var header [StreamHeaderSize]byte
+	if _, err := io.ReadFull(r, header[:]); err != nil {
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			return nil, ErrStreamHeader
+		}
+		return nil, err
+	}
+	size := int(binary.BigEndian.Uint32(header[1:5]))
+	if header[0] != streamVersion || size <= 0 || size > MaxChunkSize {
+		return nil, ErrStreamHeader
+	}
+	return &Reader{
+		stream: newStream(a, header[:], additionalData),
+		r:      r,
+		buf:    make([]byte, 0, size+TagSize+1),
+		plain:  make([]byte, 0, size),
+		size:   size,
+	}, nil
+}
+
+// Read decrypts into p. It returns ErrDecryption if the stream was
+// tampered with, reordered or truncated, and io.EOF only after the
+// last chunk authenticated.
+func (r *Reader) Read(p []byte) (int, error) {
+	for len(r.out) == 0 {
+		if r.err != nil {
+			return 0, r.err
+		}
+		r.err = r.fill()
+	}
+	n := copy(p, r.out)
+	r.out = r.out[n:]
+	return n, nil
+}
+
+func (r *Reader) fill() error {
+	full := r.size + TagSize
+	n, err := io.ReadFull(r.r, r.buf[len(r.buf):full+1])
+	r.buf = r.buf[:len(r.buf)+n]
+	last := false
+	switch err {
+	case nil:
+	case io.EOF, io.ErrUnexpectedEOF:
+		last = true
+	default:
+		return err
+	}
+	chunk := r.buf
+	if !last {
+		chunk = r.buf[:full]
+	}
+	if err := r.next(last); err != nil {
+		return err
+	}
+	out, err := r.aead.Open(r.plain[:0], r.nonce[:], chunk, r.ad)
+	if err != nil {
+		return ErrDecryption
+	}
+	r.out = out
+	if last {
+		r.buf = r.buf[:0]
+		return io.EOF
+	}
+	r.buf = append(r.buf[:0], r.buf[full]) // the look ahead byte starts the next chunk
+	return nil
+}
@@ -0,0 +1,208 @@
+package ascon
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"testing"
+)
+
+const testChunkSize = 64
+
+var testAD = []byte("stream additional data")
+
+func testCipher(t *testing.T) *Cipher {
+	t.Helper()
+	a, err := New(bytes.Repeat([]byte{7}, KeySize), Ascon128a)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return a
+}
+
+func testPlaintext(n int) []byte {
+	p := make([]byte, n)
+	for i := range p {
+		p[i] = byte(i * 31)
+	}
+	return p
+}
+
+func seal(t *testing.T, a *Cipher, plaintext []byte) []byte {
+	t.Helper()
+	var b bytes.Buffer
+	w, err := NewWriter(&b, a, testChunkSize, testAD)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// odd write sizes cross the chunk boundaries
+	for p := plaintext; len(p) > 0; {
+		n := min(len(p), 23)
+		if _, err := w.Write(p[:n]); err != nil {
+			t.Fatal(err)
+		}
+		p = p[n:]
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return b.Bytes()
+}
+
+func open(a *Cipher, stream []byte, ad []byte) ([]byte, error) {
+	r, err := NewReader(bytes.NewReader(stream), a, ad)
+	if err != nil {
+		return nil, err
+	}
+	return io.ReadAll(r)
+}
+
+// chunks splits a sealed stream into its header and chunks.
+func chunks(stream []byte) (header []byte, l [][]byte) {
+	header, stream = stream[:StreamHeaderSize], stream[StreamHeaderSize:]
+	for len(stream) > testChunkSize+TagSize {
+		l = append(l, stream[:testChunkSize+TagSize])
+		stream = stream[testChunkSize+TagSize:]
+	}
+	return header, append(l, stream)
+}
+
+func join(header []byte, l [][]byte) []byte {
+	stream := append([]byte(nil), header...)
+	for _, c := range l {
+		stream = append(stream, c...)
+	}
+	return stream
+}
+
+func TestStreamRoundTrip(t *testing.T) {
+	a := testCipher(t)
+	for _, n := range []int{0, 1, testChunkSize - 1, testChunkSize, testChunkSize + 1, 3 * testChunkSize, 1000} {
+		plaintext := testPlaintext(n)
+		stream := seal(t, a, plaintext)
+		got, err := open(a, stream, testAD)
+		if err != nil {
+			t.Errorf("%d bytes: %v", n, err)
+			continue
+		}
+		if !bytes.Equal(got, plaintext) {
+			t.Errorf("%d bytes: plaintext differs", n)
+		}
+	}
+}
+
+func TestStreamWrongAdditionalData(t *testing.T) {
+	a := testCipher(t)
+	stream := seal(t, a, testPlaintext(100))
+	if _, err := open(a, stream, []byte("other")); err != ErrDecryption {
+		t.Errorf("got %v, want %v", err, ErrDecryption)
+	}
+}
+
+func TestStreamTruncated(t *testing.T) {
+	a := testCipher(t)
+	stream := seal(t, a, testPlaintext(3*testChunkSize+10))
+	header, l := chunks(stream)
+	for _, tc := range []struct {
+		name   string
+		stream []byte
+		err    error
+	}{
+		{"empty", nil, ErrStreamHeader},
+		{"partial header", stream[:StreamHeaderSize-1], ErrStreamHeader},
+		{"header only", header, ErrDecryption},
+		{"after a chunk", join(header, l[:1]), ErrDecryption},
+		{"after two chunks", join(header, l[:2]), ErrDecryption},
+		{"within a chunk", stream[:StreamHeaderSize+testChunkSize/2], ErrDecryption},
+		{"last chunk dropped", join(header, l[:len(l)-1]), ErrDecryption},
+		{"tag cut", stream[:len(stream)-1], ErrDecryption},
+	} {
+		if _, err := open(a, tc.stream, testAD); err != tc.err {
+			t.Errorf("%s: got %v, want %v", tc.name, err, tc.err)
+		}
+	}
+}
+
+func TestStreamReordered(t *testing.T) {
+	a := testCipher(t)
+	header, l := chunks(seal(t, a, testPlaintext(3*testChunkSize+10)))
+	for _, tc := range []struct {
+		name string
+		l    [][]byte
+	}{
+		{"swapped", [][]byte{l[1], l[0], l[2], l[3]}},
+		{"duplicated", [][]byte{l[0], l[0], l[1], l[2], l[3]}},
+		{"dropped", [][]byte{l[0], l[2], l[3]}},
+		{"last moved", [][]byte{l[0], l[1], l[3], l[2]}},
+	} {
+		if _, err := open(a, join(header, tc.l), testAD); err != ErrDecryption {
+			t.Errorf("%s: got %v, want %v", tc.name, err, ErrDecryption)
+		}
+	}
+
+	// chunks of another stream with the same key do not open either
+	otherHeader, other := chunks(seal(t, a, testPlaintext(3*testChunkSize+10)))
+	if _, err := open(a, join(header, [][]byte{l[0], other[1], l[2], l[3]}), testAD); err != ErrDecryption {
+		t.Errorf("spliced: got %v, want %v", err, ErrDecryption)
+	}
+	if _, err := open(a, join(otherHeader, l), testAD); err != ErrDecryption {
+		t.Errorf("other header: got %v, want %v", err, ErrDecryption)
+	}
+}
+
+func TestStreamTampered(t *testing.T) {
+	a := testCipher(t)
+	stream := seal(t, a, testPlaintext(3*testChunkSize+10))
+	for i := range stream {
+		tampered := append([]byte(nil), stream...)
+		tampered[i] ^= 0x01
+		got, err := open(a, tampered, testAD)
+		if err == nil {
+			t.Fatalf("byte %d flipped: stream opened", i)
+		}
+		if i >= StreamHeaderSize && len(got) > (i-StreamHeaderSize)/(testChunkSize+TagSize)*testChunkSize {
+			t.Errorf("byte %d flipped: plaintext of the tampered chunk returned", i)
+		}
+	}
+}
+
+func TestStreamHeader(t *testing.T) {
+	a := testCipher(t)
+	header := make([]byte, StreamHeaderSize)
+	header[0] = streamVersion
+	for _, tc := range []struct {
+		name    string
+		version byte
+		size    uint32
+	}{
+		{"zero chunk size", streamVersion, 0},
+		{"chunk size too large", streamVersion, MaxChunkSize + 1},
+		{"version", streamVersion + 1, testChunkSize},
+	} {
+		header[0] = tc.version
+		binary.BigEndian.PutUint32(header[1:5], tc.size)
+		if _, err := NewReader(bytes.NewReader(header), a, nil); err != ErrStreamHeader {
+			t.Errorf("%s: got %v, want %v", tc.name, err, ErrStreamHeader)
+		}
+	}
+
+	if _, err := NewWriter(io.Discard, a, -1, nil); err != ErrStreamHeader {
+		t.Errorf("negative chunk size: got %v, want %v", err, ErrStreamHeader)
+	}
+	if _, err := NewWriter(io.Discard, a, MaxChunkSize+1, nil); err != ErrStreamHeader {
+		t.Errorf("chunk size too large: got %v, want %v", err, ErrStreamHeader)
+	}
+}
+
+func TestStreamWriteAfterClose(t *testing.T) {
+	w, err := NewWriter(io.Discard, testCipher(t), 0, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := w.Write([]byte("x")); err != ErrStreamClosed {
+		t.Errorf("got %v, want %v", err, ErrStreamClosed)
+	}
+}
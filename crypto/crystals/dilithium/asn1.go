@@ -0,0 +1,154 @@
+package dilithium
+
+// (c) 2023-2024 unix-world.org
+
+import (
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"encoding/pem"
+	"errors"
+)
+
+//Keys are encoded as PKCS#8 (private) and SubjectPublicKeyInfo (public), as other algorithms are, with the ML-DSA
+//algorithm identifiers of NIST (no parameters): Dilithium2 is ML-DSA-44, Dilithium3 is ML-DSA-65 and Dilithium5 is ML-DSA-87.
+//The public key is the packed public key, in the BIT STRING; the private key is the packed private key, in an
+//OCTET STRING (the "expandedKey" form).
+//This package implements Dilithium round 3, not the final FIPS 204, so the packed keys and signatures can only be
+//used with this package: an ML-DSA key of another implementation does not parse, or does not verify.
+var (
+	OidMLDSA44 = asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 3, 17}
+	OidMLDSA65 = asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 3, 18}
+	OidMLDSA87 = asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 3, 19}
+)
+
+type privateKeyInfo struct {
+	Version    int
+	Algo       pkix.AlgorithmIdentifier
+	PrivateKey []byte
+}
+
+type publicKeyInfo struct {
+	Algo      pkix.AlgorithmIdentifier
+	PublicKey asn1.BitString
+}
+
+//OID returns the algorithm identifier of the Dilithium instance, nil for a custom one.
+func (d *Dilithium) OID() asn1.ObjectIdentifier {
+	switch d.Name {
+	case "Dilithium2":
+		return OidMLDSA44
+	case "Dilithium3":
+		return OidMLDSA65
+	case "Dilithium5":
+		return OidMLDSA87
+	}
+	return nil
+}
+
+//SchemeByOID returns a (randomized) Dilithium instance for an algorithm identifier, nil if it is not one of ours.
+func SchemeByOID(oid asn1.ObjectIdentifier) *Dilithium {
+	switch {
+	case oid.Equal(OidMLDSA44):
+		return NewDilithium2()
+	case oid.Equal(OidMLDSA65):
+		return NewDilithium3()
+	case oid.Equal(OidMLDSA87):
+		return NewDilithium5()
+	}
+	return nil
+}
+
+//MarshalPKCS8PrivateKey encodes the key as an unencrypted PKCS#8 PrivateKeyInfo, in DER.
+//Use crypto/pkcs8.MarshalPrivateKey to encrypt it with a password.
+func MarshalPKCS8PrivateKey(k *SigningKey) ([]byte, error) {
+	oid := k.d.OID()
+	if oid == nil {
+		return nil, errors.New("Dilithium: no OID for " + k.d.Name)
+	}
+	key, err := asn1.Marshal(k.sk)
+	if err != nil {
+		return nil, err
+	}
+	return asn1.Marshal(privateKeyInfo{Algo: pkix.AlgorithmIdentifier{Algorithm: oid}, PrivateKey: key})
+}
+
+//ParsePKCS8PrivateKey decodes a DER PKCS#8 PrivateKeyInfo made by MarshalPKCS8PrivateKey.
+func ParsePKCS8PrivateKey(der []byte) (*SigningKey, error) {
+	var info privateKeyInfo
+	if _, err := asn1.Unmarshal(der, &info); err != nil { // as crypto/x509, trailing data (padding of a decrypted key) is ignored
+		return nil, errors.New("Dilithium: invalid PKCS#8 private key")
+	}
+	d := SchemeByOID(info.Algo.Algorithm)
+	if d == nil || len(info.Algo.Parameters.FullBytes) != 0 {
+		return nil, errors.New("Dilithium: PKCS#8 private key is not ML-DSA")
+	}
+	var sk []byte
+	if rest, err := asn1.Unmarshal(info.PrivateKey, &sk); err != nil || len(rest) != 0 {
+		return nil, errors.New("Dilithium: invalid PKCS#8 private key")
+	}
+	return NewSigningKey(d, sk)
+}
+
+//MarshalPKIXPublicKey encodes the key as a SubjectPublicKeyInfo, in DER.
+func MarshalPKIXPublicKey(k *VerifyingKey) ([]byte, error) {
+	oid := k.d.OID()
+	if oid == nil {
+		return nil, errors.New("Dilithium: no OID for " + k.d.Name)
+	}
+	return asn1.Marshal(publicKeyInfo{
+		Algo:      pkix.AlgorithmIdentifier{Algorithm: oid},
+		PublicKey: asn1.BitString{Bytes: k.pk, BitLength: 8 * len(k.pk)},
+	})
+}
+
+//ParsePKIXPublicKey decodes a DER SubjectPublicKeyInfo made by MarshalPKIXPublicKey.
+func ParsePKIXPublicKey(der []byte) (*VerifyingKey, error) {
+	var info publicKeyInfo
+	if rest, err := asn1.Unmarshal(der, &info); err != nil || len(rest) != 0 {
+		return nil, errors.New("Dilithium: invalid public key")
+	}
+	d := SchemeByOID(info.Algo.Algorithm)
+	if d == nil || len(info.Algo.Parameters.FullBytes) != 0 {
+		return nil, errors.New("Dilithium: public key is not ML-DSA")
+	}
+	if info.PublicKey.BitLength != 8*len(info.PublicKey.Bytes) {
+		return nil, errors.New("Dilithium: invalid public key")
+	}
+	return NewVerifyingKey(d, info.PublicKey.Bytes)
+}
+
+//MarshalPrivateKeyPEM encodes the key as a "PRIVATE KEY" PEM block (unencrypted PKCS#8).
+func MarshalPrivateKeyPEM(k *SigningKey) ([]byte, error) {
+	der, err := MarshalPKCS8PrivateKey(k)
+	if err != nil {
+		return nil, err
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}), nil
+}
+
+//ParsePrivateKeyPEM decodes the first "PRIVATE KEY" PEM block of data.
+func ParsePrivateKeyPEM(data []byte) (*SigningKey, error) {
+	block, _ := pem.Decode(data)
+	if block == nil || block.Type != "PRIVATE KEY" {
+		return nil, errors.New("Dilithium: no PRIVATE KEY PEM block found")
+	}
+	return ParsePKCS8PrivateKey(block.Bytes)
+}
+
+//MarshalPublicKeyPEM encodes the key as a "PUBLIC KEY" PEM block.
+func MarshalPublicKeyPEM(k *VerifyingKey) ([]byte, error) {
+	der, err := MarshalPKIXPublicKey(k)
+	if err != nil {
+		return nil, err
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der}), nil
+}
+
+//ParsePublicKeyPEM decodes the first "PUBLIC KEY" PEM block of data.
+func ParsePublicKeyPEM(data []byte) (*VerifyingKey, error) {
+	block, _ := pem.Decode(data)
+	if block == nil || block.Type != "PUBLIC KEY" {
+		return nil, errors.New("Dilithium: no PUBLIC KEY PEM block found")
+	}
+	return ParsePKIXPublicKey(block.Bytes)
+}
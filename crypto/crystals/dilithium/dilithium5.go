@@ -60,6 +60,18 @@ func (d *Dilithium) KeyGen(seed []byte) (error, []byte, []byte) { // return: err
 	for i := 0; i < K; i++ {
 		s2[i] = polyUniformEta(rhoprime, uint16(i+L), ETA)
 	}
+	t1, t0 := d.computeT(Ahat, s1, s2)
+	state.Write(append(rho[:], packT1(t1, K)...))
+	state.Read(tr[:])
+
+	return nil, d.PackPK(PublicKey{T1: t1, Rho: rho}), d.PackSK(PrivateKey{Rho: rho, Key: key, Tr: tr, S1: s1, S2: s2, T0: t0})
+}
+
+//computeT computes t = A*s1 + s2 and splits it into its high (t1) and low (t0) bits.
+func (d *Dilithium) computeT(Ahat Mat, s1, s2 Vec) (Vec, Vec) {
+	K := d.params.K
+	L := d.params.L
+
 	s1hat := s1.copy()
 	s1hat.ntt(L)
 	s2hat := s2.copy()
@@ -74,10 +86,7 @@ func (d *Dilithium) KeyGen(seed []byte) (error, []byte, []byte) { // return: err
 		t[i].addQ()
 		t1[i], t0[i] = polyPower2Round(t[i])
 	}
-	state.Write(append(rho[:], packT1(t1, K)...))
-	state.Read(tr[:])
-
-	return nil, d.PackPK(PublicKey{T1: t1, Rho: rho}), d.PackSK(PrivateKey{Rho: rho, Key: key, Tr: tr, S1: s1, S2: s2, T0: t0})
+	return t1, t0
 }
 
 //Sign produces a signature on the given msg using the secret signing key.
@@ -0,0 +1,125 @@
+package dilithium
+
+// (c) 2023-2024 unix-world.org
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/subtle"
+	"errors"
+	"io"
+
+	"github.com/unix-world/smartgo/crypto/sha3"
+)
+
+//SigningKey is a packed private key together with its Dilithium instance and public key.
+//It implements crypto.Signer, so it can be used wherever an ed25519 or ecdsa key is.
+type SigningKey struct {
+	d  *Dilithium
+	sk []byte
+	pk []byte
+}
+
+//VerifyingKey is a packed public key together with its Dilithium instance.
+//It is the crypto.PublicKey of a SigningKey.
+type VerifyingKey struct {
+	d  *Dilithium
+	pk []byte
+}
+
+//GenerateKey creates a SigningKey for the given Dilithium instance.
+//A 32 byte long seed can be given as argument. If a nil seed is given, the seed is generated using Go crypto's random number generator.
+func GenerateKey(d *Dilithium, seed []byte) (*SigningKey, error) {
+	err, pk, sk := d.KeyGen(seed)
+	if err != nil {
+		return nil, err
+	}
+	return &SigningKey{d: d, sk: sk, pk: pk}, nil
+}
+
+//NewSigningKey wraps a packed private key, as returned by KeyGen.
+//The public key is computed back from it, a private key that is not consistent (tampered) is rejected.
+func NewSigningKey(d *Dilithium, packedSK []byte) (*SigningKey, error) {
+	if len(packedSK) != d.SIZESK() {
+		return nil, errors.New("Private key does not have the correct size")
+	}
+	K := d.params.K
+	sk := d.UnpackSK(packedSK)
+	t1, t0 := d.computeT(expandSeed(sk.Rho, K, d.params.L), sk.S1, sk.S2)
+	pk := d.PackPK(PublicKey{T1: t1, Rho: sk.Rho})
+
+	var tr [SEEDBYTES]byte
+	state := sha3.NewShake256()
+	state.Write(pk)
+	state.Read(tr[:])
+	if subtle.ConstantTimeCompare(tr[:], sk.Tr[:]) != 1 || subtle.ConstantTimeCompare(packT0(t0, K), packT0(sk.T0, K)) != 1 {
+		return nil, errors.New("Private key is not valid")
+	}
+	return &SigningKey{d: d, sk: append([]byte(nil), packedSK...), pk: pk}, nil
+}
+
+//NewVerifyingKey wraps a packed public key, as returned by KeyGen.
+func NewVerifyingKey(d *Dilithium, packedPK []byte) (*VerifyingKey, error) {
+	if len(packedPK) != d.SIZEPK() {
+		return nil, errors.New("Public key does not have the correct size")
+	}
+	return &VerifyingKey{d: d, pk: append([]byte(nil), packedPK...)}, nil
+}
+
+//Scheme returns the Dilithium instance the key belongs to.
+func (k *SigningKey) Scheme() *Dilithium {
+	return k.d
+}
+
+//Bytes returns the packed private key.
+func (k *SigningKey) Bytes() []byte {
+	return append([]byte(nil), k.sk...)
+}
+
+//Public returns the *VerifyingKey of the key.
+func (k *SigningKey) Public() crypto.PublicKey {
+	return &VerifyingKey{d: k.d, pk: k.pk}
+}
+
+//Equal tells if x is the same private key, of the same Dilithium level.
+func (k *SigningKey) Equal(x crypto.PrivateKey) bool {
+	xk, ok := x.(*SigningKey)
+	if !ok || xk == nil {
+		return false
+	}
+	return k.d.Name == xk.d.Name && subtle.ConstantTimeCompare(k.sk, xk.sk) == 1
+}
+
+//Sign signs the message itself, Dilithium does its own hashing: as for ed25519, opts.HashFunc() must be crypto.Hash(0).
+//The rand argument is ignored, randomized or deterministic signing is set by the Dilithium instance.
+func (k *SigningKey) Sign(rand io.Reader, message []byte, opts crypto.SignerOpts) ([]byte, error) {
+	if opts != nil && opts.HashFunc() != crypto.Hash(0) {
+		return nil, errors.New("Dilithium cannot sign a pre-hashed message")
+	}
+	err, sig := k.d.Sign(k.sk, message)
+	return sig, err
+}
+
+//Scheme returns the Dilithium instance the key belongs to.
+func (k *VerifyingKey) Scheme() *Dilithium {
+	return k.d
+}
+
+//Bytes returns the packed public key.
+func (k *VerifyingKey) Bytes() []byte {
+	return append([]byte(nil), k.pk...)
+}
+
+//Equal tells if x is the same public key, of the same Dilithium level.
+func (k *VerifyingKey) Equal(x crypto.PublicKey) bool {
+	xk, ok := x.(*VerifyingKey)
+	if !ok || xk == nil {
+		return false
+	}
+	return k.d.Name == xk.d.Name && bytes.Equal(k.pk, xk.pk)
+}
+
+//Verify checks a signature made by Sign over msg.
+func (k *VerifyingKey) Verify(msg, sig []byte) bool {
+	return k.d.Verify(k.pk, msg, sig)
+}
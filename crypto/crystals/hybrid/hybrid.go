@@ -0,0 +1,209 @@
+
+// X25519 + Kyber768 :: Hybrid Post-Quantum KEM
+// (c) 2023-2024 unix-world.org
+// r.20241117.2358
+
+package hybrid
+
+// The hybrid KEM combines a classical X25519 key exchange with the Kyber768 post-quantum KEM,
+// so that the shared secret stays safe as long as at least one of the two is not broken.
+//
+//	public key:  x25519 public (32) | kyber768 public (1184)
+//	private key: x25519 private (32) | kyber768 private (2400)
+//	ciphertext:  x25519 ephemeral public (32) | kyber768 ciphertext (1088)
+//
+// The shared secret is derived with SHA3-256 over both shared secrets, both ciphertexts and the
+// recipient's X25519 public key (as for X-Wing, the Kyber part already binds it's own public key):
+//
+//	SHA3-256( KdfLabel | ss kyber | ss x25519 | ct x25519 | ct kyber | pk x25519 )
+//
+// Key generation and encapsulation take optional seeds, so the results can be reproduced (test vectors).
+// Without a seed (nil) they use the crypto random generator ; seeds must never be reused in real use.
+
+import (
+	"errors"
+	"crypto/rand"
+	"crypto/ecdh"
+
+	"github.com/unix-world/smartgo/crypto/sha3"
+
+	"github.com/unix-world/smartgoext/crypto/crystals/kyber"
+)
+
+const (
+	KdfLabel string = "X25519Kyber768.v1"
+
+	X25519Size int = 32
+
+	KyberPublicKeySize  int = 1184
+	KyberPrivateKeySize int = 2400
+	KyberCiphertextSize int = 1088
+
+	PublicKeySize    int = X25519Size + KyberPublicKeySize  // 1216
+	PrivateKeySize   int = X25519Size + KyberPrivateKeySize // 2432
+	CiphertextSize   int = X25519Size + KyberCiphertextSize // 1120
+	SharedSecretSize int = 32
+
+	KeySeedSize    int = 64 + X25519Size // kyber seed (64) | x25519 private (32)
+	EncapsSeedSize int = X25519Size + 32 // x25519 ephemeral private (32) | kyber coins (32)
+)
+
+
+//KeyGen creates a hybrid public and private key pair.
+//A 96 byte long seed can be given as argument (KeySeedSize). If a nil seed is given, it is generated using Go crypto's random number generator.
+// returns: error, publicKey, privateKey
+func KeyGen(seed []byte) (error, []byte, []byte) {
+	//--
+	if(len(seed) != KeySeedSize) {
+		if(seed != nil) {
+			return errors.New("Seed must be exactly 96 bytes"), nil, nil
+		} //end if
+		seed = make([]byte, KeySeedSize)
+		if _, err := rand.Read(seed); err != nil {
+			return err, nil, nil
+		} //end if
+	} //end if
+	//--
+	xPriv, errX := ecdh.X25519().NewPrivateKey(seed[64:])
+	if(errX != nil) {
+		return errX, nil, nil
+	} //end if
+	//--
+	errK, kPub, kPriv := kyber.NewKyber768().KeyGen(seed[:64])
+	if(errK != nil) {
+		return errK, nil, nil
+	} //end if
+	//--
+	pub := make([]byte, 0, PublicKeySize)
+	pub = append(pub, xPriv.PublicKey().Bytes()...)
+	pub = append(pub, kPub...)
+	//--
+	priv := make([]byte, 0, PrivateKeySize)
+	priv = append(priv, xPriv.Bytes()...)
+	priv = append(priv, kPriv...)
+	//--
+	return nil, pub, priv
+	//--
+} //END FUNCTION
+
+
+//PublicKey returns the public key that belongs to a hybrid private key.
+// returns: error, publicKey
+func PublicKey(priv []byte) (error, []byte) {
+	//--
+	if(len(priv) != PrivateKeySize) {
+		return errors.New("Private key does not have the correct size"), nil
+	} //end if
+	//--
+	xPriv, errX := ecdh.X25519().NewPrivateKey(priv[:X25519Size])
+	if(errX != nil) {
+		return errX, nil
+	} //end if
+	//--
+	errK, sk := kyber.NewKyber768().UnpackSK(priv[X25519Size:])
+	if(errK != nil) {
+		return errK, nil
+	} //end if
+	//--
+	pub := make([]byte, 0, PublicKeySize)
+	pub = append(pub, xPriv.PublicKey().Bytes()...)
+	pub = append(pub, sk.Pk...)
+	//--
+	return nil, pub
+	//--
+} //END FUNCTION
+
+
+//Encaps generates a shared secret and it's encapsulation for the given hybrid public key.
+//A 64 byte long seed can be given as argument (EncapsSeedSize). If a nil seed is given, it is generated using Go crypto's random number generator.
+// returns: error, ciphertext, sharedSecret
+func Encaps(pub []byte, seed []byte) (error, []byte, []byte) {
+	//--
+	if(len(pub) != PublicKeySize) {
+		return errors.New("Public key does not have the correct size"), nil, nil
+	} //end if
+	if(len(seed) != EncapsSeedSize) {
+		if(seed != nil) {
+			return errors.New("Seed must be exactly 64 bytes"), nil, nil
+		} //end if
+		seed = make([]byte, EncapsSeedSize)
+		if _, err := rand.Read(seed); err != nil {
+			return err, nil, nil
+		} //end if
+	} //end if
+	//--
+	xPub, errP := ecdh.X25519().NewPublicKey(pub[:X25519Size])
+	if(errP != nil) {
+		return errP, nil, nil
+	} //end if
+	xEph, errE := ecdh.X25519().NewPrivateKey(seed[:X25519Size])
+	if(errE != nil) {
+		return errE, nil, nil
+	} //end if
+	xSs, errX := xEph.ECDH(xPub)
+	if(errX != nil) { // low order point
+		return errX, nil, nil
+	} //end if
+	//--
+	errK, kCt, kSs := kyber.NewKyber768().Encaps(pub[X25519Size:], seed[X25519Size:])
+	if(errK != nil) {
+		return errK, nil, nil
+	} //end if
+	//--
+	ct := make([]byte, 0, CiphertextSize)
+	ct = append(ct, xEph.PublicKey().Bytes()...)
+	ct = append(ct, kCt...)
+	//--
+	return nil, ct, combine(kSs, xSs, ct, pub[:X25519Size])
+	//--
+} //END FUNCTION
+
+
+//Decaps recovers the shared secret from a ciphertext made by Encaps, with the hybrid private key.
+//A ciphertext that was tampered with on the Kyber side gives a different (pseudo-random) secret, not an error.
+// returns: error, sharedSecret
+func Decaps(priv []byte, ct []byte) (error, []byte) {
+	//--
+	if((len(priv) != PrivateKeySize) || (len(ct) != CiphertextSize)) {
+		return errors.New("Cannot decapsulate, inputs do not have the correct size"), nil
+	} //end if
+	//--
+	xPriv, errX := ecdh.X25519().NewPrivateKey(priv[:X25519Size])
+	if(errX != nil) {
+		return errX, nil
+	} //end if
+	xEph, errP := ecdh.X25519().NewPublicKey(ct[:X25519Size])
+	if(errP != nil) {
+		return errP, nil
+	} //end if
+	xSs, errS := xPriv.ECDH(xEph)
+	if(errS != nil) { // low order point
+		return errS, nil
+	} //end if
+	//--
+	errK, kSs := kyber.NewKyber768().Decaps(priv[X25519Size:], ct[X25519Size:])
+	if(errK != nil) {
+		return errK, nil
+	} //end if
+	//--
+	return nil, combine(kSs, xSs, ct, xPriv.PublicKey().Bytes())
+	//--
+} //END FUNCTION
+
+
+func combine(kSs []byte, xSs []byte, ct []byte, xPub []byte) []byte {
+	//--
+	h := sha3.New256()
+	h.Write([]byte(KdfLabel))
+	h.Write(kSs)
+	h.Write(xSs)
+	h.Write(ct[:X25519Size])
+	h.Write(ct[X25519Size:])
+	h.Write(xPub)
+	//--
+	return h.Sum(nil)
+	//--
+} //END FUNCTION
+
+
+// #END
@@ -0,0 +1,123 @@
+package hybrid
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"testing"
+)
+
+// seq returns n bytes counting up from start, to build reproducible seeds.
+func seq(n int, start byte) []byte {
+	b := make([]byte, n)
+	for i := range b {
+		b[i] = start + byte(i)
+	}
+	return b
+}
+
+func sum(b []byte) string {
+	h := sha256.Sum256(b)
+	return hex.EncodeToString(h[:])
+}
+
+// Known answer: keys and ciphertext are given by their SHA-256, the shared secret as is.
+var vector = struct {
+	keySeed, encapsSeed []byte
+	pk, sk, ct, ss      string
+}{
+	keySeed:    seq(KeySeedSize, 0x00),
+	encapsSeed: seq(EncapsSeedSize, 0x80),
+	pk:         "8952d2a87352a5b973239c52bdba65919a1b82498a34f02bb2aa2aed0d3dbb04",
+	sk:         "f74cd516c099f6e465d7958836788baf99303eb7bf811b5efbaa23ccdf46f61d",
+	ct:         "2f74e0e97e3f62484e0f2efca25e6d5b7b03df5331202c7f2217c62b50e0b95e",
+	ss:         "b6d2887800f30aafd03e2139892388b8660b16641cdcdf105d877e215be23b92",
+}
+
+func TestVector(t *testing.T) {
+	err, pub, priv := KeyGen(vector.keySeed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(pub) != PublicKeySize || len(priv) != PrivateKeySize {
+		t.Fatalf("key sizes: %d, %d", len(pub), len(priv))
+	}
+	if got := sum(pub); got != vector.pk {
+		t.Errorf("public key: got %s, want %s", got, vector.pk)
+	}
+	if got := sum(priv); got != vector.sk {
+		t.Errorf("private key: got %s, want %s", got, vector.sk)
+	}
+
+	err, ct, ss := Encaps(pub, vector.encapsSeed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(ct) != CiphertextSize || len(ss) != SharedSecretSize {
+		t.Fatalf("ciphertext / secret sizes: %d, %d", len(ct), len(ss))
+	}
+	if got := sum(ct); got != vector.ct {
+		t.Errorf("ciphertext: got %s, want %s", got, vector.ct)
+	}
+	if got := hex.EncodeToString(ss); got != vector.ss {
+		t.Errorf("shared secret: got %s, want %s", got, vector.ss)
+	}
+
+	err, ss2 := Decaps(priv, ct)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(ss, ss2) {
+		t.Errorf("decaps: got %x, want %x", ss2, ss)
+	}
+
+	err, pub2 := PublicKey(priv)
+	if err != nil || !bytes.Equal(pub, pub2) {
+		t.Errorf("PublicKey does not match KeyGen: %v", err)
+	}
+}
+
+func TestDecapsTampered(t *testing.T) {
+	_, pub, priv := KeyGen(nil)
+	_, ct, ss := Encaps(pub, nil)
+	for _, i := range []int{0, X25519Size, CiphertextSize - 1} {
+		bad := append([]byte(nil), ct...)
+		bad[i] ^= 0x01
+		err, ss2 := Decaps(priv, bad)
+		if err == nil && bytes.Equal(ss, ss2) {
+			t.Errorf("byte %d: tampered ciphertext gives the same secret", i)
+		}
+	}
+}
+
+func TestSealOpen(t *testing.T) {
+	_, pub, priv := KeyGen(nil)
+	_, _, other := KeyGen(nil)
+	msg := []byte("stored secret")
+	for _, c := range []byte{CipherAES256GCM, CipherAscon128a} {
+		err, sealed := SealToWithCipher(pub, msg, c)
+		if err != nil {
+			t.Fatal(err)
+		}
+		err, got := Open(priv, sealed)
+		if err != nil || !bytes.Equal(got, msg) {
+			t.Fatalf("cipher %d: Open = %q, %v", c, got, err)
+		}
+		if err, _ := Open(other, sealed); err == nil {
+			t.Errorf("cipher %d: opened with the wrong key", c)
+		}
+		for _, i := range []int{1, 2, len(sealed) - 1} {
+			bad := append([]byte(nil), sealed...)
+			bad[i] ^= 0x01
+			if err, _ := Open(priv, bad); err == nil {
+				t.Errorf("cipher %d: byte %d tampered, still opens", c, i)
+			}
+		}
+		if err, _ := Open(priv, sealed[:len(sealed)-1]); err == nil {
+			t.Errorf("cipher %d: truncated, still opens", c)
+		}
+	}
+	if err, _ := SealToWithCipher(pub, msg, 9); err == nil {
+		t.Error("unknown cipher accepted")
+	}
+}
@@ -0,0 +1,133 @@
+
+// X25519 + Kyber768 :: Hybrid Post-Quantum KEM :: Seal / Open
+// (c) 2023-2024 unix-world.org
+// r.20241117.2358
+
+package hybrid
+
+// One-shot public key encryption: every message gets a fresh encapsulation, and the AEAD key is
+// derived from it's shared secret, so a key is never used twice and the nonce can be fixed (zero).
+//
+//	version(1) | cipher id(1) | hybrid ciphertext (1120) | AEAD ciphertext + tag
+//
+// Everything before the AEAD ciphertext is authenticated as additional data.
+
+import (
+	"errors"
+
+	"crypto/aes"
+	"crypto/cipher"
+
+	"github.com/unix-world/smartgo/crypto/sha3"
+
+	"github.com/unix-world/smartgoext/crypto/ascon"
+)
+
+const (
+	sealVersion byte = 1
+
+	CipherAES256GCM byte = 1
+	CipherAscon128a byte = 2
+
+	sealHeaderSize int = 2 + CiphertextSize
+	sealKeyLabel string = "X25519Kyber768.v1 seal"
+)
+
+
+//SealTo encrypts and authenticates the plaintext for the owner of the hybrid public key, with AES-256-GCM.
+// returns: error, sealedData
+func SealTo(pub []byte, plaintext []byte) (error, []byte) {
+	//--
+	return SealToWithCipher(pub, plaintext, CipherAES256GCM)
+	//--
+} //END FUNCTION
+
+
+//SealToWithCipher works as SealTo, but with the selected AEAD: CipherAES256GCM or CipherAscon128a.
+// returns: error, sealedData
+func SealToWithCipher(pub []byte, plaintext []byte, cipherId byte) (error, []byte) {
+	//--
+	errE, ct, ss := Encaps(pub, nil)
+	if(errE != nil) {
+		return errE, nil
+	} //end if
+	//--
+	errA, aead := sealCipher(cipherId, ss)
+	if(errA != nil) {
+		return errA, nil
+	} //end if
+	//--
+	data := make([]byte, 0, sealHeaderSize + len(plaintext) + aead.Overhead())
+	data = append(data, sealVersion, cipherId)
+	data = append(data, ct...)
+	nonce := make([]byte, aead.NonceSize())
+	//--
+	return nil, aead.Seal(data, nonce, plaintext, data)
+	//--
+} //END FUNCTION
+
+
+//Open decrypts data made by SealTo or SealToWithCipher with the hybrid private key.
+//Any modification of the sealed data makes it fail.
+// returns: error, plaintext
+func Open(priv []byte, sealed []byte) (error, []byte) {
+	//--
+	if((len(sealed) < sealHeaderSize) || (sealed[0] != sealVersion)) {
+		return errors.New("Invalid sealed data"), nil
+	} //end if
+	//--
+	errD, ss := Decaps(priv, sealed[2:sealHeaderSize])
+	if(errD != nil) {
+		return errD, nil
+	} //end if
+	//--
+	errA, aead := sealCipher(sealed[1], ss)
+	if(errA != nil) {
+		return errA, nil
+	} //end if
+	if(len(sealed) < sealHeaderSize + aead.Overhead()) {
+		return errors.New("Invalid sealed data"), nil
+	} //end if
+	//--
+	nonce := make([]byte, aead.NonceSize())
+	plaintext, errO := aead.Open(nil, nonce, sealed[sealHeaderSize:], sealed[:sealHeaderSize])
+	if(errO != nil) {
+		return errors.New("Sealed data authentication failed"), nil
+	} //end if
+	//--
+	return nil, plaintext
+	//--
+} //END FUNCTION
+
+
+func sealCipher(cipherId byte, ss []byte) (error, cipher.AEAD) {
+	//--
+	key := make([]byte, 32)
+	h := sha3.NewShake256()
+	h.Write([]byte(sealKeyLabel))
+	h.Write([]byte{cipherId})
+	h.Write(ss)
+	h.Read(key)
+	//--
+	switch(cipherId) {
+		case CipherAES256GCM:
+			block, err := aes.NewCipher(key)
+			if(err != nil) {
+				return err, nil
+			} //end if
+			aead, errG := cipher.NewGCM(block)
+			return errG, aead
+		case CipherAscon128a:
+			aead, err := ascon.New(key[:ascon.KeySize], ascon.Ascon128a)
+			if(err != nil) {
+				return err, nil
+			} //end if
+			return nil, aead
+	} //end switch
+	//--
+	return errors.New("Invalid seal cipher"), nil
+	//--
+} //END FUNCTION
+
+
+// #END
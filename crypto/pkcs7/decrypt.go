@@ -9,39 +9,123 @@ import (
 	"crypto/rand"
 	"crypto/rsa"
 	"crypto/x509"
+	"crypto/x509/pkix"
 	"encoding/asn1"
 	"errors"
 	"fmt"
 )
 
 // ErrUnsupportedAlgorithm tells you when our quick dev assumptions have failed
-var ErrUnsupportedAlgorithm = errors.New("pkcs7: cannot decrypt data: only RSA, DES, DES-EDE3, AES-256-CBC and AES-128-GCM supported")
+var ErrUnsupportedAlgorithm = errors.New("pkcs7: cannot decrypt data: only RSA, RSA-OAEP, ECDH, DES, DES-EDE3, AES-256-CBC and AES-128-GCM supported")
 
 // ErrNotEncryptedContent is returned when attempting to Decrypt data that is not encrypted data
 var ErrNotEncryptedContent = errors.New("pkcs7: content data is a decryptable data type")
 
-// Decrypt decrypts encrypted content info for recipient cert and private key
+// Decrypt decrypts encrypted content info for recipient cert and private key.
+// For RSA recipients the private key has to implement crypto.Decrypter, as
+// *rsa.PrivateKey and keys kept in an HSM do. For EC recipients it has to be
+// an *ecdsa.PrivateKey or to implement KeyAgreer.
 func (p7 *PKCS7) Decrypt(cert *x509.Certificate, pkey crypto.PrivateKey) ([]byte, error) {
 	data, ok := p7.raw.(envelopedData)
 	if !ok {
 		return nil, ErrNotEncryptedContent
 	}
-	recipient := selectRecipientForCertificate(data.RecipientInfos, cert)
-	if recipient.EncryptedKey == nil {
-		return nil, errors.New("pkcs7: no enveloped recipient for provided certificate")
-	}
-	switch pkey := pkey.(type) {
-	case *rsa.PrivateKey:
+	for _, raw := range data.RecipientInfos {
 		var contentKey []byte
-		contentKey, err := rsa.DecryptPKCS1v15(rand.Reader, pkey, recipient.EncryptedKey)
+		var err error
+		switch {
+		case raw.Class == asn1.ClassUniversal && raw.Tag == asn1.TagSequence:
+			var recipient recipientInfo
+			if _, err := asn1.Unmarshal(raw.FullBytes, &recipient); err != nil {
+				continue
+			}
+			if !isCertMatchForIssuerAndSerial(cert, recipient.IssuerAndSerialNumber) {
+				continue
+			}
+			contentKey, err = recipient.decryptKey(pkey)
+		case raw.Class == asn1.ClassContextSpecific && raw.Tag == 1:
+			var recipient keyAgreeRecipientInfo
+			if _, err := asn1.UnmarshalWithParams(raw.FullBytes, &recipient, "tag:1"); err != nil {
+				continue
+			}
+			encryptedKey := recipient.encryptedKeyFor(cert)
+			if encryptedKey == nil {
+				continue
+			}
+			contentKey, err = recipient.decryptKey(encryptedKey, cert, pkey)
+		default:
+			continue
+		}
 		if err != nil {
 			return nil, err
 		}
 		return data.EncryptedContentInfo.decrypt(contentKey)
 	}
+	return nil, errors.New("pkcs7: no enveloped recipient for provided certificate")
+}
+
+func (ri recipientInfo) decryptKey(pkey crypto.PrivateKey) ([]byte, error) {
+	decrypter, ok := pkey.(crypto.Decrypter)
+	if !ok {
+		return nil, ErrUnsupportedAlgorithm
+	}
+	if _, ok := decrypter.Public().(*rsa.PublicKey); !ok {
+		return nil, ErrUnsupportedAlgorithm
+	}
+	alg := ri.KeyEncryptionAlgorithm
+	switch {
+	case alg.Algorithm.Equal(OIDEncryptionAlgorithmRSA):
+		return decrypter.Decrypt(rand.Reader, ri.EncryptedKey, &rsa.PKCS1v15DecryptOptions{})
+	case alg.Algorithm.Equal(OIDEncryptionAlgorithmRSAESOAEP):
+		opts, err := parseOAEPParameters(alg.Parameters.FullBytes)
+		if err != nil {
+			return nil, err
+		}
+		return decrypter.Decrypt(rand.Reader, ri.EncryptedKey, opts)
+	}
 	return nil, ErrUnsupportedAlgorithm
 }
 
+// parseOAEPParameters reads RSAES-OAEP-params, where everything defaults to SHA-1
+// and an empty label.
+func parseOAEPParameters(der []byte) (*rsa.OAEPOptions, error) {
+	opts := &rsa.OAEPOptions{Hash: crypto.SHA1, MGFHash: crypto.SHA1}
+	if len(der) == 0 {
+		return opts, nil
+	}
+	var params rsaOAEPParameters
+	if _, err := asn1.Unmarshal(der, &params); err != nil {
+		return nil, errors.New("pkcs7: invalid RSAES-OAEP parameters")
+	}
+	var err error
+	if params.HashFunc.Algorithm != nil {
+		if opts.Hash, err = getHashForOID(params.HashFunc.Algorithm); err != nil {
+			return nil, err
+		}
+	}
+	if params.MaskGenFunc.Algorithm != nil {
+		var mgfHash pkix.AlgorithmIdentifier
+		if !params.MaskGenFunc.Algorithm.Equal(OIDMaskGenFunctionMGF1) {
+			return nil, ErrUnsupportedAlgorithm
+		}
+		if _, err := asn1.Unmarshal(params.MaskGenFunc.Parameters.FullBytes, &mgfHash); err != nil {
+			return nil, errors.New("pkcs7: invalid RSAES-OAEP parameters")
+		}
+		if opts.MGFHash, err = getHashForOID(mgfHash.Algorithm); err != nil {
+			return nil, err
+		}
+	}
+	if params.PSourceFunc.Algorithm != nil {
+		if !params.PSourceFunc.Algorithm.Equal(OIDPSourceSpecified) {
+			return nil, ErrUnsupportedAlgorithm
+		}
+		if _, err := asn1.Unmarshal(params.PSourceFunc.Parameters.FullBytes, &opts.Label); err != nil {
+			return nil, errors.New("pkcs7: invalid RSAES-OAEP parameters")
+		}
+	}
+	return opts, nil
+}
+
 // DecryptUsingPSK decrypts encrypted data using caller provided
 // pre-shared secret
 func (p7 *PKCS7) DecryptUsingPSK(key []byte) ([]byte, error) {
@@ -166,12 +250,3 @@ func unpad(data []byte, blocklen int) ([]byte, error) {
 
 	return data[:len(data)-padlen], nil
 }
-
-func selectRecipientForCertificate(recipients []recipientInfo, cert *x509.Certificate) recipientInfo {
-	for _, recp := range recipients {
-		if isCertMatchForIssuerAndSerial(cert, recp.IssuerAndSerialNumber) {
-			return recp
-		}
-	}
-	return recipientInfo{}
-}
@@ -0,0 +1,299 @@
+package pkcs7
+
+import (
+	"crypto"
+	"crypto/aes"
+	"crypto/ecdh"
+	"crypto/ecdsa"
+	"crypto/rand"
+	"crypto/subtle"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"encoding/binary"
+	"errors"
+
+	_ "crypto/sha1"   // for crypto.SHA1
+	_ "crypto/sha256" // for crypto.SHA256
+	_ "crypto/sha512" // for crypto.SHA384, crypto.SHA512
+)
+
+// ECDH ephemeral-static key agreement, as in RFC 5753: the originator makes an
+// ephemeral key on the curve of the recipient, derives a key-encryption key from
+// the shared secret with the ANSI X9.63 KDF, and wraps the content key with it
+// (RFC 3394 AES key wrap).
+
+// KeyAgreer is a private key that can do an ECDH key agreement. It is implemented
+// by *ecdh.PrivateKey, and can be implemented by keys that stay in an HSM.
+// Decrypt also accepts an *ecdsa.PrivateKey.
+type KeyAgreer interface {
+	ECDH(remote *ecdh.PublicKey) ([]byte, error)
+}
+
+type keyAgreeRecipientInfo struct {
+	Version                int
+	Originator             asn1.RawValue // [0] EXPLICIT OriginatorIdentifierOrKey
+	UKM                    []byte        `asn1:"explicit,optional,tag:1"`
+	KeyEncryptionAlgorithm pkix.AlgorithmIdentifier
+	RecipientEncryptedKeys []recipientEncryptedKey
+}
+
+type originatorPublicKey struct {
+	Algorithm pkix.AlgorithmIdentifier
+	PublicKey asn1.BitString
+}
+
+type recipientEncryptedKey struct {
+	RID          asn1.RawValue // issuerAndSerialNumber, or [0] rKeyId
+	EncryptedKey []byte
+}
+
+type eccCMSSharedInfo struct {
+	KeyInfo     pkix.AlgorithmIdentifier
+	EntityUInfo []byte `asn1:"explicit,optional,tag:0"`
+	SuppPubInfo []byte `asn1:"explicit,tag:2"`
+}
+
+// ecdhScheme gives the key agreement algorithm, its KDF hash and the key wrap
+// used for a curve, the pairs of RFC 5008 (Suite B).
+func ecdhScheme(curve ecdh.Curve) (asn1.ObjectIdentifier, crypto.Hash, asn1.ObjectIdentifier, error) {
+	switch curve {
+	case ecdh.P256():
+		return OIDKeyAgreementECDHSHA256KDF, crypto.SHA256, OIDKeyWrapAES128, nil
+	case ecdh.P384():
+		return OIDKeyAgreementECDHSHA384KDF, crypto.SHA384, OIDKeyWrapAES256, nil
+	}
+	return nil, 0, nil, errors.New("pkcs7: only P-256 and P-384 recipients are supported for ECDH")
+}
+
+func encryptKeyECDH(key []byte, recipient *x509.Certificate, pub *ecdsa.PublicKey) (*keyAgreeRecipientInfo, error) {
+	recipientKey, err := pub.ECDH()
+	if err != nil {
+		return nil, err
+	}
+	kaOID, hash, wrapOID, err := ecdhScheme(recipientKey.Curve())
+	if err != nil {
+		return nil, err
+	}
+	ephemeral, err := recipientKey.Curve().GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	secret, err := ephemeral.ECDH(recipientKey)
+	if err != nil {
+		return nil, err
+	}
+	kek, err := deriveKEK(hash, secret, wrapOID, nil)
+	if err != nil {
+		return nil, err
+	}
+	wrapped, err := aesKeyWrap(kek, key)
+	if err != nil {
+		return nil, err
+	}
+
+	originatorKey, err := asn1.MarshalWithParams(originatorPublicKey{
+		Algorithm: pkix.AlgorithmIdentifier{Algorithm: OIDPublicKeyECDSA},
+		PublicKey: asn1.BitString{Bytes: ephemeral.PublicKey().Bytes(), BitLength: 8 * len(ephemeral.PublicKey().Bytes())},
+	}, "tag:1")
+	if err != nil {
+		return nil, err
+	}
+	// a RawValue is marshalled and unmarshalled as is, so the explicit [0] is handled here
+	originator, err := asn1.Marshal(asn1.RawValue{Class: asn1.ClassContextSpecific, Tag: 0, IsCompound: true, Bytes: originatorKey})
+	if err != nil {
+		return nil, err
+	}
+	wrapAlg, err := asn1.Marshal(pkix.AlgorithmIdentifier{Algorithm: wrapOID})
+	if err != nil {
+		return nil, err
+	}
+	rid, err := asn1.Marshal(cert2issuerAndSerial(recipient))
+	if err != nil {
+		return nil, err
+	}
+	return &keyAgreeRecipientInfo{
+		Version:    3,
+		Originator: asn1.RawValue{FullBytes: originator},
+		KeyEncryptionAlgorithm: pkix.AlgorithmIdentifier{
+			Algorithm:  kaOID,
+			Parameters: asn1.RawValue{FullBytes: wrapAlg},
+		},
+		RecipientEncryptedKeys: []recipientEncryptedKey{{
+			RID:          asn1.RawValue{FullBytes: rid},
+			EncryptedKey: wrapped,
+		}},
+	}, nil
+}
+
+// encryptedKeyFor returns the wrapped key for cert, nil if cert is not one of the recipients.
+func (kari keyAgreeRecipientInfo) encryptedKeyFor(cert *x509.Certificate) []byte {
+	for _, rek := range kari.RecipientEncryptedKeys {
+		var ias issuerAndSerial
+		if _, err := asn1.Unmarshal(rek.RID.FullBytes, &ias); err != nil {
+			continue
+		}
+		if isCertMatchForIssuerAndSerial(cert, ias) {
+			return rek.EncryptedKey
+		}
+	}
+	return nil
+}
+
+func (kari keyAgreeRecipientInfo) decryptKey(encryptedKey []byte, cert *x509.Certificate, pkey crypto.PrivateKey) ([]byte, error) {
+	var agreer KeyAgreer
+	switch pkey := pkey.(type) {
+	case *ecdsa.PrivateKey:
+		k, err := pkey.ECDH()
+		if err != nil {
+			return nil, err
+		}
+		agreer = k
+	case KeyAgreer:
+		agreer = pkey
+	default:
+		return nil, ErrUnsupportedAlgorithm
+	}
+	pub, ok := cert.PublicKey.(*ecdsa.PublicKey)
+	if !ok {
+		return nil, ErrUnsupportedAlgorithm
+	}
+	recipientKey, err := pub.ECDH()
+	if err != nil {
+		return nil, err
+	}
+
+	var hash crypto.Hash
+	switch alg := kari.KeyEncryptionAlgorithm.Algorithm; {
+	case alg.Equal(OIDKeyAgreementECDHSHA1KDF):
+		hash = crypto.SHA1
+	case alg.Equal(OIDKeyAgreementECDHSHA256KDF):
+		hash = crypto.SHA256
+	case alg.Equal(OIDKeyAgreementECDHSHA384KDF):
+		hash = crypto.SHA384
+	case alg.Equal(OIDKeyAgreementECDHSHA512KDF):
+		hash = crypto.SHA512
+	default:
+		return nil, ErrUnsupportedAlgorithm
+	}
+	var wrapAlg pkix.AlgorithmIdentifier
+	if _, err := asn1.Unmarshal(kari.KeyEncryptionAlgorithm.Parameters.FullBytes, &wrapAlg); err != nil {
+		return nil, errors.New("pkcs7: invalid key wrap algorithm")
+	}
+
+	// the originator has to be an originatorKey, [1] inside the explicit [0]
+	var originatorKey asn1.RawValue
+	if _, err := asn1.Unmarshal(kari.Originator.Bytes, &originatorKey); err != nil {
+		return nil, err
+	}
+	if originatorKey.Class != asn1.ClassContextSpecific || originatorKey.Tag != 1 {
+		return nil, errors.New("pkcs7: only ephemeral originator keys are supported")
+	}
+	var originator originatorPublicKey
+	if _, err := asn1.UnmarshalWithParams(originatorKey.FullBytes, &originator, "tag:1"); err != nil {
+		return nil, err
+	}
+	ephemeral, err := recipientKey.Curve().NewPublicKey(originator.PublicKey.RightAlign())
+	if err != nil {
+		return nil, err
+	}
+	secret, err := agreer.ECDH(ephemeral)
+	if err != nil {
+		return nil, err
+	}
+	kek, err := deriveKEK(hash, secret, wrapAlg.Algorithm, kari.UKM)
+	if err != nil {
+		return nil, err
+	}
+	return aesKeyUnwrap(kek, encryptedKey)
+}
+
+// deriveKEK is the ANSI X9.63 KDF over the ECC-CMS-SharedInfo of RFC 5753, section 7.2.
+func deriveKEK(hash crypto.Hash, secret []byte, wrapOID asn1.ObjectIdentifier, ukm []byte) ([]byte, error) {
+	var size int
+	switch {
+	case wrapOID.Equal(OIDKeyWrapAES128):
+		size = 16
+	case wrapOID.Equal(OIDKeyWrapAES192):
+		size = 24
+	case wrapOID.Equal(OIDKeyWrapAES256):
+		size = 32
+	default:
+		return nil, ErrUnsupportedAlgorithm
+	}
+	sharedInfo, err := asn1.Marshal(eccCMSSharedInfo{
+		KeyInfo:     pkix.AlgorithmIdentifier{Algorithm: wrapOID},
+		EntityUInfo: ukm,
+		SuppPubInfo: binary.BigEndian.AppendUint32(nil, uint32(8*size)),
+	})
+	if err != nil {
+		return nil, err
+	}
+	var kek []byte
+	for counter := uint32(1); len(kek) < size; counter++ {
+		h := hash.New()
+		h.Write(secret)
+		h.Write(binary.BigEndian.AppendUint32(nil, counter))
+		h.Write(sharedInfo)
+		kek = h.Sum(kek)
+	}
+	return kek[:size], nil
+}
+
+var keyWrapIV = []byte{0xa6, 0xa6, 0xa6, 0xa6, 0xa6, 0xa6, 0xa6, 0xa6}
+
+// aesKeyWrap wraps key with kek, RFC 3394.
+func aesKeyWrap(kek, key []byte) ([]byte, error) {
+	if len(key)%8 != 0 || len(key) < 16 {
+		return nil, errors.New("pkcs7: key wrap needs a content key of at least 16 bytes, in 8 byte blocks")
+	}
+	block, err := aes.NewCipher(kek)
+	if err != nil {
+		return nil, err
+	}
+	n := len(key) / 8
+	out := make([]byte, 8+len(key))
+	copy(out, keyWrapIV)
+	copy(out[8:], key)
+	var b [16]byte
+	for j := 0; j < 6; j++ {
+		for i := 1; i <= n; i++ {
+			copy(b[:8], out[:8])
+			copy(b[8:], out[8*i:8*i+8])
+			block.Encrypt(b[:], b[:])
+			t := uint64(n*j + i)
+			binary.BigEndian.PutUint64(out[:8], binary.BigEndian.Uint64(b[:8])^t)
+			copy(out[8*i:], b[8:])
+		}
+	}
+	return out, nil
+}
+
+// aesKeyUnwrap reverses aesKeyWrap, and checks the integrity of the wrapped key.
+func aesKeyUnwrap(kek, wrapped []byte) ([]byte, error) {
+	if len(wrapped)%8 != 0 || len(wrapped) < 24 {
+		return nil, errors.New("pkcs7: invalid wrapped key")
+	}
+	block, err := aes.NewCipher(kek)
+	if err != nil {
+		return nil, err
+	}
+	n := len(wrapped)/8 - 1
+	out := make([]byte, len(wrapped))
+	copy(out, wrapped)
+	var b [16]byte
+	for j := 5; j >= 0; j-- {
+		for i := n; i >= 1; i-- {
+			t := uint64(n*j + i)
+			binary.BigEndian.PutUint64(b[:8], binary.BigEndian.Uint64(out[:8])^t)
+			copy(b[8:], out[8*i:8*i+8])
+			block.Decrypt(b[:], b[:])
+			copy(out[:8], b[:8])
+			copy(out[8*i:], b[8:])
+		}
+	}
+	if subtle.ConstantTimeCompare(out[:8], keyWrapIV) != 1 {
+		return nil, errors.New("pkcs7: wrapped key integrity check failed")
+	}
+	return out[8:], nil
+}
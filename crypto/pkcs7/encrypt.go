@@ -5,8 +5,10 @@ import (
 	"crypto/aes"
 	"crypto/cipher"
 	"crypto/des"
+	"crypto/ecdsa"
 	"crypto/rand"
 	"crypto/rsa"
+	"crypto/sha256"
 	"crypto/x509"
 	"crypto/x509/pkix"
 	"encoding/asn1"
@@ -16,7 +18,7 @@ import (
 
 type envelopedData struct {
 	Version              int
-	RecipientInfos       []recipientInfo `asn1:"set"`
+	RecipientInfos       []asn1.RawValue `asn1:"set"` // recipientInfo, or keyAgreeRecipientInfo tagged [1]
 	EncryptedContentInfo encryptedContentInfo
 }
 
@@ -32,6 +34,12 @@ type recipientInfo struct {
 	EncryptedKey           []byte
 }
 
+type rsaOAEPParameters struct {
+	HashFunc    pkix.AlgorithmIdentifier `asn1:"explicit,optional,tag:0"`
+	MaskGenFunc pkix.AlgorithmIdentifier `asn1:"explicit,optional,tag:1"`
+	PSourceFunc pkix.AlgorithmIdentifier `asn1:"explicit,optional,tag:2"`
+}
+
 type encryptedContentInfo struct {
 	ContentType                asn1.ObjectIdentifier
 	ContentEncryptionAlgorithm pkix.AlgorithmIdentifier
@@ -62,6 +70,14 @@ const (
 // algorithm is used in the Encrypt() function.
 var ContentEncryptionAlgorithm = EncryptionAlgorithmDESCBC
 
+const (
+	// KeyEncryptionAlgorithmRSAPKCS1v15 is RSAES-PKCS1-v1_5
+	KeyEncryptionAlgorithmRSAPKCS1v15 = iota
+
+	// KeyEncryptionAlgorithmRSAOAEPSHA256 is RSAES-OAEP with SHA-256 and MGF1 with SHA-256
+	KeyEncryptionAlgorithmRSAOAEPSHA256
+)
+
 // ErrUnsupportedEncryptionAlgorithm is returned when attempting to encrypt
 // content with an unsupported algorithm.
 var ErrUnsupportedEncryptionAlgorithm = errors.New("pkcs7: cannot encrypt content: only DES-CBC, AES-CBC, and AES-GCM supported")
@@ -77,10 +93,10 @@ type aesGCMParameters struct {
 	ICVLen int
 }
 
-func encryptAESGCM(content []byte, key []byte) ([]byte, *encryptedContentInfo, error) {
+func encryptAESGCM(content []byte, key []byte, algorithm int) ([]byte, *encryptedContentInfo, error) {
 	var keyLen int
 	var algID asn1.ObjectIdentifier
-	switch ContentEncryptionAlgorithm {
+	switch algorithm {
 	case EncryptionAlgorithmAES128GCM:
 		keyLen = 16
 		algID = OIDEncryptionAlgorithmAES128GCM
@@ -88,7 +104,7 @@ func encryptAESGCM(content []byte, key []byte) ([]byte, *encryptedContentInfo, e
 		keyLen = 32
 		algID = OIDEncryptionAlgorithmAES256GCM
 	default:
-		return nil, nil, fmt.Errorf("invalid ContentEncryptionAlgorithm in encryptAESGCM: %d", ContentEncryptionAlgorithm)
+		return nil, nil, fmt.Errorf("invalid ContentEncryptionAlgorithm in encryptAESGCM: %d", algorithm)
 	}
 	if key == nil {
 		// Create AES key
@@ -191,10 +207,10 @@ func encryptDESCBC(content []byte, key []byte) ([]byte, *encryptedContentInfo, e
 	return key, &eci, nil
 }
 
-func encryptAESCBC(content []byte, key []byte) ([]byte, *encryptedContentInfo, error) {
+func encryptAESCBC(content []byte, key []byte, algorithm int) ([]byte, *encryptedContentInfo, error) {
 	var keyLen int
 	var algID asn1.ObjectIdentifier
-	switch ContentEncryptionAlgorithm {
+	switch algorithm {
 	case EncryptionAlgorithmAES128CBC:
 		keyLen = 16
 		algID = OIDEncryptionAlgorithmAES128CBC
@@ -202,7 +218,7 @@ func encryptAESCBC(content []byte, key []byte) ([]byte, *encryptedContentInfo, e
 		keyLen = 32
 		algID = OIDEncryptionAlgorithmAES256CBC
 	default:
-		return nil, nil, fmt.Errorf("invalid ContentEncryptionAlgorithm in encryptAESCBC: %d", ContentEncryptionAlgorithm)
+		return nil, nil, fmt.Errorf("invalid ContentEncryptionAlgorithm in encryptAESCBC: %d", algorithm)
 	}
 
 	if key == nil {
@@ -258,24 +274,45 @@ func encryptAESCBC(content []byte, key []byte) ([]byte, *encryptedContentInfo, e
 //
 //	ContentEncryptionAlgorithm = EncryptionAlgorithmAES128GCM
 //
+// EncryptWithAlgorithm selects the algorithms of a single call instead, without
+// changing the global.
+//
+// RSA recipients get the content key encrypted with RSAES-OAEP and SHA-256,
+// EncryptWithAlgorithm can select PKCS#1 v1.5 for readers without OAEP. P-256
+// and P-384 recipients get an ECDH ephemeral-static key agreement with AES key
+// wrap (RFC 5753), which needs a content key of 16 bytes or more, so DES-CBC can
+// not be used with them.
+//
 // TODO(fullsailor): Add support for encrypting content with other algorithms
 func Encrypt(content []byte, recipients []*x509.Certificate) ([]byte, error) {
+	return EncryptWithAlgorithm(content, recipients, ContentEncryptionAlgorithm, KeyEncryptionAlgorithmRSAOAEPSHA256)
+}
+
+// EncryptWithAlgorithm is like Encrypt, but encrypts the content with
+// algorithm, one of the EncryptionAlgorithm constants, instead of the global
+// ContentEncryptionAlgorithm, and the content key of RSA recipients with
+// keyAlgorithm, one of the KeyEncryptionAlgorithm constants.
+func EncryptWithAlgorithm(content []byte, recipients []*x509.Certificate, algorithm int, keyAlgorithm int) ([]byte, error) {
+	if keyAlgorithm != KeyEncryptionAlgorithmRSAPKCS1v15 && keyAlgorithm != KeyEncryptionAlgorithmRSAOAEPSHA256 {
+		return nil, fmt.Errorf("pkcs7: invalid key encryption algorithm: %d", keyAlgorithm)
+	}
+
 	var eci *encryptedContentInfo
 	var key []byte
 	var err error
 
 	// Apply chosen symmetric encryption method
-	switch ContentEncryptionAlgorithm {
+	switch algorithm {
 	case EncryptionAlgorithmDESCBC:
 		key, eci, err = encryptDESCBC(content, nil)
 	case EncryptionAlgorithmAES128CBC:
 		fallthrough
 	case EncryptionAlgorithmAES256CBC:
-		key, eci, err = encryptAESCBC(content, nil)
+		key, eci, err = encryptAESCBC(content, nil, algorithm)
 	case EncryptionAlgorithmAES128GCM:
 		fallthrough
 	case EncryptionAlgorithmAES256GCM:
-		key, eci, err = encryptAESGCM(content, nil)
+		key, eci, err = encryptAESGCM(content, nil, algorithm)
 
 	default:
 		return nil, ErrUnsupportedEncryptionAlgorithm
@@ -286,28 +323,40 @@ func Encrypt(content []byte, recipients []*x509.Certificate) ([]byte, error) {
 	}
 
 	// Prepare each recipient's encrypted cipher key
-	recipientInfos := make([]recipientInfo, len(recipients))
+	version := 0
+	recipientInfos := make([]asn1.RawValue, len(recipients))
 	for i, recipient := range recipients {
-		encrypted, err := encryptKey(key, recipient)
-		if err != nil {
-			return nil, err
-		}
-		ias := cert2issuerAndSerial(recipient)
-		info := recipientInfo{
-			Version:               0,
-			IssuerAndSerialNumber: ias,
-			KeyEncryptionAlgorithm: pkix.AlgorithmIdentifier{
-				Algorithm: OIDEncryptionAlgorithmRSA,
-			},
-			EncryptedKey: encrypted,
+		var info []byte
+		switch pub := recipient.PublicKey.(type) {
+		case *rsa.PublicKey:
+			ri, err := encryptKeyRSA(key, recipient, pub, keyAlgorithm)
+			if err != nil {
+				return nil, err
+			}
+			info, err = asn1.Marshal(*ri)
+			if err != nil {
+				return nil, err
+			}
+		case *ecdsa.PublicKey:
+			kari, err := encryptKeyECDH(key, recipient, pub)
+			if err != nil {
+				return nil, err
+			}
+			info, err = asn1.MarshalWithParams(*kari, "tag:1")
+			if err != nil {
+				return nil, err
+			}
+			version = 2
+		default:
+			return nil, fmt.Errorf("pkcs7: cannot encrypt for recipient key type %T", recipient.PublicKey)
 		}
-		recipientInfos[i] = info
+		recipientInfos[i] = asn1.RawValue{FullBytes: info}
 	}
 
 	// Prepare envelope content
 	envelope := envelopedData{
 		EncryptedContentInfo: *eci,
-		Version:              0,
+		Version:              version,
 		RecipientInfos:       recipientInfos,
 	}
 	innerContent, err := asn1.Marshal(envelope)
@@ -342,7 +391,7 @@ func EncryptUsingPSK(content []byte, key []byte) ([]byte, error) {
 	case EncryptionAlgorithmAES128GCM:
 		fallthrough
 	case EncryptionAlgorithmAES256GCM:
-		_, eci, err = encryptAESGCM(content, key)
+		_, eci, err = encryptAESGCM(content, key, ContentEncryptionAlgorithm)
 
 	default:
 		return nil, ErrUnsupportedEncryptionAlgorithm
@@ -376,11 +425,46 @@ func marshalEncryptedContent(content []byte) asn1.RawValue {
 	return asn1.RawValue{Tag: 0, Class: 2, Bytes: asn1Content, IsCompound: true}
 }
 
-func encryptKey(key []byte, recipient *x509.Certificate) ([]byte, error) {
-	if pub := recipient.PublicKey.(*rsa.PublicKey); pub != nil {
-		return rsa.EncryptPKCS1v15(rand.Reader, pub, key)
+func encryptKeyRSA(key []byte, recipient *x509.Certificate, pub *rsa.PublicKey, algorithm int) (*recipientInfo, error) {
+	info := recipientInfo{
+		Version:               0,
+		IssuerAndSerialNumber: cert2issuerAndSerial(recipient),
+		KeyEncryptionAlgorithm: pkix.AlgorithmIdentifier{
+			Algorithm: OIDEncryptionAlgorithmRSA,
+		},
+	}
+	var err error
+	switch algorithm {
+	case KeyEncryptionAlgorithmRSAPKCS1v15:
+		info.EncryptedKey, err = rsa.EncryptPKCS1v15(rand.Reader, pub, key)
+	case KeyEncryptionAlgorithmRSAOAEPSHA256:
+		sha256Alg := pkix.AlgorithmIdentifier{Algorithm: OIDDigestAlgorithmSHA256, Parameters: asn1.NullRawValue}
+		mgfParams, err := asn1.Marshal(sha256Alg)
+		if err != nil {
+			return nil, err
+		}
+		params, err := asn1.Marshal(rsaOAEPParameters{
+			HashFunc:    sha256Alg,
+			MaskGenFunc: pkix.AlgorithmIdentifier{Algorithm: OIDMaskGenFunctionMGF1, Parameters: asn1.RawValue{FullBytes: mgfParams}},
+		})
+		if err != nil {
+			return nil, err
+		}
+		info.KeyEncryptionAlgorithm = pkix.AlgorithmIdentifier{
+			Algorithm:  OIDEncryptionAlgorithmRSAESOAEP,
+			Parameters: asn1.RawValue{FullBytes: params},
+		}
+		info.EncryptedKey, err = rsa.EncryptOAEP(sha256.New(), rand.Reader, pub, key, nil)
+		if err != nil {
+			return nil, err
+		}
+	default:
+		return nil, fmt.Errorf("pkcs7: invalid key encryption algorithm: %d", algorithm)
+	}
+	if err != nil {
+		return nil, err
 	}
-	return nil, ErrUnsupportedAlgorithm
+	return &info, nil
 }
 
 func pad(data []byte, blocklen int) ([]byte, error) {
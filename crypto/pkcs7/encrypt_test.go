@@ -0,0 +1,276 @@
+package pkcs7
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"math/big"
+	"testing"
+	"time"
+)
+
+var testContent = []byte("Enveloped content of more than a single block, to be encrypted.")
+
+func testRecipient(t *testing.T, key crypto.Signer) *x509.Certificate {
+	t.Helper()
+	serial, err := rand.Int(rand.Reader, big.NewInt(1<<62))
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: serial,
+		Subject:      pkix.Name{CommonName: "recipient"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageKeyEncipherment | x509.KeyUsageKeyAgreement | x509.KeyUsageDigitalSignature,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, key.Public(), key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return cert
+}
+
+func testRSAKey(t *testing.T) *rsa.PrivateKey {
+	t.Helper()
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return key
+}
+
+func testECKey(t *testing.T, curve elliptic.Curve) *ecdsa.PrivateKey {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(curve, rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return key
+}
+
+// decrypterOnly hides everything of a key but crypto.Decrypter, as a key kept
+// in an HSM would.
+type decrypterOnly struct {
+	crypto.Decrypter
+}
+
+// recipientInfos returns the recipient infos of enveloped data.
+func recipientInfos(t *testing.T, der []byte) []asn1.RawValue {
+	t.Helper()
+	p7, err := Parse(der)
+	if err != nil {
+		t.Fatalf("Parse() = %v", err)
+	}
+	data, ok := p7.raw.(envelopedData)
+	if !ok {
+		t.Fatalf("content %T, want enveloped data", p7.raw)
+	}
+	return data.RecipientInfos
+}
+
+func decryptTest(t *testing.T, der []byte, cert *x509.Certificate, key crypto.PrivateKey) ([]byte, error) {
+	t.Helper()
+	p7, err := Parse(der)
+	if err != nil {
+		t.Fatalf("Parse() = %v", err)
+	}
+	return p7.Decrypt(cert, key)
+}
+
+func TestEncryptKeyTransport(t *testing.T) {
+	key := testRSAKey(t)
+	cert := testRecipient(t, key)
+	for _, tc := range []struct {
+		keyAlgorithm int
+		oid          asn1.ObjectIdentifier
+	}{
+		{KeyEncryptionAlgorithmRSAOAEPSHA256, OIDEncryptionAlgorithmRSAESOAEP},
+		{KeyEncryptionAlgorithmRSAPKCS1v15, OIDEncryptionAlgorithmRSA},
+	} {
+		for _, algorithm := range []int{EncryptionAlgorithmAES128CBC, EncryptionAlgorithmAES256CBC, EncryptionAlgorithmAES128GCM, EncryptionAlgorithmAES256GCM, EncryptionAlgorithmDESCBC} {
+			der, err := EncryptWithAlgorithm(testContent, []*x509.Certificate{cert}, algorithm, tc.keyAlgorithm)
+			if err != nil {
+				t.Fatalf("%v/%d: EncryptWithAlgorithm() = %v", tc.oid, algorithm, err)
+			}
+
+			infos := recipientInfos(t, der)
+			if len(infos) != 1 {
+				t.Fatalf("%v/%d: %d recipient infos, want 1", tc.oid, algorithm, len(infos))
+			}
+			var ri recipientInfo
+			if _, err := asn1.Unmarshal(infos[0].FullBytes, &ri); err != nil {
+				t.Fatalf("%v/%d: recipient info: %v", tc.oid, algorithm, err)
+			}
+			if !ri.KeyEncryptionAlgorithm.Algorithm.Equal(tc.oid) {
+				t.Errorf("%v/%d: key encryption algorithm %v", tc.oid, algorithm, ri.KeyEncryptionAlgorithm.Algorithm)
+			}
+
+			for name, pkey := range map[string]crypto.PrivateKey{"rsa.PrivateKey": key, "crypto.Decrypter": decrypterOnly{key}} {
+				content, err := decryptTest(t, der, cert, pkey)
+				if err != nil {
+					t.Errorf("%v/%d: Decrypt() with a %s = %v", tc.oid, algorithm, name, err)
+					continue
+				}
+				if !bytes.Equal(content, testContent) {
+					t.Errorf("%v/%d: Decrypt() with a %s = %q", tc.oid, algorithm, name, content)
+				}
+			}
+		}
+	}
+}
+
+func TestEncryptDefaultsToOAEP(t *testing.T) {
+	defer func(algorithm int) { ContentEncryptionAlgorithm = algorithm }(ContentEncryptionAlgorithm)
+	ContentEncryptionAlgorithm = EncryptionAlgorithmAES256GCM
+
+	key := testRSAKey(t)
+	cert := testRecipient(t, key)
+	der, err := Encrypt(testContent, []*x509.Certificate{cert})
+	if err != nil {
+		t.Fatalf("Encrypt() = %v", err)
+	}
+	var ri recipientInfo
+	if _, err := asn1.Unmarshal(recipientInfos(t, der)[0].FullBytes, &ri); err != nil {
+		t.Fatal(err)
+	}
+	if !ri.KeyEncryptionAlgorithm.Algorithm.Equal(OIDEncryptionAlgorithmRSAESOAEP) {
+		t.Errorf("key encryption algorithm %v, want RSAES-OAEP", ri.KeyEncryptionAlgorithm.Algorithm)
+	}
+	opts, err := parseOAEPParameters(ri.KeyEncryptionAlgorithm.Parameters.FullBytes)
+	if err != nil {
+		t.Fatalf("parseOAEPParameters() = %v", err)
+	}
+	if opts.Hash != crypto.SHA256 || opts.MGFHash != crypto.SHA256 || len(opts.Label) != 0 {
+		t.Errorf("OAEP parameters %+v, want SHA-256 and MGF1 with SHA-256", opts)
+	}
+
+	if _, err := EncryptWithAlgorithm(testContent, []*x509.Certificate{cert}, EncryptionAlgorithmAES256GCM, -1); err == nil {
+		t.Error("EncryptWithAlgorithm() with an unknown key encryption algorithm succeeded")
+	}
+}
+
+func TestParseOAEPParametersDefaults(t *testing.T) {
+	opts, err := parseOAEPParameters(nil)
+	if err != nil {
+		t.Fatalf("parseOAEPParameters() = %v", err)
+	}
+	if opts.Hash != crypto.SHA1 || opts.MGFHash != crypto.SHA1 {
+		t.Errorf("OAEP parameters %+v, want SHA-1 and MGF1 with SHA-1", opts)
+	}
+
+	// RSAES-OAEP without parameters, as written by other implementations
+	key := testRSAKey(t)
+	cert := testRecipient(t, key)
+	encryptedKey, err := rsa.EncryptOAEP(crypto.SHA1.New(), rand.Reader, &key.PublicKey, bytes.Repeat([]byte{1}, 32), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ri := recipientInfo{
+		IssuerAndSerialNumber:  cert2issuerAndSerial(cert),
+		KeyEncryptionAlgorithm: pkix.AlgorithmIdentifier{Algorithm: OIDEncryptionAlgorithmRSAESOAEP},
+		EncryptedKey:           encryptedKey,
+	}
+	contentKey, err := ri.decryptKey(decrypterOnly{key})
+	if err != nil {
+		t.Fatalf("decryptKey() = %v", err)
+	}
+	if !bytes.Equal(contentKey, bytes.Repeat([]byte{1}, 32)) {
+		t.Errorf("decryptKey() = %x", contentKey)
+	}
+}
+
+func TestEncryptKeyAgreement(t *testing.T) {
+	for _, curve := range []elliptic.Curve{elliptic.P256(), elliptic.P384()} {
+		name := curve.Params().Name
+		key := testECKey(t, curve)
+		cert := testRecipient(t, key)
+		der, err := EncryptWithAlgorithm(testContent, []*x509.Certificate{cert}, EncryptionAlgorithmAES256GCM, KeyEncryptionAlgorithmRSAOAEPSHA256)
+		if err != nil {
+			t.Fatalf("%s: EncryptWithAlgorithm() = %v", name, err)
+		}
+
+		infos := recipientInfos(t, der)
+		if len(infos) != 1 || infos[0].Class != asn1.ClassContextSpecific || infos[0].Tag != 1 {
+			t.Fatalf("%s: recipient infos %v, want a single [1] KeyAgreeRecipientInfo", name, infos)
+		}
+		var kari keyAgreeRecipientInfo
+		if _, err := asn1.UnmarshalWithParams(infos[0].FullBytes, &kari, "tag:1"); err != nil {
+			t.Fatalf("%s: KeyAgreeRecipientInfo: %v", name, err)
+		}
+		if kari.Version != 3 || len(kari.RecipientEncryptedKeys) != 1 {
+			t.Errorf("%s: KeyAgreeRecipientInfo version %d with %d keys", name, kari.Version, len(kari.RecipientEncryptedKeys))
+		}
+
+		ecdhKey, err := key.ECDH()
+		if err != nil {
+			t.Fatal(err)
+		}
+		for keyName, pkey := range map[string]crypto.PrivateKey{"ecdsa.PrivateKey": key, "KeyAgreer": ecdhKey} {
+			content, err := decryptTest(t, der, cert, pkey)
+			if err != nil {
+				t.Errorf("%s: Decrypt() with a %s = %v", name, keyName, err)
+				continue
+			}
+			if !bytes.Equal(content, testContent) {
+				t.Errorf("%s: Decrypt() with a %s = %q", name, keyName, content)
+			}
+		}
+
+		other := testECKey(t, curve)
+		if _, err := decryptTest(t, der, cert, other); err == nil {
+			t.Errorf("%s: Decrypt() with another key succeeded", name)
+		}
+	}
+
+	// the AES key wrap needs content keys of 16 bytes or more
+	key := testECKey(t, elliptic.P256())
+	if _, err := EncryptWithAlgorithm(testContent, []*x509.Certificate{testRecipient(t, key)}, EncryptionAlgorithmDESCBC, KeyEncryptionAlgorithmRSAOAEPSHA256); err == nil {
+		t.Error("EncryptWithAlgorithm() with DES-CBC for an EC recipient succeeded")
+	}
+	if _, err := EncryptWithAlgorithm(testContent, []*x509.Certificate{testRecipient(t, testECKey(t, elliptic.P224()))}, EncryptionAlgorithmAES256GCM, KeyEncryptionAlgorithmRSAOAEPSHA256); err == nil {
+		t.Error("EncryptWithAlgorithm() for a P-224 recipient succeeded")
+	}
+}
+
+func TestEncryptMultipleRecipients(t *testing.T) {
+	rsaKey := testRSAKey(t)
+	ecKey := testECKey(t, elliptic.P256())
+	rsaCert, ecCert := testRecipient(t, rsaKey), testRecipient(t, ecKey)
+	der, err := EncryptWithAlgorithm(testContent, []*x509.Certificate{rsaCert, ecCert}, EncryptionAlgorithmAES128GCM, KeyEncryptionAlgorithmRSAOAEPSHA256)
+	if err != nil {
+		t.Fatalf("EncryptWithAlgorithm() = %v", err)
+	}
+	for _, tc := range []struct {
+		cert *x509.Certificate
+		key  crypto.PrivateKey
+	}{
+		{rsaCert, rsaKey},
+		{ecCert, ecKey},
+	} {
+		content, err := decryptTest(t, der, tc.cert, tc.key)
+		if err != nil {
+			t.Errorf("%T: Decrypt() = %v", tc.key, err)
+			continue
+		}
+		if !bytes.Equal(content, testContent) {
+			t.Errorf("%T: Decrypt() = %q", tc.key, content)
+		}
+	}
+
+	// a certificate that is not among the recipients
+	otherKey := testRSAKey(t)
+	if _, err := decryptTest(t, der, testRecipient(t, otherKey), otherKey); err == nil {
+		t.Error("Decrypt() for another certificate succeeded")
+	}
+}
@@ -83,6 +83,22 @@ var (
 	OIDEncryptionAlgorithmAES128GCM  = asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 1, 6}
 	OIDEncryptionAlgorithmAES128CBC  = asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 1, 2}
 	OIDEncryptionAlgorithmAES256GCM  = asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 1, 46}
+
+	// Key Transport and Key Agreement Algorithms
+	OIDEncryptionAlgorithmRSAESOAEP = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 1, 7}
+	OIDMaskGenFunctionMGF1          = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 1, 8}
+	OIDPSourceSpecified             = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 1, 9}
+	OIDPublicKeyECDSA               = asn1.ObjectIdentifier{1, 2, 840, 10045, 2, 1}
+
+	OIDKeyAgreementECDHSHA1KDF   = asn1.ObjectIdentifier{1, 3, 133, 16, 840, 63, 0, 2}
+	OIDKeyAgreementECDHSHA256KDF = asn1.ObjectIdentifier{1, 3, 132, 1, 11, 1}
+	OIDKeyAgreementECDHSHA384KDF = asn1.ObjectIdentifier{1, 3, 132, 1, 11, 2}
+	OIDKeyAgreementECDHSHA512KDF = asn1.ObjectIdentifier{1, 3, 132, 1, 11, 3}
+
+	// Key Wrap Algorithms
+	OIDKeyWrapAES128 = asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 1, 5}
+	OIDKeyWrapAES192 = asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 1, 25}
+	OIDKeyWrapAES256 = asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 1, 45}
 )
 
 func getHashForOID(oid asn1.ObjectIdentifier) (crypto.Hash, error) {
@@ -11,6 +11,8 @@ import (
 	"encoding/asn1"
 	"errors"
 	"fmt"
+
+	"github.com/unix-world/smartgoext/crypto/crystals/dilithium"
 )
 
 // DefaultOpts are the default options for encrypting a key if none are given.
@@ -130,13 +132,31 @@ func parseEncryptionScheme(encryptionScheme pkix.AlgorithmIdentifier) (Cipher, [
 	return cipher, iv, nil
 }
 
+// parsePKCS8 parses an unencrypted PKCS#8 key, Dilithium (ML-DSA) keys
+// included, which crypto/x509 does not know about.
+func parsePKCS8(der []byte) (interface{}, error) {
+	var info privateKeyInfo
+	if _, err := asn1.Unmarshal(der, &info); err == nil && dilithium.SchemeByOID(info.PrivateKeyAlgorithm.Algorithm) != nil {
+		return dilithium.ParsePKCS8PrivateKey(der)
+	}
+	return x509.ParsePKCS8PrivateKey(der)
+}
+
+// marshalPKCS8 is the counterpart of parsePKCS8.
+func marshalPKCS8(priv interface{}) ([]byte, error) {
+	if key, ok := priv.(*dilithium.SigningKey); ok {
+		return dilithium.MarshalPKCS8PrivateKey(key)
+	}
+	return x509.MarshalPKCS8PrivateKey(priv)
+}
+
 // ParsePrivateKey parses a DER-encoded PKCS#8 private key.
 // Password can be nil.
 // This is equivalent to ParsePKCS8PrivateKey.
 func ParsePrivateKey(der []byte, password []byte) (interface{}, KDFParameters, error) {
 	// No password provided, assume the private key is unencrypted
 	if len(password) == 0 {
-		privateKey, err := x509.ParsePKCS8PrivateKey(der)
+		privateKey, err := parsePKCS8(der)
 		return privateKey, nil, err
 	}
 
@@ -177,7 +197,7 @@ func ParsePrivateKey(der []byte, password []byte) (interface{}, KDFParameters, e
 		return nil, nil, err
 	}
 
-	key, err := x509.ParsePKCS8PrivateKey(decryptedKey)
+	key, err := parsePKCS8(decryptedKey)
 	if err != nil {
 		return nil, nil, errors.New("pkcs8: incorrect password")
 	}
@@ -188,7 +208,7 @@ func ParsePrivateKey(der []byte, password []byte) (interface{}, KDFParameters, e
 // Password can be nil.
 func MarshalPrivateKey(priv interface{}, password []byte, opts *Opts) ([]byte, error) {
 	if len(password) == 0 {
-		return x509.MarshalPKCS8PrivateKey(priv)
+		return marshalPKCS8(priv)
 	}
 
 	if opts == nil {
@@ -196,7 +216,7 @@ func MarshalPrivateKey(priv interface{}, password []byte, opts *Opts) ([]byte, e
 	}
 
 	// Convert private key into PKCS8 format
-	pkey, err := x509.MarshalPKCS8PrivateKey(priv)
+	pkey, err := marshalPKCS8(priv)
 	if err != nil {
 		return nil, err
 	}
@@ -296,10 +316,24 @@ func ParsePKCS8PrivateKeyECDSA(der []byte, v ...[]byte) (*ecdsa.PrivateKey, erro
 	return typedKey, nil
 }
 
+// ParsePKCS8PrivateKeyDilithium parses encrypted/unencrypted Dilithium (ML-DSA) private keys in PKCS#8 format. To parse encrypted private keys, a password of []byte type should be provided to the function as the second parameter.
+func ParsePKCS8PrivateKeyDilithium(der []byte, v ...[]byte) (*dilithium.SigningKey, error) {
+	key, err := ParsePKCS8PrivateKey(der, v...)
+	if err != nil {
+		return nil, err
+	}
+	typedKey, ok := key.(*dilithium.SigningKey)
+	if !ok {
+		return nil, errors.New("key block is not of type Dilithium")
+	}
+	return typedKey, nil
+}
+
 // ConvertPrivateKeyToPKCS8 converts the private key into PKCS#8 format.
 // To encrypt the private key, the password of []byte type should be provided as the second parameter.
 //
-// The only supported key types are RSA and ECDSA (*rsa.PrivateKey or *ecdsa.PrivateKey for priv)
+// The supported key types are the ones of crypto/x509 (*rsa.PrivateKey, *ecdsa.PrivateKey, ed25519.PrivateKey, *ecdh.PrivateKey)
+// and Dilithium (*dilithium.SigningKey)
 func ConvertPrivateKeyToPKCS8(priv interface{}, v ...[]byte) ([]byte, error) {
 	var password []byte
 	if len(v) > 0 {
@@ -0,0 +1,106 @@
+package dbf
+
+import (
+	"errors"
+	"strings"
+	"unicode/utf8"
+
+	"golang.org/x/text/encoding/charmap"
+)
+
+// CodePageUTF8 is the code page of UTF-8 text, which has the non standard language
+// driver F0h.
+const CodePageUTF8 = 65001
+
+// languageDrivers maps the language driver byte of the header to its code page.
+var languageDrivers = map[byte]int{
+	0x01: 437,  // US MS-DOS
+	0x02: 850,  // International MS-DOS
+	0x03: 1252, // Windows ANSI
+	0x57: 1252, // ANSI
+	0x58: 1252, // Western European ANSI
+	0x59: 1252, // Spanish ANSI
+	0x64: 852,  // Eastern European MS-DOS
+	0x65: 866,  // Russian MS-DOS
+	0xC8: 1250, // Eastern European Windows
+	0xC9: 1251, // Russian Windows
+	0xF0: CodePageUTF8,
+}
+
+var codePages = map[int]*charmap.Charmap{
+	437:  charmap.CodePage437,
+	850:  charmap.CodePage850,
+	852:  charmap.CodePage852,
+	866:  charmap.CodePage866,
+	1250: charmap.Windows1250,
+	1251: charmap.Windows1251,
+	1252: charmap.Windows1252,
+}
+
+// languageDriver returns the language driver byte of a code page.
+func languageDriver(codePage int) (byte, error) {
+	switch codePage {
+	case 0:
+		return 0x00, nil
+	case 1252:
+		return 0x03, nil
+	}
+	for ldid, cp := range languageDrivers {
+		if cp == codePage {
+			return ldid, nil
+		}
+	}
+	return 0, errors.New("dbf: unsupported code page")
+}
+
+// decoder translates text between a code page and UTF-8, a nil charmap keeps the bytes.
+type decoder struct {
+	charmap *charmap.Charmap
+}
+
+func newDecoder(codePage int) (decoder, error) {
+	if codePage == 0 || codePage == CodePageUTF8 {
+		return decoder{}, nil
+	}
+	cm, ok := codePages[codePage]
+	if !ok {
+		return decoder{}, errors.New("dbf: unsupported code page")
+	}
+	return decoder{cm}, nil
+}
+
+func (d decoder) decode(b []byte) string {
+	if d.charmap == nil {
+		return string(b)
+	}
+
+	var sb strings.Builder
+	sb.Grow(len(b))
+	for _, c := range b {
+		if c < utf8.RuneSelf {
+			sb.WriteByte(c)
+		} else {
+			sb.WriteRune(d.charmap.DecodeByte(c))
+		}
+	}
+	return sb.String()
+}
+
+// encode replaces characters which do not exist in the code page with '?'.
+func (d decoder) encode(s string) []byte {
+	if d.charmap == nil {
+		return []byte(s)
+	}
+
+	b := make([]byte, 0, len(s))
+	for _, r := range s {
+		if r < utf8.RuneSelf {
+			b = append(b, byte(r))
+		} else if c, ok := d.charmap.EncodeRune(r); ok {
+			b = append(b, c)
+		} else {
+			b = append(b, '?')
+		}
+	}
+	return b
+}
@@ -0,0 +1,620 @@
+package dbf
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/unix-world/smartgoext/db/xorm-builder"
+)
+
+// DeletedColumn is the column which marks deleted records in exports and imports
+// with the WithDeleted option.
+const DeletedColumn = "_DELETED"
+
+// ConvertOption configures the exports and imports of a table.
+type ConvertOption func(*convertConfig)
+
+type convertConfig struct {
+	fields    []string
+	deleted   bool
+	table     string
+	batchSize int
+}
+
+// WithFields selects the fields of an export or the columns of an import by name and
+// sets their order, all fields are converted by default.
+func WithFields(names ...string) ConvertOption {
+	return func(c *convertConfig) {
+		c.fields = names
+	}
+}
+
+// WithDeleted includes the deleted records, which are skipped by default. They are
+// marked by the DeletedColumn in front of the fields. ImportCSV marks the records as
+// deleted whose DeletedColumn is true.
+func WithDeleted() ConvertOption {
+	return func(c *convertConfig) {
+		c.deleted = true
+	}
+}
+
+// WithTable sets the table name of ExportSQL, the default is "data".
+func WithTable(name string) ConvertOption {
+	return func(c *convertConfig) {
+		c.table = name
+	}
+}
+
+// WithBatchSize sets the number of records per INSERT of ExportSQL, the default is 100.
+func WithBatchSize(n int) ConvertOption {
+	return func(c *convertConfig) {
+		c.batchSize = n
+	}
+}
+
+func newConvertConfig(opts []ConvertOption) *convertConfig {
+	c := &convertConfig{table: "data", batchSize: 100}
+	for _, opt := range opts {
+		opt(c)
+	}
+	if c.batchSize <= 0 {
+		c.batchSize = 1
+	}
+	return c
+}
+
+// exportFields returns the indexes of the exported fields.
+func (dt *DbfTable) exportFields(c *convertConfig) ([]int, error) {
+	if len(c.fields) <= 0 {
+		indexes := make([]int, len(dt.fields))
+		for i := range indexes {
+			indexes[i] = i
+		}
+		return indexes, nil
+	}
+
+	indexes := make([]int, 0, len(c.fields))
+	for _, name := range c.fields {
+		index := -1
+		for i := range dt.fields {
+			if strings.EqualFold(dt.fields[i].Name, name) {
+				index = i
+				break
+			}
+		}
+		if index < 0 {
+			return nil, fmt.Errorf("dbf: field '%s' does not exist", name)
+		}
+		indexes = append(indexes, index)
+	}
+	return indexes, nil
+}
+
+// exportRows calls fn with each exported record.
+func (dt *DbfTable) exportRows(c *convertConfig, fn func(row int, deleted bool) error) error {
+	for row := 0; row < dt.NumRecords(); row++ {
+		deleted := dt.IsDeleted(row)
+		if deleted && !c.deleted {
+			continue
+		}
+		if err := fn(row, deleted); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// TypedFieldValue returns the value of a field as int64 or float64 for numbers, bool
+// for logicals, time.Time for dates and string for other types, which is decoded from the
+// code page of the table. Empty numbers, logicals and dates are nil, values which could
+// not be parsed are returned as string.
+func (dt *DbfTable) TypedFieldValue(row int, fieldIndex int) interface{} {
+	field := &dt.fields[fieldIndex]
+	value := dt.FieldValue(row, fieldIndex)
+
+	switch field.Type {
+	case "N", "F":
+		if value == "" {
+			return nil
+		}
+		if field.Precision == 0 {
+			if n, err := strconv.ParseInt(value, 10, 64); err == nil {
+				return n
+			}
+		}
+		if f, err := strconv.ParseFloat(value, 64); err == nil {
+			return f
+		}
+	case "L":
+		switch value {
+		case "T", "t", "Y", "y":
+			return true
+		case "F", "f", "N", "n":
+			return false
+		}
+		return nil
+	case "D":
+		if value == "" {
+			return nil
+		}
+		if t, err := time.Parse("20060102", value); err == nil {
+			return t
+		}
+	case "C", "M":
+		return dt.decoder.decode([]byte(value))
+	}
+	return value
+}
+
+// ExportCSV writes a header with the field names and a line for each record.
+// Dates are written as YYYY-MM-DD and logicals as T or F.
+func (dt *DbfTable) ExportCSV(w io.Writer, opts ...ConvertOption) error {
+	c := newConvertConfig(opts)
+	indexes, err := dt.exportFields(c)
+	if err != nil {
+		return err
+	}
+
+	cw := csv.NewWriter(w)
+	header := make([]string, 0, len(indexes)+1)
+	if c.deleted {
+		header = append(header, DeletedColumn)
+	}
+	for _, i := range indexes {
+		header = append(header, dt.fields[i].Name)
+	}
+	if err := cw.Write(header); err != nil {
+		return err
+	}
+
+	record := make([]string, len(header))
+	err = dt.exportRows(c, func(row int, deleted bool) error {
+		values := record[:0]
+		if c.deleted {
+			values = append(values, formatCSV(deleted))
+		}
+		for _, i := range indexes {
+			values = append(values, formatCSV(dt.TypedFieldValue(row, i)))
+		}
+		return cw.Write(values)
+	})
+	if err != nil {
+		return err
+	}
+
+	cw.Flush()
+	return cw.Error()
+}
+
+func formatCSV(value interface{}) string {
+	switch v := value.(type) {
+	case nil:
+		return ""
+	case bool:
+		if v {
+			return "T"
+		}
+		return "F"
+	case time.Time:
+		return v.Format("2006-01-02")
+	}
+	return fmt.Sprint(value)
+}
+
+// ExportJSONLines writes a JSON object for each record, with the fields as keys in
+// their order and the values of TypedFieldValue. Dates are written as YYYY-MM-DD.
+func (dt *DbfTable) ExportJSONLines(w io.Writer, opts ...ConvertOption) error {
+	c := newConvertConfig(opts)
+	indexes, err := dt.exportFields(c)
+	if err != nil {
+		return err
+	}
+
+	keys := make([][]byte, len(indexes))
+	for j, i := range indexes {
+		if keys[j], err = json.Marshal(dt.fields[i].Name); err != nil {
+			return err
+		}
+	}
+
+	bw := bufio.NewWriter(w)
+	err = dt.exportRows(c, func(row int, deleted bool) error {
+		bw.WriteByte('{')
+		if c.deleted {
+			fmt.Fprintf(bw, "%q:%t", DeletedColumn, deleted)
+			if len(keys) > 0 {
+				bw.WriteByte(',')
+			}
+		}
+		for j, i := range indexes {
+			if j > 0 {
+				bw.WriteByte(',')
+			}
+			bw.Write(keys[j])
+			bw.WriteByte(':')
+
+			value := dt.TypedFieldValue(row, i)
+			if t, ok := value.(time.Time); ok {
+				value = t.Format("2006-01-02")
+			}
+			data, err := json.Marshal(value)
+			if err != nil {
+				return err
+			}
+			bw.Write(data)
+		}
+		_, err := bw.WriteString("}\n")
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	return bw.Flush()
+}
+
+// ExportSQL writes a CREATE TABLE statement for the fields and INSERT statements which
+// insert up to the batch size of records each, with identifiers and literals written for
+// dialect, which is one of the dialects of xorm-builder.
+func (dt *DbfTable) ExportSQL(w io.Writer, dialect string, opts ...ConvertOption) error {
+	c := newConvertConfig(opts)
+	indexes, err := dt.exportFields(c)
+	if err != nil {
+		return err
+	}
+
+	cols := make([]builder.Column, 0, len(indexes)+1)
+	if c.deleted {
+		name := DeletedColumn
+		if dialect == builder.ORACLE {
+			// Oracle identifiers must start with a letter unless quoted
+			name = `"` + name + `"`
+		}
+		cols = append(cols, builder.Column{Name: name, Type: builder.TypeBool, NotNull: true})
+	}
+	for _, i := range indexes {
+		cols = append(cols, sqlColumn(&dt.fields[i]))
+	}
+
+	create, err := builder.Dialect(dialect).QuotePolicy(builder.QuotePolicyReserved).CreateTable(c.table, cols...).ToBoundSQL()
+	if err != nil {
+		return err
+	}
+
+	bw := bufio.NewWriter(w)
+	if _, err := bw.WriteString(create + ";\n"); err != nil {
+		return err
+	}
+
+	quoter := builder.NewQuoter(dialect, builder.QuotePolicyReserved)
+	names := make([]string, len(cols))
+	for i, col := range cols {
+		names[i] = quoter.Quote(col.Name)
+	}
+	into := fmt.Sprintf("INTO %s (%s)", quoter.Quote(c.table), strings.Join(names, ","))
+
+	var batch []string
+	flush := func() error {
+		if len(batch) <= 0 {
+			return nil
+		}
+		var err error
+		if dialect == builder.ORACLE {
+			// Oracle inserts multiple rows only with INSERT ALL
+			_, err = fmt.Fprintf(bw, "INSERT ALL %s VALUES %s SELECT 1 FROM DUAL;\n", into, strings.Join(batch, " "+into+" VALUES "))
+		} else {
+			_, err = fmt.Fprintf(bw, "INSERT %s VALUES %s;\n", into, strings.Join(batch, ","))
+		}
+		batch = batch[:0]
+		return err
+	}
+
+	values := make([]string, 0, len(cols))
+	err = dt.exportRows(c, func(row int, deleted bool) error {
+		values = values[:0]
+		if c.deleted {
+			values = append(values, sqlLiteral(dialect, deleted))
+		}
+		for _, i := range indexes {
+			values = append(values, sqlLiteral(dialect, dt.TypedFieldValue(row, i)))
+		}
+		batch = append(batch, "("+strings.Join(values, ",")+")")
+		if len(batch) >= c.batchSize {
+			return flush()
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	if err := flush(); err != nil {
+		return err
+	}
+	return bw.Flush()
+}
+
+// sqlColumn returns the column of a field.
+func sqlColumn(field *DbfField) builder.Column {
+	col := builder.Column{Name: field.Name}
+	switch field.Type {
+	case "C":
+		col.Type, col.Length = builder.TypeVarchar, int(field.Length)
+	case "N":
+		switch {
+		case field.Precision > 0:
+			col.Type, col.Length, col.Scale = builder.TypeDecimal, int(field.Length), int(field.Precision)
+		case field.Length <= 9:
+			col.Type = builder.TypeInt
+		case field.Length <= 18:
+			col.Type = builder.TypeBigInt
+		default:
+			col.Type, col.Length = builder.TypeDecimal, int(field.Length)
+		}
+	case "F":
+		col.Type = builder.TypeFloat
+	case "L":
+		col.Type = builder.TypeBool
+	case "D":
+		col.Type = builder.TypeDate
+	default:
+		col.Type = builder.TypeText
+	}
+	return col
+}
+
+// sqlLiteral returns a value of TypedFieldValue as literal of dialect.
+func sqlLiteral(dialect string, value interface{}) string {
+	switch v := value.(type) {
+	case nil:
+		return "NULL"
+	case int64:
+		return strconv.FormatInt(v, 10)
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case bool:
+		if dialect == builder.POSTGRES {
+			return strings.ToUpper(strconv.FormatBool(v))
+		}
+		if v {
+			return "1"
+		}
+		return "0"
+	case time.Time:
+		date := "'" + v.Format("2006-01-02") + "'"
+		switch dialect {
+		case builder.POSTGRES, builder.MYSQL, builder.ORACLE:
+			return "DATE " + date
+		}
+		return date
+	}
+
+	s := strings.Replace(fmt.Sprint(value), "'", "''", -1)
+	switch dialect {
+	case builder.MYSQL:
+		// MySQL treats backslashes in strings as escapes
+		s = strings.Replace(s, `\`, `\\`, -1)
+	case builder.MSSQL:
+		return "N'" + s + "'"
+	}
+	return "'" + s + "'"
+}
+
+// csvColumn collects the type of a column of ImportCSV.
+type csvColumn struct {
+	index     int
+	name      string
+	isInt     bool
+	isFloat   bool
+	isBool    bool
+	isDate    bool
+	length    int // length of text, digits in front of the point of numbers
+	precision int
+}
+
+func (col *csvColumn) infer(value string) {
+	if value == "" {
+		return
+	}
+	if col.isInt || col.isFloat {
+		sign := strings.HasPrefix(value, "-") || strings.HasPrefix(value, "+")
+		digits := strings.TrimLeft(value, "+-")
+		// numbers with leading zeros like zip codes are text
+		leadingZero := len(digits) > 1 && digits[0] == '0' && digits[1] != '.'
+		if col.isInt {
+			_, err := strconv.ParseInt(value, 10, 64)
+			col.isInt = err == nil && !leadingZero
+		}
+		if f, err := strconv.ParseFloat(value, 64); err != nil || math.IsInf(f, 0) || math.IsNaN(f) ||
+			leadingZero || strings.ContainsAny(digits, "eExXpP_") {
+			col.isFloat = false
+		} else {
+			integer, fraction := digits, ""
+			if dot := strings.IndexByte(digits, '.'); dot >= 0 {
+				integer, fraction = digits[:dot], digits[dot+1:]
+			}
+			length := len(integer)
+			if length <= 0 {
+				length = 1 // .5 is written as 0.5
+			}
+			if sign {
+				length++
+			}
+			if length > col.length {
+				col.length = length
+			}
+			if len(fraction) > col.precision {
+				col.precision = len(fraction)
+			}
+		}
+	}
+	if col.isBool {
+		_, ok := parseBool(value)
+		col.isBool = ok
+	}
+	if col.isDate {
+		_, ok := parseDate(value)
+		col.isDate = ok
+	}
+}
+
+func parseBool(value string) (bool, bool) {
+	switch strings.ToUpper(value) {
+	case "T", "Y", "TRUE", "YES":
+		return true, true
+	case "F", "N", "FALSE", "NO":
+		return false, true
+	}
+	return false, false
+}
+
+func parseDate(value string) (time.Time, bool) {
+	for _, layout := range []string{"2006-01-02", "20060102"} {
+		if t, err := time.Parse(layout, value); err == nil {
+			return t, true
+		}
+	}
+	return time.Time{}, false
+}
+
+// ImportCSV creates a table from CSV with a header of field names. The types of the
+// fields are inferred from the values: integers and decimals become numbers, T, F, Y, N,
+// true, false, yes and no become logicals, YYYY-MM-DD and YYYYMMDD become dates and all
+// other columns become text, which is truncated at 254 bytes. Field names are truncated
+// at 10 characters and must be unique.
+func ImportCSV(r io.Reader, opts ...ConvertOption) (*DbfTable, error) {
+	c := newConvertConfig(opts)
+
+	records, err := csv.NewReader(r).ReadAll()
+	if err != nil {
+		return nil, err
+	}
+	if len(records) <= 0 {
+		return nil, errors.New("dbf: missing CSV header")
+	}
+	header, records := records[0], records[1:]
+
+	deletedIndex := -1
+	var cols []*csvColumn
+	for i, name := range header {
+		if c.deleted && name == DeletedColumn {
+			deletedIndex = i
+			continue
+		}
+		cols = append(cols, &csvColumn{index: i, name: name})
+	}
+	if len(c.fields) > 0 {
+		selected := make([]*csvColumn, 0, len(c.fields))
+		for _, name := range c.fields {
+			var found *csvColumn
+			for _, col := range cols {
+				if strings.EqualFold(col.name, name) {
+					found = col
+					break
+				}
+			}
+			if found == nil {
+				return nil, fmt.Errorf("dbf: column '%s' does not exist", name)
+			}
+			selected = append(selected, found)
+		}
+		cols = selected
+	}
+
+	for _, col := range cols {
+		col.isInt, col.isFloat, col.isBool, col.isDate = true, true, true, true
+		textLength, empty := 1, true
+		for _, record := range records {
+			if col.index >= len(record) {
+				continue
+			}
+			value := record[col.index]
+			if value != "" {
+				empty = false
+			}
+			col.infer(value)
+			if len(value) > textLength {
+				textLength = len(value)
+			}
+		}
+
+		if empty {
+			col.isInt, col.isFloat, col.isBool, col.isDate = false, false, false, false
+		}
+		if (col.isInt || col.isFloat) && col.length+col.precision+1 > 20 {
+			// longer numbers lose their precision
+			col.isInt, col.isFloat = false, false
+		}
+		if !col.isInt && !col.isFloat {
+			col.length, col.precision = textLength, 0
+			if col.length > 254 {
+				col.length = 254
+			}
+		}
+	}
+
+	dt := New()
+	for _, col := range cols {
+		switch {
+		case col.isInt:
+			err = dt.AddIntField(col.name, uint8(col.length))
+		case col.isFloat:
+			err = dt.AddFloatField(col.name, uint8(col.length+col.precision+1), uint8(col.precision))
+		case col.isBool:
+			err = dt.AddBoolField(col.name)
+		case col.isDate:
+			err = dt.AddDateField(col.name)
+		default:
+			err = dt.AddTextField(col.name, uint8(col.length))
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	for _, record := range records {
+		row := dt.AddRecord()
+		for i, col := range cols {
+			if col.index >= len(record) || record[col.index] == "" {
+				continue
+			}
+			value := record[col.index]
+			switch {
+			case col.isInt:
+				n, _ := strconv.ParseInt(value, 10, 64)
+				value = strconv.FormatInt(n, 10)
+			case col.isFloat:
+				f, _ := strconv.ParseFloat(value, 64)
+				value = strconv.FormatFloat(f, 'f', col.precision, 64)
+			case col.isBool:
+				b, _ := parseBool(value)
+				value = formatCSV(b)
+			case col.isDate:
+				t, _ := parseDate(value)
+				value = t.Format("20060102")
+			default:
+				if len(value) > col.length {
+					n := col.length
+					for n > 0 && !utf8.RuneStart(value[n]) {
+						n--
+					}
+					value = value[:n]
+				}
+			}
+			dt.SetFieldValue(row, i, value)
+		}
+		if deletedIndex >= 0 && deletedIndex < len(record) {
+			if deleted, _ := parseBool(record[deletedIndex]); deleted {
+				dt.Delete(row)
+			}
+		}
+	}
+	return dt, nil
+}
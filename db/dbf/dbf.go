@@ -41,6 +41,10 @@ type DbfTable struct {
 	dataStore []byte
 	// keeps the dbase memo data in memory as a byte array
 	memoStore []byte
+	// the memo data is a FoxPro .fpt file rather than a .dbt file
+	memoFpt bool
+	// decodes the text of the table code page in the exports
+	decoder decoder
 }
 
 type DbfField struct {
@@ -48,7 +52,12 @@ type DbfField struct {
 	Type       string
 	Length     uint8
 	Precision  uint8
+	Nullable   bool // Visual FoxPro field which could be NULL
 	fieldStore [32]byte
+	offset     int  // offset of the field in the record
+	flags      byte // Visual FoxPro field flags
+	nullBit    int  // bit of the field in _NullFlags, -1 if none
+	varBit     int  // bit of a Varchar or Varbinary field in _NullFlags, -1 if none
 }
 
 // Create a new dbase table from the scratch
@@ -92,7 +101,7 @@ func (df *DbfField) SetFieldName(fieldName string) {
 
 // LoadFile load dBase III+ from file.
 func LoadFile(fileName string) (table *DbfTable, err error) {
-	data, memo, err := readFile(fileName)
+	data, memo, memoFpt, err := readFile(fileName)
 	if err != nil {
 		return nil, err
 	}
@@ -102,6 +111,10 @@ func LoadFile(fileName string) (table *DbfTable, err error) {
 	// set DbfTable dataStore slice that will store the complete file in memory
 	dt.dataStore = data
 	dt.memoStore = memo
+	dt.memoFpt = memoFpt
+	if dt.decoder, err = newDecoder(languageDrivers[data[29]]); err != nil {
+		dt.decoder, err = decoder{}, nil
+	}
 
 	// read dbase table header information
 	dt.fileSignature = data[0]
@@ -329,14 +342,21 @@ func (dt *DbfTable) readMemoBlock(indexStr string) string {
 		log.Println("Invalid memo block index", indexStr, err)
 		return ""
 	}
-
-	for i := blockIndex * 512; i < len(dt.memoStore); i++ {
-		if dt.memoStore[i] == 0x1A {
-			return string(dt.memoStore[blockIndex*512 : i])
-		}
+	if blockIndex == 0 {
+		return ""
 	}
 
-	return ""
+	mr, err := newMemoReader(bytes.NewReader(dt.memoStore), dt.memoFpt)
+	if err != nil {
+		log.Println("Invalid memo file", err)
+		return ""
+	}
+	memo, err := mr.read(int64(blockIndex))
+	if err != nil {
+		log.Println("Invalid memo block", indexStr, err)
+		return ""
+	}
+	return string(memo)
 }
 
 // FieldValueByName returns the value of a field given row number and fieldName provided.
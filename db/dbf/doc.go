@@ -1,5 +1,5 @@
 /*
-Package for working with dBase III plus database files.
+Package for working with dBase III plus, dBASE IV and Visual FoxPro database files.
 
 1. Package provides both reflection-via-struct interface and direct Row()/FieldValueByName()/AddxxxField() interface.
 2. Once table is created and rows added to it, table structure can not be modified.
@@ -18,9 +18,14 @@ The `omitempty` tag option works similarly to the encoding/json package.
 When omitempty is specified for a field, that field will only be written
 if its value is not the zero value for its type.
 
-TODO: File is loaded and kept in-memory. Not a good design choice if file is huge.
-This should be changed to use buffers and keep some of the data on-disk in the future.
-Current API structure should allow redesign.
+DbfTable loads and keeps the file in-memory, which is not a good choice if the file is huge.
+Reader and Writer stream the records of large files from and to disk instead. They support
+dBASE IV and Visual FoxPro tables with the types Integer, Currency, DateTime, Double, Varchar,
+Varbinary and NULL values, .fpt memos of any block size and the code page of the language
+driver byte.
+
+ExportCSV, ExportJSONLines and ExportSQL convert the records of a table, ImportCSV creates a
+table from CSV with the types of the fields inferred from the values.
 
 Typical usage
 db := dbf.New() or dbf.LoadFile(filename)
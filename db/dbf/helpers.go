@@ -14,42 +14,45 @@ func appendSlice(slice, data []byte) []byte {
 	return slice
 }
 
-func readFile(filename string) ([]byte, []byte, error) {
+func readFile(filename string) ([]byte, []byte, bool, error) {
 	f, err := os.Open(filename)
 	if err != nil {
-		return nil, nil, err
+		return nil, nil, false, err
 	}
 	defer f.Close()
 
 	data, err := ioutil.ReadAll(f)
 	if err != nil {
-		return nil, nil, err
+		return nil, nil, false, err
 	}
 
-	// Look for associated dbt file
+	// Look for associated fpt or dbt file
 	var (
 		memo []byte
+		fpt  bool
 
 		base = strings.TrimSuffix(filename, filepath.Ext(filename))
 	)
-	for _, dbtPath := range []string{base + ".dbt", base + ".DBT"} {
-		dbtFile, err := os.Open(dbtPath)
+	for _, memoPath := range []string{base + ".fpt", base + ".FPT", base + ".dbt", base + ".DBT"} {
+		memoFile, err := os.Open(memoPath)
 		if err != nil {
 			if os.IsNotExist(err) {
 				continue
 			}
 
-			return nil, nil, err
+			return nil, nil, false, err
 		}
 
-		memo, err = ioutil.ReadAll(dbtFile)
+		memo, err = ioutil.ReadAll(memoFile)
+		memoFile.Close()
 		if err != nil {
-			return nil, nil, err
+			return nil, nil, false, err
 		}
+		fpt = strings.EqualFold(filepath.Ext(memoPath), ".fpt")
 		break
 	}
 
-	return data, memo, err
+	return data, memo, fpt, nil
 }
 
 func uint32ToBytes(x uint32) []byte {
@@ -0,0 +1,195 @@
+package dbf
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	"os"
+)
+
+const (
+	dbtBlockSize = 512 // block size of dBASE III memo files
+	fptBlockSize = 64  // default block size of FoxPro memo files
+	fptTypeText  = 1   // FoxPro memo block of text, 0 is picture or binary data
+)
+
+// memoReader reads the blocks of a dBASE (.dbt) or FoxPro (.fpt) memo file.
+type memoReader struct {
+	r         io.ReaderAt
+	fpt       bool
+	blockSize int64
+	size      int64 // size of the memo file, -1 if unknown
+}
+
+// readerAtSize returns the size of the data of r, or -1 if r does not tell.
+func readerAtSize(r io.ReaderAt) int64 {
+	switch r := r.(type) {
+	case interface{ Size() int64 }:
+		return r.Size()
+	case interface{ Stat() (os.FileInfo, error) }:
+		if fi, err := r.Stat(); err == nil && fi.Mode().IsRegular() {
+			return fi.Size()
+		}
+	}
+	return -1
+}
+
+func newMemoReader(r io.ReaderAt, fpt bool) (*memoReader, error) {
+	var header [22]byte
+	if _, err := r.ReadAt(header[:], 0); err != nil && err != io.EOF {
+		return nil, err
+	}
+
+	mr := &memoReader{r: r, fpt: fpt, blockSize: dbtBlockSize, size: readerAtSize(r)}
+	if fpt {
+		mr.blockSize = int64(binary.BigEndian.Uint16(header[6:8]))
+	} else if size := binary.LittleEndian.Uint16(header[20:22]); size > 0 {
+		// dBASE IV stores the block size, dBASE III leaves it empty
+		mr.blockSize = int64(size)
+	}
+	if mr.blockSize <= 0 {
+		return nil, errors.New("dbf: invalid memo block size")
+	}
+	return mr, nil
+}
+
+// read returns the data of the memo starting at block. The length stored in the block
+// is checked against the size of the memo file, so a corrupt one could not make it
+// allocate more than the file holds.
+func (mr *memoReader) read(block int64) ([]byte, error) {
+	if block <= 0 || (mr.size >= 0 && block > mr.size/mr.blockSize) {
+		return nil, errors.New("dbf: invalid memo block")
+	}
+	offset := block * mr.blockSize
+
+	var header [8]byte
+	if _, err := mr.r.ReadAt(header[:], offset); err != nil && err != io.EOF {
+		return nil, err
+	}
+
+	var length int64
+	switch {
+	case mr.fpt:
+		length = int64(binary.BigEndian.Uint32(header[4:8]))
+		offset += 8
+	case bytes.Equal(header[:4], []byte{0xFF, 0xFF, 0x08, 0x00}):
+		// dBASE IV block, the length includes the block header
+		length = int64(binary.LittleEndian.Uint32(header[4:8])) - 8
+		offset += 8
+	default:
+		// dBASE III memo runs up to the end of text marker
+		return mr.readTerminated(offset)
+	}
+
+	if length < 0 || (mr.size >= 0 && length > mr.size-offset) {
+		return nil, errors.New("dbf: invalid memo block")
+	}
+	if mr.size < 0 {
+		// without the size the data grows as it is read, up to the end of the file
+		data, err := io.ReadAll(io.NewSectionReader(mr.r, offset, length))
+		if err == nil && int64(len(data)) < length {
+			err = io.ErrUnexpectedEOF
+		}
+		if err != nil {
+			return nil, err
+		}
+		return data, nil
+	}
+	data := make([]byte, length)
+	if n, err := mr.r.ReadAt(data, offset); n < len(data) {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		return nil, err
+	}
+	return data, nil
+}
+
+func (mr *memoReader) readTerminated(offset int64) ([]byte, error) {
+	var data []byte
+	chunk := make([]byte, dbtBlockSize)
+	for {
+		n, err := mr.r.ReadAt(chunk, offset)
+		if i := bytes.IndexByte(chunk[:n], 0x1A); i >= 0 {
+			return append(data, chunk[:i]...), nil
+		}
+		data = append(data, chunk[:n]...)
+		if err == io.EOF {
+			return data, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		offset += int64(n)
+	}
+}
+
+// memoWriter appends memos to a dBASE III (.dbt) or FoxPro (.fpt) memo file.
+type memoWriter struct {
+	w         io.WriteSeeker
+	fpt       bool
+	blockSize int64
+	next      int64 // next free block
+}
+
+func newMemoWriter(w io.WriteSeeker, fpt bool) (*memoWriter, error) {
+	mw := &memoWriter{w: w, fpt: fpt, blockSize: dbtBlockSize, next: 1}
+	if fpt {
+		mw.blockSize = fptBlockSize
+		mw.next = dbtBlockSize / fptBlockSize
+	}
+
+	// the header takes 512 bytes, the next free block is written on close
+	header := make([]byte, dbtBlockSize)
+	if fpt {
+		binary.BigEndian.PutUint16(header[6:8], uint16(mw.blockSize))
+	}
+	if _, err := w.Seek(0, io.SeekStart); err != nil {
+		return nil, err
+	}
+	if _, err := w.Write(header); err != nil {
+		return nil, err
+	}
+	return mw, nil
+}
+
+// write appends a memo and returns its block.
+func (mw *memoWriter) write(data []byte, text bool) (int64, error) {
+	var block []byte
+	if mw.fpt {
+		block = make([]byte, 8, 8+len(data))
+		if text {
+			binary.BigEndian.PutUint32(block[0:4], fptTypeText)
+		}
+		binary.BigEndian.PutUint32(block[4:8], uint32(len(data)))
+		block = append(block, data...)
+	} else {
+		block = append(append(make([]byte, 0, len(data)+2), data...), 0x1A, 0x1A)
+	}
+	if pad := int64(len(block)) % mw.blockSize; pad > 0 {
+		block = append(block, make([]byte, mw.blockSize-pad)...)
+	}
+
+	if _, err := mw.w.Write(block); err != nil {
+		return 0, err
+	}
+	start := mw.next
+	mw.next += int64(len(block)) / mw.blockSize
+	return start, nil
+}
+
+// close writes the next free block into the header.
+func (mw *memoWriter) close() error {
+	var next [4]byte
+	if mw.fpt {
+		binary.BigEndian.PutUint32(next[:], uint32(mw.next))
+	} else {
+		binary.LittleEndian.PutUint32(next[:], uint32(mw.next))
+	}
+	if _, err := mw.w.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+	_, err := mw.w.Write(next[:])
+	return err
+}
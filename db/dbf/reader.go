@@ -0,0 +1,476 @@
+package dbf
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	fieldFlagSystem   = 0x01 // hidden system field like _NullFlags
+	fieldFlagNullable = 0x02 // field could be NULL
+	fieldFlagBinary   = 0x04 // text is not translated by the code page
+
+	julianDayUnixEpoch = 2440588 // julian day of 1970-01-01
+)
+
+// Reader streams the records of a dBASE III, dBASE IV, FoxPro or Visual FoxPro table.
+// Records are read on demand, so tables of any size could be processed.
+//
+// Values are returned as nil (empty or NULL), string, []byte (binary memo and Varbinary),
+// bool, int64 (Integer and Numeric without decimals), float64 (Numeric with decimals,
+// Float, Double and Currency) or time.Time (Date and DateTime).
+type Reader struct {
+	r    io.ReaderAt
+	memo *memoReader
+
+	signature    byte
+	updated      time.Time
+	numRecords   int
+	headerSize   int64
+	recordLength int
+	codePage     int
+
+	fields    []DbfField
+	nullFlags *DbfField
+	decoder   decoder
+
+	closers []io.Closer
+
+	// sequential reading
+	buf    *bufio.Reader
+	index  int
+	record []byte
+	err    error
+}
+
+// NewReader reads the header of the table in r, memo is the matching .dbt or .fpt memo file
+// and could be nil if the table has no memo fields. Text is decoded from the code page of
+// the language driver of the table.
+func NewReader(r io.ReaderAt, memo io.ReaderAt) (*Reader, error) {
+	var header [32]byte
+	if _, err := r.ReadAt(header[:], 0); err != nil {
+		return nil, err
+	}
+
+	rd := &Reader{
+		r:            r,
+		signature:    header[0],
+		updated:      updateDate(header[1], header[2], header[3]),
+		numRecords:   int(binary.LittleEndian.Uint32(header[4:8])),
+		headerSize:   int64(binary.LittleEndian.Uint16(header[8:10])),
+		recordLength: int(binary.LittleEndian.Uint16(header[10:12])),
+		codePage:     languageDrivers[header[29]],
+		index:        -1,
+	}
+	if rd.headerSize < 33 || rd.recordLength < 1 {
+		return nil, errors.New("dbf: invalid table header")
+	}
+
+	descriptors := make([]byte, rd.headerSize-32)
+	if _, err := r.ReadAt(descriptors, 32); err != nil {
+		return nil, err
+	}
+
+	offset, bit := 1, 0
+	for i := 0; i+32 <= len(descriptors) && descriptors[i] != 0x0D; i += 32 {
+		d := descriptors[i : i+32]
+		name := d[:11]
+		if n := bytes.IndexByte(name, 0); n >= 0 {
+			name = name[:n]
+		}
+		field := DbfField{
+			Name:      strings.TrimRight(string(name), " "),
+			Type:      string(d[11]),
+			Length:    d[16],
+			Precision: d[17],
+			flags:     d[18],
+			offset:    offset,
+			nullBit:   -1,
+			varBit:    -1,
+		}
+		copy(field.fieldStore[:], d)
+		if field.Name == "" {
+			field.Name = fmt.Sprintf("MISSING%d", len(rd.fields))
+		}
+		if err := rd.checkLength(&field); err != nil {
+			return nil, err
+		}
+		offset += int(field.Length)
+
+		if field.Type == "0" {
+			// _NullFlags holds the null and length bits of the Visual FoxPro fields
+			rd.nullFlags = &field
+			continue
+		}
+		if rd.isFoxPro() {
+			if field.Type == "V" || field.Type == "Q" {
+				field.varBit = bit
+				bit++
+			}
+			if field.flags&fieldFlagNullable != 0 {
+				field.Nullable = true
+				field.nullBit = bit
+				bit++
+			}
+		}
+		rd.fields = append(rd.fields, field)
+	}
+	if offset > rd.recordLength {
+		return nil, errors.New("dbf: fields exceed the record length")
+	}
+
+	var err error
+	if rd.decoder, err = newDecoder(rd.codePage); err != nil {
+		rd.decoder = decoder{}
+	}
+	if memo != nil {
+		if rd.memo, err = newMemoReader(memo, rd.isFoxPro()); err != nil {
+			return nil, err
+		}
+	}
+
+	rd.record = make([]byte, rd.recordLength)
+	rd.buf = bufio.NewReaderSize(io.NewSectionReader(r, rd.headerSize, int64(rd.numRecords)*int64(rd.recordLength)), 64*1024)
+	return rd, nil
+}
+
+// OpenReader opens a table file and its .fpt or .dbt memo file, if there is one.
+func OpenReader(fileName string) (*Reader, error) {
+	f, err := os.Open(fileName)
+	if err != nil {
+		return nil, err
+	}
+
+	var memo *os.File
+	base := strings.TrimSuffix(fileName, filepath.Ext(fileName))
+	for _, memoPath := range []string{base + ".fpt", base + ".FPT", base + ".dbt", base + ".DBT"} {
+		if memo, err = os.Open(memoPath); err == nil {
+			break
+		}
+		if !os.IsNotExist(err) {
+			f.Close()
+			return nil, err
+		}
+		memo = nil
+	}
+
+	var rd *Reader
+	if memo != nil {
+		rd, err = NewReader(f, memo)
+	} else {
+		rd, err = NewReader(f, nil)
+	}
+	if err != nil {
+		f.Close()
+		if memo != nil {
+			memo.Close()
+		}
+		return nil, err
+	}
+
+	rd.closers = append(rd.closers, f)
+	if memo != nil {
+		rd.closers = append(rd.closers, memo)
+	}
+	return rd, nil
+}
+
+// Close closes the files opened by OpenReader.
+func (rd *Reader) Close() error {
+	var err error
+	for _, c := range rd.closers {
+		if e := c.Close(); e != nil && err == nil {
+			err = e
+		}
+	}
+	rd.closers = nil
+	return err
+}
+
+// isFoxPro tells if the table is a FoxPro table with .fpt memos.
+func (rd *Reader) isFoxPro() bool {
+	switch rd.signature {
+	case 0x30, 0x31, 0x32, 0xF5, 0xFB:
+		return true
+	}
+	return false
+}
+
+// checkLength rejects a field whose length does not fit its type, as the values of
+// fixed size types are decoded from exactly that many bytes.
+func (rd *Reader) checkLength(field *DbfField) error {
+	want := 0
+	switch field.Type {
+	case "L":
+		want = 1
+	case "D", "T", "Y":
+		want = 8
+	case "B":
+		// dBASE stores binary memos in B fields
+		if rd.isFoxPro() {
+			want = 8
+		}
+	case "I":
+		want = 4
+	case "V", "Q":
+		if field.Length < 1 {
+			return fmt.Errorf("dbf: invalid length %d of %s field %s", field.Length, field.Type, field.Name)
+		}
+	}
+	if want > 0 && int(field.Length) != want {
+		return fmt.Errorf("dbf: invalid length %d of %s field %s", field.Length, field.Type, field.Name)
+	}
+	return nil
+}
+
+// Fields returns the fields of the table, without the _NullFlags system field.
+func (rd *Reader) Fields() []DbfField {
+	return rd.fields
+}
+
+// NumRecords returns the number of records, including deleted ones.
+func (rd *Reader) NumRecords() int {
+	return rd.numRecords
+}
+
+// Updated returns the date of the last update of the table.
+func (rd *Reader) Updated() time.Time {
+	return rd.updated
+}
+
+// CodePage returns the code page of the text, 0 if unknown.
+func (rd *Reader) CodePage() int {
+	return rd.codePage
+}
+
+// SetCodePage overrides the code page of the language driver, 0 returns text undecoded.
+func (rd *Reader) SetCodePage(codePage int) error {
+	d, err := newDecoder(codePage)
+	if err != nil {
+		return err
+	}
+	rd.codePage, rd.decoder = codePage, d
+	return nil
+}
+
+// Next advances to the next record which is not deleted.
+func (rd *Reader) Next() bool {
+	if rd.err != nil {
+		return false
+	}
+	for rd.index+1 < rd.numRecords {
+		rd.index++
+		if _, err := io.ReadFull(rd.buf, rd.record); err != nil {
+			if err == io.EOF {
+				err = io.ErrUnexpectedEOF
+			}
+			rd.err = err
+			return false
+		}
+		if rd.record[0] != 0x2A {
+			return true
+		}
+	}
+	return false
+}
+
+// Index returns the record number of the current record.
+func (rd *Reader) Index() int {
+	return rd.index
+}
+
+// Err returns the error which stopped Next.
+func (rd *Reader) Err() error {
+	return rd.err
+}
+
+// Values decodes the current record.
+func (rd *Reader) Values() ([]interface{}, error) {
+	if rd.index < 0 || rd.index >= rd.numRecords {
+		return nil, errors.New("dbf: no current record")
+	}
+	return rd.decode(rd.record)
+}
+
+// ReadRecord decodes the record at index and tells whether it is deleted, it does not
+// move the position of Next.
+func (rd *Reader) ReadRecord(index int) ([]interface{}, bool, error) {
+	if index < 0 || index >= rd.numRecords {
+		return nil, false, errors.New("dbf: record index out of range")
+	}
+
+	record := make([]byte, rd.recordLength)
+	if n, err := rd.r.ReadAt(record, rd.headerSize+int64(index)*int64(rd.recordLength)); n < len(record) {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		return nil, false, err
+	}
+
+	values, err := rd.decode(record)
+	return values, record[0] == 0x2A, err
+}
+
+func (rd *Reader) decode(record []byte) ([]interface{}, error) {
+	var nullFlags []byte
+	if rd.nullFlags != nil {
+		nullFlags = record[rd.nullFlags.offset : rd.nullFlags.offset+int(rd.nullFlags.Length)]
+	}
+
+	values := make([]interface{}, len(rd.fields))
+	for i := range rd.fields {
+		field := &rd.fields[i]
+		if field.nullBit >= 0 && bitSet(nullFlags, field.nullBit) {
+			continue
+		}
+
+		data := record[field.offset : field.offset+int(field.Length)]
+		if field.varBit >= 0 && bitSet(nullFlags, field.varBit) {
+			// the last byte holds the length of a shorter value
+			n := int(data[len(data)-1])
+			if n > len(data)-1 {
+				return nil, fmt.Errorf("dbf: invalid length of field %s", field.Name)
+			}
+			data = data[:n]
+		}
+
+		value, err := rd.decodeField(field, data)
+		if err != nil {
+			return nil, fmt.Errorf("dbf: field %s: %v", field.Name, err)
+		}
+		values[i] = value
+	}
+	return values, nil
+}
+
+func (rd *Reader) decodeField(field *DbfField, data []byte) (interface{}, error) {
+	switch field.Type {
+	case "C":
+		if field.flags&fieldFlagBinary != 0 {
+			return string(bytes.TrimRight(data, " \x00")), nil
+		}
+		return rd.decoder.decode(bytes.TrimRight(data, " \x00")), nil
+	case "V":
+		if field.flags&fieldFlagBinary != 0 {
+			return string(data), nil
+		}
+		return rd.decoder.decode(data), nil
+	case "Q":
+		return append([]byte(nil), data...), nil
+	case "N", "F":
+		s := strings.TrimSpace(string(bytes.Trim(data, "\x00")))
+		if s == "" {
+			return nil, nil
+		}
+		if field.Precision == 0 && field.Type == "N" {
+			if n, err := strconv.ParseInt(s, 10, 64); err == nil {
+				return n, nil
+			}
+		}
+		return strconv.ParseFloat(s, 64)
+	case "L":
+		switch data[0] {
+		case 'T', 't', 'Y', 'y':
+			return true, nil
+		case 'F', 'f', 'N', 'n':
+			return false, nil
+		}
+		return nil, nil
+	case "D":
+		s := strings.TrimSpace(string(data))
+		if s == "" || s == "00000000" {
+			return nil, nil
+		}
+		return time.Parse("20060102", s)
+	case "T":
+		return decodeDateTime(data), nil
+	case "I":
+		if len(data) != 4 {
+			break
+		}
+		return int64(int32(binary.LittleEndian.Uint32(data))), nil
+	case "Y":
+		if len(data) != 8 {
+			break
+		}
+		return float64(int64(binary.LittleEndian.Uint64(data))) / 10000, nil
+	case "B", "O":
+		if len(data) == 8 {
+			return math.Float64frombits(binary.LittleEndian.Uint64(data)), nil
+		}
+		// dBASE binary memo
+		return rd.readMemo(field, data)
+	case "M", "G", "P", "W":
+		return rd.readMemo(field, data)
+	}
+
+	// unknown types are handled like text
+	return rd.decoder.decode(bytes.TrimRight(data, " \x00")), nil
+}
+
+// decodeDateTime decodes the julian day and the milliseconds of the day.
+func decodeDateTime(data []byte) interface{} {
+	if len(data) != 8 {
+		return nil
+	}
+	day := int64(int32(binary.LittleEndian.Uint32(data[0:4])))
+	ms := int64(int32(binary.LittleEndian.Uint32(data[4:8])))
+	if day == 0 && ms == 0 {
+		return nil
+	}
+	return time.Unix((day-julianDayUnixEpoch)*86400, ms*int64(time.Millisecond)).UTC()
+}
+
+func (rd *Reader) readMemo(field *DbfField, data []byte) (interface{}, error) {
+	var block int64
+	if len(data) == 4 {
+		block = int64(binary.LittleEndian.Uint32(data))
+	} else {
+		s := strings.TrimSpace(string(bytes.Trim(data, "\x00")))
+		if s != "" {
+			var err error
+			if block, err = strconv.ParseInt(s, 10, 64); err != nil {
+				return nil, err
+			}
+		}
+	}
+	if block <= 0 {
+		return nil, nil
+	}
+	if rd.memo == nil {
+		return nil, errors.New("missing memo file")
+	}
+
+	memo, err := rd.memo.read(block)
+	if err != nil {
+		return nil, err
+	}
+	if field.Type != "M" || field.flags&fieldFlagBinary != 0 {
+		return memo, nil
+	}
+	return rd.decoder.decode(memo), nil
+}
+
+// updateDate returns the date of the last update, the year is stored as years since 1900
+// but some writers store the last two digits only.
+func updateDate(year, month, day byte) time.Time {
+	y := 1900 + int(year)
+	if year < 80 {
+		y += 100
+	}
+	return time.Date(y, time.Month(month), int(day), 0, 0, 0, 0, time.UTC)
+}
+
+func bitSet(flags []byte, bit int) bool {
+	return bit/8 < len(flags) && flags[bit/8]&(1<<uint(bit%8)) != 0
+}
@@ -0,0 +1,172 @@
+package dbf
+
+import (
+	"bytes"
+	"encoding/binary"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+type testField struct {
+	name   string
+	typ    byte
+	length byte
+	flags  byte
+}
+
+// testTable builds a table with the fields and a single record.
+func testTable(signature byte, fields []testField, record []byte) []byte {
+	var b bytes.Buffer
+	header := make([]byte, 32)
+	header[0] = signature
+	binary.LittleEndian.PutUint32(header[4:8], 1)
+	binary.LittleEndian.PutUint16(header[8:10], uint16(32+32*len(fields)+1))
+	binary.LittleEndian.PutUint16(header[10:12], uint16(len(record)))
+	b.Write(header)
+
+	for _, f := range fields {
+		d := make([]byte, 32)
+		copy(d, f.name)
+		d[11] = f.typ
+		d[16] = f.length
+		d[18] = f.flags
+		b.Write(d)
+	}
+	b.WriteByte(0x0D)
+	b.Write(record)
+	return b.Bytes()
+}
+
+func TestNewReaderFieldLength(t *testing.T) {
+	for _, tc := range []struct {
+		signature byte
+		typ       byte
+		length    byte
+		ok        bool
+	}{
+		{0x03, 'L', 1, true},
+		{0x03, 'L', 0, false},
+		{0x03, 'L', 2, false},
+		{0x03, 'D', 8, true},
+		{0x03, 'D', 6, false},
+		{0x30, 'T', 8, true},
+		{0x30, 'T', 4, false},
+		{0x30, 'B', 8, true},
+		{0x30, 'B', 10, false},
+		{0x8B, 'B', 10, true}, // dBASE binary memo
+		{0x30, 'Y', 8, true},
+		{0x30, 'Y', 0, false},
+		{0x30, 'I', 4, true},
+		{0x30, 'I', 8, false},
+		{0x30, 'V', 1, true},
+		{0x30, 'V', 0, false},
+		{0x30, 'Q', 0, false},
+		{0x03, 'C', 0, true},
+		{0x03, 'N', 20, true},
+	} {
+		data := testTable(tc.signature, []testField{{"F", tc.typ, tc.length, 0}}, make([]byte, 1+int(tc.length)))
+		_, err := NewReader(bytes.NewReader(data), nil)
+		if tc.ok && err != nil {
+			t.Errorf("%c of length %d: NewReader() = %v", tc.typ, tc.length, err)
+		}
+		if !tc.ok && (err == nil || !strings.Contains(err.Error(), "invalid length")) {
+			t.Errorf("%c of length %d: NewReader() = %v, want an invalid length", tc.typ, tc.length, err)
+		}
+	}
+}
+
+func TestReaderVarLength(t *testing.T) {
+	fields := []testField{
+		{"V", 'V', 6, 0},
+		{"Q", 'Q', 1, 0},
+		{"_NullFlags", '0', 1, fieldFlagSystem},
+	}
+	for _, tc := range []struct {
+		record []byte
+		want   []interface{}
+	}{
+		// full length
+		{[]byte(" abcdef\x07\x00"), []interface{}{"abcdef", []byte{7}}},
+		// shorter values carry their length in the last byte
+		{[]byte(" ab\x00\x00\x00\x02\x07\x01"), []interface{}{"ab", []byte{7}}},
+		{[]byte(" \x00\x00\x00\x00\x00\x00\x00\x03"), []interface{}{"", []byte(nil)}},
+	} {
+		rd, err := NewReader(bytes.NewReader(testTable(0x30, fields, tc.record)), nil)
+		if err != nil {
+			t.Fatalf("NewReader() = %v", err)
+		}
+		values, _, err := rd.ReadRecord(0)
+		if err != nil {
+			t.Fatalf("ReadRecord() = %v", err)
+		}
+		if !reflect.DeepEqual(values, tc.want) {
+			t.Errorf("ReadRecord() = %#v, want %#v", values, tc.want)
+		}
+	}
+
+	rd, err := NewReader(bytes.NewReader(testTable(0x30, fields, []byte(" ab\x00\x00\x00\x09\x07\x01"))), nil)
+	if err != nil {
+		t.Fatalf("NewReader() = %v", err)
+	}
+	if _, _, err := rd.ReadRecord(0); err == nil {
+		t.Error("ReadRecord() with a length beyond the field succeeded")
+	}
+}
+
+func TestWriterReader(t *testing.T) {
+	fields := []DbfField{
+		{Name: "NAME", Type: "C", Length: 10},
+		{Name: "ACTIVE", Type: "L"},
+		{Name: "BORN", Type: "D"},
+		{Name: "SEEN", Type: "T"},
+		{Name: "COUNT", Type: "I"},
+		{Name: "PRICE", Type: "Y"},
+		{Name: "RATIO", Type: "B"},
+		{Name: "NOTE", Type: "V", Length: 8, Nullable: true},
+		{Name: "RAW", Type: "Q", Length: 4},
+	}
+	born := time.Date(1990, 5, 17, 0, 0, 0, 0, time.UTC)
+	seen := time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+	records := [][]interface{}{
+		{"Ann", true, born, seen, int64(-7), 12.5, 0.25, "hi", []byte{1, 2}},
+		{"Bob", false, nil, nil, int64(0), 0.0, 0.0, nil, []byte(nil)},
+	}
+
+	fileName := filepath.Join(t.TempDir(), "test.dbf")
+	wr, err := CreateWriter(fileName, fields, 0)
+	if err != nil {
+		t.Fatalf("CreateWriter() = %v", err)
+	}
+	for _, record := range records {
+		if err := wr.Write(record); err != nil {
+			t.Fatalf("Write() = %v", err)
+		}
+	}
+	if err := wr.Close(); err != nil {
+		t.Fatalf("Close() = %v", err)
+	}
+
+	rd, err := OpenReader(fileName)
+	if err != nil {
+		t.Fatalf("OpenReader() = %v", err)
+	}
+	defer rd.Close()
+	for i, want := range records {
+		if !rd.Next() {
+			t.Fatalf("Next() = false at record %d: %v", i, rd.Err())
+		}
+		values, err := rd.Values()
+		if err != nil {
+			t.Fatalf("Values() = %v", err)
+		}
+		if !reflect.DeepEqual(values, want) {
+			t.Errorf("record %d = %#v, want %#v", i, values, want)
+		}
+	}
+	if rd.Next() {
+		t.Error("Next() = true after the last record")
+	}
+}
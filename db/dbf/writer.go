@@ -0,0 +1,546 @@
+package dbf
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Writer streams records into a new table. Tables with Integer, Currency, DateTime,
+// Double, Varchar, Varbinary, General, Picture or Blob fields or with nullable fields are
+// written as Visual FoxPro tables with .fpt memos, all others as dBASE III tables with
+// .dbt memos.
+//
+// Values are accepted in the types returned by Reader, numbers of any Go type are
+// converted and nil leaves a field empty or sets it NULL.
+type Writer struct {
+	w    io.WriteSeeker
+	buf  *bufio.Writer
+	memo *memoWriter
+
+	foxPro     bool
+	fields     []DbfField
+	nullFlags  *DbfField
+	encoder    decoder
+	numRecords uint32
+	record     []byte
+
+	closers []io.Closer
+}
+
+// NewWriter writes the header of a table with fields into w, memo receives the memos and
+// is needed only if there are memo fields. Text is encoded into codePage, which is stored
+// as language driver, 0 writes text unchanged without language driver.
+func NewWriter(w io.WriteSeeker, memo io.WriteSeeker, fields []DbfField, codePage int) (*Writer, error) {
+	if len(fields) <= 0 {
+		return nil, errors.New("dbf: no fields")
+	}
+	ldid, err := languageDriver(codePage)
+	if err != nil {
+		return nil, err
+	}
+
+	wr := &Writer{w: w, foxPro: isFoxProSchema(fields)}
+	if wr.encoder, err = newDecoder(codePage); err != nil {
+		return nil, err
+	}
+
+	hasMemo := false
+	offset, bit := 1, 0
+	names := make(map[string]bool)
+	for _, f := range fields {
+		field := DbfField{
+			Name:      strings.ToUpper(f.Name),
+			Type:      f.Type,
+			Length:    f.Length,
+			Precision: f.Precision,
+			Nullable:  f.Nullable,
+			offset:    offset,
+			nullBit:   -1,
+			varBit:    -1,
+		}
+		if len(field.Name) <= 0 || len(field.Name) > 10 || names[field.Name] {
+			return nil, fmt.Errorf("dbf: invalid or duplicate field name '%s'", f.Name)
+		}
+		names[field.Name] = true
+
+		if err := wr.normalizeField(&field); err != nil {
+			return nil, err
+		}
+		if isMemoField(&field) {
+			hasMemo = true
+		}
+		if field.Type == "V" || field.Type == "Q" {
+			field.varBit = bit
+			bit++
+		}
+		if field.Nullable {
+			field.flags |= fieldFlagNullable
+			field.nullBit = bit
+			bit++
+		}
+		offset += int(field.Length)
+		wr.fields = append(wr.fields, field)
+	}
+	if bit > 0 {
+		wr.nullFlags = &DbfField{Name: "_NullFlags", Type: "0", Length: uint8((bit + 7) / 8), flags: fieldFlagSystem | fieldFlagBinary, offset: offset}
+		offset += int(wr.nullFlags.Length)
+	}
+	if offset > math.MaxUint16 {
+		return nil, errors.New("dbf: record too long")
+	}
+
+	if hasMemo {
+		if memo == nil {
+			return nil, errors.New("dbf: memo fields need a memo file")
+		}
+		if wr.memo, err = newMemoWriter(memo, wr.foxPro); err != nil {
+			return nil, err
+		}
+	}
+
+	if _, err := w.Seek(0, io.SeekStart); err != nil {
+		return nil, err
+	}
+	wr.buf = bufio.NewWriterSize(w, 64*1024)
+	wr.record = make([]byte, offset)
+	if err := wr.writeHeader(ldid, hasMemo); err != nil {
+		return nil, err
+	}
+	return wr, nil
+}
+
+// CreateWriter creates a table file and, if there are memo fields, its memo file.
+func CreateWriter(fileName string, fields []DbfField, codePage int) (*Writer, error) {
+	f, err := os.Create(fileName)
+	if err != nil {
+		return nil, err
+	}
+
+	var memo *os.File
+	for _, field := range fields {
+		if !isMemoField(&field) {
+			continue
+		}
+		ext := ".dbt"
+		if isFoxProSchema(fields) {
+			ext = ".fpt"
+		}
+		if memo, err = os.Create(strings.TrimSuffix(fileName, filepath.Ext(fileName)) + ext); err != nil {
+			f.Close()
+			return nil, err
+		}
+		break
+	}
+
+	var wr *Writer
+	if memo != nil {
+		wr, err = NewWriter(f, memo, fields, codePage)
+	} else {
+		wr, err = NewWriter(f, nil, fields, codePage)
+	}
+	if err != nil {
+		f.Close()
+		if memo != nil {
+			memo.Close()
+		}
+		return nil, err
+	}
+
+	wr.closers = append(wr.closers, f)
+	if memo != nil {
+		wr.closers = append(wr.closers, memo)
+	}
+	return wr, nil
+}
+
+func isFoxProSchema(fields []DbfField) bool {
+	for _, field := range fields {
+		switch field.Type {
+		case "I", "Y", "T", "B", "V", "Q", "G", "P", "W":
+			return true
+		}
+		if field.Nullable {
+			return true
+		}
+	}
+	return false
+}
+
+func isMemoField(field *DbfField) bool {
+	switch field.Type {
+	case "M", "G", "P", "W":
+		return true
+	}
+	return false
+}
+
+// normalizeField checks the type of a field and sets the length of fixed size types.
+func (wr *Writer) normalizeField(field *DbfField) error {
+	switch field.Type {
+	case "C", "V", "Q":
+		if field.Length <= 0 {
+			return fmt.Errorf("dbf: field %s needs a length", field.Name)
+		}
+		field.Precision = 0
+	case "N", "F":
+		if field.Length <= 0 || field.Length > 20 || field.Precision >= field.Length {
+			return fmt.Errorf("dbf: invalid length or precision of field %s", field.Name)
+		}
+	case "L":
+		field.Length, field.Precision = 1, 0
+	case "D", "T", "B":
+		field.Length, field.Precision = 8, 0
+	case "Y":
+		field.Length, field.Precision = 8, 4
+	case "I":
+		field.Length, field.Precision = 4, 0
+	case "M", "G", "P", "W":
+		field.Length, field.Precision = 10, 0
+		if wr.foxPro {
+			field.Length = 4
+		}
+	default:
+		return fmt.Errorf("dbf: unsupported type '%s' of field %s", field.Type, field.Name)
+	}
+
+	if field.Type == "Q" || field.Type == "G" || field.Type == "P" || field.Type == "W" {
+		field.flags |= fieldFlagBinary
+	}
+	return nil
+}
+
+func (wr *Writer) writeHeader(ldid byte, hasMemo bool) error {
+	fields := wr.fields
+	if wr.nullFlags != nil {
+		fields = append(fields[:len(fields):len(fields)], *wr.nullFlags)
+	}
+
+	headerSize := 32 + 32*len(fields) + 1
+	if wr.foxPro {
+		// Visual FoxPro reserves the backlink to the database container
+		headerSize += 263
+	}
+	header := make([]byte, headerSize)
+
+	header[0] = 0x03
+	switch {
+	case wr.foxPro:
+		header[0] = 0x30
+		if hasMemo {
+			header[28] = 0x02
+		}
+	case hasMemo:
+		header[0] = 0x83
+	}
+
+	now := time.Now()
+	header[1] = byte(now.Year() - 1900)
+	header[2] = byte(now.Month())
+	header[3] = byte(now.Day())
+	binary.LittleEndian.PutUint16(header[8:10], uint16(headerSize))
+	binary.LittleEndian.PutUint16(header[10:12], uint16(len(wr.record)))
+	header[29] = ldid
+
+	for i, field := range fields {
+		d := header[32+32*i : 64+32*i]
+		copy(d[:10], field.Name)
+		d[11] = field.Type[0]
+		if wr.foxPro {
+			binary.LittleEndian.PutUint32(d[12:16], uint32(field.offset))
+		}
+		d[16] = field.Length
+		d[17] = field.Precision
+		d[18] = field.flags
+	}
+	header[32+32*len(fields)] = 0x0D
+
+	_, err := wr.buf.Write(header)
+	return err
+}
+
+// Fields returns the fields of the table, with the lengths of fixed size types set.
+func (wr *Writer) Fields() []DbfField {
+	return wr.fields
+}
+
+// Write appends a record with a value for each field.
+func (wr *Writer) Write(values []interface{}) error {
+	if len(values) != len(wr.fields) {
+		return fmt.Errorf("dbf: %d values for %d fields", len(values), len(wr.fields))
+	}
+	if wr.numRecords == math.MaxUint32 {
+		return errors.New("dbf: too many records")
+	}
+
+	record := wr.record
+	for i := range record {
+		record[i] = ' '
+	}
+	var nullFlags []byte
+	if wr.nullFlags != nil {
+		nullFlags = record[wr.nullFlags.offset : wr.nullFlags.offset+int(wr.nullFlags.Length)]
+		for i := range nullFlags {
+			nullFlags[i] = 0
+		}
+	}
+
+	for i := range wr.fields {
+		field := &wr.fields[i]
+		data := record[field.offset : field.offset+int(field.Length)]
+		if values[i] == nil && field.nullBit >= 0 {
+			nullFlags[field.nullBit/8] |= 1 << uint(field.nullBit%8)
+			for j := range data {
+				data[j] = 0
+			}
+			continue
+		}
+		if err := wr.encodeField(field, data, nullFlags, values[i]); err != nil {
+			return fmt.Errorf("dbf: field %s: %v", field.Name, err)
+		}
+	}
+
+	if _, err := wr.buf.Write(record); err != nil {
+		return err
+	}
+	wr.numRecords++
+	return nil
+}
+
+func (wr *Writer) encodeField(field *DbfField, data, nullFlags []byte, value interface{}) error {
+	switch field.Type {
+	case "C":
+		b, err := wr.bytesOf(field, value)
+		if err != nil {
+			return err
+		}
+		if len(b) > len(data) {
+			b = b[:len(data)]
+		}
+		copy(data, b)
+	case "V", "Q":
+		b, err := wr.bytesOf(field, value)
+		if err != nil {
+			return err
+		}
+		for j := range data {
+			data[j] = 0
+		}
+		if len(b) >= len(data) {
+			copy(data, b)
+			return nil
+		}
+		// a shorter value keeps its length in the last byte
+		copy(data, b)
+		data[len(data)-1] = byte(len(b))
+		nullFlags[field.varBit/8] |= 1 << uint(field.varBit%8)
+	case "N", "F":
+		if value == nil {
+			return nil
+		}
+		f, err := toFloat(value)
+		if err != nil {
+			return err
+		}
+		s := strconv.FormatFloat(f, 'f', int(field.Precision), 64)
+		if len(s) > len(data) {
+			return fmt.Errorf("number %s exceeds the length %d", s, len(data))
+		}
+		copy(data[len(data)-len(s):], s)
+	case "L":
+		switch v := value.(type) {
+		case nil:
+			data[0] = '?'
+		case bool:
+			data[0] = 'F'
+			if v {
+				data[0] = 'T'
+			}
+		default:
+			return fmt.Errorf("unexpected %T for a logical", value)
+		}
+	case "D":
+		t, err := toTime(value)
+		if err != nil || t.IsZero() {
+			return err
+		}
+		copy(data, t.Format("20060102"))
+	case "T":
+		for j := range data {
+			data[j] = 0
+		}
+		t, err := toTime(value)
+		if err != nil || t.IsZero() {
+			return err
+		}
+		t = t.UTC()
+		midnight := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+		binary.LittleEndian.PutUint32(data[0:4], uint32(midnight.Unix()/86400+julianDayUnixEpoch))
+		binary.LittleEndian.PutUint32(data[4:8], uint32(t.Sub(midnight)/time.Millisecond))
+	case "I":
+		if value == nil {
+			value = 0
+		}
+		f, err := toFloat(value)
+		if err != nil {
+			return err
+		}
+		if f < math.MinInt32 || f > math.MaxInt32 {
+			return fmt.Errorf("integer %v out of range", value)
+		}
+		binary.LittleEndian.PutUint32(data, uint32(int32(f)))
+	case "Y":
+		if value == nil {
+			value = 0
+		}
+		f, err := toFloat(value)
+		if err != nil {
+			return err
+		}
+		binary.LittleEndian.PutUint64(data, uint64(int64(math.Round(f*10000))))
+	case "B":
+		if value == nil {
+			value = 0
+		}
+		f, err := toFloat(value)
+		if err != nil {
+			return err
+		}
+		binary.LittleEndian.PutUint64(data, math.Float64bits(f))
+	case "M", "G", "P", "W":
+		return wr.writeMemo(field, data, value)
+	}
+	return nil
+}
+
+func (wr *Writer) writeMemo(field *DbfField, data []byte, value interface{}) error {
+	if wr.foxPro {
+		for j := range data {
+			data[j] = 0
+		}
+	}
+	if value == nil {
+		return nil
+	}
+	b, err := wr.bytesOf(field, value)
+	if err != nil || len(b) <= 0 {
+		return err
+	}
+
+	block, err := wr.memo.write(b, field.Type == "M")
+	if err != nil {
+		return err
+	}
+	if wr.foxPro {
+		binary.LittleEndian.PutUint32(data, uint32(block))
+	} else {
+		s := strconv.FormatInt(block, 10)
+		copy(data[len(data)-len(s):], s)
+	}
+	return nil
+}
+
+// bytesOf returns text encoded into the code page, binary fields and []byte are kept.
+func (wr *Writer) bytesOf(field *DbfField, value interface{}) ([]byte, error) {
+	switch v := value.(type) {
+	case nil:
+		return nil, nil
+	case []byte:
+		return v, nil
+	case string:
+		if field.flags&fieldFlagBinary != 0 {
+			return []byte(v), nil
+		}
+		return wr.encoder.encode(v), nil
+	case fmt.Stringer:
+		return wr.encoder.encode(v.String()), nil
+	}
+	return wr.encoder.encode(fmt.Sprint(value)), nil
+}
+
+func toFloat(value interface{}) (float64, error) {
+	switch v := value.(type) {
+	case int:
+		return float64(v), nil
+	case int8:
+		return float64(v), nil
+	case int16:
+		return float64(v), nil
+	case int32:
+		return float64(v), nil
+	case int64:
+		return float64(v), nil
+	case uint:
+		return float64(v), nil
+	case uint8:
+		return float64(v), nil
+	case uint16:
+		return float64(v), nil
+	case uint32:
+		return float64(v), nil
+	case uint64:
+		return float64(v), nil
+	case float32:
+		return float64(v), nil
+	case float64:
+		return v, nil
+	case string:
+		return strconv.ParseFloat(strings.TrimSpace(v), 64)
+	}
+	return 0, fmt.Errorf("unexpected %T for a number", value)
+}
+
+func toTime(value interface{}) (time.Time, error) {
+	switch v := value.(type) {
+	case nil:
+		return time.Time{}, nil
+	case time.Time:
+		return v, nil
+	case *time.Time:
+		if v == nil {
+			return time.Time{}, nil
+		}
+		return *v, nil
+	}
+	return time.Time{}, fmt.Errorf("unexpected %T for a date", value)
+}
+
+// Close completes the table by writing the number of records, the end of file marker and
+// the header of the memo file, it closes the files created by CreateWriter.
+func (wr *Writer) Close() error {
+	err := wr.finish()
+	for _, c := range wr.closers {
+		if e := c.Close(); e != nil && err == nil {
+			err = e
+		}
+	}
+	wr.closers = nil
+	return err
+}
+
+func (wr *Writer) finish() error {
+	if _, err := wr.buf.Write([]byte{0x1A}); err != nil {
+		return err
+	}
+	if err := wr.buf.Flush(); err != nil {
+		return err
+	}
+	if _, err := wr.w.Seek(4, io.SeekStart); err != nil {
+		return err
+	}
+	if _, err := wr.w.Write(uint32ToBytes(wr.numRecords)); err != nil {
+		return err
+	}
+	if wr.memo != nil {
+		return wr.memo.close()
+	}
+	return nil
+}
@@ -78,6 +78,59 @@ func (i *Index) List() List {
 	return list
 }
 
+// Len will return the number of documents in the index.
+func (i *Index) Len() int {
+	return i.btree.Len()
+}
+
+// Search will return the position of the first document in the index for which
+// the function returns true. As with sort.Search, the function must return false
+// and then true for the documents in ascending order.
+func (i *Index) Search(fn func(Doc) bool) int {
+	// binary search positions
+	lo, hi := 0, i.btree.Len()
+	for lo < hi {
+		mid := int(uint(lo+hi) >> 1)
+		doc, _ := i.btree.GetAt(mid)
+		if !fn(doc) {
+			lo = mid + 1
+		} else {
+			hi = mid
+		}
+	}
+
+	return lo
+}
+
+// Range will call the function with the documents from the start position up
+// to but excluding the end position. The documents are walked in descending
+// order if reverse is true. The walk stops if the function returns false.
+func (i *Index) Range(start, end int, reverse bool, fn func(Doc) bool) {
+	// check range
+	if start >= end {
+		return
+	}
+
+	// walk ascending
+	if !reverse {
+		pivot, _ := i.btree.GetAt(start)
+		n := end - start
+		i.btree.Ascend(pivot, func(doc Doc) bool {
+			n--
+			return fn(doc) && n > 0
+		})
+		return
+	}
+
+	// walk descending
+	pivot, _ := i.btree.GetAt(end - 1)
+	n := end - start
+	i.btree.Descend(pivot, func(doc Doc) bool {
+		n--
+		return fn(doc) && n > 0
+	})
+}
+
 // Clone will clone the index. Mutating the new index will not mutate the original
 // index.
 func (i *Index) Clone() *Index {
@@ -2,6 +2,7 @@ package lungo
 
 import (
 	"context"
+	"fmt"
 
 	"github.com/unix-world/smartgoext/db/mongo-driver/bson"
 	"github.com/unix-world/smartgoext/db/mongo-driver/mongo"
@@ -20,8 +21,42 @@ type Collection struct {
 }
 
 // Aggregate implements the ICollection.Aggregate method.
-func (c *Collection) Aggregate(context.Context, interface{}, ...*options.AggregateOptions) (ICursor, error) {
-	panic("lungo: not implemented")
+func (c *Collection) Aggregate(ctx context.Context, pipeline interface{}, opts ...*options.AggregateOptions) (ICursor, error) {
+	// merge options
+	opt := options.MergeAggregateOptions(opts...)
+
+	// assert supported options
+	assertOptions(opt, map[string]string{
+		"AllowDiskUse": ignored,
+		"BatchSize":    ignored,
+		"Comment":      ignored,
+		"MaxAwaitTime": ignored,
+		"MaxTime":      ignored,
+	})
+
+	// check pipeline
+	if pipeline == nil {
+		return nil, fmt.Errorf("missing pipeline")
+	}
+
+	// transform pipeline
+	stages, err := bsonkit.TransformList(pipeline)
+	if err != nil {
+		return nil, err
+	}
+
+	// run pipeline
+	res, err := useTransaction(ctx, c.engine, false, func(txn *Transaction) (interface{}, error) {
+		return txn.Aggregate(c.handle, stages)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// get list
+	list := res.(*Result).Matched
+
+	return &Cursor{list: list}, nil
 }
 
 // BulkWrite implements the ICollection.BulkWrite method.
@@ -521,6 +556,71 @@ func (c *Collection) Find(ctx context.Context, filter interface{}, opts ...*opti
 	return &Cursor{list: list}, nil
 }
 
+// Explain runs the query like Find and returns a document that describes the
+// selected query plan and its execution, similar to the MongoDB explain command
+// in the "executionStats" mode. It is not part of the ICollection interface.
+func (c *Collection) Explain(ctx context.Context, filter interface{}, opts ...*options.FindOptions) (bson.D, error) {
+	// merge options
+	opt := options.MergeFindOptions(opts...)
+
+	// assert supported options
+	assertOptions(opt, map[string]string{
+		"AllowPartialResults": ignored,
+		"BatchSize":           ignored,
+		"Comment":             ignored,
+		"Limit":               supported,
+		"MaxAwaitTime":        ignored,
+		"MaxTime":             ignored,
+		"NoCursorTimeout":     ignored,
+		"Projection":          ignored,
+		"Skip":                supported,
+		"Snapshot":            ignored,
+		"Sort":                supported,
+	})
+
+	// check filer
+	if filter == nil {
+		panic("lungo: missing filter document")
+	}
+
+	// transform filter
+	query, err := bsonkit.Transform(filter)
+	if err != nil {
+		return nil, err
+	}
+
+	// get sort
+	var sort bsonkit.Doc
+	if opt.Sort != nil {
+		sort, err = bsonkit.Transform(opt.Sort)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	// get skip
+	var skip int
+	if opt.Skip != nil {
+		skip = int(*opt.Skip)
+	}
+
+	// get limit
+	var limit int
+	if opt.Limit != nil {
+		limit = int(*opt.Limit)
+	}
+
+	// explain query
+	res, err := useTransaction(ctx, c.engine, false, func(txn *Transaction) (interface{}, error) {
+		return txn.Explain(c.handle, query, sort, skip, limit)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return *res.(bsonkit.Doc), nil
+}
+
 // FindOne implements the ICollection.FindOne method.
 func (c *Collection) FindOne(ctx context.Context, filter interface{}, opts ...*options.FindOneOptions) ISingleResult {
 	// merge options
@@ -0,0 +1,633 @@
+package lungo
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"hash/crc32"
+	"os"
+	"reflect"
+	"sort"
+	"sync"
+
+	"github.com/unix-world/smartgoext/db/mongo-driver/bson"
+
+	"github.com/unix-world/smartgoext/db/lungo/bsonkit"
+	"github.com/unix-world/smartgoext/db/lungo/dbkit"
+	"github.com/unix-world/smartgoext/db/lungo/mongokit"
+)
+
+// DefaultCompactSize is the default journal size after which a journal store
+// compacts the journal into a new snapshot.
+const DefaultCompactSize = 16 << 20
+
+// JournalStore writes the catalog to a snapshot file on disk and appends the
+// changes of every committed transaction to a journal file next to it. The
+// journal is replayed on load and compacted into a new snapshot in the
+// background once it grows beyond the configured size.
+//
+// The snapshot uses the same format as the FileStore. An existing file written
+// by a FileStore can therefore be loaded by a journal store and vice versa once
+// the journal has been compacted.
+type JournalStore struct {
+	// The size in bytes after which the journal is compacted.
+	//
+	// Default: 16 MiB.
+	CompactSize int64
+
+	// The function that is called with errors from the compaction goroutine.
+	CompactErrors func(error)
+
+	path       string
+	mode       os.FileMode
+	file       *os.File
+	size       int64
+	seq        int64
+	catalog    *Catalog
+	compacting bool
+	group      sync.WaitGroup
+	compaction sync.Mutex
+	mutex      sync.Mutex
+}
+
+// NewJournalStore creates and returns a new journal store. The snapshot is
+// stored at the specified path and the journal at the same path with an
+// additional ".journal" extension.
+func NewJournalStore(path string, mode os.FileMode) *JournalStore {
+	// set default mode
+	if mode == 0 {
+		mode = 0666
+	}
+
+	return &JournalStore{
+		CompactSize: DefaultCompactSize,
+		path:        path,
+		mode:        mode,
+	}
+}
+
+// Load will read the snapshot from disk, replay the journal and return the
+// resulting catalog. If no files exist at the specified location an empty
+// catalog is returned. A torn final record in the journal, as left by an
+// interrupted write, is discarded and truncated. A corrupt record that is
+// followed by valid records is returned as an error.
+func (s *JournalStore) Load() (*Catalog, error) {
+	// acquire lock
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	// check file
+	if s.file != nil {
+		return nil, fmt.Errorf("journal already loaded")
+	}
+
+	// load snapshot
+	var snapshot journalSnapshot
+	buf, err := os.ReadFile(s.path)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	} else if err == nil {
+		err = bson.Unmarshal(buf, &snapshot)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	// ensure namespaces
+	if snapshot.Namespaces == nil {
+		snapshot.Namespaces = map[string]FileNamespace{}
+	}
+
+	// load journal
+	buf, err = os.ReadFile(s.journalPath())
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+
+	// replay records
+	seq := snapshot.Sequence
+	var offset int
+	for offset < len(buf) {
+		// decode record
+		record, n, err := decodeJournalRecord(buf[offset:])
+		if err == errTornRecord {
+			break
+		} else if err != nil {
+			return nil, fmt.Errorf("journal record at offset %d: %w", offset, err)
+		}
+
+		// skip records already contained in the snapshot
+		if record.Sequence <= seq {
+			offset += n
+			continue
+		}
+
+		// check sequence
+		if record.Sequence != seq+1 {
+			return nil, fmt.Errorf("journal record at offset %d: unexpected sequence %d", offset, record.Sequence)
+		}
+
+		// apply record
+		err = record.apply(snapshot.Namespaces)
+		if err != nil {
+			return nil, fmt.Errorf("journal record at offset %d: %w", offset, err)
+		}
+
+		// advance
+		seq = record.Sequence
+		offset += n
+	}
+
+	// build catalog
+	file := File{Namespaces: snapshot.Namespaces}
+	catalog, err := file.BuildCatalog()
+	if err != nil {
+		return nil, err
+	}
+
+	// open journal
+	journal, err := os.OpenFile(s.journalPath(), os.O_WRONLY|os.O_CREATE|os.O_APPEND, s.mode)
+	if err != nil {
+		return nil, err
+	}
+
+	// truncate torn record
+	if offset < len(buf) {
+		err = journal.Truncate(int64(offset))
+		if err == nil {
+			err = journal.Sync()
+		}
+		if err != nil {
+			_ = journal.Close()
+			return nil, err
+		}
+	}
+
+	// set state
+	s.file = journal
+	s.size = int64(offset)
+	s.seq = seq
+	s.catalog = catalog
+
+	return catalog, nil
+}
+
+// Store will append the changes between the last stored and the provided
+// catalog to the journal. The journal is compacted in the background if it
+// exceeds the configured size.
+func (s *JournalStore) Store(catalog *Catalog) error {
+	// acquire lock
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	// check file
+	if s.file == nil {
+		return fmt.Errorf("journal not loaded")
+	}
+
+	// collect changes
+	changes := diffCatalog(s.catalog, catalog)
+	if len(changes) == 0 {
+		s.catalog = catalog
+		return nil
+	}
+
+	// encode record
+	buf, err := encodeJournalRecord(&journalRecord{
+		Sequence: s.seq + 1,
+		Changes:  changes,
+	})
+	if err != nil {
+		return err
+	}
+
+	// append record
+	_, err = s.file.Write(buf)
+	if err == nil {
+		err = s.file.Sync()
+	}
+	if err != nil {
+		// remove partially written record
+		_ = s.file.Truncate(s.size)
+		return err
+	}
+
+	// update state
+	s.size += int64(len(buf))
+	s.seq++
+	s.catalog = catalog
+
+	// compact journal if too big
+	if s.CompactSize > 0 && s.size >= s.CompactSize && !s.compacting {
+		s.compacting = true
+		s.group.Add(1)
+		go s.compact()
+	}
+
+	return nil
+}
+
+// Compact will write the last stored catalog to a new snapshot and remove all
+// records contained in it from the journal. Concurrent calls to Store may
+// continue while the snapshot is being written.
+func (s *JournalStore) Compact() error {
+	// acquire compaction lock
+	s.compaction.Lock()
+	defer s.compaction.Unlock()
+
+	// get state
+	s.mutex.Lock()
+	catalog, seq, size := s.catalog, s.seq, s.size
+	closed := s.file == nil
+	s.mutex.Unlock()
+
+	// check file
+	if closed {
+		return fmt.Errorf("journal not loaded")
+	}
+
+	// encode snapshot
+	buf, err := bson.Marshal(journalSnapshot{
+		Sequence:   seq,
+		Namespaces: BuildFile(catalog).Namespaces,
+	})
+	if err != nil {
+		return err
+	}
+
+	// write snapshot
+	err = dbkit.AtomicWriteFile(s.path, bytes.NewReader(buf), s.mode)
+	if err != nil {
+		return err
+	}
+
+	// acquire lock
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	// check file
+	if s.file == nil {
+		return nil
+	}
+
+	// read records appended since the snapshot
+	tail := make([]byte, s.size-size)
+	reader, err := os.Open(s.journalPath())
+	if err != nil {
+		return err
+	}
+	_, err = reader.ReadAt(tail, size)
+	_ = reader.Close()
+	if err != nil {
+		return err
+	}
+
+	// rewrite journal
+	err = dbkit.AtomicWriteFile(s.journalPath(), bytes.NewReader(tail), s.mode)
+	if err != nil {
+		return err
+	}
+
+	// reopen journal
+	journal, err := os.OpenFile(s.journalPath(), os.O_WRONLY|os.O_APPEND, s.mode)
+	if err != nil {
+		return err
+	}
+
+	// replace file
+	_ = s.file.Close()
+	s.file = journal
+	s.size = int64(len(tail))
+
+	return nil
+}
+
+// Close will wait for a running compaction and close the journal. It should
+// be called after the engine using the store has been closed.
+func (s *JournalStore) Close() error {
+	// await compaction
+	s.group.Wait()
+
+	// acquire lock
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	// check file
+	if s.file == nil {
+		return nil
+	}
+
+	// close file
+	err := s.file.Close()
+	s.file = nil
+
+	return err
+}
+
+func (s *JournalStore) compact() {
+	// ensure flag is reset
+	defer func() {
+		s.mutex.Lock()
+		s.compacting = false
+		s.mutex.Unlock()
+		s.group.Done()
+	}()
+
+	// compact journal
+	err := s.Compact()
+	if err != nil && s.CompactErrors != nil {
+		s.CompactErrors(err)
+	}
+}
+
+func (s *JournalStore) journalPath() string {
+	return s.path + ".journal"
+}
+
+type journalSnapshot struct {
+	Sequence   int64                    `bson:"sequence"`
+	Namespaces map[string]FileNamespace `bson:"namespaces"`
+}
+
+type journalRecord struct {
+	Sequence int64           `bson:"sequence"`
+	Changes  []journalChange `bson:"changes"`
+}
+
+type journalChange struct {
+	Namespace string               `bson:"namespace"`
+	Drop      bool                 `bson:"drop,omitempty"`
+	Indexes   map[string]FileIndex `bson:"indexes"`
+	Updated   []journalUpdate      `bson:"updated,omitempty"`
+	Removed   []int                `bson:"removed,omitempty"`
+	Inserted  bsonkit.List         `bson:"inserted,omitempty"`
+}
+
+type journalUpdate struct {
+	Position int         `bson:"position"`
+	Document bsonkit.Doc `bson:"document"`
+}
+
+func (r *journalRecord) apply(namespaces map[string]FileNamespace) error {
+	for _, change := range r.Changes {
+		// handle drop
+		if change.Drop {
+			delete(namespaces, change.Namespace)
+			continue
+		}
+
+		// get namespace
+		ns, ok := namespaces[change.Namespace]
+		if !ok {
+			ns.Indexes = map[string]FileIndex{}
+		}
+
+		// copy documents
+		docs := make(bsonkit.List, len(ns.Documents), len(ns.Documents)+len(change.Inserted))
+		copy(docs, ns.Documents)
+
+		// apply updates
+		for _, update := range change.Updated {
+			if update.Position < 0 || update.Position >= len(docs) {
+				return fmt.Errorf("invalid update position %d", update.Position)
+			}
+			docs[update.Position] = update.Document
+		}
+
+		// apply removals in descending order
+		for i := len(change.Removed) - 1; i >= 0; i-- {
+			pos := change.Removed[i]
+			if pos < 0 || pos >= len(docs) || (i > 0 && change.Removed[i-1] >= pos) {
+				return fmt.Errorf("invalid removal position %d", pos)
+			}
+			docs = append(docs[:pos], docs[pos+1:]...)
+		}
+
+		// apply insertions
+		docs = append(docs, change.Inserted...)
+		ns.Documents = docs
+
+		// apply indexes
+		if change.Indexes != nil {
+			ns.Indexes = change.Indexes
+		}
+
+		// set namespace
+		namespaces[change.Namespace] = ns
+	}
+
+	return nil
+}
+
+var errTornRecord = fmt.Errorf("torn record")
+
+// A record is framed by its little endian payload length and CRC-32 checksum.
+const journalHeaderSize = 8
+
+func encodeJournalRecord(record *journalRecord) ([]byte, error) {
+	// encode payload
+	payload, err := bson.Marshal(record)
+	if err != nil {
+		return nil, err
+	}
+
+	// frame payload
+	buf := make([]byte, journalHeaderSize+len(payload))
+	binary.LittleEndian.PutUint32(buf[0:], uint32(len(payload)))
+	binary.LittleEndian.PutUint32(buf[4:], crc32.ChecksumIEEE(payload))
+	copy(buf[journalHeaderSize:], payload)
+
+	return buf, nil
+}
+
+func decodeJournalRecord(buf []byte) (*journalRecord, int, error) {
+	// check header
+	if len(buf) < journalHeaderSize {
+		return nil, 0, errTornRecord
+	}
+
+	// get length and check payload
+	length := int(binary.LittleEndian.Uint32(buf[0:]))
+	end := journalHeaderSize + length
+	if end > len(buf) || end < journalHeaderSize {
+		// only the final record can be torn, if a valid record follows the
+		// length is corrupt and the following records must not be dropped
+		if containsJournalRecord(buf[1:]) {
+			return nil, 0, fmt.Errorf("invalid length %d", length)
+		}
+		return nil, 0, errTornRecord
+	}
+
+	// verify checksum, a mismatch in the final record or in a zero filled tail
+	// is treated as a torn write
+	payload := buf[journalHeaderSize:end]
+	if length == 0 || crc32.ChecksumIEEE(payload) != binary.LittleEndian.Uint32(buf[4:]) {
+		if end == len(buf) || isZero(buf) {
+			return nil, 0, errTornRecord
+		}
+		return nil, 0, fmt.Errorf("checksum mismatch")
+	}
+
+	// decode payload
+	var record journalRecord
+	err := bson.Unmarshal(payload, &record)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	return &record, end, nil
+}
+
+// containsJournalRecord reports whether a record with a valid checksum starts
+// anywhere in the buffer.
+func containsJournalRecord(buf []byte) bool {
+	for i := 0; i+journalHeaderSize < len(buf); i++ {
+		length := int(binary.LittleEndian.Uint32(buf[i:]))
+		end := i + journalHeaderSize + length
+		if length == 0 || end > len(buf) || end < i+journalHeaderSize {
+			continue
+		}
+		if crc32.ChecksumIEEE(buf[i+journalHeaderSize:end]) == binary.LittleEndian.Uint32(buf[i+4:]) {
+			return true
+		}
+	}
+	return false
+}
+
+func isZero(buf []byte) bool {
+	for _, b := range buf {
+		if b != 0 {
+			return false
+		}
+	}
+	return true
+}
+
+func diffCatalog(old, new *Catalog) []journalChange {
+	// prepare changes
+	var changes []journalChange
+
+	// collect dropped namespaces
+	for handle := range old.Namespaces {
+		if _, ok := new.Namespaces[handle]; !ok {
+			changes = append(changes, journalChange{
+				Namespace: handle.String(),
+				Drop:      true,
+			})
+		}
+	}
+
+	// collect changed namespaces
+	for handle, namespace := range new.Namespaces {
+		// namespaces are copied on write, an identical namespace is unchanged
+		previous := old.Namespaces[handle]
+		if previous == namespace {
+			continue
+		}
+
+		// diff namespace
+		change, ok := diffNamespace(previous, namespace)
+		if ok {
+			change.Namespace = handle.String()
+			changes = append(changes, change)
+		}
+	}
+
+	// sort changes
+	sort.SliceStable(changes, func(i, j int) bool {
+		return changes[i].Namespace < changes[j].Namespace
+	})
+
+	return changes
+}
+
+func diffNamespace(old, new *mongokit.Collection) (journalChange, bool) {
+	// prepare change
+	var change journalChange
+
+	// handle new namespace
+	if old == nil {
+		change.Indexes = fileIndexes(new)
+		change.Inserted = new.Documents.List
+		return change, true
+	}
+
+	// compare indexes
+	indexes := fileIndexes(new)
+	if !reflect.DeepEqual(fileIndexes(old), indexes) {
+		change.Indexes = indexes
+	}
+
+	// documents are immutable and may only be appended, replaced in place or
+	// removed, walk the old documents while tracking their expected position
+	var pos int
+	for i, doc := range old.Documents.List {
+		// check if kept
+		if p, ok := new.Documents.Index[doc]; ok {
+			if p != pos {
+				return resetNamespace(old, new, change), true
+			}
+			pos++
+			continue
+		}
+
+		// check if replaced in place
+		if pos < len(new.Documents.List) {
+			repl := new.Documents.List[pos]
+			if _, ok := old.Documents.Index[repl]; !ok {
+				change.Updated = append(change.Updated, journalUpdate{
+					Position: i,
+					Document: repl,
+				})
+				pos++
+				continue
+			}
+		}
+
+		// otherwise, removed
+		change.Removed = append(change.Removed, i)
+	}
+
+	// collect inserted documents
+	for _, doc := range new.Documents.List[pos:] {
+		if _, ok := old.Documents.Index[doc]; ok {
+			return resetNamespace(old, new, change), true
+		}
+		change.Inserted = append(change.Inserted, doc)
+	}
+
+	// check change
+	if change.Indexes == nil && len(change.Updated) == 0 && len(change.Removed) == 0 && len(change.Inserted) == 0 {
+		return change, false
+	}
+
+	return change, true
+}
+
+func resetNamespace(old, new *mongokit.Collection, change journalChange) journalChange {
+	// remove all old and insert all new documents
+	change.Updated = nil
+	change.Removed = make([]int, len(old.Documents.List))
+	for i := range change.Removed {
+		change.Removed[i] = i
+	}
+	change.Inserted = new.Documents.List
+
+	return change
+}
+
+func fileIndexes(namespace *mongokit.Collection) map[string]FileIndex {
+	// collect indexes
+	indexes := map[string]FileIndex{}
+	for name, index := range namespace.Indexes {
+		config := index.Config()
+		indexes[name] = FileIndex{
+			Key:     config.Key,
+			Unique:  config.Unique,
+			Partial: config.Partial,
+			Expiry:  config.Expiry,
+		}
+	}
+
+	return indexes
+}
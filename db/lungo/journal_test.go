@@ -0,0 +1,246 @@
+package lungo
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/unix-world/smartgoext/db/mongo-driver/bson"
+
+	"github.com/unix-world/smartgoext/db/lungo/bsonkit"
+)
+
+var journalHandle = Handle{"foo", "bar"}
+
+func openJournal(t *testing.T, path string) (*Engine, *JournalStore) {
+	t.Helper()
+	store := NewJournalStore(path, 0666)
+	engine, err := CreateEngine(Options{Store: store})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return engine, store
+}
+
+func closeJournal(t *testing.T, engine *Engine, store *JournalStore) {
+	t.Helper()
+	engine.Close()
+	if err := store.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func journalCommit(t *testing.T, engine *Engine, fn func(txn *Transaction) error) {
+	t.Helper()
+	txn, err := engine.Begin(nil, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer engine.Abort(txn)
+	if err = fn(txn); err != nil {
+		t.Fatal(err)
+	}
+	if err = engine.Commit(txn); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func journalInsert(t *testing.T, engine *Engine, values ...int32) {
+	t.Helper()
+	journalCommit(t, engine, func(txn *Transaction) error {
+		var list bsonkit.List
+		for _, v := range values {
+			list = append(list, bsonkit.MustConvert(bson.M{"_id": v, "v": v}))
+		}
+		_, err := txn.Insert(journalHandle, list, true)
+		return err
+	})
+}
+
+func journalValues(engine *Engine) []int32 {
+	ns := engine.Catalog().Namespaces[journalHandle]
+	if ns == nil {
+		return nil
+	}
+	var values []int32
+	for _, doc := range ns.Documents.List {
+		values = append(values, bsonkit.Get(doc, "v").(int32))
+	}
+	return values
+}
+
+func assertValues(t *testing.T, engine *Engine, want ...int32) {
+	t.Helper()
+	if got := journalValues(engine); !reflect.DeepEqual(got, want) {
+		t.Fatalf("got documents %v, want %v", got, want)
+	}
+}
+
+func fileSize(t *testing.T, path string) int64 {
+	t.Helper()
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return info.Size()
+}
+
+func TestJournalStoreReopen(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "test.bson")
+
+	engine, store := openJournal(t, path)
+	journalInsert(t, engine, 1, 2)
+	journalInsert(t, engine, 3)
+	journalCommit(t, engine, func(txn *Transaction) error {
+		_, err := txn.Update(journalHandle, bsonkit.MustConvert(bson.M{"_id": int32(2)}), nil,
+			bsonkit.MustConvert(bson.M{"$set": bson.M{"v": int32(20)}}), 0, 1, false, nil)
+		return err
+	})
+	journalCommit(t, engine, func(txn *Transaction) error {
+		_, err := txn.Delete(journalHandle, bsonkit.MustConvert(bson.M{"_id": int32(1)}), nil, 0, 1)
+		return err
+	})
+	assertValues(t, engine, 20, 3)
+	closeJournal(t, engine, store)
+
+	// only the journal has been written
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Fatalf("snapshot written without compaction: %v", err)
+	}
+
+	engine, store = openJournal(t, path)
+	assertValues(t, engine, 20, 3)
+	journalInsert(t, engine, 4)
+	closeJournal(t, engine, store)
+
+	engine, store = openJournal(t, path)
+	defer closeJournal(t, engine, store)
+	assertValues(t, engine, 20, 3, 4)
+}
+
+func TestJournalStoreTornTail(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "test.bson")
+
+	engine, store := openJournal(t, path)
+	journalInsert(t, engine, 1)
+	journalInsert(t, engine, 2)
+	closeJournal(t, engine, store)
+	size := fileSize(t, path+".journal")
+
+	// append the start of a record that was never finished
+	record, err := encodeJournalRecord(&journalRecord{Sequence: 100})
+	if err != nil {
+		t.Fatal(err)
+	}
+	journal, err := os.OpenFile(path+".journal", os.O_WRONLY|os.O_APPEND, 0666)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = journal.Write(record[:len(record)-3]); err != nil {
+		t.Fatal(err)
+	}
+	if err = journal.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	engine, store = openJournal(t, path)
+	assertValues(t, engine, 1, 2)
+	if got := fileSize(t, path+".journal"); got != size {
+		t.Fatalf("torn record not truncated: size %d, want %d", got, size)
+	}
+	journalInsert(t, engine, 3)
+	closeJournal(t, engine, store)
+
+	engine, store = openJournal(t, path)
+	defer closeJournal(t, engine, store)
+	assertValues(t, engine, 1, 2, 3)
+}
+
+func TestJournalStoreCorruptRecord(t *testing.T) {
+	for _, tc := range []struct {
+		name    string
+		corrupt func(buf []byte)
+	}{
+		{"length", func(buf []byte) { buf[3] = 0x7f }},
+		{"checksum", func(buf []byte) { buf[4] ^= 0xff }},
+		{"payload", func(buf []byte) { buf[journalHeaderSize+10] ^= 0xff }},
+	} {
+		path := filepath.Join(t.TempDir(), "test.bson")
+
+		engine, store := openJournal(t, path)
+		journalInsert(t, engine, 1)
+		journalInsert(t, engine, 2)
+		closeJournal(t, engine, store)
+
+		// corrupt the first of the records
+		buf, err := os.ReadFile(path + ".journal")
+		if err != nil {
+			t.Fatal(err)
+		}
+		tc.corrupt(buf)
+		if err = os.WriteFile(path+".journal", buf, 0666); err != nil {
+			t.Fatal(err)
+		}
+
+		store = NewJournalStore(path, 0666)
+		if _, err = store.Load(); err == nil {
+			t.Errorf("%s: corrupt record followed by valid records loaded", tc.name)
+			_ = store.Close()
+		}
+		if got := fileSize(t, path+".journal"); got != int64(len(buf)) {
+			t.Errorf("%s: journal truncated to %d bytes", tc.name, got)
+		}
+	}
+}
+
+func TestJournalStoreCompact(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "test.bson")
+
+	engine, store := openJournal(t, path)
+	journalInsert(t, engine, 1, 2)
+	journalInsert(t, engine, 3)
+	if err := store.Compact(); err != nil {
+		t.Fatal(err)
+	}
+	if got := fileSize(t, path+".journal"); got != 0 {
+		t.Fatalf("journal not emptied by compaction: size %d", got)
+	}
+	journalInsert(t, engine, 4)
+	closeJournal(t, engine, store)
+
+	// snapshot and journal
+	engine, store = openJournal(t, path)
+	assertValues(t, engine, 1, 2, 3, 4)
+	closeJournal(t, engine, store)
+
+	// the snapshot alone is a file store
+	fileEngine, err := CreateEngine(Options{Store: NewFileStore(path, 0666)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertValues(t, fileEngine, 1, 2, 3)
+	fileEngine.Close()
+}
+
+func TestJournalStoreCompactBackground(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "test.bson")
+
+	engine, store := openJournal(t, path)
+	store.CompactSize = 1
+	store.CompactErrors = func(err error) {
+		t.Error(err)
+	}
+	for i := int32(1); i <= 5; i++ {
+		journalInsert(t, engine, i)
+	}
+	closeJournal(t, engine, store)
+
+	if fileSize(t, path) == 0 {
+		t.Fatal("no snapshot written by background compaction")
+	}
+
+	engine, store = openJournal(t, path)
+	defer closeJournal(t, engine, store)
+	assertValues(t, engine, 1, 2, 3, 4, 5)
+}
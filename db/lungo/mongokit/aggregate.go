@@ -0,0 +1,781 @@
+package mongokit
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/unix-world/smartgoext/db/mongo-driver/bson"
+
+	"github.com/unix-world/smartgoext/db/lungo/bsonkit"
+)
+
+// https://www.mongodb.com/docs/manual/reference/operator/aggregation-pipeline
+
+// Lookup returns the documents of the named collection, it is used by the
+// $lookup stage to join documents from another collection.
+type Lookup func(collection string) (bsonkit.List, error)
+
+// Stage is an aggregation pipeline stage. It receives the documents from the
+// previous stage and returns the documents for the next one.
+type Stage func(list bsonkit.List, name string, spec interface{}, lookup Lookup) (bsonkit.List, error)
+
+// Accumulator is a $group accumulator. It is called for each document of the
+// group with the current state (nil for the first document) and returns the
+// new state. It is called once more with a nil document to get the result.
+type Accumulator func(state interface{}, doc bsonkit.Doc, expr interface{}) (interface{}, error)
+
+// AggregationStages defines the available aggregation pipeline stages.
+var AggregationStages = map[string]Stage{}
+
+// GroupAccumulators defines the available $group accumulators.
+var GroupAccumulators = map[string]Accumulator{}
+
+func init() {
+	// register aggregation stages
+	AggregationStages["$match"] = stageMatch
+	AggregationStages["$project"] = stageProject
+	AggregationStages["$addFields"] = stageAddFields
+	AggregationStages["$set"] = stageAddFields
+	AggregationStages["$group"] = stageGroup
+	AggregationStages["$sort"] = stageSort
+	AggregationStages["$skip"] = stageSkip
+	AggregationStages["$limit"] = stageLimit
+	AggregationStages["$unwind"] = stageUnwind
+	AggregationStages["$count"] = stageCount
+	AggregationStages["$lookup"] = stageLookup
+
+	// register group accumulators
+	GroupAccumulators["$sum"] = accumulateSum
+	GroupAccumulators["$avg"] = accumulateAvg
+	GroupAccumulators["$min"] = accumulateMin
+	GroupAccumulators["$max"] = accumulateMax
+	GroupAccumulators["$push"] = accumulatePush
+	GroupAccumulators["$first"] = accumulateFirst
+	GroupAccumulators["$last"] = accumulateLast
+}
+
+// Aggregate will run the aggregation pipeline on the list of documents and
+// return the resulting list. The documents of the list are not modified. The
+// lookup function is used to resolve other collections, it may be nil if the
+// pipeline has no $lookup stage.
+func Aggregate(list bsonkit.List, pipeline bsonkit.List, lookup Lookup) (bsonkit.List, error) {
+	// run stages
+	for _, stage := range pipeline {
+		// check stage
+		if len(*stage) != 1 {
+			return nil, fmt.Errorf("a pipeline stage must have exactly one field")
+		}
+
+		// lookup stage
+		name := (*stage)[0].Key
+		fn := AggregationStages[name]
+		if fn == nil {
+			return nil, fmt.Errorf("unknown pipeline stage %q", name)
+		}
+
+		// run stage
+		var err error
+		list, err = fn(list, name, (*stage)[0].Value, lookup)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return list, nil
+}
+
+func stageMatch(list bsonkit.List, name string, spec interface{}, _ Lookup) (bsonkit.List, error) {
+	// get query
+	query, ok := spec.(bson.D)
+	if !ok {
+		return nil, fmt.Errorf("%s: expected document", name)
+	}
+
+	return Filter(list, &query, 0)
+}
+
+func stageProject(list bsonkit.List, name string, spec interface{}, _ Lookup) (bsonkit.List, error) {
+	// get specification
+	doc, ok := spec.(bson.D)
+	if !ok {
+		return nil, fmt.Errorf("%s: expected document", name)
+	} else if len(doc) == 0 {
+		return nil, fmt.Errorf("%s: expected at least one field", name)
+	}
+
+	// flatten nested specifications
+	fields := flattenProjection(doc, "")
+
+	// sort out fields
+	hideID := false
+	var include, exclude []string
+	var computed bson.D
+	for _, field := range fields {
+		switch value := field.Value.(type) {
+		case bool, int32, int64, float64:
+			if Truthy(value) {
+				include = append(include, field.Key)
+			} else if field.Key == "_id" {
+				hideID = true
+			} else {
+				exclude = append(exclude, field.Key)
+			}
+		default:
+			computed = append(computed, field)
+		}
+	}
+
+	// check mode
+	if len(exclude) > 0 && (len(include) > 0 || len(computed) > 0) {
+		return nil, fmt.Errorf("%s: cannot have a mix of inclusion and exclusion", name)
+	}
+
+	// project documents
+	result := make(bsonkit.List, 0, len(list))
+	for _, doc := range list {
+		var res bsonkit.Doc
+		if len(include) == 0 && len(computed) == 0 {
+			// unset excluded fields
+			res = bsonkit.Clone(doc)
+			for _, path := range exclude {
+				bsonkit.Unset(res, path)
+			}
+		} else {
+			// copy id and included fields
+			res = &bson.D{}
+			for _, path := range append([]string{"_id"}, include...) {
+				value := bsonkit.Get(doc, path)
+				if value == bsonkit.Missing {
+					continue
+				}
+				_, err := bsonkit.Put(res, path, value, false)
+				if err != nil {
+					return nil, err
+				}
+			}
+
+			// set computed fields
+			for _, field := range computed {
+				value, err := Evaluate(doc, field.Value)
+				if err != nil {
+					return nil, err
+				}
+				if value == bsonkit.Missing {
+					continue
+				}
+				_, err = bsonkit.Put(res, field.Key, value, false)
+				if err != nil {
+					return nil, err
+				}
+			}
+		}
+
+		// hide id
+		if hideID {
+			bsonkit.Unset(res, "_id")
+		}
+
+		result = append(result, res)
+	}
+
+	return result, nil
+}
+
+// flattenProjection turns nested projections like {a: {b: 1}} into dotted
+// paths like {"a.b": 1}. Documents starting with an operator are expressions
+// and left as is.
+func flattenProjection(spec bson.D, prefix string) bson.D {
+	var fields bson.D
+	for _, field := range spec {
+		path := field.Key
+		if prefix != "" {
+			path = prefix + "." + path
+		}
+		if doc, ok := field.Value.(bson.D); ok && len(doc) > 0 && !strings.HasPrefix(doc[0].Key, "$") {
+			fields = append(fields, flattenProjection(doc, path)...)
+			continue
+		}
+		fields = append(fields, bson.E{Key: path, Value: field.Value})
+	}
+	return fields
+}
+
+func stageAddFields(list bsonkit.List, name string, spec interface{}, _ Lookup) (bsonkit.List, error) {
+	// get specification
+	fields, ok := spec.(bson.D)
+	if !ok {
+		return nil, fmt.Errorf("%s: expected document", name)
+	}
+
+	// add fields
+	result := make(bsonkit.List, 0, len(list))
+	for _, doc := range list {
+		res := bsonkit.Clone(doc)
+		for _, field := range fields {
+			// evaluate against the original document
+			value, err := Evaluate(doc, field.Value)
+			if err != nil {
+				return nil, err
+			}
+
+			// unset field if removed
+			if value == bsonkit.Missing {
+				bsonkit.Unset(res, field.Key)
+				continue
+			}
+
+			// set field
+			_, err = bsonkit.Put(res, field.Key, value, false)
+			if err != nil {
+				return nil, err
+			}
+		}
+		result = append(result, res)
+	}
+
+	return result, nil
+}
+
+type groupState struct {
+	id     interface{}
+	values []interface{}
+}
+
+func stageGroup(list bsonkit.List, name string, spec interface{}, _ Lookup) (bsonkit.List, error) {
+	// get specification
+	fields, ok := spec.(bson.D)
+	if !ok {
+		return nil, fmt.Errorf("%s: expected document", name)
+	}
+
+	// get id expression and accumulators
+	var idExpr interface{}
+	var hasID bool
+	var accFields []string
+	var accFuncs []Accumulator
+	var accExprs []interface{}
+	for _, field := range fields {
+		// handle id
+		if field.Key == "_id" {
+			idExpr = field.Value
+			hasID = true
+			continue
+		}
+
+		// get accumulator
+		acc, ok := field.Value.(bson.D)
+		if !ok || len(acc) != 1 {
+			return nil, fmt.Errorf("%s: the field %q must be an accumulator object", name, field.Key)
+		}
+		fn := GroupAccumulators[acc[0].Key]
+		if fn == nil {
+			return nil, fmt.Errorf("%s: unknown group accumulator %q", name, acc[0].Key)
+		}
+
+		accFields = append(accFields, field.Key)
+		accFuncs = append(accFuncs, fn)
+		accExprs = append(accExprs, acc[0].Value)
+	}
+	if !hasID {
+		return nil, fmt.Errorf("%s: a group specification must include an _id", name)
+	}
+
+	// group documents, in order of appearance
+	var groups []*groupState
+	index := map[string]*groupState{}
+	for _, doc := range list {
+		// evaluate id
+		id, err := Evaluate(doc, idExpr)
+		if err != nil {
+			return nil, err
+		}
+		if id == bsonkit.Missing {
+			id = nil
+		}
+
+		// get group
+		key, err := groupKey(id)
+		if err != nil {
+			return nil, err
+		}
+		group := index[key]
+		if group == nil {
+			group = &groupState{id: id, values: make([]interface{}, len(accFuncs))}
+			index[key] = group
+			groups = append(groups, group)
+		}
+
+		// accumulate
+		for i, fn := range accFuncs {
+			group.values[i], err = fn(group.values[i], doc, accExprs[i])
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	// build documents
+	result := make(bsonkit.List, 0, len(groups))
+	for _, group := range groups {
+		doc := bson.D{{Key: "_id", Value: group.id}}
+		for i, fn := range accFuncs {
+			value, err := fn(group.values[i], nil, accExprs[i])
+			if err != nil {
+				return nil, err
+			}
+			doc = append(doc, bson.E{Key: accFields[i], Value: value})
+		}
+		result = append(result, &doc)
+	}
+
+	return result, nil
+}
+
+// groupKey returns a key for the group id, equal ids get equal keys.
+func groupKey(id interface{}) (string, error) {
+	// normalize numbers, 1 and 1.0 are the same group
+	if f, ok := toFloat64(id); ok {
+		id = f
+	}
+
+	// marshal value
+	bytes, err := bson.Marshal(bson.D{{Key: "k", Value: id}})
+	if err != nil {
+		return "", err
+	}
+
+	return string(bytes), nil
+}
+
+type avgState struct {
+	sum   float64
+	count int
+}
+
+type pickState struct {
+	value interface{}
+	set   bool
+}
+
+func accumulateSum(state interface{}, doc bsonkit.Doc, expr interface{}) (interface{}, error) {
+	// return result
+	if doc == nil {
+		if state == nil {
+			return int32(0), nil
+		}
+		return state, nil
+	}
+
+	// evaluate value
+	value, err := Evaluate(doc, expr)
+	if err != nil {
+		return nil, err
+	}
+
+	// prepare state
+	if state == nil {
+		state = int32(0)
+	}
+
+	// add numbers, other values (including arrays) are ignored
+	if sum := addNumbers(state, value); sum != bsonkit.Missing {
+		state = sum
+	}
+
+	return state, nil
+}
+
+func accumulateAvg(state interface{}, doc bsonkit.Doc, expr interface{}) (interface{}, error) {
+	// prepare state
+	avg, _ := state.(*avgState)
+	if avg == nil {
+		avg = &avgState{}
+	}
+
+	// return result
+	if doc == nil {
+		if avg.count == 0 {
+			return nil, nil
+		}
+		return avg.sum / float64(avg.count), nil
+	}
+
+	// evaluate value
+	value, err := Evaluate(doc, expr)
+	if err != nil {
+		return nil, err
+	}
+
+	// add number, other values are ignored
+	if f, ok := toFloat64(value); ok {
+		avg.sum += f
+		avg.count++
+	}
+
+	return avg, nil
+}
+
+func accumulateMin(state interface{}, doc bsonkit.Doc, expr interface{}) (interface{}, error) {
+	return accumulateExtreme(state, doc, expr, -1)
+}
+
+func accumulateMax(state interface{}, doc bsonkit.Doc, expr interface{}) (interface{}, error) {
+	return accumulateExtreme(state, doc, expr, 1)
+}
+
+func accumulateExtreme(state interface{}, doc bsonkit.Doc, expr interface{}, direction int) (interface{}, error) {
+	// prepare state
+	pick, _ := state.(*pickState)
+	if pick == nil {
+		pick = &pickState{}
+	}
+
+	// return result
+	if doc == nil {
+		return pick.value, nil
+	}
+
+	// evaluate value
+	value, err := Evaluate(doc, expr)
+	if err != nil {
+		return nil, err
+	}
+
+	// null and missing values are ignored
+	if isNullish(value) {
+		return pick, nil
+	}
+
+	// keep value
+	if !pick.set || bsonkit.Compare(value, pick.value)*direction > 0 {
+		pick.value = value
+		pick.set = true
+	}
+
+	return pick, nil
+}
+
+func accumulatePush(state interface{}, doc bsonkit.Doc, expr interface{}) (interface{}, error) {
+	// prepare state
+	array, _ := state.(bson.A)
+	if array == nil {
+		array = bson.A{}
+	}
+
+	// return result
+	if doc == nil {
+		return array, nil
+	}
+
+	// evaluate value
+	value, err := Evaluate(doc, expr)
+	if err != nil {
+		return nil, err
+	}
+
+	// missing values are skipped
+	if value == bsonkit.Missing {
+		return array, nil
+	}
+
+	return append(array, value), nil
+}
+
+func accumulateFirst(state interface{}, doc bsonkit.Doc, expr interface{}) (interface{}, error) {
+	return accumulatePick(state, doc, expr, false)
+}
+
+func accumulateLast(state interface{}, doc bsonkit.Doc, expr interface{}) (interface{}, error) {
+	return accumulatePick(state, doc, expr, true)
+}
+
+func accumulatePick(state interface{}, doc bsonkit.Doc, expr interface{}, last bool) (interface{}, error) {
+	// prepare state
+	pick, _ := state.(*pickState)
+	if pick == nil {
+		pick = &pickState{}
+	}
+
+	// return result
+	if doc == nil {
+		return pick.value, nil
+	}
+
+	// keep first value
+	if pick.set && !last {
+		return pick, nil
+	}
+
+	// evaluate value
+	value, err := Evaluate(doc, expr)
+	if err != nil {
+		return nil, err
+	}
+	if value == bsonkit.Missing {
+		value = nil
+	}
+
+	// set value
+	pick.value = value
+	pick.set = true
+
+	return pick, nil
+}
+
+func stageSort(list bsonkit.List, name string, spec interface{}, _ Lookup) (bsonkit.List, error) {
+	// get sort document
+	doc, ok := spec.(bson.D)
+	if !ok || len(doc) == 0 {
+		return nil, fmt.Errorf("%s: expected non empty document", name)
+	}
+
+	return Sort(list, &doc)
+}
+
+func stageSkip(list bsonkit.List, name string, spec interface{}, _ Lookup) (bsonkit.List, error) {
+	// get number
+	num, ok := toInt(spec)
+	if !ok || num < 0 {
+		return nil, fmt.Errorf("%s: expected non negative integer", name)
+	}
+
+	// apply skip
+	if num >= len(list) {
+		return bsonkit.List{}, nil
+	}
+
+	return list[num:], nil
+}
+
+func stageLimit(list bsonkit.List, name string, spec interface{}, _ Lookup) (bsonkit.List, error) {
+	// get number
+	num, ok := toInt(spec)
+	if !ok || num <= 0 {
+		return nil, fmt.Errorf("%s: expected positive integer", name)
+	}
+
+	// apply limit
+	if num < len(list) {
+		return list[:num], nil
+	}
+
+	return list, nil
+}
+
+func stageUnwind(list bsonkit.List, name string, spec interface{}, _ Lookup) (bsonkit.List, error) {
+	// get options
+	var path, indexField string
+	var preserve bool
+	switch spec := spec.(type) {
+	case string:
+		path = spec
+	case bson.D:
+		for _, field := range spec {
+			switch field.Key {
+			case "path":
+				path, _ = field.Value.(string)
+			case "includeArrayIndex":
+				indexField, _ = field.Value.(string)
+			case "preserveNullAndEmptyArrays":
+				preserve, _ = field.Value.(bool)
+			default:
+				return nil, fmt.Errorf("%s: unknown option %q", name, field.Key)
+			}
+		}
+	default:
+		return nil, fmt.Errorf("%s: expected string or document", name)
+	}
+
+	// check path
+	if !strings.HasPrefix(path, "$") || len(path) == 1 {
+		return nil, fmt.Errorf("%s: path must be a field path prefixed with $", name)
+	}
+	path = path[1:]
+
+	// unwind documents
+	var result bsonkit.List
+	for _, doc := range list {
+		value := bsonkit.Get(doc, path)
+
+		// handle arrays
+		if array, ok := value.(bson.A); ok && len(array) > 0 {
+			for i, item := range array {
+				res := bsonkit.Clone(doc)
+				_, err := bsonkit.Put(res, path, item, false)
+				if err != nil {
+					return nil, err
+				}
+				if indexField != "" {
+					_, err = bsonkit.Put(res, indexField, int64(i), false)
+					if err != nil {
+						return nil, err
+					}
+				}
+				result = append(result, res)
+			}
+			continue
+		}
+
+		// handle non array values
+		_, isArray := value.(bson.A)
+		if !isArray && !isNullish(value) {
+			res := doc
+			if indexField != "" {
+				res = bsonkit.Clone(doc)
+				_, err := bsonkit.Put(res, indexField, nil, false)
+				if err != nil {
+					return nil, err
+				}
+			}
+			result = append(result, res)
+			continue
+		}
+
+		// handle null, missing and empty arrays, empty arrays are removed
+		if preserve {
+			res := doc
+			if indexField != "" || isArray {
+				res = bsonkit.Clone(doc)
+			}
+			if isArray {
+				bsonkit.Unset(res, path)
+			}
+			if indexField != "" {
+				_, err := bsonkit.Put(res, indexField, nil, false)
+				if err != nil {
+					return nil, err
+				}
+			}
+			result = append(result, res)
+		}
+	}
+
+	return result, nil
+}
+
+func stageCount(list bsonkit.List, name string, spec interface{}, _ Lookup) (bsonkit.List, error) {
+	// get field
+	field, ok := spec.(string)
+	if !ok || field == "" || strings.HasPrefix(field, "$") || strings.Contains(field, ".") {
+		return nil, fmt.Errorf("%s: expected a field name", name)
+	}
+
+	// no documents, no count
+	if len(list) == 0 {
+		return bsonkit.List{}, nil
+	}
+
+	return bsonkit.List{&bson.D{{Key: field, Value: int32(len(list))}}}, nil
+}
+
+func stageLookup(list bsonkit.List, name string, spec interface{}, lookup Lookup) (bsonkit.List, error) {
+	// get options
+	doc, ok := spec.(bson.D)
+	if !ok {
+		return nil, fmt.Errorf("%s: expected document", name)
+	}
+	var from, localField, foreignField, as string
+	var pipeline bsonkit.List
+	for _, field := range doc {
+		switch field.Key {
+		case "from":
+			from, _ = field.Value.(string)
+		case "localField":
+			localField, _ = field.Value.(string)
+		case "foreignField":
+			foreignField, _ = field.Value.(string)
+		case "as":
+			as, _ = field.Value.(string)
+		case "pipeline":
+			array, ok := field.Value.(bson.A)
+			if !ok {
+				return nil, fmt.Errorf("%s: expected array as pipeline", name)
+			}
+			for _, item := range array {
+				stage, ok := item.(bson.D)
+				if !ok {
+					return nil, fmt.Errorf("%s: expected array of documents as pipeline", name)
+				}
+				pipeline = append(pipeline, &stage)
+			}
+		case "let":
+			return nil, fmt.Errorf("%s: let variables are not supported", name)
+		default:
+			return nil, fmt.Errorf("%s: unsupported option %q", name, field.Key)
+		}
+	}
+
+	// check options
+	if from == "" || as == "" {
+		return nil, fmt.Errorf("%s: from and as are required", name)
+	} else if (localField == "") != (foreignField == "") {
+		return nil, fmt.Errorf("%s: localField and foreignField must be given together", name)
+	} else if localField == "" && pipeline == nil {
+		return nil, fmt.Errorf("%s: localField and foreignField or pipeline are required", name)
+	} else if lookup == nil {
+		return nil, fmt.Errorf("%s: no collections available", name)
+	}
+
+	// get foreign documents
+	foreign, err := lookup(from)
+	if err != nil {
+		return nil, err
+	}
+
+	// run an uncorrelated pipeline only once
+	if localField == "" {
+		foreign, err = Aggregate(foreign, pipeline, lookup)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	// join documents
+	result := make(bsonkit.List, 0, len(list))
+	for _, doc := range list {
+		// match foreign documents
+		matched := foreign
+		if localField != "" {
+			// get local value, missing matches null
+			value, _ := bsonkit.All(doc, localField, true, true)
+			if value == bsonkit.Missing {
+				value = nil
+			}
+
+			// match any value of arrays
+			var query bson.D
+			if array, ok := value.(bson.A); ok {
+				query = bson.D{{Key: foreignField, Value: bson.D{{Key: "$in", Value: array}}}}
+			} else {
+				query = bson.D{{Key: foreignField, Value: bson.D{{Key: "$eq", Value: value}}}}
+			}
+
+			matched, err = Filter(foreign, &query, 0)
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		// run pipeline on matched documents
+		if localField != "" && pipeline != nil {
+			matched, err = Aggregate(matched, pipeline, lookup)
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		// add matched documents
+		array := make(bson.A, 0, len(matched))
+		for _, m := range matched {
+			array = append(array, *bsonkit.Clone(m))
+		}
+		res := bsonkit.Clone(doc)
+		_, err = bsonkit.Put(res, as, array, false)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, res)
+	}
+
+	return result, nil
+}
@@ -3,6 +3,7 @@ package mongokit
 import (
 	"fmt"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/unix-world/smartgoext/db/mongo-driver/bson"
@@ -27,6 +28,10 @@ func init() {
 	FieldUpdateOperators["$currentDate"] = applyCurrentDate
 	FieldUpdateOperators["$push"] = applyPush
 	FieldUpdateOperators["$pop"] = applyPop
+	FieldUpdateOperators["$addToSet"] = applyAddToSet
+	FieldUpdateOperators["$pull"] = applyPull
+	FieldUpdateOperators["$pullAll"] = applyPullAll
+	FieldUpdateOperators["$bit"] = applyBit
 }
 
 // Changes record the applied changes to a document.
@@ -409,3 +414,247 @@ func applyPop(ctx Context, doc bsonkit.Doc, name, path string, v interface{}) er
 
 	return nil
 }
+
+func applyAddToSet(ctx Context, doc bsonkit.Doc, name, path string, v interface{}) error {
+	// get values
+	values := bson.A{v}
+	if d, ok := v.(bson.D); ok && len(d) > 0 && d[0].Key == "$each" {
+		// check modifiers
+		if len(d) > 1 {
+			return fmt.Errorf("found unexpected fields after $each in $addToSet: %s", d[1].Key)
+		}
+
+		// get array
+		array, ok := d[0].Value.(bson.A)
+		if !ok {
+			return fmt.Errorf("The argument to $each in %s must be an array but it was of type %s", name, typeName(d[0].Value))
+		}
+		values = array
+	}
+
+	// get field
+	field := bsonkit.Get(doc, path)
+
+	// get array
+	var array bson.A
+	switch value := field.(type) {
+	case bson.A:
+		array = value
+	case bsonkit.MissingType:
+	default:
+		return fmt.Errorf("Cannot apply %s to non-array field. Field named '%s' has non-array type %s", name, lastSegment(path), typeName(field))
+	}
+
+	// add missing values
+	result := append(bson.A{}, array...)
+	for _, value := range values {
+		if !contains(result, value) {
+			result = append(result, value)
+		}
+	}
+
+	// check if changed
+	if field != bsonkit.Missing && len(result) == len(array) {
+		return nil
+	}
+
+	// set array
+	_, err := bsonkit.Put(doc, path, result, false)
+	if err != nil {
+		return err
+	}
+
+	// record change
+	err = ctx.Value.(*Changes).Record(path, result)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func applyPull(ctx Context, doc bsonkit.Doc, name, path string, v interface{}) error {
+	// prepare matcher
+	var matcher func(interface{}) (bool, error)
+	if query, ok := v.(bson.D); ok && len(query) > 0 && strings.HasPrefix(query[0].Key, "$") {
+		// match elements with expression operators
+		matcher = func(item interface{}) (bool, error) {
+			return Match(&bson.D{{Key: "item", Value: item}}, &bson.D{{Key: "item", Value: query}})
+		}
+	} else if ok {
+		// match document elements with query
+		matcher = func(item interface{}) (bool, error) {
+			doc, ok := item.(bson.D)
+			if !ok {
+				return false, nil
+			}
+			return Match(&doc, &query)
+		}
+	} else {
+		// match equal elements
+		matcher = func(item interface{}) (bool, error) {
+			return bsonkit.Compare(item, v) == 0, nil
+		}
+	}
+
+	return applyCull(ctx, doc, name, path, matcher)
+}
+
+func applyPullAll(ctx Context, doc bsonkit.Doc, name, path string, v interface{}) error {
+	// get values
+	values, ok := v.(bson.A)
+	if !ok {
+		return fmt.Errorf("%s requires an array argument but was given a %s", name, typeName(v))
+	}
+
+	return applyCull(ctx, doc, name, path, func(item interface{}) (bool, error) {
+		return contains(values, item), nil
+	})
+}
+
+func applyCull(ctx Context, doc bsonkit.Doc, name, path string, matcher func(interface{}) (bool, error)) error {
+	// get field
+	field := bsonkit.Get(doc, path)
+	if field == bsonkit.Missing {
+		return nil
+	}
+
+	// get array
+	array, ok := field.(bson.A)
+	if !ok {
+		return fmt.Errorf("Cannot apply %s to a non-array value", name)
+	}
+
+	// remove matching elements
+	result := make(bson.A, 0, len(array))
+	for _, item := range array {
+		ok, err := matcher(item)
+		if err != nil {
+			return err
+		} else if !ok {
+			result = append(result, item)
+		}
+	}
+
+	// check if changed
+	if len(result) == len(array) {
+		return nil
+	}
+
+	// set array
+	_, err := bsonkit.Put(doc, path, result, false)
+	if err != nil {
+		return err
+	}
+
+	// record change
+	err = ctx.Value.(*Changes).Record(path, result)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func applyBit(ctx Context, doc bsonkit.Doc, name, path string, v interface{}) error {
+	// get operations
+	ops, ok := v.(bson.D)
+	if !ok {
+		return fmt.Errorf("The %s modifier is not compatible with a %s. You must pass in an embedded document: {%s: {field: {and/or/xor: #}}", name, typeName(v), name)
+	}
+
+	// check operations
+	if len(ops) == 0 {
+		return fmt.Errorf("You must pass in at least one bitwise operation. The format is: {%s: {field: {and/or/xor: #}}", name)
+	}
+
+	// get field
+	field := bsonkit.Get(doc, path)
+	if field == bsonkit.Missing {
+		field = int32(0)
+	}
+
+	// check field
+	switch field.(type) {
+	case int32, int64:
+	default:
+		return fmt.Errorf("Cannot apply %s to a value of non-integral type. Field named '%s' has non-integer type %s", name, lastSegment(path), typeName(field))
+	}
+
+	// apply operations
+	value := field
+	for _, op := range ops {
+		// check operand
+		switch op.Value.(type) {
+		case int32, int64:
+		default:
+			return fmt.Errorf("The %s modifier field must be an Integer(32/64 bit); a '%s' is not supported here", name, typeName(op.Value))
+		}
+
+		// apply operation
+		switch op.Key {
+		case "and":
+			value = bitwise(value, op.Value, func(a, b int64) int64 { return a & b })
+		case "or":
+			value = bitwise(value, op.Value, func(a, b int64) int64 { return a | b })
+		case "xor":
+			value = bitwise(value, op.Value, func(a, b int64) int64 { return a ^ b })
+		default:
+			return fmt.Errorf("The %s modifier only supports 'and', 'or', and 'xor', not '%s' which is an unknown operator", name, op.Key)
+		}
+	}
+
+	// check if changed
+	if bsonkit.Get(doc, path) != bsonkit.Missing && value == field {
+		return nil
+	}
+
+	// set value
+	_, err := bsonkit.Put(doc, path, value, false)
+	if err != nil {
+		return err
+	}
+
+	// record change
+	err = ctx.Value.(*Changes).Record(path, value)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func bitwise(a, b interface{}, fn func(a, b int64) int64) interface{} {
+	// keep 32-bit integers if both are
+	x, xok := a.(int32)
+	y, yok := b.(int32)
+	if xok && yok {
+		return int32(fn(int64(x), int64(y)))
+	}
+
+	return fn(toInt64(a), toInt64(b))
+}
+
+func contains(list bson.A, value interface{}) bool {
+	for _, item := range list {
+		if bsonkit.Compare(item, value) == 0 {
+			return true
+		}
+	}
+	return false
+}
+
+func typeName(v interface{}) string {
+	_, typ := bsonkit.Inspect(v)
+	if alias, ok := bsonkit.Type2Alias[typ]; ok {
+		return alias
+	}
+	return "missing"
+}
+
+func lastSegment(path string) string {
+	if i := strings.LastIndexByte(path, '.'); i >= 0 {
+		return path[i+1:]
+	}
+	return path
+}
@@ -62,36 +62,12 @@ func NewCollection(idIndex bool) *Collection {
 
 // Find will look up the documents that match the specified query.
 func (c *Collection) Find(query, sort bsonkit.Doc, skip, limit int) (*Result, error) {
-	// get documents
-	list := c.Documents.List
-
-	// sort documents
-	var err error
-	if sort != nil && len(*sort) > 0 {
-		list, err = Sort(list, sort)
-		if err != nil {
-			return nil, err
-		}
-	}
-
-	// adjust limit
-	if limit > 0 {
-		limit += skip
-	}
-
-	// filter documents
-	list, err = Filter(list, query, limit)
+	// query documents
+	list, _, err := c.query(query, sort, skip, limit)
 	if err != nil {
 		return nil, err
 	}
 
-	// apply skip
-	if skip > len(list) {
-		list = nil
-	} else {
-		list = list[skip:]
-	}
-
 	return &Result{
 		Matched: list,
 	}, nil
@@ -130,20 +106,8 @@ func (c *Collection) Insert(doc bsonkit.Doc) (*Result, error) {
 // Replace will look up the first document that matches the query and if found
 // replace it with the specified document.
 func (c *Collection) Replace(query, repl, sort bsonkit.Doc) (*Result, error) {
-	// get documents
-	list := c.Documents.List
-
-	// sort documents
-	var err error
-	if sort != nil && len(*sort) > 0 {
-		list, err = Sort(list, sort)
-		if err != nil {
-			return nil, err
-		}
-	}
-
-	// filter documents
-	list, err = Filter(list, query, 1)
+	// query document
+	list, _, err := c.query(query, sort, 0, 1)
 	if err != nil {
 		return nil, err
 	}
@@ -197,36 +161,12 @@ func (c *Collection) Replace(query, repl, sort bsonkit.Doc) (*Result, error) {
 // Update will look up all documents that match the specified query and update
 // them according to the update document.
 func (c *Collection) Update(query, update, sort bsonkit.Doc, skip, limit int, arrayFilters bsonkit.List) (*Result, error) {
-	// get documents
-	list := c.Documents.List
-
-	// sort documents
-	var err error
-	if sort != nil && len(*sort) > 0 {
-		list, err = Sort(list, sort)
-		if err != nil {
-			return nil, err
-		}
-	}
-
-	// adjust limit
-	if limit > 0 {
-		limit += skip
-	}
-
-	// filter documents
-	list, err = Filter(list, query, limit)
+	// query documents
+	list, _, err := c.query(query, sort, skip, limit)
 	if err != nil {
 		return nil, err
 	}
 
-	// apply skip
-	if skip > len(list) {
-		list = nil
-	} else {
-		list = list[skip:]
-	}
-
 	// check list
 	if len(list) == 0 {
 		return &Result{}, nil
@@ -368,36 +308,12 @@ func (c *Collection) Upsert(query, repl, update bsonkit.Doc, arrayFilters bsonki
 
 // Delete will remove all documents that match the specified query.
 func (c *Collection) Delete(query, sort bsonkit.Doc, skip, limit int) (*Result, error) {
-	// get documents
-	list := c.Documents.List
-
-	// sort documents
-	var err error
-	if sort != nil && len(*sort) > 0 {
-		list, err = Sort(list, sort)
-		if err != nil {
-			return nil, err
-		}
-	}
-
-	// adjust limit
-	if limit > 0 {
-		limit += skip
-	}
-
-	// filter documents
-	list, err = Filter(list, query, limit)
+	// query documents
+	list, _, err := c.query(query, sort, skip, limit)
 	if err != nil {
 		return nil, err
 	}
 
-	// apply skip
-	if skip > len(list) {
-		list = nil
-	} else {
-		list = list[skip:]
-	}
-
 	// update indexes
 	for _, doc := range list {
 		for name, index := range c.Indexes {
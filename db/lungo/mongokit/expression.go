@@ -0,0 +1,646 @@
+package mongokit
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/unix-world/smartgoext/db/mongo-driver/bson"
+	"github.com/unix-world/smartgoext/db/mongo-driver/bson/primitive"
+
+	"github.com/unix-world/smartgoext/db/lungo/bsonkit"
+)
+
+// https://www.mongodb.com/docs/manual/reference/operator/aggregation/#expression-operators
+
+// ExpressionOperator is an aggregation expression operator. It receives the
+// unevaluated argument of the operator.
+type ExpressionOperator func(doc bsonkit.Doc, op string, arg interface{}) (interface{}, error)
+
+// AggregationExpressionOperators defines the available aggregation expression
+// operators.
+var AggregationExpressionOperators = map[string]ExpressionOperator{}
+
+func init() {
+	// register aggregation expression operators
+	AggregationExpressionOperators["$literal"] = exprLiteral
+	AggregationExpressionOperators["$add"] = exprAdd
+	AggregationExpressionOperators["$subtract"] = exprSubtract
+	AggregationExpressionOperators["$multiply"] = exprMultiply
+	AggregationExpressionOperators["$divide"] = exprDivide
+	AggregationExpressionOperators["$mod"] = exprMod
+	AggregationExpressionOperators["$eq"] = exprComp
+	AggregationExpressionOperators["$ne"] = exprComp
+	AggregationExpressionOperators["$gt"] = exprComp
+	AggregationExpressionOperators["$gte"] = exprComp
+	AggregationExpressionOperators["$lt"] = exprComp
+	AggregationExpressionOperators["$lte"] = exprComp
+	AggregationExpressionOperators["$cmp"] = exprComp
+	AggregationExpressionOperators["$and"] = exprAnd
+	AggregationExpressionOperators["$or"] = exprOr
+	AggregationExpressionOperators["$not"] = exprNot
+	AggregationExpressionOperators["$cond"] = exprCond
+	AggregationExpressionOperators["$ifNull"] = exprIfNull
+	AggregationExpressionOperators["$in"] = exprIn
+	AggregationExpressionOperators["$size"] = exprSize
+	AggregationExpressionOperators["$arrayElemAt"] = exprArrayElemAt
+	AggregationExpressionOperators["$concat"] = exprConcat
+	AggregationExpressionOperators["$toLower"] = exprCase
+	AggregationExpressionOperators["$toUpper"] = exprCase
+}
+
+// Evaluate will evaluate the aggregation expression against the document. Field
+// paths are written "$field.path" and the whole document is "$$ROOT". Missing
+// is returned if the expression resolves to a missing field.
+func Evaluate(doc bsonkit.Doc, expr interface{}) (interface{}, error) {
+	switch expr := expr.(type) {
+	case string:
+		// handle variables
+		if strings.HasPrefix(expr, "$$") {
+			name, path, _ := strings.Cut(expr[2:], ".")
+			switch name {
+			case "ROOT", "CURRENT":
+				if path == "" {
+					return *doc, nil
+				}
+				return evaluatePath(doc, path), nil
+			case "REMOVE":
+				return bsonkit.Missing, nil
+			default:
+				return nil, fmt.Errorf("unknown variable %q", expr)
+			}
+		}
+
+		// handle field paths
+		if strings.HasPrefix(expr, "$") {
+			if len(expr) == 1 {
+				return nil, fmt.Errorf("empty field path")
+			}
+			return evaluatePath(doc, expr[1:]), nil
+		}
+
+		return expr, nil
+	case bson.D:
+		// handle operators
+		if len(expr) == 1 && strings.HasPrefix(expr[0].Key, "$") {
+			operator := AggregationExpressionOperators[expr[0].Key]
+			if operator == nil {
+				return nil, fmt.Errorf("unknown expression operator %q", expr[0].Key)
+			}
+			return operator(doc, expr[0].Key, expr[0].Value)
+		}
+
+		// otherwise, evaluate object
+		result := make(bson.D, 0, len(expr))
+		for _, field := range expr {
+			// check key
+			if strings.HasPrefix(field.Key, "$") {
+				return nil, fmt.Errorf("unexpected operator %q in object expression", field.Key)
+			}
+
+			// evaluate value
+			value, err := Evaluate(doc, field.Value)
+			if err != nil {
+				return nil, err
+			}
+
+			// skip missing
+			if value == bsonkit.Missing {
+				continue
+			}
+
+			result = append(result, bson.E{Key: field.Key, Value: value})
+		}
+
+		return result, nil
+	case bson.A:
+		// evaluate items
+		result := make(bson.A, 0, len(expr))
+		for _, item := range expr {
+			value, err := Evaluate(doc, item)
+			if err != nil {
+				return nil, err
+			}
+			if value == bsonkit.Missing {
+				value = nil
+			}
+			result = append(result, value)
+		}
+
+		return result, nil
+	default:
+		return expr, nil
+	}
+}
+
+func evaluatePath(doc bsonkit.Doc, path string) interface{} {
+	// collect values from arrays of embedded documents
+	value, _ := bsonkit.All(doc, path, true, false)
+	return value
+}
+
+// evaluateArgs evaluates the arguments of an operator, a single argument may
+// be given without an array.
+func evaluateArgs(doc bsonkit.Doc, op string, arg interface{}, min, max int) (bson.A, error) {
+	// wrap single argument
+	args, ok := arg.(bson.A)
+	if !ok {
+		args = bson.A{arg}
+	}
+
+	// check count
+	if len(args) < min || (max >= 0 && len(args) > max) {
+		return nil, fmt.Errorf("%s: invalid number of arguments", op)
+	}
+
+	// evaluate arguments
+	values := make(bson.A, 0, len(args))
+	for _, arg := range args {
+		value, err := Evaluate(doc, arg)
+		if err != nil {
+			return nil, err
+		}
+		values = append(values, value)
+	}
+
+	return values, nil
+}
+
+// isNullish returns whether the value is null or missing.
+func isNullish(v interface{}) bool {
+	return v == nil || v == bsonkit.Missing
+}
+
+// Truthy returns whether the value is true in an aggregation expression: all
+// values but false, null, missing and zero are true.
+func Truthy(v interface{}) bool {
+	if isNullish(v) {
+		return false
+	}
+	switch v := v.(type) {
+	case bool:
+		return v
+	case int32, int64, float64, primitive.Decimal128:
+		return bsonkit.Compare(v, int64(0)) != 0
+	}
+	return true
+}
+
+func exprLiteral(_ bsonkit.Doc, _ string, arg interface{}) (interface{}, error) {
+	return arg, nil
+}
+
+func exprAdd(doc bsonkit.Doc, op string, arg interface{}) (interface{}, error) {
+	// evaluate arguments
+	args, err := evaluateArgs(doc, op, arg, 0, -1)
+	if err != nil {
+		return nil, err
+	}
+
+	// add numbers, a date may be offset by milliseconds
+	var sum interface{} = int32(0)
+	var date bool
+	for _, value := range args {
+		if isNullish(value) {
+			return nil, nil
+		}
+		if dt, ok := value.(primitive.DateTime); ok {
+			if date {
+				return nil, fmt.Errorf("%s: only one date allowed", op)
+			}
+			date = true
+			value = int64(dt)
+		}
+		sum = addNumbers(sum, value)
+		if sum == bsonkit.Missing {
+			return nil, fmt.Errorf("%s: only numbers and dates are supported", op)
+		}
+	}
+
+	// convert date
+	if date {
+		return primitive.DateTime(toInt64(sum)), nil
+	}
+
+	return sum, nil
+}
+
+func exprSubtract(doc bsonkit.Doc, op string, arg interface{}) (interface{}, error) {
+	// evaluate arguments
+	args, err := evaluateArgs(doc, op, arg, 2, 2)
+	if err != nil {
+		return nil, err
+	}
+	if isNullish(args[0]) || isNullish(args[1]) {
+		return nil, nil
+	}
+
+	// handle dates
+	ld, lok := args[0].(primitive.DateTime)
+	rd, rok := args[1].(primitive.DateTime)
+	switch {
+	case lok && rok:
+		return int64(ld) - int64(rd), nil
+	case lok:
+		diff := bsonkit.Add(int64(ld), bsonkit.Mul(args[1], int32(-1)))
+		if diff == bsonkit.Missing {
+			return nil, fmt.Errorf("%s: only numbers and dates are supported", op)
+		}
+		return primitive.DateTime(toInt64(diff)), nil
+	}
+
+	// subtract numbers
+	diff := addNumbers(args[0], bsonkit.Mul(args[1], int32(-1)))
+	if diff == bsonkit.Missing {
+		return nil, fmt.Errorf("%s: only numbers and dates are supported", op)
+	}
+
+	return diff, nil
+}
+
+func exprMultiply(doc bsonkit.Doc, op string, arg interface{}) (interface{}, error) {
+	// evaluate arguments
+	args, err := evaluateArgs(doc, op, arg, 0, -1)
+	if err != nil {
+		return nil, err
+	}
+
+	// multiply numbers
+	var product interface{} = int32(1)
+	for _, value := range args {
+		if isNullish(value) {
+			return nil, nil
+		}
+		product = bsonkit.Mul(product, value)
+		if product == bsonkit.Missing {
+			return nil, fmt.Errorf("%s: only numbers are supported", op)
+		}
+	}
+
+	return product, nil
+}
+
+func exprDivide(doc bsonkit.Doc, op string, arg interface{}) (interface{}, error) {
+	// evaluate arguments
+	args, err := evaluateArgs(doc, op, arg, 2, 2)
+	if err != nil {
+		return nil, err
+	}
+	if isNullish(args[0]) || isNullish(args[1]) {
+		return nil, nil
+	}
+
+	// get numbers
+	l, lok := toFloat64(args[0])
+	r, rok := toFloat64(args[1])
+	if !lok || !rok {
+		return nil, fmt.Errorf("%s: only numbers are supported", op)
+	} else if r == 0 {
+		return nil, fmt.Errorf("%s: division by zero", op)
+	}
+
+	return l / r, nil
+}
+
+func exprMod(doc bsonkit.Doc, op string, arg interface{}) (interface{}, error) {
+	// evaluate arguments
+	args, err := evaluateArgs(doc, op, arg, 2, 2)
+	if err != nil {
+		return nil, err
+	}
+	if isNullish(args[0]) || isNullish(args[1]) {
+		return nil, nil
+	}
+
+	// check divisor
+	if r, ok := toFloat64(args[1]); ok && r == 0 {
+		return nil, fmt.Errorf("%s: division by zero", op)
+	}
+
+	// compute remainder
+	res := bsonkit.Mod(args[0], args[1])
+	if res == bsonkit.Missing {
+		return nil, fmt.Errorf("%s: only numbers are supported", op)
+	}
+
+	return res, nil
+}
+
+func exprComp(doc bsonkit.Doc, op string, arg interface{}) (interface{}, error) {
+	// evaluate arguments
+	args, err := evaluateArgs(doc, op, arg, 2, 2)
+	if err != nil {
+		return nil, err
+	}
+
+	// missing compares as null
+	for i, value := range args {
+		if value == bsonkit.Missing {
+			args[i] = nil
+		}
+	}
+
+	// compare values, without type bracketing
+	res := bsonkit.Compare(args[0], args[1])
+
+	// check operator
+	switch op {
+	case "$eq":
+		return res == 0, nil
+	case "$ne":
+		return res != 0, nil
+	case "$gt":
+		return res > 0, nil
+	case "$gte":
+		return res >= 0, nil
+	case "$lt":
+		return res < 0, nil
+	case "$lte":
+		return res <= 0, nil
+	default:
+		return int32(res), nil
+	}
+}
+
+func exprAnd(doc bsonkit.Doc, op string, arg interface{}) (interface{}, error) {
+	// evaluate arguments
+	args, err := evaluateArgs(doc, op, arg, 0, -1)
+	if err != nil {
+		return nil, err
+	}
+
+	// check values
+	for _, value := range args {
+		if !Truthy(value) {
+			return false, nil
+		}
+	}
+
+	return true, nil
+}
+
+func exprOr(doc bsonkit.Doc, op string, arg interface{}) (interface{}, error) {
+	// evaluate arguments
+	args, err := evaluateArgs(doc, op, arg, 0, -1)
+	if err != nil {
+		return nil, err
+	}
+
+	// check values
+	for _, value := range args {
+		if Truthy(value) {
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
+
+func exprNot(doc bsonkit.Doc, op string, arg interface{}) (interface{}, error) {
+	// evaluate argument
+	args, err := evaluateArgs(doc, op, arg, 1, 1)
+	if err != nil {
+		return nil, err
+	}
+
+	return !Truthy(args[0]), nil
+}
+
+func exprCond(doc bsonkit.Doc, op string, arg interface{}) (interface{}, error) {
+	// get branches
+	var cond, then, otherwise interface{}
+	switch arg := arg.(type) {
+	case bson.A:
+		if len(arg) != 3 {
+			return nil, fmt.Errorf("%s: expected three arguments", op)
+		}
+		cond, then, otherwise = arg[0], arg[1], arg[2]
+	case bson.D:
+		for _, field := range arg {
+			switch field.Key {
+			case "if":
+				cond = field.Value
+			case "then":
+				then = field.Value
+			case "else":
+				otherwise = field.Value
+			default:
+				return nil, fmt.Errorf("%s: unknown argument %q", op, field.Key)
+			}
+		}
+	default:
+		return nil, fmt.Errorf("%s: expected array or document", op)
+	}
+
+	// evaluate condition
+	value, err := Evaluate(doc, cond)
+	if err != nil {
+		return nil, err
+	}
+
+	// evaluate branch
+	if Truthy(value) {
+		return Evaluate(doc, then)
+	}
+
+	return Evaluate(doc, otherwise)
+}
+
+func exprIfNull(doc bsonkit.Doc, op string, arg interface{}) (interface{}, error) {
+	// evaluate arguments
+	args, err := evaluateArgs(doc, op, arg, 2, -1)
+	if err != nil {
+		return nil, err
+	}
+
+	// return first non null value
+	for _, value := range args[:len(args)-1] {
+		if !isNullish(value) {
+			return value, nil
+		}
+	}
+
+	return args[len(args)-1], nil
+}
+
+func exprIn(doc bsonkit.Doc, op string, arg interface{}) (interface{}, error) {
+	// evaluate arguments
+	args, err := evaluateArgs(doc, op, arg, 2, 2)
+	if err != nil {
+		return nil, err
+	}
+
+	// get array
+	array, ok := args[1].(bson.A)
+	if !ok {
+		return nil, fmt.Errorf("%s: expected array as second argument", op)
+	}
+
+	// find value
+	for _, item := range array {
+		if bsonkit.Compare(item, args[0]) == 0 {
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
+
+func exprSize(doc bsonkit.Doc, op string, arg interface{}) (interface{}, error) {
+	// evaluate argument
+	args, err := evaluateArgs(doc, op, arg, 1, 1)
+	if err != nil {
+		return nil, err
+	}
+
+	// get array
+	array, ok := args[0].(bson.A)
+	if !ok {
+		return nil, fmt.Errorf("%s: expected array", op)
+	}
+
+	return int32(len(array)), nil
+}
+
+func exprArrayElemAt(doc bsonkit.Doc, op string, arg interface{}) (interface{}, error) {
+	// evaluate arguments
+	args, err := evaluateArgs(doc, op, arg, 2, 2)
+	if err != nil {
+		return nil, err
+	}
+	if isNullish(args[0]) || isNullish(args[1]) {
+		return nil, nil
+	}
+
+	// get array and index
+	array, ok := args[0].(bson.A)
+	if !ok {
+		return nil, fmt.Errorf("%s: expected array as first argument", op)
+	}
+	index, ok := toInt(args[1])
+	if !ok {
+		return nil, fmt.Errorf("%s: expected integer as second argument", op)
+	}
+
+	// handle negative index
+	if index < 0 {
+		index += len(array)
+	}
+
+	// check range
+	if index < 0 || index >= len(array) {
+		return bsonkit.Missing, nil
+	}
+
+	return array[index], nil
+}
+
+func exprConcat(doc bsonkit.Doc, op string, arg interface{}) (interface{}, error) {
+	// evaluate arguments
+	args, err := evaluateArgs(doc, op, arg, 0, -1)
+	if err != nil {
+		return nil, err
+	}
+
+	// join strings
+	var sb strings.Builder
+	for _, value := range args {
+		if isNullish(value) {
+			return nil, nil
+		}
+		str, ok := value.(string)
+		if !ok {
+			return nil, fmt.Errorf("%s: only strings are supported", op)
+		}
+		sb.WriteString(str)
+	}
+
+	return sb.String(), nil
+}
+
+func exprCase(doc bsonkit.Doc, op string, arg interface{}) (interface{}, error) {
+	// evaluate argument
+	args, err := evaluateArgs(doc, op, arg, 1, 1)
+	if err != nil {
+		return nil, err
+	}
+
+	// get string
+	var str string
+	switch value := args[0].(type) {
+	case string:
+		str = value
+	case int32, int64:
+		str = fmt.Sprintf("%d", value)
+	case float64:
+		str = strconv.FormatFloat(value, 'g', -1, 64)
+	default:
+		if !isNullish(value) {
+			return nil, fmt.Errorf("%s: unsupported value %v", op, value)
+		}
+	}
+
+	// change case
+	if op == "$toUpper" {
+		return strings.ToUpper(str), nil
+	}
+
+	return strings.ToLower(str), nil
+}
+
+// addNumbers adds two numbers like bsonkit.Add but promotes an overflowing
+// sum of two int32 to int64, as MongoDB does.
+func addNumbers(num, inc interface{}) interface{} {
+	l, lok := num.(int32)
+	r, rok := inc.(int32)
+	if lok && rok {
+		sum := int64(l) + int64(r)
+		if sum != int64(int32(sum)) {
+			return sum
+		}
+		return int32(sum)
+	}
+
+	return bsonkit.Add(num, inc)
+}
+
+func toFloat64(v interface{}) (float64, bool) {
+	switch v := v.(type) {
+	case int32:
+		return float64(v), true
+	case int64:
+		return float64(v), true
+	case float64:
+		return v, true
+	case primitive.Decimal128:
+		f, err := strconv.ParseFloat(v.String(), 64)
+		return f, err == nil
+	}
+	return 0, false
+}
+
+func toInt64(v interface{}) int64 {
+	switch v := v.(type) {
+	case int32:
+		return int64(v)
+	case int64:
+		return v
+	default:
+		f, _ := toFloat64(v)
+		return int64(f)
+	}
+}
+
+func toInt(v interface{}) (int, bool) {
+	switch v := v.(type) {
+	case int32:
+		return int(v), true
+	case int64:
+		return int(v), true
+	case float64:
+		if v == float64(int(v)) {
+			return int(v), true
+		}
+	}
+	return 0, false
+}
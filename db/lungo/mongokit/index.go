@@ -88,9 +88,10 @@ func (c IndexConfig) Name() (string, error) {
 // not safe from concurrent access and does not roll back changes on errors.
 // Therefore, the recommended approach is to clone the index before making changes.
 type Index struct {
-	config  IndexConfig
-	columns []bsonkit.Column
-	base    *bsonkit.Index
+	config   IndexConfig
+	columns  []bsonkit.Column
+	base     *bsonkit.Index
+	multikey bool
 }
 
 // CreateIndex will create and return a new index.
@@ -155,6 +156,18 @@ func (i *Index) Add(doc bsonkit.Doc) (bool, error) {
 		}
 	}
 
+	// documents with arrays on an indexed path are only indexed by the whole
+	// array, remember it so that the index is not used to look up values
+	if !i.multikey {
+		for _, column := range i.columns {
+			value, nested := bsonkit.All(doc, column.Path, true, false)
+			if _, ok := value.(bson.A); ok || nested {
+				i.multikey = true
+				break
+			}
+		}
+	}
+
 	return i.base.Add(doc), nil
 }
 
@@ -194,6 +207,12 @@ func (i *Index) List() bsonkit.List {
 	return i.base.List()
 }
 
+// Multikey returns whether an indexed path of a document has been an array. Such
+// an index is not used by the query planner.
+func (i *Index) Multikey() bool {
+	return i.multikey
+}
+
 // Config will return the index configuration.
 func (i *Index) Config() IndexConfig {
 	return IndexConfig{
@@ -208,8 +227,9 @@ func (i *Index) Config() IndexConfig {
 // original index.
 func (i *Index) Clone() *Index {
 	return &Index{
-		config:  i.config,
-		columns: i.columns,
-		base:    i.base.Clone(),
+		config:   i.config,
+		columns:  i.columns,
+		base:     i.base.Clone(),
+		multikey: i.multikey,
 	}
 }
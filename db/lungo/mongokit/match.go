@@ -3,8 +3,14 @@ package mongokit
 import (
 	"errors"
 	"fmt"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode"
 
 	"github.com/unix-world/smartgoext/db/mongo-driver/bson"
+	"github.com/unix-world/smartgoext/db/mongo-driver/bson/primitive"
 
 	"github.com/unix-world/smartgoext/db/lungo/bsonkit"
 )
@@ -26,6 +32,8 @@ func init() {
 	TopLevelQueryOperators["$or"] = matchOr
 	TopLevelQueryOperators["$nor"] = matchNor
 	TopLevelQueryOperators["$jsonSchema"] = matchJSONSchema
+	TopLevelQueryOperators["$expr"] = matchExpr
+	TopLevelQueryOperators["$text"] = matchText
 
 	// register expression query operators
 	ExpressionQueryOperators[""] = matchComp
@@ -43,6 +51,8 @@ func init() {
 	ExpressionQueryOperators["$all"] = matchAll
 	ExpressionQueryOperators["$size"] = matchSize
 	ExpressionQueryOperators["$elemMatch"] = matchElem
+	ExpressionQueryOperators["$regex"] = matchRegex
+	ExpressionQueryOperators["$mod"] = matchMod
 }
 
 // Match will test if the specified document matches the supplied MongoDB query
@@ -132,7 +142,12 @@ func matchNor(ctx Context, doc bsonkit.Doc, name, path string, v interface{}) er
 	})
 }
 
-func matchComp(_ Context, doc bsonkit.Doc, op, path string, v interface{}) error {
+func matchComp(ctx Context, doc bsonkit.Doc, op, path string, v interface{}) error {
+	// handle implicit regular expression
+	if regex, ok := v.(primitive.Regex); ok && op == "" {
+		return matchRegex(ctx, doc, "$regex", path, regex)
+	}
+
 	return matchUnwind(doc, path, true, false, func(field interface{}) error {
 		// determine if comparable (type bracketing)
 		lc, _ := bsonkit.Inspect(field)
@@ -169,6 +184,13 @@ func matchComp(_ Context, doc bsonkit.Doc, op, path string, v interface{}) error
 }
 
 func matchNot(ctx Context, doc bsonkit.Doc, name, path string, v interface{}) error {
+	// handle regular expression
+	if regex, ok := v.(primitive.Regex); ok {
+		return matchNegate(func() error {
+			return matchRegex(ctx, doc, "$regex", path, regex)
+		})
+	}
+
 	// coerce item
 	query, ok := v.(bson.D)
 	if !ok {
@@ -190,8 +212,6 @@ func matchNot(ctx Context, doc bsonkit.Doc, name, path string, v interface{}) er
 		}
 	}
 
-	// TODO: Support regular expressions.
-
 	return ErrNotMatched
 }
 
@@ -205,13 +225,23 @@ func matchIn(_ Context, doc bsonkit.Doc, name, path string, v interface{}) error
 
 		// check if field is in array
 		for _, item := range array {
+			// match regular expressions
+			if regex, ok := item.(primitive.Regex); ok {
+				ok, err := regexMatches(regex, field)
+				if err != nil {
+					return err
+				} else if ok {
+					return nil
+				}
+				continue
+			}
+
+			// compare values
 			if bsonkit.Compare(field, item) == 0 {
 				return nil
 			}
 		}
 
-		// TODO: Support regular expressions.
-
 		return ErrNotMatched
 	})
 }
@@ -462,3 +492,290 @@ func matchNegate(op func() error) error {
 
 	return ErrNotMatched
 }
+
+func matchRegex(_ Context, doc bsonkit.Doc, name, path string, v interface{}) error {
+	// get regular expression
+	var regex primitive.Regex
+	switch value := v.(type) {
+	case string:
+		regex.Pattern = value
+	case primitive.Regex:
+		regex = value
+	default:
+		return fmt.Errorf("%s has to be a string", name)
+	}
+
+	return matchUnwind(doc, path, true, false, func(field interface{}) error {
+		// match field
+		ok, err := regexMatches(regex, field)
+		if err != nil {
+			return err
+		} else if !ok {
+			return ErrNotMatched
+		}
+
+		return nil
+	})
+}
+
+func matchMod(_ Context, doc bsonkit.Doc, _, path string, v interface{}) error {
+	// get array
+	array, ok := v.(bson.A)
+	if !ok {
+		return fmt.Errorf("malformed mod, needs to be an array")
+	}
+
+	// check array
+	if len(array) < 2 {
+		return fmt.Errorf("malformed mod, not enough elements")
+	} else if len(array) > 2 {
+		return fmt.Errorf("malformed mod, too many elements")
+	}
+
+	// get divisor
+	divisor, ok := truncateNumber(array[0])
+	if !ok {
+		return fmt.Errorf("malformed mod, divisor not a number")
+	} else if divisor == 0 {
+		return fmt.Errorf("divisor cannot be 0")
+	}
+
+	// get remainder
+	remainder, ok := truncateNumber(array[1])
+	if !ok {
+		return fmt.Errorf("malformed mod, remainder not a number")
+	}
+
+	return matchUnwind(doc, path, true, false, func(field interface{}) error {
+		// get number
+		num, ok := truncateNumber(field)
+		if !ok {
+			return ErrNotMatched
+		}
+
+		// compare remainder
+		if num%divisor != remainder {
+			return ErrNotMatched
+		}
+
+		return nil
+	})
+}
+
+func matchExpr(_ Context, doc bsonkit.Doc, _, _ string, v interface{}) error {
+	// evaluate expression
+	res, err := Evaluate(doc, v)
+	if err != nil {
+		return err
+	}
+
+	// check result
+	if !Truthy(res) {
+		return ErrNotMatched
+	}
+
+	return nil
+}
+
+func matchText(_ Context, _ bsonkit.Doc, name, _ string, v interface{}) error {
+	// get document
+	args, ok := v.(bson.D)
+	if !ok {
+		return fmt.Errorf("%s expects an object", name)
+	}
+
+	// check arguments
+	var searchSet bool
+	for _, arg := range args {
+		switch arg.Key {
+		case "$search":
+			if _, ok := arg.Value.(string); !ok {
+				return fmt.Errorf("$search needs a String")
+			}
+			searchSet = true
+		case "$language":
+			if _, ok := arg.Value.(string); !ok {
+				return fmt.Errorf("$language needs a String")
+			}
+		case "$caseSensitive", "$diacriticSensitive":
+			if _, ok := arg.Value.(bool); !ok {
+				return fmt.Errorf("%s needs a boolean", arg.Key)
+			}
+		default:
+			return fmt.Errorf("extra fields in $text")
+		}
+	}
+
+	// check search
+	if !searchSet {
+		return fmt.Errorf("$search required")
+	}
+
+	// text indexes are not yet supported
+	return fmt.Errorf("text index required for $text query")
+}
+
+func mergeRegexOptions(exps bson.D) (bson.D, error) {
+	// find operators
+	regexIndex, optionsIndex := -1, -1
+	for i, exp := range exps {
+		switch exp.Key {
+		case "$regex":
+			regexIndex = i
+		case "$options":
+			optionsIndex = i
+		}
+	}
+
+	// check options
+	if optionsIndex < 0 {
+		return exps, nil
+	} else if regexIndex < 0 {
+		return nil, fmt.Errorf("$options needs a $regex")
+	}
+
+	// get options
+	options, ok := exps[optionsIndex].Value.(string)
+	if !ok {
+		return nil, fmt.Errorf("$options has to be a string")
+	}
+
+	// get regular expression
+	var regex primitive.Regex
+	switch value := exps[regexIndex].Value.(type) {
+	case string:
+		regex.Pattern = value
+	case primitive.Regex:
+		if value.Options != "" && options != "" {
+			return nil, fmt.Errorf("options set in both $regex and $options")
+		}
+		regex = value
+	default:
+		return nil, fmt.Errorf("$regex has to be a string")
+	}
+
+	// set options
+	if options != "" {
+		regex.Options = options
+	}
+
+	// rebuild expressions
+	result := make(bson.D, 0, len(exps)-1)
+	for i, exp := range exps {
+		if i == optionsIndex {
+			continue
+		} else if i == regexIndex {
+			exp.Value = regex
+		}
+		result = append(result, exp)
+	}
+
+	return result, nil
+}
+
+func regexMatches(regex primitive.Regex, field interface{}) (bool, error) {
+	// match regular expressions and symbols by equality
+	switch value := field.(type) {
+	case primitive.Regex:
+		return value.Pattern == regex.Pattern && value.Options == regex.Options, nil
+	case primitive.Symbol:
+		field = string(value)
+	}
+
+	// get string
+	str, ok := field.(string)
+	if !ok {
+		return false, nil
+	}
+
+	// compile expression
+	re, err := compileRegex(regex)
+	if err != nil {
+		return false, err
+	}
+
+	return re.MatchString(str), nil
+}
+
+func compileRegex(regex primitive.Regex) (*regexp.Regexp, error) {
+	// prepare flags
+	var flags string
+	pattern := regex.Pattern
+	for _, opt := range regex.Options {
+		switch opt {
+		case 'i', 'm', 's':
+			flags += string(opt)
+		case 'x':
+			pattern = stripExtendedRegex(pattern)
+		case 'u':
+			// unicode is always enabled
+		default:
+			return nil, fmt.Errorf("invalid flag in regex options: %c", opt)
+		}
+	}
+
+	// add flags
+	if flags != "" {
+		pattern = "(?" + flags + ")" + pattern
+	}
+
+	// compile pattern
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, fmt.Errorf("Regular expression is invalid: %s", err.Error())
+	}
+
+	return re, nil
+}
+
+func stripExtendedRegex(pattern string) string {
+	// remove unescaped whitespace and comments outside of character classes
+	var b strings.Builder
+	var escaped, class, comment bool
+	for _, r := range pattern {
+		switch {
+		case comment:
+			comment = r != '\n'
+			continue
+		case escaped:
+			escaped = false
+		case r == '\\':
+			escaped = true
+		case r == '[':
+			class = true
+		case r == ']':
+			class = false
+		case !class && r == '#':
+			comment = true
+			continue
+		case !class && unicode.IsSpace(r):
+			continue
+		}
+		b.WriteRune(r)
+	}
+
+	return b.String()
+}
+
+func truncateNumber(v interface{}) (int64, bool) {
+	// truncate number towards zero
+	switch v := v.(type) {
+	case int32:
+		return int64(v), true
+	case int64:
+		return v, true
+	case float64:
+		if math.IsNaN(v) || math.IsInf(v, 0) {
+			return 0, false
+		}
+		return int64(v), true
+	case primitive.Decimal128:
+		f, err := strconv.ParseFloat(v.String(), 64)
+		if err != nil || math.IsNaN(f) || math.IsInf(f, 0) {
+			return 0, false
+		}
+		return int64(f), true
+	default:
+		return 0, false
+	}
+}
@@ -0,0 +1,683 @@
+package mongokit
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/unix-world/smartgoext/db/mongo-driver/bson"
+	"github.com/unix-world/smartgoext/db/mongo-driver/bson/primitive"
+
+	"github.com/unix-world/smartgoext/db/lungo/bsonkit"
+)
+
+// maxPlanRegions limits the number of index regions a plan may scan, which is
+// the product of the $in values of the used index columns.
+const maxPlanRegions = 256
+
+// Plan describes how the documents matching a query have been looked up.
+type Plan struct {
+	// The name of the used index, empty for a collection scan.
+	Index string
+
+	// The key of the used index.
+	Key bsonkit.Doc
+
+	// The scanned values per index column, formatted as in the MongoDB
+	// explain output. Open ends are limited to the type of the other end.
+	Bounds bson.D
+
+	// Whether the index has been walked backwards.
+	Reverse bool
+
+	// Whether the index provided the requested sort order.
+	Sorted bool
+
+	// The query and sort.
+	Query bsonkit.Doc
+	Sort  bsonkit.Doc
+
+	// The number of examined index keys and documents and the number of
+	// returned documents.
+	KeysExamined int
+	DocsExamined int
+	Returned     int
+
+	index   *Index
+	regions []planRegion
+	columns []bsonkit.Column
+}
+
+// planRange is the range of values scanned for an index column. Open ends
+// are limited to the class of the other end (type bracketing).
+type planRange struct {
+	class          bsonkit.Class
+	lower, upper   interface{}
+	hasLower       bool
+	hasUpper       bool
+	lowerInclusive bool
+	upperInclusive bool
+}
+
+// planPredicate collects the conditions on a path that can be looked up in
+// an index.
+type planPredicate struct {
+	points bson.A
+	rng    *planRange
+	empty  bool
+}
+
+// planRegion is a contiguous part of an index: fixed values for the first
+// columns and an optional range for the next column.
+type planRegion struct {
+	points bson.A
+	rng    *planRange
+}
+
+// Explain will return a document that describes the plan like the MongoDB
+// explain command does.
+func (p *Plan) Explain() bsonkit.Doc {
+	// prepare stage
+	var stage bson.D
+	if p.index == nil {
+		stage = bson.D{
+			{Key: "stage", Value: "COLLSCAN"},
+			{Key: "filter", Value: planDoc(p.Query)},
+			{Key: "direction", Value: "forward"},
+		}
+	} else {
+		direction := "forward"
+		if p.Reverse {
+			direction = "backward"
+		}
+		stage = bson.D{
+			{Key: "stage", Value: "FETCH"},
+			{Key: "filter", Value: planDoc(p.Query)},
+			{Key: "inputStage", Value: bson.D{
+				{Key: "stage", Value: "IXSCAN"},
+				{Key: "keyPattern", Value: planDoc(p.Key)},
+				{Key: "indexName", Value: p.Index},
+				{Key: "isMultiKey", Value: false},
+				{Key: "isUnique", Value: p.index.config.Unique},
+				{Key: "direction", Value: direction},
+				{Key: "indexBounds", Value: p.Bounds},
+			}},
+		}
+	}
+
+	// add sort stage
+	if p.Sort != nil && len(*p.Sort) > 0 && !p.Sorted {
+		stage = bson.D{
+			{Key: "stage", Value: "SORT"},
+			{Key: "sortPattern", Value: planDoc(p.Sort)},
+			{Key: "inputStage", Value: stage},
+		}
+	}
+
+	return &bson.D{
+		{Key: "queryPlanner", Value: bson.D{
+			{Key: "winningPlan", Value: stage},
+		}},
+		{Key: "executionStats", Value: bson.D{
+			{Key: "nReturned", Value: int64(p.Returned)},
+			{Key: "totalKeysExamined", Value: int64(p.KeysExamined)},
+			{Key: "totalDocsExamined", Value: int64(p.DocsExamined)},
+		}},
+	}
+}
+
+func planDoc(doc bsonkit.Doc) bson.D {
+	if doc == nil {
+		return bson.D{}
+	}
+	return *doc
+}
+
+// Explain will run the query like Find without returning the documents and
+// return a document describing the plan and its execution.
+func (c *Collection) Explain(query, sort bsonkit.Doc, skip, limit int) (bsonkit.Doc, error) {
+	// run query
+	_, plan, err := c.query(query, sort, skip, limit)
+	if err != nil {
+		return nil, err
+	}
+
+	return plan.Explain(), nil
+}
+
+// query will look up the documents that match the query in the requested
+// order. An index is used if one covers the query or the sort. The result is
+// the same as sorting and filtering the whole list of documents.
+func (c *Collection) query(query, sort bsonkit.Doc, skip, limit int) (bsonkit.List, *Plan, error) {
+	// get sort columns
+	var columns []bsonkit.Column
+	if sort != nil && len(*sort) > 0 {
+		var err error
+		columns, err = Columns(sort)
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+
+	// plan query
+	plan := c.plan(query, columns)
+	plan.Query = query
+	plan.Sort = sort
+
+	// scan collection if no index is used
+	if plan.index == nil {
+		list, err := plan.scanCollection(c.Documents.List, skip, limit)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		return list, plan, nil
+	}
+
+	// check query against the first document as a collection scan would
+	if len(c.Documents.List) > 0 {
+		_, err := Match(c.Documents.List[0], query)
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+
+	// scan index
+	list, err := plan.scanIndex(skip, limit)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// restore collection or requested order
+	if !plan.Sorted {
+		sortByPosition(list, c.Documents.Index)
+		if columns != nil {
+			bsonkit.Sort(list, columns, true)
+		}
+	}
+
+	// apply skip and limit
+	list = applySkipLimit(list, skip, limit)
+	plan.Returned = len(list)
+
+	return list, plan, nil
+}
+
+func sortByPosition(list bsonkit.List, positions map[bsonkit.Doc]int) {
+	sort.Slice(list, func(i, j int) bool {
+		return positions[list[i]] < positions[list[j]]
+	})
+}
+
+func applySkipLimit(list bsonkit.List, skip, limit int) bsonkit.List {
+	// apply skip
+	if skip >= len(list) {
+		return nil
+	}
+	list = list[skip:]
+
+	// apply limit
+	if limit > 0 && limit < len(list) {
+		list = list[:limit]
+	}
+
+	return list
+}
+
+func (p *Plan) scanCollection(list bsonkit.List, skip, limit int) (bsonkit.List, error) {
+	// sort documents
+	if p.columns != nil {
+		list = append(bsonkit.List(nil), list...)
+		bsonkit.Sort(list, p.columns, true)
+	}
+
+	// adjust limit
+	if limit > 0 {
+		limit += skip
+	}
+
+	// filter documents
+	var result bsonkit.List
+	for _, doc := range list {
+		p.DocsExamined++
+		ok, err := Match(doc, p.Query)
+		if err != nil {
+			return nil, err
+		} else if ok {
+			result = append(result, doc)
+			if limit > 0 && len(result) >= limit {
+				break
+			}
+		}
+	}
+
+	// apply skip
+	result = applySkipLimit(result, skip, 0)
+	p.Returned = len(result)
+
+	return result, nil
+}
+
+func (p *Plan) scanIndex(skip, limit int) (bsonkit.List, error) {
+	// adjust limit, only a sorted scan may stop early
+	if limit > 0 && p.Sorted {
+		limit += skip
+	} else {
+		limit = 0
+	}
+
+	// scan regions
+	var result bsonkit.List
+	var matchErr error
+	for _, region := range p.regions {
+		// get positions
+		start := p.index.base.Search(func(doc bsonkit.Doc) bool {
+			return p.locate(region, doc) >= 0
+		})
+		end := p.index.base.Search(func(doc bsonkit.Doc) bool {
+			return p.locate(region, doc) > 0
+		})
+
+		// walk documents
+		p.index.base.Range(start, end, p.Reverse, func(doc bsonkit.Doc) bool {
+			p.KeysExamined++
+
+			// once full, only continue with documents that sort the same
+			// as the last one, their order is fixed below
+			full := limit > 0 && len(result) >= limit
+			if full && bsonkit.Order(result[len(result)-1], doc, p.columns, false) != 0 {
+				return false
+			}
+
+			// match document
+			p.DocsExamined++
+			ok, err := Match(doc, p.Query)
+			if err != nil {
+				matchErr = err
+				return false
+			} else if ok {
+				result = append(result, doc)
+			}
+
+			return true
+		})
+		if matchErr != nil {
+			return nil, matchErr
+		}
+	}
+
+	// documents that sort the same are ordered by identity, as bsonkit.Sort
+	// does, whatever the direction of the scan
+	if p.Sorted {
+		for i := 0; i < len(result); {
+			j := i + 1
+			for j < len(result) && bsonkit.Order(result[i], result[j], p.columns, false) == 0 {
+				j++
+			}
+			if j-i > 1 {
+				bsonkit.Sort(result[i:j], nil, true)
+			}
+			i = j
+		}
+	}
+
+	return result, nil
+}
+
+// locate will return whether the document is before (-1), in (0) or after (1)
+// the region in index order.
+func (p *Plan) locate(region planRegion, doc bsonkit.Doc) int {
+	columns := p.index.columns
+
+	// check fixed values
+	for i, value := range region.points {
+		res := bsonkit.Compare(bsonkit.Get(doc, columns[i].Path), value)
+		if columns[i].Reverse {
+			res *= -1
+		}
+		if res != 0 {
+			return res
+		}
+	}
+
+	// check range
+	if region.rng == nil {
+		return 0
+	}
+	column := columns[len(region.points)]
+	below, above := region.rng.locate(bsonkit.Get(doc, column.Path))
+	if column.Reverse {
+		below, above = above, below
+	}
+	if below {
+		return -1
+	} else if above {
+		return 1
+	}
+
+	return 0
+}
+
+// locate will return whether the value is below or above the range.
+func (r *planRange) locate(value interface{}) (bool, bool) {
+	var below, above bool
+	class, _ := bsonkit.Inspect(value)
+
+	// check lower end
+	if r.hasLower {
+		res := bsonkit.Compare(value, r.lower)
+		below = res < 0 || (res == 0 && !r.lowerInclusive)
+	} else {
+		below = class < r.class
+	}
+
+	// check upper end
+	if r.hasUpper {
+		res := bsonkit.Compare(value, r.upper)
+		above = res > 0 || (res == 0 && !r.upperInclusive)
+	} else {
+		above = class > r.class
+	}
+
+	return below, above
+}
+
+// plan will select the index to use for the query and sort. It returns a plan
+// without an index if none is useful.
+func (c *Collection) plan(query bsonkit.Doc, columns []bsonkit.Column) *Plan {
+	// collect predicates
+	predicates := map[string]*planPredicate{}
+	if query != nil {
+		collectPredicates(*query, predicates)
+	}
+
+	// get index names, sorted for a stable selection
+	names := make([]string, 0, len(c.Indexes))
+	for name := range c.Indexes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	// select best index
+	best := &Plan{columns: columns}
+	bestScore := 0
+	for _, name := range names {
+		// get index
+		index := c.Indexes[name]
+
+		// partial and multikey indexes do not contain all values
+		if index.config.Partial != nil || index.multikey {
+			continue
+		}
+
+		// get bounds, fixed values for a prefix and a range for the next column
+		var points []bson.A
+		var rng *planRange
+		regions := 1
+		for _, column := range index.columns {
+			pred := predicates[column.Path]
+			if pred == nil {
+				break
+			}
+			if pred.empty {
+				points = append(points, bson.A{})
+				regions = 0
+				break
+			}
+			if pred.points != nil {
+				if regions*len(pred.points) > maxPlanRegions {
+					break
+				}
+				regions *= len(pred.points)
+				points = append(points, pred.points)
+				continue
+			}
+			rng = pred.rng
+			break
+		}
+
+		// check if the index provides the sort order, following the fixed values
+		sorted := false
+		reverse := false
+		if columns != nil && regions <= 1 && len(points)+len(columns) <= len(index.columns) {
+			sorted = true
+			for i, column := range columns {
+				indexColumn := index.columns[len(points)+i]
+				flip := column.Reverse != indexColumn.Reverse
+				if column.Path != indexColumn.Path || (i > 0 && flip != reverse) {
+					sorted = false
+					break
+				}
+				reverse = flip
+			}
+			if !sorted {
+				reverse = false
+			}
+		}
+
+		// compute score, fixed values weigh more than a range, and a sort is
+		// only worth an index if nothing else is
+		score := len(points) * 4
+		if rng != nil {
+			score += 2
+		}
+		if sorted {
+			score++
+		}
+		if score <= bestScore || (score == 1 && !sorted) {
+			continue
+		}
+
+		// set plan
+		bestScore = score
+		best = &Plan{
+			Index:   name,
+			Key:     index.config.Key,
+			Reverse: reverse,
+			Sorted:  sorted,
+			index:   index,
+			regions: planRegions(points, rng),
+			columns: columns,
+		}
+		best.Bounds = planBounds(index.columns, points, rng)
+	}
+
+	return best
+}
+
+// planRegions will return the regions for the cartesian product of the fixed
+// values with the range.
+func planRegions(points []bson.A, rng *planRange) []planRegion {
+	regions := []planRegion{{}}
+	for _, values := range points {
+		next := make([]planRegion, 0, len(regions)*len(values))
+		for _, region := range regions {
+			for _, value := range values {
+				next = append(next, planRegion{
+					points: append(append(bson.A{}, region.points...), value),
+				})
+			}
+		}
+		regions = next
+	}
+	for i := range regions {
+		regions[i].rng = rng
+	}
+
+	return regions
+}
+
+func planBounds(columns []bsonkit.Column, points []bson.A, rng *planRange) bson.D {
+	bounds := bson.D{}
+	for i, column := range columns {
+		var list bson.A
+		switch {
+		case i < len(points):
+			for _, value := range points[i] {
+				str := planValue(value)
+				list = append(list, "["+str+", "+str+"]")
+			}
+		case i == len(points) && rng != nil:
+			lower, upper := "MinKey", "MaxKey"
+			if rng.hasLower {
+				lower = planValue(rng.lower)
+			}
+			if rng.hasUpper {
+				upper = planValue(rng.upper)
+			}
+			left, right := "[", "]"
+			if rng.hasLower && !rng.lowerInclusive {
+				left = "("
+			}
+			if rng.hasUpper && !rng.upperInclusive {
+				right = ")"
+			}
+			list = bson.A{left + lower + ", " + upper + right}
+		default:
+			list = bson.A{"[MinKey, MaxKey]"}
+		}
+		bounds = append(bounds, bson.E{Key: column.Path, Value: list})
+	}
+
+	return bounds
+}
+
+func planValue(value interface{}) string {
+	switch value := value.(type) {
+	case nil:
+		return "null"
+	case string:
+		return fmt.Sprintf("%q", value)
+	case primitive.ObjectID:
+		return fmt.Sprintf("ObjectId('%s')", value.Hex())
+	default:
+		return strings.TrimSpace(fmt.Sprintf("%v", value))
+	}
+}
+
+// collectPredicates will collect the fixed values and ranges of the paths in
+// the query that can be looked up in an index. Only conditions that all
+// matching documents satisfy are collected, others are left to the filter.
+func collectPredicates(query bson.D, predicates map[string]*planPredicate) {
+	for _, pair := range query {
+		// handle and
+		if pair.Key == "$and" {
+			if list, ok := pair.Value.(bson.A); ok {
+				for _, item := range list {
+					if doc, ok := item.(bson.D); ok {
+						collectPredicates(doc, predicates)
+					}
+				}
+			}
+			continue
+		}
+
+		// skip other top level operators
+		if strings.HasPrefix(pair.Key, "$") {
+			continue
+		}
+
+		// handle operators
+		if exps, ok := pair.Value.(bson.D); ok && len(exps) > 0 && strings.HasPrefix(exps[0].Key, "$") {
+			for _, exp := range exps {
+				switch exp.Key {
+				case "$eq":
+					addPoints(predicates, pair.Key, bson.A{exp.Value})
+				case "$in":
+					if list, ok := exp.Value.(bson.A); ok {
+						addPoints(predicates, pair.Key, list)
+					}
+				case "$gt", "$gte", "$lt", "$lte":
+					addRange(predicates, pair.Key, exp.Key, exp.Value)
+				}
+			}
+			continue
+		}
+
+		// handle equality
+		addPoints(predicates, pair.Key, bson.A{pair.Value})
+	}
+}
+
+func addPoints(predicates map[string]*planPredicate, path string, values bson.A) {
+	// regular expressions are matched, not compared
+	for _, value := range values {
+		if _, ok := value.(primitive.Regex); ok {
+			return
+		}
+	}
+
+	// get predicate
+	pred := predicates[path]
+	if pred == nil {
+		pred = &planPredicate{}
+		predicates[path] = pred
+	}
+
+	// keep first values, the documents have to match all of them anyway
+	if pred.points != nil {
+		return
+	}
+
+	// sort and deduplicate values
+	points := append(bson.A{}, values...)
+	sort.Slice(points, func(i, j int) bool {
+		return bsonkit.Compare(points[i], points[j]) < 0
+	})
+	unique := bson.A{}
+	for i, value := range points {
+		if i == 0 || bsonkit.Compare(points[i-1], value) != 0 {
+			unique = append(unique, value)
+		}
+	}
+	pred.points = unique
+	pred.empty = len(unique) == 0
+}
+
+func addRange(predicates map[string]*planPredicate, path, op string, value interface{}) {
+	// check class
+	class, _ := bsonkit.Inspect(value)
+	switch class {
+	case bsonkit.Null, bsonkit.Regex:
+		return
+	}
+
+	// get predicate
+	pred := predicates[path]
+	if pred == nil {
+		pred = &planPredicate{}
+		predicates[path] = pred
+	}
+
+	// get range
+	if pred.rng == nil {
+		pred.rng = &planRange{class: class}
+	} else if pred.rng.class != class {
+		// no value can be of both types
+		pred.empty = true
+		return
+	}
+	rng := pred.rng
+
+	// narrow range
+	switch op {
+	case "$gt", "$gte":
+		inclusive := op == "$gte"
+		res := 1
+		if rng.hasLower {
+			res = bsonkit.Compare(value, rng.lower)
+		}
+		if res > 0 || (res == 0 && !inclusive) {
+			rng.lower, rng.hasLower, rng.lowerInclusive = value, true, inclusive
+		}
+	case "$lt", "$lte":
+		inclusive := op == "$lte"
+		res := -1
+		if rng.hasUpper {
+			res = bsonkit.Compare(value, rng.upper)
+		}
+		if res < 0 || (res == 0 && !inclusive) {
+			rng.upper, rng.hasUpper, rng.upperInclusive = value, true, inclusive
+		}
+	}
+}
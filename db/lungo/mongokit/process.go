@@ -8,7 +8,7 @@ import (
 	"github.com/unix-world/smartgoext/db/lungo/bsonkit"
 )
 
-// TODO: Add support for positional operator `$` (apply and project).
+// TODO: Add support for positional operator `$` in projections.
 
 // Operator is a generic operator.
 type Operator func(ctx Context, doc bsonkit.Doc, op, path string, v interface{}) error
@@ -110,6 +110,12 @@ func ProcessExpression(ctx Context, doc bsonkit.Doc, prefix string, pair bson.E,
 	// check for field expressions with a document which may contain either
 	// only expression operators or only simple conditions
 	if exps, ok := pair.Value.(bson.D); ok {
+		// merge regular expression options
+		exps, err := mergeRegexOptions(exps)
+		if err != nil {
+			return err
+		}
+
 		// process all expressions (implicit and)
 		for i, exp := range exps {
 			// stop and leave document as a simple condition if the
@@ -9,8 +9,6 @@ import (
 	"github.com/unix-world/smartgoext/db/lungo/bsonkit"
 )
 
-// TODO: Implement implicit positional operators.
-
 // Resolve will resolve all positional operators in the provided path using the
 // query, document and array filters. For each match it will call the callback
 // with the generated absolute path.
@@ -38,9 +36,32 @@ func resolve(path string, query bsonkit.Doc, doc bson.D, arrayFilters bsonkit.Li
 		return fmt.Errorf("expected array at %q to match against positional operator", head)
 	}
 
-	// check implicit positional operator "$"
+	// handle implicit positional operator "$"
 	if operator == "$" {
-		return fmt.Errorf("the implicit positional operator is not yet supported")
+		// check tail
+		if tail != bsonkit.PathEnd && strings.Contains("."+tail+".", ".$.") {
+			return fmt.Errorf("Too many positional (i.e. '$') elements found in path '%s'", path)
+		}
+
+		// find first matching element
+		index, err := matchPosition(query, doc, head, array)
+		if err != nil {
+			return err
+		}
+
+		// prepare builder
+		builder := bsonkit.NewPathBuilder(len(head) + 22 + len(tail))
+
+		// add head and index
+		builder.AddSegment(head)
+		builder.AddIndex(index)
+
+		// append tail if available
+		if tail != bsonkit.PathEnd {
+			builder.AddSegment(tail)
+		}
+
+		return resolve(builder.String(), query, doc, arrayFilters, callback)
 	}
 
 	// check operator
@@ -133,3 +154,45 @@ func resolve(path string, query bsonkit.Doc, doc bson.D, arrayFilters bsonkit.Li
 
 	return nil
 }
+
+func matchPosition(query bsonkit.Doc, doc bson.D, head string, array bson.A) (int, error) {
+	// check query
+	if query == nil {
+		return 0, fmt.Errorf("The positional operator did not find the match needed from the query.")
+	}
+
+	// prepare a copy of the document
+	virtual := bsonkit.Clone(&doc)
+
+	// the query must constrain the array, if it still matches without any
+	// element it did not match an element
+	_, err := bsonkit.Put(virtual, head, bson.A{}, false)
+	if err != nil {
+		return 0, err
+	}
+	ok, err := Match(virtual, query)
+	if err != nil {
+		return 0, err
+	} else if ok {
+		return 0, fmt.Errorf("The positional operator did not find the match needed from the query.")
+	}
+
+	// match the query against every element alone
+	for i, item := range array {
+		// replace array with element
+		_, err := bsonkit.Put(virtual, head, bson.A{item}, false)
+		if err != nil {
+			return 0, err
+		}
+
+		// match document
+		ok, err := Match(virtual, query)
+		if err != nil {
+			return 0, err
+		} else if ok {
+			return i, nil
+		}
+	}
+
+	return 0, fmt.Errorf("The positional operator did not find the match needed from the query.")
+}
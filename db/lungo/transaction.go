@@ -154,6 +154,67 @@ func (t *Transaction) Find(handle Handle, query, sort bsonkit.Doc, skip, limit i
 	}, nil
 }
 
+// Explain will run a query like Find and return a document describing the
+// selected query plan and its execution.
+func (t *Transaction) Explain(handle Handle, query, sort bsonkit.Doc, skip, limit int) (bsonkit.Doc, error) {
+	// acquire read lock
+	t.mutex.RLock()
+	defer t.mutex.RUnlock()
+
+	// validate handle
+	err := handle.Validate(true)
+	if err != nil {
+		return nil, err
+	}
+
+	// use empty collection if missing
+	namespace := t.catalog.Namespaces[handle]
+	if namespace == nil {
+		namespace = mongokit.NewCollection(false)
+	}
+
+	return namespace.Explain(query, sort, skip, limit)
+}
+
+// Aggregate will run the aggregation pipeline on the documents of a namespace.
+// Collections joined by $lookup are resolved in the same database. The returned
+// results will contain the resulting list of documents.
+func (t *Transaction) Aggregate(handle Handle, pipeline bsonkit.List) (*Result, error) {
+	// acquire read lock
+	t.mutex.RLock()
+	defer t.mutex.RUnlock()
+
+	// validate handle
+	err := handle.Validate(true)
+	if err != nil {
+		return nil, err
+	}
+
+	// get documents
+	var list bsonkit.List
+	if t.catalog.Namespaces[handle] != nil {
+		list = t.catalog.Namespaces[handle].Documents.List
+	}
+
+	// run pipeline
+	list, err = mongokit.Aggregate(list, pipeline, func(collection string) (bsonkit.List, error) {
+		// get namespace
+		namespace := t.catalog.Namespaces[Handle{handle[0], collection}]
+		if namespace == nil {
+			return nil, nil
+		}
+
+		return namespace.Documents.List, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &Result{
+		Matched: list,
+	}, nil
+}
+
 // Bulk performs the specified operations in one go. If ordered is true the
 // process is aborted on the first error.
 func (t *Transaction) Bulk(handle Handle, ops []Operation, ordered bool) ([]Result, error) {
@@ -0,0 +1,190 @@
+/*
+Copyright 2011 The gomemcache AUTHORS
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package memcache
+
+import (
+	"errors"
+	"net"
+	"sync"
+	"time"
+)
+
+// DefaultHealthInterval is the default time between two rounds of
+// health checks.
+const DefaultHealthInterval = time.Second
+
+// ErrNotEjecting is returned by NewHealthChecker when the client's
+// ServerSelector is not an EjectingSelector.
+var ErrNotEjecting = errors.New("memcache: server selector can not eject servers")
+
+// HealthChecker pings the servers of a Client in the background. It
+// ejects the servers that fail from the client's EjectingSelector, and
+// restores them once they answer again.
+//
+// The fields must be set before Start.
+type HealthChecker struct {
+	// Interval is the time between two rounds of pings.
+	// If zero, DefaultHealthInterval is used.
+	Interval time.Duration
+
+	// FailureLimit is the number of consecutive failed pings after
+	// which a server is ejected. If less than one, 1 is used.
+	FailureLimit int
+
+	// RiseLimit is the number of consecutive answered pings after
+	// which an ejected server is restored. If less than one, 1 is used.
+	RiseLimit int
+
+	// OnChange, if not nil, is called when a server is ejected (up is
+	// false) or restored (up is true).
+	OnChange func(addr net.Addr, up bool)
+
+	client   *Client
+	selector EjectingSelector
+
+	mu     sync.Mutex
+	counts map[string]int // consecutive failures of servers in rotation, successes of ejected ones
+	stop   chan struct{}
+	done   chan struct{}
+}
+
+// NewHealthChecker returns a HealthChecker for the servers of c. The
+// selector of c must be an EjectingSelector, such as a KetamaSelector.
+func NewHealthChecker(c *Client) (*HealthChecker, error) {
+	es, ok := c.selector.(EjectingSelector)
+	if !ok {
+		return nil, ErrNotEjecting
+	}
+	return &HealthChecker{client: c, selector: es}, nil
+}
+
+// Start runs the checks in the background, every Interval, until Stop.
+func (h *HealthChecker) Start() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.stop != nil {
+		return
+	}
+	h.stop, h.done = make(chan struct{}), make(chan struct{})
+	go h.run(h.stop, h.done)
+}
+
+// Stop stops the background checks and waits for the current round
+// to finish. Ejected servers stay ejected.
+func (h *HealthChecker) Stop() {
+	h.mu.Lock()
+	stop, done := h.stop, h.done
+	h.stop, h.done = nil, nil
+	h.mu.Unlock()
+	if stop != nil {
+		close(stop)
+		<-done
+	}
+}
+
+func (h *HealthChecker) run(stop, done chan struct{}) {
+	defer close(done)
+	interval := h.Interval
+	if interval <= 0 {
+		interval = DefaultHealthInterval
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			h.Check()
+		}
+	}
+}
+
+// Check runs one round of checks right away: every server, in rotation
+// or ejected, is pinged once.
+func (h *HealthChecker) Check() {
+	var live []net.Addr
+	h.selector.Each(func(addr net.Addr) error {
+		live = append(live, addr)
+		return nil
+	})
+	ejected := h.selector.Ejected()
+
+	var wg sync.WaitGroup
+	for _, addr := range live {
+		wg.Add(1)
+		go func(addr net.Addr) {
+			defer wg.Done()
+			if err := h.client.ping(addr); err != nil {
+				h.count(addr, false)
+			} else {
+				h.reset(addr)
+			}
+		}(addr)
+	}
+	for _, addr := range ejected {
+		wg.Add(1)
+		go func(addr net.Addr) {
+			defer wg.Done()
+			if err := h.client.ping(addr); err == nil {
+				h.count(addr, true)
+			} else {
+				h.reset(addr)
+			}
+		}(addr)
+	}
+	wg.Wait()
+}
+
+func (h *HealthChecker) reset(addr net.Addr) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	delete(h.counts, addr.String())
+}
+
+// count counts a failed ping of a server in rotation, or an answered
+// ping of an ejected server, and switches the server at the limit.
+func (h *HealthChecker) count(addr net.Addr, up bool) {
+	limit := h.FailureLimit
+	if up {
+		limit = h.RiseLimit
+	}
+	if limit < 1 {
+		limit = 1
+	}
+	h.mu.Lock()
+	if h.counts == nil {
+		h.counts = make(map[string]int)
+	}
+	h.counts[addr.String()]++
+	if h.counts[addr.String()] < limit {
+		h.mu.Unlock()
+		return
+	}
+	delete(h.counts, addr.String())
+	h.mu.Unlock()
+
+	if up {
+		h.client.recordResult(addr, nil)
+		h.selector.Restore(addr)
+	} else {
+		h.selector.Eject(addr)
+	}
+	if h.OnChange != nil {
+		h.OnChange(addr, up)
+	}
+}
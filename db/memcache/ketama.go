@@ -0,0 +1,205 @@
+/*
+Copyright 2011 The gomemcache AUTHORS
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package memcache
+
+import (
+	"crypto/md5"
+	"encoding/binary"
+	"net"
+	"sort"
+	"strconv"
+	"sync"
+)
+
+// pointsPerServer is the number of ring points of a server of average
+// weight, as in libketama: 40 MD5 digests of 4 points each.
+const pointsPerServer = 40
+
+// WeightedServer is a server address with its share of the keys.
+type WeightedServer struct {
+	Addr   string
+	Weight int
+}
+
+// KetamaSelector is a ServerSelector that spreads the keys over its
+// servers with consistent hashing, compatible with libketama: adding or
+// removing a server only moves the keys of that server. Its zero value is
+// usable.
+//
+// KetamaSelector is an EjectingSelector: the keys of an ejected server go
+// to the next servers on the ring, the keys of the other servers stay put.
+type KetamaSelector struct {
+	mu      sync.RWMutex
+	addrs   []net.Addr
+	names   []string // the servers as configured, hashed onto the ring
+	ring    []ketamaPoint
+	ejected map[string]bool
+}
+
+type ketamaPoint struct {
+	hash   uint32
+	server int // index in addrs
+}
+
+// SetServers changes the set of servers at runtime, each server with the
+// same weight. A server is given more weight if it's listed multiple times.
+//
+// SetServers returns an error if any of the server names fail to
+// resolve. No attempt is made to connect to the server. If any error
+// is returned, no changes are made to the KetamaSelector.
+func (ks *KetamaSelector) SetServers(servers ...string) error {
+	weighted := make([]WeightedServer, len(servers))
+	for i, server := range servers {
+		weighted[i] = WeightedServer{Addr: server, Weight: 1}
+	}
+	return ks.SetWeightedServers(weighted...)
+}
+
+// SetWeightedServers changes the set of servers at runtime, each server
+// getting a share of the keys proportional to its weight. Servers with a
+// weight of zero or less are left out. Ejected servers that are still in
+// the set stay ejected.
+//
+// As in libketama, the ring points are hashed from the server names as
+// given, not from their resolved addresses, so "cache1:11211" gets the
+// same keys in every client that names it so.
+func (ks *KetamaSelector) SetWeightedServers(servers ...WeightedServer) error {
+	var addrs []net.Addr
+	var names []string
+	var weights []int
+	index := make(map[string]int)
+	total := 0
+	for _, server := range servers {
+		if server.Weight <= 0 {
+			continue
+		}
+		addr, err := resolveAddr(server.Addr)
+		if err != nil {
+			return err
+		}
+		total += server.Weight
+		if i, ok := index[server.Addr]; ok {
+			weights[i] += server.Weight
+			continue
+		}
+		index[server.Addr] = len(addrs)
+		addrs = append(addrs, addr)
+		names = append(names, server.Addr)
+		weights = append(weights, server.Weight)
+	}
+
+	var ring []ketamaPoint
+	for i, name := range names {
+		n := pointsPerServer * len(addrs) * weights[i] / total
+		for k := 0; k < n; k++ {
+			digest := md5.Sum([]byte(name + "-" + strconv.Itoa(k)))
+			for h := 0; h < 4; h++ {
+				ring = append(ring, ketamaPoint{
+					hash:   binary.LittleEndian.Uint32(digest[4*h:]),
+					server: i,
+				})
+			}
+		}
+	}
+	sort.Slice(ring, func(i, j int) bool { return ring[i].hash < ring[j].hash })
+
+	ks.mu.Lock()
+	defer ks.mu.Unlock()
+	ejected := make(map[string]bool)
+	for _, addr := range addrs {
+		if ks.ejected[addr.String()] {
+			ejected[addr.String()] = true
+		}
+	}
+	ks.addrs, ks.names, ks.ring, ks.ejected = addrs, names, ring, ejected
+	return nil
+}
+
+// PickServer returns the server owning the first ring point at or after
+// the hash of key, skipping ejected servers.
+func (ks *KetamaSelector) PickServer(key string) (net.Addr, error) {
+	return ks.pickServerExcluding(key, nil)
+}
+
+// pickServerExcluding is PickServer skipping the excluded servers as well,
+// keyed by address, so a retry goes to the next server on the ring.
+func (ks *KetamaSelector) pickServerExcluding(key string, excluded map[string]bool) (net.Addr, error) {
+	ks.mu.RLock()
+	defer ks.mu.RUnlock()
+	if len(ks.ring) == 0 || len(ks.ejected) == len(ks.addrs) {
+		return nil, ErrNoServers
+	}
+	if len(ks.addrs) == 1 && !excluded[ks.addrs[0].String()] {
+		return ks.addrs[0], nil
+	}
+	digest := md5.Sum([]byte(key))
+	h := binary.LittleEndian.Uint32(digest[:4])
+	i := sort.Search(len(ks.ring), func(i int) bool { return ks.ring[i].hash >= h })
+	for n := 0; n < len(ks.ring); n++ {
+		addr := ks.addrs[ks.ring[(i+n)%len(ks.ring)].server]
+		if !ks.ejected[addr.String()] && !excluded[addr.String()] {
+			return addr, nil
+		}
+	}
+	return nil, ErrNoServers
+}
+
+// Each iterates over each server in rotation calling the given function
+func (ks *KetamaSelector) Each(f func(net.Addr) error) error {
+	ks.mu.RLock()
+	defer ks.mu.RUnlock()
+	for _, a := range ks.addrs {
+		if ks.ejected[a.String()] {
+			continue
+		}
+		if err := f(a); nil != err {
+			return err
+		}
+	}
+	return nil
+}
+
+// Eject takes the server out of rotation.
+func (ks *KetamaSelector) Eject(addr net.Addr) {
+	ks.mu.Lock()
+	defer ks.mu.Unlock()
+	for _, a := range ks.addrs {
+		if a.String() == addr.String() {
+			ks.ejected[a.String()] = true
+		}
+	}
+}
+
+// Restore puts an ejected server back in rotation.
+func (ks *KetamaSelector) Restore(addr net.Addr) {
+	ks.mu.Lock()
+	defer ks.mu.Unlock()
+	delete(ks.ejected, addr.String())
+}
+
+// Ejected returns the servers that are out of rotation.
+func (ks *KetamaSelector) Ejected() []net.Addr {
+	ks.mu.RLock()
+	defer ks.mu.RUnlock()
+	var ejected []net.Addr
+	for _, a := range ks.addrs {
+		if ks.ejected[a.String()] {
+			ejected = append(ejected, a)
+		}
+	}
+	return ejected
+}
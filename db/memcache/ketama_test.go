@@ -0,0 +1,253 @@
+/*
+Copyright 2011 The gomemcache AUTHORS
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package memcache
+
+import (
+	"crypto/md5"
+	"fmt"
+	"net"
+	"sort"
+	"testing"
+)
+
+const testKeys = 10000
+
+func testKey(i int) string {
+	return fmt.Sprintf("user:%d:profile", i)
+}
+
+func ketamaSelector(t *testing.T, servers ...WeightedServer) *KetamaSelector {
+	t.Helper()
+	ks := new(KetamaSelector)
+	if err := ks.SetWeightedServers(servers...); err != nil {
+		t.Fatalf("SetWeightedServers() = %v", err)
+	}
+	return ks
+}
+
+// owners returns the server picked for each test key.
+func owners(t *testing.T, ss ServerSelector) []string {
+	t.Helper()
+	l := make([]string, testKeys)
+	for i := range l {
+		addr, err := ss.PickServer(testKey(i))
+		if err != nil {
+			t.Fatalf("PickServer() = %v", err)
+		}
+		l[i] = addr.String()
+	}
+	return l
+}
+
+func shares(l []string) map[string]int {
+	m := make(map[string]int)
+	for _, addr := range l {
+		m[addr]++
+	}
+	return m
+}
+
+// libketamaServer is the server of key on the continuum of libketama, built
+// from the server names, all of the same weight.
+func libketamaServer(names []string, key string) string {
+	type point struct {
+		value  uint32
+		server string
+	}
+	var continuum []point
+	for _, name := range names {
+		for k := 0; k < 40; k++ {
+			digest := md5.Sum([]byte(fmt.Sprintf("%s-%d", name, k)))
+			for h := 0; h < 4; h++ {
+				value := uint32(digest[3+h*4])<<24 | uint32(digest[2+h*4])<<16 | uint32(digest[1+h*4])<<8 | uint32(digest[h*4])
+				continuum = append(continuum, point{value, name})
+			}
+		}
+	}
+	sort.Slice(continuum, func(i, j int) bool { return continuum[i].value < continuum[j].value })
+	digest := md5.Sum([]byte(key))
+	h := uint32(digest[3])<<24 | uint32(digest[2])<<16 | uint32(digest[1])<<8 | uint32(digest[0])
+	for _, p := range continuum {
+		if p.value >= h {
+			return p.server
+		}
+	}
+	return continuum[0].server
+}
+
+func TestKetamaLibketamaCompatible(t *testing.T) {
+	// the names, not the addresses they resolve to, are hashed
+	names := []string{"localhost:11211", "localhost:11212", "localhost:11213", "localhost:11214"}
+	ks := new(KetamaSelector)
+	if err := ks.SetServers(names...); err != nil {
+		t.Fatalf("SetServers() = %v", err)
+	}
+	for i := 0; i < 1000; i++ {
+		addr, err := ks.PickServer(testKey(i))
+		if err != nil {
+			t.Fatalf("PickServer() = %v", err)
+		}
+		want := libketamaServer(names, testKey(i))
+		_, port, _ := net.SplitHostPort(addr.String())
+		_, wantPort, _ := net.SplitHostPort(want)
+		if port != wantPort {
+			t.Fatalf("PickServer(%q) = %s, want %s", testKey(i), addr, want)
+		}
+	}
+}
+
+func TestKetamaDistribution(t *testing.T) {
+	servers := []WeightedServer{
+		{"127.0.0.1:11211", 1},
+		{"127.0.0.1:11212", 1},
+		{"127.0.0.1:11213", 1},
+		{"127.0.0.1:11214", 2},
+	}
+	got := shares(owners(t, ketamaSelector(t, servers...)))
+	for _, server := range servers {
+		// a fifth of the keys per unit of weight, give or take a third
+		want := testKeys * server.Weight / 5
+		if n := got[server.Addr]; n < want*2/3 || n > want*4/3 {
+			t.Errorf("%s of weight %d has %d keys, want about %d", server.Addr, server.Weight, n, want)
+		}
+	}
+
+	// servers of weight zero get no keys
+	got = shares(owners(t, ketamaSelector(t, append(servers, WeightedServer{"127.0.0.1:11215", 0})...)))
+	if n := got["127.0.0.1:11215"]; n != 0 {
+		t.Errorf("server of weight 0 has %d keys", n)
+	}
+}
+
+func TestKetamaConsistency(t *testing.T) {
+	servers := []WeightedServer{
+		{"127.0.0.1:11211", 1},
+		{"127.0.0.1:11212", 1},
+		{"127.0.0.1:11213", 1},
+	}
+	before := owners(t, ketamaSelector(t, servers...))
+
+	// adding a server only moves keys to it
+	added := owners(t, ketamaSelector(t, append(servers, WeightedServer{"127.0.0.1:11214", 1})...))
+	moved := 0
+	for i := range before {
+		if added[i] != before[i] {
+			moved++
+			if added[i] != "127.0.0.1:11214" {
+				t.Fatalf("key %d moved from %s to %s", i, before[i], added[i])
+			}
+		}
+	}
+	if moved < testKeys/8 || moved > testKeys*3/8 {
+		t.Errorf("%d keys moved to the added server, want about a quarter", moved)
+	}
+
+	// removing a server only moves its keys
+	removed := owners(t, ketamaSelector(t, servers[1:]...))
+	for i := range before {
+		if before[i] != "127.0.0.1:11211" && removed[i] != before[i] {
+			t.Fatalf("key %d moved from %s to %s", i, before[i], removed[i])
+		}
+	}
+}
+
+func TestKetamaEject(t *testing.T) {
+	ks := ketamaSelector(t,
+		WeightedServer{"127.0.0.1:11211", 1},
+		WeightedServer{"127.0.0.1:11212", 1},
+		WeightedServer{"127.0.0.1:11213", 1},
+	)
+	before := owners(t, ks)
+	addr, err := resolveAddr("127.0.0.1:11211")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ks.Eject(addr)
+	if ejected := ks.Ejected(); len(ejected) != 1 || ejected[0].String() != addr.String() {
+		t.Errorf("Ejected() = %v, want [%s]", ejected, addr)
+	}
+	var each []string
+	ks.Each(func(a net.Addr) error {
+		each = append(each, a.String())
+		return nil
+	})
+	if len(each) != 2 {
+		t.Errorf("Each() visited %v, want the 2 servers in rotation", each)
+	}
+	ejected := owners(t, ks)
+	for i := range before {
+		if ejected[i] == addr.String() {
+			t.Fatalf("key %d picked the ejected server", i)
+		}
+		if before[i] != addr.String() && ejected[i] != before[i] {
+			t.Fatalf("key %d moved from %s to %s", i, before[i], ejected[i])
+		}
+	}
+
+	// setting the servers again keeps the server ejected
+	if err := ks.SetServers("127.0.0.1:11211", "127.0.0.1:11212", "127.0.0.1:11213"); err != nil {
+		t.Fatal(err)
+	}
+	if len(ks.Ejected()) != 1 {
+		t.Errorf("Ejected() = %v after SetServers", ks.Ejected())
+	}
+
+	ks.Restore(addr)
+	if len(ks.Ejected()) != 0 {
+		t.Errorf("Ejected() = %v after Restore", ks.Ejected())
+	}
+	restored := owners(t, ks)
+	for i := range before {
+		if restored[i] != before[i] {
+			t.Fatalf("key %d on %s after Restore, want %s", i, restored[i], before[i])
+		}
+	}
+
+	for _, a := range []string{"127.0.0.1:11211", "127.0.0.1:11212", "127.0.0.1:11213"} {
+		addr, _ := resolveAddr(a)
+		ks.Eject(addr)
+	}
+	if _, err := ks.PickServer("key"); err != ErrNoServers {
+		t.Errorf("PickServer() with every server ejected = %v, want %v", err, ErrNoServers)
+	}
+}
+
+func TestKetamaPickServerExcluding(t *testing.T) {
+	ks := ketamaSelector(t,
+		WeightedServer{"127.0.0.1:11211", 1},
+		WeightedServer{"127.0.0.1:11212", 1},
+	)
+	for i := 0; i < 100; i++ {
+		first, err := ks.PickServer(testKey(i))
+		if err != nil {
+			t.Fatal(err)
+		}
+		excluded := map[string]bool{first.String(): true}
+		next, err := ks.pickServerExcluding(testKey(i), excluded)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if next.String() == first.String() {
+			t.Fatalf("pickServerExcluding() picked the excluded %s", first)
+		}
+		excluded[next.String()] = true
+		if _, err := ks.pickServerExcluding(testKey(i), excluded); err != ErrNoServers {
+			t.Fatalf("pickServerExcluding() with every server excluded = %v, want %v", err, ErrNoServers)
+		}
+	}
+}
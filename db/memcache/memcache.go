@@ -124,6 +124,14 @@ func New(server ...string) *Client {
 	return NewFromSelector(ss)
 }
 
+// NewKetama returns a memcache client that spreads the keys over the
+// provided server(s) with consistent hashing, see KetamaSelector.
+func NewKetama(server ...string) *Client {
+	ks := new(KetamaSelector)
+	ks.SetServers(server...)
+	return NewFromSelector(ks)
+}
+
 // NewFromSelector returns a new Client using the provided ServerSelector.
 func NewFromSelector(ss ServerSelector) *Client {
 	return &Client{selector: ss}
@@ -152,10 +160,25 @@ type Client struct {
 	// be set to a number higher than your peak parallel requests.
 	MaxIdleConns int
 
+	// Retries is how many more times a single key operation is tried
+	// when connecting to its server fails. Nothing was sent then, so it
+	// is safe for every command. With a KetamaSelector each try goes to
+	// the next server on the ring that was not tried yet, whether or not
+	// the failing server gets ejected (see FailureLimit). Other selectors
+	// pick the server again, which is the same one until it's ejected.
+	Retries int
+
+	// FailureLimit is the number of consecutive network errors after
+	// which a server is ejected from an EjectingSelector. If zero, the
+	// client never ejects servers, it's left to a HealthChecker, which
+	// is also the one that restores them.
+	FailureLimit int
+
 	selector ServerSelector
 
 	lk       sync.Mutex
 	freeconn map[string][]*conn
+	failures map[string]int
 }
 
 // Item is an item to be got or stored in a memcached server.
@@ -309,20 +332,59 @@ func (c *Client) getConn(addr net.Addr) (*conn, error) {
 	return cn, nil
 }
 
-func (c *Client) onItem(item *Item, fn func(*Client, *bufio.ReadWriter, *Item) error) error {
-	addr, err := c.selector.PickServer(item.Key)
-	if err != nil {
-		return err
+// isNetworkError tells if err means the server could not be reached or
+// the connection broke, as opposed to a cache or protocol error.
+func isNetworkError(err error) bool {
+	var ne net.Error
+	var cte *ConnectTimeoutError
+	return errors.As(err, &ne) || errors.As(err, &cte) ||
+		err == io.EOF || err == io.ErrUnexpectedEOF
+}
+
+// isDialError tells if err happened while connecting, before any
+// command was sent.
+func isDialError(err error) bool {
+	var oe *net.OpError
+	var cte *ConnectTimeoutError
+	return errors.As(err, &cte) || (errors.As(err, &oe) && oe.Op == "dial")
+}
+
+// recordResult counts the consecutive network errors of addr, and
+// ejects it from the selector at FailureLimit.
+func (c *Client) recordResult(addr net.Addr, err error) {
+	if c.FailureLimit <= 0 {
+		return
 	}
-	cn, err := c.getConn(addr)
-	if err != nil {
-		return err
+	es, ok := c.selector.(EjectingSelector)
+	if !ok {
+		return
 	}
-	defer cn.condRelease(&err)
-	if err = fn(c, cn.rw, item); err != nil {
-		return err
+	c.lk.Lock()
+	if !isNetworkError(err) {
+		delete(c.failures, addr.String())
+		c.lk.Unlock()
+		return
 	}
-	return nil
+	if c.failures == nil {
+		c.failures = make(map[string]int)
+	}
+	c.failures[addr.String()]++
+	eject := c.failures[addr.String()] >= c.FailureLimit
+	if eject {
+		delete(c.failures, addr.String())
+	}
+	c.lk.Unlock()
+	if eject {
+		es.Eject(addr)
+	}
+}
+
+func (c *Client) onItem(item *Item, fn func(*Client, *bufio.ReadWriter, *Item) error) error {
+	return c.withKeyAddr(item.Key, func(addr net.Addr) error {
+		return c.withAddrRw(addr, func(rw *bufio.ReadWriter) error {
+			return fn(c, rw, item)
+		})
+	})
 }
 
 func (c *Client) FlushAll() error {
@@ -356,11 +418,37 @@ func (c *Client) withKeyAddr(key string, fn func(net.Addr) error) (err error) {
 	if !legalKey(key) {
 		return ErrMalformedKey
 	}
-	addr, err := c.selector.PickServer(key)
-	if err != nil {
-		return err
+	var failed map[string]bool
+	var lastErr error
+	for try := 0; ; try++ {
+		addr, err := c.pickServer(key, failed)
+		if err == ErrNoServers && lastErr != nil {
+			// every server was tried
+			return lastErr
+		}
+		if err != nil {
+			return err
+		}
+		err = fn(addr)
+		c.recordResult(addr, err)
+		if err == nil || try >= c.Retries || !isDialError(err) {
+			return err
+		}
+		if failed == nil {
+			failed = make(map[string]bool)
+		}
+		failed[addr.String()] = true
+		lastErr = err
+	}
+}
+
+// pickServer picks the server of key, another one than the failed ones if
+// the selector can.
+func (c *Client) pickServer(key string, failed map[string]bool) (net.Addr, error) {
+	if es, ok := c.selector.(excludingSelector); ok && len(failed) > 0 {
+		return es.pickServerExcluding(key, failed)
 	}
-	return fn(addr)
+	return c.selector.PickServer(key)
 }
 
 func (c *Client) withAddrRw(addr net.Addr, fn func(*bufio.ReadWriter) error) (err error) {
@@ -494,7 +582,9 @@ func (c *Client) GetMulti(keys []string) (map[string]*Item, error) {
 	ch := make(chan error, buffered)
 	for addr, keys := range keyMap {
 		go func(addr net.Addr, keys []string) {
-			ch <- c.getFromAddr(addr, keys, addItemToMap)
+			err := c.getFromAddr(addr, keys, addItemToMap)
+			c.recordResult(addr, err)
+			ch <- err
 		}(addr, keys)
 	}
 
@@ -0,0 +1,225 @@
+/*
+Copyright 2011 The gomemcache AUTHORS
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package memcache
+
+import (
+	"net"
+	"testing"
+
+	"github.com/unix-world/smartgoext/db/memcache/memcachetest"
+)
+
+// testServers starts n in-process memcached servers.
+func testServers(t *testing.T, n int) []*memcachetest.Server {
+	t.Helper()
+	servers := make([]*memcachetest.Server, n)
+	for i := range servers {
+		s, err := memcachetest.NewServer()
+		if err != nil {
+			t.Fatalf("NewServer() = %v", err)
+		}
+		t.Cleanup(func() { s.Close() })
+		servers[i] = s
+	}
+	return servers
+}
+
+func ketamaClient(servers []*memcachetest.Server) *Client {
+	addrs := make([]string, len(servers))
+	for i, s := range servers {
+		addrs[i] = s.Addr()
+	}
+	return NewKetama(addrs...)
+}
+
+// keyOn returns a key that the client picks the server at addr for.
+func keyOn(t *testing.T, c *Client, addr string) string {
+	t.Helper()
+	for i := 0; i < testKeys; i++ {
+		picked, err := c.selector.PickServer(testKey(i))
+		if err != nil {
+			t.Fatalf("PickServer() = %v", err)
+		}
+		if picked.String() == addr {
+			return testKey(i)
+		}
+	}
+	t.Fatalf("no key on %s", addr)
+	return ""
+}
+
+func ejected(c *Client) []net.Addr {
+	return c.selector.(EjectingSelector).Ejected()
+}
+
+func TestKetamaClient(t *testing.T) {
+	servers := testServers(t, 3)
+	c := ketamaClient(servers)
+	for i := 0; i < 300; i++ {
+		if err := c.Set(&Item{Key: testKey(i), Value: []byte("v")}); err != nil {
+			t.Fatalf("Set() = %v", err)
+		}
+	}
+	total := 0
+	for _, s := range servers {
+		if s.Len() == 0 {
+			t.Errorf("server %s got no keys", s.Addr())
+		}
+		total += s.Len()
+	}
+	if total != 300 {
+		t.Errorf("servers hold %d items, want 300", total)
+	}
+
+	keys := make([]string, 300)
+	for i := range keys {
+		keys[i] = testKey(i)
+	}
+	items, err := c.GetMulti(keys)
+	if err != nil {
+		t.Fatalf("GetMulti() = %v", err)
+	}
+	if len(items) != 300 {
+		t.Errorf("GetMulti() returned %d items, want 300", len(items))
+	}
+}
+
+func TestRetryExcludesFailedServer(t *testing.T) {
+	servers := testServers(t, 3)
+	c := ketamaClient(servers)
+	key := keyOn(t, c, servers[0].Addr())
+	servers[0].Stop()
+
+	if err := c.Set(&Item{Key: key, Value: []byte("v")}); err == nil {
+		t.Fatal("Set() on a stopped server without retries succeeded")
+	}
+
+	// without a FailureLimit nothing is ejected, the retry goes to the
+	// next server on the ring
+	c.Retries = 1
+	if err := c.Set(&Item{Key: key, Value: []byte("v")}); err != nil {
+		t.Fatalf("Set() with a retry = %v", err)
+	}
+	if servers[1].Len()+servers[2].Len() != 1 {
+		t.Errorf("the item is not on the other servers")
+	}
+	if l := ejected(c); len(l) != 0 {
+		t.Errorf("ejected %v without a FailureLimit", l)
+	}
+
+	// every server down, the error of the last try
+	for _, s := range servers {
+		s.Stop()
+	}
+	c.Retries = 5
+	if err := c.Set(&Item{Key: key, Value: []byte("v")}); err == nil || err == ErrNoServers {
+		t.Errorf("Set() with every server stopped = %v, want the connection error", err)
+	}
+}
+
+func TestFailureLimitEjects(t *testing.T) {
+	servers := testServers(t, 2)
+	c := ketamaClient(servers)
+	c.FailureLimit = 2
+	key := keyOn(t, c, servers[0].Addr())
+	servers[0].Stop()
+
+	for i := 0; i < c.FailureLimit; i++ {
+		if len(ejected(c)) != 0 {
+			t.Fatalf("ejected after %d failures", i)
+		}
+		if _, err := c.Get(key); err == nil || err == ErrCacheMiss {
+			t.Fatalf("Get() on a stopped server = %v", err)
+		}
+	}
+	if l := ejected(c); len(l) != 1 || l[0].String() != servers[0].Addr() {
+		t.Fatalf("Ejected() = %v, want [%s]", l, servers[0].Addr())
+	}
+
+	// the keys of the ejected server fail over
+	if err := c.Set(&Item{Key: key, Value: []byte("v")}); err != nil {
+		t.Fatalf("Set() after the ejection = %v", err)
+	}
+	if servers[1].Len() != 1 {
+		t.Errorf("the item is not on the other server")
+	}
+
+	// a success resets the count
+	c.selector.(EjectingSelector).Restore(ejected(c)[0])
+	c.Get(key)
+	if err := servers[0].Start(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.Get(key); err != ErrCacheMiss {
+		t.Fatalf("Get() on the restarted server = %v", err)
+	}
+	servers[0].Stop()
+	c.Get(key)
+	if l := ejected(c); len(l) != 0 {
+		t.Errorf("ejected %v after failures with a success in between", l)
+	}
+}
+
+func TestHealthChecker(t *testing.T) {
+	if _, err := NewHealthChecker(New("127.0.0.1:11211")); err != ErrNotEjecting {
+		t.Errorf("NewHealthChecker() with a ServerList = %v, want %v", err, ErrNotEjecting)
+	}
+
+	servers := testServers(t, 2)
+	c := ketamaClient(servers)
+	hc, err := NewHealthChecker(c)
+	if err != nil {
+		t.Fatalf("NewHealthChecker() = %v", err)
+	}
+	hc.FailureLimit = 2
+	hc.RiseLimit = 2
+	var changes []bool
+	hc.OnChange = func(addr net.Addr, up bool) {
+		if addr.String() != servers[0].Addr() {
+			t.Errorf("OnChange(%s)", addr)
+		}
+		changes = append(changes, up)
+	}
+
+	hc.Check()
+	if len(ejected(c)) != 0 || len(changes) != 0 {
+		t.Fatalf("ejected %v with every server up", ejected(c))
+	}
+
+	servers[0].Stop()
+	hc.Check()
+	if len(ejected(c)) != 0 {
+		t.Fatalf("ejected %v before the FailureLimit", ejected(c))
+	}
+	hc.Check()
+	if l := ejected(c); len(l) != 1 || l[0].String() != servers[0].Addr() {
+		t.Fatalf("Ejected() = %v, want [%s]", l, servers[0].Addr())
+	}
+
+	servers[0].Start()
+	hc.Check()
+	if len(ejected(c)) != 1 {
+		t.Fatal("restored before the RiseLimit")
+	}
+	hc.Check()
+	if l := ejected(c); len(l) != 0 {
+		t.Fatalf("Ejected() = %v after the server came back", l)
+	}
+	if len(changes) != 2 || changes[0] || !changes[1] {
+		t.Errorf("OnChange() calls %v, want [false true]", changes)
+	}
+}
@@ -0,0 +1,422 @@
+/*
+Copyright 2011 The gomemcache AUTHORS
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package memcachetest provides an in-process memcached server speaking the
+// text protocol, for testing memcache clients without a real memcached.
+package memcachetest
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// relativeExpirationLimit is the largest expiration taken as a number of
+// seconds from now; larger values are Unix timestamps, as in memcached.
+const relativeExpirationLimit = 60 * 60 * 24 * 30
+
+type item struct {
+	value   []byte
+	flags   uint32
+	casid   uint64
+	expires time.Time // zero means no expiration
+}
+
+// Server is an in-process memcached server. It listens on a loopback address
+// and keeps its items in memory.
+//
+// Stop and Start simulate an outage: while stopped, connections are refused,
+// and the open ones are closed. The items are kept.
+type Server struct {
+	addr string
+
+	mu    sync.Mutex
+	ln    net.Listener
+	conns map[net.Conn]bool
+	items map[string]*item
+	casid uint64
+	wg    sync.WaitGroup
+}
+
+// NewServer starts a Server on a random port of 127.0.0.1.
+func NewServer() (*Server, error) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return nil, err
+	}
+	s := &Server{
+		addr:  ln.Addr().String(),
+		conns: make(map[net.Conn]bool),
+		items: make(map[string]*item),
+	}
+	s.serve(ln)
+	return s, nil
+}
+
+// Addr returns the host:port the server listens on, it stays the same
+// across Stop and Start.
+func (s *Server) Addr() string {
+	return s.addr
+}
+
+// Stop closes the listener and the open connections.
+func (s *Server) Stop() {
+	s.mu.Lock()
+	if s.ln != nil {
+		s.ln.Close()
+		s.ln = nil
+	}
+	for c := range s.conns {
+		c.Close()
+	}
+	s.mu.Unlock()
+	s.wg.Wait()
+}
+
+// Start listens again on the address of the server after a Stop.
+func (s *Server) Start() error {
+	s.mu.Lock()
+	running := s.ln != nil
+	s.mu.Unlock()
+	if running {
+		return nil
+	}
+	ln, err := net.Listen("tcp", s.addr)
+	if err != nil {
+		return err
+	}
+	s.serve(ln)
+	return nil
+}
+
+// Close stops the server for good.
+func (s *Server) Close() error {
+	s.Stop()
+	return nil
+}
+
+// Len returns the number of unexpired items.
+func (s *Server) Len() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	n := 0
+	for key := range s.items {
+		if s.lookup(key) != nil {
+			n++
+		}
+	}
+	return n
+}
+
+func (s *Server) serve(ln net.Listener) {
+	s.mu.Lock()
+	s.ln = ln
+	s.mu.Unlock()
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		for {
+			c, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			s.mu.Lock()
+			if s.ln != ln {
+				s.mu.Unlock()
+				c.Close()
+				return
+			}
+			s.conns[c] = true
+			s.mu.Unlock()
+			s.wg.Add(1)
+			go func() {
+				defer s.wg.Done()
+				s.handle(c)
+				s.mu.Lock()
+				delete(s.conns, c)
+				s.mu.Unlock()
+				c.Close()
+			}()
+		}
+	}()
+}
+
+func (s *Server) handle(c net.Conn) {
+	rw := bufio.NewReadWriter(bufio.NewReader(c), bufio.NewWriter(c))
+	for {
+		line, err := rw.ReadString('\n')
+		if err != nil {
+			return
+		}
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			fmt.Fprintf(rw, "ERROR\r\n")
+		} else if quit := s.dispatch(rw, fields); quit {
+			rw.Flush()
+			return
+		}
+		if err := rw.Flush(); err != nil {
+			return
+		}
+	}
+}
+
+// dispatch runs one command, it returns true when the connection has to be closed.
+func (s *Server) dispatch(rw *bufio.ReadWriter, fields []string) bool {
+	cmd, args := fields[0], fields[1:]
+	switch cmd {
+	case "get", "gets":
+		s.get(rw.Writer, args, cmd == "gets")
+	case "set", "add", "replace", "append", "prepend", "cas":
+		return s.store(rw, cmd, args)
+	case "delete":
+		s.delete(rw.Writer, args)
+	case "incr", "decr":
+		s.incrDecr(rw.Writer, cmd == "incr", args)
+	case "touch":
+		s.touch(rw.Writer, args)
+	case "flush_all":
+		s.mu.Lock()
+		s.items = make(map[string]*item)
+		s.mu.Unlock()
+		if !noreply(args) {
+			fmt.Fprintf(rw, "OK\r\n")
+		}
+	case "version":
+		fmt.Fprintf(rw, "VERSION 1.6.0-memcachetest\r\n")
+	case "quit":
+		return true
+	default:
+		fmt.Fprintf(rw, "ERROR\r\n")
+	}
+	return false
+}
+
+func noreply(args []string) bool {
+	return len(args) > 0 && args[len(args)-1] == "noreply"
+}
+
+// expiration turns the exptime of a command into a time, zero for none.
+func expiration(exptime int64) time.Time {
+	switch {
+	case exptime == 0:
+		return time.Time{}
+	case exptime < 0:
+		return time.Unix(1, 0)
+	case exptime <= relativeExpirationLimit:
+		return time.Now().Add(time.Duration(exptime) * time.Second)
+	}
+	return time.Unix(exptime, 0)
+}
+
+// lookup returns the item of key, nil if there is none or it has expired.
+// The caller holds s.mu.
+func (s *Server) lookup(key string) *item {
+	it, ok := s.items[key]
+	if !ok {
+		return nil
+	}
+	if !it.expires.IsZero() && !time.Now().Before(it.expires) {
+		delete(s.items, key)
+		return nil
+	}
+	return it
+}
+
+// put stores it under key with a new cas id. The caller holds s.mu.
+func (s *Server) put(key string, it *item) {
+	s.casid++
+	it.casid = s.casid
+	s.items[key] = it
+}
+
+func (s *Server) get(w *bufio.Writer, keys []string, withCas bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, key := range keys {
+		it := s.lookup(key)
+		if it == nil {
+			continue
+		}
+		if withCas {
+			fmt.Fprintf(w, "VALUE %s %d %d %d\r\n", key, it.flags, len(it.value), it.casid)
+		} else {
+			fmt.Fprintf(w, "VALUE %s %d %d\r\n", key, it.flags, len(it.value))
+		}
+		w.Write(it.value)
+		w.WriteString("\r\n")
+	}
+	w.WriteString("END\r\n")
+}
+
+func (s *Server) store(rw *bufio.ReadWriter, cmd string, args []string) bool {
+	// <cmd> <key> <flags> <exptime> <bytes> [<cas unique>] [noreply]
+	want := 4
+	if cmd == "cas" {
+		want = 5
+	}
+	if len(args) < want {
+		fmt.Fprintf(rw, "ERROR\r\n")
+		return false
+	}
+	key := args[0]
+	flags, err1 := strconv.ParseUint(args[1], 10, 32)
+	exptime, err2 := strconv.ParseInt(args[2], 10, 64)
+	size, err3 := strconv.Atoi(args[3])
+	var casid uint64
+	var err4 error
+	if cmd == "cas" {
+		casid, err4 = strconv.ParseUint(args[4], 10, 64)
+	}
+	if err1 != nil || err2 != nil || err3 != nil || err4 != nil || size < 0 {
+		fmt.Fprintf(rw, "CLIENT_ERROR bad command line format\r\n")
+		return true
+	}
+	data := make([]byte, size+2)
+	if _, err := io.ReadFull(rw, data); err != nil {
+		return true
+	}
+	if string(data[size:]) != "\r\n" {
+		fmt.Fprintf(rw, "CLIENT_ERROR bad data chunk\r\n")
+		return false
+	}
+	value := data[:size]
+
+	s.mu.Lock()
+	result := "STORED"
+	old := s.lookup(key)
+	switch cmd {
+	case "set":
+		s.put(key, &item{value: value, flags: uint32(flags), expires: expiration(exptime)})
+	case "add":
+		if old != nil {
+			result = "NOT_STORED"
+		} else {
+			s.put(key, &item{value: value, flags: uint32(flags), expires: expiration(exptime)})
+		}
+	case "replace":
+		if old == nil {
+			result = "NOT_STORED"
+		} else {
+			s.put(key, &item{value: value, flags: uint32(flags), expires: expiration(exptime)})
+		}
+	case "append", "prepend":
+		// flags and exptime are ignored, as in memcached
+		if old == nil {
+			result = "NOT_STORED"
+		} else if cmd == "append" {
+			s.put(key, &item{value: append(append([]byte(nil), old.value...), value...), flags: old.flags, expires: old.expires})
+		} else {
+			s.put(key, &item{value: append(append([]byte(nil), value...), old.value...), flags: old.flags, expires: old.expires})
+		}
+	case "cas":
+		switch {
+		case old == nil:
+			result = "NOT_FOUND"
+		case old.casid != casid:
+			result = "EXISTS"
+		default:
+			s.put(key, &item{value: value, flags: uint32(flags), expires: expiration(exptime)})
+		}
+	}
+	s.mu.Unlock()
+	if !noreply(args) {
+		fmt.Fprintf(rw, "%s\r\n", result)
+	}
+	return false
+}
+
+func (s *Server) delete(w *bufio.Writer, args []string) {
+	if len(args) < 1 {
+		fmt.Fprintf(w, "ERROR\r\n")
+		return
+	}
+	s.mu.Lock()
+	result := "NOT_FOUND"
+	if s.lookup(args[0]) != nil {
+		delete(s.items, args[0])
+		result = "DELETED"
+	}
+	s.mu.Unlock()
+	if !noreply(args) {
+		fmt.Fprintf(w, "%s\r\n", result)
+	}
+}
+
+func (s *Server) incrDecr(w *bufio.Writer, incr bool, args []string) {
+	if len(args) < 2 {
+		fmt.Fprintf(w, "ERROR\r\n")
+		return
+	}
+	delta, err := strconv.ParseUint(args[1], 10, 64)
+	if err != nil {
+		fmt.Fprintf(w, "CLIENT_ERROR invalid numeric delta argument\r\n")
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	it := s.lookup(args[0])
+	if it == nil {
+		if !noreply(args) {
+			fmt.Fprintf(w, "NOT_FOUND\r\n")
+		}
+		return
+	}
+	n, err := strconv.ParseUint(strings.TrimSpace(string(it.value)), 10, 64)
+	if err != nil {
+		fmt.Fprintf(w, "CLIENT_ERROR cannot increment or decrement non-numeric value\r\n")
+		return
+	}
+	switch {
+	case incr:
+		n += delta // wraps around at 64 bits, as in memcached
+	case delta > n:
+		n = 0
+	default:
+		n -= delta
+	}
+	s.put(args[0], &item{value: []byte(strconv.FormatUint(n, 10)), flags: it.flags, expires: it.expires})
+	if !noreply(args) {
+		fmt.Fprintf(w, "%d\r\n", n)
+	}
+}
+
+func (s *Server) touch(w *bufio.Writer, args []string) {
+	if len(args) < 2 {
+		fmt.Fprintf(w, "ERROR\r\n")
+		return
+	}
+	exptime, err := strconv.ParseInt(args[1], 10, 64)
+	if err != nil {
+		fmt.Fprintf(w, "CLIENT_ERROR invalid exptime argument\r\n")
+		return
+	}
+	s.mu.Lock()
+	result := "NOT_FOUND"
+	if it := s.lookup(args[0]); it != nil {
+		it.expires = expiration(exptime)
+		result = "TOUCHED"
+	}
+	s.mu.Unlock()
+	if !noreply(args) {
+		fmt.Fprintf(w, "%s\r\n", result)
+	}
+}
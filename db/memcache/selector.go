@@ -35,6 +35,27 @@ type ServerSelector interface {
 	Each(func(net.Addr) error) error
 }
 
+// EjectingSelector is a ServerSelector that can take servers out of
+// rotation and put them back. PickServer and Each skip ejected servers.
+// The Client ejects servers after FailureLimit network errors, and a
+// HealthChecker ejects and restores them as they fail or answer pings.
+type EjectingSelector interface {
+	ServerSelector
+	// Eject takes the server out of rotation.
+	Eject(addr net.Addr)
+	// Restore puts an ejected server back in rotation.
+	Restore(addr net.Addr)
+	// Ejected returns the servers that are out of rotation.
+	Ejected() []net.Addr
+}
+
+// excludingSelector is a ServerSelector that can pick the server of a key
+// among the servers not excluded, keyed by address. The Client uses it to
+// retry on another server than the ones that failed.
+type excludingSelector interface {
+	pickServerExcluding(key string, excluded map[string]bool) (net.Addr, error)
+}
+
 // ServerList is a simple ServerSelector. Its zero value is usable.
 type ServerList struct {
 	mu    sync.RWMutex
@@ -68,19 +89,11 @@ func (s *staticAddr) String() string  { return s.str }
 func (ss *ServerList) SetServers(servers ...string) error {
 	naddr := make([]net.Addr, len(servers))
 	for i, server := range servers {
-		if strings.Contains(server, "/") {
-			addr, err := net.ResolveUnixAddr("unix", server)
-			if err != nil {
-				return err
-			}
-			naddr[i] = newStaticAddr(addr)
-		} else {
-			tcpaddr, err := net.ResolveTCPAddr("tcp", server)
-			if err != nil {
-				return err
-			}
-			naddr[i] = newStaticAddr(tcpaddr)
+		addr, err := resolveAddr(server)
+		if err != nil {
+			return err
 		}
+		naddr[i] = addr
 	}
 
 	ss.mu.Lock()
@@ -89,6 +102,23 @@ func (ss *ServerList) SetServers(servers ...string) error {
 	return nil
 }
 
+// resolveAddr resolves a server name, a path for a unix socket or
+// host:port for TCP.
+func resolveAddr(server string) (net.Addr, error) {
+	if strings.Contains(server, "/") {
+		addr, err := net.ResolveUnixAddr("unix", server)
+		if err != nil {
+			return nil, err
+		}
+		return newStaticAddr(addr), nil
+	}
+	tcpaddr, err := net.ResolveTCPAddr("tcp", server)
+	if err != nil {
+		return nil, err
+	}
+	return newStaticAddr(tcpaddr), nil
+}
+
 // Each iterates over each server calling the given function
 func (ss *ServerList) Each(f func(net.Addr) error) error {
 	ss.mu.RLock()
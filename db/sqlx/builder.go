@@ -0,0 +1,302 @@
+package sqlx
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/unix-world/smartgoext/db/sqlx/reflectx"
+	"github.com/unix-world/smartgoext/db/xorm-builder"
+)
+
+// BuilderSQL writes the statement of b with the placeholders of bindType, the
+// arguments are returned in order of their placeholders.
+func BuilderSQL(bindType int, b *builder.Builder) (string, []interface{}, error) {
+	w := builder.NewWriter()
+	if err := b.WriteTo(w); err != nil {
+		return "", nil, err
+	}
+
+	args := w.Args()
+	for i := range args {
+		if namedArg, ok := args[i].(sql.NamedArg); ok {
+			args[i] = namedArg.Value
+		}
+	}
+	return Rebind(bindType, w.String()), args, nil
+}
+
+// bindTypeOf returns the bindtype for the driver of a DB, Tx or Conn.
+func bindTypeOf(i interface{}) int {
+	switch v := i.(type) {
+	case interface{ DriverName() string }:
+		return BindType(v.DriverName())
+	case *Conn:
+		return BindType(v.driverName)
+	}
+	return UNKNOWN
+}
+
+// QueryBuilderContext queries the database with the statement of b using the
+// placeholders of the driver of q.
+func QueryBuilderContext(ctx context.Context, q QueryerContext, b *builder.Builder) (*Rows, error) {
+	query, args, err := BuilderSQL(bindTypeOf(q), b)
+	if err != nil {
+		return nil, err
+	}
+	return q.QueryxContext(ctx, query, args...)
+}
+
+// SelectBuilderContext executes the statement of b using the placeholders of the
+// driver of q and scans each row into dest, which must be a slice of structs,
+// scannables or map[string]interface{}.
+func SelectBuilderContext(ctx context.Context, q QueryerContext, dest interface{}, b *builder.Builder) error {
+	query, args, err := BuilderSQL(bindTypeOf(q), b)
+	if err != nil {
+		return err
+	}
+
+	if maps, ok := dest.(*[]map[string]interface{}); ok {
+		return selectMaps(ctx, q, maps, query, args)
+	}
+	return SelectContext(ctx, q, dest, query, args...)
+}
+
+func selectMaps(ctx context.Context, q QueryerContext, dest *[]map[string]interface{}, query string, args []interface{}) error {
+	rows, err := q.QueryxContext(ctx, query, args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	*dest = (*dest)[:0]
+	for rows.Next() {
+		m := map[string]interface{}{}
+		if err := rows.MapScan(m); err != nil {
+			return err
+		}
+		*dest = append(*dest, m)
+	}
+	return rows.Err()
+}
+
+// GetBuilderContext executes the statement of b using the placeholders of the
+// driver of q and scans the row into dest, which must be a struct, a scannable or
+// a map[string]interface{}. If there is no row, sql.ErrNoRows is returned.
+func GetBuilderContext(ctx context.Context, q QueryerContext, dest interface{}, b *builder.Builder) error {
+	query, args, err := BuilderSQL(bindTypeOf(q), b)
+	if err != nil {
+		return err
+	}
+
+	r := q.QueryRowxContext(ctx, query, args...)
+	if m, ok := dest.(map[string]interface{}); ok {
+		return r.MapScan(m)
+	}
+	return r.scanAny(dest, false)
+}
+
+// ExecBuilderContext executes the statement of b using the placeholders of the
+// driver of e.
+func ExecBuilderContext(ctx context.Context, e ExecerContext, b *builder.Builder) (sql.Result, error) {
+	query, args, err := BuilderSQL(bindTypeOf(e), b)
+	if err != nil {
+		return nil, err
+	}
+	return e.ExecContext(ctx, query, args...)
+}
+
+// Keyset paginates a select by the values of its ordering columns, the next page
+// starts after the last row of the previous page which is encoded in a cursor.
+// The combination of Columns must be unique and the columns must not be NULL.
+type Keyset struct {
+	Columns []string // ordering columns, like "created" and "id"
+	Desc    bool     // descending order
+	Limit   int      // rows per page
+}
+
+// SelectPageContext orders and limits b to the page of k after the row of cursor,
+// an empty cursor selects the first page. The rows are scanned into dest like
+// SelectBuilderContext does and the cursor of the next page is returned, which is
+// empty after the last page. The Builder needs a dialect for the limit and is
+// modified, so a new one has to be built for every page.
+func SelectPageContext(ctx context.Context, q QueryerContext, dest interface{}, b *builder.Builder, k Keyset, cursor string) (string, error) {
+	if len(k.Columns) <= 0 || k.Limit <= 0 {
+		return "", errors.New("keyset needs columns and a positive limit")
+	}
+
+	if len(cursor) > 0 {
+		vals, err := decodeCursor(cursor, len(k.Columns))
+		if err != nil {
+			return "", err
+		}
+		b.Where(k.after(vals))
+	}
+
+	order := " ASC"
+	if k.Desc {
+		order = " DESC"
+	}
+	b.OrderBy(strings.Join(k.Columns, order+",") + order)
+	// one more row tells whether there is a next page
+	b.Limit(k.Limit + 1)
+
+	if err := SelectBuilderContext(ctx, q, dest, b); err != nil {
+		return "", err
+	}
+
+	direct := reflect.Indirect(reflect.ValueOf(dest))
+	if direct.Len() <= k.Limit {
+		return "", nil
+	}
+	direct.SetLen(k.Limit)
+
+	vals, err := k.values(mapperFor(q), direct.Index(k.Limit-1))
+	if err != nil {
+		return "", err
+	}
+	return encodeCursor(vals)
+}
+
+// after returns the condition of the rows following the row with vals
+func (k Keyset) after(vals []interface{}) builder.Cond {
+	conds := make([]builder.Cond, 0, len(k.Columns))
+	for i, col := range k.Columns {
+		var cond builder.Cond
+		if k.Desc {
+			cond = builder.Lt{col: vals[i]}
+		} else {
+			cond = builder.Gt{col: vals[i]}
+		}
+
+		if i > 0 {
+			eq := builder.Eq{}
+			for j := 0; j < i; j++ {
+				eq[k.Columns[j]] = vals[j]
+			}
+			cond = builder.And(eq, cond)
+		}
+		conds = append(conds, cond)
+	}
+	return builder.Or(conds...)
+}
+
+// values returns the values of the keyset columns of a scanned row, which is a
+// struct, a pointer to a struct or a map[string]interface{}
+func (k Keyset) values(m *reflectx.Mapper, row reflect.Value) ([]interface{}, error) {
+	row = reflect.Indirect(row)
+	vals := make([]interface{}, len(k.Columns))
+	for i, col := range k.Columns {
+		// the result column of a qualified column carries its name only
+		name := col[strings.LastIndex(col, ".")+1:]
+
+		var val interface{}
+		switch row.Kind() {
+		case reflect.Map:
+			field := row.MapIndex(reflect.ValueOf(name))
+			if !field.IsValid() {
+				return nil, fmt.Errorf("missing keyset column %s in %s", name, row.Type())
+			}
+			val = field.Interface()
+		case reflect.Struct:
+			fi, ok := m.TypeMap(row.Type()).Names[name]
+			if !ok {
+				return nil, fmt.Errorf("missing keyset column %s in %s", name, row.Type())
+			}
+			val = reflectx.FieldByIndexesReadOnly(row, fi.Index).Interface()
+		default:
+			return nil, fmt.Errorf("keyset needs struct or map rows but got %s", row.Kind())
+		}
+
+		// reduce scanned values like sql.NullInt64 to their driver values
+		val, err := driver.DefaultParameterConverter.ConvertValue(val)
+		if err != nil {
+			return nil, err
+		}
+		if val == nil {
+			return nil, fmt.Errorf("keyset column %s is NULL", name)
+		}
+		vals[i] = val
+	}
+	return vals, nil
+}
+
+// encodeCursor encodes driver values as tagged strings, which keep their type
+// through the JSON encoding of the cursor
+func encodeCursor(vals []interface{}) (string, error) {
+	tagged := make([]string, len(vals))
+	for i, val := range vals {
+		switch v := val.(type) {
+		case int64:
+			tagged[i] = "i" + strconv.FormatInt(v, 10)
+		case float64:
+			tagged[i] = "f" + strconv.FormatFloat(v, 'g', -1, 64)
+		case bool:
+			tagged[i] = "b" + strconv.FormatBool(v)
+		case string:
+			tagged[i] = "s" + v
+		case []byte:
+			tagged[i] = "x" + base64.StdEncoding.EncodeToString(v)
+		case time.Time:
+			tagged[i] = "t" + v.Format(time.RFC3339Nano)
+		default:
+			return "", fmt.Errorf("unsupported keyset value type %T", val)
+		}
+	}
+
+	data, err := json.Marshal(tagged)
+	if err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(data), nil
+}
+
+func decodeCursor(cursor string, n int) ([]interface{}, error) {
+	errCursor := errors.New("invalid keyset cursor")
+
+	data, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return nil, errCursor
+	}
+	var tagged []string
+	if err := json.Unmarshal(data, &tagged); err != nil || len(tagged) != n {
+		return nil, errCursor
+	}
+
+	vals := make([]interface{}, n)
+	for i, t := range tagged {
+		if len(t) <= 0 {
+			return nil, errCursor
+		}
+
+		var err error
+		switch t[0] {
+		case 'i':
+			vals[i], err = strconv.ParseInt(t[1:], 10, 64)
+		case 'f':
+			vals[i], err = strconv.ParseFloat(t[1:], 64)
+		case 'b':
+			vals[i], err = strconv.ParseBool(t[1:])
+		case 's':
+			vals[i] = t[1:]
+		case 'x':
+			vals[i], err = base64.StdEncoding.DecodeString(t[1:])
+		case 't':
+			vals[i], err = time.Parse(time.RFC3339Nano, t[1:])
+		default:
+			return nil, errCursor
+		}
+		if err != nil {
+			return nil, errCursor
+		}
+	}
+	return vals, nil
+}
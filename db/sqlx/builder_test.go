@@ -0,0 +1,446 @@
+package sqlx
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"io"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/unix-world/smartgoext/db/xorm-builder"
+)
+
+// fakeConn is a database connection which hands every statement to a handler,
+// which returns the columns and rows of the result.
+type fakeConn struct {
+	queries []string
+	args    [][]driver.Value
+	handle  func(query string, args []driver.Value) ([]string, [][]driver.Value)
+}
+
+func (c *fakeConn) Connect(context.Context) (driver.Conn, error) { return c, nil }
+func (c *fakeConn) Driver() driver.Driver                        { return nil }
+
+func (c *fakeConn) Prepare(string) (driver.Stmt, error) {
+	return nil, errors.New("fake: prepared statements are not supported")
+}
+func (c *fakeConn) Close() error              { return nil }
+func (c *fakeConn) Begin() (driver.Tx, error) { return nil, errors.New("fake: no transactions") }
+
+func (c *fakeConn) QueryContext(_ context.Context, query string, named []driver.NamedValue) (driver.Rows, error) {
+	args := make([]driver.Value, len(named))
+	for i, arg := range named {
+		args[i] = arg.Value
+	}
+	c.queries = append(c.queries, query)
+	c.args = append(c.args, args)
+
+	cols, rows := c.handle(query, args)
+	return &fakeRows{cols: cols, rows: rows}, nil
+}
+
+func (c *fakeConn) ExecContext(ctx context.Context, query string, named []driver.NamedValue) (driver.Result, error) {
+	rows, err := c.QueryContext(ctx, query, named)
+	if err != nil {
+		return nil, err
+	}
+	return driver.RowsAffected(len(rows.(*fakeRows).rows)), nil
+}
+
+type fakeRows struct {
+	cols []string
+	rows [][]driver.Value
+}
+
+func (r *fakeRows) Columns() []string { return r.cols }
+func (r *fakeRows) Close() error      { return nil }
+
+func (r *fakeRows) Next(dest []driver.Value) error {
+	if len(r.rows) == 0 {
+		return io.EOF
+	}
+	copy(dest, r.rows[0])
+	r.rows = r.rows[1:]
+	return nil
+}
+
+func fakeDB(driverName string, handle func(query string, args []driver.Value) ([]string, [][]driver.Value)) (*DB, *fakeConn) {
+	conn := &fakeConn{handle: handle}
+	return NewDb(sql.OpenDB(conn), driverName), conn
+}
+
+type pageRow struct {
+	ID      int64     `db:"id"`
+	Created time.Time `db:"created"`
+	Name    string    `db:"name"`
+}
+
+func testRows() []pageRow {
+	start := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	var rows []pageRow
+	for i := int64(1); i <= 8; i++ {
+		rows = append(rows, pageRow{
+			ID: i,
+			// pairs of rows share their creation time
+			Created: start.Add(time.Duration((i-1)/2) * time.Hour),
+			Name:    "row " + strconv.FormatInt(i, 10),
+		})
+	}
+	return rows
+}
+
+func TestBuilderSQL(t *testing.T) {
+	b := builder.Postgres().Select("id").From("t").
+		Where(builder.Eq{"a": 1, "b": sql.Named("b", "x")}.And(builder.In("c", 2, 3)))
+	for _, tc := range []struct {
+		bindType int
+		query    string
+	}{
+		{QUESTION, "SELECT id FROM t WHERE a=? AND b=? AND c IN (?,?)"},
+		{DOLLAR, "SELECT id FROM t WHERE a=$1 AND b=$2 AND c IN ($3,$4)"},
+		{AT, "SELECT id FROM t WHERE a=@p1 AND b=@p2 AND c IN (@p3,@p4)"},
+		{NAMED, "SELECT id FROM t WHERE a=:arg1 AND b=:arg2 AND c IN (:arg3,:arg4)"},
+	} {
+		query, args, err := BuilderSQL(tc.bindType, b)
+		if err != nil {
+			t.Fatalf("BuilderSQL(%d) = %v", tc.bindType, err)
+		}
+		if query != tc.query {
+			t.Errorf("BuilderSQL(%d) = %q, want %q", tc.bindType, query, tc.query)
+		}
+		if want := []interface{}{1, "x", 2, 3}; !reflect.DeepEqual(args, want) {
+			t.Errorf("BuilderSQL(%d) args = %#v, want %#v", tc.bindType, args, want)
+		}
+	}
+
+	// the dialect of the builder decides the syntax but not the placeholders
+	query, _, err := BuilderSQL(AT, builder.MsSQL().Select("id").From("t").Where(builder.Eq{"a": 1}).Limit(2))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "SELECT id FROM (SELECT TOP 2 id,ROW_NUMBER() OVER (ORDER BY (SELECT 1)) AS RN FROM t WHERE a=@p1) at"; query != want {
+		t.Errorf("BuilderSQL() = %q, want %q", query, want)
+	}
+
+	if _, _, err := BuilderSQL(DOLLAR, builder.Select("id")); err != builder.ErrNoTableName {
+		t.Errorf("BuilderSQL() without table = %v, want %v", err, builder.ErrNoTableName)
+	}
+}
+
+func TestSelectBuilderContext(t *testing.T) {
+	ctx := context.Background()
+	rows := testRows()[:2]
+	handle := func(query string, args []driver.Value) ([]string, [][]driver.Value) {
+		var values [][]driver.Value
+		for _, row := range rows {
+			values = append(values, []driver.Value{row.ID, row.Created, row.Name})
+		}
+		return []string{"id", "created", "name"}, values
+	}
+
+	for driverName, want := range map[string]string{
+		"postgres":  "SELECT id,created,name FROM t WHERE name<>$1",
+		"mysql":     "SELECT id,created,name FROM t WHERE name<>?",
+		"sqlserver": "SELECT id,created,name FROM t WHERE name<>@p1",
+		"godror":    "SELECT id,created,name FROM t WHERE name<>:arg1",
+	} {
+		db, conn := fakeDB(driverName, handle)
+		b := func() *builder.Builder {
+			return builder.Select("id", "created", "name").From("t").Where(builder.Neq{"name": "x"})
+		}
+
+		// structs
+		var structs []pageRow
+		if err := SelectBuilderContext(ctx, db, &structs, b()); err != nil {
+			t.Fatalf("%s: SelectBuilderContext() = %v", driverName, err)
+		}
+		if !reflect.DeepEqual(structs, rows) {
+			t.Errorf("%s: SelectBuilderContext() = %v, want %v", driverName, structs, rows)
+		}
+
+		// maps
+		var maps []map[string]interface{}
+		if err := SelectBuilderContext(ctx, db, &maps, b()); err != nil {
+			t.Fatalf("%s: SelectBuilderContext() = %v", driverName, err)
+		}
+		if len(maps) != 2 || maps[1]["id"] != int64(2) || maps[1]["name"] != "row 2" {
+			t.Errorf("%s: SelectBuilderContext() = %v", driverName, maps)
+		}
+
+		// single row
+		var row pageRow
+		if err := GetBuilderContext(ctx, db, &row, b()); err != nil {
+			t.Fatalf("%s: GetBuilderContext() = %v", driverName, err)
+		}
+		if row != rows[0] {
+			t.Errorf("%s: GetBuilderContext() = %v, want %v", driverName, row, rows[0])
+		}
+		m := map[string]interface{}{}
+		if err := GetBuilderContext(ctx, db, m, b()); err != nil {
+			t.Fatalf("%s: GetBuilderContext() = %v", driverName, err)
+		}
+		if m["id"] != int64(1) {
+			t.Errorf("%s: GetBuilderContext() = %v", driverName, m)
+		}
+
+		// cursor
+		r, err := QueryBuilderContext(ctx, db, b())
+		if err != nil {
+			t.Fatalf("%s: QueryBuilderContext() = %v", driverName, err)
+		}
+		n := 0
+		for r.Next() {
+			n++
+		}
+		r.Close()
+		if n != 2 {
+			t.Errorf("%s: QueryBuilderContext() returned %d rows, want 2", driverName, n)
+		}
+
+		for i, query := range conn.queries {
+			if query != want {
+				t.Errorf("%s: query %d = %q, want %q", driverName, i, query, want)
+			}
+			if !reflect.DeepEqual(conn.args[i], []driver.Value{"x"}) {
+				t.Errorf("%s: args %d = %v, want [x]", driverName, i, conn.args[i])
+			}
+		}
+	}
+
+	// no row
+	db, _ := fakeDB("postgres", func(string, []driver.Value) ([]string, [][]driver.Value) {
+		return []string{"id"}, nil
+	})
+	var row pageRow
+	if err := GetBuilderContext(ctx, db, &row, builder.Select("id").From("t")); err != sql.ErrNoRows {
+		t.Errorf("GetBuilderContext() without rows = %v, want %v", err, sql.ErrNoRows)
+	}
+	if err := SelectBuilderContext(ctx, db, &[]pageRow{}, builder.Select("id")); err != builder.ErrNoTableName {
+		t.Errorf("SelectBuilderContext() without table = %v, want %v", err, builder.ErrNoTableName)
+	}
+}
+
+func TestExecBuilderContext(t *testing.T) {
+	db, conn := fakeDB("postgres", func(string, []driver.Value) ([]string, [][]driver.Value) {
+		return nil, [][]driver.Value{{}, {}, {}}
+	})
+	res, err := ExecBuilderContext(context.Background(), db,
+		builder.Update(builder.Eq{"name": "x"}).From("t").Where(builder.Gt{"id": 2}))
+	if err != nil {
+		t.Fatalf("ExecBuilderContext() = %v", err)
+	}
+	if n, _ := res.RowsAffected(); n != 3 {
+		t.Errorf("RowsAffected() = %d, want 3", n)
+	}
+	if want := "UPDATE t SET name=$1 WHERE id>$2"; conn.queries[0] != want {
+		t.Errorf("query = %q, want %q", conn.queries[0], want)
+	}
+	if want := []driver.Value{"x", int64(2)}; !reflect.DeepEqual(conn.args[0], want) {
+		t.Errorf("args = %v, want %v", conn.args[0], want)
+	}
+
+	if _, err := ExecBuilderContext(context.Background(), db, builder.Update().From("t")); err != builder.ErrNoColumnToUpdate {
+		t.Errorf("ExecBuilderContext() without updates = %v, want %v", err, builder.ErrNoColumnToUpdate)
+	}
+}
+
+// pageHandler serves rows ordered and filtered by the keyset (created, id) like
+// a database would for the statements of SelectPageContext.
+func pageHandler(t *testing.T, rows []pageRow, desc bool) func(string, []driver.Value) ([]string, [][]driver.Value) {
+	return func(query string, args []driver.Value) ([]string, [][]driver.Value) {
+		sorted := append([]pageRow(nil), rows...)
+		less := func(a, b pageRow) bool {
+			if !a.Created.Equal(b.Created) {
+				return a.Created.Before(b.Created)
+			}
+			return a.ID < b.ID
+		}
+		sort.Slice(sorted, func(i, j int) bool {
+			if desc {
+				return less(sorted[j], sorted[i])
+			}
+			return less(sorted[i], sorted[j])
+		})
+
+		// created>$1 OR (created=$2 AND id>$3)
+		if len(args) > 0 {
+			after := pageRow{Created: args[0].(time.Time), ID: args[2].(int64)}
+			var filtered []pageRow
+			for _, row := range sorted {
+				if (!desc && less(after, row)) || (desc && less(row, after)) {
+					filtered = append(filtered, row)
+				}
+			}
+			sorted = filtered
+		}
+
+		limit, err := strconv.Atoi(query[strings.LastIndex(query, " ")+1:])
+		if err != nil {
+			t.Fatalf("no limit in %q", query)
+		}
+		if len(sorted) > limit {
+			sorted = sorted[:limit]
+		}
+
+		var values [][]driver.Value
+		for _, row := range sorted {
+			values = append(values, []driver.Value{row.ID, row.Created, row.Name})
+		}
+		return []string{"id", "created", "name"}, values
+	}
+}
+
+func TestSelectPageContext(t *testing.T) {
+	ctx := context.Background()
+	rows := testRows()
+
+	for _, desc := range []bool{false, true} {
+		db, conn := fakeDB("postgres", pageHandler(t, rows, desc))
+		k := Keyset{Columns: []string{"created", "t.id"}, Desc: desc, Limit: 3}
+
+		var ids []int64
+		cursor := ""
+		for page := 0; page < 10; page++ {
+			var dest []pageRow
+			next, err := SelectPageContext(ctx, db, &dest, builder.Postgres().Select("id", "created", "name").From("t"), k, cursor)
+			if err != nil {
+				t.Fatalf("desc %t: SelectPageContext() = %v", desc, err)
+			}
+			if len(dest) > 3 {
+				t.Errorf("desc %t: page of %d rows", desc, len(dest))
+			}
+			for _, row := range dest {
+				ids = append(ids, row.ID)
+			}
+			if next == "" {
+				break
+			}
+			cursor = next
+		}
+
+		want := []int64{1, 2, 3, 4, 5, 6, 7, 8}
+		if desc {
+			want = []int64{8, 7, 6, 5, 4, 3, 2, 1}
+		}
+		if !reflect.DeepEqual(ids, want) {
+			t.Errorf("desc %t: pages = %v, want %v", desc, ids, want)
+		}
+
+		order, cmp := "ASC", ">"
+		if desc {
+			order, cmp = "DESC", "<"
+		}
+		queries := []string{
+			"SELECT id,created,name FROM t ORDER BY created " + order + ",t.id " + order + " LIMIT 4",
+			"SELECT id,created,name FROM t WHERE created" + cmp + "$1 OR (created=$2 AND t.id" + cmp + "$3) ORDER BY created " + order + ",t.id " + order + " LIMIT 4",
+		}
+		if len(conn.queries) != 3 {
+			t.Fatalf("desc %t: %d queries, want 3", desc, len(conn.queries))
+		}
+		for i, query := range conn.queries {
+			want := queries[0]
+			if i > 0 {
+				want = queries[1]
+			}
+			if query != want {
+				t.Errorf("desc %t: query %d = %q, want %q", desc, i, query, want)
+			}
+		}
+	}
+
+	// the keyset follows the conditions of the builder
+	db, conn := fakeDB("postgres", func(string, []driver.Value) ([]string, [][]driver.Value) {
+		return []string{"id", "created", "name"}, nil
+	})
+	cursor, err := encodeCursor([]interface{}{rows[0].Created, rows[0].ID})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var page []pageRow
+	if _, err := SelectPageContext(ctx, db, &page, builder.Postgres().Select("id", "created", "name").From("t").
+		Where(builder.Neq{"name": "x"}), Keyset{Columns: []string{"created", "id"}, Limit: 2}, cursor); err != nil {
+		t.Fatalf("SelectPageContext() = %v", err)
+	}
+	if want := "SELECT id,created,name FROM t WHERE name<>$1 AND (created>$2 OR (created=$3 AND id>$4)) ORDER BY created ASC,id ASC LIMIT 3"; conn.queries[0] != want {
+		t.Errorf("query = %q, want %q", conn.queries[0], want)
+	}
+	if want := []driver.Value{"x", rows[0].Created, rows[0].Created, rows[0].ID}; !reflect.DeepEqual(conn.args[0], want) {
+		t.Errorf("args = %v, want %v", conn.args[0], want)
+	}
+
+	// a last page which is full has no next page
+	db, _ = fakeDB("postgres", pageHandler(t, rows[:3], false))
+	var dest []map[string]interface{}
+	next, err := SelectPageContext(ctx, db, &dest, builder.Postgres().Select("id", "created", "name").From("t"),
+		Keyset{Columns: []string{"created", "id"}, Limit: 3}, "")
+	if err != nil || next != "" || len(dest) != 3 {
+		t.Errorf("SelectPageContext() = %q, %v with %d rows, want the last page", next, err, len(dest))
+	}
+}
+
+func TestSelectPageContextErrors(t *testing.T) {
+	ctx := context.Background()
+	db, _ := fakeDB("postgres", pageHandler(t, testRows(), false))
+	b := func() *builder.Builder {
+		return builder.Postgres().Select("id", "created", "name").From("t")
+	}
+	k := Keyset{Columns: []string{"created", "id"}, Limit: 3}
+
+	var dest []pageRow
+	for _, k := range []Keyset{{Limit: 3}, {Columns: []string{"id"}}} {
+		if _, err := SelectPageContext(ctx, db, &dest, b(), k, ""); err == nil {
+			t.Errorf("SelectPageContext(%v) succeeded", k)
+		}
+	}
+
+	valid, err := encodeCursor([]interface{}{int64(1)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, cursor := range []string{"%%", "bm90IGpzb24", valid} {
+		if _, err := SelectPageContext(ctx, db, &dest, b(), k, cursor); err == nil || err.Error() != "invalid keyset cursor" {
+			t.Errorf("SelectPageContext() with cursor %q = %v", cursor, err)
+		}
+	}
+
+	// the keyset columns have to be selected
+	if _, err := SelectPageContext(ctx, db, &[]struct {
+		ID int64 `db:"id"`
+	}{}, builder.Postgres().Select("id").From("t"), k, ""); err == nil {
+		t.Error("SelectPageContext() without keyset column succeeded")
+	}
+}
+
+func TestKeysetCursor(t *testing.T) {
+	vals := []interface{}{
+		int64(-42), 1.5, true, "a\"b", []byte{0, 1, 2},
+		time.Date(2021, 2, 3, 4, 5, 6, 7, time.FixedZone("", 3600)),
+	}
+	cursor, err := encodeCursor(vals)
+	if err != nil {
+		t.Fatalf("encodeCursor() = %v", err)
+	}
+	got, err := decodeCursor(cursor, len(vals))
+	if err != nil {
+		t.Fatalf("decodeCursor() = %v", err)
+	}
+	if !got[5].(time.Time).Equal(vals[5].(time.Time)) {
+		t.Errorf("decodeCursor() time = %v, want %v", got[5], vals[5])
+	}
+	if !reflect.DeepEqual(got[:5], vals[:5]) {
+		t.Errorf("decodeCursor() = %#v, want %#v", got, vals)
+	}
+
+	if _, err := decodeCursor(cursor, len(vals)-1); err == nil {
+		t.Error("decodeCursor() with too few columns succeeded")
+	}
+	if _, err := encodeCursor([]interface{}{struct{}{}}); err == nil {
+		t.Error("encodeCursor() of a struct succeeded")
+	}
+}
@@ -12,12 +12,17 @@ import (
 type optype byte
 
 const (
-	condType   optype = iota // only conditions
-	selectType               // select
-	insertType               // insert
-	updateType               // update
-	deleteType               // delete
-	setOpType                // set operation
+	condType        optype = iota // only conditions
+	selectType                    // select
+	insertType                    // insert
+	updateType                    // update
+	deleteType                    // delete
+	setOpType                     // set operation
+	createTableType               // create table
+	addColumnType                 // alter table add column
+	createIndexType               // create index
+	dropTableType                 // drop table
+	dropIndexType                 // drop index
 )
 
 // all databasees
@@ -53,22 +58,28 @@ type limit struct {
 // Builder describes a SQL statement
 type Builder struct {
 	optype
-	dialect    string
-	isNested   bool
-	into       string
-	from       string
-	subQuery   *Builder
-	cond       Cond
-	selects    []string
-	joins      joins
-	setOps     []setOp
-	limitation *limit
-	insertCols []string
-	insertVals []interface{}
-	updates    []UpdateCond
-	orderBy    interface{}
-	groupBy    string
-	having     interface{}
+	dialect     string
+	isNested    bool
+	into        string
+	from        string
+	subQuery    *Builder
+	cond        Cond
+	selects     []string
+	joins       joins
+	setOps      []setOp
+	limitation  *limit
+	insertCols  []string
+	insertVals  []interface{}
+	updates     []UpdateCond
+	orderBy     interface{}
+	groupBy     string
+	having      interface{}
+	ctes        []cte
+	upsert      *upsert
+	returning   []string
+	columns     []Column
+	index       *index
+	quotePolicy QuotePolicy
 }
 
 // Dialect sets the db dialect of Builder.
@@ -180,6 +191,7 @@ func (b *Builder) setOperation(opType, distinctType string, cond *Builder) *Buil
 		builder.optype = setOpType
 		builder.dialect = b.dialect
 		builder.selects = b.selects
+		builder.quotePolicy = b.quotePolicy
 
 		currentSetOps := b.setOps
 		// erase sub setOps (actually append to new Builder.unions)
@@ -189,6 +201,10 @@ func (b *Builder) setOperation(opType, distinctType string, cond *Builder) *Buil
 			currentSetOps[e].builder.dialect = b.dialect
 		}
 
+		// common table expressions belong to the whole set operation
+		builder.ctes = b.ctes
+		b.ctes = nil
+
 		builder.setOps = append(append(builder.setOps, setOp{opType, "", b}), currentSetOps...)
 	} else {
 		builder = b
@@ -258,6 +274,34 @@ func (b *Builder) Delete(conds ...Cond) *Builder {
 
 // WriteTo implements Writer interface
 func (b *Builder) WriteTo(w Writer) error {
+	// nested builders and conditions quote identifiers by the policy of the outermost builder
+	if _, ok := w.(*quoteWriter); !ok && b.quotePolicy != QuotePolicyNone {
+		if b.dialect == "" {
+			return ErrDialectNotSetUp
+		}
+		w = &quoteWriter{w, NewQuoter(b.dialect, b.quotePolicy)}
+	}
+
+	if len(b.ctes) > 0 {
+		if !b.withSupported() {
+			// MySQL and Oracle accept WITH only in front of the select of an INSERT ... SELECT
+			if b.optype != insertType || b.from == "" {
+				return ErrCTENotSupported
+			}
+		} else {
+			if err := b.withWriteTo(w); err != nil {
+				return err
+			}
+
+			// unset common table expressions to prevent nested writes from repeating them
+			ctes := b.ctes
+			b.ctes = nil
+			defer func() {
+				b.ctes = ctes
+			}()
+		}
+	}
+
 	switch b.optype {
 	/*case condType:
 	return b.cond.WriteTo(w)*/
@@ -271,6 +315,8 @@ func (b *Builder) WriteTo(w Writer) error {
 		return b.deleteWriteTo(w)
 	case setOpType:
 		return b.setOpWriteTo(w)
+	case createTableType, addColumnType, createIndexType, dropTableType, dropIndexType:
+		return b.ddlWriteTo(w)
 	}
 
 	return ErrNotSupportType
@@ -0,0 +1,232 @@
+// Copyright 2016 The Xorm Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package builder
+
+import (
+	"fmt"
+	"strings"
+)
+
+type index struct {
+	name   string
+	cols   []string
+	unique bool
+}
+
+// CreateTable creates a CREATE TABLE Builder
+func CreateTable(tableName string, cols ...Column) *Builder {
+	builder := &Builder{cond: NewCond()}
+	return builder.CreateTable(tableName, cols...)
+}
+
+// AddColumn creates an ALTER TABLE ADD COLUMN Builder
+func AddColumn(tableName string, col Column) *Builder {
+	builder := &Builder{cond: NewCond()}
+	return builder.AddColumn(tableName, col)
+}
+
+// CreateIndex creates a CREATE INDEX Builder
+func CreateIndex(indexName, tableName string, cols ...string) *Builder {
+	builder := &Builder{cond: NewCond()}
+	return builder.CreateIndex(indexName, tableName, cols...)
+}
+
+// CreateUniqueIndex creates a CREATE UNIQUE INDEX Builder
+func CreateUniqueIndex(indexName, tableName string, cols ...string) *Builder {
+	builder := &Builder{cond: NewCond()}
+	return builder.CreateUniqueIndex(indexName, tableName, cols...)
+}
+
+// DropTable creates a DROP TABLE Builder
+func DropTable(tableName string) *Builder {
+	builder := &Builder{cond: NewCond()}
+	return builder.DropTable(tableName)
+}
+
+// DropIndex creates a DROP INDEX Builder
+func DropIndex(indexName, tableName string) *Builder {
+	builder := &Builder{cond: NewCond()}
+	return builder.DropIndex(indexName, tableName)
+}
+
+// CreateTable sets CREATE TABLE SQL, a single primary key column carries the constraint
+// itself while a composite primary key is written as table constraint
+func (b *Builder) CreateTable(tableName string, cols ...Column) *Builder {
+	b.from = tableName
+	b.columns = cols
+	b.optype = createTableType
+	return b
+}
+
+// AddColumn sets ALTER TABLE ADD COLUMN SQL
+func (b *Builder) AddColumn(tableName string, col Column) *Builder {
+	b.from = tableName
+	b.columns = []Column{col}
+	b.optype = addColumnType
+	return b
+}
+
+// CreateIndex sets CREATE INDEX SQL
+func (b *Builder) CreateIndex(indexName, tableName string, cols ...string) *Builder {
+	b.from = tableName
+	b.index = &index{name: indexName, cols: cols}
+	b.optype = createIndexType
+	return b
+}
+
+// CreateUniqueIndex sets CREATE UNIQUE INDEX SQL
+func (b *Builder) CreateUniqueIndex(indexName, tableName string, cols ...string) *Builder {
+	b.CreateIndex(indexName, tableName, cols...)
+	b.index.unique = true
+	return b
+}
+
+// DropTable sets DROP TABLE SQL
+func (b *Builder) DropTable(tableName string) *Builder {
+	b.from = tableName
+	b.optype = dropTableType
+	return b
+}
+
+// DropIndex sets DROP INDEX SQL, the table is needed by MySQL and MsSQL only
+func (b *Builder) DropIndex(indexName, tableName string) *Builder {
+	b.from = tableName
+	b.index = &index{name: indexName}
+	b.optype = dropIndexType
+	return b
+}
+
+func (b *Builder) ddlWriteTo(w Writer) error {
+	switch b.optype {
+	case createTableType:
+		return b.createTableWriteTo(w)
+	case addColumnType:
+		return b.addColumnWriteTo(w)
+	case createIndexType:
+		return b.createIndexWriteTo(w)
+	case dropTableType:
+		if len(b.from) <= 0 {
+			return ErrNoTableName
+		}
+		_, err := fmt.Fprint(w, "DROP TABLE ", quoteTo(w, b.from))
+		return err
+	case dropIndexType:
+		return b.dropIndexWriteTo(w)
+	}
+
+	return ErrNotSupportType
+}
+
+func (b *Builder) createTableWriteTo(w Writer) error {
+	if len(b.from) <= 0 {
+		return ErrNoTableName
+	}
+	if len(b.columns) <= 0 {
+		return ErrNoColumns
+	}
+
+	var pks []string
+	for _, col := range b.columns {
+		if col.PrimaryKey {
+			pks = append(pks, col.Name)
+		}
+	}
+
+	if _, err := fmt.Fprintf(w, "CREATE TABLE %s (", quoteTo(w, b.from)); err != nil {
+		return err
+	}
+
+	for i, col := range b.columns {
+		if i > 0 {
+			if _, err := fmt.Fprint(w, ","); err != nil {
+				return err
+			}
+		}
+		if err := b.columnWriteTo(w, col, col.PrimaryKey && len(pks) == 1); err != nil {
+			return err
+		}
+	}
+
+	if len(pks) > 1 {
+		if _, err := fmt.Fprintf(w, ",PRIMARY KEY (%s)", strings.Join(quoteAllTo(w, pks), ",")); err != nil {
+			return err
+		}
+	}
+
+	_, err := fmt.Fprint(w, ")")
+	return err
+}
+
+func (b *Builder) addColumnWriteTo(w Writer) error {
+	if len(b.from) <= 0 {
+		return ErrNoTableName
+	}
+	if len(b.columns) <= 0 {
+		return ErrNoColumns
+	}
+
+	// MsSQL and Oracle do not accept the COLUMN keyword
+	add := " ADD COLUMN "
+	if b.dialect == MSSQL || b.dialect == ORACLE {
+		add = " ADD "
+	}
+
+	if _, err := fmt.Fprint(w, "ALTER TABLE ", quoteTo(w, b.from), add); err != nil {
+		return err
+	}
+
+	col := b.columns[0]
+	return b.columnWriteTo(w, col, col.PrimaryKey)
+}
+
+func (b *Builder) createIndexWriteTo(w Writer) error {
+	if len(b.from) <= 0 {
+		return ErrNoTableName
+	}
+	if len(b.index.name) <= 0 {
+		return ErrNoIndexName
+	}
+	if len(b.index.cols) <= 0 {
+		return ErrNoColumns
+	}
+
+	if _, err := fmt.Fprint(w, "CREATE "); err != nil {
+		return err
+	}
+	if b.index.unique {
+		if _, err := fmt.Fprint(w, "UNIQUE "); err != nil {
+			return err
+		}
+	}
+
+	_, err := fmt.Fprintf(w, "INDEX %s ON %s (%s)", quoteTo(w, b.index.name), quoteTo(w, b.from),
+		strings.Join(quoteAllTo(w, b.index.cols), ","))
+	return err
+}
+
+func (b *Builder) dropIndexWriteTo(w Writer) error {
+	if len(b.index.name) <= 0 {
+		return ErrNoIndexName
+	}
+
+	if _, err := fmt.Fprint(w, "DROP INDEX ", quoteTo(w, b.index.name)); err != nil {
+		return err
+	}
+
+	switch b.dialect {
+	case POSTGRES, SQLITE, ORACLE:
+		return nil
+	case MYSQL, MSSQL:
+		if len(b.from) <= 0 {
+			return ErrNoTableName
+		}
+		_, err := fmt.Fprint(w, " ON ", quoteTo(w, b.from))
+		return err
+	case "":
+		return ErrDialectNotSetUp
+	}
+
+	return ErrNotSupportDialectType
+}
@@ -0,0 +1,152 @@
+// Copyright 2016 The Xorm Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package builder
+
+import "testing"
+
+func TestCreateTable(t *testing.T) {
+	dialectTest(t, "table", func(dialect string) *Builder {
+		return Dialect(dialect).QuotePolicy(QuotePolicyReserved).CreateTable("user",
+			Column{Name: "id", Type: TypeBigInt, PrimaryKey: true, AutoIncrement: true},
+			Column{Name: "order", Type: TypeVarchar, NotNull: true, Default: "''"},
+			Column{Name: "price", Type: TypeDecimal, Length: 10, Scale: 2},
+		)
+	}, []string{
+		`CREATE TABLE "user" (id BIGSERIAL PRIMARY KEY,"order" VARCHAR(255) DEFAULT '' NOT NULL,price DECIMAL(10,2))`,
+		`CREATE TABLE "user" (id INTEGER PRIMARY KEY AUTOINCREMENT,"order" VARCHAR(255) DEFAULT '' NOT NULL,price NUMERIC(10,2))`,
+		"CREATE TABLE `user` (id BIGINT AUTO_INCREMENT PRIMARY KEY,`order` VARCHAR(255) DEFAULT '' NOT NULL,price DECIMAL(10,2))",
+		`CREATE TABLE [user] (id BIGINT IDENTITY(1,1) PRIMARY KEY,[order] NVARCHAR(255) DEFAULT '' NOT NULL,price DECIMAL(10,2))`,
+		`CREATE TABLE "user" (id NUMBER(19) GENERATED BY DEFAULT AS IDENTITY PRIMARY KEY,"order" VARCHAR2(255 CHAR) DEFAULT '' NOT NULL,price NUMBER(10,2))`,
+	})
+
+	dialectTest(t, "composite key", func(dialect string) *Builder {
+		return Dialect(dialect).CreateTable("m",
+			Column{Name: "a", Type: TypeInt, PrimaryKey: true},
+			Column{Name: "b", Type: TypeSmallInt, PrimaryKey: true},
+		)
+	}, []string{
+		"CREATE TABLE m (a INTEGER,b SMALLINT,PRIMARY KEY (a,b))",
+		"CREATE TABLE m (a INTEGER,b INTEGER,PRIMARY KEY (a,b))",
+		"CREATE TABLE m (a INT,b SMALLINT,PRIMARY KEY (a,b))",
+		"CREATE TABLE m (a INT,b SMALLINT,PRIMARY KEY (a,b))",
+		"CREATE TABLE m (a NUMBER(10),b NUMBER(5),PRIMARY KEY (a,b))",
+	})
+}
+
+func TestColumnTypes(t *testing.T) {
+	for _, tc := range []struct {
+		col  Column
+		want []string
+	}{
+		{Column{Type: TypeBool}, []string{"BOOLEAN", "INTEGER", "TINYINT(1)", "BIT", "NUMBER(1)"}},
+		{Column{Type: TypeSmallInt}, []string{"SMALLINT", "INTEGER", "SMALLINT", "SMALLINT", "NUMBER(5)"}},
+		{Column{Type: TypeSmallInt, AutoIncrement: true}, []string{"SMALLSERIAL", "INTEGER", "SMALLINT AUTO_INCREMENT", "SMALLINT IDENTITY(1,1)", "NUMBER(5) GENERATED BY DEFAULT AS IDENTITY"}},
+		{Column{Type: TypeInt}, []string{"INTEGER", "INTEGER", "INT", "INT", "NUMBER(10)"}},
+		{Column{Type: TypeInt, AutoIncrement: true}, []string{"SERIAL", "INTEGER", "INT AUTO_INCREMENT", "INT IDENTITY(1,1)", "NUMBER(10) GENERATED BY DEFAULT AS IDENTITY"}},
+		{Column{Type: TypeBigInt}, []string{"BIGINT", "INTEGER", "BIGINT", "BIGINT", "NUMBER(19)"}},
+		{Column{Type: TypeFloat}, []string{"DOUBLE PRECISION", "REAL", "DOUBLE", "FLOAT", "BINARY_DOUBLE"}},
+		{Column{Type: TypeDecimal, Length: 8}, []string{"DECIMAL(8,0)", "NUMERIC(8,0)", "DECIMAL(8,0)", "DECIMAL(8,0)", "NUMBER(8,0)"}},
+		{Column{Type: TypeVarchar, Length: 20}, []string{"VARCHAR(20)", "VARCHAR(20)", "VARCHAR(20)", "NVARCHAR(20)", "VARCHAR2(20 CHAR)"}},
+		{Column{Type: TypeText}, []string{"TEXT", "TEXT", "LONGTEXT", "NVARCHAR(MAX)", "CLOB"}},
+		{Column{Type: TypeBlob}, []string{"BYTEA", "BLOB", "LONGBLOB", "VARBINARY(MAX)", "BLOB"}},
+		{Column{Type: TypeDate}, []string{"DATE", "DATE", "DATE", "DATE", "DATE"}},
+		{Column{Type: TypeDateTime, NotNull: true, Default: "CURRENT_TIMESTAMP"}, []string{
+			"TIMESTAMP DEFAULT CURRENT_TIMESTAMP NOT NULL",
+			"DATETIME DEFAULT CURRENT_TIMESTAMP NOT NULL",
+			"DATETIME DEFAULT CURRENT_TIMESTAMP NOT NULL",
+			"DATETIME2 DEFAULT CURRENT_TIMESTAMP NOT NULL",
+			"TIMESTAMP DEFAULT CURRENT_TIMESTAMP NOT NULL",
+		}},
+	} {
+		tc.col.Name = "c"
+		want := make([]string, len(tc.want))
+		for i, w := range tc.want {
+			want[i] = "CREATE TABLE t (c " + w + ")"
+		}
+		dialectTest(t, tc.want[0], func(dialect string) *Builder {
+			return Dialect(dialect).CreateTable("t", tc.col)
+		}, want)
+	}
+}
+
+func TestAlterTable(t *testing.T) {
+	dialectTest(t, "add column", func(dialect string) *Builder {
+		return Dialect(dialect).QuotePolicy(QuotePolicyReserved).AddColumn("user", Column{Name: "at", Type: TypeDateTime})
+	}, []string{
+		`ALTER TABLE "user" ADD COLUMN at TIMESTAMP`,
+		`ALTER TABLE "user" ADD COLUMN at DATETIME`,
+		"ALTER TABLE `user` ADD COLUMN at DATETIME",
+		`ALTER TABLE [user] ADD at DATETIME2`,
+		`ALTER TABLE "user" ADD at TIMESTAMP`,
+	})
+}
+
+func TestIndexes(t *testing.T) {
+	dialectTest(t, "create", func(dialect string) *Builder {
+		return Dialect(dialect).CreateIndex("t_n", "t", "n")
+	}, []string{
+		"CREATE INDEX t_n ON t (n)",
+		"CREATE INDEX t_n ON t (n)",
+		"CREATE INDEX t_n ON t (n)",
+		"CREATE INDEX t_n ON t (n)",
+		"CREATE INDEX t_n ON t (n)",
+	})
+
+	dialectTest(t, "create unique", func(dialect string) *Builder {
+		return Dialect(dialect).QuotePolicy(QuotePolicyReserved).CreateUniqueIndex("t_key", "t", "key", "id")
+	}, []string{
+		`CREATE UNIQUE INDEX t_key ON t ("key",id)`,
+		`CREATE UNIQUE INDEX t_key ON t ("key",id)`,
+		"CREATE UNIQUE INDEX t_key ON t (`key`,id)",
+		`CREATE UNIQUE INDEX t_key ON t ([key],id)`,
+		`CREATE UNIQUE INDEX t_key ON t ("key",id)`,
+	})
+
+	dialectTest(t, "drop", func(dialect string) *Builder {
+		return Dialect(dialect).DropIndex("t_n", "t")
+	}, []string{
+		"DROP INDEX t_n",
+		"DROP INDEX t_n",
+		"DROP INDEX t_n ON t",
+		"DROP INDEX t_n ON t",
+		"DROP INDEX t_n",
+	})
+
+	dialectTest(t, "drop table", func(dialect string) *Builder {
+		return Dialect(dialect).QuotePolicy(QuotePolicyReserved).DropTable("user")
+	}, []string{
+		`DROP TABLE "user"`,
+		`DROP TABLE "user"`,
+		"DROP TABLE `user`",
+		`DROP TABLE [user]`,
+		`DROP TABLE "user"`,
+	})
+}
+
+func TestDDLErrors(t *testing.T) {
+	for _, tc := range []struct {
+		name string
+		b    *Builder
+		err  error
+	}{
+		{"no table", Postgres().CreateTable("", Column{Name: "a", Type: TypeInt}), ErrNoTableName},
+		{"no columns", Postgres().CreateTable("t"), ErrNoColumns},
+		{"no column name", Postgres().CreateTable("t", Column{Type: TypeInt}), ErrInvalidColumn},
+		{"no column type", Postgres().CreateTable("t", Column{Name: "a"}), ErrInvalidColumn},
+		{"decimal without precision", Postgres().CreateTable("t", Column{Name: "a", Type: TypeDecimal}), ErrInvalidColumn},
+		{"auto increment text", Postgres().CreateTable("t", Column{Name: "a", Type: TypeText, AutoIncrement: true}), ErrInvalidColumn},
+		{"no dialect", CreateTable("t", Column{Name: "a", Type: TypeInt}), ErrDialectNotSetUp},
+		{"unknown dialect", Dialect("db2").CreateTable("t", Column{Name: "a", Type: TypeInt}), ErrNotSupportDialectType},
+		{"add column without table", Postgres().AddColumn("", Column{Name: "a", Type: TypeInt}), ErrNoTableName},
+		{"no index name", Postgres().CreateIndex("", "t", "a"), ErrNoIndexName},
+		{"no index columns", Postgres().CreateIndex("i", "t"), ErrNoColumns},
+		{"drop index without name", Postgres().DropIndex("", "t"), ErrNoIndexName},
+		{"drop index without table", MySQL().DropIndex("i", ""), ErrNoTableName},
+		{"drop index without dialect", DropIndex("i", "t"), ErrDialectNotSetUp},
+		{"drop table without name", Postgres().DropTable(""), ErrNoTableName},
+	} {
+		sqlTest(t, tc.name, tc.b, "error: "+tc.err.Error())
+	}
+}
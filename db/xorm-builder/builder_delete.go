@@ -19,9 +19,25 @@ func (b *Builder) deleteWriteTo(w Writer) error {
 		return ErrNoTableName
 	}
 
-	if _, err := fmt.Fprintf(w, "DELETE FROM %s WHERE ", b.from); err != nil {
+	if err := b.checkReturning(); err != nil {
 		return err
 	}
 
-	return b.cond.WriteTo(w)
+	if _, err := fmt.Fprintf(w, "DELETE FROM %s", quoteTo(w, b.from)); err != nil {
+		return err
+	}
+
+	if err := b.outputWriteTo(w, "DELETED"); err != nil {
+		return err
+	}
+
+	if _, err := fmt.Fprint(w, " WHERE "); err != nil {
+		return err
+	}
+
+	if err := b.cond.WriteTo(w); err != nil {
+		return err
+	}
+
+	return b.returningWriteTo(w)
 }
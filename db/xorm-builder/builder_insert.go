@@ -8,6 +8,7 @@ import (
 	"bytes"
 	"fmt"
 	"sort"
+	"strings"
 )
 
 // Insert creates an insert Builder
@@ -17,19 +18,44 @@ func Insert(eq ...interface{}) *Builder {
 }
 
 func (b *Builder) insertSelectWriteTo(w Writer) error {
-	if _, err := fmt.Fprintf(w, "INSERT INTO %s ", b.into); err != nil {
+	if b.upsert != nil && b.dialect != POSTGRES && b.dialect != MYSQL {
+		if b.dialect == "" {
+			return ErrDialectNotSetUp
+		}
+		return ErrUpsertNotSupported
+	}
+
+	if _, err := fmt.Fprintf(w, "INSERT INTO %s", quoteTo(w, b.into)); err != nil {
 		return err
 	}
 
 	if len(b.insertCols) > 0 {
-		fmt.Fprintf(w, "(")
-		for _, col := range b.insertCols {
-			fmt.Fprintf(w, col)
+		fmt.Fprintf(w, " (%s)", strings.Join(quoteAllTo(w, b.insertCols), ","))
+	}
+
+	if err := b.outputWriteTo(w, "INSERTED"); err != nil {
+		return err
+	}
+	fmt.Fprint(w, " ")
+
+	// common table expressions which could not be written in front of the insert
+	if len(b.ctes) > 0 {
+		if err := b.withWriteTo(w); err != nil {
+			return err
+		}
+	}
+
+	if err := b.selectWriteTo(w); err != nil {
+		return err
+	}
+
+	if b.upsert != nil {
+		if err := b.upsertWriteTo(w); err != nil {
+			return err
 		}
-		fmt.Fprintf(w, ") ")
 	}
 
-	return b.selectWriteTo(w)
+	return b.returningWriteTo(w)
 }
 
 func (b *Builder) insertWriteTo(w Writer) error {
@@ -39,12 +65,19 @@ func (b *Builder) insertWriteTo(w Writer) error {
 	if len(b.insertCols) <= 0 && b.from == "" {
 		return ErrNoColumnToInsert
 	}
+	if err := b.checkReturning(); err != nil {
+		return err
+	}
 
 	if b.into != "" && b.from != "" {
 		return b.insertSelectWriteTo(w)
 	}
 
-	if _, err := fmt.Fprintf(w, "INSERT INTO %s (", b.into); err != nil {
+	if b.upsert != nil && (b.dialect == MSSQL || b.dialect == ORACLE) {
+		return b.mergeWriteTo(w)
+	}
+
+	if _, err := fmt.Fprintf(w, "INSERT INTO %s (", quoteTo(w, b.into)); err != nil {
 		return err
 	}
 
@@ -54,7 +87,7 @@ func (b *Builder) insertWriteTo(w Writer) error {
 
 	for i, col := range b.insertCols {
 		value := b.insertVals[i]
-		fmt.Fprint(w, col)
+		fmt.Fprint(w, quoteTo(w, col))
 		if e, ok := value.(*Expression); ok {
 			fmt.Fprintf(valBuffer, "(%s)", e.sql)
 			args = append(args, e.args...)
@@ -75,7 +108,15 @@ func (b *Builder) insertWriteTo(w Writer) error {
 		}
 	}
 
-	if _, err := fmt.Fprint(w, ") Values ("); err != nil {
+	if _, err := fmt.Fprint(w, ")"); err != nil {
+		return err
+	}
+
+	if err := b.outputWriteTo(w, "INSERTED"); err != nil {
+		return err
+	}
+
+	if _, err := fmt.Fprint(w, " Values ("); err != nil {
 		return err
 	}
 
@@ -88,7 +129,13 @@ func (b *Builder) insertWriteTo(w Writer) error {
 
 	w.Append(args...)
 
-	return nil
+	if b.upsert != nil {
+		if err := b.upsertWriteTo(w); err != nil {
+			return err
+		}
+	}
+
+	return b.returningWriteTo(w)
 }
 
 type insertColsSorter struct {
@@ -53,7 +53,7 @@ func (joins joins) WriteTo(w Writer) error {
 		var alias string
 		if aliased, ok := v.joinTable.(*Aliased); ok {
 			joinTable = aliased.table
-			alias = aliased.alias + " "
+			alias = quoteTo(w, aliased.alias) + " "
 		}
 
 		switch tbl := joinTable.(type) {
@@ -68,7 +68,7 @@ func (joins joins) WriteTo(w Writer) error {
 				return err
 			}
 		case string:
-			if _, err := fmt.Fprintf(w, " %s JOIN %s %s", v.joinType, tbl, alias); err != nil {
+			if _, err := fmt.Fprintf(w, " %s JOIN %s %s", v.joinType, quoteTo(w, tbl), alias); err != nil {
 				return err
 			}
 		}
@@ -75,7 +75,7 @@ func (b *Builder) limitWriteTo(w Writer) error {
 
 			var final *Builder
 			selects := b.selects
-			b.selects = append(append([]string{fmt.Sprintf("TOP %d %v", limit.limitN+limit.offset, b.selects[0])},
+			b.selects = append(append([]string{fmt.Sprintf("TOP %d %v", limit.limitN+limit.offset, quoteTo(w, b.selects[0]))},
 				b.selects[1:]...), "ROW_NUMBER() OVER (ORDER BY (SELECT 1)) AS RN")
 
 			var wb *Builder
@@ -0,0 +1,74 @@
+// Copyright 2016 The Xorm Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package builder
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Returning sets the columns of the inserted, updated or deleted rows returned by the statement.
+// It is written as RETURNING for Postgres and SQLite and as OUTPUT for MsSQL, MySQL and Oracle
+// cannot return rows from a plain statement.
+func (b *Builder) Returning(cols ...string) *Builder {
+	b.returning = cols
+	return b
+}
+
+func (b *Builder) checkReturning() error {
+	if len(b.returning) <= 0 {
+		return nil
+	}
+
+	switch b.dialect {
+	case POSTGRES, SQLITE, MSSQL:
+		return nil
+	case "":
+		return ErrDialectNotSetUp
+	}
+
+	return ErrReturningNotSupported
+}
+
+// returningWriteTo writes the RETURNING clause which ends the statement
+func (b *Builder) returningWriteTo(w Writer) error {
+	if len(b.returning) <= 0 || b.dialect == MSSQL {
+		return nil
+	}
+
+	_, err := fmt.Fprint(w, " RETURNING ", strings.Join(quoteAllTo(w, b.returning), ","))
+	return err
+}
+
+// outputWriteTo writes the OUTPUT clause of MsSQL, pseudo is the INSERTED or DELETED table
+func (b *Builder) outputWriteTo(w Writer, pseudo string) error {
+	if len(b.returning) <= 0 || b.dialect != MSSQL {
+		return nil
+	}
+
+	if _, err := fmt.Fprint(w, " OUTPUT "); err != nil {
+		return err
+	}
+
+	for i, col := range b.returning {
+		if i > 0 {
+			if _, err := fmt.Fprint(w, ","); err != nil {
+				return err
+			}
+		}
+
+		// qualify plain columns with the pseudo table
+		if !strings.Contains(col, ".") && !strings.Contains(col, "(") {
+			col = pseudo + "." + quoteTo(w, col)
+		} else {
+			col = quoteTo(w, col)
+		}
+		if _, err := fmt.Fprint(w, col); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,51 @@
+// Copyright 2016 The Xorm Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package builder
+
+import "testing"
+
+func TestReturning(t *testing.T) {
+	dialectTest(t, "insert", func(dialect string) *Builder {
+		return Dialect(dialect).Insert(Eq{"id": 1}).Into("t").Returning("id", "n")
+	}, []string{
+		"INSERT INTO t (id) Values ($1) RETURNING id,n",
+		"INSERT INTO t (id) Values (?) RETURNING id,n",
+		"error: " + ErrReturningNotSupported.Error(),
+		"INSERT INTO t (id) OUTPUT INSERTED.id,INSERTED.n Values (@p1)",
+		"error: " + ErrReturningNotSupported.Error(),
+	}, 1)
+
+	dialectTest(t, "update", func(dialect string) *Builder {
+		return Dialect(dialect).Update(Eq{"n": 2}).From("t").Where(Eq{"id": 1}).Returning("n")
+	}, []string{
+		"UPDATE t SET n=$1 WHERE id=$2 RETURNING n",
+		"UPDATE t SET n=? WHERE id=? RETURNING n",
+		"error: " + ErrReturningNotSupported.Error(),
+		"UPDATE t SET n=@p1 OUTPUT INSERTED.n WHERE id=@p2",
+		"error: " + ErrReturningNotSupported.Error(),
+	}, 2, 1)
+
+	dialectTest(t, "delete", func(dialect string) *Builder {
+		return Dialect(dialect).Delete(Eq{"id": 1}).From("t").Returning("id", "DELETED.n", "LEN(name)")
+	}, []string{
+		"DELETE FROM t WHERE id=$1 RETURNING id,DELETED.n,LEN(name)",
+		"DELETE FROM t WHERE id=? RETURNING id,DELETED.n,LEN(name)",
+		"error: " + ErrReturningNotSupported.Error(),
+		"DELETE FROM t OUTPUT DELETED.id,DELETED.n,LEN(name) WHERE id=@p1",
+		"error: " + ErrReturningNotSupported.Error(),
+	}, 1)
+
+	dialectTest(t, "upsert", func(dialect string) *Builder {
+		return Dialect(dialect).Insert(Eq{"id": 1, "n": 2}).Into("t").
+			OnConflict("id").DoUpdateColumns("n").Returning("id")
+	}, []string{
+		"INSERT INTO t (id,n) Values ($1,$2) ON CONFLICT (id) DO UPDATE SET n=excluded.n RETURNING id",
+		"INSERT INTO t (id,n) Values (?,?) ON CONFLICT (id) DO UPDATE SET n=excluded.n RETURNING id",
+		"error: " + ErrReturningNotSupported.Error(),
+		"MERGE INTO t WITH (HOLDLOCK) AS dst USING (SELECT @p1 AS id,@p2 AS n) AS src ON (dst.id=src.id) " +
+			"WHEN MATCHED THEN UPDATE SET dst.n=src.n WHEN NOT MATCHED THEN INSERT (id,n) VALUES (src.id,src.n) OUTPUT INSERTED.id;",
+		"error: " + ErrReturningNotSupported.Error(),
+	}, 1, 2)
+}
@@ -30,7 +30,7 @@ func (b *Builder) selectWriteTo(w Writer) error {
 	}
 	if len(b.selects) > 0 {
 		for i, s := range b.selects {
-			if _, err := fmt.Fprint(w, s); err != nil {
+			if _, err := fmt.Fprint(w, quoteTo(w, s)); err != nil {
 				return err
 			}
 			if i != len(b.selects)-1 {
@@ -46,7 +46,7 @@ func (b *Builder) selectWriteTo(w Writer) error {
 	}
 
 	if b.subQuery == nil {
-		if _, err := fmt.Fprint(w, " FROM ", b.from); err != nil {
+		if _, err := fmt.Fprint(w, " FROM ", quoteTo(w, b.from)); err != nil {
 			return err
 		}
 	} else {
@@ -72,7 +72,7 @@ func (b *Builder) selectWriteTo(w Writer) error {
 			if len(b.from) == 0 {
 				fmt.Fprintf(w, ")")
 			} else {
-				fmt.Fprintf(w, ") %v", b.from)
+				fmt.Fprintf(w, ") %v", quoteTo(w, b.from))
 			}
 		default:
 			return ErrUnexpectedSubQuery
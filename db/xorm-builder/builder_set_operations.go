@@ -10,6 +10,11 @@ import (
 )
 
 func (b *Builder) setOpWriteTo(w Writer) error {
+	return b.setOpMembersWriteTo(w, true)
+}
+
+// setOpMembersWriteTo writes the members of a set operation, enclosed in parentheses if nested is true
+func (b *Builder) setOpMembersWriteTo(w Writer, nested bool) error {
 	if b.limitation != nil || b.cond.IsValid() ||
 		b.orderBy != nil || b.having != nil || b.groupBy != "" {
 		return ErrNotUnexpectedUnionConditions
@@ -37,13 +42,17 @@ func (b *Builder) setOpWriteTo(w Writer) error {
 					fmt.Fprint(w, fmt.Sprintf(" %s %s ", strings.ToUpper(o.opType), strings.ToUpper(o.distinctType)))
 				}
 			}
-			fmt.Fprint(w, "(")
+			if nested {
+				fmt.Fprint(w, "(")
+			}
 
 			if err := current.selectWriteTo(w); err != nil {
 				return err
 			}
 
-			fmt.Fprint(w, ")")
+			if nested {
+				fmt.Fprint(w, ")")
+			}
 		}
 	}
 
@@ -0,0 +1,70 @@
+// Copyright 2016 The Xorm Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package builder
+
+import (
+	"database/sql"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// dialects are all dialects in the order of the expectations of the tests
+var dialects = []string{POSTGRES, SQLITE, MYSQL, MSSQL, ORACLE}
+
+// sqlTest checks the SQL and the args of the builder, an expected SQL starting with
+// "error: " is the expected error message instead
+func sqlTest(t *testing.T, name string, b *Builder, want string, args ...interface{}) {
+	t.Helper()
+	got, gotArgs, err := b.ToSQL()
+	if strings.HasPrefix(want, "error: ") {
+		if err == nil || err.Error() != strings.TrimPrefix(want, "error: ") {
+			t.Errorf("%s: ToSQL() = %q, %v, want error %q", name, got, err, strings.TrimPrefix(want, "error: "))
+		}
+		return
+	}
+	if err != nil {
+		t.Errorf("%s: ToSQL() = %v", name, err)
+		return
+	}
+	if got != want {
+		t.Errorf("%s: ToSQL() =\n%s\nwant\n%s", name, got, want)
+	}
+
+	// MsSQL and Oracle get named args
+	if b.dialect == MSSQL || b.dialect == ORACLE {
+		for i := range args {
+			args[i] = sql.Named(fmt.Sprintf("p%d", i+1), args[i])
+		}
+	}
+	if len(args) == 0 {
+		args = nil
+	}
+	if len(gotArgs) == 0 {
+		gotArgs = nil
+	}
+	if !reflect.DeepEqual(gotArgs, args) {
+		t.Errorf("%s: args %#v, want %#v", name, gotArgs, args)
+	}
+}
+
+// dialectTest builds b for each dialect and checks the results, which are given in the
+// order of dialects
+func dialectTest(t *testing.T, name string, b func(dialect string) *Builder, want []string, args ...interface{}) {
+	t.Helper()
+	if len(want) != len(dialects) {
+		t.Fatalf("%s: %d expectations for %d dialects", name, len(want), len(dialects))
+	}
+	for i, dialect := range dialects {
+		sqlTest(t, name+"/"+dialect, b(dialect), want[i], append([]interface{}(nil), args...)...)
+	}
+}
+
+func TestDialectNotSetUp(t *testing.T) {
+	sqlTest(t, "upsert", Insert(Eq{"id": 1}).Into("t").OnConflict("id"), "error: "+ErrDialectNotSetUp.Error())
+	sqlTest(t, "returning", Delete(Eq{"id": 1}).From("t").Returning("id"), "error: "+ErrDialectNotSetUp.Error())
+	sqlTest(t, "quote", Select("order").From("t").QuotePolicy(QuotePolicyAlways), "error: "+ErrDialectNotSetUp.Error())
+}
@@ -27,8 +27,11 @@ func (b *Builder) updateWriteTo(w Writer) error {
 	if len(b.updates) <= 0 {
 		return ErrNoColumnToUpdate
 	}
+	if err := b.checkReturning(); err != nil {
+		return err
+	}
 
-	if _, err := fmt.Fprintf(w, "UPDATE %s SET ", b.from); err != nil {
+	if _, err := fmt.Fprintf(w, "UPDATE %s SET ", quoteTo(w, b.from)); err != nil {
 		return err
 	}
 
@@ -45,13 +48,19 @@ func (b *Builder) updateWriteTo(w Writer) error {
 		}
 	}
 
-	if !b.cond.IsValid() {
-		return nil
+	if err := b.outputWriteTo(w, "INSERTED"); err != nil {
+		return err
 	}
 
-	if _, err := fmt.Fprint(w, " WHERE "); err != nil {
-		return err
+	if b.cond.IsValid() {
+		if _, err := fmt.Fprint(w, " WHERE "); err != nil {
+			return err
+		}
+
+		if err := b.cond.WriteTo(w); err != nil {
+			return err
+		}
 	}
 
-	return b.cond.WriteTo(w)
+	return b.returningWriteTo(w)
 }
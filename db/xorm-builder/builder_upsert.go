@@ -0,0 +1,338 @@
+// Copyright 2016 The Xorm Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package builder
+
+import (
+	"fmt"
+	"strings"
+)
+
+type upsert struct {
+	conflictCols []string
+	updates      []UpdateCond
+	updateCols   []string
+}
+
+// OnConflict turns an insert into an upsert, cols are the unique columns which identify
+// a conflicting row. Without DoUpdate or DoUpdateColumns a conflicting row is left untouched.
+//
+// The upsert is written as INSERT ... ON CONFLICT for Postgres and SQLite, as
+// INSERT ... ON DUPLICATE KEY UPDATE for MySQL (which picks the conflicting unique key
+// itself) and as MERGE for MsSQL and Oracle.
+func (b *Builder) OnConflict(cols ...string) *Builder {
+	if b.upsert == nil {
+		b.upsert = &upsert{}
+	}
+	b.upsert.conflictCols = cols
+	return b
+}
+
+// DoNothing leaves a conflicting row untouched
+func (b *Builder) DoNothing() *Builder {
+	if b.upsert == nil {
+		b.upsert = &upsert{}
+	}
+	b.upsert.updates = nil
+	b.upsert.updateCols = nil
+	return b
+}
+
+// DoUpdate sets the columns of a conflicting row to the values of updates
+func (b *Builder) DoUpdate(updates ...Cond) *Builder {
+	if b.upsert == nil {
+		b.upsert = &upsert{}
+	}
+	for _, update := range updates {
+		if u, ok := update.(UpdateCond); ok && u.IsValid() {
+			b.upsert.updates = append(b.upsert.updates, u)
+		}
+	}
+	return b
+}
+
+// DoUpdateColumns sets the columns of a conflicting row to the values proposed for insertion
+func (b *Builder) DoUpdateColumns(cols ...string) *Builder {
+	if b.upsert == nil {
+		b.upsert = &upsert{}
+	}
+	b.upsert.updateCols = append(b.upsert.updateCols, cols...)
+	return b
+}
+
+// isUpdate returns whether a conflicting row gets an assignment. Conflict columns are
+// never assigned, as they already hold the proposed values, so an upsert updating only
+// those leaves the row untouched like DoNothing.
+func (u *upsert) isUpdate() bool {
+	if len(u.updates) > 0 {
+		return true
+	}
+	for _, col := range u.updateCols {
+		if !u.isConflictCol(col) {
+			return true
+		}
+	}
+	return false
+}
+
+// excludedRef returns how the dialect references the value proposed for insertion into the
+// already quoted col
+func excludedRef(dialect, col string) string {
+	switch dialect {
+	case MYSQL:
+		return fmt.Sprintf("VALUES(%s)", col)
+	case MSSQL, ORACLE:
+		return "src." + col
+	}
+	return "excluded." + col
+}
+
+// upsertUpdatesWriteTo writes the assignments of the update of a conflicting row. Within
+// MERGE the columns are qualified with the dst alias, as they would be ambiguous between
+// the target and the src row.
+func (b *Builder) upsertUpdatesWriteTo(w Writer) error {
+	merge := b.dialect == MSSQL || b.dialect == ORACLE
+
+	first := true
+	for _, col := range b.upsert.updateCols {
+		if b.upsert.isConflictCol(col) {
+			continue
+		}
+		if !first {
+			if _, err := fmt.Fprint(w, ","); err != nil {
+				return err
+			}
+		}
+		col = quoteTo(w, col)
+		target := col
+		if merge {
+			target = "dst." + col
+		}
+		if _, err := fmt.Fprintf(w, "%s=%s", target, excludedRef(b.dialect, col)); err != nil {
+			return err
+		}
+		first = false
+	}
+
+	for _, u := range b.upsert.updates {
+		if !first {
+			if _, err := fmt.Fprint(w, ","); err != nil {
+				return err
+			}
+		}
+		if merge {
+			u = qualifyUpdate(u, "dst")
+		}
+		if err := u.OpWriteTo(",", w); err != nil {
+			return err
+		}
+		first = false
+	}
+
+	return nil
+}
+
+// qualifyUpdate prefixes the columns assigned by an Eq with alias, which also qualifies
+// the column of Incr and Decr. Expressions are written as given, they have to qualify
+// their columns themselves.
+func qualifyUpdate(u UpdateCond, alias string) UpdateCond {
+	eq, ok := u.(Eq)
+	if !ok {
+		return u
+	}
+	qualified := make(Eq, len(eq))
+	for k, v := range eq {
+		if !strings.Contains(k, ".") {
+			k = alias + "." + k
+		}
+		qualified[k] = v
+	}
+	return qualified
+}
+
+func (u *upsert) isConflictCol(col string) bool {
+	for _, c := range u.conflictCols {
+		if c == col {
+			return true
+		}
+	}
+	return false
+}
+
+// upsertWriteTo writes the conflict clause following the values of an insert
+func (b *Builder) upsertWriteTo(w Writer) error {
+	switch b.dialect {
+	case POSTGRES, SQLITE:
+		if _, err := fmt.Fprint(w, " ON CONFLICT"); err != nil {
+			return err
+		}
+		if len(b.upsert.conflictCols) > 0 {
+			if _, err := fmt.Fprintf(w, " (%s)", strings.Join(quoteAllTo(w, b.upsert.conflictCols), ",")); err != nil {
+				return err
+			}
+		}
+
+		if !b.upsert.isUpdate() {
+			_, err := fmt.Fprint(w, " DO NOTHING")
+			return err
+		}
+		if len(b.upsert.conflictCols) <= 0 {
+			return ErrNoConflictTarget
+		}
+
+		if _, err := fmt.Fprint(w, " DO UPDATE SET "); err != nil {
+			return err
+		}
+		return b.upsertUpdatesWriteTo(w)
+	case MYSQL:
+		if _, err := fmt.Fprint(w, " ON DUPLICATE KEY UPDATE "); err != nil {
+			return err
+		}
+
+		// a no-op assignment keeps the conflicting row untouched
+		if !b.upsert.isUpdate() {
+			cols := make([]string, 0, len(b.upsert.conflictCols)+len(b.insertCols))
+			cols = append(cols, b.upsert.conflictCols...)
+			cols = append(cols, b.insertCols...)
+			if len(cols) <= 0 {
+				return ErrNoConflictTarget
+			}
+			col := quoteTo(w, cols[0])
+			_, err := fmt.Fprintf(w, "%s=%s", col, col)
+			return err
+		}
+		return b.upsertUpdatesWriteTo(w)
+	case "":
+		return ErrDialectNotSetUp
+	}
+
+	return ErrUpsertNotSupported
+}
+
+// mergeWriteTo writes an upsert as MERGE statement
+func (b *Builder) mergeWriteTo(w Writer) error {
+	if len(b.upsert.conflictCols) <= 0 {
+		return ErrNoConflictTarget
+	}
+	for _, col := range b.upsert.conflictCols {
+		if !b.isInsertCol(col) {
+			return ErrConflictColumnNotInserted
+		}
+	}
+
+	// Oracle does not accept the AS keyword before table aliases
+	as := " AS "
+	if b.dialect == ORACLE {
+		as = " "
+	}
+
+	if _, err := fmt.Fprintf(w, "MERGE INTO %s", quoteTo(w, b.into)); err != nil {
+		return err
+	}
+	if b.dialect == MSSQL {
+		if _, err := fmt.Fprint(w, " WITH (HOLDLOCK)"); err != nil {
+			return err
+		}
+	}
+	if _, err := fmt.Fprint(w, as, "dst USING (SELECT "); err != nil {
+		return err
+	}
+
+	args := make([]interface{}, 0, len(b.insertVals))
+	for i, col := range b.insertCols {
+		if i > 0 {
+			if _, err := fmt.Fprint(w, ","); err != nil {
+				return err
+			}
+		}
+
+		value := b.insertVals[i]
+		if e, ok := value.(*Expression); ok {
+			fmt.Fprintf(w, "(%s)", e.sql)
+			args = append(args, e.args...)
+		} else if value == nil {
+			fmt.Fprint(w, `null`)
+		} else {
+			fmt.Fprint(w, "?")
+			args = append(args, value)
+		}
+
+		if _, err := fmt.Fprint(w, " AS ", quoteTo(w, col)); err != nil {
+			return err
+		}
+	}
+	w.Append(args...)
+
+	if b.dialect == ORACLE {
+		if _, err := fmt.Fprint(w, " FROM DUAL"); err != nil {
+			return err
+		}
+	}
+
+	if _, err := fmt.Fprint(w, ")", as, "src ON ("); err != nil {
+		return err
+	}
+	for i, col := range b.upsert.conflictCols {
+		if i > 0 {
+			if _, err := fmt.Fprint(w, " AND "); err != nil {
+				return err
+			}
+		}
+		col = quoteTo(w, col)
+		if _, err := fmt.Fprintf(w, "dst.%s=src.%s", col, col); err != nil {
+			return err
+		}
+	}
+	if _, err := fmt.Fprint(w, ")"); err != nil {
+		return err
+	}
+
+	if b.upsert.isUpdate() {
+		if _, err := fmt.Fprint(w, " WHEN MATCHED THEN UPDATE SET "); err != nil {
+			return err
+		}
+		if err := b.upsertUpdatesWriteTo(w); err != nil {
+			return err
+		}
+	}
+
+	if _, err := fmt.Fprintf(w, " WHEN NOT MATCHED THEN INSERT (%s) VALUES (", strings.Join(quoteAllTo(w, b.insertCols), ",")); err != nil {
+		return err
+	}
+	for i, col := range b.insertCols {
+		if i > 0 {
+			if _, err := fmt.Fprint(w, ","); err != nil {
+				return err
+			}
+		}
+		if _, err := fmt.Fprint(w, "src.", quoteTo(w, col)); err != nil {
+			return err
+		}
+	}
+	if _, err := fmt.Fprint(w, ")"); err != nil {
+		return err
+	}
+
+	if err := b.outputWriteTo(w, "INSERTED"); err != nil {
+		return err
+	}
+
+	// MsSQL requires MERGE to be terminated
+	if b.dialect == MSSQL {
+		if _, err := fmt.Fprint(w, ";"); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (b *Builder) isInsertCol(col string) bool {
+	for _, c := range b.insertCols {
+		if c == col {
+			return true
+		}
+	}
+	return false
+}
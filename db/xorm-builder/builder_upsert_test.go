@@ -0,0 +1,113 @@
+// Copyright 2016 The Xorm Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package builder
+
+import "testing"
+
+func TestUpsertUpdateColumns(t *testing.T) {
+	dialectTest(t, "columns", func(dialect string) *Builder {
+		return Dialect(dialect).Insert(Eq{"id": 1, "name": "a", "n": 2}).Into("t").
+			OnConflict("id").DoUpdateColumns("name", "n")
+	}, []string{
+		"INSERT INTO t (id,n,name) Values ($1,$2,$3) ON CONFLICT (id) DO UPDATE SET name=excluded.name,n=excluded.n",
+		"INSERT INTO t (id,n,name) Values (?,?,?) ON CONFLICT (id) DO UPDATE SET name=excluded.name,n=excluded.n",
+		"INSERT INTO t (id,n,name) Values (?,?,?) ON DUPLICATE KEY UPDATE name=VALUES(name),n=VALUES(n)",
+		"MERGE INTO t WITH (HOLDLOCK) AS dst USING (SELECT @p1 AS id,@p2 AS n,@p3 AS name) AS src ON (dst.id=src.id) " +
+			"WHEN MATCHED THEN UPDATE SET dst.name=src.name,dst.n=src.n WHEN NOT MATCHED THEN INSERT (id,n,name) VALUES (src.id,src.n,src.name);",
+		"MERGE INTO t dst USING (SELECT :p1 AS id,:p2 AS n,:p3 AS name FROM DUAL) src ON (dst.id=src.id) " +
+			"WHEN MATCHED THEN UPDATE SET dst.name=src.name,dst.n=src.n WHEN NOT MATCHED THEN INSERT (id,n,name) VALUES (src.id,src.n,src.name)",
+	}, 1, 2, "a")
+
+	// conflict columns are skipped
+	dialectTest(t, "conflict column", func(dialect string) *Builder {
+		return Dialect(dialect).Insert(Eq{"id": 1, "n": 2}).Into("t").
+			OnConflict("id").DoUpdateColumns("id", "n")
+	}, []string{
+		"INSERT INTO t (id,n) Values ($1,$2) ON CONFLICT (id) DO UPDATE SET n=excluded.n",
+		"INSERT INTO t (id,n) Values (?,?) ON CONFLICT (id) DO UPDATE SET n=excluded.n",
+		"INSERT INTO t (id,n) Values (?,?) ON DUPLICATE KEY UPDATE n=VALUES(n)",
+		"MERGE INTO t WITH (HOLDLOCK) AS dst USING (SELECT @p1 AS id,@p2 AS n) AS src ON (dst.id=src.id) " +
+			"WHEN MATCHED THEN UPDATE SET dst.n=src.n WHEN NOT MATCHED THEN INSERT (id,n) VALUES (src.id,src.n);",
+		"MERGE INTO t dst USING (SELECT :p1 AS id,:p2 AS n FROM DUAL) src ON (dst.id=src.id) " +
+			"WHEN MATCHED THEN UPDATE SET dst.n=src.n WHEN NOT MATCHED THEN INSERT (id,n) VALUES (src.id,src.n)",
+	}, 1, 2)
+}
+
+func TestUpsertDoNothing(t *testing.T) {
+	doNothing := []string{
+		"INSERT INTO t (id,n) Values ($1,$2) ON CONFLICT (id) DO NOTHING",
+		"INSERT INTO t (id,n) Values (?,?) ON CONFLICT (id) DO NOTHING",
+		"INSERT INTO t (id,n) Values (?,?) ON DUPLICATE KEY UPDATE id=id",
+		"MERGE INTO t WITH (HOLDLOCK) AS dst USING (SELECT @p1 AS id,@p2 AS n) AS src ON (dst.id=src.id) " +
+			"WHEN NOT MATCHED THEN INSERT (id,n) VALUES (src.id,src.n);",
+		"MERGE INTO t dst USING (SELECT :p1 AS id,:p2 AS n FROM DUAL) src ON (dst.id=src.id) " +
+			"WHEN NOT MATCHED THEN INSERT (id,n) VALUES (src.id,src.n)",
+	}
+
+	dialectTest(t, "on conflict", func(dialect string) *Builder {
+		return Dialect(dialect).Insert(Eq{"id": 1, "n": 2}).Into("t").OnConflict("id")
+	}, doNothing, 1, 2)
+
+	dialectTest(t, "do nothing", func(dialect string) *Builder {
+		return Dialect(dialect).Insert(Eq{"id": 1, "n": 2}).Into("t").
+			OnConflict("id").DoUpdateColumns("n").DoNothing()
+	}, doNothing, 1, 2)
+
+	// updating only conflict columns leaves the row untouched
+	dialectTest(t, "conflict columns only", func(dialect string) *Builder {
+		return Dialect(dialect).Insert(Eq{"id": 1, "n": 2}).Into("t").
+			OnConflict("id").DoUpdateColumns("id")
+	}, doNothing, 1, 2)
+}
+
+func TestUpsertDoUpdate(t *testing.T) {
+	dialectTest(t, "increment", func(dialect string) *Builder {
+		return Dialect(dialect).Insert(Eq{"id": 1, "n": 2}).Into("t").
+			OnConflict("id").DoUpdate(Eq{"n": Incr(1)})
+	}, []string{
+		"INSERT INTO t (id,n) Values ($1,$2) ON CONFLICT (id) DO UPDATE SET n=n+$3",
+		"INSERT INTO t (id,n) Values (?,?) ON CONFLICT (id) DO UPDATE SET n=n+?",
+		"INSERT INTO t (id,n) Values (?,?) ON DUPLICATE KEY UPDATE n=n+?",
+		"MERGE INTO t WITH (HOLDLOCK) AS dst USING (SELECT @p1 AS id,@p2 AS n) AS src ON (dst.id=src.id) " +
+			"WHEN MATCHED THEN UPDATE SET dst.n=dst.n+@p3 WHEN NOT MATCHED THEN INSERT (id,n) VALUES (src.id,src.n);",
+		"MERGE INTO t dst USING (SELECT :p1 AS id,:p2 AS n FROM DUAL) src ON (dst.id=src.id) " +
+			"WHEN MATCHED THEN UPDATE SET dst.n=dst.n+:p3 WHEN NOT MATCHED THEN INSERT (id,n) VALUES (src.id,src.n)",
+	}, 1, 2, 1)
+
+	// columns assigned before updates
+	sqlTest(t, "columns and updates", Postgres().Insert(Eq{"id": 1, "n": 2, "name": "a"}).Into("t").
+		OnConflict("id").DoUpdate(Eq{"n": Incr(1)}).DoUpdateColumns("name"),
+		"INSERT INTO t (id,n,name) Values ($1,$2,$3) ON CONFLICT (id) DO UPDATE SET name=excluded.name,n=n+$4",
+		1, 2, "a", 1)
+
+	// a conflict column could still be changed explicitly
+	sqlTest(t, "conflict column", SQLite().Insert(Eq{"id": 1}).Into("t").
+		OnConflict("id").DoUpdate(Eq{"id": Expr("id+1000")}),
+		"INSERT INTO t (id) Values (?) ON CONFLICT (id) DO UPDATE SET id=(id+1000)",
+		1)
+}
+
+func TestUpsertErrors(t *testing.T) {
+	// an update needs to know the conflicting unique key
+	sqlTest(t, "no conflict target", Postgres().Insert(Eq{"id": 1, "n": 2}).Into("t").
+		OnConflict().DoUpdateColumns("n"), "error: "+ErrNoConflictTarget.Error())
+	sqlTest(t, "no merge target", MsSQL().Insert(Eq{"id": 1, "n": 2}).Into("t").
+		OnConflict().DoUpdateColumns("n"), "error: "+ErrNoConflictTarget.Error())
+	sqlTest(t, "conflict column not inserted", Oracle().Insert(Eq{"n": 2}).Into("t").
+		OnConflict("id").DoUpdateColumns("n"), "error: "+ErrConflictColumnNotInserted.Error())
+
+	// without a conflict target Postgres ignores every conflict
+	sqlTest(t, "any conflict", Postgres().Insert(Eq{"id": 1}).Into("t").OnConflict(),
+		"INSERT INTO t (id) Values ($1) ON CONFLICT DO NOTHING", 1)
+
+	// insert from select
+	sqlTest(t, "select", Postgres().Insert("id", "n").Into("t").Select("id", "n").From("s").
+		OnConflict("id").DoUpdateColumns("n"),
+		"INSERT INTO t (id,n) SELECT id,n FROM s ON CONFLICT (id) DO UPDATE SET n=excluded.n")
+	sqlTest(t, "select", SQLite().Insert("id", "n").Into("t").Select("id", "n").From("s").
+		OnConflict("id").DoUpdateColumns("n"), "error: "+ErrUpsertNotSupported.Error())
+	sqlTest(t, "select", MsSQL().Insert("id", "n").Into("t").Select("id", "n").From("s").
+		OnConflict("id").DoUpdateColumns("n"), "error: "+ErrUpsertNotSupported.Error())
+}
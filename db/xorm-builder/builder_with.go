@@ -0,0 +1,131 @@
+// Copyright 2016 The Xorm Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package builder
+
+import (
+	"fmt"
+	"strings"
+)
+
+type cte struct {
+	name      string
+	cols      []string
+	builder   *Builder
+	recursive bool
+}
+
+// With creates a Builder with a common table expression
+func With(name string, query *Builder, cols ...string) *Builder {
+	builder := &Builder{cond: NewCond()}
+	return builder.With(name, query, cols...)
+}
+
+// WithRecursive creates a Builder with a recursive common table expression
+func WithRecursive(name string, query *Builder, cols ...string) *Builder {
+	builder := &Builder{cond: NewCond()}
+	return builder.WithRecursive(name, query, cols...)
+}
+
+// With adds a common table expression which could be referenced by name in the statement
+func (b *Builder) With(name string, query *Builder, cols ...string) *Builder {
+	b.ctes = append(b.ctes, cte{name, cols, query, false})
+	return b
+}
+
+// WithRecursive adds a recursive common table expression, query is usually a union
+// of an anchor select and a select referencing name
+func (b *Builder) WithRecursive(name string, query *Builder, cols ...string) *Builder {
+	b.ctes = append(b.ctes, cte{name, cols, query, true})
+	return b
+}
+
+// withSupported reports whether the dialect accepts a WITH clause in front of the statement
+func (b *Builder) withSupported() bool {
+	switch b.optype {
+	case selectType, setOpType:
+		return true
+	case insertType:
+		return b.dialect != MYSQL && b.dialect != ORACLE
+	case updateType, deleteType:
+		return b.dialect != ORACLE
+	}
+	return false
+}
+
+func (b *Builder) withWriteTo(w Writer) error {
+	recursive := false
+	for _, c := range b.ctes {
+		if len(c.name) <= 0 || c.builder == nil {
+			return ErrInvalidCTE
+		}
+		if c.recursive {
+			recursive = true
+			// Oracle derives the columns of a recursive query from the column list only
+			if b.dialect == ORACLE && len(c.cols) <= 0 {
+				return ErrRecursiveCTENoColumns
+			}
+		}
+	}
+
+	if _, err := fmt.Fprint(w, "WITH "); err != nil {
+		return err
+	}
+
+	// MsSQL and Oracle detect recursion themselves and reject the keyword
+	if recursive && b.dialect != MSSQL && b.dialect != ORACLE {
+		if _, err := fmt.Fprint(w, "RECURSIVE "); err != nil {
+			return err
+		}
+	}
+
+	for i, c := range b.ctes {
+		if c.builder.dialect != "" && b.dialect != c.builder.dialect {
+			return ErrInconsistentDialect
+		}
+
+		// dialect of common table expression will inherit from the main one (if not set up)
+		if b.dialect != "" && c.builder.dialect == "" {
+			c.builder.dialect = b.dialect
+			for e := range c.builder.setOps {
+				if c.builder.setOps[e].builder.dialect == "" {
+					c.builder.setOps[e].builder.dialect = b.dialect
+				}
+			}
+		}
+
+		if i > 0 {
+			if _, err := fmt.Fprint(w, ","); err != nil {
+				return err
+			}
+		}
+
+		if _, err := fmt.Fprint(w, quoteTo(w, c.name)); err != nil {
+			return err
+		}
+		if len(c.cols) > 0 {
+			if _, err := fmt.Fprintf(w, " (%s)", strings.Join(quoteAllTo(w, c.cols), ",")); err != nil {
+				return err
+			}
+		}
+
+		if _, err := fmt.Fprint(w, " AS ("); err != nil {
+			return err
+		}
+		// recursive queries expect the plain form "anchor UNION [ALL] recursive"
+		if c.builder.optype == setOpType && len(c.builder.ctes) <= 0 {
+			if err := c.builder.setOpMembersWriteTo(w, false); err != nil {
+				return err
+			}
+		} else if err := c.builder.WriteTo(w); err != nil {
+			return err
+		}
+		if _, err := fmt.Fprint(w, ")"); err != nil {
+			return err
+		}
+	}
+
+	_, err := fmt.Fprint(w, " ")
+	return err
+}
@@ -0,0 +1,111 @@
+// Copyright 2016 The Xorm Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package builder
+
+import "testing"
+
+func TestWith(t *testing.T) {
+	dialectTest(t, "select", func(dialect string) *Builder {
+		return Dialect(dialect).With("c", Select("id").From("t").Where(Gt{"n": 1})).
+			Select("id").From("c").Where(Neq{"id": 2})
+	}, []string{
+		"WITH c AS (SELECT id FROM t WHERE n>$1) SELECT id FROM c WHERE id<>$2",
+		"WITH c AS (SELECT id FROM t WHERE n>?) SELECT id FROM c WHERE id<>?",
+		"WITH c AS (SELECT id FROM t WHERE n>?) SELECT id FROM c WHERE id<>?",
+		"WITH c AS (SELECT id FROM t WHERE n>@p1) SELECT id FROM c WHERE id<>@p2",
+		"WITH c AS (SELECT id FROM t WHERE n>:p1) SELECT id FROM c WHERE id<>:p2",
+	}, 1, 2)
+
+	dialectTest(t, "several", func(dialect string) *Builder {
+		return Dialect(dialect).With("a", Select("id").From("t"), "x").With("b", Select("x").From("a")).
+			Select("x").From("b")
+	}, []string{
+		"WITH a (x) AS (SELECT id FROM t),b AS (SELECT x FROM a) SELECT x FROM b",
+		"WITH a (x) AS (SELECT id FROM t),b AS (SELECT x FROM a) SELECT x FROM b",
+		"WITH a (x) AS (SELECT id FROM t),b AS (SELECT x FROM a) SELECT x FROM b",
+		"WITH a (x) AS (SELECT id FROM t),b AS (SELECT x FROM a) SELECT x FROM b",
+		"WITH a (x) AS (SELECT id FROM t),b AS (SELECT x FROM a) SELECT x FROM b",
+	})
+}
+
+func TestWithRecursive(t *testing.T) {
+	dialectTest(t, "recursive", func(dialect string) *Builder {
+		return Dialect(dialect).WithRecursive("c", Select("1").From("dual").
+			Union("all", Select("n+1").From("c").Where(Lt{"n": 5})), "n").
+			Select("n").From("c")
+	}, []string{
+		"WITH RECURSIVE c (n) AS (SELECT 1 FROM dual UNION ALL SELECT n+1 FROM c WHERE n<$1) SELECT n FROM c",
+		"WITH RECURSIVE c (n) AS (SELECT 1 FROM dual UNION ALL SELECT n+1 FROM c WHERE n<?) SELECT n FROM c",
+		"WITH RECURSIVE c (n) AS (SELECT 1 FROM dual UNION ALL SELECT n+1 FROM c WHERE n<?) SELECT n FROM c",
+		"WITH c (n) AS (SELECT 1 FROM dual UNION ALL SELECT n+1 FROM c WHERE n<@p1) SELECT n FROM c",
+		"WITH c (n) AS (SELECT 1 FROM dual UNION ALL SELECT n+1 FROM c WHERE n<:p1) SELECT n FROM c",
+	}, 5)
+
+	sqlTest(t, "no columns", Oracle().WithRecursive("c", Select("1").From("dual").
+		Union("all", Select("n+1").From("c"))).Select("n").From("c"),
+		"error: "+ErrRecursiveCTENoColumns.Error())
+}
+
+func TestWithStatements(t *testing.T) {
+	dialectTest(t, "update", func(dialect string) *Builder {
+		return Dialect(dialect).With("c", Select("id").From("s")).
+			Update(Eq{"n": 1}).From("t").Where(In("id", Select("id").From("c")))
+	}, []string{
+		"WITH c AS (SELECT id FROM s) UPDATE t SET n=$1 WHERE id IN (SELECT id FROM c)",
+		"WITH c AS (SELECT id FROM s) UPDATE t SET n=? WHERE id IN (SELECT id FROM c)",
+		"WITH c AS (SELECT id FROM s) UPDATE t SET n=? WHERE id IN (SELECT id FROM c)",
+		"WITH c AS (SELECT id FROM s) UPDATE t SET n=@p1 WHERE id IN (SELECT id FROM c)",
+		"error: " + ErrCTENotSupported.Error(),
+	}, 1)
+
+	dialectTest(t, "delete", func(dialect string) *Builder {
+		return Dialect(dialect).With("c", Select("id").From("s")).
+			Delete(In("id", Select("id").From("c"))).From("t")
+	}, []string{
+		"WITH c AS (SELECT id FROM s) DELETE FROM t WHERE id IN (SELECT id FROM c)",
+		"WITH c AS (SELECT id FROM s) DELETE FROM t WHERE id IN (SELECT id FROM c)",
+		"WITH c AS (SELECT id FROM s) DELETE FROM t WHERE id IN (SELECT id FROM c)",
+		"WITH c AS (SELECT id FROM s) DELETE FROM t WHERE id IN (SELECT id FROM c)",
+		"error: " + ErrCTENotSupported.Error(),
+	})
+
+	// MySQL and Oracle put the common table expression in front of the select
+	dialectTest(t, "insert select", func(dialect string) *Builder {
+		return Dialect(dialect).With("c", Select("id").From("s")).
+			Insert("id").Into("t").Select("id").From("c")
+	}, []string{
+		"WITH c AS (SELECT id FROM s) INSERT INTO t (id) SELECT id FROM c",
+		"WITH c AS (SELECT id FROM s) INSERT INTO t (id) SELECT id FROM c",
+		"INSERT INTO t (id) WITH c AS (SELECT id FROM s) SELECT id FROM c",
+		"WITH c AS (SELECT id FROM s) INSERT INTO t (id) SELECT id FROM c",
+		"INSERT INTO t (id) WITH c AS (SELECT id FROM s) SELECT id FROM c",
+	})
+
+	dialectTest(t, "insert values", func(dialect string) *Builder {
+		return Dialect(dialect).With("c", Select("id").From("s")).
+			Insert(Eq{"id": 1}).Into("t")
+	}, []string{
+		"WITH c AS (SELECT id FROM s) INSERT INTO t (id) Values ($1)",
+		"WITH c AS (SELECT id FROM s) INSERT INTO t (id) Values (?)",
+		"error: " + ErrCTENotSupported.Error(),
+		"WITH c AS (SELECT id FROM s) INSERT INTO t (id) Values (@p1)",
+		"error: " + ErrCTENotSupported.Error(),
+	}, 1)
+}
+
+func TestWithErrors(t *testing.T) {
+	sqlTest(t, "no name", Postgres().With("", Select("id").From("s")).Select("id").From("c"),
+		"error: "+ErrInvalidCTE.Error())
+	sqlTest(t, "no query", Postgres().With("c", nil).Select("id").From("c"),
+		"error: "+ErrInvalidCTE.Error())
+	sqlTest(t, "dialect", Postgres().With("c", MySQL().Select("id").From("s")).Select("id").From("c"),
+		"error: "+ErrInconsistentDialect.Error())
+
+	// the expressions are written again by the next call
+	b := With("c", Select("id").From("s")).Select("id").From("c")
+	for i := 0; i < 2; i++ {
+		sqlTest(t, "repeated", b, "WITH c AS (SELECT id FROM s) SELECT id FROM c")
+	}
+}
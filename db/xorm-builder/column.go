@@ -0,0 +1,251 @@
+// Copyright 2016 The Xorm Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package builder
+
+import (
+	"fmt"
+)
+
+// ColumnType is a portable column type which is mapped to the type of each dialect
+type ColumnType int
+
+// all portable column types
+const (
+	TypeBool     ColumnType = iota + 1 // boolean
+	TypeSmallInt                       // 16 bit integer
+	TypeInt                            // 32 bit integer
+	TypeBigInt                         // 64 bit integer
+	TypeFloat                          // double precision floating point
+	TypeDecimal                        // exact numeric of Length digits with Scale decimals
+	TypeVarchar                        // string of at most Length characters
+	TypeText                           // unlimited string
+	TypeBlob                           // unlimited binary data
+	TypeDate                           // date without time
+	TypeDateTime                       // date and time without time zone
+)
+
+// DefaultVarcharLength is used for TypeVarchar columns without Length
+const DefaultVarcharLength = 255
+
+// Column defines a column of CREATE TABLE or ALTER TABLE ADD COLUMN
+type Column struct {
+	Name          string
+	Type          ColumnType
+	Length        int    // length of TypeVarchar, precision of TypeDecimal
+	Scale         int    // scale of TypeDecimal
+	NotNull       bool   // adds NOT NULL
+	Default       string // raw SQL of the default value, like 0, 'none' or CURRENT_TIMESTAMP
+	PrimaryKey    bool   // the column is (part of) the primary key
+	AutoIncrement bool   // integer column which is generated by the database
+}
+
+// sqlType returns the type of the column in the dialect
+func (col Column) sqlType(dialect string) (string, error) {
+	switch col.Type {
+	case TypeBool:
+		switch dialect {
+		case POSTGRES:
+			return "BOOLEAN", nil
+		case MYSQL:
+			return "TINYINT(1)", nil
+		case SQLITE:
+			return "INTEGER", nil
+		case MSSQL:
+			return "BIT", nil
+		case ORACLE:
+			return "NUMBER(1)", nil
+		}
+	case TypeSmallInt:
+		switch dialect {
+		case POSTGRES, MYSQL, MSSQL:
+			if col.AutoIncrement && dialect == POSTGRES {
+				return "SMALLSERIAL", nil
+			}
+			return "SMALLINT", nil
+		case SQLITE:
+			return "INTEGER", nil
+		case ORACLE:
+			return "NUMBER(5)", nil
+		}
+	case TypeInt:
+		switch dialect {
+		case POSTGRES:
+			if col.AutoIncrement {
+				return "SERIAL", nil
+			}
+			return "INTEGER", nil
+		case SQLITE:
+			return "INTEGER", nil
+		case MYSQL, MSSQL:
+			return "INT", nil
+		case ORACLE:
+			return "NUMBER(10)", nil
+		}
+	case TypeBigInt:
+		switch dialect {
+		case POSTGRES:
+			if col.AutoIncrement {
+				return "BIGSERIAL", nil
+			}
+			return "BIGINT", nil
+		case MYSQL, MSSQL:
+			return "BIGINT", nil
+		case SQLITE:
+			// only INTEGER is an alias of the rowid
+			return "INTEGER", nil
+		case ORACLE:
+			return "NUMBER(19)", nil
+		}
+	case TypeFloat:
+		switch dialect {
+		case POSTGRES:
+			return "DOUBLE PRECISION", nil
+		case MYSQL:
+			return "DOUBLE", nil
+		case SQLITE:
+			return "REAL", nil
+		case MSSQL:
+			return "FLOAT", nil
+		case ORACLE:
+			return "BINARY_DOUBLE", nil
+		}
+	case TypeDecimal:
+		if col.Length <= 0 {
+			return "", ErrInvalidColumn
+		}
+		switch dialect {
+		case POSTGRES, MYSQL, MSSQL:
+			return fmt.Sprintf("DECIMAL(%d,%d)", col.Length, col.Scale), nil
+		case SQLITE:
+			return fmt.Sprintf("NUMERIC(%d,%d)", col.Length, col.Scale), nil
+		case ORACLE:
+			return fmt.Sprintf("NUMBER(%d,%d)", col.Length, col.Scale), nil
+		}
+	case TypeVarchar:
+		length := col.Length
+		if length <= 0 {
+			length = DefaultVarcharLength
+		}
+		switch dialect {
+		case POSTGRES, MYSQL, SQLITE:
+			return fmt.Sprintf("VARCHAR(%d)", length), nil
+		case MSSQL:
+			return fmt.Sprintf("NVARCHAR(%d)", length), nil
+		case ORACLE:
+			return fmt.Sprintf("VARCHAR2(%d CHAR)", length), nil
+		}
+	case TypeText:
+		switch dialect {
+		case POSTGRES, SQLITE:
+			return "TEXT", nil
+		case MYSQL:
+			return "LONGTEXT", nil
+		case MSSQL:
+			return "NVARCHAR(MAX)", nil
+		case ORACLE:
+			return "CLOB", nil
+		}
+	case TypeBlob:
+		switch dialect {
+		case POSTGRES:
+			return "BYTEA", nil
+		case MYSQL:
+			return "LONGBLOB", nil
+		case SQLITE, ORACLE:
+			return "BLOB", nil
+		case MSSQL:
+			return "VARBINARY(MAX)", nil
+		}
+	case TypeDate:
+		switch dialect {
+		case POSTGRES, MYSQL, SQLITE, MSSQL, ORACLE:
+			return "DATE", nil
+		}
+	case TypeDateTime:
+		switch dialect {
+		case POSTGRES, ORACLE:
+			return "TIMESTAMP", nil
+		case MYSQL, SQLITE:
+			return "DATETIME", nil
+		case MSSQL:
+			return "DATETIME2", nil
+		}
+	default:
+		return "", ErrInvalidColumn
+	}
+
+	if dialect == "" {
+		return "", ErrDialectNotSetUp
+	}
+	return "", ErrNotSupportDialectType
+}
+
+// columnWriteTo writes the definition of col, inlinePK writes the primary key constraint
+// of a single column primary key
+func (b *Builder) columnWriteTo(w Writer, col Column, inlinePK bool) error {
+	if len(col.Name) <= 0 {
+		return ErrInvalidColumn
+	}
+	if col.AutoIncrement {
+		switch col.Type {
+		case TypeSmallInt, TypeInt, TypeBigInt:
+		default:
+			return ErrInvalidColumn
+		}
+	}
+
+	sqlType, err := col.sqlType(b.dialect)
+	if err != nil {
+		return err
+	}
+	if _, err := fmt.Fprint(w, quoteTo(w, col.Name), " ", sqlType); err != nil {
+		return err
+	}
+
+	if col.AutoIncrement {
+		switch b.dialect {
+		case MSSQL:
+			if _, err := fmt.Fprint(w, " IDENTITY(1,1)"); err != nil {
+				return err
+			}
+		case ORACLE:
+			if _, err := fmt.Fprint(w, " GENERATED BY DEFAULT AS IDENTITY"); err != nil {
+				return err
+			}
+		}
+	}
+
+	if len(col.Default) > 0 {
+		if _, err := fmt.Fprint(w, " DEFAULT ", col.Default); err != nil {
+			return err
+		}
+	}
+
+	if col.NotNull {
+		if _, err := fmt.Fprint(w, " NOT NULL"); err != nil {
+			return err
+		}
+	}
+
+	if col.AutoIncrement && b.dialect == MYSQL {
+		if _, err := fmt.Fprint(w, " AUTO_INCREMENT"); err != nil {
+			return err
+		}
+	}
+
+	if inlinePK {
+		if _, err := fmt.Fprint(w, " PRIMARY KEY"); err != nil {
+			return err
+		}
+		// SQLite generates values only for an INTEGER PRIMARY KEY
+		if col.AutoIncrement && b.dialect == SQLITE {
+			if _, err := fmt.Fprint(w, " AUTOINCREMENT"); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
@@ -17,9 +17,10 @@ func WriteMap(w Writer, data map[string]interface{}, op string) error {
 
 	for _, k := range keys {
 		v := data[k]
+		col := quoteTo(w, k)
 		switch v.(type) {
 		case *Expression:
-			if _, err := fmt.Fprintf(w, "%s%s(", k, op); err != nil {
+			if _, err := fmt.Fprintf(w, "%s%s(", col, op); err != nil {
 				return err
 			}
 
@@ -31,7 +32,7 @@ func WriteMap(w Writer, data map[string]interface{}, op string) error {
 				return err
 			}
 		case *Builder:
-			if _, err := fmt.Fprintf(w, "%s%s(", k, op); err != nil {
+			if _, err := fmt.Fprintf(w, "%s%s(", col, op); err != nil {
 				return err
 			}
 
@@ -43,7 +44,7 @@ func WriteMap(w Writer, data map[string]interface{}, op string) error {
 				return err
 			}
 		default:
-			if _, err := fmt.Fprintf(w, "%s%s?", k, op); err != nil {
+			if _, err := fmt.Fprintf(w, "%s%s?", col, op); err != nil {
 				return err
 			}
 			args = append(args, v)
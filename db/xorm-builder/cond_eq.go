@@ -25,13 +25,14 @@ func (eq Eq) OpWriteTo(op string, w Writer) error {
 	i := 0
 	for _, k := range eq.sortedKeys() {
 		v := eq[k]
+		col := quoteTo(w, k)
 		switch v.(type) {
 		case []int, []int64, []string, []int32, []int16, []int8, []uint, []uint64, []uint32, []uint16, []interface{}:
 			if err := In(k, v).WriteTo(w); err != nil {
 				return err
 			}
 		case *Expression:
-			if _, err := fmt.Fprintf(w, "%s=(", k); err != nil {
+			if _, err := fmt.Fprintf(w, "%s=(", col); err != nil {
 				return err
 			}
 
@@ -43,7 +44,7 @@ func (eq Eq) OpWriteTo(op string, w Writer) error {
 				return err
 			}
 		case *Builder:
-			if _, err := fmt.Fprintf(w, "%s=(", k); err != nil {
+			if _, err := fmt.Fprintf(w, "%s=(", col); err != nil {
 				return err
 			}
 
@@ -55,21 +56,21 @@ func (eq Eq) OpWriteTo(op string, w Writer) error {
 				return err
 			}
 		case Incr:
-			if _, err := fmt.Fprintf(w, "%s=%s+?", k, k); err != nil {
+			if _, err := fmt.Fprintf(w, "%s=%s+?", col, col); err != nil {
 				return err
 			}
 			w.Append(int(v.(Incr)))
 		case Decr:
-			if _, err := fmt.Fprintf(w, "%s=%s-?", k, k); err != nil {
+			if _, err := fmt.Fprintf(w, "%s=%s-?", col, col); err != nil {
 				return err
 			}
 			w.Append(int(v.(Decr)))
 		case nil:
-			if _, err := fmt.Fprintf(w, "%s=null", k); err != nil {
+			if _, err := fmt.Fprintf(w, "%s=null", col); err != nil {
 				return err
 			}
 		default:
-			if _, err := fmt.Fprintf(w, "%s=?", k); err != nil {
+			if _, err := fmt.Fprintf(w, "%s=?", col); err != nil {
 				return err
 			}
 			w.Append(v)
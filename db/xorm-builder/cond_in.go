@@ -28,6 +28,8 @@ func (condIn condIn) handleBlank(w Writer) error {
 }
 
 func (condIn condIn) WriteTo(w Writer) error {
+	col := quoteTo(w, condIn.col)
+
 	if len(condIn.vals) <= 0 {
 		return condIn.handleBlank(w)
 	}
@@ -48,7 +50,7 @@ func (condIn condIn) WriteTo(w Writer) error {
 			trackMap[val] = true
 		}
 		questionMark := strings.Repeat("?,", len(trackMap))
-		if _, err := fmt.Fprintf(w, "%s IN (%s)", condIn.col, questionMark[:len(questionMark)-1]); err != nil {
+		if _, err := fmt.Fprintf(w, "%s IN (%s)", col, questionMark[:len(questionMark)-1]); err != nil {
 			return err
 		}
 	case []int16:
@@ -66,7 +68,7 @@ func (condIn condIn) WriteTo(w Writer) error {
 			trackMap[val] = true
 		}
 		questionMark := strings.Repeat("?,", len(trackMap))
-		if _, err := fmt.Fprintf(w, "%s IN (%s)", condIn.col, questionMark[:len(questionMark)-1]); err != nil {
+		if _, err := fmt.Fprintf(w, "%s IN (%s)", col, questionMark[:len(questionMark)-1]); err != nil {
 			return err
 		}
 	case []int:
@@ -84,7 +86,7 @@ func (condIn condIn) WriteTo(w Writer) error {
 			trackMap[val] = true
 		}
 		questionMark := strings.Repeat("?,", len(trackMap))
-		if _, err := fmt.Fprintf(w, "%s IN (%s)", condIn.col, questionMark[:len(questionMark)-1]); err != nil {
+		if _, err := fmt.Fprintf(w, "%s IN (%s)", col, questionMark[:len(questionMark)-1]); err != nil {
 			return err
 		}
 	case []int32:
@@ -102,7 +104,7 @@ func (condIn condIn) WriteTo(w Writer) error {
 			trackMap[val] = true
 		}
 		questionMark := strings.Repeat("?,", len(trackMap))
-		if _, err := fmt.Fprintf(w, "%s IN (%s)", condIn.col, questionMark[:len(questionMark)-1]); err != nil {
+		if _, err := fmt.Fprintf(w, "%s IN (%s)", col, questionMark[:len(questionMark)-1]); err != nil {
 			return err
 		}
 	case []int64:
@@ -120,7 +122,7 @@ func (condIn condIn) WriteTo(w Writer) error {
 			trackMap[val] = true
 		}
 		questionMark := strings.Repeat("?,", len(trackMap))
-		if _, err := fmt.Fprintf(w, "%s IN (%s)", condIn.col, questionMark[:len(questionMark)-1]); err != nil {
+		if _, err := fmt.Fprintf(w, "%s IN (%s)", col, questionMark[:len(questionMark)-1]); err != nil {
 			return err
 		}
 	case []uint8:
@@ -138,7 +140,7 @@ func (condIn condIn) WriteTo(w Writer) error {
 			trackMap[val] = true
 		}
 		questionMark := strings.Repeat("?,", len(trackMap))
-		if _, err := fmt.Fprintf(w, "%s IN (%s)", condIn.col, questionMark[:len(questionMark)-1]); err != nil {
+		if _, err := fmt.Fprintf(w, "%s IN (%s)", col, questionMark[:len(questionMark)-1]); err != nil {
 			return err
 		}
 	case []uint16:
@@ -156,7 +158,7 @@ func (condIn condIn) WriteTo(w Writer) error {
 			trackMap[val] = true
 		}
 		questionMark := strings.Repeat("?,", len(trackMap))
-		if _, err := fmt.Fprintf(w, "%s IN (%s)", condIn.col, questionMark[:len(questionMark)-1]); err != nil {
+		if _, err := fmt.Fprintf(w, "%s IN (%s)", col, questionMark[:len(questionMark)-1]); err != nil {
 			return err
 		}
 	case []uint:
@@ -174,7 +176,7 @@ func (condIn condIn) WriteTo(w Writer) error {
 			trackMap[val] = true
 		}
 		questionMark := strings.Repeat("?,", len(trackMap))
-		if _, err := fmt.Fprintf(w, "%s IN (%s)", condIn.col, questionMark[:len(questionMark)-1]); err != nil {
+		if _, err := fmt.Fprintf(w, "%s IN (%s)", col, questionMark[:len(questionMark)-1]); err != nil {
 			return err
 		}
 	case []uint32:
@@ -192,7 +194,7 @@ func (condIn condIn) WriteTo(w Writer) error {
 			trackMap[val] = true
 		}
 		questionMark := strings.Repeat("?,", len(trackMap))
-		if _, err := fmt.Fprintf(w, "%s IN (%s)", condIn.col, questionMark[:len(questionMark)-1]); err != nil {
+		if _, err := fmt.Fprintf(w, "%s IN (%s)", col, questionMark[:len(questionMark)-1]); err != nil {
 			return err
 		}
 	case []uint64:
@@ -210,7 +212,7 @@ func (condIn condIn) WriteTo(w Writer) error {
 			trackMap[val] = true
 		}
 		questionMark := strings.Repeat("?,", len(trackMap))
-		if _, err := fmt.Fprintf(w, "%s IN (%s)", condIn.col, questionMark[:len(questionMark)-1]); err != nil {
+		if _, err := fmt.Fprintf(w, "%s IN (%s)", col, questionMark[:len(questionMark)-1]); err != nil {
 			return err
 		}
 	case []string:
@@ -228,7 +230,7 @@ func (condIn condIn) WriteTo(w Writer) error {
 			trackMap[val] = true
 		}
 		questionMark := strings.Repeat("?,", len(trackMap))
-		if _, err := fmt.Fprintf(w, "%s IN (%s)", condIn.col, questionMark[:len(questionMark)-1]); err != nil {
+		if _, err := fmt.Fprintf(w, "%s IN (%s)", col, questionMark[:len(questionMark)-1]); err != nil {
 			return err
 		}
 	case []interface{}:
@@ -237,13 +239,13 @@ func (condIn condIn) WriteTo(w Writer) error {
 			return condIn.handleBlank(w)
 		}
 		questionMark := strings.Repeat("?,", len(vals))
-		if _, err := fmt.Fprintf(w, "%s IN (%s)", condIn.col, questionMark[:len(questionMark)-1]); err != nil {
+		if _, err := fmt.Fprintf(w, "%s IN (%s)", col, questionMark[:len(questionMark)-1]); err != nil {
 			return err
 		}
 		w.Append(vals...)
 	case *Expression:
 		val := condIn.vals[0].(*Expression)
-		if _, err := fmt.Fprintf(w, "%s IN (", condIn.col); err != nil {
+		if _, err := fmt.Fprintf(w, "%s IN (", col); err != nil {
 			return err
 		}
 		if err := val.WriteTo(w); err != nil {
@@ -254,7 +256,7 @@ func (condIn condIn) WriteTo(w Writer) error {
 		}
 	case *Builder:
 		bd := condIn.vals[0].(*Builder)
-		if _, err := fmt.Fprintf(w, "%s IN (", condIn.col); err != nil {
+		if _, err := fmt.Fprintf(w, "%s IN (", col); err != nil {
 			return err
 		}
 		if err := bd.WriteTo(w); err != nil {
@@ -282,7 +284,7 @@ func (condIn condIn) WriteTo(w Writer) error {
 			}
 
 			questionMark := strings.Repeat("?,", len(trackMap))
-			if _, err := fmt.Fprintf(w, "%s IN (%s)", condIn.col, questionMark[:len(questionMark)-1]); err != nil {
+			if _, err := fmt.Fprintf(w, "%s IN (%s)", col, questionMark[:len(questionMark)-1]); err != nil {
 				return err
 			}
 		} else {
@@ -304,7 +306,7 @@ func (condIn condIn) WriteTo(w Writer) error {
 			condIn.vals = condIn.vals[:i]
 
 			questionMark := strings.Repeat("?,", len(condIn.vals))
-			if _, err := fmt.Fprintf(w, "%s IN (%s)", condIn.col, questionMark[:len(questionMark)-1]); err != nil {
+			if _, err := fmt.Fprintf(w, "%s IN (%s)", col, questionMark[:len(questionMark)-1]); err != nil {
 				return err
 			}
 			w.Append(condIn.vals...)
@@ -20,13 +20,14 @@ func (neq Neq) WriteTo(w Writer) error {
 	i := 0
 	for _, k := range neq.sortedKeys() {
 		v := neq[k]
+		col := quoteTo(w, k)
 		switch v.(type) {
 		case []int, []int64, []string, []int32, []int16, []int8:
 			if err := NotIn(k, v).WriteTo(w); err != nil {
 				return err
 			}
 		case *Expression:
-			if _, err := fmt.Fprintf(w, "%s<>(", k); err != nil {
+			if _, err := fmt.Fprintf(w, "%s<>(", col); err != nil {
 				return err
 			}
 
@@ -38,7 +39,7 @@ func (neq Neq) WriteTo(w Writer) error {
 				return err
 			}
 		case *Builder:
-			if _, err := fmt.Fprintf(w, "%s<>(", k); err != nil {
+			if _, err := fmt.Fprintf(w, "%s<>(", col); err != nil {
 				return err
 			}
 
@@ -50,7 +51,7 @@ func (neq Neq) WriteTo(w Writer) error {
 				return err
 			}
 		default:
-			if _, err := fmt.Fprintf(w, "%s<>?", k); err != nil {
+			if _, err := fmt.Fprintf(w, "%s<>?", col); err != nil {
 				return err
 			}
 			args = append(args, v)
@@ -25,6 +25,8 @@ func (condNotIn condNotIn) handleBlank(w Writer) error {
 }
 
 func (condNotIn condNotIn) WriteTo(w Writer) error {
+	col := quoteTo(w, condNotIn.col)
+
 	if len(condNotIn.vals) <= 0 {
 		return condNotIn.handleBlank(w)
 	}
@@ -45,7 +47,7 @@ func (condNotIn condNotIn) WriteTo(w Writer) error {
 			trackMap[val] = true
 		}
 		questionMark := strings.Repeat("?,", len(trackMap))
-		if _, err := fmt.Fprintf(w, "%s NOT IN (%s)", condNotIn.col, questionMark[:len(questionMark)-1]); err != nil {
+		if _, err := fmt.Fprintf(w, "%s NOT IN (%s)", col, questionMark[:len(questionMark)-1]); err != nil {
 			return err
 		}
 	case []int16:
@@ -63,7 +65,7 @@ func (condNotIn condNotIn) WriteTo(w Writer) error {
 			trackMap[val] = true
 		}
 		questionMark := strings.Repeat("?,", len(trackMap))
-		if _, err := fmt.Fprintf(w, "%s NOT IN (%s)", condNotIn.col, questionMark[:len(questionMark)-1]); err != nil {
+		if _, err := fmt.Fprintf(w, "%s NOT IN (%s)", col, questionMark[:len(questionMark)-1]); err != nil {
 			return err
 		}
 	case []int:
@@ -81,7 +83,7 @@ func (condNotIn condNotIn) WriteTo(w Writer) error {
 			trackMap[val] = true
 		}
 		questionMark := strings.Repeat("?,", len(trackMap))
-		if _, err := fmt.Fprintf(w, "%s NOT IN (%s)", condNotIn.col, questionMark[:len(questionMark)-1]); err != nil {
+		if _, err := fmt.Fprintf(w, "%s NOT IN (%s)", col, questionMark[:len(questionMark)-1]); err != nil {
 			return err
 		}
 	case []int32:
@@ -99,7 +101,7 @@ func (condNotIn condNotIn) WriteTo(w Writer) error {
 			trackMap[val] = true
 		}
 		questionMark := strings.Repeat("?,", len(trackMap))
-		if _, err := fmt.Fprintf(w, "%s NOT IN (%s)", condNotIn.col, questionMark[:len(questionMark)-1]); err != nil {
+		if _, err := fmt.Fprintf(w, "%s NOT IN (%s)", col, questionMark[:len(questionMark)-1]); err != nil {
 			return err
 		}
 	case []int64:
@@ -117,7 +119,7 @@ func (condNotIn condNotIn) WriteTo(w Writer) error {
 			trackMap[val] = true
 		}
 		questionMark := strings.Repeat("?,", len(trackMap))
-		if _, err := fmt.Fprintf(w, "%s NOT IN (%s)", condNotIn.col, questionMark[:len(questionMark)-1]); err != nil {
+		if _, err := fmt.Fprintf(w, "%s NOT IN (%s)", col, questionMark[:len(questionMark)-1]); err != nil {
 			return err
 		}
 	case []uint8:
@@ -135,7 +137,7 @@ func (condNotIn condNotIn) WriteTo(w Writer) error {
 			trackMap[val] = true
 		}
 		questionMark := strings.Repeat("?,", len(trackMap))
-		if _, err := fmt.Fprintf(w, "%s NOT IN (%s)", condNotIn.col, questionMark[:len(questionMark)-1]); err != nil {
+		if _, err := fmt.Fprintf(w, "%s NOT IN (%s)", col, questionMark[:len(questionMark)-1]); err != nil {
 			return err
 		}
 	case []uint16:
@@ -153,7 +155,7 @@ func (condNotIn condNotIn) WriteTo(w Writer) error {
 			trackMap[val] = true
 		}
 		questionMark := strings.Repeat("?,", len(trackMap))
-		if _, err := fmt.Fprintf(w, "%s NOT IN (%s)", condNotIn.col, questionMark[:len(questionMark)-1]); err != nil {
+		if _, err := fmt.Fprintf(w, "%s NOT IN (%s)", col, questionMark[:len(questionMark)-1]); err != nil {
 			return err
 		}
 	case []uint:
@@ -171,7 +173,7 @@ func (condNotIn condNotIn) WriteTo(w Writer) error {
 			trackMap[val] = true
 		}
 		questionMark := strings.Repeat("?,", len(trackMap))
-		if _, err := fmt.Fprintf(w, "%s NOT IN (%s)", condNotIn.col, questionMark[:len(questionMark)-1]); err != nil {
+		if _, err := fmt.Fprintf(w, "%s NOT IN (%s)", col, questionMark[:len(questionMark)-1]); err != nil {
 			return err
 		}
 	case []uint32:
@@ -189,7 +191,7 @@ func (condNotIn condNotIn) WriteTo(w Writer) error {
 			trackMap[val] = true
 		}
 		questionMark := strings.Repeat("?,", len(trackMap))
-		if _, err := fmt.Fprintf(w, "%s NOT IN (%s)", condNotIn.col, questionMark[:len(questionMark)-1]); err != nil {
+		if _, err := fmt.Fprintf(w, "%s NOT IN (%s)", col, questionMark[:len(questionMark)-1]); err != nil {
 			return err
 		}
 	case []uint64:
@@ -207,7 +209,7 @@ func (condNotIn condNotIn) WriteTo(w Writer) error {
 			trackMap[val] = true
 		}
 		questionMark := strings.Repeat("?,", len(trackMap))
-		if _, err := fmt.Fprintf(w, "%s NOT IN (%s)", condNotIn.col, questionMark[:len(questionMark)-1]); err != nil {
+		if _, err := fmt.Fprintf(w, "%s NOT IN (%s)", col, questionMark[:len(questionMark)-1]); err != nil {
 			return err
 		}
 	case []string:
@@ -225,7 +227,7 @@ func (condNotIn condNotIn) WriteTo(w Writer) error {
 			trackMap[val] = true
 		}
 		questionMark := strings.Repeat("?,", len(trackMap))
-		if _, err := fmt.Fprintf(w, "%s NOT IN (%s)", condNotIn.col, questionMark[:len(questionMark)-1]); err != nil {
+		if _, err := fmt.Fprintf(w, "%s NOT IN (%s)", col, questionMark[:len(questionMark)-1]); err != nil {
 			return err
 		}
 	case []interface{}:
@@ -234,13 +236,13 @@ func (condNotIn condNotIn) WriteTo(w Writer) error {
 			return condNotIn.handleBlank(w)
 		}
 		questionMark := strings.Repeat("?,", len(vals))
-		if _, err := fmt.Fprintf(w, "%s NOT IN (%s)", condNotIn.col, questionMark[:len(questionMark)-1]); err != nil {
+		if _, err := fmt.Fprintf(w, "%s NOT IN (%s)", col, questionMark[:len(questionMark)-1]); err != nil {
 			return err
 		}
 		w.Append(vals...)
 	case *Expression:
 		val := condNotIn.vals[0].(*Expression)
-		if _, err := fmt.Fprintf(w, "%s NOT IN (", condNotIn.col); err != nil {
+		if _, err := fmt.Fprintf(w, "%s NOT IN (", col); err != nil {
 			return err
 		}
 		if err := val.WriteTo(w); err != nil {
@@ -251,7 +253,7 @@ func (condNotIn condNotIn) WriteTo(w Writer) error {
 		}
 	case *Builder:
 		val := condNotIn.vals[0].(*Builder)
-		if _, err := fmt.Fprintf(w, "%s NOT IN (", condNotIn.col); err != nil {
+		if _, err := fmt.Fprintf(w, "%s NOT IN (", col); err != nil {
 			return err
 		}
 		if err := val.WriteTo(w); err != nil {
@@ -279,7 +281,7 @@ func (condNotIn condNotIn) WriteTo(w Writer) error {
 			}
 
 			questionMark := strings.Repeat("?,", len(trackMap))
-			if _, err := fmt.Fprintf(w, "%s NOT IN (%s)", condNotIn.col, questionMark[:len(questionMark)-1]); err != nil {
+			if _, err := fmt.Fprintf(w, "%s NOT IN (%s)", col, questionMark[:len(questionMark)-1]); err != nil {
 				return err
 			}
 		} else {
@@ -301,7 +303,7 @@ func (condNotIn condNotIn) WriteTo(w Writer) error {
 			condNotIn.vals = condNotIn.vals[:i]
 
 			questionMark := strings.Repeat("?,", len(condNotIn.vals))
-			if _, err := fmt.Fprintf(w, "%s NOT IN (%s)", condNotIn.col, questionMark[:len(questionMark)-1]); err != nil {
+			if _, err := fmt.Fprintf(w, "%s NOT IN (%s)", col, questionMark[:len(questionMark)-1]); err != nil {
 				return err
 			}
 			w.Append(condNotIn.vals...)
@@ -37,4 +37,24 @@ var (
 	ErrUnnamedDerivedTable = errors.New("Every derived table must have its own alias")
 	// ErrInconsistentDialect Inconsistent dialect in same builder
 	ErrInconsistentDialect = errors.New("Inconsistent dialect in same builder")
+	// ErrInvalidCTE common table expression without name or query
+	ErrInvalidCTE = errors.New("Common table expression needs a name and a query")
+	// ErrCTENotSupported dialect cannot put a common table expression in front of the statement
+	ErrCTENotSupported = errors.New("Common table expression is not supported by the dialect for this statement")
+	// ErrRecursiveCTENoColumns recursive common table expression needs a column list
+	ErrRecursiveCTENoColumns = errors.New("Recursive common table expression needs a column list in this dialect")
+	// ErrNoConflictTarget no conflict columns for upsert
+	ErrNoConflictTarget = errors.New("No conflict column(s) indicated for upsert")
+	// ErrConflictColumnNotInserted conflict column is not part of the inserted columns
+	ErrConflictColumnNotInserted = errors.New("Conflict column(s) must be inserted for MERGE")
+	// ErrUpsertNotSupported dialect or statement cannot express an upsert
+	ErrUpsertNotSupported = errors.New("Upsert is not supported by the dialect for this statement")
+	// ErrReturningNotSupported dialect cannot return rows of a statement
+	ErrReturningNotSupported = errors.New("RETURNING is not supported by the dialect")
+	// ErrNoColumns no column for CREATE TABLE or CREATE INDEX
+	ErrNoColumns = errors.New("No column(s) indicated")
+	// ErrInvalidColumn column definition without name or with an unknown type
+	ErrInvalidColumn = errors.New("Column needs a name and a type, only integer columns could be auto incremented")
+	// ErrNoIndexName no index name
+	ErrNoIndexName = errors.New("No index name indicated")
 )
@@ -0,0 +1,219 @@
+// Copyright 2016 The Xorm Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package builder
+
+import (
+	"strings"
+	"unicode"
+)
+
+// QuotePolicy describes which identifiers are quoted
+type QuotePolicy int
+
+const (
+	// QuotePolicyNone writes identifiers as given
+	QuotePolicyNone QuotePolicy = iota
+	// QuotePolicyReserved quotes identifiers which are reserved words of the dialect
+	QuotePolicyReserved
+	// QuotePolicyAlways quotes all identifiers
+	QuotePolicyAlways
+)
+
+// Quoter quotes identifiers for a dialect
+type Quoter struct {
+	Prefix     byte
+	Suffix     byte
+	Policy     QuotePolicy
+	IsReserved func(word string) bool
+}
+
+// NewQuoter creates the Quoter of the dialect
+func NewQuoter(dialect string, policy QuotePolicy) Quoter {
+	q := Quoter{Prefix: '"', Suffix: '"', Policy: policy}
+	switch dialect {
+	case MYSQL:
+		q.Prefix, q.Suffix = '`', '`'
+	case MSSQL:
+		q.Prefix, q.Suffix = '[', ']'
+	}
+
+	extra := reservedWords[dialect]
+	q.IsReserved = func(word string) bool {
+		word = strings.ToUpper(word)
+		return commonReservedWords[word] || extra[word]
+	}
+	return q
+}
+
+// Quote quotes a column or table name according to the policy. Qualified names like
+// table.col and names followed by an alias are quoted part by part, while expressions,
+// already quoted names and "*" are returned unchanged.
+func (q Quoter) Quote(name string) string {
+	if q.Policy == QuotePolicyNone {
+		return name
+	}
+
+	fields := strings.Fields(name)
+	switch {
+	case len(fields) == 1:
+		if quoted, ok := q.quotePath(fields[0]); ok {
+			return quoted
+		}
+	case len(fields) == 2:
+		if quoted, ok := q.quotePath(fields[0]); ok {
+			if alias, ok := q.quoteWord(fields[1]); ok {
+				return quoted + " " + alias
+			}
+		}
+	case len(fields) == 3 && strings.EqualFold(fields[1], "AS"):
+		if quoted, ok := q.quotePath(fields[0]); ok {
+			if alias, ok := q.quoteWord(fields[2]); ok {
+				return quoted + " " + fields[1] + " " + alias
+			}
+		}
+	}
+	return name
+}
+
+// quotePath quotes every part of a dotted name, it fails if a part is no identifier
+func (q Quoter) quotePath(path string) (string, bool) {
+	parts := strings.Split(path, ".")
+	for i, part := range parts {
+		if part == "*" && i == len(parts)-1 {
+			continue
+		}
+		quoted, ok := q.quoteWord(part)
+		if !ok {
+			return "", false
+		}
+		parts[i] = quoted
+	}
+	return strings.Join(parts, "."), true
+}
+
+// quoteWord quotes a single identifier, it fails if word is no identifier
+func (q Quoter) quoteWord(word string) (string, bool) {
+	if len(word) >= 2 && word[0] == q.Prefix && word[len(word)-1] == q.Suffix {
+		return word, true
+	}
+	if !isIdentifier(word) {
+		return "", false
+	}
+	if unquotedWords[strings.ToUpper(word)] {
+		return word, true
+	}
+	if q.Policy == QuotePolicyReserved && !q.IsReserved(word) {
+		return word, true
+	}
+	return string(q.Prefix) + word + string(q.Suffix), true
+}
+
+func isIdentifier(word string) bool {
+	if len(word) <= 0 {
+		return false
+	}
+	for i, r := range word {
+		switch {
+		case r == '_' || unicode.IsLetter(r):
+		case i > 0 && (r == '$' || unicode.IsDigit(r)):
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+// quoteWriter carries the Quoter of the outermost builder to nested builders and conditions
+type quoteWriter struct {
+	Writer
+	quoter Quoter
+}
+
+// quoteTo quotes name if the writer has been set up by a builder with a quote policy
+func quoteTo(w Writer, name string) string {
+	if qw, ok := w.(*quoteWriter); ok {
+		return qw.quoter.Quote(name)
+	}
+	return name
+}
+
+// quoteAllTo quotes every name of names
+func quoteAllTo(w Writer, names []string) []string {
+	quoted := make([]string, len(names))
+	for i, name := range names {
+		quoted[i] = quoteTo(w, name)
+	}
+	return quoted
+}
+
+// unquotedWords are literals and pseudo columns which must never be quoted
+var unquotedWords = map[string]bool{
+	"NULL": true, "TRUE": true, "FALSE": true, "DEFAULT": true,
+	"CURRENT_DATE": true, "CURRENT_TIME": true, "CURRENT_TIMESTAMP": true,
+	"LOCALTIME": true, "LOCALTIMESTAMP": true,
+	"ROWNUM": true, "ROWID": true, "SYSDATE": true, "SYSTIMESTAMP": true,
+}
+
+// commonReservedWords are reserved by the SQL standard and most dialects
+var commonReservedWords = wordSet(`ADD ALL ALTER AND ANY AS ASC AUTHORIZATION BETWEEN BY CASE CAST CHECK
+	COLUMN CONSTRAINT CREATE CROSS CURRENT_USER DEFAULT DELETE DESC DISTINCT DROP ELSE END ESCAPE
+	EXCEPT EXISTS FETCH FOR FOREIGN FROM FULL GRANT GROUP HAVING IN INDEX INNER INSERT INTERSECT
+	INTO IS JOIN KEY LEFT LIKE NATURAL NOT NULL OF ON OR ORDER OUTER PRIMARY REFERENCES RIGHT
+	SELECT SESSION_USER SET SOME TABLE THEN TO UNION UNIQUE UPDATE USER USING VALUES WHEN
+	WHERE WITH`)
+
+// reservedWords are the additional reserved words of the dialects
+var reservedWords = map[string]map[string]bool{
+	POSTGRES: wordSet(`ANALYSE ANALYZE ARRAY ASYMMETRIC BOTH COLLATE CONCURRENTLY DEFERRABLE DO
+		FREEZE ILIKE INITIALLY ISNULL LATERAL LEADING LIMIT NOTNULL OFFSET ONLY OVERLAPS PLACING
+		RETURNING SIMILAR SYMMETRIC TABLESAMPLE TRAILING VARIADIC VERBOSE WINDOW`),
+	SQLITE: wordSet(`ABORT ACTION AFTER ATTACH AUTOINCREMENT BEFORE BEGIN CASCADE COLLATE COMMIT
+		CONFLICT DEFERRABLE DETACH EACH EXCLUSIVE EXPLAIN GLOB IF IGNORE IMMEDIATE INDEXED
+		INSTEAD ISNULL LIMIT MATCH NOTNULL OFFSET PLAN PRAGMA QUERY RAISE RECURSIVE REGEXP
+		REINDEX RELEASE RENAME REPLACE RESTRICT ROLLBACK ROW SAVEPOINT TEMP TEMPORARY TRIGGER
+		VACUUM VIEW VIRTUAL`),
+	MYSQL: wordSet(`ACCESSIBLE ANALYZE BEFORE BOTH CALL CASCADE CHANGE CHAR CHARACTER COLLATE
+		CONDITION CONTINUE CONVERT CURSOR DATABASE DATABASES DECIMAL DECLARE DELAYED DESCRIBE
+		DIV DOUBLE DUAL EACH ELSEIF ENCLOSED ESCAPED EXIT EXPLAIN FLOAT FORCE FULLTEXT GROUPS
+		HIGH_PRIORITY IF IGNORE INFILE INT INTEGER INTERVAL ITERATE KEYS KILL LEADING LEAVE
+		LIMIT LINEAR LINES LOAD LOCK LONG LOOP LOW_PRIORITY MATCH MOD NUMERIC OPTION OPTIONALLY
+		OUTFILE PARTITION PROCEDURE PURGE RANGE RANK READ REAL RECURSIVE REGEXP RELEASE RENAME
+		REPEAT REPLACE REQUIRE RESTRICT RETURN REVOKE RLIKE ROW ROWS SCHEMA SCHEMAS SEPARATOR
+		SHOW SIGNAL SPATIAL SQL STARTING STRAIGHT_JOIN TERMINATED TRAILING TRIGGER UNDO UNLOCK
+		UNSIGNED USAGE USE VARCHAR WHILE WRITE XOR ZEROFILL`),
+	MSSQL: wordSet(`BACKUP BEGIN BREAK BROWSE BULK CASCADE CHECKPOINT CLOSE CLUSTERED COMMIT
+		COMPUTE CONTAINS CONTAINSTABLE CONTINUE CONVERT CURSOR DATABASE DBCC DEALLOCATE DECLARE
+		DENY DISK DISTRIBUTED DOUBLE DUMP ERRLVL EXEC EXECUTE EXIT EXTERNAL FILE FILLFACTOR
+		FREETEXT FREETEXTTABLE FUNCTION GOTO HOLDLOCK IDENTITY IDENTITY_INSERT IDENTITYCOL IF
+		KILL LINENO LOAD MERGE NATIONAL NOCHECK NONCLUSTERED OFF OFFSETS OPEN OPENDATASOURCE
+		OPENQUERY OPENROWSET OPENXML OPTION OVER PERCENT PIVOT PLAN PRECISION PRINT PROC
+		PROCEDURE PUBLIC RAISERROR READ READTEXT RECONFIGURE REPLICATION RESTORE RESTRICT RETURN
+		REVERT REVOKE ROLLBACK ROWCOUNT ROWGUIDCOL RULE SAVE SCHEMA SECURITYAUDIT SEMANTICKEYPHRASETABLE
+		SETUSER SHUTDOWN STATISTICS SYSTEM_USER TABLESAMPLE TEXTSIZE TOP TRAN TRANSACTION
+		TRIGGER TRUNCATE TRY_CONVERT TSEQUAL UNPIVOT UPDATETEXT USE VARYING VIEW WAITFOR WHILE
+		WITHIN WRITETEXT`),
+	ORACLE: wordSet(`ACCESS AUDIT CHAR CLUSTER COMMENT COMPRESS CONNECT CURRENT DATE DECIMAL
+		EXCLUSIVE FILE FLOAT IDENTIFIED IMMEDIATE INCREMENT INITIAL INTEGER LEVEL LOCK LONG
+		MAXEXTENTS MINUS MLSLABEL MODE MODIFY NOAUDIT NOCOMPRESS NOWAIT NUMBER OFFLINE ONLINE
+		OPTION PCTFREE PRIOR PUBLIC RAW RENAME RESOURCE REVOKE ROW ROWS SESSION SHARE SIZE
+		SMALLINT START SUCCESSFUL SYNONYM UID VALIDATE VARCHAR VARCHAR2 VIEW WHENEVER`),
+}
+
+func wordSet(words string) map[string]bool {
+	set := make(map[string]bool)
+	for _, word := range strings.Fields(words) {
+		set[word] = true
+	}
+	return set
+}
+
+// QuotePolicy sets which identifiers are quoted by the builder, the quote characters and
+// reserved words depend on the dialect. Quoted identifiers are case sensitive on Postgres
+// and Oracle. Raw SQL of Expr, join conditions given as string, OrderBy, GroupBy and Having
+// is written as given.
+func (b *Builder) QuotePolicy(policy QuotePolicy) *Builder {
+	b.quotePolicy = policy
+	return b
+}
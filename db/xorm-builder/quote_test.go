@@ -0,0 +1,133 @@
+// Copyright 2016 The Xorm Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package builder
+
+import "testing"
+
+func TestQuoterQuote(t *testing.T) {
+	for _, tc := range []struct {
+		dialect string
+		policy  QuotePolicy
+		name    string
+		want    string
+	}{
+		{POSTGRES, QuotePolicyNone, "order", "order"},
+		{POSTGRES, QuotePolicyReserved, "order", `"order"`},
+		{POSTGRES, QuotePolicyReserved, "ORDER", `"ORDER"`},
+		{POSTGRES, QuotePolicyReserved, "name", "name"},
+		{POSTGRES, QuotePolicyAlways, "name", `"name"`},
+		{MYSQL, QuotePolicyReserved, "order", "`order`"},
+		{MSSQL, QuotePolicyReserved, "order", "[order]"},
+		{ORACLE, QuotePolicyReserved, "order", `"order"`},
+
+		// reserved words of a dialect only
+		{POSTGRES, QuotePolicyReserved, "limit", `"limit"`},
+		{MSSQL, QuotePolicyReserved, "limit", "limit"},
+		{ORACLE, QuotePolicyReserved, "level", `"level"`},
+		{MYSQL, QuotePolicyReserved, "level", "level"},
+		{MSSQL, QuotePolicyReserved, "merge", "[merge]"},
+
+		// qualified names and aliases
+		{POSTGRES, QuotePolicyReserved, "t.user", `t."user"`},
+		{POSTGRES, QuotePolicyAlways, "t.*", `"t".*`},
+		{POSTGRES, QuotePolicyAlways, "user u", `"user" "u"`},
+		{MYSQL, QuotePolicyReserved, "user AS order", "`user` AS `order`"},
+		{MSSQL, QuotePolicyAlways, "s.t.c", "[s].[t].[c]"},
+
+		// names which are never quoted
+		{POSTGRES, QuotePolicyAlways, "*", "*"},
+		{POSTGRES, QuotePolicyAlways, "NULL", "NULL"},
+		{POSTGRES, QuotePolicyAlways, "current_timestamp", "current_timestamp"},
+		{ORACLE, QuotePolicyAlways, "ROWNUM", "ROWNUM"},
+		{POSTGRES, QuotePolicyAlways, `"user"`, `"user"`},
+		{MSSQL, QuotePolicyAlways, "[user]", "[user]"},
+		{POSTGRES, QuotePolicyAlways, "COUNT(*)", "COUNT(*)"},
+		{POSTGRES, QuotePolicyAlways, "n+1", "n+1"},
+		{POSTGRES, QuotePolicyAlways, "1", "1"},
+		{POSTGRES, QuotePolicyAlways, "a b c", "a b c"},
+		{POSTGRES, QuotePolicyAlways, "", ""},
+	} {
+		if got := NewQuoter(tc.dialect, tc.policy).Quote(tc.name); got != tc.want {
+			t.Errorf("%s/%d: Quote(%q) = %q, want %q", tc.dialect, tc.policy, tc.name, got, tc.want)
+		}
+	}
+}
+
+func TestQuotePolicy(t *testing.T) {
+	dialectTest(t, "select", func(dialect string) *Builder {
+		return Dialect(dialect).QuotePolicy(QuotePolicyReserved).
+			Select("order", "t.user", "name").From("user t").
+			Where(Eq{"order": 1}.And(Like{"t.user", "a"})).
+			Join("INNER", "group", "group.id = t.id")
+	}, []string{
+		`SELECT "order",t."user",name FROM "user" t INNER JOIN "group" ON group.id = t.id WHERE "order"=$1 AND t."user" LIKE $2`,
+		`SELECT "order",t."user",name FROM "user" t INNER JOIN "group" ON group.id = t.id WHERE "order"=? AND t."user" LIKE ?`,
+		"SELECT `order`,t.`user`,name FROM `user` t INNER JOIN `group` ON group.id = t.id WHERE `order`=? AND t.`user` LIKE ?",
+		`SELECT [order],t.[user],name FROM [user] t INNER JOIN [group] ON group.id = t.id WHERE [order]=@p1 AND t.[user] LIKE @p2`,
+		`SELECT "order",t."user",name FROM "user" t INNER JOIN "group" ON group.id = t.id WHERE "order"=:p1 AND t."user" LIKE :p2`,
+	}, 1, "%a%")
+
+	dialectTest(t, "insert", func(dialect string) *Builder {
+		return Dialect(dialect).QuotePolicy(QuotePolicyAlways).Insert(Eq{"order": 1, "name": "x"}).Into("user")
+	}, []string{
+		`INSERT INTO "user" ("name","order") Values ($1,$2)`,
+		`INSERT INTO "user" ("name","order") Values (?,?)`,
+		"INSERT INTO `user` (`name`,`order`) Values (?,?)",
+		`INSERT INTO [user] ([name],[order]) Values (@p1,@p2)`,
+		`INSERT INTO "user" ("name","order") Values (:p1,:p2)`,
+	}, "x", 1)
+
+	dialectTest(t, "update", func(dialect string) *Builder {
+		return Dialect(dialect).QuotePolicy(QuotePolicyReserved).Update(Eq{"order": Incr(1)}).
+			From("user").Where(In("group", 1, 2))
+	}, []string{
+		`UPDATE "user" SET "order"="order"+$1 WHERE "group" IN ($2,$3)`,
+		`UPDATE "user" SET "order"="order"+? WHERE "group" IN (?,?)`,
+		"UPDATE `user` SET `order`=`order`+? WHERE `group` IN (?,?)",
+		`UPDATE [user] SET [order]=[order]+@p1 WHERE [group] IN (@p2,@p3)`,
+		`UPDATE "user" SET "order"="order"+:p1 WHERE "group" IN (:p2,:p3)`,
+	}, 1, 1, 2)
+
+	dialectTest(t, "delete", func(dialect string) *Builder {
+		return Dialect(dialect).QuotePolicy(QuotePolicyReserved).Delete(IsNull{"order"}).From("user")
+	}, []string{
+		`DELETE FROM "user" WHERE "order" IS NULL`,
+		`DELETE FROM "user" WHERE "order" IS NULL`,
+		"DELETE FROM `user` WHERE `order` IS NULL",
+		`DELETE FROM [user] WHERE [order] IS NULL`,
+		`DELETE FROM "user" WHERE "order" IS NULL`,
+	})
+}
+
+func TestQuotePolicyNested(t *testing.T) {
+	// nested builders are quoted by the policy of the outermost builder
+	sqlTest(t, "sub query", MySQL().QuotePolicy(QuotePolicyReserved).Select("order").
+		From(Select("order").From("user").Where(Eq{"key": 1}), "t"),
+		"SELECT `order` FROM (SELECT `order` FROM `user` WHERE `key`=?) t", 1)
+	sqlTest(t, "in", Postgres().QuotePolicy(QuotePolicyReserved).Select("id").From("user").
+		Where(In("group", Select("group").From("groups"))),
+		`SELECT id FROM "user" WHERE "group" IN (SELECT "group" FROM groups)`)
+	sqlTest(t, "join", MsSQL().QuotePolicy(QuotePolicyReserved).Select("u.id").From("user", "u").
+		Join("LEFT", As("order", "o"), Eq{"o.user": Expr("u.id")}),
+		`SELECT u.id FROM [user] u LEFT JOIN [order] o ON o.[user]=(u.id)`)
+	sqlTest(t, "union", SQLite().QuotePolicy(QuotePolicyReserved).Select("order").From("a").
+		Union("all", Select("order").From("b")),
+		`(SELECT "order" FROM a) UNION ALL (SELECT "order" FROM b)`)
+	sqlTest(t, "with", Postgres().QuotePolicy(QuotePolicyReserved).
+		With("user", Select("order").From("t"), "order").Select("order").From("user"),
+		`WITH "user" ("order") AS (SELECT "order" FROM t) SELECT "order" FROM "user"`)
+	sqlTest(t, "upsert", MySQL().QuotePolicy(QuotePolicyReserved).Insert(Eq{"key": 1, "order": 2}).
+		Into("t").OnConflict("key").DoUpdateColumns("order"),
+		"INSERT INTO t (`key`,`order`) Values (?,?) ON DUPLICATE KEY UPDATE `order`=VALUES(`order`)", 1, 2)
+	sqlTest(t, "merge", Oracle().QuotePolicy(QuotePolicyReserved).Insert(Eq{"level": 1, "n": 2}).
+		Into("t").OnConflict("level").DoUpdateColumns("n").Returning(),
+		`MERGE INTO t dst USING (SELECT :p1 AS "level",:p2 AS n FROM DUAL) src ON (dst."level"=src."level") `+
+			`WHEN MATCHED THEN UPDATE SET dst.n=src.n WHEN NOT MATCHED THEN INSERT ("level",n) VALUES (src."level",src.n)`, 1, 2)
+
+	// raw SQL is written as given
+	sqlTest(t, "raw", Postgres().QuotePolicy(QuotePolicyAlways).Select("id").From("t").
+		Where(Expr("order = ?", 1)).OrderBy("order DESC").GroupBy("user"),
+		`SELECT "id" FROM "t" WHERE order = $1 GROUP BY user ORDER BY order DESC`, 1)
+}
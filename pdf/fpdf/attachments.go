@@ -70,7 +70,7 @@ func (f *Fpdf) writeCompressedFileObject(content []byte, mimeType string) { // e
 
 	mod := timeOrNow(f.modDate)
 //	f.outf("<< /Type /EmbeddedFile /Length %d /Filter /FlateDecode /Params << /CheckSum <%s> /Size %d >> >>\n", lenCompressed, sum, lenUncompressed)
-	f.outf("<< /Type /EmbeddedFile /Subtype /%s /Length %d /Filter /FlateDecode /Params << /ModDate %s /CheckSum <%s> /Size %d >> >>", mimeType, lenCompressed, f.textstring("D:"+mod.Format("20060102150405")), sum, lenUncompressed) // fix by unixman to comply with PDF/A standards
+	f.outf("<< /Type /EmbeddedFile /Subtype /%s /Length %d /Filter /FlateDecode /Params << /ModDate %s /CheckSum <%s> /Size %d >> >>", mimeType, f.protect.length(lenCompressed), f.textstring("D:"+mod.Format("20060102150405")), sum, lenUncompressed) // fix by unixman to comply with PDF/A standards
 	//--
 	f.putstream(compressed)
 	f.out("endobj")
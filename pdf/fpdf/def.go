@@ -384,14 +384,21 @@ type FontLoader interface {
 // Pdf defines the interface used for various methods. It is implemented by the
 // main FPDF instance as well as templates.
 type Pdf interface {
+	AddCheckBox(name string, x, y, size float64, checked bool, flags int)
+	AddComboBox(name string, x, y, w, h float64, options []string, value string, flags int)
 	AddFont(familyStr, styleStr, fileStr string)
 	AddFontFromBytes(familyStr, styleStr string, jsonFileBytes, zFileBytes []byte)
 	AddFontFromReader(familyStr, styleStr string, r io.Reader)
 	AddLayer(name string, visible bool) (layerID int)
 	AddLink() int
+	AddListBox(name string, x, y, w, h float64, options []string, value string, flags int)
+	AddMultilineTextField(name string, x, y, w, h float64, value string, flags int)
 	AddPage()
 	AddPageFormat(orientationStr string, size SizeType)
+	AddPushButton(name, caption string, x, y, w, h float64, javascript string, flags int)
+	AddRadioButton(name, value string, x, y, size float64, selected bool, flags int)
 	AddSpotColor(nameStr string, c, m, y, k byte)
+	AddTextField(name string, x, y, w, h float64, value string, flags int)
 	AliasNbPages(aliasStr string)
 	ArcTo(x, y, rx, ry, degRotate, degStart, degEnd float64)
 	Arc(x, y, rx, ry, degRotate, degStart, degEnd float64, styleStr string)
@@ -503,6 +510,7 @@ type Pdf interface {
 	SetFontUnitSize(size float64)
 	SetFooterFunc(fnc func())
 	SetFooterFuncLpi(fnc func(lastPage bool))
+	SetFormTabOrder(orderStr string)
 	SetHeaderFunc(fnc func())
 	SetHeaderFuncMode(fnc func(), homeMode bool)
 	SetHomeXY()
@@ -518,6 +526,7 @@ type Pdf interface {
 	SetPageBox(t string, x, y, wd, ht float64)
 	SetPage(pageNum int)
 	SetProtection(actionFlag byte, userPassStr, ownerPassStr string)
+	SetProtectionAlgorithm(actionFlag byte, userPassStr, ownerPassStr, algorithmStr string)
 	SetRightMargin(margin float64)
 	SetSubject(subjectStr string, isUTF8 bool)
 	SetTextColor(r, g, b int)
@@ -671,6 +680,7 @@ type Fpdf struct {
 	err              error                      // Set if error occurs during life cycle of instance
 	protect          protectType                // document protection structure
 	layer            layerRecType               // manages optional layers in document
+	form             formRecType                // interactive form fields of the document
 	catalogSort      bool                       // sort resource catalogs in document
 	isFatalErr       bool                       // Fatal Error Semaphore (unixman)
 	fPage            int                        // First Page Profile Number (unixman)
@@ -698,7 +708,9 @@ const (
 	pdfVers1_3 = pdfVersion(uint16(1)<<8 | uint16(3))
 	pdfVers1_4 = pdfVersion(uint16(1)<<8 | uint16(4))
 	pdfVers1_5 = pdfVersion(uint16(1)<<8 | uint16(5))
+	pdfVers1_6 = pdfVersion(uint16(1)<<8 | uint16(6))
 	pdfVers1_7 = pdfVersion(uint16(1)<<8 | uint16(7)) // by unixman
+	pdfVers2_0 = pdfVersion(uint16(2)<<8 | uint16(0))
 )
 
 type pdfVersion uint16
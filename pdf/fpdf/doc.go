@@ -38,7 +38,8 @@ support for text, drawing and images.
 
 -   Clipping
 
--   Document protection
+-   Document protection (RC4, AES-128 and AES-256)
+-   Interactive form fields
 
 -   Layers
 
@@ -0,0 +1,592 @@
+// Copyright ©2023 The go-pdf Authors. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package fpdf
+
+import (
+	"math"
+	"strings"
+)
+
+// Flags of form fields, they can be combined by or-ing them together
+const (
+	// FieldReadOnly prevents the user from changing the value of the field
+	FieldReadOnly = 1 << 0
+	// FieldRequired requires a value for the field when the form is submitted
+	FieldRequired = 1 << 1
+	// FieldNoExport excludes the field from the submitted form
+	FieldNoExport = 1 << 2
+)
+
+// Flags of form fields set by the type of the field
+const (
+	fieldMultiline     = 1 << 12
+	fieldNoToggleToOff = 1 << 14
+	fieldRadio         = 1 << 15
+	fieldPushButton    = 1 << 16
+	fieldCombo         = 1 << 17
+)
+
+const (
+	formText = iota
+	formCheckBox
+	formRadio
+	formChoice
+	formPushButton
+)
+
+type formWidgetType struct {
+	page       int
+	x, y, w, h float64 // bottom left corner and size, in points
+	value      string  // export value of a radio button
+	objNum     int
+	apOn       int // object number of the appearance, checked for a button
+	apOff      int // object number of the appearance of an unchecked button
+}
+
+type formFieldType struct {
+	kind       int
+	name       string
+	value      string
+	options    []string
+	flags      int
+	javascript string
+	fontSize   float64 // in points
+	text, draw colorType
+	widgets    []*formWidgetType
+	objNum     int
+}
+
+type formRecType struct {
+	fields   []*formFieldType
+	names    map[string]*formFieldType
+	widgets  map[int][]*formWidgetType // widgets of each page, in tab order
+	tabOrder string
+	helvObj  int // object number of the font of text fields
+	zadbObj  int // object number of the font of check marks
+}
+
+// helveticaWidths are the glyph widths of Helvetica in WinAnsiEncoding
+var helveticaWidths = [256]int{
+	278, 278, 278, 278, 278, 278, 278, 278, 278, 278, 278, 278, 278, 278, 278, 278,
+	278, 278, 278, 278, 278, 278, 278, 278, 278, 278, 278, 278, 278, 278, 278, 278,
+	278, 278, 355, 556, 556, 889, 667, 191, 333, 333, 389, 584, 278, 333, 278, 278,
+	556, 556, 556, 556, 556, 556, 556, 556, 556, 556, 278, 278, 584, 584, 584, 556,
+	1015, 667, 667, 722, 722, 667, 611, 778, 722, 278, 500, 667, 556, 833, 722, 778,
+	667, 778, 722, 667, 611, 722, 667, 944, 667, 667, 611, 278, 278, 278, 469, 556,
+	333, 556, 556, 500, 556, 556, 278, 556, 556, 222, 222, 500, 222, 833, 556, 556,
+	556, 556, 333, 500, 278, 556, 500, 722, 500, 500, 500, 334, 260, 334, 584, 350,
+	556, 350, 222, 556, 333, 1000, 556, 556, 333, 1000, 667, 333, 1000, 350, 611, 350,
+	350, 222, 222, 333, 333, 350, 556, 1000, 333, 1000, 500, 333, 944, 350, 500, 667,
+	278, 333, 556, 556, 556, 556, 260, 556, 333, 737, 370, 556, 584, 333, 737, 333,
+	400, 584, 333, 333, 333, 556, 537, 278, 333, 333, 365, 556, 834, 834, 834, 611,
+	667, 667, 667, 667, 667, 667, 1000, 722, 667, 667, 667, 667, 278, 278, 278, 278,
+	722, 722, 778, 778, 778, 778, 778, 584, 778, 722, 722, 722, 722, 667, 667, 611,
+	556, 556, 556, 556, 556, 556, 889, 500, 556, 556, 556, 556, 278, 278, 278, 278,
+	556, 556, 556, 556, 556, 556, 556, 584, 611, 556, 556, 556, 556, 500, 556, 500,
+}
+
+// winAnsiRunes are the runes of WinAnsiEncoding which are not ASCII
+var winAnsiRunes = func() map[rune]byte {
+	m := map[rune]byte{
+		0x20AC: 0x80, 0x201A: 0x82, 0x0192: 0x83, 0x201E: 0x84, 0x2026: 0x85,
+		0x2020: 0x86, 0x2021: 0x87, 0x02C6: 0x88, 0x2030: 0x89, 0x0160: 0x8A,
+		0x2039: 0x8B, 0x0152: 0x8C, 0x017D: 0x8E, 0x2018: 0x91, 0x2019: 0x92,
+		0x201C: 0x93, 0x201D: 0x94, 0x2022: 0x95, 0x2013: 0x96, 0x2014: 0x97,
+		0x02DC: 0x98, 0x2122: 0x99, 0x0161: 0x9A, 0x203A: 0x9B, 0x0153: 0x9C,
+		0x017E: 0x9E, 0x0178: 0x9F,
+	}
+	for r := rune(0xA0); r <= 0xFF; r++ {
+		m[r] = byte(r)
+	}
+	return m
+}()
+
+// winAnsi translates the text of the appearance of a field to
+// WinAnsiEncoding, the runes out of it are replaced by a question mark
+func winAnsi(s string) string {
+	b := make([]byte, 0, len(s))
+	for _, r := range s {
+		switch ch, ok := winAnsiRunes[r]; {
+		case r < 0x80:
+			b = append(b, byte(r))
+		case ok:
+			b = append(b, ch)
+		default:
+			b = append(b, '?')
+		}
+	}
+	return string(b)
+}
+
+// helveticaWidth returns the width of s, in WinAnsiEncoding, in Helvetica of
+// size points
+func helveticaWidth(s string, size float64) float64 {
+	var w int
+	for i := 0; i < len(s); i++ {
+		w += helveticaWidths[s[i]]
+	}
+	return float64(w) * size / 1000
+}
+
+// addFormField registers a field with a widget on the current page at (x, y)
+// of size w by h in user units. It returns nil if the field cannot be added.
+func (f *Fpdf) addFormField(fld *formFieldType, x, y, w, h float64) *formWidgetType {
+	if f.err != nil {
+		return nil
+	}
+	if f.page < 1 {
+		f.SetErrorf("form field \"%s\" added before the first page", fld.name)
+		return nil
+	}
+	if fld.name == "" || strings.Contains(fld.name, ".") {
+		f.SetErrorf("invalid form field name \"%s\"", fld.name)
+		return nil
+	}
+	if f.form.names == nil {
+		f.form.names = make(map[string]*formFieldType)
+		f.form.widgets = make(map[int][]*formWidgetType)
+	}
+	if _, ok := f.form.names[fld.name]; ok {
+		f.SetErrorf("form field \"%s\" already exists", fld.name)
+		return nil
+	}
+	fld.fontSize = f.fontSizePt
+	fld.text = f.color.text
+	fld.draw = f.color.draw
+	f.form.fields = append(f.form.fields, fld)
+	f.form.names[fld.name] = fld
+	return f.addFormWidget(fld, x, y, w, h)
+}
+
+func (f *Fpdf) addFormWidget(fld *formFieldType, x, y, w, h float64) *formWidgetType {
+	wdg := &formWidgetType{page: f.page, x: x * f.k, y: f.hPt - (y+h)*f.k, w: w * f.k, h: h * f.k}
+	fld.widgets = append(fld.widgets, wdg)
+	f.form.widgets[f.page] = append(f.form.widgets[f.page], wdg)
+	return wdg
+}
+
+// AddTextField puts a single line text field on the current page, on the
+// rectangle defined by x, y, w and h. name identifies the field in the
+// document, it must be unique and must not contain a period. value is the
+// initial text of the field. flags combines FieldReadOnly, FieldRequired and
+// FieldNoExport.
+//
+// The text of the field is displayed with Helvetica at the current font size
+// and in the current text color. The border of the field is drawn in the
+// current draw color. Fields are visited in the order they are added, see
+// SetFormTabOrder() to change it. See the AddTextField example for a
+// demonstration of this method and the other form fields.
+func (f *Fpdf) AddTextField(name string, x, y, w, h float64, value string, flags int) {
+	f.addFormField(&formFieldType{kind: formText, name: name, value: value, flags: flags}, x, y, w, h)
+}
+
+// AddMultilineTextField puts a text field of several lines on the current
+// page. The lines of value are separated by "\n", and wrapped to the width of
+// the field. See AddTextField() for the other arguments.
+func (f *Fpdf) AddMultilineTextField(name string, x, y, w, h float64, value string, flags int) {
+	f.addFormField(&formFieldType{kind: formText, name: name, value: value, flags: flags | fieldMultiline}, x, y, w, h)
+}
+
+// AddCheckBox puts a check box of size by size user units on the current page
+// at (x, y), the upper left corner of the box. The check box exports the value
+// "Yes" when it is checked. See AddTextField() for name and flags.
+func (f *Fpdf) AddCheckBox(name string, x, y, size float64, checked bool, flags int) {
+	fld := &formFieldType{kind: formCheckBox, name: name, flags: flags}
+	if checked {
+		fld.value = "Yes"
+	}
+	f.addFormField(fld, x, y, size, size)
+}
+
+// AddRadioButton puts a radio button of size by size user units on the current
+// page at (x, y). The radio buttons with the same name form a group, of which
+// a single button is selected, and may be on several pages. value is the
+// value exported by the group when the button is selected, it must be unique
+// in the group. selected sets the button initially selected. The flags of a
+// group are those of its first button. See AddTextField() for the other
+// arguments.
+func (f *Fpdf) AddRadioButton(name, value string, x, y, size float64, selected bool, flags int) {
+	if f.err != nil {
+		return
+	}
+	if value == "" {
+		f.SetErrorf("radio button of \"%s\" without value", name)
+		return
+	}
+	fld, ok := f.form.names[name]
+	if !ok {
+		wdg := f.addFormField(&formFieldType{kind: formRadio, name: name, flags: flags | fieldRadio | fieldNoToggleToOff}, x, y, size, size)
+		if wdg != nil {
+			wdg.value = value
+			if selected {
+				f.form.names[name].value = value
+			}
+		}
+		return
+	}
+	if fld.kind != formRadio {
+		f.SetErrorf("form field \"%s\" already exists", name)
+		return
+	}
+	for _, wdg := range fld.widgets {
+		if wdg.value == value {
+			f.SetErrorf("radio button \"%s\" of \"%s\" already exists", value, name)
+			return
+		}
+	}
+	if f.page < 1 {
+		f.SetErrorf("form field \"%s\" added before the first page", name)
+		return
+	}
+	f.addFormWidget(fld, x, y, size, size).value = value
+	if selected {
+		fld.value = value
+	}
+}
+
+// AddComboBox puts a drop-down list of options on the current page. value is
+// the option initially selected, or an empty string for none. See
+// AddTextField() for the other arguments.
+func (f *Fpdf) AddComboBox(name string, x, y, w, h float64, options []string, value string, flags int) {
+	f.addChoiceField(name, x, y, w, h, options, value, flags|fieldCombo)
+}
+
+// AddListBox puts a scrollable list of options on the current page. value is
+// the option initially selected, or an empty string for none. See
+// AddTextField() for the other arguments.
+func (f *Fpdf) AddListBox(name string, x, y, w, h float64, options []string, value string, flags int) {
+	f.addChoiceField(name, x, y, w, h, options, value, flags)
+}
+
+func (f *Fpdf) addChoiceField(name string, x, y, w, h float64, options []string, value string, flags int) {
+	if f.err != nil {
+		return
+	}
+	if value != "" && optionIndex(options, value) < 0 {
+		f.SetErrorf("value \"%s\" of form field \"%s\" is not an option", value, name)
+		return
+	}
+	fld := &formFieldType{kind: formChoice, name: name, value: value, flags: flags}
+	fld.options = append(fld.options, options...)
+	f.addFormField(fld, x, y, w, h)
+}
+
+func optionIndex(options []string, value string) int {
+	for j, opt := range options {
+		if opt == value {
+			return j
+		}
+	}
+	return -1
+}
+
+// AddPushButton puts a button labelled with caption on the current page.
+// javascript is the code run when the button is pressed, for instance
+// "this.print();" or a call to this.submitForm() to return the filled form;
+// it may be empty. See AddTextField() for the other arguments.
+func (f *Fpdf) AddPushButton(name, caption string, x, y, w, h float64, javascript string, flags int) {
+	f.addFormField(&formFieldType{kind: formPushButton, name: name, value: caption, javascript: javascript, flags: flags | fieldPushButton}, x, y, w, h)
+}
+
+// SetFormTabOrder defines the order in which the form fields of each page are
+// visited with the tab key. orderStr is "R" for rows, "C" for columns, "S" for
+// the structure of the document, or an empty string for the order in which the
+// fields are added, which is the default.
+func (f *Fpdf) SetFormTabOrder(orderStr string) {
+	switch orderStr {
+	case "":
+	case "R", "C", "S":
+		if f.pdfVersion < pdfVers1_5 {
+			f.pdfVersion = pdfVers1_5
+		}
+	default:
+		f.SetErrorf("invalid form tab order \"%s\"", orderStr)
+		return
+	}
+	f.form.tabOrder = orderStr
+}
+
+// formTextString returns a text string, encoded in UTF-16 if it is not ASCII
+func (f *Fpdf) formTextString(s string) string {
+	for _, r := range s {
+		if r >= 0x80 {
+			return f.textstring(utf8toutf16(s))
+		}
+	}
+	return f.textstring(s)
+}
+
+// formName returns a name object
+func (f *Fpdf) formName(s string) string {
+	return "/" + f.escapeSmarter(s)
+}
+
+// formNumberObjects assigns the numbers of the objects of the form, which
+// follow object n
+func (f *Fpdf) formNumberObjects(n int) {
+	if len(f.form.fields) == 0 {
+		return
+	}
+	f.form.helvObj, f.form.zadbObj = n+1, n+2
+	n += 2
+	for _, fld := range f.form.fields {
+		if fld.kind == formRadio {
+			n++
+			fld.objNum = n
+		}
+		for _, wdg := range fld.widgets {
+			// a field with a single widget is merged with it
+			n++
+			wdg.objNum = n
+			if fld.kind != formRadio {
+				fld.objNum = n
+			}
+			n++
+			wdg.apOn = n
+			if fld.kind == formCheckBox || fld.kind == formRadio {
+				n++
+				wdg.apOff = n
+			}
+		}
+	}
+}
+
+// formPutAnnots adds the widgets of page n to its annotations
+func (f *Fpdf) formPutAnnots(annots *fmtBuffer, n int) {
+	for _, wdg := range f.form.widgets[n] {
+		annots.printf("%d 0 R ", wdg.objNum)
+	}
+}
+
+// formPutObjects writes the fields, their widgets and appearances, with the
+// numbers assigned by formNumberObjects. pages are the object numbers of the
+// pages.
+func (f *Fpdf) formPutObjects(pages []int) {
+	if len(f.form.fields) == 0 {
+		return
+	}
+	f.newobj()
+	f.out("<</Type /Font /Subtype /Type1 /BaseFont /Helvetica /Encoding /WinAnsiEncoding>>")
+	f.out("endobj")
+	f.newobj()
+	f.out("<</Type /Font /Subtype /Type1 /BaseFont /ZapfDingbats>>")
+	f.out("endobj")
+	for _, fld := range f.form.fields {
+		if fld.kind == formRadio {
+			f.newobj()
+			var kids fmtBuffer
+			for _, wdg := range fld.widgets {
+				kids.printf("%d 0 R ", wdg.objNum)
+			}
+			value := "/Off"
+			if fld.value != "" {
+				value = f.formName(fld.value)
+			}
+			f.outf("<</FT /Btn /T %s /Ff %d /V %s /Kids [%s]>>",
+				f.formTextString(fld.name), fld.flags, value, strings.TrimSpace(kids.String()))
+			f.out("endobj")
+		}
+		for _, wdg := range fld.widgets {
+			f.newobj()
+			if f.n != wdg.objNum {
+				f.SetErrorf("form field \"%s\" written as object %d instead of %d", fld.name, f.n, wdg.objNum)
+				return
+			}
+			f.out("<</Type /Annot /Subtype /Widget")
+			f.outf("/Rect [%.2f %.2f %.2f %.2f] /F 4 /P %d 0 R", wdg.x, wdg.y, wdg.x+wdg.w, wdg.y+wdg.h, pages[wdg.page])
+			f.formPutField(fld, wdg)
+			f.out(">>")
+			f.out("endobj")
+			f.formPutAppearances(fld, wdg)
+		}
+	}
+}
+
+// formColor returns the components of clr for an array or an operator
+func formColor(clr colorType) string {
+	return sprintf("%.3f %.3f %.3f", clr.r, clr.g, clr.b)
+}
+
+// formPutField writes the entries of a field and of its widget
+func (f *Fpdf) formPutField(fld *formFieldType, wdg *formWidgetType) {
+	if fld.kind == formRadio {
+		f.outf("/Parent %d 0 R", fld.objNum)
+	} else {
+		f.outf("/T %s /Ff %d", f.formTextString(fld.name), fld.flags)
+	}
+	mk := "/BC [" + formColor(fld.draw) + "]"
+	switch fld.kind {
+	case formText:
+		f.out("/FT /Tx")
+		if fld.value != "" {
+			f.outf("/V %s", f.formTextString(fld.value))
+		}
+	case formCheckBox:
+		f.out("/FT /Btn")
+		state := "/Off"
+		if fld.value != "" {
+			state = "/Yes"
+		}
+		f.outf("/V %s /AS %s", state, state)
+		mk += " /CA " + f.textstring("4")
+	case formRadio:
+		state := "/Off"
+		if fld.value == wdg.value {
+			state = f.formName(wdg.value)
+		}
+		f.outf("/AS %s", state)
+		mk += " /CA " + f.textstring("l")
+	case formChoice:
+		f.out("/FT /Ch")
+		var opts fmtBuffer
+		for _, opt := range fld.options {
+			opts.printf("%s ", f.formTextString(opt))
+		}
+		f.outf("/Opt [%s]", strings.TrimSpace(opts.String()))
+		if fld.value != "" {
+			f.outf("/V %s", f.formTextString(fld.value))
+			if fld.flags&fieldCombo == 0 {
+				f.outf("/I [%d]", optionIndex(fld.options, fld.value))
+			}
+		}
+	case formPushButton:
+		f.out("/FT /Btn")
+		mk += " /BG [0.753 0.753 0.753] /CA " + f.formTextString(fld.value)
+		if fld.javascript != "" {
+			f.outf("/A <</S /JavaScript /JS %s>>", f.formTextString(fld.javascript))
+		}
+	}
+	f.outf("/MK <<%s>>", mk)
+	if fld.kind == formCheckBox || fld.kind == formRadio {
+		f.outf("/DA %s", f.textstring("/ZaDb 0 Tf "+formColor(fld.text)+" rg"))
+		f.outf("/AP <</N <<%s %d 0 R /Off %d 0 R>>>>", strIf(fld.kind == formRadio, f.formName(wdg.value), "/Yes"), wdg.apOn, wdg.apOff)
+	} else {
+		f.outf("/DA %s", f.textstring(sprintf("/Helv %.2f Tf %s rg", fld.fontSize, formColor(fld.text))))
+		f.outf("/AP <</N %d 0 R>>", wdg.apOn)
+	}
+}
+
+// formPutAppearances writes the appearances of a widget
+func (f *Fpdf) formPutAppearances(fld *formFieldType, wdg *formWidgetType) {
+	var border fmtBuffer
+	border.printf("%s RG 1 w ", formColor(fld.draw))
+	if fld.kind == formRadio {
+		border.printf("%s S\n", circlePath(wdg.w/2, wdg.h/2, wdg.w/2-0.5))
+	} else {
+		border.printf("0.5 0.5 %.2f %.2f re S\n", wdg.w-1, wdg.h-1)
+	}
+
+	var ap fmtBuffer
+	switch fld.kind {
+	case formCheckBox, formRadio:
+		glyph, width := "4", 0.846
+		if fld.kind == formRadio {
+			glyph, width = "l", 0.791
+		}
+		size := wdg.h * 0.7
+		ap.printf("%sq BT /ZaDb %.2f Tf %s rg %.2f %.2f Td (%s) Tj ET Q\n", border.String(), size, formColor(fld.text),
+			(wdg.w-width*size)/2, (wdg.h-0.7*size)/2, glyph)
+		f.formPutAppearance(wdg, "/ZaDb", f.form.zadbObj, ap.String())
+		f.formPutAppearance(wdg, "/ZaDb", f.form.zadbObj, border.String())
+		return
+	case formPushButton:
+		caption := winAnsi(fld.value)
+		ap.printf("0.753 g 0 0 %.2f %.2f re f\n%s", wdg.w, wdg.h, border.String())
+		ap.printf("BT /Helv %.2f Tf %s rg %.2f %.2f Td (%s) Tj ET\n", fld.fontSize, formColor(fld.text),
+			(wdg.w-helveticaWidth(caption, fld.fontSize))/2, (wdg.h-fld.fontSize)/2+0.22*fld.fontSize, f.escape(caption))
+	default:
+		ap.printf("%s/Tx BMC\nq 1 1 %.2f %.2f re W n\n", border.String(), wdg.w-2, wdg.h-2)
+		f.formPutText(&ap, fld, wdg)
+		ap.printf("Q\nEMC\n")
+	}
+	f.formPutAppearance(wdg, "/Helv", f.form.helvObj, ap.String())
+}
+
+// formPutText writes the text of a text or choice field in its appearance
+func (f *Fpdf) formPutText(ap *fmtBuffer, fld *formFieldType, wdg *formWidgetType) {
+	size := fld.fontSize
+	leading := size * 1.15
+	var lines []string
+	top := wdg.h - 2 - 0.8*size
+	switch {
+	case fld.kind == formChoice && fld.flags&fieldCombo == 0:
+		for j, opt := range fld.options {
+			if opt == fld.value {
+				ap.printf("0.600 0.757 0.855 rg 1 %.2f %.2f %.2f re f\n", wdg.h-1-float64(j+1)*leading, wdg.w-2, leading)
+			}
+			lines = append(lines, winAnsi(opt))
+		}
+	case fld.flags&fieldMultiline != 0:
+		for _, line := range strings.Split(winAnsi(fld.value), "\n") {
+			lines = append(lines, wrapHelvetica(line, size, wdg.w-4)...)
+		}
+	default:
+		lines = []string{winAnsi(fld.value)}
+		top = (wdg.h-size)/2 + 0.22*size
+	}
+	if len(lines) == 0 || (len(lines) == 1 && lines[0] == "") {
+		return
+	}
+	ap.printf("BT /Helv %.2f Tf %s rg %.2f TL 2 %.2f Td\n", size, formColor(fld.text), leading, top)
+	for j, line := range lines {
+		if j > 0 {
+			ap.printf("T* ")
+		}
+		ap.printf("(%s) Tj\n", f.escape(line))
+	}
+	ap.printf("ET\n")
+}
+
+// wrapHelvetica splits a line of text in WinAnsiEncoding into lines no wider
+// than width points in Helvetica of size points
+func wrapHelvetica(line string, size, width float64) []string {
+	words := strings.Split(line, " ")
+	var lines []string
+	cur := words[0]
+	for _, word := range words[1:] {
+		if helveticaWidth(cur+" "+word, size) > width {
+			lines = append(lines, cur)
+			cur = word
+		} else {
+			cur += " " + word
+		}
+	}
+	return append(lines, cur)
+}
+
+// circlePath returns the path of a circle of radius r centered at (x, y)
+func circlePath(x, y, r float64) string {
+	k := r * 4 * (math.Sqrt2 - 1) / 3
+	return sprintf("%.2f %.2f m %.2f %.2f %.2f %.2f %.2f %.2f c %.2f %.2f %.2f %.2f %.2f %.2f c "+
+		"%.2f %.2f %.2f %.2f %.2f %.2f c %.2f %.2f %.2f %.2f %.2f %.2f c",
+		x+r, y,
+		x+r, y+k, x+k, y+r, x, y+r,
+		x-k, y+r, x-r, y+k, x-r, y,
+		x-r, y-k, x-k, y-r, x, y-r,
+		x+k, y-r, x+r, y-k, x+r, y)
+}
+
+// formPutAppearance writes an appearance of a widget, which uses the font obj
+// as fontName
+func (f *Fpdf) formPutAppearance(wdg *formWidgetType, fontName string, obj int, ap string) {
+	f.newobj()
+	f.outf("<</Type /XObject /Subtype /Form /BBox [0 0 %.2f %.2f] /Resources <</Font <<%s %d 0 R>>>> /Length %d>>",
+		wdg.w, wdg.h, fontName, obj, f.protect.length(len(ap)))
+	f.putstream([]byte(ap))
+	f.out("endobj")
+}
+
+// formPutCatalog writes the interactive form dictionary of the catalog
+func (f *Fpdf) formPutCatalog() {
+	if len(f.form.fields) == 0 {
+		return
+	}
+	var fields fmtBuffer
+	for _, fld := range f.form.fields {
+		fields.printf("%d 0 R ", fld.objNum)
+	}
+	f.outf("/AcroForm <</Fields [%s] /DR <</Font <</Helv %d 0 R /ZaDb %d 0 R>>>> /DA %s>>",
+		strings.TrimSpace(fields.String()), f.form.helvObj, f.form.zadbObj, f.textstring("/Helv 0 Tf 0 g"))
+}
@@ -3806,6 +3806,9 @@ func (f *Fpdf) SetXY(x, y float64) {
 // full access to the document regardless of the actionFlag value. An empty
 // string for this argument will be replaced with a random value, effectively
 // prohibiting full access to the document.
+//
+// The document is encrypted with 40-bit RC4, which is weak; use
+// SetProtectionAlgorithm to encrypt it with AES.
 func (f *Fpdf) SetProtection(actionFlag byte, userPassStr, ownerPassStr string) {
 	if f.err != nil {
 		return
@@ -3813,6 +3816,33 @@ func (f *Fpdf) SetProtection(actionFlag byte, userPassStr, ownerPassStr string)
 	f.protect.setProtection(actionFlag, userPassStr, ownerPassStr)
 }
 
+// SetProtectionAlgorithm applies the same constraints as SetProtection, with
+// the encryption algorithm specified by algorithmStr: ProtectionRC4, the one of
+// SetProtection, ProtectionAES128 or ProtectionAES256. The PDF version of the
+// document is raised to 1.6 for AES-128 and to 2.0 for AES-256.
+//
+// With AES-256 the passwords are encoded as UTF-8 and truncated to 127 bytes.
+func (f *Fpdf) SetProtectionAlgorithm(actionFlag byte, userPassStr, ownerPassStr, algorithmStr string) {
+	if f.err != nil {
+		return
+	}
+	switch algorithmStr {
+	case ProtectionRC4:
+	case ProtectionAES128:
+		if f.pdfVersion < pdfVers1_6 {
+			f.pdfVersion = pdfVers1_6
+		}
+	case ProtectionAES256:
+		if f.pdfVersion < pdfVers2_0 {
+			f.pdfVersion = pdfVers2_0
+		}
+	default:
+		f.SetErrorf("unsupported protection algorithm: \"%s\"", algorithmStr)
+		return
+	}
+	f.protect.setProtectionAlgorithm(actionFlag, userPassStr, ownerPassStr, algorithmStr)
+}
+
 // OutputAndClose sends the PDF document to the writer specified by w. This
 // method will close both f and w, even if an error is detected and no document
 // is produced.
@@ -3987,9 +4017,7 @@ func (f *Fpdf) escapeSmarter(s string) string {
 // textstring formats a text string
 func (f *Fpdf) textstring(s string) string {
 	if f.protect.encrypted {
-		b := []byte(s)
-		f.protect.rc4(uint32(f.n), &b)
-		s = string(b)
+		s = string(f.protect.encrypt(uint32(f.n), []byte(s)))
 	}
 	return "(" + f.escape(s) + ")"
 }
@@ -4116,7 +4144,7 @@ func (f *Fpdf) newobj() {
 func (f *Fpdf) putstream(b []byte) {
 	// dbg("putstream")
 	if f.protect.encrypted {
-		f.protect.rc4(uint32(f.n), &b)
+		b = f.protect.encrypt(uint32(f.n), b)
 	}
 	f.out("stream")
 	f.out(string(b))
@@ -4288,6 +4316,8 @@ func (f *Fpdf) putpages() {
 		hPt = f.defPageSize.Wd * f.k
 	}
 	pagesObjectNumbers := make([]int, nb+1) // 1-based
+	// form fields follow the page and content objects of the pages
+	f.formNumberObjects(f.n + 2*nb)
 	for n := 1; n <= nb; n++ {
 		// Page
 		f.newobj()
@@ -4303,7 +4333,7 @@ func (f *Fpdf) putpages() {
 		}
 		f.out("/Resources 2 0 R")
 		// Links
-		if len(f.pageLinks[n])+len(f.pageAttachments[n]) > 0 {
+		if len(f.pageLinks[n])+len(f.pageAttachments[n])+len(f.form.widgets[n]) > 0 {
 			var annots fmtBuffer
 			annots.printf("/Annots [")
 			for _, pl := range f.pageLinks[n] {
@@ -4326,9 +4356,13 @@ func (f *Fpdf) putpages() {
 				}
 			}
 			f.putAttachmentAnnotationLinks(&annots, n)
+			f.formPutAnnots(&annots, n)
 			annots.printf("]")
 			f.out(annots.String())
 		}
+		if f.form.tabOrder != "" && len(f.form.widgets[n]) > 0 {
+			f.outf("/Tabs /%s", f.form.tabOrder)
+		}
 		if f.pdfVersion > pdfVers1_3 {
 			f.out("/Group <</Type /Group /S /Transparency /CS /" + colorSpaceRGB + ">>")
 		}
@@ -4344,15 +4378,16 @@ func (f *Fpdf) putpages() {
 		if f.compress {
 			mem := xmem.compress(f.pages[n].Bytes())
 			data := mem.bytes()
-			f.outf("<</Filter /FlateDecode /Length %d>>", len(data))
+			f.outf("<</Filter /FlateDecode /Length %d>>", f.protect.length(len(data)))
 			f.putstream(data)
 			mem.release()
 		} else {
-			f.outf("<</Length %d>>", f.pages[n].Len())
+			f.outf("<</Length %d>>", f.protect.length(f.pages[n].Len()))
 			f.putstream(f.pages[n].Bytes())
 		}
 		f.out("endobj")
 	}
+	f.formPutObjects(pagesObjectNumbers)
 	// Pages root
 	f.offsets[1] = f.buffer.Len()
 	f.out("1 0 obj")
@@ -4416,7 +4451,7 @@ func (f *Fpdf) putfonts() {
 					buf = append(buf, font[6+info.length1+6:info.length2]...)
 					font = buf
 				}
-				f.outf("<</Length %d", len(font))
+				f.outf("<</Length %d", f.protect.length(len(font)))
 				if compressed {
 					f.out("/Filter /FlateDecode")
 				}
@@ -4530,7 +4565,7 @@ func (f *Fpdf) putfonts() {
 				f.out("endobj")
 
 				f.newobj()
-				f.out("<</Length " + strconv.Itoa(len(toUnicode)) + ">>")
+				f.out("<</Length " + strconv.Itoa(f.protect.length(len(toUnicode))) + ">>")
 				f.putstream([]byte(toUnicode))
 				f.out("endobj")
 
@@ -4570,7 +4605,7 @@ func (f *Fpdf) putfonts() {
 				mem := xmem.compress(cidToGidMap)
 				cidToGidMap = mem.bytes()
 				f.newobj()
-				f.out("<</Length " + strconv.Itoa(len(cidToGidMap)) + "/Filter /FlateDecode>>")
+				f.out("<</Length " + strconv.Itoa(f.protect.length(len(cidToGidMap))) + "/Filter /FlateDecode>>")
 				f.putstream(cidToGidMap)
 				f.out("endobj")
 				mem.release()
@@ -4579,7 +4614,7 @@ func (f *Fpdf) putfonts() {
 				mem = xmem.compress(utf8FontStream)
 				compressedFontStream := mem.bytes()
 				f.newobj()
-				f.out("<</Length " + strconv.Itoa(len(compressedFontStream)))
+				f.out("<</Length " + strconv.Itoa(f.protect.length(len(compressedFontStream))))
 				f.out("/Filter /FlateDecode")
 				f.out("/Length1 " + strconv.Itoa(utf8FontSize))
 				f.out(">>")
@@ -4812,7 +4847,7 @@ func (f *Fpdf) putimage(info *ImageInfoType) {
 	if info.smask != nil {
 		f.outf("/SMask %d 0 R", f.n+1)
 	}
-	f.outf("/Length %d>>", len(info.data))
+	f.outf("/Length %d>>", f.protect.length(len(info.data)))
 	f.putstream(info.data)
 	f.out("endobj")
 	// 	Soft mask
@@ -4835,11 +4870,11 @@ func (f *Fpdf) putimage(info *ImageInfoType) {
 		if f.compress {
 			mem := xmem.compress(info.pal)
 			pal := mem.bytes()
-			f.outf("<</Filter /FlateDecode /Length %d>>", len(pal))
+			f.outf("<</Filter /FlateDecode /Length %d>>", f.protect.length(len(pal)))
 			f.putstream(pal)
 			mem.release()
 		} else {
-			f.outf("<</Length %d>>", len(info.pal))
+			f.outf("<</Length %d>>", f.protect.length(len(info.pal)))
 			f.putstream(info.pal)
 		}
 		f.out("endobj")
@@ -5011,8 +5046,28 @@ func (f *Fpdf) putresources() {
 		f.protect.objNum = f.n
 		f.out("<<")
 		f.out("/Filter /Standard")
-		f.out("/V 1")
-		f.out("/R 2")
+		switch f.protect.algorithm {
+		case ProtectionAES128:
+			f.out("/V 4")
+			f.out("/R 4")
+			f.out("/Length 128")
+			f.out("/CF << /StdCF << /CFM /AESV2 /AuthEvent /DocOpen /Length 16 >> >>")
+			f.out("/StmF /StdCF")
+			f.out("/StrF /StdCF")
+		case ProtectionAES256:
+			f.out("/V 5")
+			f.out("/R 6")
+			f.out("/Length 256")
+			f.out("/CF << /StdCF << /CFM /AESV3 /AuthEvent /DocOpen /Length 32 >> >>")
+			f.out("/StmF /StdCF")
+			f.out("/StrF /StdCF")
+			f.outf("/OE (%s)", f.escape(string(f.protect.oeValue)))
+			f.outf("/UE (%s)", f.escape(string(f.protect.ueValue)))
+			f.outf("/Perms (%s)", f.escape(string(f.protect.permsValue)))
+		default:
+			f.out("/V 1")
+			f.out("/R 2")
+		}
 		f.outf("/O (%s)", f.escape(string(f.protect.oValue)))
 		f.outf("/U (%s)", f.escape(string(f.protect.uValue)))
 		f.outf("/P %d", f.protect.pValue)
@@ -5125,6 +5180,8 @@ func (f *Fpdf) putcatalog() {
 	//--
 	// Layers
 	f.layerPutCatalog()
+	// Form fields
+	f.formPutCatalog()
 	//-- PDF/A AF Entry
 	theAFEntry := f.getAFEntries()
 	if(theAFEntry != "") {
@@ -5186,6 +5243,10 @@ func (f *Fpdf) puttrailer() {
 	//	f.out("/ID [()()]")
 	}
 	uid1 := smart.StrToUpper(smart.Sh3a224(uuid.Uuid17Seq()))
+	if f.protect.encrypted {
+		// the encryption key depends on the first element of the ID
+		uid1 = fmt.Sprintf("%X", f.protect.fileID)
+	}
 	uid2 := smart.StrToUpper(smart.Sh3a224(uuid.Uuid13Str()))
 	f.outf("/ID[<%s><%s>]", uid1, uid2) // unixman: PDF/A
 }
@@ -5212,12 +5273,12 @@ func (f *Fpdf) puticc() {
 		mem := xmem.compress(iccData)
 		data := mem.bytes()
 	//	f.outf("<< /Filter /FlateDecode /Length %d /N 3 >>", len(data))
-		f.outf("<< /Filter /FlateDecode /Length %d /Params << /CheckSum <%s> /Size %d >> /N 3 >>", len(data), sum, len(iccData))
+		f.outf("<< /Filter /FlateDecode /Length %d /Params << /CheckSum <%s> /Size %d >> /N 3 >>", f.protect.length(len(data)), sum, len(iccData))
 		f.putstream(data)
 		mem.release()
 	} else {
 	//-- #
-		f.outf("<< /Length %d /N 3 >>", len(iccData))
+		f.outf("<< /Length %d /N 3 >>", f.protect.length(len(iccData)))
 		f.putstream(iccData)
 	}
 	f.out("endobj")
@@ -5251,13 +5312,13 @@ func (f *Fpdf) putxmp() {
 	if(f.compress && f.compressXMP) { // unixman: PDF/A compliancy does not pass: Metadata object stream contains Filter key
 		mem := xmem.compress(f.xmp)
 		data := mem.bytes()
-		f.outf("<< /Type /Metadata /Subtype /XML /Filter /FlateDecode /Length %d >>", len(data))
+		f.outf("<< /Type /Metadata /Subtype /XML /Filter /FlateDecode /Length %d >>", f.protect.length(len(data)))
 		f.putstream(data)
 		mem.release()
 		//println("Using Compressed XMP")
 	} else {
 		//println("Using Normal XMP")
-		f.outf("<< /Type /Metadata /Subtype /XML /Length %d >>", len(f.xmp))
+		f.outf("<< /Type /Metadata /Subtype /XML /Length %d >>", f.protect.length(len(f.xmp)))
 		f.putstream(f.xmp)
 	}
 	f.out("endobj")
@@ -2818,3 +2818,44 @@ func ExampleFpdf_RoundedRect_rotated() {
 	// Output:
 	// Successfully generated pdf/Fpdf_RoundedRect_rotated.pdf
 }
+
+// ExampleFpdf_AddTextField demonstrates the interactive form fields: text
+// fields, check boxes, radio buttons, drop-down and scrollable lists, and push
+// buttons.
+func ExampleFpdf_AddTextField() {
+	pdf := fpdf.New("P", "mm", "A4", "")
+	pdf.AddUTF8Font("dejavu", "", example.FontFile("DejaVuSansCondensed.ttf"))
+	pdf.SetFont("dejavu", "", 12)
+	pdf.AddPage()
+	pdf.SetDrawColor(64, 64, 128)
+	pdf.SetFormTabOrder("R")
+
+	label := func(y float64, str string) {
+		pdf.Text(20, y+5, str)
+	}
+	label(20, "Name")
+	pdf.AddTextField("name", 60, 20, 100, 7, "", fpdf.FieldRequired)
+	label(30, "Email")
+	pdf.AddTextField("email", 60, 30, 100, 7, "someone@example.com", 0)
+	label(40, "Comments")
+	pdf.AddMultilineTextField("comments", 60, 40, 100, 25, "Line one\nLine two", 0)
+	label(70, "Subscribe")
+	pdf.AddCheckBox("subscribe", 60, 71, 5, true, 0)
+	label(80, "Size")
+	for j, size := range []string{"Small", "Medium", "Large"} {
+		x := 60 + float64(j)*35
+		pdf.AddRadioButton("size", size, x, 81, 5, size == "Medium", 0)
+		pdf.Text(x+7, 85, size)
+	}
+	label(90, "Country")
+	pdf.AddComboBox("country", 60, 90, 60, 7, []string{"France", "Germany", "Italy", "Spain"}, "Italy", 0)
+	label(100, "Colors")
+	pdf.AddListBox("colors", 60, 100, 60, 20, []string{"Red", "Green", "Blue", "Yellow"}, "Blue", 0)
+	pdf.AddPushButton("print", "Print", 60, 125, 30, 8, "this.print();", fpdf.FieldNoExport)
+
+	fileStr := example.Filename("Fpdf_AddTextField")
+	err := pdf.OutputFileAndClose(fileStr)
+	example.SummaryCompare(err, fileStr)
+	// Output:
+	// Successfully generated pdf/Fpdf_AddTextField.pdf
+}
@@ -32,6 +32,7 @@ import (
 	"os"
 	"path/filepath"
 	"runtime"
+	"strings"
 	"testing"
 
 	"github.com/unix-world/smartgoext/pdf/fpdf"
@@ -331,3 +332,88 @@ func BenchmarkCurveTo(b *testing.B) {
 		pdf.CurveTo(190, 100, 105, 100)
 	}
 }
+
+// TestSetProtectionAlgorithm checks the encryption dictionary and the version
+// of documents protected with AES.
+func TestSetProtectionAlgorithm(t *testing.T) {
+	for _, tc := range []struct {
+		algorithm, version, filter string
+	}{
+		{fpdf.ProtectionAES128, "%PDF-1.6", "/CFM /AESV2"},
+		{fpdf.ProtectionAES256, "%PDF-2.0", "/CFM /AESV3"},
+	} {
+		pdf := fpdf.New("P", "mm", "A4", "")
+		pdf.SetProtectionAlgorithm(fpdf.CnProtectPrint, "123", "abc", tc.algorithm)
+		pdf.AddPage()
+		var buf bytes.Buffer
+		if err := pdf.Output(&buf); err != nil {
+			t.Fatalf("%s: %v", tc.algorithm, err)
+		}
+		out := buf.String()
+		if !strings.HasPrefix(out, tc.version) {
+			t.Errorf("%s: document does not start with %s", tc.algorithm, tc.version)
+		}
+		if !strings.Contains(out, tc.filter) {
+			t.Errorf("%s: encryption dictionary without %s", tc.algorithm, tc.filter)
+		}
+	}
+
+	pdf := fpdf.New("P", "mm", "A4", "")
+	pdf.SetProtectionAlgorithm(fpdf.CnProtectPrint, "123", "abc", "DES")
+	if pdf.Error() == nil {
+		t.Fatal("expecting error for an unsupported algorithm")
+	}
+}
+
+func TestFormFields(t *testing.T) {
+	pdf := fpdf.New("P", "mm", "A4", "")
+	pdf.SetCompression(false)
+	pdf.SetFormTabOrder("R")
+	pdf.AddPage()
+	pdf.AddTextField("name", 10, 10, 80, 7, "Jane", fpdf.FieldRequired)
+	pdf.AddCheckBox("agree", 10, 20, 5, true, 0)
+	pdf.AddRadioButton("size", "S", 10, 30, 5, false, 0)
+	pdf.AddRadioButton("size", "L", 20, 30, 5, true, 0)
+	pdf.AddComboBox("country", 10, 40, 50, 7, []string{"France", "Italy"}, "Italy", 0)
+	pdf.AddPushButton("print", "Print", 10, 50, 30, 8, "this.print();", 0)
+	var buf bytes.Buffer
+	if err := pdf.Output(&buf); err != nil {
+		t.Fatal(err)
+	}
+	out := buf.String()
+	for _, s := range []string{"/AcroForm", "/Tabs /R", "/FT /Tx", "/FT /Btn", "/FT /Ch", "/V /L", "/V /Yes"} {
+		if !strings.Contains(out, s) {
+			t.Errorf("document without %s", s)
+		}
+	}
+	if got := strings.Count(out, "/Subtype /Widget"); got != 6 {
+		t.Errorf("got %d widgets, want 6", got)
+	}
+
+	for _, tc := range []struct {
+		name string
+		fn   func(pdf *fpdf.Fpdf)
+	}{
+		{"duplicate name", func(pdf *fpdf.Fpdf) {
+			pdf.AddTextField("a", 10, 10, 50, 7, "", 0)
+			pdf.AddCheckBox("a", 10, 20, 5, false, 0)
+		}},
+		{"invalid option", func(pdf *fpdf.Fpdf) {
+			pdf.AddListBox("a", 10, 10, 50, 20, []string{"x", "y"}, "z", 0)
+		}},
+		{"duplicate radio value", func(pdf *fpdf.Fpdf) {
+			pdf.AddRadioButton("a", "x", 10, 10, 5, false, 0)
+			pdf.AddRadioButton("a", "x", 20, 10, 5, false, 0)
+		}},
+		{"invalid tab order", func(pdf *fpdf.Fpdf) {
+			pdf.SetFormTabOrder("X")
+		}},
+	} {
+		pdf := fpdf.New("P", "mm", "A4", "")
+		pdf.AddPage()
+		tc.fn(pdf)
+		if pdf.Error() == nil {
+			t.Errorf("%s: expecting error", tc.name)
+		}
+	}
+}
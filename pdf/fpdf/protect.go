@@ -28,10 +28,14 @@
 package fpdf
 
 import (
+	"crypto/aes"
+	"crypto/cipher"
 	"crypto/md5"
+	"crypto/rand"
 	"crypto/rc4"
+	"crypto/sha256"
+	"crypto/sha512"
 	"encoding/binary"
-	"math/rand"
 )
 
 // Advisory bitflag constants that control document activities
@@ -42,24 +46,74 @@ const (
 	CnProtectAnnotForms = 32
 )
 
+const (
+	// ProtectionRC4 represents the 40-bit RC4 encryption of revision 2 of the
+	// standard security handler, kept for compatibility
+	ProtectionRC4 = "RC4"
+	// ProtectionAES128 represents the AES-128 encryption of revision 4 of the
+	// standard security handler (PDF 1.6)
+	ProtectionAES128 = "AES-128"
+	// ProtectionAES256 represents the AES-256 encryption of revision 6 of the
+	// standard security handler, with a key derived by SHA-256 (PDF 2.0)
+	ProtectionAES256 = "AES-256"
+)
+
 type protectType struct {
 	encrypted     bool
+	algorithm     string
 	uValue        []byte
 	oValue        []byte
+	ueValue       []byte // AES-256 only
+	oeValue       []byte // AES-256 only
+	permsValue    []byte // AES-256 only
 	pValue        int
 	padding       []byte
 	encryptionKey []byte
+	fileID        []byte // first element of the trailer ID
 	objNum        int
-	rc4cipher     *rc4.Cipher
-	rc4n          uint32 // Object number associated with rc4 cipher
+}
+
+// encrypt encrypts a string or a stream of object n
+func (p *protectType) encrypt(n uint32, buf []byte) []byte {
+	switch p.algorithm {
+	case ProtectionAES128, ProtectionAES256:
+		return p.aes(n, buf)
+	}
+	p.rc4(n, &buf)
+	return buf
 }
 
 func (p *protectType) rc4(n uint32, buf *[]byte) {
-	if p.rc4cipher == nil || p.rc4n != n {
-		p.rc4cipher, _ = rc4.NewCipher(p.objectKey(n))
-		p.rc4n = n
+	// every string and stream starts a new key stream
+	c, _ := rc4.NewCipher(p.objectKey(n))
+	c.XORKeyStream(*buf, *buf)
+}
+
+// aes encrypts buf in CBC mode, preceded by a random initialization vector and
+// padded as defined in RFC 8018
+func (p *protectType) aes(n uint32, buf []byte) []byte {
+	key := p.encryptionKey
+	if p.algorithm == ProtectionAES128 {
+		key = p.objectKey(n)
+	}
+	block, _ := aes.NewCipher(key)
+	pad := aes.BlockSize - len(buf)%aes.BlockSize
+	v := make([]byte, aes.BlockSize+len(buf)+pad)
+	randomBytes(v[:aes.BlockSize])
+	copy(v[aes.BlockSize:], buf)
+	for j := aes.BlockSize + len(buf); j < len(v); j++ {
+		v[j] = byte(pad)
+	}
+	cipher.NewCBCEncrypter(block, v[:aes.BlockSize]).CryptBlocks(v[aes.BlockSize:], v[aes.BlockSize:])
+	return v
+}
+
+// length returns the length of a string or a stream of n bytes once encrypted
+func (p *protectType) length(n int) int {
+	if p.encrypted && (p.algorithm == ProtectionAES128 || p.algorithm == ProtectionAES256) {
+		return aes.BlockSize + n + aes.BlockSize - n%aes.BlockSize
 	}
-	p.rc4cipher.XORKeyStream(*buf, *buf)
+	return n
 }
 
 func (p *protectType) objectKey(n uint32) []byte {
@@ -68,8 +122,15 @@ func (p *protectType) objectKey(n uint32) []byte {
 	binary.LittleEndian.PutUint32(nbuf, n)
 	b = append(b, p.encryptionKey...)
 	b = append(b, nbuf[0], nbuf[1], nbuf[2], 0, 0)
+	if p.algorithm == ProtectionAES128 {
+		b = append(b, "sAlT"...)
+	}
 	s := md5.Sum(b)
-	return s[0:10]
+	return s[0:min(len(p.encryptionKey)+5, 16)]
+}
+
+func randomBytes(b []byte) {
+	_, _ = rand.Read(b)
 }
 
 func oValueGen(userPass, ownerPass []byte) (v []byte) {
@@ -92,6 +153,10 @@ func (p *protectType) uValueGen() (v []byte) {
 }
 
 func (p *protectType) setProtection(privFlag byte, userPassStr, ownerPassStr string) {
+	p.setProtectionAlgorithm(privFlag, userPassStr, ownerPassStr, ProtectionRC4)
+}
+
+func (p *protectType) setProtectionAlgorithm(privFlag byte, userPassStr, ownerPassStr, algorithmStr string) {
 	privFlag = 192 | (privFlag & (CnProtectCopy | CnProtectModify | CnProtectPrint | CnProtectAnnotForms))
 	p.padding = []byte{
 		0x28, 0xBF, 0x4E, 0x5E, 0x4E, 0x75, 0x8A, 0x41,
@@ -102,21 +167,171 @@ func (p *protectType) setProtection(privFlag byte, userPassStr, ownerPassStr str
 	userPass := []byte(userPassStr)
 	var ownerPass []byte
 	if ownerPassStr == "" {
-		ownerPass = make([]byte, 8)
-		binary.LittleEndian.PutUint64(ownerPass, uint64(rand.Int63()))
+		ownerPass = make([]byte, 16)
+		randomBytes(ownerPass)
 	} else {
 		ownerPass = []byte(ownerPassStr)
 	}
+	p.fileID = make([]byte, 16)
+	randomBytes(p.fileID)
+	p.algorithm = algorithmStr
+	p.encrypted = true
+	switch algorithmStr {
+	case ProtectionAES128:
+		p.setAES128(permissions(privFlag), userPass, ownerPass)
+		return
+	case ProtectionAES256:
+		p.setAES256(permissions(privFlag), userPass, ownerPass)
+		return
+	}
 	userPass = append(userPass, p.padding...)[0:32]
 	ownerPass = append(ownerPass, p.padding...)[0:32]
-	p.encrypted = true
 	p.oValue = oValueGen(userPass, ownerPass)
 	var buf []byte
 	buf = append(buf, userPass...)
 	buf = append(buf, p.oValue...)
 	buf = append(buf, privFlag, 0xff, 0xff, 0xff)
+	buf = append(buf, p.fileID...)
 	sum := md5.Sum(buf)
 	p.encryptionKey = sum[0:5]
 	p.uValue = p.uValueGen()
 	p.pValue = -(int(privFlag^255) + 1)
 }
+
+// permissions returns the P value of revisions 4 and 6, where the reserved
+// bits are set and the permissions of revision 3 follow the ones of privFlag
+func permissions(privFlag byte) uint32 {
+	perms := 0xFFFFF000 | uint32(privFlag)
+	perms |= 1 << 9 // text extraction for accessibility, always granted
+	if privFlag&CnProtectAnnotForms != 0 {
+		perms |= 1 << 8 // form filling
+	}
+	if privFlag&CnProtectModify != 0 {
+		perms |= 1 << 10 // document assembly
+	}
+	if privFlag&CnProtectPrint != 0 {
+		perms |= 1 << 11 // high quality printing
+	}
+	return perms
+}
+
+// setAES128 computes the values of revision 4, with the algorithms 2, 3 and 5
+// of ISO 32000-1
+func (p *protectType) setAES128(perms uint32, userPass, ownerPass []byte) {
+	userPass = append(userPass, p.padding...)[0:32]
+	ownerPass = append(ownerPass, p.padding...)[0:32]
+	p.oValue = rc4Rounds(md5Rounds(ownerPass, 16), userPass)
+
+	var buf []byte
+	buf = append(buf, userPass...)
+	buf = append(buf, p.oValue...)
+	buf = binary.LittleEndian.AppendUint32(buf, perms)
+	buf = append(buf, p.fileID...)
+	p.encryptionKey = md5Rounds(buf, 16)
+
+	sum := md5.Sum(append(append([]byte{}, p.padding...), p.fileID...))
+	p.uValue = append(rc4Rounds(p.encryptionKey, sum[:]), p.padding[0:16]...)
+	p.pValue = int(int32(perms))
+}
+
+// md5Rounds returns the first n bytes of the MD5 hash of b, hashed 50 more
+// times
+func md5Rounds(b []byte, n int) []byte {
+	sum := md5.Sum(b)
+	for j := 0; j < 50; j++ {
+		sum = md5.Sum(sum[0:n])
+	}
+	return sum[0:n]
+}
+
+// rc4Rounds encrypts b with key, then 19 more times with key xored with the
+// round number
+func rc4Rounds(key, b []byte) []byte {
+	v := make([]byte, len(b))
+	copy(v, b)
+	k := make([]byte, len(key))
+	for i := 0; i < 20; i++ {
+		for j := range key {
+			k[j] = key[j] ^ byte(i)
+		}
+		c, _ := rc4.NewCipher(k)
+		c.XORKeyStream(v, v)
+	}
+	return v
+}
+
+// setAES256 computes the values of revision 6, with the algorithms 8, 9 and 10
+// of ISO 32000-2. The passwords are used as UTF-8, without SASLprep.
+func (p *protectType) setAES256(perms uint32, userPass, ownerPass []byte) {
+	userPass = userPass[0:min(len(userPass), 127)]
+	ownerPass = ownerPass[0:min(len(ownerPass), 127)]
+	p.encryptionKey = make([]byte, 32)
+	randomBytes(p.encryptionKey)
+	// validation and key salts of the user, then of the owner
+	salts := make([]byte, 32)
+	randomBytes(salts)
+
+	p.uValue = append(hashR6(userPass, salts[0:8], nil), salts[0:16]...)
+	p.ueValue = encryptKeyR6(hashR6(userPass, salts[8:16], nil), p.encryptionKey)
+	p.oValue = append(hashR6(ownerPass, salts[16:24], p.uValue), salts[16:32]...)
+	p.oeValue = encryptKeyR6(hashR6(ownerPass, salts[24:32], p.uValue), p.encryptionKey)
+
+	perm := make([]byte, 16)
+	binary.LittleEndian.PutUint32(perm, perms)
+	copy(perm[4:], "\xff\xff\xff\xffTadb") // metadata is encrypted
+	randomBytes(perm[12:])
+	block, _ := aes.NewCipher(p.encryptionKey)
+	block.Encrypt(perm, perm)
+	p.permsValue = perm
+	p.pValue = int(int32(perms))
+}
+
+// hashR6 is the hash of a password of revision 6, algorithm 2.B of ISO
+// 32000-2. userKey is the U value for the owner password, nil otherwise.
+func hashR6(password, salt, userKey []byte) []byte {
+	h := sha256.New()
+	h.Write(password)
+	h.Write(salt)
+	h.Write(userKey)
+	k := h.Sum(nil)
+	for round := 0; ; round++ {
+		var k1 []byte
+		for j := 0; j < 64; j++ {
+			k1 = append(k1, password...)
+			k1 = append(k1, k...)
+			k1 = append(k1, userKey...)
+		}
+		block, _ := aes.NewCipher(k[0:16])
+		e := make([]byte, len(k1))
+		cipher.NewCBCEncrypter(block, k[16:32]).CryptBlocks(e, k1)
+		// the first 16 bytes of e as a number modulo 3
+		var sum int
+		for _, c := range e[0:16] {
+			sum += int(c)
+		}
+		switch sum % 3 {
+		case 0:
+			s := sha256.Sum256(e)
+			k = s[:]
+		case 1:
+			s := sha512.Sum384(e)
+			k = s[:]
+		default:
+			s := sha512.Sum512(e)
+			k = s[:]
+		}
+		if round >= 63 && int(e[len(e)-1]) <= round-31 {
+			break
+		}
+	}
+	return k[0:32]
+}
+
+// encryptKeyR6 encrypts the file key with AES-256 in CBC mode, with a zero
+// initialization vector and no padding
+func encryptKeyR6(key, fileKey []byte) []byte {
+	block, _ := aes.NewCipher(key)
+	v := make([]byte, len(fileKey))
+	cipher.NewCBCEncrypter(block, make([]byte, aes.BlockSize)).CryptBlocks(v, fileKey)
+	return v
+}
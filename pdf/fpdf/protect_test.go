@@ -0,0 +1,111 @@
+// Copyright ©2023 The go-pdf Authors. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package fpdf
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"encoding/binary"
+	"testing"
+)
+
+// decryptAES decrypts a string or a stream encrypted by protectType.aes.
+func decryptAES(t *testing.T, key, b []byte) []byte {
+	t.Helper()
+	if len(b) < 2*aes.BlockSize || len(b)%aes.BlockSize != 0 {
+		t.Fatalf("invalid length %d of encrypted data", len(b))
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	v := make([]byte, len(b)-aes.BlockSize)
+	cipher.NewCBCDecrypter(block, b[:aes.BlockSize]).CryptBlocks(v, b[aes.BlockSize:])
+	pad := int(v[len(v)-1])
+	if pad < 1 || pad > aes.BlockSize {
+		t.Fatalf("invalid padding %d", pad)
+	}
+	return v[:len(v)-pad]
+}
+
+func TestProtectEncrypt(t *testing.T) {
+	for _, algorithm := range []string{ProtectionRC4, ProtectionAES128, ProtectionAES256} {
+		var p protectType
+		p.setProtectionAlgorithm(CnProtectPrint, "123", "abc", algorithm)
+		for _, n := range []int{0, 1, 15, 16, 17, 100} {
+			b := bytes.Repeat([]byte{'x'}, n)
+			v := p.encrypt(7, append([]byte(nil), b...))
+			if len(v) != p.length(n) {
+				t.Errorf("%s: length of %d bytes encrypted: got %d, want %d", algorithm, n, len(v), p.length(n))
+			}
+			if algorithm == ProtectionRC4 {
+				continue
+			}
+			key := p.encryptionKey
+			if algorithm == ProtectionAES128 {
+				key = p.objectKey(7)
+			}
+			if got := decryptAES(t, key, v); !bytes.Equal(got, b) {
+				t.Errorf("%s: decrypted %q, want %q", algorithm, got, b)
+			}
+		}
+	}
+}
+
+func TestProtectAES256(t *testing.T) {
+	var p protectType
+	p.setProtectionAlgorithm(CnProtectPrint|CnProtectCopy, "123", "abc", ProtectionAES256)
+	if len(p.uValue) != 48 || len(p.oValue) != 48 || len(p.ueValue) != 32 || len(p.oeValue) != 32 {
+		t.Fatalf("invalid lengths of U, O, UE, OE: %d, %d, %d, %d",
+			len(p.uValue), len(p.oValue), len(p.ueValue), len(p.oeValue))
+	}
+
+	decryptKey := func(key, encrypted []byte) []byte {
+		block, err := aes.NewCipher(key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		v := make([]byte, len(encrypted))
+		cipher.NewCBCDecrypter(block, make([]byte, aes.BlockSize)).CryptBlocks(v, encrypted)
+		return v
+	}
+
+	// user password, algorithm 11 and 2.A of ISO 32000-2
+	if !bytes.Equal(hashR6([]byte("123"), p.uValue[32:40], nil), p.uValue[:32]) {
+		t.Error("user password not validated")
+	}
+	if bytes.Equal(hashR6([]byte("abc"), p.uValue[32:40], nil), p.uValue[:32]) {
+		t.Error("owner password validated as user password")
+	}
+	if key := decryptKey(hashR6([]byte("123"), p.uValue[40:48], nil), p.ueValue); !bytes.Equal(key, p.encryptionKey) {
+		t.Error("file key not decrypted with the user password")
+	}
+
+	// owner password, algorithm 12
+	if !bytes.Equal(hashR6([]byte("abc"), p.oValue[32:40], p.uValue), p.oValue[:32]) {
+		t.Error("owner password not validated")
+	}
+	if key := decryptKey(hashR6([]byte("abc"), p.oValue[40:48], p.uValue), p.oeValue); !bytes.Equal(key, p.encryptionKey) {
+		t.Error("file key not decrypted with the owner password")
+	}
+
+	// permissions, algorithm 13
+	block, err := aes.NewCipher(p.encryptionKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	perms := make([]byte, aes.BlockSize)
+	block.Decrypt(perms, p.permsValue)
+	if string(perms[8:12]) != "Tadb" {
+		t.Errorf("invalid Perms %q", perms)
+	}
+	if got := int32(binary.LittleEndian.Uint32(perms)); int(got) != p.pValue {
+		t.Errorf("Perms has P %d, want %d", got, p.pValue)
+	}
+	if want := int32(-1324); int(want) != p.pValue {
+		t.Errorf("P is %d, want %d", p.pValue, want)
+	}
+}
@@ -219,7 +219,7 @@ func (f *Fpdf) putTemplates() {
 			mem = xmem.compress(buffer)
 			buffer = mem.bytes()
 		}
-		f.outf("/Length %d >>", len(buffer))
+		f.outf("/Length %d >>", f.protect.length(len(buffer)))
 		f.putstream(buffer)
 		f.out("endobj")
 		if mem != nil {